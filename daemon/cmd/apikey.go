@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/domain"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/services/auth"
+)
+
+// APIKey groups the API key management subcommands.
+type APIKey struct {
+	Create APIKeyCreate `cmd:"" help:"create a new API key"`
+	List   APIKeyList   `cmd:"" help:"list configured API keys"`
+	Revoke APIKeyRevoke `cmd:"" help:"revoke an API key"`
+}
+
+// APIKeyCreate creates a new named API key and prints it once.
+type APIKeyCreate struct {
+	Name   string   `required:"" help:"descriptive name for the key (e.g. home-assistant)"`
+	Scopes []string `name:"scope" default:"read" help:"scopes to grant: read, control, admin"`
+}
+
+// Run creates the API key and prints the plaintext value.
+func (c *APIKeyCreate) Run(ctx *domain.Context) error {
+	store, err := auth.NewKeyStore(ctx.APIKeysFile)
+	if err != nil {
+		return err
+	}
+
+	scopes := make([]auth.Scope, 0, len(c.Scopes))
+	for _, value := range c.Scopes {
+		scope, err := auth.ParseScope(value)
+		if err != nil {
+			return err
+		}
+		scopes = append(scopes, scope)
+	}
+
+	plaintext, key, err := store.Create(c.Name, scopes)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Created API key %s (%s) with scopes %v\n", key.ID, key.Name, key.Scopes)
+	fmt.Printf("Key: %s\n", plaintext)
+	fmt.Println("Store this key now, it cannot be shown again.")
+	return nil
+}
+
+// APIKeyList prints the configured API keys without their secrets.
+type APIKeyList struct{}
+
+// Run prints the configured API keys.
+func (c *APIKeyList) Run(ctx *domain.Context) error {
+	store, err := auth.NewKeyStore(ctx.APIKeysFile)
+	if err != nil {
+		return err
+	}
+
+	keys := store.List()
+	if len(keys) == 0 {
+		fmt.Println("No API keys configured, authentication is disabled")
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "ID\tNAME\tSCOPES\tCREATED")
+	for _, key := range keys {
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%v\t%s\n", key.ID, key.Name, key.Scopes, key.CreatedAt.Format(time.RFC3339))
+	}
+	return tw.Flush()
+}
+
+// APIKeyRevoke deletes an API key by ID or name.
+type APIKeyRevoke struct {
+	Key   string `arg:"" help:"ID or name of the key to revoke"`
+	Force bool   `help:"also revoke the last admin key, which turns authentication off when no other keys remain"`
+}
+
+// Run revokes the API key.
+func (c *APIKeyRevoke) Run(ctx *domain.Context) error {
+	store, err := auth.NewKeyStore(ctx.APIKeysFile)
+	if err != nil {
+		return err
+	}
+
+	if err := store.Revoke(c.Key, c.Force); err != nil {
+		return err
+	}
+
+	fmt.Printf("Revoked API key %s\n", c.Key)
+	return nil
+}
@@ -21,8 +21,12 @@ const (
 	ProcUptime = "/proc/uptime"
 	// ProcStat is the path to the /proc/stat file.
 	ProcStat = "/proc/stat"
+	// ProcDir is the path to the /proc directory.
+	ProcDir = "/proc"
 	// SysHwmon is the path to the /sys/class/hwmon directory.
 	SysHwmon = "/sys/class/hwmon"
+	// SysPCIDevices is the path to the /sys/bus/pci/devices directory.
+	SysPCIDevices = "/sys/bus/pci/devices"
 
 	// SensorsBin is the path to the sensors binary.
 	SensorsBin = "/usr/bin/sensors"
@@ -30,10 +34,22 @@ const (
 	SmartctlBin = "/usr/sbin/smartctl"
 	// DockerBin is the path to the docker binary.
 	DockerBin = "/usr/bin/docker"
+	// DockerSocket is the path to the Docker Engine API socket.
+	DockerSocket = "/var/run/docker.sock"
+	// DockerTemplatesDir holds the dockerMan XML templates of the containers the user installed.
+	DockerTemplatesDir = "/boot/config/plugins/dockerMan/templates-user"
+	// DockerAutostartFile lists the containers dockerMan starts with the array, in order.
+	DockerAutostartFile = "/var/lib/docker/unraid-autostart"
 	// VirshBin is the path to the virsh binary.
 	VirshBin = "/usr/bin/virsh"
+	// LibvirtSocket is the path to the libvirtd remote protocol socket.
+	LibvirtSocket = "/var/run/libvirt/libvirt-sock"
 	// MdcmdBin is the path to the mdcmd binary.
 	MdcmdBin = "/usr/local/sbin/mdcmd"
+	// EmcmdBin is the path to the emcmd binary, which sends commands to emhttpd as the webGUI does.
+	EmcmdBin = "/usr/local/sbin/emcmd"
+	// HdparmBin is the path to the hdparm binary.
+	HdparmBin = "/usr/sbin/hdparm"
 	// ApcaccessBin is the path to the apcaccess binary.
 	ApcaccessBin = "/sbin/apcaccess"
 	// UpscBin is the path to the upsc binary.
@@ -48,6 +64,24 @@ const (
 	// ProcSPLARCStats is the path to the ZFS ARC statistics file.
 	ProcSPLARCStats = "/proc/spl/kstat/zfs/arcstats"
 
+	// PluginConfigDir is the persistent configuration directory for the agent on the flash drive.
+	PluginConfigDir = "/boot/config/plugins/unraid-management-agent"
+	// APIKeysFile is the path to the hashed API key store.
+	APIKeysFile = PluginConfigDir + "/apikeys.json"
+	// SelfSignedCertFile is the path of the auto-generated TLS certificate.
+	SelfSignedCertFile = PluginConfigDir + "/tls/agent.crt"
+	// SelfSignedKeyFile is the path of the auto-generated TLS private key.
+	SelfSignedKeyFile = PluginConfigDir + "/tls/agent.key"
+	// AlertRulesFile is the path to the alert rule definitions.
+	AlertRulesFile = PluginConfigDir + "/alerts.json"
+	// WebhooksFile is the path to the webhook targets; failed deliveries are kept next to it.
+	WebhooksFile = PluginConfigDir + "/webhooks.json"
+	// SelfTestFile is the path to the SMART self-test schedule.
+	SelfTestFile = PluginConfigDir + "/selftest.json"
+	// HistoryFile is the default path of the persisted metric history. It lives in RAM, so it
+	// survives agent restarts and upgrades but not a reboot; a path under /boot keeps it on flash.
+	HistoryFile = "/var/local/unraid-management-agent/history.gob.gz"
+
 	// NutPidFile is the path to the NUT UPS monitor PID file.
 	NutPidFile = "/var/run/nut/upsmon.pid"
 	// ApcPidFile is the path to the APC UPS daemon PID file.
@@ -75,6 +109,13 @@ const (
 	IntervalHardware = 300
 	// IntervalZFS is the collection interval for ZFS metrics in seconds.
 	IntervalZFS = 30
+	// IntervalHistorySave is how often the metric history is written to disk in seconds.
+	IntervalHistorySave = 900
+	// IntervalHistorySaveFlash is how often the metric history is written when its file is on
+	// the flash drive, in seconds. It is also saved on shutdown.
+	IntervalHistorySaveFlash = 86400
+	// IntervalDockerUpdates is how often container images are checked against their registry in seconds.
+	IntervalDockerUpdates = 21600
 
 	// WSPingInterval is the WebSocket ping interval in seconds.
 	WSPingInterval = 30
@@ -82,4 +123,13 @@ const (
 	WSMaxClients = 10
 	// WSBufferSize is the WebSocket message buffer size.
 	WSBufferSize = 256
+
+	// DiskSpinHistorySize is how many spin state changes are kept per disk.
+	DiskSpinHistorySize = 500
+
+	// VMConsoleIdleTimeout is how long a VM console session may go without keyboard or mouse
+	// input before it is closed, in seconds.
+	VMConsoleIdleTimeout = 900
+	// VMConsoleMaxIdleTimeout is the longest idle timeout a console session may ask for, in seconds.
+	VMConsoleMaxIdleTimeout = 14400
 )
@@ -0,0 +1,112 @@
+package constants
+
+// Event bus topics published by the collectors.
+const (
+	// TopicSystemUpdate carries *dto.SystemInfo.
+	TopicSystemUpdate = "system_update"
+	// TopicArrayStatusUpdate carries *dto.ArrayStatus.
+	TopicArrayStatusUpdate = "array_status_update"
+	// TopicDiskListUpdate carries []dto.DiskInfo.
+	TopicDiskListUpdate = "disk_list_update"
+	// TopicDiskSMARTUpdate carries []dto.DiskSMART.
+	TopicDiskSMARTUpdate = "disk_smart_update"
+	// TopicShareListUpdate carries []dto.ShareInfo.
+	TopicShareListUpdate = "share_list_update"
+	// TopicContainerListUpdate carries []*dto.ContainerInfo.
+	TopicContainerListUpdate = "container_list_update"
+	// TopicVMListUpdate carries []*dto.VMInfo.
+	TopicVMListUpdate = "vm_list_update"
+	// TopicUPSStatusUpdate carries *dto.UPSStatus.
+	TopicUPSStatusUpdate = "ups_status_update"
+	// TopicGPUMetricsUpdate carries []*dto.GPUMetrics.
+	TopicGPUMetricsUpdate = "gpu_metrics_update"
+	// TopicNetworkListUpdate carries []dto.NetworkInfo.
+	TopicNetworkListUpdate = "network_list_update"
+	// TopicHardwareUpdate carries *dto.HardwareInfo.
+	TopicHardwareUpdate = "hardware_update"
+	// TopicRegistrationUpdate carries *dto.Registration.
+	TopicRegistrationUpdate = "registration_update"
+	// TopicNotificationsUpdate carries *dto.NotificationList.
+	TopicNotificationsUpdate = "notifications_update"
+	// TopicUnassignedDevicesUpdate carries *dto.UnassignedDeviceList.
+	TopicUnassignedDevicesUpdate = "unassigned_devices_update"
+	// TopicZFSPoolsUpdate carries []dto.ZFSPool.
+	TopicZFSPoolsUpdate = "zfs_pools_update"
+	// TopicZFSDatasetsUpdate carries []dto.ZFSDataset.
+	TopicZFSDatasetsUpdate = "zfs_datasets_update"
+	// TopicZFSSnapshotsUpdate carries []dto.ZFSSnapshot.
+	TopicZFSSnapshotsUpdate = "zfs_snapshots_update"
+	// TopicZFSARCStatsUpdate carries dto.ZFSARCStats.
+	TopicZFSARCStatsUpdate = "zfs_arc_stats_update"
+)
+
+// Event bus topics carrying one-off events rather than periodic state updates.
+const (
+	// TopicAlertEvent carries *dto.AlertEvent.
+	TopicAlertEvent = "alert_event"
+	// TopicContainerEvent carries *dto.ContainerEvent.
+	TopicContainerEvent = "container_event"
+	// TopicDockerBatchJob carries *dto.DockerBatchJob.
+	TopicDockerBatchJob = "docker_batch_job"
+)
+
+// CollectorTopics returns every topic published by the collectors. It is the single list used
+// by the API cache, the WebSocket stream and other subscribers, so a topic added here is
+// cached and streamed everywhere. Every collector Topic* constant above must be listed.
+func CollectorTopics() []string {
+	return []string{
+		TopicSystemUpdate,
+		TopicArrayStatusUpdate,
+		TopicDiskListUpdate,
+		TopicDiskSMARTUpdate,
+		TopicShareListUpdate,
+		TopicContainerListUpdate,
+		TopicVMListUpdate,
+		TopicUPSStatusUpdate,
+		TopicGPUMetricsUpdate,
+		TopicNetworkListUpdate,
+		TopicHardwareUpdate,
+		TopicRegistrationUpdate,
+		TopicNotificationsUpdate,
+		TopicUnassignedDevicesUpdate,
+		TopicZFSPoolsUpdate,
+		TopicZFSDatasetsUpdate,
+		TopicZFSSnapshotsUpdate,
+		TopicZFSARCStatsUpdate,
+	}
+}
+
+// IsCollectorTopic reports whether topic is published by a collector.
+func IsCollectorTopic(topic string) bool {
+	for _, t := range CollectorTopics() {
+		if t == topic {
+			return true
+		}
+	}
+	return false
+}
+
+// AgentTopics returns every event topic, published by agent services such as the alert engine,
+// the Docker events watcher or Docker batch jobs. These events are streamed but not cached.
+func AgentTopics() []string {
+	return []string{
+		TopicAlertEvent,
+		TopicContainerEvent,
+		TopicDockerBatchJob,
+	}
+}
+
+// AllTopics returns the collector topics followed by the agent topics.
+func AllTopics() []string {
+	return append(CollectorTopics(), AgentTopics()...)
+}
+
+// IsTopic reports whether topic is published by a collector or an agent service.
+func IsTopic(topic string) bool {
+	for _, t := range AllTopics() {
+		if t == topic {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,82 @@
+package constants
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// TestAllTopicsListsEveryTopic guards against a new Topic* constant being left out of
+// CollectorTopics or AgentTopics, which would stop it from being cached and streamed.
+func TestAllTopicsListsEveryTopic(t *testing.T) {
+	file, err := parser.ParseFile(token.NewFileSet(), "topics.go", nil, 0)
+	if err != nil {
+		t.Fatalf("failed to parse topics.go: %v", err)
+	}
+
+	declared := make(map[string]string)
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.CONST {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			value := spec.(*ast.ValueSpec)
+			for i, name := range value.Names {
+				if !strings.HasPrefix(name.Name, "Topic") {
+					continue
+				}
+				lit, ok := value.Values[i].(*ast.BasicLit)
+				if !ok {
+					t.Fatalf("%s is not a string literal", name.Name)
+				}
+				declared[name.Name], _ = strconv.Unquote(lit.Value)
+			}
+		}
+	}
+
+	if len(declared) == 0 {
+		t.Fatal("no Topic* constants found")
+	}
+
+	listed := make(map[string]bool)
+	for _, topic := range AllTopics() {
+		if listed[topic] {
+			t.Errorf("topic %s listed more than once", topic)
+		}
+		listed[topic] = true
+	}
+
+	for name, topic := range declared {
+		if !listed[topic] {
+			t.Errorf("%s (%q) is missing from CollectorTopics() and AgentTopics()", name, topic)
+		}
+	}
+	if len(listed) != len(declared) {
+		t.Errorf("AllTopics() has %d topics, %d Topic* constants declared", len(listed), len(declared))
+	}
+}
+
+func TestIsCollectorTopic(t *testing.T) {
+	if !IsCollectorTopic(TopicNotificationsUpdate) {
+		t.Errorf("IsCollectorTopic(%q) = false", TopicNotificationsUpdate)
+	}
+	if IsCollectorTopic("update") {
+		t.Error(`IsCollectorTopic("update") = true`)
+	}
+}
+
+func TestIsTopic(t *testing.T) {
+	if !IsTopic(TopicAlertEvent) || !IsTopic(TopicSystemUpdate) {
+		t.Error("IsTopic() = false for a declared topic")
+	}
+	if IsCollectorTopic(TopicAlertEvent) {
+		t.Errorf("IsCollectorTopic(%q) = true for an agent topic", TopicAlertEvent)
+	}
+	if IsTopic("update") {
+		t.Error(`IsTopic("update") = true`)
+	}
+}
@@ -3,6 +3,34 @@ package domain
 
 // Config holds the application configuration settings.
 type Config struct {
-	Version string `json:"version"`
-	Port    int    `json:"port"`
+	Version     string `json:"version"`
+	Port        int    `json:"port"`
+	APIKeysFile string `json:"api_keys_file"`
+
+	// TLS settings; when TLSEnabled is set without a certificate, a self-signed one is generated
+	TLSEnabled      bool   `json:"tls_enabled"`
+	TLSCertFile     string `json:"tls_cert_file"`
+	TLSKeyFile      string `json:"tls_key_file"`
+	TLSClientCAFile string `json:"tls_client_ca_file"`
+
+	// MQTT settings; the MQTT publisher is disabled when MQTTBroker is empty
+	MQTTBroker          string `json:"mqtt_broker"`
+	MQTTUsername        string `json:"mqtt_username"`
+	MQTTPassword        string `json:"-"`
+	MQTTClientID        string `json:"mqtt_client_id"`
+	MQTTTopicPrefix     string `json:"mqtt_topic_prefix"`
+	MQTTDiscoveryPrefix string `json:"mqtt_discovery_prefix"`
+
+	// AlertRulesFile stores the alert rules; rules are kept in memory only when empty
+	AlertRulesFile string `json:"alert_rules_file"`
+
+	// WebhooksFile stores the webhook targets; the dead-letter queue is kept next to it
+	WebhooksFile string `json:"webhooks_file"`
+
+	// SelfTestFile stores the SMART self-test schedule and the last test of each disk
+	SelfTestFile string `json:"self_test_file"`
+
+	// Metric history settings; history is kept in memory only when HistoryFile is empty
+	HistoryFile      string `json:"history_file"`
+	HistoryRetention string `json:"history_retention"`
 }
@@ -0,0 +1,52 @@
+package dto
+
+import "time"
+
+// AlertRule is a threshold rule evaluated against metric samples
+type AlertRule struct {
+	ID              string    `json:"id"`
+	Name            string    `json:"name"`
+	Enabled         bool      `json:"enabled"`
+	Metric          string    `json:"metric"`           // a metric name from GET /history
+	Entity          string    `json:"entity,omitempty"` // empty matches every entity
+	Operator        string    `json:"operator"`         // ">", ">=", "<", "<=", "==", "!="
+	Threshold       float64   `json:"threshold"`
+	ForSeconds      int       `json:"for_seconds"`      // condition must hold this long before firing
+	Hysteresis      float64   `json:"hysteresis"`       // margin past the threshold required to resolve
+	CooldownSeconds int       `json:"cooldown_seconds"` // minimum time between firings per entity
+	Importance      string    `json:"importance"`       // "alert", "warning" or "info"
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// AlertEvent is published when a rule starts or stops firing for an entity
+type AlertEvent struct {
+	RuleID     string    `json:"rule_id"`
+	RuleName   string    `json:"rule_name"`
+	Metric     string    `json:"metric"`
+	Entity     string    `json:"entity,omitempty"`
+	State      string    `json:"state"` // "firing" or "resolved"
+	Value      float64   `json:"value"`
+	Operator   string    `json:"operator"`
+	Threshold  float64   `json:"threshold"`
+	Importance string    `json:"importance"`
+	Message    string    `json:"message"`
+	Timestamp  time.Time `json:"timestamp"`
+}
+
+// AlertStatus is the current state of a rule for one entity
+type AlertStatus struct {
+	RuleID   string    `json:"rule_id"`
+	RuleName string    `json:"rule_name"`
+	Metric   string    `json:"metric"`
+	Entity   string    `json:"entity,omitempty"`
+	State    string    `json:"state"` // "pending" or "firing"
+	Value    float64   `json:"value"`
+	Since    time.Time `json:"since"`
+}
+
+// AlertStatusList is the response of the active alerts endpoint
+type AlertStatusList struct {
+	Alerts    []AlertStatus `json:"alerts"`
+	Timestamp time.Time     `json:"timestamp"`
+}
@@ -11,6 +11,7 @@ type ArrayStatus struct {
 	TotalBytes          uint64    `json:"total_bytes"`
 	ParityValid         bool      `json:"parity_valid"`
 	ParityCheckStatus   string    `json:"parity_check_status"`
+	ParityCheckRunning  bool      `json:"parity_check_running"`
 	ParityCheckProgress float64   `json:"parity_check_progress"`
 	NumDisks            int       `json:"num_disks"`
 	NumDataDisks        int       `json:"num_data_disks"`
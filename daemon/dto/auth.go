@@ -0,0 +1,24 @@
+package dto
+
+import "time"
+
+// APIKeyInfo describes a configured API key without its secret
+type APIKeyInfo struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+// APIKeyCreateRequest is the request body for creating an API key
+type APIKeyCreateRequest struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"` // "read", "control", "admin"
+}
+
+// APIKeyCreateResponse contains the newly created key; the plaintext key is only returned once
+type APIKeyCreateResponse struct {
+	APIKeyInfo
+	Key string `json:"key"`
+}
@@ -15,6 +15,7 @@ type DiskInfo struct {
 	SMARTStatus   string  `json:"smart_status"`
 	SMARTErrors   int     `json:"smart_errors"`
 	SpindownDelay int     `json:"spindown_delay"`
+	Index         int     `json:"index"` // slot of the disk in emhttpd, which its settings are addressed by
 	FileSystem    string  `json:"filesystem"`
 
 	// Disk identification
@@ -52,3 +53,177 @@ type SMARTAttribute struct {
 	RawValue   string `json:"raw_value"`
 	WhenFailed string `json:"when_failed,omitempty"`
 }
+
+// DiskSpinRequest names the disks, by device, to spin up or down at once. No disks means all
+// array disks.
+type DiskSpinRequest struct {
+	Disks []string `json:"disks,omitempty"`
+}
+
+// DiskSpinResult is the outcome of a spin up or down request for one disk
+type DiskSpinResult struct {
+	Device    string `json:"device"`
+	Name      string `json:"name"`
+	Success   bool   `json:"success"`
+	SpinState string `json:"spin_state"` // state reported by the drive after the command
+	Error     string `json:"error,omitempty"`
+}
+
+// DiskSpinResponse is the response of a spin up or down request
+type DiskSpinResponse struct {
+	Success   bool             `json:"success"`
+	Message   string           `json:"message"`
+	Results   []DiskSpinResult `json:"results"`
+	Timestamp time.Time        `json:"timestamp"`
+}
+
+// DiskSpinEvent is a change of a disk's spin state
+type DiskSpinEvent struct {
+	Time  time.Time     `json:"time"`
+	From  string        `json:"from"`
+	To    string        `json:"to"`
+	Cause DiskSpinCause `json:"cause"`
+}
+
+// DiskSpinCause is what the agent saw around a spin state change. Disk states are sampled, so
+// the I/O and open files are those of the sampling interval in which the change was noticed.
+type DiskSpinCause struct {
+	Source     string            `json:"source"` // "api", "io" (wake-ups with I/O or open files), "idle" (spin-down timer) or "unknown"
+	ReadBytes  uint64            `json:"read_bytes"`
+	WriteBytes uint64            `json:"write_bytes"`
+	Shares     []string          `json:"shares,omitempty"`
+	Processes  []DiskSpinProcess `json:"processes,omitempty"`
+}
+
+// DiskSpinProcess is a process that had a file open on a disk when it woke. "shfs" is the Unraid
+// user share filesystem, so its files show which share was accessed.
+type DiskSpinProcess struct {
+	PID  int    `json:"pid"`
+	Name string `json:"name"`
+	Path string `json:"path"`
+}
+
+// DiskSpinHistory is the spin state history of one disk. Events are only included for a single
+// disk.
+type DiskSpinHistory struct {
+	Device            string          `json:"device"`
+	Name              string          `json:"name"`
+	SpinState         string          `json:"spin_state"`
+	WakeUps24h        int             `json:"wake_ups_24h"`
+	SpinDowns24h      int             `json:"spin_downs_24h"`
+	StandbySeconds24h int64           `json:"standby_seconds_24h"`
+	StandbyPercent24h float64         `json:"standby_percent_24h"`
+	LastChange        *time.Time      `json:"last_change,omitempty"`
+	Events            []DiskSpinEvent `json:"events,omitempty"`
+	TrackedSince      time.Time       `json:"tracked_since"`
+}
+
+// DiskSMART is the SMART data of a disk as read by smartctl. Disks in standby are not woken up
+// to read it, so it is kept from the last time the disk was active.
+type DiskSMART struct {
+	Device               string                    `json:"device"`
+	Name                 string                    `json:"name"`
+	Protocol             string                    `json:"protocol"` // "ATA", "NVMe" or "SCSI"
+	Model                string                    `json:"model,omitempty"`
+	SerialNumber         string                    `json:"serial_number,omitempty"`
+	Firmware             string                    `json:"firmware,omitempty"`
+	Status               string                    `json:"smart_status"` // "PASSED" or "FAILED"
+	Temperature          float64                   `json:"temperature_celsius,omitempty"`
+	PowerOnHours         uint64                    `json:"power_on_hours"`
+	PowerCycleCount      uint64                    `json:"power_cycle_count"`
+	ReallocatedSectors   uint64                    `json:"reallocated_sectors"`   // ATA attribute 5, SCSI grown defects
+	PendingSectors       uint64                    `json:"pending_sectors"`       // ATA attribute 197
+	OfflineUncorrectable uint64                    `json:"offline_uncorrectable"` // ATA attribute 198
+	ErrorLogCount        uint64                    `json:"error_log_count"`
+	Attributes           map[string]SMARTAttribute `json:"attributes,omitempty"` // ATA attributes by name
+	NVMeHealth           *NVMeHealth               `json:"nvme_health,omitempty"`
+	SelfTests            []SMARTSelfTest           `json:"self_tests"`
+	SelfTestRunning      *SMARTSelfTestProgress    `json:"self_test_running,omitempty"`
+	SelfTestMinutes      map[string]int            `json:"self_test_minutes,omitempty"` // expected duration by test type
+	Standby              bool                      `json:"standby"`                     // the disk was in standby at the last collection
+	UpdatedAt            time.Time                 `json:"updated_at"`
+}
+
+// NVMeHealth is the SMART / health information log of an NVMe device
+type NVMeHealth struct {
+	CriticalWarning         int    `json:"critical_warning"`
+	AvailableSpare          int    `json:"available_spare_percent"`
+	AvailableSpareThreshold int    `json:"available_spare_threshold_percent"`
+	PercentageUsed          int    `json:"percentage_used"`
+	BytesRead               uint64 `json:"bytes_read"`
+	BytesWritten            uint64 `json:"bytes_written"`
+	MediaErrors             uint64 `json:"media_errors"`
+	UnsafeShutdowns         uint64 `json:"unsafe_shutdowns"`
+	ErrorLogEntries         uint64 `json:"error_log_entries"`
+	WarningTempMinutes      uint64 `json:"warning_temp_minutes"`
+	CriticalTempMinutes     uint64 `json:"critical_temp_minutes"`
+}
+
+// SMARTSelfTest is an entry of a disk's self-test log, newest first
+type SMARTSelfTest struct {
+	Type             string  `json:"type"` // "short", "extended", "conveyance", "selective" or as reported
+	Status           string  `json:"status"`
+	Passed           bool    `json:"passed"`
+	RemainingPercent int     `json:"remaining_percent,omitempty"`
+	LifetimeHours    uint64  `json:"lifetime_hours"`
+	FirstErrorLBA    *uint64 `json:"first_error_lba,omitempty"`
+}
+
+// SMARTSelfTestProgress is the self-test a disk is running
+type SMARTSelfTestProgress struct {
+	Type            string `json:"type,omitempty"` // reported by NVMe devices only
+	ProgressPercent int    `json:"progress_percent"`
+}
+
+// DiskSelfTestRequest is the request to start a SMART self-test
+type DiskSelfTestRequest struct {
+	Type string `json:"type"` // "short" (default), "extended" ("long") or "conveyance"
+}
+
+// DiskSelfTest is the self-test state of a disk: the running test, how long each type takes and
+// the self-test log
+type DiskSelfTest struct {
+	Device          string                 `json:"device"`
+	Name            string                 `json:"name"`
+	Running         *SMARTSelfTestProgress `json:"running,omitempty"`
+	DurationMinutes map[string]int         `json:"duration_minutes,omitempty"`
+	Log             []SMARTSelfTest        `json:"log"`
+	Standby         bool                   `json:"standby"` // read before the disk spun down
+	UpdatedAt       time.Time              `json:"updated_at"`
+}
+
+// SelfTestSchedule configures the self-tests the agent starts on its own. Disks are tested one
+// after the other, up to MaxConcurrent at a time, each once every IntervalDays.
+type SelfTestSchedule struct {
+	Enabled         bool     `json:"enabled"`
+	Type            string   `json:"type"`            // "short", "extended" or "conveyance"
+	IntervalDays    int      `json:"interval_days"`   // time between two tests of a disk
+	MaxConcurrent   int      `json:"max_concurrent"`  // disks tested at the same time
+	Disks           []string `json:"disks,omitempty"` // disk names; all parity and data disks when empty
+	WakeDisks       bool     `json:"wake_disks"`      // start tests on disks in standby
+	WindowStartHour int      `json:"window_start_hour"`
+	WindowEndHour   int      `json:"window_end_hour"` // tests start between these local hours; equal hours allow any time
+}
+
+// SelfTestScheduleStatus is the self-test schedule with the state of each scheduled disk
+type SelfTestScheduleStatus struct {
+	Schedule           SelfTestSchedule     `json:"schedule"`
+	ParityCheckRunning bool                 `json:"parity_check_running"`
+	Disks              []SelfTestDiskStatus `json:"disks"`
+	Timestamp          time.Time            `json:"timestamp"`
+}
+
+// SelfTestDiskStatus is the scheduled self-test state of one disk
+type SelfTestDiskStatus struct {
+	Name            string     `json:"name"`
+	Device          string     `json:"device"`
+	Running         bool       `json:"running"`
+	ProgressPercent int        `json:"progress_percent,omitempty"`
+	LastStarted     *time.Time `json:"last_started,omitempty"`
+	LastType        string     `json:"last_type,omitempty"`
+	LastResult      string     `json:"last_result,omitempty"`
+	LastPassed      *bool      `json:"last_passed,omitempty"`
+	Interruptions   int        `json:"interruptions,omitempty"` // tests interrupted in a row
+	NextDue         time.Time  `json:"next_due"`
+	Waiting         string     `json:"waiting,omitempty"` // why a due test has not started: "standby", "parity_check", "window", "concurrency" or "interrupted"
+}
@@ -23,7 +23,231 @@ type ContainerInfo struct {
 	VolumeMappings []VolumeMapping `json:"volume_mappings"`
 	RestartPolicy  string          `json:"restart_policy"`
 	Uptime         string          `json:"uptime"`
-	Timestamp      time.Time       `json:"timestamp"`
+	// UpdateAvailable is set when the registry serves a newer image for the container's tag
+	UpdateAvailable bool               `json:"update_available"`
+	ImageUpdate     *ImageUpdateStatus `json:"image_update,omitempty"`
+	Labels          map[string]string  `json:"labels,omitempty"`
+	// WebUI is the resolved address of the container's web interface, taken from its dockerMan
+	// template or the labels Unraid sets when creating the container
+	WebUI     string             `json:"webui,omitempty"`
+	Icon      string             `json:"icon,omitempty"`
+	Template  *ContainerTemplate `json:"template,omitempty"`
+	Timestamp time.Time          `json:"timestamp"`
+}
+
+// ContainerTemplate is the Unraid dockerMan template a container is defined by
+type ContainerTemplate struct {
+	Name        string             `json:"name"`
+	Path        string             `json:"path"`
+	Repository  string             `json:"repository"`
+	Registry    string             `json:"registry,omitempty"`
+	Network     string             `json:"network"`
+	WebUI       string             `json:"webui,omitempty"`
+	Icon        string             `json:"icon,omitempty"`
+	Categories  []string           `json:"categories"`
+	Overview    string             `json:"overview,omitempty"`
+	Support     string             `json:"support,omitempty"`
+	Project     string             `json:"project,omitempty"`
+	TemplateURL string             `json:"template_url,omitempty"`
+	Privileged  bool               `json:"privileged"`
+	ExtraParams string             `json:"extra_params,omitempty"`
+	Variables   []TemplateVariable `json:"variables"`
+}
+
+// TemplateVariable is a configured path, port, variable, device or label of a template. Values
+// of masked entries such as passwords are never exposed.
+type TemplateVariable struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	Target      string `json:"target"`
+	Value       string `json:"value"`
+	Default     string `json:"default"`
+	Mode        string `json:"mode,omitempty"`
+	Description string `json:"description,omitempty"`
+	Display     string `json:"display,omitempty"`
+	Required    bool   `json:"required"`
+	Masked      bool   `json:"masked"`
+}
+
+// DockerTemplate is a dockerMan template with the container created from it, if any
+type DockerTemplate struct {
+	ContainerTemplate
+	Installed   bool   `json:"installed"`
+	ContainerID string `json:"container_id,omitempty"`
+	State       string `json:"state,omitempty"`
+}
+
+// ContainerTemplateResult is the outcome of (re)creating a container from its template
+type ContainerTemplateResult struct {
+	Success     bool      `json:"success"`
+	Message     string    `json:"message"`
+	Name        string    `json:"name"`
+	Image       string    `json:"image"`
+	ContainerID string    `json:"container_id"`
+	Replaced    bool      `json:"replaced"`
+	Started     bool      `json:"started"`
+	Warnings    []string  `json:"warnings"`
+	Timestamp   time.Time `json:"timestamp"`
+}
+
+// ImageUpdateStatus is the result of the last registry check of a container's image
+type ImageUpdateStatus struct {
+	LocalDigest  string    `json:"local_digest,omitempty"`
+	RemoteDigest string    `json:"remote_digest,omitempty"`
+	CheckedAt    time.Time `json:"checked_at"`
+	Error        string    `json:"error,omitempty"`
+}
+
+// ContainerUpdates summarizes which containers have a newer image available
+type ContainerUpdates struct {
+	Containers       []ContainerUpdateInfo `json:"containers"`
+	UpdatesAvailable int                   `json:"updates_available"`
+	Timestamp        time.Time             `json:"timestamp"`
+}
+
+// ContainerUpdateInfo is the image update status of one container
+type ContainerUpdateInfo struct {
+	ID              string             `json:"id"`
+	Name            string             `json:"name"`
+	Image           string             `json:"image"`
+	UpdateAvailable bool               `json:"update_available"`
+	ImageUpdate     *ImageUpdateStatus `json:"image_update,omitempty"`
+}
+
+// ContainerUpdateResult is the outcome of pulling a container's image and recreating it
+type ContainerUpdateResult struct {
+	Success         bool      `json:"success"`
+	Updated         bool      `json:"updated"`
+	Message         string    `json:"message"`
+	Name            string    `json:"name"`
+	Image           string    `json:"image"`
+	ContainerID     string    `json:"container_id"`
+	PreviousImageID string    `json:"previous_image_id"`
+	ImageID         string    `json:"image_id"`
+	Timestamp       time.Time `json:"timestamp"`
+}
+
+// DockerStack is a Docker Compose project with its containers and their combined resource use
+type DockerStack struct {
+	Name             string               `json:"name"`
+	State            string               `json:"state"`
+	WorkingDir       string               `json:"working_dir,omitempty"`
+	ConfigFiles      []string             `json:"config_files,omitempty"`
+	Containers       int                  `json:"containers"`
+	Running          int                  `json:"running"`
+	UpdatesAvailable int                  `json:"updates_available"`
+	CPUPercent       float64              `json:"cpu_percent"`
+	MemoryUsage      uint64               `json:"memory_usage_bytes"`
+	NetworkRX        uint64               `json:"network_rx_bytes"`
+	NetworkTX        uint64               `json:"network_tx_bytes"`
+	Services         []DockerStackService `json:"services"`
+	Timestamp        time.Time            `json:"timestamp"`
+}
+
+// DockerStackService is a container of a Compose stack
+type DockerStackService struct {
+	Service         string   `json:"service"`
+	ContainerID     string   `json:"container_id"`
+	Name            string   `json:"name"`
+	Image           string   `json:"image"`
+	State           string   `json:"state"`
+	Status          string   `json:"status"`
+	DependsOn       []string `json:"depends_on"`
+	CPUPercent      float64  `json:"cpu_percent"`
+	MemoryUsage     uint64   `json:"memory_usage_bytes"`
+	UpdateAvailable bool     `json:"update_available"`
+}
+
+// DockerStackResult is the outcome of a stack operation, with one step per container action in
+// the order they ran
+type DockerStackResult struct {
+	Success   bool              `json:"success"`
+	Stack     string            `json:"stack"`
+	Action    string            `json:"action"`
+	Message   string            `json:"message"`
+	Steps     []DockerStackStep `json:"steps"`
+	Timestamp time.Time         `json:"timestamp"`
+}
+
+// DockerStackStep is one container action of a stack operation. Skipped steps were not run
+// because a service they depend on failed.
+type DockerStackStep struct {
+	Action      string `json:"action"`
+	Service     string `json:"service"`
+	Name        string `json:"name"`
+	ContainerID string `json:"container_id"`
+	Success     bool   `json:"success"`
+	Skipped     bool   `json:"skipped,omitempty"`
+	Updated     bool   `json:"updated,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+// DockerBatchRequest asks for one action on many containers, named explicitly, chosen by a
+// selector or both
+type DockerBatchRequest struct {
+	Action      string               `json:"action"`
+	Containers  []string             `json:"containers,omitempty"`
+	Selector    *DockerBatchSelector `json:"selector,omitempty"`
+	Concurrency int                  `json:"concurrency,omitempty"`
+	Order       string               `json:"order,omitempty"` // "autostart" (default) or "none"
+}
+
+// DockerBatchSelector matches containers by labels, state and autostart setting
+type DockerBatchSelector struct {
+	Labels    map[string]string `json:"labels,omitempty"` // an empty value matches any value
+	State     string            `json:"state,omitempty"`
+	Autostart *bool             `json:"autostart,omitempty"`
+}
+
+// DockerBatchJob is the progress of a batch container operation running in the background
+type DockerBatchJob struct {
+	ID          string            `json:"id"`
+	Action      string            `json:"action"`
+	Status      string            `json:"status"` // "running", "succeeded", "failed" or "canceled"
+	Order       string            `json:"order"`
+	Concurrency int               `json:"concurrency"`
+	Total       int               `json:"total"`
+	Succeeded   int               `json:"succeeded"`
+	Failed      int               `json:"failed"`
+	Items       []DockerBatchItem `json:"items"`
+	CreatedAt   time.Time         `json:"created_at"`
+	FinishedAt  *time.Time        `json:"finished_at,omitempty"`
+}
+
+// DockerBatchItem is the outcome of a batch operation for one container
+type DockerBatchItem struct {
+	ContainerID string     `json:"container_id"`
+	Name        string     `json:"name"`
+	Status      string     `json:"status"` // "pending", "running", "waiting", "succeeded", "failed" or "canceled"
+	WaitSeconds int        `json:"wait_seconds,omitempty"`
+	Error       string     `json:"error,omitempty"`
+	StartedAt   *time.Time `json:"started_at,omitempty"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+}
+
+// ContainerEvent is a Docker engine event for a container, such as a start, crash or OOM kill
+type ContainerEvent struct {
+	ID           string    `json:"id"`
+	Name         string    `json:"name"`
+	Image        string    `json:"image"`
+	Action       string    `json:"action"`
+	ExitCode     *int      `json:"exit_code,omitempty"`
+	HealthStatus string    `json:"health_status,omitempty"`
+	Timestamp    time.Time `json:"timestamp"`
+}
+
+// ContainerLogLine is one line of container output, tagged with the stream it was written to
+type ContainerLogLine struct {
+	Stream    string     `json:"stream"`
+	Timestamp *time.Time `json:"timestamp,omitempty"`
+	Message   string     `json:"message"`
+}
+
+// ContainerLogs contains the requested log lines of a container
+type ContainerLogs struct {
+	ContainerID string             `json:"container_id"`
+	Lines       []ContainerLogLine `json:"lines"`
+	Timestamp   time.Time          `json:"timestamp"`
 }
 
 // PortMapping represents a port mapping
@@ -39,3 +263,80 @@ type VolumeMapping struct {
 	HostPath      string `json:"host_path"`
 	Mode          string `json:"mode"`
 }
+
+// DockerImage is a local image together with the containers created from it
+type DockerImage struct {
+	ID         string    `json:"id"`
+	Tags       []string  `json:"tags"`
+	Digests    []string  `json:"digests"`
+	SizeBytes  int64     `json:"size_bytes"`
+	Created    time.Time `json:"created"`
+	Dangling   bool      `json:"dangling"`
+	Containers []string  `json:"containers"`
+}
+
+// DockerNetwork is a Docker network with its address pools and attached containers
+type DockerNetwork struct {
+	ID         string                   `json:"id"`
+	Name       string                   `json:"name"`
+	Driver     string                   `json:"driver"`
+	Scope      string                   `json:"scope"`
+	Internal   bool                     `json:"internal"`
+	Subnets    []DockerNetworkSubnet    `json:"subnets"`
+	Containers []DockerNetworkContainer `json:"containers"`
+}
+
+// DockerNetworkSubnet is an address pool of a Docker network
+type DockerNetworkSubnet struct {
+	Subnet  string `json:"subnet"`
+	Gateway string `json:"gateway,omitempty"`
+	IPRange string `json:"ip_range,omitempty"`
+}
+
+// DockerNetworkContainer is a container attached to a Docker network
+type DockerNetworkContainer struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	IPAddress  string `json:"ip_address"`
+	MacAddress string `json:"mac_address"`
+}
+
+// DockerVolume is a Docker volume with its size and the containers mounting it
+type DockerVolume struct {
+	Name       string            `json:"name"`
+	Driver     string            `json:"driver"`
+	Mountpoint string            `json:"mountpoint"`
+	Created    string            `json:"created"`
+	Anonymous  bool              `json:"anonymous"`
+	SizeBytes  int64             `json:"size_bytes"`
+	InUse      bool              `json:"in_use"`
+	Containers []string          `json:"containers"`
+	Labels     map[string]string `json:"labels,omitempty"`
+}
+
+// DockerDiskUsage breaks down the space used by Docker like "docker system df"
+type DockerDiskUsage struct {
+	Images     DockerDiskUsageItem `json:"images"`
+	Containers DockerDiskUsageItem `json:"containers"`
+	Volumes    DockerDiskUsageItem `json:"volumes"`
+	BuildCache DockerDiskUsageItem `json:"build_cache"`
+	TotalBytes int64               `json:"total_bytes"`
+	Timestamp  time.Time           `json:"timestamp"`
+}
+
+// DockerDiskUsageItem is the space used by one kind of Docker resource
+type DockerDiskUsageItem struct {
+	Count            int   `json:"count"`
+	Active           int   `json:"active"`
+	SizeBytes        int64 `json:"size_bytes"`
+	ReclaimableBytes int64 `json:"reclaimable_bytes"`
+}
+
+// DockerPruneResult reports what a prune operation removed
+type DockerPruneResult struct {
+	Success        bool      `json:"success"`
+	Type           string    `json:"type"`
+	Deleted        []string  `json:"deleted"`
+	ReclaimedBytes uint64    `json:"reclaimed_bytes"`
+	Timestamp      time.Time `json:"timestamp"`
+}
@@ -0,0 +1,46 @@
+package dto
+
+import "time"
+
+// HistoryMetric describes a recorded metric and the entities it has values for
+type HistoryMetric struct {
+	Name     string   `json:"name"`
+	Entities []string `json:"entities"` // empty for system-wide metrics
+}
+
+// HistoryTier describes one retention level of the history store
+type HistoryTier struct {
+	ResolutionSeconds int64 `json:"resolution_seconds"`
+	RetentionSeconds  int64 `json:"retention_seconds"`
+}
+
+// HistoryMetricList is the response of the history index endpoint
+type HistoryMetricList struct {
+	Metrics   []HistoryMetric `json:"metrics"`
+	Tiers     []HistoryTier   `json:"tiers"`
+	Timestamp time.Time       `json:"timestamp"`
+}
+
+// HistoryPoint is one aggregated step of a metric
+type HistoryPoint struct {
+	Timestamp time.Time `json:"timestamp"`
+	Value     float64   `json:"value"` // average over the step
+	Min       float64   `json:"min"`
+	Max       float64   `json:"max"`
+}
+
+// HistorySeries holds the points of one entity
+type HistorySeries struct {
+	Entity string         `json:"entity,omitempty"`
+	Points []HistoryPoint `json:"points"`
+}
+
+// HistoryResponse is the response of a metric history query
+type HistoryResponse struct {
+	Metric            string          `json:"metric"`
+	From              time.Time       `json:"from"`
+	To                time.Time       `json:"to"`
+	StepSeconds       int64           `json:"step_seconds"`
+	ResolutionSeconds int64           `json:"resolution_seconds"`
+	Series            []HistorySeries `json:"series"`
+}
@@ -23,3 +23,197 @@ type VMInfo struct {
 	PersistentState bool      `json:"persistent"`
 	Timestamp       time.Time `json:"timestamp"`
 }
+
+// VMSnapshot describes a snapshot of a virtual machine
+type VMSnapshot struct {
+	Name        string           `json:"name"`
+	Description string           `json:"description,omitempty"`
+	Type        string           `json:"type"`  // internal or external
+	State       string           `json:"state"` // VM state when taken, or disk-snapshot
+	Memory      bool             `json:"memory"`
+	Parent      string           `json:"parent,omitempty"`
+	Disks       []VMSnapshotDisk `json:"disks"`
+	CreatedAt   time.Time        `json:"created_at"`
+}
+
+// VMSnapshotDisk describes how one disk was captured by a snapshot
+type VMSnapshotDisk struct {
+	Name     string `json:"name"`
+	Snapshot string `json:"snapshot"` // internal, external or no
+	File     string `json:"file,omitempty"`
+}
+
+// VMSnapshotRequest is the body of a snapshot create request
+type VMSnapshotRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	DiskOnly    bool   `json:"disk_only"`
+	Quiesce     bool   `json:"quiesce"`
+}
+
+// VMSnapshotResult is returned after creating a snapshot
+type VMSnapshotResult struct {
+	VMSnapshot
+	Quiesced bool   `json:"quiesced"`
+	Warning  string `json:"warning,omitempty"`
+}
+
+// VMDetails describes the configuration of a virtual machine, parsed from its libvirt domain XML
+type VMDetails struct {
+	Name        string         `json:"name"`
+	UUID        string         `json:"uuid"`
+	Description string         `json:"description,omitempty"`
+	Arch        string         `json:"arch"`
+	MachineType string         `json:"machine_type"`
+	BIOS        string         `json:"bios"` // OVMF or SeaBIOS
+	Loader      string         `json:"loader,omitempty"`
+	NVRAM       string         `json:"nvram,omitempty"`
+	MemoryBytes uint64         `json:"memory_bytes"`
+	VCPUs       int            `json:"vcpus"`
+	CPUMode     string         `json:"cpu_mode,omitempty"`
+	CPUTopology *VMCPUTopology `json:"cpu_topology,omitempty"`
+	VCPUPins    []VMVCPUPin    `json:"vcpu_pins"`
+	PinnedCPUs  []int          `json:"pinned_cpus"` // host CPUs the vCPUs run on
+	EmulatorPin string         `json:"emulator_pin,omitempty"`
+	Disks       []VMDisk       `json:"disks"`
+	NICs        []VMNIC        `json:"nics"`
+	PCIDevices  []VMPCIDevice  `json:"pci_devices"`
+	USBDevices  []VMUSBDevice  `json:"usb_devices"`
+	Graphics    []VMGraphics   `json:"graphics"`
+	GuestAgent  *VMGuestAgent  `json:"guest_agent,omitempty"`
+	Guest       *VMGuestInfo   `json:"guest,omitempty"` // while the guest agent is connected
+	Timestamp   time.Time      `json:"timestamp"`
+}
+
+// VMCPUTopology is the CPU topology presented to the guest
+type VMCPUTopology struct {
+	Sockets int `json:"sockets"`
+	Dies    int `json:"dies,omitempty"`
+	Cores   int `json:"cores"`
+	Threads int `json:"threads"`
+}
+
+// VMVCPUPin pins one vCPU to host CPUs
+type VMVCPUPin struct {
+	VCPU   int    `json:"vcpu"`
+	CPUSet string `json:"cpuset"`
+	CPUs   []int  `json:"cpus"`
+}
+
+// VMDisk describes a disk or CD-ROM drive of a virtual machine
+type VMDisk struct {
+	Target          string `json:"target"` // e.g. hdc
+	Device          string `json:"device"` // disk or cdrom
+	Bus             string `json:"bus"`
+	Format          string `json:"format,omitempty"` // raw, qcow2
+	Source          string `json:"source,omitempty"` // image file or block device
+	Cache           string `json:"cache,omitempty"`
+	Serial          string `json:"serial,omitempty"`
+	BootOrder       int    `json:"boot_order,omitempty"`
+	ReadOnly        bool   `json:"read_only"`
+	CapacityBytes   uint64 `json:"capacity_bytes"`
+	AllocationBytes uint64 `json:"allocation_bytes"`
+}
+
+// VMNIC describes a network interface of a virtual machine
+type VMNIC struct {
+	MAC    string `json:"mac"`
+	Type   string `json:"type"`   // bridge, network or direct
+	Source string `json:"source"` // bridge, network or host interface
+	Model  string `json:"model,omitempty"`
+	Target string `json:"target,omitempty"` // host tap device while running
+	// Addresses the guest agent reports for the interface with this MAC
+	IPAddresses []string `json:"ip_addresses,omitempty"`
+}
+
+// VMPCIDevice is a host PCI device passed through to a virtual machine
+type VMPCIDevice struct {
+	Address  string `json:"address"` // host address, e.g. 0000:01:00.0, as in GPUMetrics.PCIID
+	Managed  bool   `json:"managed"`
+	VendorID string `json:"vendor_id,omitempty"`
+	DeviceID string `json:"device_id,omitempty"`
+	Class    string `json:"class,omitempty"`    // e.g. 0x030000
+	Type     string `json:"type,omitempty"`     // gpu, audio, usb, network, storage or other
+	Driver   string `json:"driver,omitempty"`   // host driver, vfio-pci while passed through
+	ROMFile  string `json:"rom_file,omitempty"` // vBIOS
+}
+
+// VMUSBDevice is a host USB device passed through to a virtual machine
+type VMUSBDevice struct {
+	VendorID  string `json:"vendor_id,omitempty"`
+	ProductID string `json:"product_id,omitempty"`
+	Bus       int    `json:"bus,omitempty"`
+	Device    int    `json:"device,omitempty"`
+}
+
+// VMGraphics describes a graphical console; ports are 0 until libvirt assigns them at start
+type VMGraphics struct {
+	Type          string `json:"type"` // vnc or spice
+	Port          int    `json:"port,omitempty"`
+	WebsocketPort int    `json:"websocket_port,omitempty"`
+	Listen        string `json:"listen,omitempty"`
+	AutoPort      bool   `json:"autoport"`
+}
+
+// VMGuestAgent describes the QEMU guest agent channel of a virtual machine
+type VMGuestAgent struct {
+	Channel string `json:"channel"`
+	State   string `json:"state,omitempty"` // connected or disconnected while running
+}
+
+// VMGuestInfo is what the QEMU guest agent reports from inside a running virtual machine
+type VMGuestInfo struct {
+	Hostname       string              `json:"hostname,omitempty"`
+	OS             *VMGuestOS          `json:"os,omitempty"`
+	Timezone       string              `json:"timezone,omitempty"`
+	TimezoneOffset int                 `json:"timezone_offset_seconds"`
+	IPAddresses    []string            `json:"ip_addresses"` // addresses of the VM's NICs, without link-local ones
+	Interfaces     []VMGuestInterface  `json:"interfaces"`
+	Filesystems    []VMGuestFilesystem `json:"filesystems"`
+	Users          []VMGuestUser       `json:"users"`
+	Timestamp      time.Time           `json:"timestamp"`
+}
+
+// VMGuestOS identifies the guest operating system
+type VMGuestOS struct {
+	ID            string `json:"id,omitempty"` // e.g. ubuntu, mswindows
+	Name          string `json:"name"`
+	PrettyName    string `json:"pretty_name,omitempty"`
+	Version       string `json:"version,omitempty"`
+	VersionID     string `json:"version_id,omitempty"`
+	KernelRelease string `json:"kernel_release,omitempty"`
+	KernelVersion string `json:"kernel_version,omitempty"`
+	Machine       string `json:"machine,omitempty"`
+}
+
+// VMGuestInterface is a network interface as the guest sees it, including ones that only exist
+// inside the guest such as loopback or container bridges
+type VMGuestInterface struct {
+	Name        string             `json:"name"`
+	MAC         string             `json:"mac,omitempty"`
+	IPAddresses []VMGuestIPAddress `json:"ip_addresses"`
+}
+
+// VMGuestIPAddress is an address of a guest network interface
+type VMGuestIPAddress struct {
+	Type    string `json:"type"` // ipv4 or ipv6
+	Address string `json:"address"`
+	Prefix  uint32 `json:"prefix"`
+}
+
+// VMGuestFilesystem is a filesystem mounted in the guest
+type VMGuestFilesystem struct {
+	Mountpoint   string  `json:"mountpoint"`
+	Device       string  `json:"device,omitempty"`
+	Type         string  `json:"type,omitempty"`
+	TotalBytes   uint64  `json:"total_bytes"`
+	UsedBytes    uint64  `json:"used_bytes"`
+	UsagePercent float64 `json:"usage_percent"`
+}
+
+// VMGuestUser is a user logged in to the guest
+type VMGuestUser struct {
+	Name      string    `json:"name"`
+	Domain    string    `json:"domain,omitempty"` // Windows domain
+	LoginTime time.Time `json:"login_time"`
+}
@@ -0,0 +1,33 @@
+package dto
+
+import "time"
+
+// WebhookTarget is an outbound webhook that receives matching hub events
+type WebhookTarget struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	URL         string    `json:"url"`
+	Secret      string    `json:"secret,omitempty"` // HMAC-SHA256 key; never returned by the API
+	HasSecret   bool      `json:"has_secret"`
+	Topics      []string  `json:"topics"`                 // event topics to deliver, "*" for all
+	Template    string    `json:"template,omitempty"`     // Go text/template for the body; default is the WSEvent JSON
+	ContentType string    `json:"content_type,omitempty"` // defaults to application/json
+	Enabled     bool      `json:"enabled"`
+	Dropped     int64     `json:"dropped,omitempty"` // deliveries dropped since start because the queue was full
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// WebhookDelivery is a delivery that failed after all retries and was moved to the dead-letter queue
+type WebhookDelivery struct {
+	ID         string    `json:"id"`
+	TargetID   string    `json:"target_id"`
+	TargetName string    `json:"target_name"`
+	Topic      string    `json:"topic"`
+	Body       string    `json:"body"`
+	Truncated  bool      `json:"truncated,omitempty"` // the body was cut to 8 KiB and cannot be retried
+	Attempts   int       `json:"attempts"`
+	LastError  string    `json:"last_error"`
+	CreatedAt  time.Time `json:"created_at"`
+	FailedAt   time.Time `json:"failed_at"`
+}
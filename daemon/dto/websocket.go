@@ -9,6 +9,19 @@ type WSEvent struct {
 	Data      interface{} `json:"data"`
 }
 
+// WSSubscriptionRequest is sent by WebSocket clients to change which events they receive
+type WSSubscriptionRequest struct {
+	Action string   `json:"action"` // "subscribe", "unsubscribe" or "subscriptions"
+	Topics []string `json:"topics,omitempty"`
+	IDs    []string `json:"ids,omitempty"`
+}
+
+// WSSubscription describes an active WebSocket topic subscription
+type WSSubscription struct {
+	Topic string   `json:"topic"`
+	IDs   []string `json:"ids,omitempty"`
+}
+
 // Response represents a standard API response
 type Response struct {
 	Success   bool        `json:"success"`
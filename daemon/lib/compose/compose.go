@@ -0,0 +1,189 @@
+// Package compose groups containers created by Docker Compose into stacks. Compose records the
+// project, service and dependencies of every container it creates in labels, so stacks can be
+// rebuilt from the containers alone, whether they were started with docker compose or the
+// Unraid Compose Manager plugin.
+package compose
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/dto"
+)
+
+// Labels Compose puts on the containers it creates.
+const (
+	LabelProject     = "com.docker.compose.project"
+	LabelService     = "com.docker.compose.service"
+	LabelWorkingDir  = "com.docker.compose.project.working_dir"
+	LabelConfigFiles = "com.docker.compose.project.config_files"
+	LabelDependsOn   = "com.docker.compose.depends_on"
+	LabelOneOff      = "com.docker.compose.oneoff"
+)
+
+// Stack states.
+const (
+	StateRunning = "running"
+	StatePartial = "partial"
+	StateStopped = "stopped"
+)
+
+// ErrCycle is returned by Order when services depend on each other in a loop.
+var ErrCycle = errors.New("dependency cycle between services")
+
+// Project returns the Compose project of a container, or "" when Compose did not create it.
+// One-off containers from docker compose run are not part of a stack.
+func Project(labels map[string]string) string {
+	if strings.EqualFold(labels[LabelOneOff], "true") {
+		return ""
+	}
+	return labels[LabelProject]
+}
+
+// DependsOn returns the services a container's service depends on. Compose stores them as
+// "service:condition:restart" entries separated by commas; older versions store nothing.
+func DependsOn(labels map[string]string) []string {
+	var services []string
+	for _, entry := range strings.Split(labels[LabelDependsOn], ",") {
+		service, _, _ := strings.Cut(strings.TrimSpace(entry), ":")
+		if service != "" {
+			services = append(services, service)
+		}
+	}
+	return services
+}
+
+// Order sorts services so that every service comes after the services it depends on, the order
+// docker compose up starts them in. Services without dependencies between them are sorted by
+// name. Dependencies on services that are not in deps are ignored.
+func Order(deps map[string][]string) ([]string, error) {
+	pending := make(map[string]int, len(deps))
+	dependents := make(map[string][]string)
+	for service, needs := range deps {
+		n := 0
+		for _, need := range needs {
+			if _, ok := deps[need]; !ok || need == service {
+				continue
+			}
+			n++
+			dependents[need] = append(dependents[need], service)
+		}
+		pending[service] = n
+	}
+
+	var ready, order []string
+	for service, n := range pending {
+		if n == 0 {
+			ready = append(ready, service)
+		}
+	}
+	for len(ready) > 0 {
+		sort.Strings(ready)
+		service := ready[0]
+		ready = ready[1:]
+		order = append(order, service)
+		for _, dependent := range dependents[service] {
+			if pending[dependent]--; pending[dependent] == 0 {
+				ready = append(ready, dependent)
+			}
+		}
+	}
+
+	if len(order) != len(deps) {
+		var stuck []string
+		for service, n := range pending {
+			if n > 0 {
+				stuck = append(stuck, service)
+			}
+		}
+		sort.Strings(stuck)
+		return nil, fmt.Errorf("%w: %s", ErrCycle, strings.Join(stuck, ", "))
+	}
+	return order, nil
+}
+
+// Stacks groups containers into stacks sorted by name, summing the resource use of their
+// containers. Containers not created by Compose are left out.
+func Stacks(containers []dto.ContainerInfo) []dto.DockerStack {
+	byName := make(map[string]*dto.DockerStack)
+	var names []string
+	for _, c := range containers {
+		project := Project(c.Labels)
+		if project == "" {
+			continue
+		}
+		stack, ok := byName[project]
+		if !ok {
+			stack = &dto.DockerStack{
+				Name:       project,
+				WorkingDir: c.Labels[LabelWorkingDir],
+				Services:   []dto.DockerStackService{},
+			}
+			if files := c.Labels[LabelConfigFiles]; files != "" {
+				stack.ConfigFiles = strings.Split(files, ",")
+			}
+			byName[project] = stack
+			names = append(names, project)
+		}
+
+		dependsOn := DependsOn(c.Labels)
+		if dependsOn == nil {
+			dependsOn = []string{}
+		}
+		stack.Services = append(stack.Services, dto.DockerStackService{
+			Service:         c.Labels[LabelService],
+			ContainerID:     c.ID,
+			Name:            c.Name,
+			Image:           c.Image,
+			State:           c.State,
+			Status:          c.Status,
+			DependsOn:       dependsOn,
+			CPUPercent:      c.CPUPercent,
+			MemoryUsage:     c.MemoryUsage,
+			UpdateAvailable: c.UpdateAvailable,
+		})
+		stack.Containers++
+		if c.State == "running" {
+			stack.Running++
+		}
+		if c.UpdateAvailable {
+			stack.UpdatesAvailable++
+		}
+		stack.CPUPercent += c.CPUPercent
+		stack.MemoryUsage += c.MemoryUsage
+		stack.NetworkRX += c.NetworkRX
+		stack.NetworkTX += c.NetworkTX
+		if c.Timestamp.After(stack.Timestamp) {
+			stack.Timestamp = c.Timestamp
+		}
+	}
+
+	sort.Strings(names)
+	stacks := make([]dto.DockerStack, 0, len(names))
+	for _, name := range names {
+		stack := byName[name]
+		switch stack.Running {
+		case stack.Containers:
+			stack.State = StateRunning
+		case 0:
+			stack.State = StateStopped
+		default:
+			stack.State = StatePartial
+		}
+		sort.Slice(stack.Services, func(i, j int) bool {
+			a, b := stack.Services[i], stack.Services[j]
+			if a.Service != b.Service {
+				return a.Service < b.Service
+			}
+			return a.Name < b.Name
+		})
+		if stack.Timestamp.IsZero() {
+			stack.Timestamp = time.Now()
+		}
+		stacks = append(stacks, *stack)
+	}
+	return stacks
+}
@@ -0,0 +1,114 @@
+package compose
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/dto"
+)
+
+func TestDependsOn(t *testing.T) {
+	tests := []struct {
+		label string
+		want  []string
+	}{
+		{"broker:service_started:false,db:service_healthy:false", []string{"broker", "db"}},
+		{"db:service_healthy", []string{"db"}},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		if got := DependsOn(map[string]string{LabelDependsOn: tt.label}); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("DependsOn(%q) = %v, want %v", tt.label, got, tt.want)
+		}
+	}
+}
+
+func TestOrder(t *testing.T) {
+	tests := []struct {
+		name string
+		deps map[string][]string
+		want []string
+	}{
+		{
+			name: "dependencies first",
+			deps: map[string][]string{"webserver": {"broker", "db"}, "broker": nil, "db": nil},
+			want: []string{"broker", "db", "webserver"},
+		},
+		{
+			name: "chain",
+			deps: map[string][]string{"app": {"cache"}, "cache": {"db"}, "db": nil, "admin": nil},
+			want: []string{"admin", "db", "cache", "app"},
+		},
+		{
+			name: "missing and self dependencies are ignored",
+			deps: map[string][]string{"app": {"app", "removed"}},
+			want: []string{"app"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Order(tt.deps)
+			if err != nil {
+				t.Fatalf("Order() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Order() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	_, err := Order(map[string][]string{"a": {"b"}, "b": {"a"}, "c": nil})
+	if !errors.Is(err, ErrCycle) || err.Error() != "dependency cycle between services: a, b" {
+		t.Errorf("Order() with a cycle error = %v", err)
+	}
+}
+
+func TestStacks(t *testing.T) {
+	labels := func(project, service, dependsOn string) map[string]string {
+		return map[string]string{
+			LabelProject:     project,
+			LabelService:     service,
+			LabelDependsOn:   dependsOn,
+			LabelWorkingDir:  "/boot/config/plugins/compose.manager/projects/" + project,
+			LabelConfigFiles: "/boot/config/plugins/compose.manager/projects/" + project + "/docker-compose.yml",
+		}
+	}
+	containers := []dto.ContainerInfo{
+		{ID: "5a1f0c3e9b7d", Name: "paperless-webserver-1", State: "exited", Labels: labels("paperless", "webserver", "broker:service_started:false,db:service_healthy:false")},
+		{ID: "6b2a1d4f0c8e", Name: "paperless-broker-1", State: "running", CPUPercent: 1.5, MemoryUsage: 10 << 20, NetworkRX: 100, Labels: labels("paperless", "broker", "")},
+		{ID: "7c3b2e5a1d9f", Name: "paperless-db-1", State: "running", CPUPercent: 2.5, MemoryUsage: 30 << 20, NetworkRX: 200, UpdateAvailable: true, Labels: labels("paperless", "db", "")},
+		{ID: "8d4c3f6b2e0a", Name: "paperless-webserver-run-3b9f0a2c", State: "exited", Labels: map[string]string{LabelProject: "paperless", LabelOneOff: "True"}},
+		{ID: "9e5d4a7c3f1b", Name: "immich-server-1", State: "running", Labels: labels("immich", "server", "")},
+		{ID: "3f4e9a1c2b7d", Name: "plex", State: "running", Labels: map[string]string{"net.unraid.docker.managed": "dockerman"}},
+	}
+
+	stacks := Stacks(containers)
+	if len(stacks) != 2 || stacks[0].Name != "immich" || stacks[1].Name != "paperless" {
+		t.Fatalf("Stacks() = %+v, want immich and paperless", stacks)
+	}
+	if stacks[0].State != StateRunning {
+		t.Errorf("immich state = %q, want running", stacks[0].State)
+	}
+
+	paperless := stacks[1]
+	if paperless.State != StatePartial || paperless.Containers != 3 || paperless.Running != 2 || paperless.UpdatesAvailable != 1 {
+		t.Errorf("unexpected paperless summary: %+v", paperless)
+	}
+	if paperless.CPUPercent != 4 || paperless.MemoryUsage != 40<<20 || paperless.NetworkRX != 300 {
+		t.Errorf("resource use not summed: cpu=%v mem=%d rx=%d", paperless.CPUPercent, paperless.MemoryUsage, paperless.NetworkRX)
+	}
+	if paperless.WorkingDir != "/boot/config/plugins/compose.manager/projects/paperless" || len(paperless.ConfigFiles) != 1 {
+		t.Errorf("unexpected project paths: %q %v", paperless.WorkingDir, paperless.ConfigFiles)
+	}
+	var services []string
+	for _, s := range paperless.Services {
+		services = append(services, s.Service)
+	}
+	if !reflect.DeepEqual(services, []string{"broker", "db", "webserver"}) {
+		t.Errorf("services = %v", services)
+	}
+	if deps := paperless.Services[2].DependsOn; !reflect.DeepEqual(deps, []string{"broker", "db"}) {
+		t.Errorf("webserver depends on %v", deps)
+	}
+}
@@ -0,0 +1,193 @@
+// Package dockerapi is a minimal client for the Docker Engine HTTP API served on a Unix socket.
+// It covers the calls the agent needs without pulling in the full Docker SDK.
+package dockerapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// requestTimeout bounds non-streaming requests. Streaming calls rely on their context instead.
+const requestTimeout = 30 * time.Second
+
+// Error is returned when the Engine answers with an error status.
+type Error struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("docker API error (%d): %s", e.StatusCode, e.Message)
+}
+
+// IsNotFound reports whether err is a 404 from the Engine.
+func IsNotFound(err error) bool {
+	var apiErr *Error
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound
+}
+
+// IsAPIError reports whether err came from the Engine rather than from reaching it. Callers use
+// this to decide whether falling back to the docker CLI makes sense.
+func IsAPIError(err error) bool {
+	var apiErr *Error
+	return errors.As(err, &apiErr)
+}
+
+// IsUnreachable reports whether err is a failure to connect to the socket, so the request never
+// reached the Engine. A timeout or a dropped connection is not: the Engine may have carried out
+// the request, and repeating it through the docker CLI would run it twice.
+func IsUnreachable(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// Client talks to the Engine API over a Unix socket.
+type Client struct {
+	socket string
+	http   *http.Client
+}
+
+// NewClient creates a client for the Engine listening on socket.
+func NewClient(socket string) *Client {
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", socket)
+		},
+		MaxIdleConns:    8,
+		IdleConnTimeout: 90 * time.Second,
+	}
+	return &Client{socket: socket, http: &http.Client{Transport: transport}}
+}
+
+// Socket returns the socket path of the client.
+func (c *Client) Socket() string {
+	return c.socket
+}
+
+// do sends a request and returns the response for status codes below 400. The caller closes
+// the body. The host part of the URL is ignored by the Unix socket transport.
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body io.Reader) (*http.Response, error) {
+	return c.doWithHeader(ctx, method, path, query, body, nil)
+}
+
+// doWithHeader is do with extra request headers.
+func (c *Client) doWithHeader(ctx context.Context, method, path string, query url.Values, body io.Reader, header http.Header) (*http.Response, error) {
+	u := "http://docker" + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 400 {
+		defer resp.Body.Close()
+		return nil, decodeError(resp)
+	}
+	return resp, nil
+}
+
+func decodeError(resp *http.Response) error {
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	var payload struct {
+		Message string `json:"message"`
+	}
+	message := string(data)
+	if json.Unmarshal(data, &payload) == nil && payload.Message != "" {
+		message = payload.Message
+	}
+	return &Error{StatusCode: resp.StatusCode, Message: message}
+}
+
+// getJSON performs a bounded GET and decodes the JSON response into v.
+func (c *Client) getJSON(ctx context.Context, path string, query url.Values, v interface{}) error {
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	resp, err := c.do(ctx, http.MethodGet, path, query, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// post performs a bounded POST and discards the response body.
+func (c *Client) post(ctx context.Context, path string, query url.Values) error {
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	resp, err := c.do(ctx, http.MethodPost, path, query, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+// call performs a bounded request with an optional JSON body and decodes the JSON response into
+// out when it is not nil.
+func (c *Client) call(ctx context.Context, method, path string, query url.Values, in, out interface{}) error {
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+	return c.send(ctx, method, path, query, in, out)
+}
+
+// send is call without the default timeout, for requests that bound ctx themselves.
+func (c *Client) send(ctx context.Context, method, path string, query url.Values, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+
+	resp, err := c.do(ctx, method, path, query, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if out == nil {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// Ping checks that the Engine is reachable.
+func (c *Client) Ping(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+
+	resp, err := c.do(ctx, http.MethodGet, "/_ping", nil, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return nil
+}
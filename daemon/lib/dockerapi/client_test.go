@@ -0,0 +1,641 @@
+package dockerapi_test
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/lib/dockerapi"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/lib/dockerapi/dockertest"
+)
+
+func TestListContainers(t *testing.T) {
+	server := dockertest.NewServer(t)
+	client := dockerapi.NewClient(server.Socket())
+
+	all, err := client.ListContainers(context.Background(), true)
+	if err != nil {
+		t.Fatalf("ListContainers() error = %v", err)
+	}
+	if len(all) != 2 {
+		t.Fatalf("ListContainers(all) returned %d containers, want 2", len(all))
+	}
+	if all[0].ID != dockertest.PlexID || all[0].Names[0] != "/plex" || all[0].State != "running" {
+		t.Errorf("unexpected first container: %+v", all[0])
+	}
+	if len(all[0].Ports) != 2 || all[0].Ports[0].PublicPort != 32400 {
+		t.Errorf("unexpected ports: %+v", all[0].Ports)
+	}
+
+	running, err := client.ListContainers(context.Background(), false)
+	if err != nil {
+		t.Fatalf("ListContainers() error = %v", err)
+	}
+	if len(running) != 1 {
+		t.Errorf("ListContainers(running) returned %d containers, want 1", len(running))
+	}
+}
+
+func TestInspectContainer(t *testing.T) {
+	server := dockertest.NewServer(t)
+	client := dockerapi.NewClient(server.Socket())
+
+	info, err := client.InspectContainer(context.Background(), "plex")
+	if err != nil {
+		t.Fatalf("InspectContainer() error = %v", err)
+	}
+	if info.ID != dockertest.PlexID || info.Config.Image != "plexinc/pms-docker:1.40.2" {
+		t.Errorf("unexpected inspect result: %+v", info)
+	}
+	if info.HostConfig.RestartPolicy.Name != "unless-stopped" || len(info.HostConfig.Binds) != 2 {
+		t.Errorf("unexpected host config: %+v", info.HostConfig)
+	}
+
+	_, err = client.InspectContainer(context.Background(), "missing")
+	if !dockerapi.IsNotFound(err) {
+		t.Errorf("InspectContainer(missing) error = %v, want not found", err)
+	}
+	if !dockerapi.IsAPIError(err) {
+		t.Errorf("IsAPIError(%v) = false, want true", err)
+	}
+}
+
+func TestContainerAction(t *testing.T) {
+	server := dockertest.NewServer(t)
+	client := dockerapi.NewClient(server.Socket())
+
+	if err := client.ContainerAction(context.Background(), "plex", "restart"); err != nil {
+		t.Fatalf("ContainerAction(restart) error = %v", err)
+	}
+	if server.Count("POST /containers/plex/restart") != 1 {
+		t.Errorf("restart not received, requests: %v", server.Requests())
+	}
+
+	// 304 means the container is already in the requested state
+	server.Handle("POST /containers/sonarr/stop", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNotModified)
+	})
+	if err := client.ContainerAction(context.Background(), "sonarr", "stop"); err != nil {
+		t.Errorf("ContainerAction() on stopped container error = %v, want nil", err)
+	}
+
+	server.Handle("POST /containers/plex/start", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = w.Write([]byte(`{"message":"driver failed programming external connectivity"}`))
+	})
+	err := client.ContainerAction(context.Background(), "plex", "start")
+	if err == nil || err.Error() != "docker API error (500): driver failed programming external connectivity" {
+		t.Errorf("ContainerAction() error = %v", err)
+	}
+}
+
+func TestUnreachableSocket(t *testing.T) {
+	client := dockerapi.NewClient(filepath.Join(t.TempDir(), "missing.sock"))
+
+	err := client.Ping(context.Background())
+	if err == nil {
+		t.Fatal("Ping() on missing socket returned nil error")
+	}
+	if dockerapi.IsAPIError(err) {
+		t.Errorf("IsAPIError(%v) = true for a connection failure", err)
+	}
+	if !dockerapi.IsUnreachable(err) {
+		t.Errorf("IsUnreachable(%v) = false for a connection failure", err)
+	}
+}
+
+func TestTimeoutIsNotUnreachable(t *testing.T) {
+	server := dockertest.NewServer(t)
+	client := dockerapi.NewClient(server.Socket())
+	server.Handle("POST /containers/plex/stop", func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := client.ContainerAction(ctx, "plex", "stop")
+	if err == nil || dockerapi.IsUnreachable(err) {
+		t.Errorf("IsUnreachable(%v) = true for a request the Engine received", err)
+	}
+}
+
+func TestStreamStats(t *testing.T) {
+	server := dockertest.NewServer(t)
+	client := dockerapi.NewClient(server.Socket())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var samples []*dockerapi.Stats
+	err := client.StreamStats(ctx, dockertest.PlexID, func(s *dockerapi.Stats) {
+		samples = append(samples, s)
+		if len(samples) == 2 {
+			cancel()
+		}
+	})
+	if err != nil {
+		t.Fatalf("StreamStats() error = %v", err)
+	}
+	if len(samples) != 2 {
+		t.Fatalf("StreamStats() delivered %d samples, want 2", len(samples))
+	}
+
+	first, last := samples[0], samples[1]
+	if got := first.CPUPercent(); got != 0 {
+		t.Errorf("first sample CPUPercent() = %v, want 0 without a previous reading", got)
+	}
+	if got := last.CPUPercent(); math.Abs(got-20) > 1e-9 {
+		t.Errorf("CPUPercent() = %v, want 20", got)
+	}
+	if got := last.MemoryUsage(); got != 384*1024*1024 {
+		t.Errorf("MemoryUsage() = %d, want %d", got, 384*1024*1024)
+	}
+	if rx, tx := last.NetworkIO(); rx != 1049600 || tx != 526336 {
+		t.Errorf("NetworkIO() = %d, %d, want 1049600, 526336", rx, tx)
+	}
+}
+
+func TestStatsCPUPercentFallsBackToPerCPUCount(t *testing.T) {
+	var s dockerapi.Stats
+	s.CPUStats.CPUUsage.TotalUsage = 200
+	s.CPUStats.CPUUsage.PercpuUsage = []uint64{100, 100}
+	s.CPUStats.SystemUsage = 1000
+	s.PreCPUStats.SystemUsage = 500
+
+	if got := s.CPUPercent(); got != 80 {
+		t.Errorf("CPUPercent() = %v, want 80", got)
+	}
+}
+
+func TestStreamEvents(t *testing.T) {
+	server := dockertest.NewServer(t)
+	client := dockerapi.NewClient(server.Socket())
+	for _, e := range dockertest.RecordedEvents() {
+		server.Emit(e)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var actions []string
+	filters := map[string][]string{"type": {"container"}, "event": {"die", "health_status"}}
+	err := client.StreamEvents(ctx, filters, func(e dockerapi.Event) {
+		actions = append(actions, e.Action)
+		if e.Action == "die" {
+			if e.Actor.ID != dockertest.PlexID || e.Actor.Attributes["exitCode"] != "137" {
+				t.Errorf("unexpected die event: %+v", e)
+			}
+			cancel()
+		}
+	})
+	if err != nil {
+		t.Fatalf("StreamEvents() error = %v", err)
+	}
+
+	want := []string{"health_status: unhealthy", "die"}
+	if len(actions) != len(want) || actions[0] != want[0] || actions[1] != want[1] {
+		t.Errorf("StreamEvents() delivered %v, want %v", actions, want)
+	}
+}
+
+func TestContainerLogs(t *testing.T) {
+	server := dockertest.NewServer(t)
+	client := dockerapi.NewClient(server.Socket())
+
+	t.Run("multiplexed stream is split by source", func(t *testing.T) {
+		var lines []dockerapi.LogLine
+		err := client.ContainerLogs(context.Background(), dockertest.PlexID, false, dockerapi.LogOptions{Timestamps: true},
+			func(l dockerapi.LogLine) error {
+				lines = append(lines, l)
+				return nil
+			})
+		if err != nil {
+			t.Fatalf("ContainerLogs() error = %v", err)
+		}
+		if len(lines) != 5 {
+			t.Fatalf("ContainerLogs() returned %d lines, want 5", len(lines))
+		}
+		if lines[2].Stream != dockerapi.StreamStderr || lines[2].Text != "Critical: libusb_init failed" {
+			t.Errorf("unexpected stderr line: %+v", lines[2])
+		}
+		if lines[1].Stream != dockerapi.StreamStdout || lines[1].Text != "Starting Plex Media Server." {
+			t.Errorf("unexpected stdout line: %+v", lines[1])
+		}
+		want := time.Date(2024, 5, 13, 8, 0, 1, 300000000, time.UTC)
+		if !lines[2].Timestamp.Equal(want) {
+			t.Errorf("timestamp = %v, want %v", lines[2].Timestamp, want)
+		}
+	})
+
+	t.Run("tty stream is reported as stdout", func(t *testing.T) {
+		var lines []dockerapi.LogLine
+		err := client.ContainerLogs(context.Background(), dockertest.SonarrID, true, dockerapi.LogOptions{Tail: "1"},
+			func(l dockerapi.LogLine) error {
+				lines = append(lines, l)
+				return nil
+			})
+		if err != nil {
+			t.Fatalf("ContainerLogs() error = %v", err)
+		}
+		if len(lines) != 1 || lines[0].Stream != dockerapi.StreamStdout || !lines[0].Timestamp.IsZero() {
+			t.Errorf("unexpected lines: %+v", lines)
+		}
+		if lines[0].Text != "[Info] Microsoft.Hosting.Lifetime: Application is shutting down..." {
+			t.Errorf("unexpected text: %q", lines[0].Text)
+		}
+	})
+
+	t.Run("long lines are split the same way with and without tty", func(t *testing.T) {
+		long := strings.Repeat("x", 150*1024)
+		output := "first\r\n" + long + "\nlast"
+		server.Handle("GET /containers/long-tty/logs", func(w http.ResponseWriter, _ *http.Request) {
+			_, _ = w.Write([]byte(output))
+		})
+		// Frames do not follow lines, so the output is sent in two uneven frames
+		server.Handle("GET /containers/long/logs", func(w http.ResponseWriter, _ *http.Request) {
+			for _, payload := range []string{output[:1000], output[1000:]} {
+				header := []byte{1, 0, 0, 0, 0, 0, 0, 0}
+				binary.BigEndian.PutUint32(header[4:], uint32(len(payload)))
+				_, _ = w.Write(append(header, payload...))
+			}
+		})
+
+		for _, id := range []string{"long-tty", "long"} {
+			var lines []string
+			err := client.ContainerLogs(context.Background(), id, id == "long-tty", dockerapi.LogOptions{},
+				func(l dockerapi.LogLine) error {
+					lines = append(lines, l.Text)
+					return nil
+				})
+			if err != nil {
+				t.Fatalf("%s: ContainerLogs() error = %v", id, err)
+			}
+			if len(lines) != 5 || lines[0] != "first" || lines[4] != "last" {
+				t.Fatalf("%s: ContainerLogs() returned %d lines, want first, three chunks and last", id, len(lines))
+			}
+			if joined := lines[1] + lines[2] + lines[3]; joined != long || len(lines[1]) != 64*1024 || len(lines[2]) != 64*1024 {
+				t.Errorf("%s: long line split into chunks of %d, %d and %d bytes", id, len(lines[1]), len(lines[2]), len(lines[3]))
+			}
+		}
+	})
+
+	t.Run("follow ends cleanly on cancel", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		count := 0
+		err := client.ContainerLogs(ctx, dockertest.PlexID, false, dockerapi.LogOptions{Follow: true},
+			func(dockerapi.LogLine) error {
+				if count++; count == 5 {
+					cancel()
+				}
+				return nil
+			})
+		if err != nil {
+			t.Errorf("ContainerLogs(follow) error = %v, want nil after cancel", err)
+		}
+	})
+}
+
+func TestInspectAndPullImage(t *testing.T) {
+	server := dockertest.NewServer(t)
+	client := dockerapi.NewClient(server.Socket())
+	ctx := context.Background()
+
+	image, err := client.InspectImage(ctx, "linuxserver/sonarr")
+	if err != nil {
+		t.Fatalf("InspectImage() error = %v", err)
+	}
+	if len(image.RepoDigests) != 1 || image.RepoDigests[0] != "linuxserver/sonarr@"+dockertest.SonarrImageDigest {
+		t.Errorf("unexpected repo digests: %v", image.RepoDigests)
+	}
+
+	// An unchanged tag keeps its image
+	if err := client.PullImage(ctx, "linuxserver/sonarr"); err != nil {
+		t.Fatalf("PullImage() error = %v", err)
+	}
+	if server.Count("POST /images/create") != 1 {
+		t.Errorf("pull not received, requests: %v", server.Requests())
+	}
+
+	server.PublishImage("plexinc/pms-docker:1.40.2", "sha256:0123", "sha256:4567")
+	if err := client.PullImage(ctx, "plexinc/pms-docker:1.40.2"); err != nil {
+		t.Fatalf("PullImage() error = %v", err)
+	}
+	image, err = client.InspectImage(ctx, "plexinc/pms-docker:1.40.2")
+	if err != nil || image.ID != "sha256:0123" {
+		t.Errorf("InspectImage() after pull = %+v, %v, want the published image", image, err)
+	}
+
+	err = client.PullImage(ctx, "example/missing:1")
+	if !dockerapi.IsAPIError(err) || !strings.Contains(err.Error(), "pull access denied") {
+		t.Errorf("PullImage(missing) error = %v, want the error from the progress stream", err)
+	}
+	if _, err := client.InspectImage(ctx, "example/missing:1"); !dockerapi.IsNotFound(err) {
+		t.Errorf("InspectImage(missing) error = %v, want not found", err)
+	}
+}
+
+func TestPullImageSendsSavedCredentials(t *testing.T) {
+	server := dockertest.NewServer(t)
+	client := dockerapi.NewClient(server.Socket())
+	var auth []string
+	server.Handle("POST /images/create", func(w http.ResponseWriter, r *http.Request) {
+		auth = append(auth, r.Header.Get("X-Registry-Auth"))
+		_, _ = w.Write([]byte(`{"status":"Image is up to date"}`))
+	})
+
+	dir := t.TempDir()
+	t.Setenv("DOCKER_CONFIG", dir)
+	config := `{"auths":{"ghcr.io":{"auth":"` + base64.StdEncoding.EncodeToString([]byte("unraid:s3cret")) + `"}}}`
+	if err := os.WriteFile(filepath.Join(dir, "config.json"), []byte(config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, image := range []string{"ghcr.io/home-assistant/home-assistant:stable", "linuxserver/sonarr"} {
+		if err := client.PullImage(context.Background(), image); err != nil {
+			t.Fatalf("PullImage(%s) error = %v", image, err)
+		}
+	}
+	if len(auth) != 2 || auth[1] != "" {
+		t.Fatalf("X-Registry-Auth = %q, want credentials for ghcr.io only", auth)
+	}
+	payload, err := base64.URLEncoding.DecodeString(auth[0])
+	if err != nil {
+		t.Fatalf("X-Registry-Auth is not base64: %v", err)
+	}
+	var creds map[string]string
+	if err := json.Unmarshal(payload, &creds); err != nil || creds["username"] != "unraid" || creds["password"] != "s3cret" || creds["serveraddress"] != "ghcr.io" {
+		t.Errorf("X-Registry-Auth = %s, %v", payload, err)
+	}
+}
+
+func TestRecreateContainer(t *testing.T) {
+	server := dockertest.NewServer(t)
+	client := dockerapi.NewClient(server.Socket())
+
+	newID, err := client.RecreateContainer(context.Background(), "plex")
+	if err != nil {
+		t.Fatalf("RecreateContainer() error = %v", err)
+	}
+
+	want := []string{
+		"GET /containers/plex/json",
+		"GET /images/sha256:5b1e7f0c2a4d6e8f0a1b3c5d7e9f1a3b5c7d9e1f3a5b7c9d1e3f5a7b9c1d3e5f/json",
+		"POST /containers/" + dockertest.PlexID + "/stop",
+		"POST /containers/" + dockertest.PlexID + "/rename",
+		"POST /containers/create",
+		"POST /containers/" + newID + "/start",
+		"DELETE /containers/" + dockertest.PlexID,
+	}
+	if got := server.Requests(); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("requests = %v, want %v", got, want)
+	}
+
+	var config struct {
+		Hostname         *string `json:"Hostname"`
+		Image            string  `json:"Image"`
+		Env              []string
+		HostConfig       struct{ Binds []string }
+		NetworkingConfig struct {
+			EndpointsConfig map[string]json.RawMessage
+		}
+	}
+	if err := json.Unmarshal(server.Body("POST /containers/create"), &config); err != nil {
+		t.Fatalf("invalid create body: %v", err)
+	}
+	if config.Hostname != nil {
+		t.Errorf("generated hostname %q was copied to the new container", *config.Hostname)
+	}
+	if config.Image != "plexinc/pms-docker:1.40.2" || len(config.Env) != 3 || len(config.HostConfig.Binds) != 2 {
+		t.Errorf("configuration not preserved: %+v", config)
+	}
+	if _, ok := config.NetworkingConfig.EndpointsConfig["bridge"]; !ok || len(config.NetworkingConfig.EndpointsConfig) != 1 {
+		t.Errorf("endpoints = %v, want only bridge", config.NetworkingConfig.EndpointsConfig)
+	}
+}
+
+func TestRecreateContainerConnectsExtraNetworks(t *testing.T) {
+	server := dockertest.NewServer(t)
+	client := dockerapi.NewClient(server.Socket())
+	server.Handle("GET /containers/app/json", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`{
+			"Id": "` + dockertest.SonarrID + `",
+			"Name": "/app",
+			"State": {"Status": "exited"},
+			"Config": {"Hostname": "app", "Image": "linuxserver/sonarr"},
+			"HostConfig": {"NetworkMode": "proxy"},
+			"NetworkSettings": {"Networks": {
+				"proxy": {"IPAMConfig": {"IPv4Address": "10.0.0.5"}, "Aliases": ["app", "8c1d2e3f4a5b"]},
+				"backend": {"Aliases": ["app"]}
+			}}
+		}`))
+	})
+
+	if _, err := client.RecreateContainer(context.Background(), "app"); err != nil {
+		t.Fatalf("RecreateContainer() error = %v", err)
+	}
+
+	var config struct {
+		Hostname         string
+		NetworkingConfig struct {
+			EndpointsConfig map[string]struct {
+				IPAMConfig struct{ IPv4Address string }
+				Aliases    []string
+			}
+		}
+	}
+	_ = json.Unmarshal(server.Body("POST /containers/create"), &config)
+	proxy := config.NetworkingConfig.EndpointsConfig["proxy"]
+	if config.Hostname != "app" || proxy.IPAMConfig.IPv4Address != "10.0.0.5" || len(proxy.Aliases) != 1 {
+		t.Errorf("unexpected create body: %s", server.Body("POST /containers/create"))
+	}
+	if server.Count("POST /networks/backend/connect") != 1 {
+		t.Errorf("backend network not connected, requests: %v", server.Requests())
+	}
+	// The container was not running, so neither container is started
+	for _, r := range server.Requests() {
+		if strings.HasSuffix(r, "/start") || strings.HasSuffix(r, "/stop") {
+			t.Errorf("unexpected request %s", r)
+		}
+	}
+}
+
+func TestRecreateContainerAppliesNewImageDefaults(t *testing.T) {
+	server := dockertest.NewServer(t)
+	client := dockerapi.NewClient(server.Socket())
+	server.Handle("GET /containers/app/json", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`{
+			"Id": "` + dockertest.SonarrID + `",
+			"Name": "/app",
+			"Image": "sha256:0ld",
+			"State": {"Status": "exited"},
+			"Config": {
+				"Image": "example/app:1",
+				"Env": ["TZ=Europe/London", "PATH=/usr/bin", "APP_VERSION=1"],
+				"Cmd": ["/app", "--v1"],
+				"Entrypoint": ["/custom-init"],
+				"WorkingDir": "/srv/app",
+				"Labels": {"org.opencontainers.image.version": "1", "net.unraid.docker.managed": "dockerman"},
+				"ExposedPorts": {"8080/tcp": {}, "9000/tcp": {}}
+			},
+			"HostConfig": {"NetworkMode": "host"}
+		}`))
+	})
+	server.Handle("GET /images/sha256:0ld/json", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`{"Id": "sha256:0ld", "Config": {
+			"Env": ["PATH=/usr/bin", "APP_VERSION=1"],
+			"Cmd": ["/app", "--v1"],
+			"Entrypoint": ["/init"],
+			"WorkingDir": "/srv/app",
+			"Labels": {"org.opencontainers.image.version": "1"},
+			"ExposedPorts": {"8080/tcp": {}}
+		}}`))
+	})
+
+	if _, err := client.RecreateContainer(context.Background(), "app"); err != nil {
+		t.Fatalf("RecreateContainer() error = %v", err)
+	}
+
+	// The new image's Env, Cmd, WorkingDir, labels and ports apply; the user's settings stay
+	var config map[string]json.RawMessage
+	if err := json.Unmarshal(server.Body("POST /containers/create"), &config); err != nil {
+		t.Fatalf("invalid create body: %v", err)
+	}
+	for _, key := range []string{"Cmd", "WorkingDir"} {
+		if value, ok := config[key]; ok {
+			t.Errorf("%s %s of the old image was copied to the new container", key, value)
+		}
+	}
+	want := map[string]string{
+		"Image":        `"example/app:1"`,
+		"Env":          `["TZ=Europe/London"]`,
+		"Entrypoint":   `["/custom-init"]`,
+		"Labels":       `{"net.unraid.docker.managed":"dockerman"}`,
+		"ExposedPorts": `{"9000/tcp":{}}`,
+	}
+	for key, value := range want {
+		var got, expected interface{}
+		_ = json.Unmarshal(config[key], &got)
+		_ = json.Unmarshal([]byte(value), &expected)
+		if !reflect.DeepEqual(got, expected) {
+			t.Errorf("%s = %s, want %s", key, config[key], value)
+		}
+	}
+}
+
+func TestRecreateContainerRollsBack(t *testing.T) {
+	server := dockertest.NewServer(t)
+	client := dockerapi.NewClient(server.Socket())
+	server.Handle("POST /containers/create", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusConflict)
+		_, _ = w.Write([]byte(`{"message": "Conflict. The container name \"/plex\" is already in use"}`))
+	})
+
+	if _, err := client.RecreateContainer(context.Background(), "plex"); !dockerapi.IsAPIError(err) {
+		t.Fatalf("RecreateContainer() error = %v, want the create error", err)
+	}
+	if server.Count("POST /containers/"+dockertest.PlexID+"/rename") != 2 {
+		t.Errorf("old container not renamed back, requests: %v", server.Requests())
+	}
+	if server.Count("POST /containers/"+dockertest.PlexID+"/start") != 1 {
+		t.Errorf("old container not restarted, requests: %v", server.Requests())
+	}
+	if server.Count("DELETE /containers/"+dockertest.PlexID) != 0 {
+		t.Error("old container removed after a failed recreate")
+	}
+}
+
+func TestReplaceContainer(t *testing.T) {
+	server := dockertest.NewServer(t)
+	client := dockerapi.NewClient(server.Socket())
+	config := map[string]interface{}{"Image": "plexinc/pms-docker:latest"}
+
+	// An existing container is swapped out; the new one stays stopped without start
+	if _, err := client.ReplaceContainer(context.Background(), "plex", config, false); err != nil {
+		t.Fatalf("ReplaceContainer() error = %v", err)
+	}
+	want := []string{
+		"GET /containers/plex/json",
+		"POST /containers/" + dockertest.PlexID + "/stop",
+		"POST /containers/" + dockertest.PlexID + "/rename",
+		"POST /containers/create",
+		"DELETE /containers/" + dockertest.PlexID,
+	}
+	if got := server.Requests(); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("requests = %v, want %v", got, want)
+	}
+
+	// A new name is simply created and started
+	server = dockertest.NewServer(t)
+	client = dockerapi.NewClient(server.Socket())
+	newID, err := client.ReplaceContainer(context.Background(), "jellyfin", config, true)
+	if err != nil {
+		t.Fatalf("ReplaceContainer() error = %v", err)
+	}
+	want = []string{
+		"GET /containers/jellyfin/json",
+		"POST /containers/create",
+		"POST /containers/" + newID + "/start",
+	}
+	if got := server.Requests(); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("requests = %v, want %v", got, want)
+	}
+}
+
+func TestResourceInventory(t *testing.T) {
+	server := dockertest.NewServer(t)
+	client := dockerapi.NewClient(server.Socket())
+	ctx := context.Background()
+
+	images, err := client.ListImages(ctx)
+	if err != nil {
+		t.Fatalf("ListImages() error = %v", err)
+	}
+	dangling := 0
+	for i := range images {
+		if images[i].Dangling() {
+			dangling++
+		}
+	}
+	if len(images) != 3 || dangling != 1 {
+		t.Errorf("ListImages() returned %d images with %d dangling, want 3 and 1", len(images), dangling)
+	}
+
+	networks, err := client.ListNetworks(ctx)
+	if err != nil || len(networks) != 4 {
+		t.Fatalf("ListNetworks() = %d networks, %v", len(networks), err)
+	}
+	volumes, err := client.ListVolumes(ctx)
+	if err != nil || len(volumes) != 3 {
+		t.Fatalf("ListVolumes() = %d volumes, %v", len(volumes), err)
+	}
+
+	usage, err := client.DiskUsage(ctx)
+	if err != nil {
+		t.Fatalf("DiskUsage() error = %v", err)
+	}
+	if usage.LayersSize == 0 || len(usage.Volumes) != 3 || usage.Volumes[0].UsageData == nil {
+		t.Errorf("unexpected disk usage: %+v", usage)
+	}
+
+	report, err := client.PruneVolumes(ctx, false)
+	if err != nil {
+		t.Fatalf("PruneVolumes() error = %v", err)
+	}
+	if len(report.VolumesDeleted) != 1 || report.SpaceReclaimed != 54525952 {
+		t.Errorf("unexpected prune report: %+v", report)
+	}
+}
@@ -0,0 +1,226 @@
+package dockerapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Port is a port published by a container, as reported by the container list.
+type Port struct {
+	IP          string `json:"IP"`
+	PrivatePort int    `json:"PrivatePort"`
+	PublicPort  int    `json:"PublicPort"`
+	Type        string `json:"Type"`
+}
+
+// EndpointSettings describes a container's attachment to a network.
+type EndpointSettings struct {
+	NetworkID  string `json:"NetworkID"`
+	IPAddress  string `json:"IPAddress"`
+	Gateway    string `json:"Gateway"`
+	MacAddress string `json:"MacAddress"`
+}
+
+// MountPoint is a volume or bind mount of a container.
+type MountPoint struct {
+	Type        string `json:"Type"`
+	Name        string `json:"Name"`
+	Source      string `json:"Source"`
+	Destination string `json:"Destination"`
+	Mode        string `json:"Mode"`
+	RW          bool   `json:"RW"`
+}
+
+// Container is an entry of GET /containers/json.
+type Container struct {
+	ID         string            `json:"Id"`
+	Names      []string          `json:"Names"`
+	Image      string            `json:"Image"`
+	ImageID    string            `json:"ImageID"`
+	Created    int64             `json:"Created"`
+	State      string            `json:"State"`
+	Status     string            `json:"Status"`
+	Ports      []Port            `json:"Ports"`
+	Labels     map[string]string `json:"Labels"`
+	HostConfig struct {
+		NetworkMode string `json:"NetworkMode"`
+	} `json:"HostConfig"`
+	NetworkSettings struct {
+		Networks map[string]EndpointSettings `json:"Networks"`
+	} `json:"NetworkSettings"`
+	Mounts []MountPoint `json:"Mounts"`
+}
+
+// PortBinding is a host binding of a container port.
+type PortBinding struct {
+	HostIP   string `json:"HostIp"`
+	HostPort string `json:"HostPort"`
+}
+
+// ContainerState is the runtime state of an inspected container.
+type ContainerState struct {
+	Status     string `json:"Status"`
+	Running    bool   `json:"Running"`
+	Paused     bool   `json:"Paused"`
+	Restarting bool   `json:"Restarting"`
+	OOMKilled  bool   `json:"OOMKilled"`
+	ExitCode   int    `json:"ExitCode"`
+	StartedAt  string `json:"StartedAt"`
+	FinishedAt string `json:"FinishedAt"`
+	Health     *struct {
+		Status string `json:"Status"`
+	} `json:"Health,omitempty"`
+}
+
+// ContainerJSON is the response of GET /containers/{id}/json.
+type ContainerJSON struct {
+	ID      string         `json:"Id"`
+	Name    string         `json:"Name"`
+	Created string         `json:"Created"`
+	Image   string         `json:"Image"`
+	State   ContainerState `json:"State"`
+	Config  struct {
+		Image  string            `json:"Image"`
+		Env    []string          `json:"Env"`
+		Labels map[string]string `json:"Labels"`
+		Tty    bool              `json:"Tty"`
+	} `json:"Config"`
+	HostConfig struct {
+		NetworkMode   string `json:"NetworkMode"`
+		RestartPolicy struct {
+			Name              string `json:"Name"`
+			MaximumRetryCount int    `json:"MaximumRetryCount"`
+		} `json:"RestartPolicy"`
+		PortBindings map[string][]PortBinding `json:"PortBindings"`
+		Binds        []string                 `json:"Binds"`
+	} `json:"HostConfig"`
+	NetworkSettings struct {
+		Networks map[string]EndpointSettings `json:"Networks"`
+	} `json:"NetworkSettings"`
+	Mounts []MountPoint `json:"Mounts"`
+}
+
+// ListContainers returns all containers, including stopped ones when all is set.
+func (c *Client) ListContainers(ctx context.Context, all bool) ([]Container, error) {
+	query := url.Values{}
+	if all {
+		query.Set("all", "1")
+	}
+	var containers []Container
+	if err := c.getJSON(ctx, "/containers/json", query, &containers); err != nil {
+		return nil, err
+	}
+	return containers, nil
+}
+
+// InspectContainer returns the full configuration and state of a container by ID or name.
+func (c *Client) InspectContainer(ctx context.Context, id string) (*ContainerJSON, error) {
+	var info ContainerJSON
+	if err := c.getJSON(ctx, "/containers/"+url.PathEscape(id)+"/json", nil, &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// ContainerAction runs a lifecycle action (start, stop, restart, pause, unpause or kill) on a
+// container. A 304 response for a container already in the requested state is not an error.
+func (c *Client) ContainerAction(ctx context.Context, id, action string) error {
+	return c.post(ctx, "/containers/"+url.PathEscape(id)+"/"+action, nil)
+}
+
+// CPUStats is the CPU section of a stats sample.
+type CPUStats struct {
+	CPUUsage struct {
+		TotalUsage  uint64   `json:"total_usage"`
+		PercpuUsage []uint64 `json:"percpu_usage"`
+	} `json:"cpu_usage"`
+	SystemUsage uint64 `json:"system_cpu_usage"`
+	OnlineCPUs  uint32 `json:"online_cpus"`
+}
+
+// Stats is one sample of GET /containers/{id}/stats.
+type Stats struct {
+	Read        time.Time `json:"read"`
+	CPUStats    CPUStats  `json:"cpu_stats"`
+	PreCPUStats CPUStats  `json:"precpu_stats"`
+	MemoryStats struct {
+		Usage uint64            `json:"usage"`
+		Limit uint64            `json:"limit"`
+		Stats map[string]uint64 `json:"stats"`
+	} `json:"memory_stats"`
+	Networks map[string]struct {
+		RxBytes uint64 `json:"rx_bytes"`
+		TxBytes uint64 `json:"tx_bytes"`
+	} `json:"networks"`
+}
+
+// CPUPercent computes CPU usage the same way as "docker stats". The first sample of a stream
+// has no previous reading and reports 0.
+func (s *Stats) CPUPercent() float64 {
+	if s.PreCPUStats.SystemUsage == 0 {
+		return 0
+	}
+	cpuDelta := float64(s.CPUStats.CPUUsage.TotalUsage) - float64(s.PreCPUStats.CPUUsage.TotalUsage)
+	systemDelta := float64(s.CPUStats.SystemUsage) - float64(s.PreCPUStats.SystemUsage)
+	if cpuDelta <= 0 || systemDelta <= 0 {
+		return 0
+	}
+	cpus := float64(s.CPUStats.OnlineCPUs)
+	if cpus == 0 {
+		cpus = float64(len(s.CPUStats.CPUUsage.PercpuUsage))
+	}
+	return cpuDelta / systemDelta * cpus * 100
+}
+
+// MemoryUsage returns memory usage excluding the page cache, as "docker stats" does for
+// cgroup v1 (total_inactive_file) and v2 (inactive_file).
+func (s *Stats) MemoryUsage() uint64 {
+	usage := s.MemoryStats.Usage
+	for _, key := range []string{"total_inactive_file", "inactive_file"} {
+		if v, ok := s.MemoryStats.Stats[key]; ok && v < usage {
+			return usage - v
+		}
+	}
+	return usage
+}
+
+// NetworkIO returns the bytes received and sent summed over all interfaces.
+func (s *Stats) NetworkIO() (rx, tx uint64) {
+	for _, n := range s.Networks {
+		rx += n.RxBytes
+		tx += n.TxBytes
+	}
+	return rx, tx
+}
+
+// StreamStats follows the stats stream of a container and calls fn for every sample until ctx
+// is cancelled or the stream ends (for example when the container stops).
+func (c *Client) StreamStats(ctx context.Context, id string, fn func(*Stats)) error {
+	resp, err := c.do(ctx, http.MethodGet, "/containers/"+url.PathEscape(id)+"/stats", url.Values{"stream": {"1"}}, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var stats Stats
+		if err := decoder.Decode(&stats); err != nil {
+			if errors.Is(err, io.EOF) || ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		fn(&stats)
+	}
+}
+
+// PruneContainers removes all stopped containers.
+func (c *Client) PruneContainers(ctx context.Context) (*PruneReport, error) {
+	return c.prune(ctx, "/containers/prune", nil)
+}
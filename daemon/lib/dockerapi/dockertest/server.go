@@ -0,0 +1,723 @@
+// Package dockertest provides a fake Docker Engine listening on a Unix socket. It replays
+// responses recorded from a real Engine (a running "plex" and an exited "sonarr" container, their
+// images plus an untagged one, four networks and three volumes) so code using the dockerapi
+// client can be tested without Docker. A Compose stack can be added with AddComposeStack. Events
+// are only sent when a test emits them.
+package dockertest
+
+import (
+	"bufio"
+	"bytes"
+	"embed"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+//go:embed testdata
+var fixtures embed.FS
+
+// Recorded container IDs.
+const (
+	PlexID   = "3f4e9a1c2b7d8e6f5a4b3c2d1e0f9a8b7c6d5e4f3a2b1c0d9e8f7a6b5c4d3e2f"
+	SonarrID = "8c1d2e3f4a5b6c7d8e9f0a1b2c3d4e5f6a7b8c9d0e1f2a3b4c5d6e7f8a9b0c1d"
+)
+
+// Container IDs of the Compose stack added by AddComposeStack.
+const (
+	PaperlessWebserverID = "5a1f0c3e9b7d2a4c6e8f0b1d3a5c7e9f1b3d5a7c9e1f3b5d7a9c1e3f5b7d9a1c"
+	PaperlessBrokerID    = "6b2a1d4f0c8e3b5d7f9a1c2e4b6d8f0a2c4e6b8d0f2a4c6e8b0d2f4a6c8e0b2d"
+	PaperlessDBID        = "7c3b2e5a1d9f4c6e8a0b2d4f6c8e0a2b4d6f8c0e2a4b6d8f0c2e4a6b8d0f2c4e"
+)
+
+// Registry digests recorded in the RepoDigests of the local plex and sonarr images.
+const (
+	PlexImageDigest   = "sha256:a7c3e9f1b5d7092e4c6a8b0d2f4e6a8c0b2d4f6e8a0c2b4d6f8e0a2c4b6d8f0e"
+	SonarrImageDigest = "sha256:1e5b9d3f7a0c4e8b2d6f0a4c8e2b6d0f4a8c2e6b0d4f8a2c6e0b4d8f2a6c0e4b"
+)
+
+// Server is a fake Engine. Requests are matched against handlers registered with Handle first
+// and fall back to the recorded responses.
+type Server struct {
+	socket   string
+	listener net.Listener
+	http     *http.Server
+	mux      *http.ServeMux
+
+	events chan []byte
+
+	mu        sync.Mutex
+	overrides map[string]http.HandlerFunc
+	requests  []string
+	bodies    map[string][]byte
+	published map[string]map[string]interface{}
+	pulled    map[string]map[string]interface{}
+	created   map[string]string
+	stack     bool
+}
+
+// NewServer starts a fake Engine on a fresh socket. It is shut down when the test finishes.
+func NewServer(t testing.TB) *Server {
+	t.Helper()
+
+	// Unix socket paths are limited to ~100 bytes, which t.TempDir() can exceed
+	dir, err := os.MkdirTemp("", "dockertest-")
+	if err != nil {
+		t.Fatalf("failed to create socket directory: %v", err)
+	}
+	socket := filepath.Join(dir, "docker.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatalf("failed to listen on %s: %v", socket, err)
+	}
+
+	s := &Server{
+		socket:    socket,
+		listener:  listener,
+		mux:       http.NewServeMux(),
+		events:    make(chan []byte, 64),
+		overrides: make(map[string]http.HandlerFunc),
+		bodies:    make(map[string][]byte),
+		published: make(map[string]map[string]interface{}),
+		pulled:    make(map[string]map[string]interface{}),
+		created:   make(map[string]string),
+	}
+	s.mux.HandleFunc("GET /_ping", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("OK"))
+	})
+	s.mux.HandleFunc("GET /containers/json", s.handleList)
+	s.mux.HandleFunc("GET /containers/{id}/json", s.handleInspect)
+	s.mux.HandleFunc("GET /containers/{id}/stats", s.handleStats)
+	s.mux.HandleFunc("GET /containers/{id}/logs", s.handleLogs)
+	s.mux.HandleFunc("POST /containers/{id}/{action}", s.handleAction)
+	s.mux.HandleFunc("POST /containers/create", s.handleCreate)
+	s.mux.HandleFunc("DELETE /containers/{id}", s.handleRemove)
+	s.mux.HandleFunc("POST /networks/{id}/connect", s.handleConnect)
+	s.mux.HandleFunc("GET /images/", s.handleImage)
+	s.mux.HandleFunc("POST /images/create", s.handlePull)
+	s.mux.HandleFunc("GET /images/json", s.handleImageList)
+	s.mux.HandleFunc("GET /networks", serveFixture("testdata/networks.json"))
+	s.mux.HandleFunc("GET /volumes", serveFixture("testdata/volumes.json"))
+	s.mux.HandleFunc("GET /system/df", serveFixture("testdata/df.json"))
+	s.mux.HandleFunc("POST /images/prune", s.handlePrune)
+	s.mux.HandleFunc("POST /volumes/prune", s.handlePrune)
+	s.mux.HandleFunc("POST /containers/prune", s.handlePrune)
+	s.mux.HandleFunc("GET /events", s.handleEvents)
+
+	s.http = &http.Server{Handler: http.HandlerFunc(s.serve)}
+	go func() { _ = s.http.Serve(listener) }()
+
+	t.Cleanup(func() {
+		_ = s.http.Close()
+		os.RemoveAll(dir)
+	})
+	return s
+}
+
+// Socket returns the path the fake Engine listens on.
+func (s *Server) Socket() string {
+	return s.socket
+}
+
+// Handle overrides the response for an exact "METHOD /path" pattern, such as
+// "POST /containers/plex/start".
+func (s *Server) Handle(pattern string, handler http.HandlerFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.overrides[pattern] = handler
+}
+
+// Requests returns every request received so far as "METHOD /path".
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string{}, s.requests...)
+}
+
+// Count returns how many requests matched "METHOD /path" exactly.
+func (s *Server) Count(pattern string) int {
+	n := 0
+	for _, r := range s.Requests() {
+		if r == pattern {
+			n++
+		}
+	}
+	return n
+}
+
+// Body returns the body of the last request that matched "METHOD /path" exactly.
+func (s *Server) Body(pattern string) []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.bodies[pattern]
+}
+
+// AddComposeStack adds the "paperless" Compose stack recorded from the Compose Manager plugin: a
+// running broker and db, an exited webserver depending on both, and a one-off container left
+// behind by docker compose run. The stack containers can be listed and controlled but not
+// inspected.
+func (s *Server) AddComposeStack() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stack = true
+}
+
+// PublishImage makes the next pull of image (for example "plexinc/pms-docker:1.40.2") download a
+// new image with the given ID and registry digest, as if the tag had been pushed again.
+func (s *Server) PublishImage(image, id, digest string) {
+	repository := image
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		repository = image[:i]
+	}
+	// Round-trip through JSON so published images look exactly like the recorded ones
+	data, _ := json.Marshal(map[string]interface{}{
+		"Id":          id,
+		"RepoTags":    []string{image},
+		"RepoDigests": []string{repository + "@" + digest},
+		"Created":     time.Now().UTC().Format(time.RFC3339Nano),
+		"Size":        0,
+	})
+	var published map[string]interface{}
+	_ = json.Unmarshal(data, &published)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.published[image] = published
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	key := r.Method + " " + r.URL.Path
+	body, _ := io.ReadAll(r.Body)
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	s.mu.Lock()
+	s.requests = append(s.requests, key)
+	if len(body) > 0 {
+		s.bodies[key] = body
+	}
+	override := s.overrides[key]
+	s.mu.Unlock()
+
+	if override != nil {
+		override(w, r)
+		return
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+// Emit queues a raw JSON event for the /events stream. Events matching the subscriber's filters
+// are delivered in order; events emitted before a subscriber connects are buffered.
+func (s *Server) Emit(event string) {
+	s.events <- []byte(event)
+}
+
+// RecordedEvents returns events recorded from a real Engine: plex starting, turning unhealthy,
+// being OOM killed, dying with exit code 137 and restarting, then a "scratch" container being
+// created.
+func RecordedEvents() []string {
+	data, _ := fixtures.ReadFile("testdata/events.jsonl")
+	var events []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			events = append(events, line)
+		}
+	}
+	return events
+}
+
+// containers returns the recorded container list, followed by the Compose stack once added.
+func (s *Server) containers() []map[string]interface{} {
+	data, _ := fixtures.ReadFile("testdata/containers.json")
+	var list []map[string]interface{}
+	_ = json.Unmarshal(data, &list)
+
+	s.mu.Lock()
+	stack := s.stack
+	s.mu.Unlock()
+	if stack {
+		data, _ = fixtures.ReadFile("testdata/stack.json")
+		var extra []map[string]interface{}
+		_ = json.Unmarshal(data, &extra)
+		list = append(list, extra...)
+	}
+	return list
+}
+
+// resolve maps an ID, unique ID prefix or name to a recorded or created container ID like the
+// Engine does.
+func (s *Server) resolve(ref string) string {
+	s.mu.Lock()
+	for id := range s.created {
+		if id == ref {
+			s.mu.Unlock()
+			return id
+		}
+	}
+	s.mu.Unlock()
+	for _, c := range s.containers() {
+		id, _ := c["Id"].(string)
+		if id == ref || (len(ref) >= 12 && strings.HasPrefix(id, ref)) {
+			return id
+		}
+		names, _ := c["Names"].([]interface{})
+		for _, n := range names {
+			if n == "/"+ref {
+				return id
+			}
+		}
+	}
+	return ""
+}
+
+func writeNotFound(w http.ResponseWriter, ref string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusNotFound)
+	_ = json.NewEncoder(w).Encode(map[string]string{"message": "No such container: " + ref})
+}
+
+func (s *Server) handleList(w http.ResponseWriter, r *http.Request) {
+	list := s.containers()
+	if r.URL.Query().Get("all") == "" {
+		running := list[:0]
+		for _, c := range list {
+			if c["State"] == "running" {
+				running = append(running, c)
+			}
+		}
+		list = running
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(list)
+}
+
+func (s *Server) handleInspect(w http.ResponseWriter, r *http.Request) {
+	id := s.resolve(r.PathValue("id"))
+	data, err := fixtures.ReadFile("testdata/inspect/" + id + ".json")
+	if id == "" || err != nil {
+		writeNotFound(w, r.PathValue("id"))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(data)
+}
+
+// handleStats replays the recorded samples. In streaming mode the connection then stays open
+// until the client goes away, as it does for a running container.
+func (s *Server) handleStats(w http.ResponseWriter, r *http.Request) {
+	id := s.resolve(r.PathValue("id"))
+	if id == "" {
+		writeNotFound(w, r.PathValue("id"))
+		return
+	}
+	data, err := fixtures.ReadFile("testdata/stats/" + id + ".jsonl")
+	if err != nil {
+		// Stopped containers return a single zeroed sample
+		data = []byte("{}\n")
+	}
+
+	var samples [][]byte
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if line := bytes.TrimSpace(scanner.Bytes()); len(line) > 0 {
+			samples = append(samples, append([]byte{}, line...))
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if r.URL.Query().Get("stream") != "1" || len(samples) == 1 {
+		_, _ = w.Write(append(samples[len(samples)-1], '\n'))
+		return
+	}
+	flusher, _ := w.(http.Flusher)
+	for _, sample := range samples {
+		_, _ = w.Write(append(sample, '\n'))
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+	<-r.Context().Done()
+}
+
+// handleLogs replays the recorded log. Containers without a TTY (plex) get the multiplexed
+// format with every line split over two frames; TTY containers (sonarr) get raw output.
+func (s *Server) handleLogs(w http.ResponseWriter, r *http.Request) {
+	id := s.resolve(r.PathValue("id"))
+	if id == "" {
+		writeNotFound(w, r.PathValue("id"))
+		return
+	}
+	data, _ := fixtures.ReadFile("testdata/logs/" + id + ".log")
+
+	type entry struct{ stream, timestamp, text string }
+	var entries []entry
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		parts := strings.SplitN(line, " ", 3)
+		if len(parts) == 3 {
+			entries = append(entries, entry{parts[0], parts[1], parts[2]})
+		}
+	}
+
+	query := r.URL.Query()
+	if tail, err := strconv.Atoi(query.Get("tail")); err == nil && tail >= 0 && tail < len(entries) {
+		entries = entries[len(entries)-tail:]
+	}
+	if since, err := strconv.ParseInt(query.Get("since"), 10, 64); err == nil {
+		kept := entries[:0]
+		for _, e := range entries {
+			if t, err := time.Parse(time.RFC3339Nano, e.timestamp); err == nil && t.Unix() >= since {
+				kept = append(kept, e)
+			}
+		}
+		entries = kept
+	}
+
+	tty := id == SonarrID
+	w.Header().Set("Content-Type", "application/vnd.docker.raw-stream")
+	if !tty {
+		w.Header().Set("Content-Type", "application/vnd.docker.multiplexed-stream")
+	}
+	for _, e := range entries {
+		line := e.text + "\n"
+		if query.Get("timestamps") == "1" {
+			line = e.timestamp + " " + line
+		}
+		if tty {
+			_, _ = w.Write([]byte(line))
+			continue
+		}
+		stream := byte(1)
+		if e.stream == "stderr" {
+			stream = 2
+		}
+		split := len(line) / 2
+		for _, chunk := range []string{line[:split], line[split:]} {
+			header := []byte{stream, 0, 0, 0, 0, 0, 0, 0}
+			binary.BigEndian.PutUint32(header[4:], uint32(len(chunk)))
+			_, _ = w.Write(append(header, chunk...))
+		}
+	}
+
+	if query.Get("follow") == "1" {
+		if flusher, ok := w.(http.Flusher); ok {
+			flusher.Flush()
+		}
+		<-r.Context().Done()
+	}
+}
+
+func (s *Server) handleAction(w http.ResponseWriter, r *http.Request) {
+	switch r.PathValue("action") {
+	case "start", "stop", "restart", "pause", "unpause", "kill", "rename":
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	if s.resolve(r.PathValue("id")) == "" {
+		writeNotFound(w, r.PathValue("id"))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleCreate registers a new container with a generated ID. Its configuration can be read back
+// with Body("POST /containers/create").
+func (s *Server) handleCreate(w http.ResponseWriter, r *http.Request) {
+	var config struct {
+		Image string `json:"Image"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&config); err != nil || config.Image == "" {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(map[string]string{"message": "invalid container config"})
+		return
+	}
+
+	s.mu.Lock()
+	id := fmt.Sprintf("c0ffee%058d", len(s.created)+1)
+	s.created[id] = r.URL.Query().Get("name")
+	s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{"Id": id, "Warnings": []string{}})
+}
+
+func (s *Server) handleRemove(w http.ResponseWriter, r *http.Request) {
+	id := s.resolve(r.PathValue("id"))
+	if id == "" {
+		writeNotFound(w, r.PathValue("id"))
+		return
+	}
+	s.mu.Lock()
+	delete(s.created, id)
+	s.mu.Unlock()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleConnect(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Container string `json:"Container"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || s.resolve(request.Container) == "" {
+		writeNotFound(w, request.Container)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// images returns the recorded images followed by the ones pulled after PublishImage.
+func (s *Server) images() []map[string]interface{} {
+	var list []map[string]interface{}
+	entries, _ := fixtures.ReadDir("testdata/images")
+	for _, entry := range entries {
+		data, _ := fixtures.ReadFile("testdata/images/" + entry.Name())
+		var image map[string]interface{}
+		if json.Unmarshal(data, &image) == nil {
+			list = append(list, image)
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, image := range s.pulled {
+		list = append(list, image)
+	}
+	return list
+}
+
+// handleImage serves GET /images/{name}/json, where name is an ID (with or without the sha256
+// prefix) or a tag and may contain slashes. Pulled images take over the tags they were pulled
+// for.
+func (s *Server) handleImage(w http.ResponseWriter, r *http.Request) {
+	name, ok := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, "/images/"), "/json")
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	var match map[string]interface{}
+	for _, image := range s.images() {
+		if image["Id"] == name || image["Id"] == "sha256:"+name || hasTag(image, name) {
+			match = image
+		}
+	}
+	if match == nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		_ = json.NewEncoder(w).Encode(map[string]string{"message": "No such image: " + name})
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(match)
+}
+
+// hasTag reports whether image is tagged as ref, where a ref without a tag means "latest".
+func hasTag(image map[string]interface{}, ref string) bool {
+	if i := strings.LastIndex(ref, ":"); i <= strings.LastIndex(ref, "/") {
+		ref += ":latest"
+	}
+	tags, _ := image["RepoTags"].([]interface{})
+	for _, tag := range tags {
+		if tag == ref {
+			return true
+		}
+	}
+	return false
+}
+
+// handlePull streams pull progress. Images published with PublishImage are downloaded; any other
+// known tag is reported as up to date and unknown repositories fail inside the stream like they
+// do on a real Engine.
+func (s *Server) handlePull(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	image := query.Get("fromImage") + ":" + query.Get("tag")
+
+	s.mu.Lock()
+	published, ok := s.published[image]
+	if ok {
+		s.pulled[image] = published
+		delete(s.published, image)
+	}
+	s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(w)
+	_ = encoder.Encode(map[string]string{"status": "Pulling from " + query.Get("fromImage"), "id": query.Get("tag")})
+	switch {
+	case ok:
+		_ = encoder.Encode(map[string]string{"status": "Downloaded newer image for " + image})
+	case s.knownTag(image):
+		_ = encoder.Encode(map[string]string{"status": "Image is up to date for " + image})
+	default:
+		message := "pull access denied for " + query.Get("fromImage") + ", repository does not exist"
+		_ = encoder.Encode(map[string]interface{}{"errorDetail": map[string]string{"message": message}, "error": message})
+	}
+}
+
+func (s *Server) knownTag(ref string) bool {
+	for _, image := range s.images() {
+		if hasTag(image, ref) {
+			return true
+		}
+	}
+	return false
+}
+
+// serveFixture returns a handler that replays a recorded response.
+func serveFixture(name string) http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		data, _ := fixtures.ReadFile(name)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(data)
+	}
+}
+
+// handleImageList converts the recorded images into GET /images/json summaries.
+func (s *Server) handleImageList(w http.ResponseWriter, _ *http.Request) {
+	list := make([]map[string]interface{}, 0)
+	for _, image := range s.images() {
+		created, _ := time.Parse(time.RFC3339Nano, fmt.Sprint(image["Created"]))
+		list = append(list, map[string]interface{}{
+			"Id":          image["Id"],
+			"ParentId":    "",
+			"RepoTags":    image["RepoTags"],
+			"RepoDigests": image["RepoDigests"],
+			"Created":     created.Unix(),
+			"Size":        image["Size"],
+			"SharedSize":  -1,
+			"Labels":      nil,
+			"Containers":  -1,
+		})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(list)
+}
+
+// handlePrune reports what the Engine would remove from the recorded state: untagged images,
+// stopped containers, and volumes with no references (only anonymous ones unless the "all"
+// filter is set). Nothing is actually removed.
+func (s *Server) handlePrune(w http.ResponseWriter, r *http.Request) {
+	var filters map[string][]string
+	_ = json.Unmarshal([]byte(r.URL.Query().Get("filters")), &filters)
+
+	data, _ := fixtures.ReadFile("testdata/df.json")
+	var usage struct {
+		Images []struct {
+			ID       string   `json:"Id"`
+			RepoTags []string `json:"RepoTags"`
+			Size     uint64   `json:"Size"`
+		} `json:"Images"`
+		Containers []struct {
+			ID     string `json:"Id"`
+			State  string `json:"State"`
+			SizeRw uint64 `json:"SizeRw"`
+		} `json:"Containers"`
+		Volumes []struct {
+			Name      string            `json:"Name"`
+			Labels    map[string]string `json:"Labels"`
+			UsageData struct {
+				RefCount int    `json:"RefCount"`
+				Size     uint64 `json:"Size"`
+			} `json:"UsageData"`
+		} `json:"Volumes"`
+	}
+	_ = json.Unmarshal(data, &usage)
+
+	report := map[string]interface{}{}
+	var reclaimed uint64
+	switch r.URL.Path {
+	case "/images/prune":
+		deleted := []map[string]string{}
+		for _, image := range usage.Images {
+			if len(image.RepoTags) == 0 {
+				deleted = append(deleted, map[string]string{"Deleted": image.ID})
+				reclaimed += image.Size
+			}
+		}
+		report["ImagesDeleted"] = deleted
+	case "/containers/prune":
+		deleted := []string{}
+		for _, c := range usage.Containers {
+			if c.State != "running" {
+				deleted = append(deleted, c.ID)
+				reclaimed += c.SizeRw
+			}
+		}
+		report["ContainersDeleted"] = deleted
+	case "/volumes/prune":
+		deleted := []string{}
+		for _, v := range usage.Volumes {
+			_, anonymous := v.Labels["com.docker.volume.anonymous"]
+			if v.UsageData.RefCount == 0 && (anonymous || len(filters["all"]) > 0) {
+				deleted = append(deleted, v.Name)
+				reclaimed += v.UsageData.Size
+			}
+		}
+		report["VolumesDeleted"] = deleted
+	}
+	report["SpaceReclaimed"] = reclaimed
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(report)
+}
+
+// handleEvents streams emitted events until the client goes away. The "type" and "event"
+// filters are applied like the Engine does, where "health_status" matches every health status.
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	var filters map[string][]string
+	if raw := r.URL.Query().Get("filters"); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &filters); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+	if flusher != nil {
+		flusher.Flush()
+	}
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event := <-s.events:
+			var message struct {
+				Type   string `json:"Type"`
+				Action string `json:"Action"`
+			}
+			_ = json.Unmarshal(event, &message)
+			if !matchFilter(filters["type"], message.Type) || !matchFilter(filters["event"], message.Action) {
+				continue
+			}
+			_, _ = w.Write(append(event, '\n'))
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+	}
+}
+
+func matchFilter(values []string, actual string) bool {
+	if len(values) == 0 {
+		return true
+	}
+	for _, v := range values {
+		if v == actual || strings.HasPrefix(actual, v+":") {
+			return true
+		}
+	}
+	return false
+}
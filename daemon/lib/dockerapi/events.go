@@ -0,0 +1,54 @@
+package dockerapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// Event is a message of the GET /events stream.
+type Event struct {
+	Type   string `json:"Type"`
+	Action string `json:"Action"`
+	Actor  struct {
+		ID         string            `json:"ID"`
+		Attributes map[string]string `json:"Attributes"`
+	} `json:"Actor"`
+	Time     int64 `json:"time"`
+	TimeNano int64 `json:"timeNano"`
+}
+
+// StreamEvents follows the Engine event stream and calls fn for every event until ctx is
+// cancelled or the Engine closes the stream. filters restricts the events, for example
+// {"type": {"container"}, "event": {"start", "die"}}.
+func (c *Client) StreamEvents(ctx context.Context, filters map[string][]string, fn func(Event)) error {
+	query := url.Values{}
+	if len(filters) > 0 {
+		encoded, err := json.Marshal(filters)
+		if err != nil {
+			return err
+		}
+		query.Set("filters", string(encoded))
+	}
+
+	resp, err := c.do(ctx, http.MethodGet, "/events", query, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var event Event
+		if err := decoder.Decode(&event); err != nil {
+			if errors.Is(err, io.EOF) || ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		fn(event)
+	}
+}
@@ -0,0 +1,183 @@
+package dockerapi
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/lib/registry"
+)
+
+// pruneTimeout bounds prune requests, which can take minutes on a large image store.
+const pruneTimeout = 5 * time.Minute
+
+// ImageInspect is the response of GET /images/{name}/json.
+type ImageInspect struct {
+	ID          string   `json:"Id"`
+	RepoTags    []string `json:"RepoTags"`
+	RepoDigests []string `json:"RepoDigests"`
+	Created     string   `json:"Created"`
+	Size        int64    `json:"Size"`
+}
+
+// ImageSummary is an entry of GET /images/json.
+type ImageSummary struct {
+	ID          string            `json:"Id"`
+	ParentID    string            `json:"ParentId"`
+	RepoTags    []string          `json:"RepoTags"`
+	RepoDigests []string          `json:"RepoDigests"`
+	Created     int64             `json:"Created"`
+	Size        int64             `json:"Size"`
+	SharedSize  int64             `json:"SharedSize"`
+	Labels      map[string]string `json:"Labels"`
+	// Containers is -1 unless the image comes from DiskUsage
+	Containers int64 `json:"Containers"`
+}
+
+// Dangling reports whether the image has no tags left, which happens when a newer image took
+// over its tag.
+func (i *ImageSummary) Dangling() bool {
+	for _, tag := range i.RepoTags {
+		if tag != "<none>:<none>" {
+			return false
+		}
+	}
+	return true
+}
+
+// ListImages returns all top-level images.
+func (c *Client) ListImages(ctx context.Context) ([]ImageSummary, error) {
+	var images []ImageSummary
+	if err := c.getJSON(ctx, "/images/json", nil, &images); err != nil {
+		return nil, err
+	}
+	return images, nil
+}
+
+// PruneReport is the response of the prune endpoints. Only the list matching the pruned
+// resource is set.
+type PruneReport struct {
+	ContainersDeleted []string `json:"ContainersDeleted"`
+	ImagesDeleted     []struct {
+		Untagged string `json:"Untagged"`
+		Deleted  string `json:"Deleted"`
+	} `json:"ImagesDeleted"`
+	VolumesDeleted []string `json:"VolumesDeleted"`
+	SpaceReclaimed uint64   `json:"SpaceReclaimed"`
+}
+
+// PruneImages removes dangling images.
+func (c *Client) PruneImages(ctx context.Context) (*PruneReport, error) {
+	return c.prune(ctx, "/images/prune", map[string][]string{"dangling": {"true"}})
+}
+
+// prune calls a prune endpoint with optional filters. Pruning walks every resource of its kind,
+// so it gets a longer timeout than other requests.
+func (c *Client) prune(ctx context.Context, path string, filters map[string][]string) (*PruneReport, error) {
+	ctx, cancel := context.WithTimeout(ctx, pruneTimeout)
+	defer cancel()
+
+	query := url.Values{}
+	if len(filters) > 0 {
+		data, err := json.Marshal(filters)
+		if err != nil {
+			return nil, err
+		}
+		query.Set("filters", string(data))
+	}
+	var report PruneReport
+	if err := c.send(ctx, http.MethodPost, path, query, nil, &report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// InspectImage returns an image by ID or reference.
+func (c *Client) InspectImage(ctx context.Context, name string) (*ImageInspect, error) {
+	var image ImageInspect
+	if err := c.getJSON(ctx, "/images/"+name+"/json", nil, &image); err != nil {
+		return nil, err
+	}
+	return &image, nil
+}
+
+// PullImage pulls an image reference such as "plexinc/pms-docker:latest". It blocks until the
+// pull finishes; only ctx bounds how long that may take. Failures reported in the progress
+// stream are returned as *Error. The Engine does not read the docker CLI config, so the
+// credentials `docker login` saved for the image's registry are passed along with the request.
+func (c *Client) PullImage(ctx context.Context, image string) error {
+	name, tag := splitImageTag(image)
+	query := url.Values{"fromImage": {name}, "tag": {tag}}
+	header, err := registryAuth(image)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.doWithHeader(ctx, http.MethodPost, "/images/create", query, nil, header)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var message struct {
+			Status      string `json:"status"`
+			Error       string `json:"error"`
+			ErrorDetail struct {
+				Message string `json:"message"`
+			} `json:"errorDetail"`
+		}
+		if err := decoder.Decode(&message); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("failed to read pull progress: %w", err)
+		}
+		if message.Error != "" {
+			return &Error{StatusCode: http.StatusInternalServerError, Message: message.Error}
+		}
+	}
+}
+
+// splitImageTag separates the tag or digest from an image reference. References without either
+// get "latest", because an empty tag makes the Engine pull every tag of the repository.
+func splitImageTag(image string) (name, tag string) {
+	if i := strings.Index(image, "@"); i >= 0 {
+		return image[:i], image[i+1:]
+	}
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		return image[:i], image[i+1:]
+	}
+	return image, "latest"
+}
+
+// registryAuth returns the X-Registry-Auth header carrying the saved credentials for image's
+// registry, or no header when there are none.
+func registryAuth(image string) (http.Header, error) {
+	ref, err := registry.ParseReference(image)
+	if err != nil {
+		// The Engine rejects the reference with a better message
+		return nil, nil
+	}
+	creds, ok, err := registry.LookupCredentials(ref.Registry)
+	if err != nil || !ok {
+		return nil, err
+	}
+	payload, err := json.Marshal(map[string]string{
+		"username":      creds.Username,
+		"password":      creds.Password,
+		"serveraddress": ref.Registry,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return http.Header{"X-Registry-Auth": {base64.URLEncoding.EncodeToString(payload)}}, nil
+}
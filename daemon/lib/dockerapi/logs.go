@@ -0,0 +1,183 @@
+package dockerapi
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Log stream names.
+const (
+	StreamStdout = "stdout"
+	StreamStderr = "stderr"
+)
+
+// maxLogLine bounds a single log line; longer lines are split.
+const maxLogLine = 64 * 1024
+
+// LogOptions selects the log output of a container.
+type LogOptions struct {
+	// Tail is the number of lines from the end of the log, or "all"
+	Tail string
+	// Since only returns lines after this time when non-zero
+	Since time.Time
+	// Timestamps prefixes every line with its RFC3339Nano timestamp
+	Timestamps bool
+	// Follow keeps the stream open and returns new lines as they are written
+	Follow bool
+}
+
+// LogLine is one line of container output.
+type LogLine struct {
+	Stream    string
+	Timestamp time.Time
+	Text      string
+}
+
+// ContainerLogs reads the stdout and stderr logs of a container and calls fn for every line
+// until the log ends, ctx is cancelled (when following) or fn returns an error. tty tells
+// whether the container was created with a TTY, in which case the Engine sends a raw stream
+// that cannot be split by source and every line is reported as stdout.
+func (c *Client) ContainerLogs(ctx context.Context, id string, tty bool, opts LogOptions, fn func(LogLine) error) error {
+	query := url.Values{"stdout": {"1"}, "stderr": {"1"}}
+	if opts.Tail != "" {
+		query.Set("tail", opts.Tail)
+	}
+	if !opts.Since.IsZero() {
+		query.Set("since", fmt.Sprintf("%d", opts.Since.Unix()))
+	}
+	if opts.Timestamps {
+		query.Set("timestamps", "1")
+	}
+	if opts.Follow {
+		query.Set("follow", "1")
+	} else {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, requestTimeout)
+		defer cancel()
+	}
+
+	resp, err := c.do(ctx, http.MethodGet, "/containers/"+url.PathEscape(id)+"/logs", query, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	emit := func(stream string, line []byte) error {
+		entry := LogLine{Stream: stream, Text: string(line)}
+		if opts.Timestamps {
+			if ts, text, ok := strings.Cut(entry.Text, " "); ok {
+				if t, err := time.Parse(time.RFC3339Nano, ts); err == nil {
+					entry.Timestamp = t
+					entry.Text = text
+				}
+			}
+		}
+		return fn(entry)
+	}
+
+	if tty {
+		err = splitLines(resp.Body, func(line []byte) error { return emit(StreamStdout, line) })
+	} else {
+		err = demux(resp.Body, emit)
+	}
+	if err != nil && ctx.Err() != nil && opts.Follow {
+		return nil
+	}
+	return err
+}
+
+// splitLines calls fn for every line of r without the trailing newline. Lines of maxLogLine
+// bytes or more are passed on in chunks of exactly maxLogLine bytes, as demux does.
+func splitLines(r io.Reader, fn func([]byte) error) error {
+	reader := bufio.NewReaderSize(r, maxLogLine)
+	for {
+		line, err := reader.ReadSlice('\n')
+		switch {
+		case err == nil:
+			line = bytes.TrimSuffix(line[:len(line)-1], []byte("\r"))
+		case errors.Is(err, bufio.ErrBufferFull):
+		case errors.Is(err, io.EOF):
+			if len(line) == 0 {
+				return nil
+			}
+		default:
+			return err
+		}
+		if err := fn(line); err != nil {
+			return err
+		}
+	}
+}
+
+// demux splits a multiplexed log stream. Each frame has an 8-byte header holding the stream
+// (1 for stdout, 2 for stderr) and the big-endian payload size. Frames do not align with lines,
+// so partial lines are buffered per stream until their newline arrives.
+func demux(r io.Reader, fn func(stream string, line []byte) error) error {
+	pending := map[string][]byte{}
+	// flush splits lines as splitLines does: lines are cut into chunks of maxLogLine bytes
+	flush := func(stream string, final bool) error {
+		buf := pending[stream]
+		for {
+			var line []byte
+			if i := bytes.IndexByte(buf, '\n'); i >= 0 && i < maxLogLine {
+				line, buf = bytes.TrimSuffix(buf[:i], []byte("\r")), buf[i+1:]
+			} else if len(buf) >= maxLogLine {
+				line, buf = buf[:maxLogLine], buf[maxLogLine:]
+			} else {
+				break
+			}
+			if err := fn(stream, line); err != nil {
+				return err
+			}
+		}
+		if len(buf) > 0 && final {
+			if err := fn(stream, buf); err != nil {
+				return err
+			}
+			buf = nil
+		}
+		pending[stream] = append([]byte{}, buf...)
+		return nil
+	}
+
+	header := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return err
+		}
+
+		stream := StreamStdout
+		if header[0] == 2 {
+			stream = StreamStderr
+		}
+		size := binary.BigEndian.Uint32(header[4:])
+		payload := make([]byte, size)
+		if _, err := io.ReadFull(r, payload); err != nil {
+			return err
+		}
+
+		pending[stream] = append(pending[stream], payload...)
+		if err := flush(stream, false); err != nil {
+			return err
+		}
+	}
+
+	for _, stream := range []string{StreamStdout, StreamStderr} {
+		if err := flush(stream, true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package dockerapi
+
+import "context"
+
+// IPAMConfig is an address pool of a network.
+type IPAMConfig struct {
+	Subnet  string `json:"Subnet"`
+	Gateway string `json:"Gateway"`
+	IPRange string `json:"IPRange"`
+}
+
+// Network is an entry of GET /networks. The list does not include attached containers; those
+// are found through the networks of each container.
+type Network struct {
+	ID         string `json:"Id"`
+	Name       string `json:"Name"`
+	Created    string `json:"Created"`
+	Driver     string `json:"Driver"`
+	Scope      string `json:"Scope"`
+	Internal   bool   `json:"Internal"`
+	EnableIPv6 bool   `json:"EnableIPv6"`
+	IPAM       struct {
+		Driver string       `json:"Driver"`
+		Config []IPAMConfig `json:"Config"`
+	} `json:"IPAM"`
+	Options map[string]string `json:"Options"`
+	Labels  map[string]string `json:"Labels"`
+}
+
+// ListNetworks returns all networks.
+func (c *Client) ListNetworks(ctx context.Context) ([]Network, error) {
+	var networks []Network
+	if err := c.getJSON(ctx, "/networks", nil, &networks); err != nil {
+		return nil, err
+	}
+	return networks, nil
+}
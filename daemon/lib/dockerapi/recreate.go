@@ -0,0 +1,289 @@
+package dockerapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// rawContainer is an inspect result with the configuration kept as raw JSON, so settings this
+// package does not model survive a recreate unchanged.
+type rawContainer struct {
+	ID              string                     `json:"Id"`
+	Name            string                     `json:"Name"`
+	Image           string                     `json:"Image"` // ID of the image the container was created from
+	State           ContainerState             `json:"State"`
+	Config          map[string]json.RawMessage `json:"Config"`
+	HostConfig      json.RawMessage            `json:"HostConfig"`
+	NetworkSettings struct {
+		Networks map[string]rawEndpoint `json:"Networks"`
+	} `json:"NetworkSettings"`
+}
+
+// rawEndpoint holds the user-configurable part of a network attachment.
+type rawEndpoint struct {
+	IPAMConfig json.RawMessage `json:"IPAMConfig,omitempty"`
+	Links      []string        `json:"Links,omitempty"`
+	Aliases    []string        `json:"Aliases,omitempty"`
+}
+
+// RecreateContainer replaces a container with a new one created from the same configuration,
+// which picks up the image its tag currently points to. Settings the container only inherited
+// from its old image, such as its Env entries, Cmd or WorkingDir, are left out so the new image's
+// defaults apply. The old container is stopped and kept under a temporary name until the new one
+// is running; if any step fails it is renamed back and restarted. It returns the ID of the new
+// container. When only removing the old container fails, the new ID is returned together with
+// the error.
+func (c *Client) RecreateContainer(ctx context.Context, id string) (string, error) {
+	var old rawContainer
+	if err := c.getJSON(ctx, "/containers/"+url.PathEscape(id)+"/json", nil, &old); err != nil {
+		return "", err
+	}
+	// Without the old image, such as after it was pruned, the whole configuration is kept
+	var image struct {
+		Config map[string]json.RawMessage `json:"Config"`
+	}
+	if old.Image != "" {
+		_ = c.getJSON(ctx, "/images/"+url.PathEscape(old.Image)+"/json", nil, &image)
+	}
+	create, extraNetworks := createRequest(&old, image.Config)
+	return c.replace(ctx, &old, strings.TrimPrefix(old.Name, "/"), create, extraNetworks, old.State.Running || old.State.Restarting)
+}
+
+// ReplaceContainer creates a container named name from a create request body, replacing an
+// existing container of that name the same way RecreateContainer does. The new container is
+// started when start is set. It returns the ID of the new container.
+func (c *Client) ReplaceContainer(ctx context.Context, name string, config interface{}, start bool) (string, error) {
+	var old rawContainer
+	err := c.getJSON(ctx, "/containers/"+url.PathEscape(name)+"/json", nil, &old)
+	if IsNotFound(err) {
+		return c.replace(ctx, nil, name, config, nil, start)
+	}
+	if err != nil {
+		return "", err
+	}
+	return c.replace(ctx, &old, name, config, nil, start)
+}
+
+// replace creates the container name from create and, when old is set, swaps it in for the old
+// container. Extra networks are connected before the new container is started.
+func (c *Client) replace(ctx context.Context, old *rawContainer, name string, create interface{},
+	extraNetworks map[string]rawEndpoint, start bool) (string, error) {
+	if old == nil {
+		newID, err := c.CreateContainer(ctx, name, create)
+		if err != nil {
+			return "", fmt.Errorf("failed to create container: %w", err)
+		}
+		if start {
+			if err := c.ContainerAction(ctx, newID, "start"); err != nil {
+				_ = c.RemoveContainer(ctx, newID, true)
+				return "", fmt.Errorf("failed to start container: %w", err)
+			}
+		}
+		return newID, nil
+	}
+
+	wasRunning := old.State.Running || old.State.Restarting
+	if wasRunning {
+		if err := c.ContainerAction(ctx, old.ID, "stop"); err != nil {
+			return "", fmt.Errorf("failed to stop container: %w", err)
+		}
+	}
+	backup := name + "-old-" + old.ID[:min(12, len(old.ID))]
+	if err := c.RenameContainer(ctx, old.ID, backup); err != nil {
+		c.restore(ctx, old.ID, "", wasRunning)
+		return "", fmt.Errorf("failed to rename container: %w", err)
+	}
+
+	newID, err := c.CreateContainer(ctx, name, create)
+	if err != nil {
+		c.restore(ctx, old.ID, name, wasRunning)
+		return "", fmt.Errorf("failed to create container: %w", err)
+	}
+
+	rollback := func(step string, err error) (string, error) {
+		_ = c.RemoveContainer(ctx, newID, true)
+		c.restore(ctx, old.ID, name, wasRunning)
+		return "", fmt.Errorf("failed to %s: %w", step, err)
+	}
+	for network, endpoint := range extraNetworks {
+		if err := c.connectNetwork(ctx, network, newID, endpoint); err != nil {
+			return rollback("connect network "+network, err)
+		}
+	}
+	if start {
+		if err := c.ContainerAction(ctx, newID, "start"); err != nil {
+			return rollback("start container", err)
+		}
+	}
+
+	if err := c.RemoveContainer(ctx, old.ID, false); err != nil {
+		return newID, fmt.Errorf("failed to remove old container %s: %w", backup, err)
+	}
+	return newID, nil
+}
+
+// restore undoes a partial recreate by giving the old container its name back (unless name is
+// empty) and starting it again if it was running.
+func (c *Client) restore(ctx context.Context, id, name string, start bool) {
+	if name != "" {
+		_ = c.RenameContainer(ctx, id, name)
+	}
+	if start {
+		_ = c.ContainerAction(ctx, id, "start")
+	}
+}
+
+// createRequest builds a POST /containers/create body from an inspected container, leaving out
+// the values it shares with imageConfig, the configuration of the image it was created from. The
+// Engine only accepts one network at create time, so the network named by NetworkMode is
+// configured there and the remaining networks are returned to be connected afterwards.
+func createRequest(old *rawContainer, imageConfig map[string]json.RawMessage) (map[string]interface{}, map[string]rawEndpoint) {
+	body := make(map[string]interface{}, len(old.Config)+2)
+	for key, value := range withoutImageDefaults(old.Config, imageConfig) {
+		body[key] = value
+	}
+	// A hostname equal to the short ID was generated by the Engine and must not stick to the
+	// new container
+	var hostname string
+	if raw, ok := old.Config["Hostname"]; ok && json.Unmarshal(raw, &hostname) == nil &&
+		len(old.ID) >= 12 && hostname == old.ID[:12] {
+		delete(body, "Hostname")
+	}
+	body["HostConfig"] = old.HostConfig
+
+	var hostConfig struct {
+		NetworkMode string `json:"NetworkMode"`
+	}
+	_ = json.Unmarshal(old.HostConfig, &hostConfig)
+	primary := hostConfig.NetworkMode
+	if primary == "" || primary == "default" {
+		primary = "bridge"
+	}
+	if primary == "host" || primary == "none" || strings.HasPrefix(primary, "container:") {
+		return body, nil
+	}
+
+	endpoints := make(map[string]rawEndpoint)
+	extra := make(map[string]rawEndpoint)
+	for network, endpoint := range old.NetworkSettings.Networks {
+		// Aliases include the old short ID, which the Engine adds again for the new container
+		aliases := endpoint.Aliases[:0:0]
+		for _, alias := range endpoint.Aliases {
+			if len(old.ID) < 12 || alias != old.ID[:12] {
+				aliases = append(aliases, alias)
+			}
+		}
+		endpoint.Aliases = aliases
+		if network == primary {
+			endpoints[network] = endpoint
+		} else {
+			extra[network] = endpoint
+		}
+	}
+	body["NetworkingConfig"] = map[string]interface{}{"EndpointsConfig": endpoints}
+	return body, extra
+}
+
+// imageDefaults are the container settings an image provides. imageDefaultLists and
+// imageDefaultMaps are merged with the image's entries, so only their matching entries are
+// image defaults; the others are replaced as a whole.
+var (
+	imageDefaults     = []string{"Cmd", "Entrypoint", "WorkingDir", "User", "StopSignal", "Healthcheck", "Shell"}
+	imageDefaultLists = []string{"Env"}
+	imageDefaultMaps  = []string{"Labels", "ExposedPorts", "Volumes"}
+)
+
+// withoutImageDefaults returns the container configuration without the values that equal the
+// image's, so a recreated container picks up what a new image changed instead of keeping the
+// old image's values. Values the user set to something else are kept.
+func withoutImageDefaults(config, image map[string]json.RawMessage) map[string]json.RawMessage {
+	if len(image) == 0 {
+		return config
+	}
+
+	result := make(map[string]json.RawMessage, len(config))
+	for key, value := range config {
+		result[key] = value
+	}
+	for _, key := range imageDefaults {
+		if value, ok := result[key]; ok && jsonEqual(value, image[key]) {
+			delete(result, key)
+		}
+	}
+	for _, key := range imageDefaultLists {
+		var values, defaults []string
+		if json.Unmarshal(result[key], &values) != nil || json.Unmarshal(image[key], &defaults) != nil {
+			continue
+		}
+		kept := values[:0:0]
+		for _, value := range values {
+			if !slices.Contains(defaults, value) {
+				kept = append(kept, value)
+			}
+		}
+		result[key], _ = json.Marshal(kept)
+	}
+	for _, key := range imageDefaultMaps {
+		var values, defaults map[string]json.RawMessage
+		if json.Unmarshal(result[key], &values) != nil || json.Unmarshal(image[key], &defaults) != nil {
+			continue
+		}
+		for name, value := range values {
+			if def, ok := defaults[name]; ok && jsonEqual(value, def) {
+				delete(values, name)
+			}
+		}
+		result[key], _ = json.Marshal(values)
+	}
+	return result
+}
+
+// jsonEqual reports whether two JSON values are equal regardless of formatting and key order. A
+// missing value equals null.
+func jsonEqual(a, b json.RawMessage) bool {
+	var va, vb interface{}
+	if len(a) > 0 && json.Unmarshal(a, &va) != nil {
+		return false
+	}
+	if len(b) > 0 && json.Unmarshal(b, &vb) != nil {
+		return false
+	}
+	return reflect.DeepEqual(va, vb)
+}
+
+// CreateContainer creates a container named name from a create request body and returns its ID.
+func (c *Client) CreateContainer(ctx context.Context, name string, config interface{}) (string, error) {
+	var created struct {
+		ID string `json:"Id"`
+	}
+	if err := c.call(ctx, http.MethodPost, "/containers/create", url.Values{"name": {name}}, config, &created); err != nil {
+		return "", err
+	}
+	return created.ID, nil
+}
+
+// RenameContainer gives a container a new name.
+func (c *Client) RenameContainer(ctx context.Context, id, name string) error {
+	return c.post(ctx, "/containers/"+url.PathEscape(id)+"/rename", url.Values{"name": {name}})
+}
+
+// RemoveContainer deletes a container. With force a running container is killed first.
+func (c *Client) RemoveContainer(ctx context.Context, id string, force bool) error {
+	query := url.Values{}
+	if force {
+		query.Set("force", "1")
+	}
+	return c.call(ctx, http.MethodDelete, "/containers/"+url.PathEscape(id), query, nil, nil)
+}
+
+// connectNetwork attaches a container to a network with the given endpoint settings.
+func (c *Client) connectNetwork(ctx context.Context, network, container string, endpoint rawEndpoint) error {
+	body := map[string]interface{}{"Container": container, "EndpointConfig": endpoint}
+	return c.call(ctx, http.MethodPost, "/networks/"+url.PathEscape(network)+"/connect", nil, body, nil)
+}
@@ -0,0 +1,42 @@
+package dockerapi
+
+import (
+	"context"
+	"net/http"
+)
+
+// DiskUsage is the response of GET /system/df: every image, container, volume and build cache
+// entry with the space it takes.
+type DiskUsage struct {
+	LayersSize int64          `json:"LayersSize"`
+	Images     []ImageSummary `json:"Images"`
+	Containers []struct {
+		ID         string   `json:"Id"`
+		Names      []string `json:"Names"`
+		Image      string   `json:"Image"`
+		ImageID    string   `json:"ImageID"`
+		State      string   `json:"State"`
+		SizeRw     int64    `json:"SizeRw"`
+		SizeRootFs int64    `json:"SizeRootFs"`
+	} `json:"Containers"`
+	Volumes    []Volume `json:"Volumes"`
+	BuildCache []struct {
+		ID     string `json:"ID"`
+		Size   int64  `json:"Size"`
+		InUse  bool   `json:"InUse"`
+		Shared bool   `json:"Shared"`
+	} `json:"BuildCache"`
+}
+
+// DiskUsage computes the space used by Docker. The Engine walks every layer and volume to
+// answer, so it gets the prune timeout rather than the default one.
+func (c *Client) DiskUsage(ctx context.Context) (*DiskUsage, error) {
+	ctx, cancel := context.WithTimeout(ctx, pruneTimeout)
+	defer cancel()
+
+	var usage DiskUsage
+	if err := c.send(ctx, http.MethodGet, "/system/df", nil, nil, &usage); err != nil {
+		return nil, err
+	}
+	return &usage, nil
+}
@@ -0,0 +1,39 @@
+package dockerapi
+
+import "context"
+
+// Volume is a named or anonymous volume.
+type Volume struct {
+	Name       string            `json:"Name"`
+	Driver     string            `json:"Driver"`
+	Mountpoint string            `json:"Mountpoint"`
+	CreatedAt  string            `json:"CreatedAt"`
+	Labels     map[string]string `json:"Labels"`
+	Scope      string            `json:"Scope"`
+	// UsageData is only filled in by DiskUsage; Size and RefCount are -1 when unknown
+	UsageData *struct {
+		Size     int64 `json:"Size"`
+		RefCount int64 `json:"RefCount"`
+	} `json:"UsageData"`
+}
+
+// ListVolumes returns all volumes.
+func (c *Client) ListVolumes(ctx context.Context) ([]Volume, error) {
+	var response struct {
+		Volumes []Volume `json:"Volumes"`
+	}
+	if err := c.getJSON(ctx, "/volumes", nil, &response); err != nil {
+		return nil, err
+	}
+	return response.Volumes, nil
+}
+
+// PruneVolumes removes volumes not used by any container. The Engine only prunes anonymous
+// volumes unless all is set, in which case unused named volumes are removed as well.
+func (c *Client) PruneVolumes(ctx context.Context, all bool) (*PruneReport, error) {
+	var filters map[string][]string
+	if all {
+		filters = map[string][]string{"all": {"true"}}
+	}
+	return c.prune(ctx, "/volumes/prune", filters)
+}
@@ -0,0 +1,53 @@
+package dockerman
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Autostart is a container the Unraid Docker page starts when the array starts. Wait is the
+// delay the user configured after starting it, before the next container is started.
+type Autostart struct {
+	Name string
+	Wait time.Duration
+}
+
+// ReadAutostart reads the autostart list dockerMan keeps in /var/lib/docker/unraid-autostart,
+// in the order the containers are started. Each line holds a container name optionally followed
+// by a wait in seconds. A missing file means no container starts automatically.
+func ReadAutostart(path string) ([]Autostart, error) {
+	// #nosec G304 - dockerMan's autostart list, a fixed path under /var/lib/docker
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var list []Autostart
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		entry := Autostart{Name: fields[0]}
+		if len(fields) > 1 {
+			seconds, err := strconv.Atoi(fields[1])
+			if err != nil || seconds < 0 {
+				return nil, fmt.Errorf("%s: invalid wait for %s: %q", path, entry.Name, fields[1])
+			}
+			entry.Wait = time.Duration(seconds) * time.Second
+		}
+		list = append(list, entry)
+	}
+	return list, scanner.Err()
+}
@@ -0,0 +1,394 @@
+package dockerman
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// CreateConfig builds a Docker Engine POST /containers/create body from the template, matching
+// the docker run command Unraid generates. ExtraParams are applied for the common options
+// (restart policy, runtime, resource limits, devices, capabilities and the like); anything else
+// is returned as a warning instead of being silently dropped.
+func (t *Template) CreateConfig() (map[string]interface{}, []string) {
+	var warnings []string
+
+	env := []string{"HOST_OS=Unraid", "HOST_CONTAINERNAME=" + t.Name}
+	labels := map[string]string{LabelManaged: "dockerman"}
+	if webUI := strings.TrimSpace(t.WebUI); webUI != "" {
+		labels[LabelWebUI] = webUI
+	}
+	if icon := strings.TrimSpace(t.Icon); icon != "" {
+		labels[LabelIcon] = icon
+	}
+
+	hostConfig := map[string]interface{}{
+		"NetworkMode":   t.Network,
+		"Privileged":    t.IsPrivileged(),
+		"RestartPolicy": map[string]interface{}{"Name": "no"},
+	}
+	var binds []string
+	var devices []map[string]string
+	portBindings := make(map[string][]map[string]string)
+	exposedPorts := make(map[string]struct{})
+
+	for _, c := range t.Configs {
+		value := c.Value
+		if value == "" {
+			value = c.Default
+		}
+		switch c.Type {
+		case TypePath:
+			if value == "" || c.Target == "" {
+				continue
+			}
+			mode := c.Mode
+			if mode == "" {
+				mode = "rw"
+			}
+			binds = append(binds, value+":"+c.Target+":"+mode)
+		case TypePort:
+			// Only the bridge network publishes ports; other networks expose them directly
+			if t.Network != "bridge" || value == "" || c.Target == "" {
+				continue
+			}
+			protocol := strings.ToLower(c.Mode)
+			if protocol == "" {
+				protocol = "tcp"
+			}
+			key := c.Target + "/" + protocol
+			exposedPorts[key] = struct{}{}
+			portBindings[key] = append(portBindings[key], map[string]string{"HostIp": "", "HostPort": value})
+		case TypeVariable:
+			if c.Target != "" {
+				env = append(env, c.Target+"="+value)
+			}
+		case TypeDevice:
+			if value == "" {
+				continue
+			}
+			target := c.Target
+			if target == "" {
+				target = value
+			}
+			devices = append(devices, map[string]string{
+				"PathOnHost": value, "PathInContainer": target, "CgroupPermissions": "rwm",
+			})
+		case TypeLabel:
+			if c.Target != "" {
+				labels[c.Target] = value
+			}
+		}
+	}
+
+	config := map[string]interface{}{
+		"Image":  t.Repository,
+		"Labels": labels,
+	}
+	if cpuset := strings.TrimSpace(t.CPUset); cpuset != "" {
+		hostConfig["CpusetCpus"] = cpuset
+	}
+	if args := strings.TrimSpace(t.PostArgs); args != "" {
+		cmd, err := splitArgs(args)
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("PostArgs ignored: %v", err))
+		} else {
+			config["Cmd"] = cmd
+		}
+	}
+	if params := strings.TrimSpace(t.ExtraParams); params != "" {
+		args, err := splitArgs(params)
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("ExtraParams ignored: %v", err))
+		} else {
+			extra := &extraParams{config: config, hostConfig: hostConfig, labels: labels, env: env, devices: devices}
+			warnings = append(warnings, extra.apply(args)...)
+			env, devices = extra.env, extra.devices
+		}
+	}
+
+	config["Env"] = env
+	if len(binds) > 0 {
+		hostConfig["Binds"] = binds
+	}
+	if len(devices) > 0 {
+		hostConfig["Devices"] = devices
+	}
+	if len(portBindings) > 0 {
+		hostConfig["PortBindings"] = portBindings
+		config["ExposedPorts"] = exposedPorts
+	}
+	config["HostConfig"] = hostConfig
+
+	if ip := strings.TrimSpace(t.MyIP); ip != "" && t.Network != "bridge" && t.Network != "host" {
+		ipam := map[string]string{}
+		for _, addr := range strings.Fields(strings.ReplaceAll(ip, ",", " ")) {
+			if strings.Contains(addr, ":") {
+				ipam["IPv6Address"] = addr
+			} else {
+				ipam["IPv4Address"] = addr
+			}
+		}
+		config["NetworkingConfig"] = map[string]interface{}{
+			"EndpointsConfig": map[string]interface{}{t.Network: map[string]interface{}{"IPAMConfig": ipam}},
+		}
+	}
+	return config, warnings
+}
+
+// extraParams applies docker run options from a template's ExtraParams field.
+type extraParams struct {
+	config     map[string]interface{}
+	hostConfig map[string]interface{}
+	labels     map[string]string
+	env        []string
+	devices    []map[string]string
+}
+
+// apply handles each option and returns warnings for the ones it cannot apply.
+func (e *extraParams) apply(args []string) []string {
+	var warnings []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		name, value, hasValue := strings.Cut(arg, "=")
+		if !strings.HasPrefix(name, "-") {
+			warnings = append(warnings, fmt.Sprintf("ExtraParams: unexpected argument %q ignored", arg))
+			continue
+		}
+
+		// Boolean flags never take the next argument but accept an explicit --flag=false
+		if enable := e.boolean(name); enable != nil {
+			on := true
+			if hasValue {
+				var err error
+				if on, err = strconv.ParseBool(value); err != nil {
+					warnings = append(warnings, fmt.Sprintf("ExtraParams: invalid %s %q", name, value))
+					continue
+				}
+			}
+			if on {
+				enable()
+			}
+			continue
+		}
+
+		// An option is never taken as the value of the one before it
+		if !hasValue {
+			if i+1 >= len(args) || strings.HasPrefix(args[i+1], "-") {
+				warnings = append(warnings, fmt.Sprintf("ExtraParams: %s is missing a value", name))
+				continue
+			}
+			i++
+			value = args[i]
+		}
+		if err := e.set(name, value); err != nil {
+			warnings = append(warnings, fmt.Sprintf("ExtraParams: %v", err))
+		}
+	}
+	return warnings
+}
+
+// boolean returns the setter of a boolean flag, or nil when name takes a value.
+func (e *extraParams) boolean(name string) func() {
+	switch name {
+	case "--init":
+		return func() { e.hostConfig["Init"] = true }
+	case "--privileged":
+		return func() { e.hostConfig["Privileged"] = true }
+	case "--read-only":
+		return func() { e.hostConfig["ReadonlyRootfs"] = true }
+	case "--rm":
+		return func() { e.hostConfig["AutoRemove"] = true }
+	case "--oom-kill-disable":
+		return func() { e.hostConfig["OomKillDisable"] = true }
+	case "--publish-all", "-P":
+		return func() { e.hostConfig["PublishAllPorts"] = true }
+	case "--no-healthcheck":
+		return func() { e.config["Healthcheck"] = map[string]interface{}{"Test": []string{"NONE"}} }
+	case "--tty", "-t":
+		return func() { e.config["Tty"] = true }
+	case "--interactive", "-i":
+		return func() { e.config["OpenStdin"] = true }
+	case "-it", "-ti":
+		return func() {
+			e.config["Tty"] = true
+			e.config["OpenStdin"] = true
+		}
+	case "--detach", "-d":
+		// Containers created through the API always run detached
+		return func() {}
+	}
+	return nil
+}
+
+func (e *extraParams) set(name, value string) error {
+	switch name {
+	case "--restart":
+		policy, retries, _ := strings.Cut(value, ":")
+		restart := map[string]interface{}{"Name": policy}
+		if retries != "" {
+			n, err := strconv.Atoi(retries)
+			if err != nil {
+				return fmt.Errorf("invalid --restart %q", value)
+			}
+			restart["MaximumRetryCount"] = n
+		}
+		e.hostConfig["RestartPolicy"] = restart
+	case "--runtime":
+		e.hostConfig["Runtime"] = value
+	case "--hostname", "-h":
+		e.config["Hostname"] = value
+	case "--user", "-u":
+		e.config["User"] = value
+	case "--mac-address":
+		e.config["MacAddress"] = value
+	case "--memory", "-m":
+		bytes, err := parseBytes(value)
+		if err != nil {
+			return fmt.Errorf("invalid --memory %q", value)
+		}
+		e.hostConfig["Memory"] = bytes
+	case "--memory-swap":
+		bytes, err := parseBytes(value)
+		if err != nil && value != "-1" {
+			return fmt.Errorf("invalid --memory-swap %q", value)
+		}
+		if value == "-1" {
+			bytes = -1
+		}
+		e.hostConfig["MemorySwap"] = bytes
+	case "--shm-size":
+		bytes, err := parseBytes(value)
+		if err != nil {
+			return fmt.Errorf("invalid --shm-size %q", value)
+		}
+		e.hostConfig["ShmSize"] = bytes
+	case "--cpus":
+		cpus, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("invalid --cpus %q", value)
+		}
+		e.hostConfig["NanoCpus"] = int64(cpus * 1e9)
+	case "--cpu-shares", "-c":
+		shares, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid --cpu-shares %q", value)
+		}
+		e.hostConfig["CpuShares"] = shares
+	case "--log-opt":
+		key, v, _ := strings.Cut(value, "=")
+		logConfig, _ := e.hostConfig["LogConfig"].(map[string]interface{})
+		if logConfig == nil {
+			logConfig = map[string]interface{}{"Config": map[string]string{}}
+			e.hostConfig["LogConfig"] = logConfig
+		}
+		logConfig["Config"].(map[string]string)[key] = v
+	case "--log-driver":
+		logConfig, _ := e.hostConfig["LogConfig"].(map[string]interface{})
+		if logConfig == nil {
+			logConfig = map[string]interface{}{"Config": map[string]string{}}
+			e.hostConfig["LogConfig"] = logConfig
+		}
+		logConfig["Type"] = value
+	case "--cap-add":
+		e.appendHostList("CapAdd", value)
+	case "--cap-drop":
+		e.appendHostList("CapDrop", value)
+	case "--dns":
+		e.appendHostList("Dns", value)
+	case "--add-host":
+		e.appendHostList("ExtraHosts", value)
+	case "--security-opt":
+		e.appendHostList("SecurityOpt", value)
+	case "--group-add":
+		e.appendHostList("GroupAdd", value)
+	case "--device":
+		parts := strings.SplitN(value, ":", 3)
+		device := map[string]string{"PathOnHost": parts[0], "PathInContainer": parts[0], "CgroupPermissions": "rwm"}
+		if len(parts) > 1 {
+			device["PathInContainer"] = parts[1]
+		}
+		if len(parts) > 2 {
+			device["CgroupPermissions"] = parts[2]
+		}
+		e.devices = append(e.devices, device)
+	case "--env", "-e":
+		e.env = append(e.env, value)
+	case "--label", "-l":
+		key, v, _ := strings.Cut(value, "=")
+		e.labels[key] = v
+	default:
+		return fmt.Errorf("unsupported option %s ignored", name)
+	}
+	return nil
+}
+
+func (e *extraParams) appendHostList(key, value string) {
+	list, _ := e.hostConfig[key].([]string)
+	e.hostConfig[key] = append(list, value)
+}
+
+// parseBytes parses sizes like docker run does: a number with an optional b, k, m or g suffix.
+func parseBytes(value string) (int64, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	multiplier := int64(1)
+	switch {
+	case strings.HasSuffix(value, "g"), strings.HasSuffix(value, "gb"):
+		multiplier = 1 << 30
+	case strings.HasSuffix(value, "m"), strings.HasSuffix(value, "mb"):
+		multiplier = 1 << 20
+	case strings.HasSuffix(value, "k"), strings.HasSuffix(value, "kb"):
+		multiplier = 1 << 10
+	}
+	number := strings.TrimRight(value, "bkmg")
+	n, err := strconv.ParseFloat(number, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", value)
+	}
+	return int64(n * float64(multiplier)), nil
+}
+
+// splitArgs splits a command line into arguments, honouring single and double quotes and
+// backslash escapes like a POSIX shell. It does not expand variables.
+func splitArgs(line string) ([]string, error) {
+	var args []string
+	var current strings.Builder
+	inArg := false
+	var quote rune
+
+	runes := []rune(line)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote == '\'':
+			current.WriteRune(r)
+		case r == '\\' && i+1 < len(runes):
+			i++
+			current.WriteRune(runes[i])
+			inArg = true
+		case quote == '"':
+			current.WriteRune(r)
+		case r == '\'' || r == '"':
+			quote = r
+			inArg = true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quote in %q", line)
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args, nil
+}
@@ -0,0 +1,104 @@
+package dockerman
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Store loads the templates of a directory and re-parses a file only when it changes, so it can
+// be consulted on every collection.
+type Store struct {
+	dir string
+
+	mu      sync.Mutex
+	entries map[string]storeEntry
+}
+
+type storeEntry struct {
+	modTime  time.Time
+	size     int64
+	template *Template
+	err      error
+}
+
+// NewStore creates a store for the templates in dir, usually
+// /boot/config/plugins/dockerMan/templates-user.
+func NewStore(dir string) *Store {
+	return &Store{dir: dir, entries: make(map[string]storeEntry)}
+}
+
+// Dir returns the template directory.
+func (s *Store) Dir() string {
+	return s.dir
+}
+
+// Templates returns all valid templates sorted by name, plus the errors of files that could not
+// be parsed. A missing directory means Docker was never set up and yields no templates. The
+// templates are shared between callers and must not be modified.
+func (s *Store) Templates() ([]*Template, []error) {
+	files, err := os.ReadDir(s.dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, []error{err}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	seen := make(map[string]bool, len(files))
+	var templates []*Template
+	var errs []error
+	for _, file := range files {
+		if file.IsDir() || !strings.EqualFold(filepath.Ext(file.Name()), ".xml") {
+			continue
+		}
+		info, err := file.Info()
+		if err != nil {
+			continue
+		}
+		path := filepath.Join(s.dir, file.Name())
+		seen[path] = true
+
+		entry, ok := s.entries[path]
+		if !ok || !entry.modTime.Equal(info.ModTime()) || entry.size != info.Size() {
+			entry = storeEntry{modTime: info.ModTime(), size: info.Size()}
+			entry.template, entry.err = Load(path)
+			s.entries[path] = entry
+		}
+		if entry.err != nil {
+			errs = append(errs, entry.err)
+			continue
+		}
+		templates = append(templates, entry.template)
+	}
+
+	for path := range s.entries {
+		if !seen[path] {
+			delete(s.entries, path)
+		}
+	}
+
+	sort.Slice(templates, func(i, j int) bool {
+		return strings.ToLower(templates[i].Name) < strings.ToLower(templates[j].Name)
+	})
+	return templates, errs
+}
+
+// Get returns the template with the given container name, or nil.
+func (s *Store) Get(name string) *Template {
+	templates, _ := s.Templates()
+	for _, t := range templates {
+		if t.Name == name {
+			return t
+		}
+	}
+	return nil
+}
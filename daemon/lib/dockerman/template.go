@@ -0,0 +1,251 @@
+// Package dockerman reads the container templates Unraid's Docker manager (dockerMan) keeps on
+// the flash drive. Templates describe how a container is created: its image, network, mounts,
+// ports, variables and the WebUI, icon and support links shown in the Unraid UI.
+package dockerman
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/dto"
+)
+
+// Config types of template entries.
+const (
+	TypePath     = "Path"
+	TypePort     = "Port"
+	TypeVariable = "Variable"
+	TypeDevice   = "Device"
+	TypeLabel    = "Label"
+)
+
+// Labels Unraid puts on containers it creates from a template.
+const (
+	LabelManaged = "net.unraid.docker.managed"
+	LabelWebUI   = "net.unraid.docker.webui"
+	LabelIcon    = "net.unraid.docker.icon"
+)
+
+// Template is a parsed dockerMan XML template.
+type Template struct {
+	Name        string   `xml:"Name"`
+	Repository  string   `xml:"Repository"`
+	Registry    string   `xml:"Registry"`
+	Network     string   `xml:"Network"`
+	MyIP        string   `xml:"MyIP"`
+	Shell       string   `xml:"Shell"`
+	Privileged  string   `xml:"Privileged"`
+	Support     string   `xml:"Support"`
+	Project     string   `xml:"Project"`
+	Overview    string   `xml:"Overview"`
+	Category    string   `xml:"Category"`
+	WebUI       string   `xml:"WebUI"`
+	TemplateURL string   `xml:"TemplateURL"`
+	Icon        string   `xml:"Icon"`
+	ExtraParams string   `xml:"ExtraParams"`
+	PostArgs    string   `xml:"PostArgs"`
+	CPUset      string   `xml:"CPUset"`
+	Configs     []Config `xml:"Config"`
+
+	// Sections of version 1 templates, converted to Configs by Parse
+	Networking struct {
+		Mode    string `xml:"Mode"`
+		Publish struct {
+			Ports []struct {
+				HostPort      string `xml:"HostPort"`
+				ContainerPort string `xml:"ContainerPort"`
+				Protocol      string `xml:"Protocol"`
+			} `xml:"Port"`
+		} `xml:"Publish"`
+	} `xml:"Networking"`
+	Data struct {
+		Volumes []struct {
+			HostDir      string `xml:"HostDir"`
+			ContainerDir string `xml:"ContainerDir"`
+			Mode         string `xml:"Mode"`
+		} `xml:"Volume"`
+	} `xml:"Data"`
+	Environment struct {
+		Variables []struct {
+			Name  string `xml:"Name"`
+			Value string `xml:"Value"`
+		} `xml:"Variable"`
+	} `xml:"Environment"`
+
+	// Path is the file the template was loaded from
+	Path string `xml:"-"`
+}
+
+// Config is a path, port, variable, device or label entry of a template. Value holds what the
+// user configured; Default is the template author's suggestion.
+type Config struct {
+	Name        string `xml:"Name,attr"`
+	Target      string `xml:"Target,attr"`
+	Default     string `xml:"Default,attr"`
+	Mode        string `xml:"Mode,attr"`
+	Description string `xml:"Description,attr"`
+	Type        string `xml:"Type,attr"`
+	Display     string `xml:"Display,attr"`
+	Required    string `xml:"Required,attr"`
+	Mask        string `xml:"Mask,attr"`
+	Value       string `xml:",chardata"`
+}
+
+// Masked reports whether the value is a secret such as a password or API key.
+func (c Config) Masked() bool {
+	return strings.EqualFold(c.Mask, "true")
+}
+
+// Parse decodes a template. Version 1 templates (Networking, Data and Environment sections) are
+// converted to the Config entries of version 2.
+func Parse(data []byte) (*Template, error) {
+	var t Template
+	if err := xml.Unmarshal(data, &t); err != nil {
+		return nil, fmt.Errorf("invalid template: %w", err)
+	}
+	t.Name = strings.TrimSpace(t.Name)
+	t.Repository = strings.TrimSpace(t.Repository)
+	if t.Name == "" || t.Repository == "" {
+		return nil, fmt.Errorf("invalid template: missing Name or Repository")
+	}
+	for i := range t.Configs {
+		t.Configs[i].Value = strings.TrimSpace(t.Configs[i].Value)
+	}
+
+	if len(t.Configs) == 0 {
+		t.convertVersion1()
+	}
+	if t.Network == "" {
+		t.Network = t.Networking.Mode
+	}
+	if t.Network == "" {
+		t.Network = "bridge"
+	}
+	return &t, nil
+}
+
+func (t *Template) convertVersion1() {
+	for _, p := range t.Networking.Publish.Ports {
+		t.Configs = append(t.Configs, Config{
+			Name: "Port " + p.ContainerPort, Type: TypePort,
+			Target: p.ContainerPort, Mode: p.Protocol, Value: p.HostPort,
+		})
+	}
+	for _, v := range t.Data.Volumes {
+		t.Configs = append(t.Configs, Config{
+			Name: "Path " + v.ContainerDir, Type: TypePath,
+			Target: v.ContainerDir, Mode: v.Mode, Value: v.HostDir,
+		})
+	}
+	for _, v := range t.Environment.Variables {
+		t.Configs = append(t.Configs, Config{
+			Name: v.Name, Type: TypeVariable, Target: v.Name, Value: v.Value,
+		})
+	}
+}
+
+// Load reads and parses a template file.
+func Load(path string) (*Template, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	t, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	t.Path = path
+	return t, nil
+}
+
+// Categories splits the space separated Category field, e.g. "MediaServer:Video Status:Stable".
+func (t *Template) Categories() []string {
+	return strings.Fields(t.Category)
+}
+
+// IsPrivileged reports whether the container runs privileged.
+func (t *Template) IsPrivileged() bool {
+	privileged, _ := strconv.ParseBool(strings.TrimSpace(t.Privileged))
+	return privileged
+}
+
+// Info converts the template for the API. Masked values are left out.
+func (t *Template) Info() dto.ContainerTemplate {
+	info := dto.ContainerTemplate{
+		Name:        t.Name,
+		Path:        t.Path,
+		Repository:  t.Repository,
+		Registry:    strings.TrimSpace(t.Registry),
+		Network:     t.Network,
+		WebUI:       strings.TrimSpace(t.WebUI),
+		Icon:        strings.TrimSpace(t.Icon),
+		Categories:  t.Categories(),
+		Overview:    strings.TrimSpace(t.Overview),
+		Support:     strings.TrimSpace(t.Support),
+		Project:     strings.TrimSpace(t.Project),
+		TemplateURL: strings.TrimSpace(t.TemplateURL),
+		Privileged:  t.IsPrivileged(),
+		ExtraParams: strings.TrimSpace(t.ExtraParams),
+		Variables:   make([]dto.TemplateVariable, 0, len(t.Configs)),
+	}
+	if info.Categories == nil {
+		info.Categories = []string{}
+	}
+	for _, c := range t.Configs {
+		v := dto.TemplateVariable{
+			Name:        c.Name,
+			Type:        c.Type,
+			Target:      c.Target,
+			Value:       c.Value,
+			Default:     c.Default,
+			Mode:        c.Mode,
+			Description: c.Description,
+			Display:     c.Display,
+			Required:    strings.EqualFold(c.Required, "true"),
+			Masked:      c.Masked(),
+		}
+		if v.Masked {
+			v.Value, v.Default = "", ""
+		}
+		info.Variables = append(info.Variables, v)
+	}
+	return info
+}
+
+// webUIPortPattern matches the [PORT:1234] placeholder.
+var webUIPortPattern = regexp.MustCompile(`(?i)\[PORT:(\d+)\]`)
+
+// ResolveWebUI fills in the [IP] and [PORT:n] placeholders of a WebUI template the way Unraid
+// does. Containers on the host or bridge network are reached on the server address (hostIP) and,
+// for bridge, on the host port published for container port n. Containers on other networks
+// (such as br0) have their own address and are reached directly on port n. It returns "" when
+// the address is unknown.
+func ResolveWebUI(webUI, network, hostIP, containerIP string, publishedPorts map[int]int) string {
+	webUI = strings.TrimSpace(webUI)
+	if webUI == "" {
+		return ""
+	}
+
+	ip := containerIP
+	if network == "host" || network == "bridge" || ip == "" {
+		ip = hostIP
+	}
+	if ip == "" && strings.Contains(strings.ToUpper(webUI), "[IP]") {
+		return ""
+	}
+
+	resolved := strings.NewReplacer("[IP]", ip, "[ip]", ip).Replace(webUI)
+	return webUIPortPattern.ReplaceAllStringFunc(resolved, func(match string) string {
+		port, _ := strconv.Atoi(webUIPortPattern.FindStringSubmatch(match)[1])
+		if network == "bridge" {
+			if published, ok := publishedPorts[port]; ok && published > 0 {
+				port = published
+			}
+		}
+		return strconv.Itoa(port)
+	})
+}
@@ -0,0 +1,376 @@
+package dockerman
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadVersion2(t *testing.T) {
+	tmpl, err := Load("testdata/my-plex.xml")
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	if tmpl.Name != "plex" || tmpl.Repository != "plexinc/pms-docker:latest" || tmpl.Network != "bridge" {
+		t.Errorf("unexpected template: %+v", tmpl)
+	}
+	if tmpl.Path != "testdata/my-plex.xml" {
+		t.Errorf("Path = %q", tmpl.Path)
+	}
+	if want := []string{"MediaServer:Video", "MediaServer:Music", "Status:Stable"}; !reflect.DeepEqual(tmpl.Categories(), want) {
+		t.Errorf("Categories() = %v, want %v", tmpl.Categories(), want)
+	}
+	if len(tmpl.Configs) != 8 {
+		t.Fatalf("got %d configs, want 8", len(tmpl.Configs))
+	}
+	claim := tmpl.Configs[4]
+	if claim.Target != "PLEX_CLAIM" || claim.Value != "claim-s3cr3t" || !claim.Masked() {
+		t.Errorf("unexpected claim token config: %+v", claim)
+	}
+	if tmpl.Configs[3].Value != "" {
+		t.Errorf("self-closing config has value %q", tmpl.Configs[3].Value)
+	}
+}
+
+func TestLoadVersion1(t *testing.T) {
+	tmpl, err := Load("testdata/my-pihole.xml")
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	if tmpl.Network != "br0" || tmpl.MyIP != "192.168.1.53" {
+		t.Errorf("network = %q, ip = %q", tmpl.Network, tmpl.MyIP)
+	}
+	want := []Config{
+		{Name: "Port 53", Type: TypePort, Target: "53", Mode: "udp", Value: "53"},
+		{Name: "Path /etc/pihole", Type: TypePath, Target: "/etc/pihole", Mode: "rw", Value: "/mnt/user/appdata/pihole"},
+		{Name: "TZ", Type: TypeVariable, Target: "TZ", Value: "Europe/Amsterdam"},
+	}
+	if !reflect.DeepEqual(tmpl.Configs, want) {
+		t.Errorf("Configs = %+v, want %+v", tmpl.Configs, want)
+	}
+}
+
+func TestInfoMasksSecrets(t *testing.T) {
+	tmpl, err := Load("testdata/my-plex.xml")
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	info := tmpl.Info()
+	if info.Support != "https://forums.plex.tv" || info.Project != "https://www.plex.tv/" || len(info.Categories) != 3 {
+		t.Errorf("unexpected template info: %+v", info)
+	}
+	if len(info.Variables) != len(tmpl.Configs) {
+		t.Fatalf("got %d variables, want %d", len(info.Variables), len(tmpl.Configs))
+	}
+	claim := info.Variables[4]
+	if !claim.Masked || claim.Value != "" || claim.Default != "" {
+		t.Errorf("masked variable exposed: %+v", claim)
+	}
+	if port := info.Variables[0]; port.Value != "32401" || !port.Required {
+		t.Errorf("unexpected port variable: %+v", port)
+	}
+	// The template itself keeps the secret for creating the container
+	if tmpl.Configs[4].Value != "claim-s3cr3t" {
+		t.Error("Info() modified the template")
+	}
+}
+
+func TestParseRejectsIncompleteTemplates(t *testing.T) {
+	for _, data := range []string{
+		`<Container version="2"><Name>plex</Name></Container>`,
+		`<Container version="2"><Repository>plexinc/pms-docker</Repository></Container>`,
+		`<Container version="2"><Name>plex`,
+	} {
+		if _, err := Parse([]byte(data)); err == nil {
+			t.Errorf("Parse(%q) succeeded, want an error", data)
+		}
+	}
+}
+
+func TestResolveWebUI(t *testing.T) {
+	published := map[int]int{32400: 32401}
+	tests := []struct {
+		name        string
+		webUI       string
+		network     string
+		containerIP string
+		want        string
+	}{
+		{"bridge uses published port", "http://[IP]:[PORT:32400]/web", "bridge", "172.17.0.2", "http://192.168.1.10:32401/web"},
+		{"bridge without published port", "http://[IP]:[PORT:8080]/", "bridge", "172.17.0.2", "http://192.168.1.10:8080/"},
+		{"host network", "http://[IP]:[PORT:32400]/web", "host", "", "http://192.168.1.10:32400/web"},
+		{"custom network uses container address", "http://[IP]:[PORT:32400]/web", "br0", "192.168.1.53", "http://192.168.1.53:32400/web"},
+		{"lowercase placeholders", "http://[ip]:[port:80]/admin", "br0", "192.168.1.53", "http://192.168.1.53:80/admin"},
+		{"fixed url", "https://plex.example.com", "bridge", "", "https://plex.example.com"},
+		{"no web ui", "", "bridge", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ResolveWebUI(tt.webUI, tt.network, "192.168.1.10", tt.containerIP, published); got != tt.want {
+				t.Errorf("ResolveWebUI() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if got := ResolveWebUI("http://[IP]:[PORT:80]/", "bridge", "", "", nil); got != "" {
+		t.Errorf("ResolveWebUI() without host address = %q, want empty", got)
+	}
+}
+
+func TestCreateConfig(t *testing.T) {
+	tmpl, err := Load("testdata/my-plex.xml")
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	body, warnings := tmpl.CreateConfig()
+
+	// Round-trip through JSON to compare what the Engine receives
+	data, _ := json.Marshal(body)
+	var config struct {
+		Image        string
+		Env          []string
+		Labels       map[string]string
+		ExposedPorts map[string]struct{}
+		HostConfig   struct {
+			Binds         []string
+			NetworkMode   string
+			CpusetCpus    string
+			NanoCpus      int64
+			Runtime       string
+			RestartPolicy struct{ Name string }
+			PortBindings  map[string][]struct{ HostPort string }
+			Devices       []struct{ PathOnHost, PathInContainer string }
+			LogConfig     struct{ Config map[string]string }
+		}
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		t.Fatalf("invalid create body: %v", err)
+	}
+
+	if config.Image != "plexinc/pms-docker:latest" || config.HostConfig.NetworkMode != "bridge" {
+		t.Errorf("unexpected create body: %s", data)
+	}
+	wantEnv := []string{"HOST_OS=Unraid", "HOST_CONTAINERNAME=plex", "PLEX_CLAIM=claim-s3cr3t", "VERSION=docker"}
+	if !reflect.DeepEqual(config.Env, wantEnv) {
+		t.Errorf("Env = %v, want %v", config.Env, wantEnv)
+	}
+	wantBinds := []string{"/mnt/cache/appdata/plex:/config:rw", "/mnt/user/media:/data:ro"}
+	if !reflect.DeepEqual(config.HostConfig.Binds, wantBinds) {
+		t.Errorf("Binds = %v, want %v", config.HostConfig.Binds, wantBinds)
+	}
+	if got := config.HostConfig.PortBindings["32400/tcp"]; len(got) != 1 || got[0].HostPort != "32401" {
+		t.Errorf("PortBindings = %v", config.HostConfig.PortBindings)
+	}
+	if _, ok := config.ExposedPorts["32400/tcp"]; !ok {
+		t.Errorf("ExposedPorts = %v", config.ExposedPorts)
+	}
+	if config.Labels[LabelManaged] != "dockerman" || config.Labels[LabelWebUI] != tmpl.WebUI ||
+		config.Labels[LabelIcon] != tmpl.Icon || config.Labels["traefik.enable"] != "true" {
+		t.Errorf("Labels = %v", config.Labels)
+	}
+	if len(config.HostConfig.Devices) != 2 || config.HostConfig.Devices[0].PathInContainer != "/dev/dri/renderD128" ||
+		config.HostConfig.Devices[1].PathOnHost != "/dev/dri" {
+		t.Errorf("Devices = %+v", config.HostConfig.Devices)
+	}
+
+	// ExtraParams
+	if config.HostConfig.RestartPolicy.Name != "unless-stopped" || config.HostConfig.Runtime != "nvidia" {
+		t.Errorf("restart = %q, runtime = %q", config.HostConfig.RestartPolicy.Name, config.HostConfig.Runtime)
+	}
+	if config.HostConfig.NanoCpus != 2e9 || config.HostConfig.CpusetCpus != "2,3" {
+		t.Errorf("NanoCpus = %d, CpusetCpus = %q", config.HostConfig.NanoCpus, config.HostConfig.CpusetCpus)
+	}
+	if config.HostConfig.LogConfig.Config["max-size"] != "50m" {
+		t.Errorf("LogConfig = %v", config.HostConfig.LogConfig)
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "--sysctl") {
+		t.Errorf("warnings = %v, want one for --sysctl", warnings)
+	}
+}
+
+func TestExtraParamsBooleanFlags(t *testing.T) {
+	tmpl := &Template{Name: "app", Repository: "example/app"}
+	tmpl.ExtraParams = "--no-healthcheck --restart unless-stopped --read-only --privileged=true --rm=false --memory-swap -1 -it"
+	body, warnings := tmpl.CreateConfig()
+
+	data, _ := json.Marshal(body)
+	var config struct {
+		Tty         bool
+		OpenStdin   bool
+		Healthcheck struct{ Test []string }
+		HostConfig  struct {
+			RestartPolicy  struct{ Name string }
+			ReadonlyRootfs bool
+			Privileged     bool
+			AutoRemove     bool
+			MemorySwap     int64
+		}
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		t.Fatalf("invalid create body: %v", err)
+	}
+
+	if config.HostConfig.RestartPolicy.Name != "unless-stopped" {
+		t.Errorf("RestartPolicy = %q, want unless-stopped", config.HostConfig.RestartPolicy.Name)
+	}
+	if !config.HostConfig.ReadonlyRootfs || !config.HostConfig.Privileged || config.HostConfig.AutoRemove {
+		t.Errorf("unexpected boolean flags: %s", data)
+	}
+	if !reflect.DeepEqual(config.Healthcheck.Test, []string{"NONE"}) || !config.Tty || !config.OpenStdin {
+		t.Errorf("unexpected config: %s", data)
+	}
+	// "-1" looks like an option, so --memory-swap needs the --memory-swap=-1 form
+	if config.HostConfig.MemorySwap != 0 || len(warnings) != 2 ||
+		!strings.Contains(warnings[0], "--memory-swap is missing a value") || !strings.Contains(warnings[1], "-1") {
+		t.Errorf("MemorySwap = %d, warnings = %v", config.HostConfig.MemorySwap, warnings)
+	}
+}
+
+func TestCreateConfigCustomNetwork(t *testing.T) {
+	tmpl, err := Load("testdata/my-pihole.xml")
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	body, warnings := tmpl.CreateConfig()
+	if len(warnings) != 0 {
+		t.Errorf("unexpected warnings: %v", warnings)
+	}
+	data, _ := json.Marshal(body)
+	var config struct {
+		ExposedPorts     map[string]struct{}
+		NetworkingConfig struct {
+			EndpointsConfig map[string]struct {
+				IPAMConfig struct{ IPv4Address string }
+			}
+		}
+		HostConfig struct {
+			PortBindings  map[string]interface{}
+			RestartPolicy struct{ Name string }
+		}
+	}
+	_ = json.Unmarshal(data, &config)
+
+	// Containers on br0 have their own address, so nothing is published
+	if len(config.ExposedPorts) != 0 || len(config.HostConfig.PortBindings) != 0 {
+		t.Errorf("ports published on a custom network: %s", data)
+	}
+	if got := config.NetworkingConfig.EndpointsConfig["br0"].IPAMConfig.IPv4Address; got != "192.168.1.53" {
+		t.Errorf("IPv4Address = %q, want 192.168.1.53", got)
+	}
+	if config.HostConfig.RestartPolicy.Name != "no" {
+		t.Errorf("RestartPolicy = %q, want no", config.HostConfig.RestartPolicy.Name)
+	}
+}
+
+func TestSplitArgs(t *testing.T) {
+	tests := []struct {
+		line string
+		want []string
+	}{
+		{`--cpus 2 --restart=always`, []string{"--cpus", "2", "--restart=always"}},
+		{`-e "TZ=Europe/Amsterdam" -l 'app=my plex'`, []string{"-e", "TZ=Europe/Amsterdam", "-l", "app=my plex"}},
+		{`--hostname=my\ plex  ""`, []string{"--hostname=my plex", ""}},
+		{`  `, nil},
+	}
+	for _, tt := range tests {
+		got, err := splitArgs(tt.line)
+		if err != nil {
+			t.Errorf("splitArgs(%q) error = %v", tt.line, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitArgs(%q) = %q, want %q", tt.line, got, tt.want)
+		}
+	}
+
+	if _, err := splitArgs(`-e "TZ=UTC`); err == nil {
+		t.Error("splitArgs() with an unterminated quote succeeded")
+	}
+}
+
+func TestStore(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"my-plex.xml", "my-pihole.xml", "my-broken.xml", "readme.txt"} {
+		data, err := os.ReadFile(filepath.Join("testdata", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	store := NewStore(dir)
+
+	templates, errs := store.Templates()
+	if len(templates) != 2 || templates[0].Name != "pihole" || templates[1].Name != "plex" {
+		t.Fatalf("Templates() = %v, want pihole and plex", templates)
+	}
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "my-broken.xml") {
+		t.Errorf("errors = %v, want one for my-broken.xml", errs)
+	}
+
+	// Unchanged files are served from the cache
+	again, _ := store.Templates()
+	if again[1] != templates[1] {
+		t.Error("unchanged template was parsed again")
+	}
+
+	// A changed file is reloaded and a removed one dropped
+	path := filepath.Join(dir, "my-plex.xml")
+	data, _ := os.ReadFile(path)
+	data = []byte(strings.Replace(string(data), "plexinc/pms-docker:latest", "plexinc/pms-docker:beta", 1))
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Minute)
+	_ = os.Chtimes(path, later, later)
+	_ = os.Remove(filepath.Join(dir, "my-pihole.xml"))
+
+	templates, _ = store.Templates()
+	if len(templates) != 1 || templates[0].Repository != "plexinc/pms-docker:beta" {
+		t.Errorf("Templates() after changes = %+v", templates)
+	}
+	if store.Get("plex") == nil || store.Get("pihole") != nil {
+		t.Error("Get() does not reflect the directory")
+	}
+
+	if templates, errs := NewStore(filepath.Join(dir, "missing")).Templates(); templates != nil || errs != nil {
+		t.Errorf("missing directory returned %v, %v", templates, errs)
+	}
+}
+
+func TestReadAutostart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "unraid-autostart")
+	if err := os.WriteFile(path, []byte("mariadb 30\nnextcloud\n\nplex 5\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := ReadAutostart(path)
+	if err != nil {
+		t.Fatalf("ReadAutostart() error = %v", err)
+	}
+	want := []Autostart{{"mariadb", 30 * time.Second}, {"nextcloud", 0}, {"plex", 5 * time.Second}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadAutostart() = %v, want %v", got, want)
+	}
+
+	if list, err := ReadAutostart(filepath.Join(t.TempDir(), "missing")); list != nil || err != nil {
+		t.Errorf("missing file returned %v, %v", list, err)
+	}
+
+	if err := os.WriteFile(path, []byte("plex soon\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadAutostart(path); err == nil {
+		t.Error("ReadAutostart() accepted an invalid wait")
+	}
+}
@@ -0,0 +1,56 @@
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ReadJSONFile decodes the JSON file at path into v. A missing or empty file, or an empty path
+// for state kept in memory only, leaves v untouched; found reports whether there was anything to
+// decode.
+func ReadJSONFile(path string, v interface{}) (found bool, err error) {
+	if path == "" {
+		return false, nil
+	}
+
+	// #nosec G304 - callers pass the agent's own state files, whose paths come from its flags
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if len(strings.TrimSpace(string(data))) == 0 {
+		return false, nil
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return false, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return true, nil
+}
+
+// WriteJSONFile writes v as indented JSON to path, creating its directory. The file is only
+// readable by its owner, since the agent's state files may hold secrets. An empty path writes
+// nothing.
+func WriteJSONFile(path string, v interface{}) error {
+	if path == "" {
+		return nil
+	}
+
+	// #nosec G301 - Unraid standard permissions (0755 for directories)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", path, err)
+	}
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %w", path, err)
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
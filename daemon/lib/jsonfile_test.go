@@ -0,0 +1,47 @@
+package lib
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestJSONFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "targets.json")
+
+	// A missing file leaves the value untouched
+	values := map[string]int{"kept": 1}
+	if found, err := ReadJSONFile(path, &values); err != nil || found || values["kept"] != 1 {
+		t.Fatalf("ReadJSONFile() of missing file = %v, %v, %v", found, err, values)
+	}
+
+	if err := WriteJSONFile(path, map[string]int{"a": 1, "b": 2}); err != nil {
+		t.Fatalf("WriteJSONFile() error = %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("file permissions = %o, want 600", perm)
+	}
+	var read map[string]int
+	if found, err := ReadJSONFile(path, &read); err != nil || !found || !reflect.DeepEqual(read, map[string]int{"a": 1, "b": 2}) {
+		t.Errorf("ReadJSONFile() = %v, %v, %v", found, err, read)
+	}
+
+	// Empty files count as missing; invalid ones are an error
+	if err := os.WriteFile(path, []byte(" \n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if found, err := ReadJSONFile(path, &read); err != nil || found {
+		t.Errorf("ReadJSONFile() of empty file = %v, %v", found, err)
+	}
+	if err := os.WriteFile(path, []byte("{"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadJSONFile(path, &read); err == nil {
+		t.Error("ReadJSONFile() of invalid file succeeded")
+	}
+}
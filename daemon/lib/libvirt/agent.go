@@ -0,0 +1,133 @@
+package libvirt
+
+import (
+	"context"
+
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/lib/libvirt/internal/remote"
+)
+
+// Information groups for GetGuestInfo.
+const (
+	GuestInfoUsers      = 1 << 0
+	GuestInfoOS         = 1 << 1
+	GuestInfoTimezone   = 1 << 2
+	GuestInfoHostname   = 1 << 3
+	GuestInfoFilesystem = 1 << 4
+)
+
+// Flag values of the calls below.
+const (
+	shutdownGuestAgent      = 1 << 1
+	interfaceAddressesAgent = 1
+	setTimeSync             = 1 << 0
+)
+
+// Address types of an IPAddress.
+const (
+	AddressIPv4 = 0
+	AddressIPv6 = 1
+)
+
+// Interface is a network interface as the guest sees it.
+type Interface struct {
+	Name   string
+	HWAddr string
+	Addrs  []IPAddress
+}
+
+// IPAddress is an address of a guest interface with its prefix length.
+type IPAddress struct {
+	Type   int
+	Addr   string
+	Prefix uint32
+}
+
+// GetGuestInfo asks the QEMU guest agent of a running domain for the information groups in
+// types, such as "os.pretty-name", "hostname" or "fs.0.used-bytes". Groups the agent does not
+// support are left out.
+func (c *Client) GetGuestInfo(ctx context.Context, dom Domain, types uint32) (Params, error) {
+	var e remote.Encoder
+	dom.encode(&e)
+	e.Uint32(types)
+	e.Uint32(0)
+	payload, err := c.call(ctx, remote.ProcDomainGetGuestInfo, e.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	d := remote.NewDecoder(payload)
+	params := decodeParams(d)
+	return params, d.Err()
+}
+
+// InterfaceAddresses returns the network interfaces and addresses the guest agent reports, which
+// include addresses assigned by DHCP on bridges libvirt does not manage.
+func (c *Client) InterfaceAddresses(ctx context.Context, dom Domain) ([]Interface, error) {
+	var e remote.Encoder
+	dom.encode(&e)
+	e.Uint32(interfaceAddressesAgent)
+	e.Uint32(0)
+	payload, err := c.call(ctx, remote.ProcDomainInterfaceAddresses, e.Bytes())
+	if err != nil {
+		return nil, err
+	}
+
+	d := remote.NewDecoder(payload)
+	n := d.Uint32()
+	ifaces := make([]Interface, 0, min(n, 1024))
+	for i := uint32(0); i < n && d.Err() == nil; i++ {
+		iface := Interface{Name: d.String(), HWAddr: d.OptString()}
+		addrs := d.Uint32()
+		for j := uint32(0); j < addrs && d.Err() == nil; j++ {
+			iface.Addrs = append(iface.Addrs, IPAddress{Type: int(d.Int32()), Addr: d.String(), Prefix: d.Uint32()})
+		}
+		ifaces = append(ifaces, iface)
+	}
+	return ifaces, d.Err()
+}
+
+// ShutdownGuest asks the guest agent, rather than ACPI, to shut the guest down. Guests that
+// ignore the ACPI power button, such as Windows on the lock screen, still shut down this way.
+func (c *Client) ShutdownGuest(ctx context.Context, dom Domain) error {
+	var e remote.Encoder
+	dom.encode(&e)
+	e.Uint32(shutdownGuestAgent)
+	_, err := c.call(ctx, remote.ProcDomainShutdownFlags, e.Bytes())
+	return err
+}
+
+// FSFreeze flushes and freezes all mounted guest filesystems and returns how many were frozen.
+// They stay frozen until FSThaw is called.
+func (c *Client) FSFreeze(ctx context.Context, dom Domain) (int, error) {
+	return c.fsCall(ctx, remote.ProcDomainFSFreeze, dom)
+}
+
+// FSThaw thaws the guest filesystems frozen by FSFreeze and returns how many were thawed.
+func (c *Client) FSThaw(ctx context.Context, dom Domain) (int, error) {
+	return c.fsCall(ctx, remote.ProcDomainFSThaw, dom)
+}
+
+func (c *Client) fsCall(ctx context.Context, proc int32, dom Domain) (int, error) {
+	var e remote.Encoder
+	dom.encode(&e)
+	e.Uint32(0) // no mountpoints: all filesystems
+	e.Uint32(0)
+	payload, err := c.call(ctx, proc, e.Bytes())
+	if err != nil {
+		return 0, err
+	}
+	d := remote.NewDecoder(payload)
+	n := d.Int32()
+	return int(n), d.Err()
+}
+
+// SyncTime makes the guest agent set the guest clock from the hardware clock, which is needed
+// after a VM was paused or restored from a snapshot for a while.
+func (c *Client) SyncTime(ctx context.Context, dom Domain) error {
+	var e remote.Encoder
+	dom.encode(&e)
+	e.Uint64(0) // seconds and nanoseconds are ignored with the sync flag
+	e.Uint32(0)
+	e.Uint32(setTimeSync)
+	_, err := c.call(ctx, remote.ProcDomainSetTime, e.Bytes())
+	return err
+}
@@ -0,0 +1,336 @@
+// Package libvirt is a minimal client for the libvirt remote protocol served on the libvirtd Unix
+// socket. It covers the calls the agent needs without linking libvirt or running virsh for every
+// domain.
+package libvirt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/lib/libvirt/internal/remote"
+)
+
+// requestTimeout bounds calls whose context has no deadline.
+const requestTimeout = 30 * time.Second
+
+// connectURI is the hypervisor connection opened on the socket.
+const connectURI = "qemu:///system"
+
+// Error codes reported by libvirt, from virerror.h.
+const (
+	ErrCodeNoDomain             = 42
+	ErrCodeOperationInvalid     = 55
+	ErrCodeConfigUnsupported    = 67
+	ErrCodeNoDomainSnapshot     = 72
+	ErrCodeArgumentUnsupported  = 74
+	ErrCodeOperationUnsupported = 84
+	ErrCodeAgentUnresponsive    = 86
+)
+
+// Error is returned when libvirtd answers a call with an error.
+type Error struct {
+	Code    int
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("libvirt error (%d): %s", e.Code, e.Message)
+}
+
+// IsNotFound reports whether err means the domain or snapshot does not exist.
+func IsNotFound(err error) bool {
+	var apiErr *Error
+	return errors.As(err, &apiErr) && (apiErr.Code == ErrCodeNoDomain || apiErr.Code == ErrCodeNoDomainSnapshot)
+}
+
+// IsConflict reports whether libvirt refused the call because of the state or configuration of
+// the domain, such as pausing a VM that is not running or reverting to an external snapshot.
+func IsConflict(err error) bool {
+	var apiErr *Error
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	switch apiErr.Code {
+	case ErrCodeOperationInvalid, ErrCodeConfigUnsupported, ErrCodeArgumentUnsupported, ErrCodeOperationUnsupported:
+		return true
+	}
+	return false
+}
+
+// IsAgentUnavailable reports whether err means the domain has no QEMU guest agent configured or
+// the agent does not answer.
+func IsAgentUnavailable(err error) bool {
+	var apiErr *Error
+	return errors.As(err, &apiErr) && (apiErr.Code == ErrCodeArgumentUnsupported || apiErr.Code == ErrCodeAgentUnresponsive)
+}
+
+// IsAPIError reports whether err came from libvirtd rather than from reaching it. Callers use
+// this to decide whether falling back to virsh makes sense.
+func IsAPIError(err error) bool {
+	var apiErr *Error
+	return errors.As(err, &apiErr)
+}
+
+// IsUnreachable reports whether err means libvirtd could not be reached at all, because the
+// socket is missing or nothing accepts connections on it. Only then is falling back to virsh
+// worth trying; a call that timed out may still be running in libvirtd.
+func IsUnreachable(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// errClosed fails the calls still waiting when the client is closed.
+var errClosed = errors.New("libvirt connection closed")
+
+// Client talks to libvirtd over its Unix socket. Calls share one connection, which is opened on
+// first use and reopened after it fails. Replies are matched to calls by serial, so a long call
+// such as a snapshot or managed save does not hold up the others.
+type Client struct {
+	socket string
+
+	// connecting has room for one holder, who may use sess. Unlike a mutex, waiting for it
+	// respects the context of the call.
+	connecting chan struct{}
+	sess       *session
+}
+
+// NewClient creates a client for the libvirtd listening on socket.
+func NewClient(socket string) *Client {
+	return &Client{socket: socket, connecting: make(chan struct{}, 1)}
+}
+
+// Socket returns the socket path of the client.
+func (c *Client) Socket() string {
+	return c.socket
+}
+
+// Close closes the connection, if one is open. Calls still waiting for a reply fail.
+func (c *Client) Close() error {
+	c.connecting <- struct{}{}
+	s := c.sess
+	c.sess = nil
+	<-c.connecting
+
+	if s == nil {
+		return nil
+	}
+	_, _ = s.call(context.Background(), remote.ProcConnectClose, nil)
+	s.fail(errClosed)
+	return nil
+}
+
+// call sends one call and returns the reply payload, connecting first when needed.
+func (c *Client) call(ctx context.Context, proc int32, args []byte) ([]byte, error) {
+	s, err := c.session(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.call(ctx, proc, args)
+}
+
+// session returns the open connection, replacing it when there is none or it has failed.
+func (c *Client) session(ctx context.Context) (*session, error) {
+	select {
+	case c.connecting <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	defer func() { <-c.connecting }()
+
+	if c.sess != nil && !c.sess.failed() {
+		return c.sess, nil
+	}
+	s, err := c.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c.sess = s
+	return s, nil
+}
+
+// connect dials the socket and opens the hypervisor connection.
+func (c *Client) connect(ctx context.Context) (*session, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "unix", c.socket)
+	if err != nil {
+		return nil, err
+	}
+	s := newSession(conn)
+
+	var e remote.Encoder
+	e.OptString(connectURI)
+	e.Uint32(0)
+	if _, err := s.call(ctx, remote.ProcConnectOpen, e.Bytes()); err != nil {
+		s.fail(errClosed)
+		return nil, fmt.Errorf("failed to open %s: %w", connectURI, err)
+	}
+	return s, nil
+}
+
+// session is one connection to libvirtd. Calls are written one at a time, and a reader goroutine
+// hands each reply to the call with the same serial. Events libvirtd pushes in between are
+// skipped.
+type session struct {
+	conn net.Conn
+
+	// writing has room for one holder, who may write to conn.
+	writing chan struct{}
+
+	mu      sync.Mutex
+	serial  uint32
+	pending map[uint32]chan reply
+	err     error
+	done    chan struct{} // closed once err is set
+}
+
+type reply struct {
+	header  remote.Header
+	payload []byte
+}
+
+func newSession(conn net.Conn) *session {
+	s := &session{
+		conn:    conn,
+		writing: make(chan struct{}, 1),
+		pending: make(map[uint32]chan reply),
+		done:    make(chan struct{}),
+	}
+	go s.read()
+	return s
+}
+
+// call writes a call and waits for its reply. A call that runs out of time gives up waiting but
+// leaves the connection open; its reply is dropped when it arrives.
+func (s *session) call(ctx context.Context, proc int32, args []byte) ([]byte, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, requestTimeout)
+		defer cancel()
+	}
+
+	ch := make(chan reply, 1)
+	s.mu.Lock()
+	if s.err != nil {
+		err := s.err
+		s.mu.Unlock()
+		return nil, err
+	}
+	s.serial++
+	serial := s.serial
+	s.pending[serial] = ch
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.pending, serial)
+		s.mu.Unlock()
+	}()
+
+	if err := s.write(ctx, proc, serial, args); err != nil {
+		return nil, err
+	}
+
+	select {
+	case r := <-ch:
+		if r.header.Status != remote.StatusOK {
+			return nil, decodeError(r.payload)
+		}
+		return r.payload, nil
+	case <-s.done:
+		return nil, s.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// write sends one call. A write cut off part way leaves the stream unusable, so a failed write
+// fails the session.
+func (s *session) write(ctx context.Context, proc int32, serial uint32, args []byte) error {
+	select {
+	case s.writing <- struct{}{}:
+	case <-s.done:
+		return s.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	defer func() { <-s.writing }()
+
+	deadline, _ := ctx.Deadline()
+	_ = s.conn.SetWriteDeadline(deadline)
+	stop := context.AfterFunc(ctx, func() { _ = s.conn.SetWriteDeadline(time.Now()) })
+	defer stop()
+
+	header := remote.Header{
+		Program:   remote.Program,
+		Version:   remote.ProtocolVersion,
+		Procedure: proc,
+		Type:      remote.TypeCall,
+		Serial:    serial,
+	}
+	if err := remote.WriteMessage(s.conn, header, args); err != nil {
+		s.fail(err)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return err
+	}
+	return nil
+}
+
+// read delivers replies until the connection fails.
+func (s *session) read() {
+	for {
+		h, payload, err := remote.ReadMessage(s.conn)
+		if err != nil {
+			s.fail(err)
+			return
+		}
+		if h.Type != remote.TypeReply {
+			continue
+		}
+		s.mu.Lock()
+		ch, ok := s.pending[h.Serial]
+		delete(s.pending, h.Serial)
+		s.mu.Unlock()
+		if ok {
+			ch <- reply{header: h, payload: payload}
+		}
+	}
+}
+
+// fail closes the connection and makes waiting and later calls return err. Only the first error
+// is kept.
+func (s *session) fail(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return
+	}
+	s.err = err
+	close(s.done)
+	_ = s.conn.Close()
+}
+
+func (s *session) failed() bool {
+	select {
+	case <-s.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// decodeError reads the code and message of a remote_error; the remaining fields are not used.
+func decodeError(payload []byte) error {
+	d := remote.NewDecoder(payload)
+	code := d.Int32()
+	_ = d.Int32() // domain
+	message := d.OptString()
+	if err := d.Err(); err != nil {
+		return &Error{Message: "malformed error reply"}
+	}
+	return &Error{Code: int(code), Message: message}
+}
@@ -0,0 +1,358 @@
+package libvirt_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/lib/libvirt"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/lib/libvirt/libvirttest"
+)
+
+func names(domains []libvirt.Domain) []string {
+	var names []string
+	for _, d := range domains {
+		names = append(names, d.Name)
+	}
+	return names
+}
+
+func TestListAllDomains(t *testing.T) {
+	server := libvirttest.NewServer(t)
+	client := libvirt.NewClient(server.Socket())
+	defer client.Close()
+
+	all, err := client.ListAllDomains(context.Background(), 0)
+	if err != nil {
+		t.Fatalf("ListAllDomains() error = %v", err)
+	}
+	if got := names(all); !reflect.DeepEqual(got, []string{"Windows 11", "Home Assistant", "Ubuntu Server"}) {
+		t.Fatalf("ListAllDomains() = %v", got)
+	}
+	if all[0].UUIDString() != "5b0e4a2c-8f1d-4c3e-9a6b-2d7f1e0c4b8a" || all[0].ID != 1 || all[2].ID != -1 {
+		t.Errorf("unexpected domains: %+v", all)
+	}
+
+	inactive, err := client.ListAllDomains(context.Background(), libvirt.ListInactive)
+	if err != nil {
+		t.Fatalf("ListAllDomains() error = %v", err)
+	}
+	if got := names(inactive); !reflect.DeepEqual(got, []string{"Ubuntu Server"}) {
+		t.Errorf("ListAllDomains(inactive) = %v", got)
+	}
+
+	// Both calls share one connection
+	if n := server.Count("CONNECT_OPEN"); n != 1 {
+		t.Errorf("connection opened %d times", n)
+	}
+}
+
+func TestGetAllDomainStats(t *testing.T) {
+	server := libvirttest.NewServer(t)
+	client := libvirt.NewClient(server.Socket())
+	defer client.Close()
+
+	stats, err := client.GetAllDomainStats(context.Background(), libvirt.StatsState|libvirt.StatsCPUTotal|libvirt.StatsBlock|libvirt.StatsInterface, 0)
+	if err != nil {
+		t.Fatalf("GetAllDomainStats() error = %v", err)
+	}
+	if len(stats) != 3 {
+		t.Fatalf("GetAllDomainStats() returned %d records, want 3", len(stats))
+	}
+
+	windows := stats[0]
+	if state, _ := windows.Uint("state.state"); state != libvirt.StateRunning {
+		t.Errorf("state.state = %d", state)
+	}
+	if cpu, ok := windows.Uint("cpu.time"); !ok || cpu != 4823194000000 {
+		t.Errorf("cpu.time = %d, %v", cpu, ok)
+	}
+	if rd := windows.Sum("block", "rd.bytes"); rd != 21474836480+52428800 {
+		t.Errorf("block rd.bytes = %d", rd)
+	}
+	if path := windows.Params["block.0.path"]; path != "/mnt/user/domains/Windows 11/vdisk1.img" {
+		t.Errorf("block.0.path = %v", path)
+	}
+
+	ubuntu := stats[2]
+	if _, ok := ubuntu.Uint("cpu.time"); ok {
+		t.Error("shut off domain reported cpu.time")
+	}
+	if vcpus, _ := ubuntu.Uint("vcpu.current"); vcpus != 2 {
+		t.Errorf("vcpu.current = %d", vcpus)
+	}
+}
+
+func TestDomainAction(t *testing.T) {
+	server := libvirttest.NewServer(t)
+	client := libvirt.NewClient(server.Socket())
+	defer client.Close()
+	ctx := context.Background()
+
+	if err := client.DomainAction(ctx, "Windows 11", "suspend"); err != nil {
+		t.Fatalf("suspend error = %v", err)
+	}
+	if state := server.State("Windows 11"); state != libvirt.StatePaused {
+		t.Errorf("state after suspend = %s", libvirt.StateName(state))
+	}
+	if err := client.DomainAction(ctx, "Windows 11", "managedsave"); err != nil {
+		t.Fatalf("managedsave error = %v", err)
+	}
+	if server.Count("DOMAIN_MANAGED_SAVE Windows 11") != 1 {
+		t.Errorf("unexpected requests: %v", server.Requests())
+	}
+
+	err := client.DomainAction(ctx, "Ubuntu Server", "shutdown")
+	var apiErr *libvirt.Error
+	if !errors.As(err, &apiErr) || apiErr.Code != libvirt.ErrCodeOperationInvalid {
+		t.Errorf("shutdown of shut off domain error = %v", err)
+	}
+
+	// An API error leaves the connection usable
+	err = client.DomainAction(ctx, "Windows 10", "start")
+	if !libvirt.IsNotFound(err) {
+		t.Errorf("start of unknown domain error = %v", err)
+	}
+	if err := client.DomainAction(ctx, "Ubuntu Server", "start"); err != nil {
+		t.Errorf("start error = %v", err)
+	}
+	if n := server.Count("CONNECT_OPEN"); n != 1 {
+		t.Errorf("connection opened %d times", n)
+	}
+
+	if err := client.DomainAction(ctx, "Ubuntu Server", "undefine"); err == nil || libvirt.IsAPIError(err) {
+		t.Errorf("unknown action error = %v", err)
+	}
+}
+
+func TestSlowCallDoesNotBlockOthers(t *testing.T) {
+	server := libvirttest.NewServer(t)
+	client := libvirt.NewClient(server.Socket())
+	defer client.Close()
+	ctx := context.Background()
+
+	if _, err := client.ListAllDomains(ctx, 0); err != nil {
+		t.Fatalf("ListAllDomains() error = %v", err)
+	}
+	release := server.Hold("DOMAIN_MANAGED_SAVE")
+	defer release()
+	saved := make(chan error, 1)
+	go func() { saved <- client.DomainAction(ctx, "Windows 11", "managedsave") }()
+
+	if err := client.DomainAction(ctx, "Home Assistant", "suspend"); err != nil {
+		t.Fatalf("suspend during managedsave error = %v", err)
+	}
+	if _, err := client.ListAllDomains(ctx, 0); err != nil {
+		t.Fatalf("ListAllDomains() during managedsave error = %v", err)
+	}
+
+	// A call that runs out of time gives up without closing the shared connection
+	short, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if err := client.DomainAction(short, "Home Assistant", "managedsave"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("held managedsave error = %v", err)
+	}
+	if libvirt.IsUnreachable(context.DeadlineExceeded) {
+		t.Error("IsUnreachable(DeadlineExceeded) = true")
+	}
+
+	release()
+	if err := <-saved; err != nil {
+		t.Errorf("managedsave error = %v", err)
+	}
+	if n := server.Count("CONNECT_OPEN"); n != 1 {
+		t.Errorf("connection opened %d times", n)
+	}
+}
+
+func TestClientWithoutDaemon(t *testing.T) {
+	client := libvirt.NewClient(filepath.Join(t.TempDir(), "libvirt-sock"))
+
+	_, err := client.ListAllDomains(context.Background(), 0)
+	if err == nil || libvirt.IsAPIError(err) || !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("ListAllDomains() without daemon error = %v", err)
+	}
+	if !libvirt.IsUnreachable(err) {
+		t.Errorf("IsUnreachable(%v) = false", err)
+	}
+}
+
+func TestStateName(t *testing.T) {
+	tests := map[int]string{
+		libvirt.StateRunning: "running",
+		libvirt.StateShutoff: "shut off",
+		libvirt.StateBlocked: "idle",
+		42:                   "unknown",
+	}
+	for state, want := range tests {
+		if got := libvirt.StateName(state); got != want {
+			t.Errorf("StateName(%d) = %q, want %q", state, got, want)
+		}
+	}
+}
+
+func TestSnapshots(t *testing.T) {
+	server := libvirttest.NewServer(t)
+	client := libvirt.NewClient(server.Socket())
+	defer client.Close()
+	ctx := context.Background()
+
+	snaps, err := client.ListSnapshots(ctx, "Home Assistant")
+	if err != nil {
+		t.Fatalf("ListSnapshots() error = %v", err)
+	}
+	if len(snaps) != 2 || snaps[1].Name != "before-2024.11" || snaps[1].Domain.Name != "Home Assistant" {
+		t.Fatalf("ListSnapshots() = %+v", snaps)
+	}
+	desc, err := client.SnapshotDescription(ctx, snaps[1])
+	if err != nil {
+		t.Fatalf("SnapshotDescription() error = %v", err)
+	}
+	if desc.Parent == nil || desc.Parent.Name != "before-2024.10" || desc.Memory.Snapshot != "internal" || desc.CreationTime != 1730800000 {
+		t.Errorf("unexpected description: %+v", desc)
+	}
+
+	snap, err := client.CreateSnapshot(ctx, "Windows 11", libvirt.SnapshotXML{Name: "pre-24H2"},
+		libvirt.SnapshotCreateDiskOnly|libvirt.SnapshotCreateQuiesce)
+	if !libvirt.IsAgentUnavailable(err) {
+		t.Fatalf("quiesced CreateSnapshot() without agent error = %v", err)
+	}
+	snap, err = client.CreateSnapshot(ctx, "Windows 11", libvirt.SnapshotXML{Name: "pre-24H2"}, libvirt.SnapshotCreateDiskOnly)
+	if err != nil {
+		t.Fatalf("CreateSnapshot() error = %v", err)
+	}
+	desc, err = client.SnapshotDescription(ctx, snap)
+	if err != nil {
+		t.Fatalf("SnapshotDescription() error = %v", err)
+	}
+	if desc.State != "disk-snapshot" || desc.Disks[0].Source == nil || desc.Disks[0].Source.File != "/mnt/user/domains/Windows 11/vdisk1.pre-24H2" {
+		t.Errorf("unexpected disk-only snapshot: %+v", desc)
+	}
+	if err := client.RevertSnapshot(ctx, snap); !libvirt.IsConflict(err) {
+		t.Errorf("RevertSnapshot() to external snapshot error = %v", err)
+	}
+
+	if _, err := client.LookupSnapshot(ctx, "Windows 11", "missing"); !libvirt.IsNotFound(err) {
+		t.Errorf("LookupSnapshot() of unknown snapshot error = %v", err)
+	}
+}
+
+func TestDomainDescription(t *testing.T) {
+	server := libvirttest.NewServer(t)
+	client := libvirt.NewClient(server.Socket())
+	defer client.Close()
+	ctx := context.Background()
+
+	dom, err := client.LookupDomain(ctx, "Windows 11")
+	if err != nil {
+		t.Fatalf("LookupDomain() error = %v", err)
+	}
+	desc, err := client.DomainDescription(ctx, dom)
+	if err != nil {
+		t.Fatalf("DomainDescription() error = %v", err)
+	}
+	if desc.Memory.Bytes() != 8<<30 || desc.VCPU.Count != 4 || desc.OS.Type.Machine != "pc-q35-7.2" {
+		t.Errorf("unexpected domain: memory %d, vcpus %d, machine %q", desc.Memory.Bytes(), desc.VCPU.Count, desc.OS.Type.Machine)
+	}
+	if len(desc.Devices.Disks) != 2 || desc.Devices.Disks[0].Path() != "/mnt/user/domains/Windows 11/vdisk1.img" {
+		t.Errorf("unexpected disks: %+v", desc.Devices.Disks)
+	}
+	if len(desc.Devices.HostDevs) != 3 || desc.Devices.HostDevs[1].Source.Address.PCI() != "0000:01:00.1" {
+		t.Errorf("unexpected host devices: %+v", desc.Devices.HostDevs)
+	}
+
+	info, err := client.GetBlockInfo(ctx, dom, "hdc")
+	if err != nil {
+		t.Fatalf("GetBlockInfo() error = %v", err)
+	}
+	if info.Capacity != 107374182400 || info.Allocation != 68719476736 {
+		t.Errorf("GetBlockInfo() = %+v", info)
+	}
+	if _, err := client.GetBlockInfo(ctx, dom, "sdz"); !libvirt.IsAPIError(err) {
+		t.Errorf("GetBlockInfo() of unknown disk error = %v", err)
+	}
+}
+
+func TestGuestAgent(t *testing.T) {
+	server := libvirttest.NewServer(t)
+	client := libvirt.NewClient(server.Socket())
+	defer client.Close()
+	ctx := context.Background()
+
+	dom, err := client.LookupDomain(ctx, "Home Assistant")
+	if err != nil {
+		t.Fatalf("LookupDomain() error = %v", err)
+	}
+	info, err := client.GetGuestInfo(ctx, dom, libvirt.GuestInfoOS|libvirt.GuestInfoFilesystem|libvirt.GuestInfoTimezone)
+	if err != nil {
+		t.Fatalf("GetGuestInfo() error = %v", err)
+	}
+	if info.String("os.pretty-name") != "Home Assistant OS 12.1" || info.String("hostname") != "" {
+		t.Errorf("unexpected guest info: %v", info)
+	}
+	if offset, _ := info.Int("timezone.offset"); offset != 36000 {
+		t.Errorf("timezone.offset = %d", offset)
+	}
+	if used := info.Sum("fs", "used-bytes"); used != 8123456512+231735296 {
+		t.Errorf("filesystem usage = %d", used)
+	}
+
+	ifaces, err := client.InterfaceAddresses(ctx, dom)
+	if err != nil {
+		t.Fatalf("InterfaceAddresses() error = %v", err)
+	}
+	if len(ifaces) != 4 || ifaces[1].HWAddr != "52:54:00:8e:21:4d" || ifaces[1].Addrs[0] != (libvirt.IPAddress{Type: libvirt.AddressIPv4, Addr: "192.168.20.57", Prefix: 24}) {
+		t.Errorf("unexpected interfaces: %+v", ifaces)
+	}
+
+	if n, err := client.FSFreeze(ctx, dom); err != nil || n != 2 {
+		t.Errorf("FSFreeze() = %d, %v", n, err)
+	}
+	if _, err := client.FSFreeze(ctx, dom); !libvirt.IsAPIError(err) {
+		t.Errorf("FSFreeze() of frozen guest error = %v", err)
+	}
+	if n, err := client.FSThaw(ctx, dom); err != nil || n != 2 || server.Frozen("Home Assistant") {
+		t.Errorf("FSThaw() = %d, %v", n, err)
+	}
+	if err := client.SyncTime(ctx, dom); err != nil {
+		t.Errorf("SyncTime() error = %v", err)
+	}
+	if err := client.ShutdownGuest(ctx, dom); err != nil || server.State("Home Assistant") != libvirt.StateShutoff {
+		t.Errorf("ShutdownGuest() error = %v, state %d", err, server.State("Home Assistant"))
+	}
+
+	windows, _ := client.LookupDomain(ctx, "Windows 11")
+	if _, err := client.GetGuestInfo(ctx, windows, 0); !libvirt.IsAgentUnavailable(err) {
+		t.Errorf("GetGuestInfo() without agent error = %v", err)
+	}
+}
+
+func TestParseCPUSet(t *testing.T) {
+	tests := map[string][]int{
+		"4":        {4},
+		"16,4":     {4, 16},
+		"0-3,^2,8": {0, 1, 3, 8},
+		" 5 , 17 ": {5, 17},
+		"2-2":      {2},
+		"":         {},
+		"0-3,^0-1": nil,
+		"a":        nil,
+		"3-1":      nil,
+	}
+	for cpuset, want := range tests {
+		got, err := libvirt.ParseCPUSet(cpuset)
+		if want == nil {
+			if err == nil {
+				t.Errorf("ParseCPUSet(%q) = %v, want error", cpuset, got)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(got, want) {
+			t.Errorf("ParseCPUSet(%q) = %v, %v, want %v", cpuset, got, err, want)
+		}
+	}
+}
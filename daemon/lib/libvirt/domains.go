@@ -0,0 +1,255 @@
+package libvirt
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/lib/libvirt/internal/remote"
+)
+
+// Flags for ListAllDomains and GetAllDomainStats selecting which domains are returned.
+const (
+	ListActive      = 1 << 0
+	ListInactive    = 1 << 1
+	ListPersistent  = 1 << 2
+	ListTransient   = 1 << 3
+	ListRunning     = 1 << 4
+	ListPaused      = 1 << 5
+	ListShutoff     = 1 << 6
+	ListAutostart   = 1 << 10
+	ListNoAutostart = 1 << 11
+)
+
+// Stats groups for GetAllDomainStats.
+const (
+	StatsState     = 1 << 0
+	StatsCPUTotal  = 1 << 1
+	StatsBalloon   = 1 << 2
+	StatsVCPU      = 1 << 3
+	StatsInterface = 1 << 4
+	StatsBlock     = 1 << 5
+)
+
+// Domain states reported in the state.state statistic.
+const (
+	StateNoState = iota
+	StateRunning
+	StateBlocked
+	StatePaused
+	StateShutdown
+	StateShutoff
+	StateCrashed
+	StatePMSuspended
+)
+
+// stateNames are the state names virsh prints, so both backends report the same strings.
+var stateNames = map[int]string{
+	StateNoState:     "no state",
+	StateRunning:     "running",
+	StateBlocked:     "idle",
+	StatePaused:      "paused",
+	StateShutdown:    "in shutdown",
+	StateShutoff:     "shut off",
+	StateCrashed:     "crashed",
+	StatePMSuspended: "pmsuspended",
+}
+
+// StateName returns the virsh name of a domain state.
+func StateName(state int) string {
+	if name, ok := stateNames[state]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// domainActions map the virsh commands the agent runs to their procedures. Reboot and managed
+// save take an extra flags argument.
+var domainActions = map[string]int32{
+	"start":       remote.ProcDomainCreate,
+	"shutdown":    remote.ProcDomainShutdown,
+	"reboot":      remote.ProcDomainReboot,
+	"suspend":     remote.ProcDomainSuspend,
+	"resume":      remote.ProcDomainResume,
+	"managedsave": remote.ProcDomainManagedSave,
+	"destroy":     remote.ProcDomainDestroy,
+}
+
+// Domain identifies a domain. ID is -1 while the domain is not running.
+type Domain struct {
+	Name string
+	UUID [16]byte
+	ID   int32
+}
+
+// UUIDString formats the UUID the way virsh domuuid prints it.
+func (d Domain) UUIDString() string {
+	u := d.UUID
+	return fmt.Sprintf("%x-%x-%x-%x-%x", u[0:4], u[4:6], u[6:8], u[8:10], u[10:16])
+}
+
+func (d Domain) encode(e *remote.Encoder) {
+	e.Domain(d.Name, d.UUID, d.ID)
+}
+
+func decodeDomain(d *remote.Decoder) Domain {
+	name, uuid, id := d.Domain()
+	return Domain{Name: name, UUID: uuid, ID: id}
+}
+
+// Params holds typed parameters keyed by libvirt field name, as returned for domain statistics
+// and guest information.
+type Params map[string]interface{}
+
+// Uint returns a numeric parameter as uint64.
+func (p Params) Uint(field string) (uint64, bool) {
+	switch v := p[field].(type) {
+	case int32:
+		return uint64(v), v >= 0
+	case uint32:
+		return uint64(v), true
+	case int64:
+		return uint64(v), v >= 0
+	case uint64:
+		return v, true
+	default:
+		return 0, false
+	}
+}
+
+// Int returns a numeric parameter as int64.
+func (p Params) Int(field string) (int64, bool) {
+	switch v := p[field].(type) {
+	case int32:
+		return int64(v), true
+	case uint32:
+		return int64(v), true
+	case int64:
+		return v, true
+	case uint64:
+		return int64(v), true
+	default:
+		return 0, false
+	}
+}
+
+// String returns a string parameter.
+func (p Params) String(field string) string {
+	s, _ := p[field].(string)
+	return s
+}
+
+// Sum adds a numeric parameter over the devices of a group, e.g. Sum("net", "rx.bytes") adds
+// net.0.rx.bytes up to net.<count-1>.rx.bytes.
+func (p Params) Sum(group, field string) uint64 {
+	count, _ := p.Uint(group + ".count")
+	var total uint64
+	for i := uint64(0); i < count; i++ {
+		v, _ := p.Uint(group + "." + strconv.FormatUint(i, 10) + "." + field)
+		total += v
+	}
+	return total
+}
+
+// decodeParams reads a counted list of typed parameters.
+func decodeParams(d *remote.Decoder) Params {
+	params := make(Params)
+	n := d.Uint32()
+	for i := uint32(0); i < n && d.Err() == nil; i++ {
+		field, value := d.Param()
+		params[field] = value
+	}
+	return params
+}
+
+// DomainStats holds the statistics of one domain, keyed by libvirt field name such as
+// "cpu.time" or "block.0.rd.bytes".
+type DomainStats struct {
+	Domain Domain
+	Params Params
+}
+
+// Uint returns a numeric statistic as uint64.
+func (s DomainStats) Uint(field string) (uint64, bool) {
+	return s.Params.Uint(field)
+}
+
+// Sum adds a numeric statistic over the devices of a group.
+func (s DomainStats) Sum(group, field string) uint64 {
+	return s.Params.Sum(group, field)
+}
+
+// ListAllDomains lists the domains matching flags, or all domains when flags is 0.
+func (c *Client) ListAllDomains(ctx context.Context, flags uint32) ([]Domain, error) {
+	var e remote.Encoder
+	e.Int32(1) // need_results
+	e.Uint32(flags)
+	payload, err := c.call(ctx, remote.ProcConnectListAllDomains, e.Bytes())
+	if err != nil {
+		return nil, err
+	}
+
+	d := remote.NewDecoder(payload)
+	n := d.Uint32()
+	domains := make([]Domain, 0, min(n, 1024))
+	for i := uint32(0); i < n && d.Err() == nil; i++ {
+		domains = append(domains, decodeDomain(d))
+	}
+	return domains, d.Err()
+}
+
+// LookupDomain finds a domain by name.
+func (c *Client) LookupDomain(ctx context.Context, name string) (Domain, error) {
+	var e remote.Encoder
+	e.String(name)
+	payload, err := c.call(ctx, remote.ProcDomainLookupByName, e.Bytes())
+	if err != nil {
+		return Domain{}, err
+	}
+	d := remote.NewDecoder(payload)
+	dom := decodeDomain(d)
+	return dom, d.Err()
+}
+
+// DomainAction runs a lifecycle action on a domain by name. Actions are named after the virsh
+// commands: start, shutdown, reboot, suspend, resume, managedsave and destroy.
+func (c *Client) DomainAction(ctx context.Context, name, action string) error {
+	proc, ok := domainActions[action]
+	if !ok {
+		return fmt.Errorf("unknown domain action %q", action)
+	}
+	dom, err := c.LookupDomain(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	var e remote.Encoder
+	dom.encode(&e)
+	if proc == remote.ProcDomainReboot || proc == remote.ProcDomainManagedSave {
+		e.Uint32(0)
+	}
+	_, err = c.call(ctx, proc, e.Bytes())
+	return err
+}
+
+// GetAllDomainStats returns the statistics groups in stats for the domains matching flags, or
+// for all domains when flags is 0, in one call.
+func (c *Client) GetAllDomainStats(ctx context.Context, stats, flags uint32) ([]DomainStats, error) {
+	var e remote.Encoder
+	e.Uint32(0) // no explicit domain list
+	e.Uint32(stats)
+	e.Uint32(flags)
+	payload, err := c.call(ctx, remote.ProcConnectGetAllDomainStats, e.Bytes())
+	if err != nil {
+		return nil, err
+	}
+
+	d := remote.NewDecoder(payload)
+	n := d.Uint32()
+	records := make([]DomainStats, 0, min(n, 1024))
+	for i := uint32(0); i < n && d.Err() == nil; i++ {
+		dom := decodeDomain(d)
+		records = append(records, DomainStats{Domain: dom, Params: decodeParams(d)})
+	}
+	return records, d.Err()
+}
@@ -0,0 +1,288 @@
+package libvirt
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/lib/libvirt/internal/remote"
+)
+
+// DomainXML is the part of a <domain> description the agent reports.
+type DomainXML struct {
+	XMLName     xml.Name `xml:"domain"`
+	Name        string   `xml:"name"`
+	UUID        string   `xml:"uuid"`
+	Description string   `xml:"description"`
+	Memory      SizeXML  `xml:"memory"`
+	VCPU        struct {
+		Placement string `xml:"placement,attr"`
+		Count     int    `xml:",chardata"`
+	} `xml:"vcpu"`
+	CPUTune struct {
+		VCPUPins []struct {
+			VCPU   int    `xml:"vcpu,attr"`
+			CPUSet string `xml:"cpuset,attr"`
+		} `xml:"vcpupin"`
+		EmulatorPin *struct {
+			CPUSet string `xml:"cpuset,attr"`
+		} `xml:"emulatorpin"`
+	} `xml:"cputune"`
+	OS struct {
+		Firmware string `xml:"firmware,attr"`
+		Type     struct {
+			Arch    string `xml:"arch,attr"`
+			Machine string `xml:"machine,attr"`
+		} `xml:"type"`
+		Loader *struct {
+			Type   string `xml:"type,attr"`
+			Secure string `xml:"secure,attr"`
+			Path   string `xml:",chardata"`
+		} `xml:"loader"`
+		NVRAM string `xml:"nvram"`
+	} `xml:"os"`
+	CPU struct {
+		Mode     string `xml:"mode,attr"`
+		Topology *struct {
+			Sockets int `xml:"sockets,attr"`
+			Dies    int `xml:"dies,attr"`
+			Cores   int `xml:"cores,attr"`
+			Threads int `xml:"threads,attr"`
+		} `xml:"topology"`
+	} `xml:"cpu"`
+	Devices struct {
+		Disks      []DiskXML      `xml:"disk"`
+		Interfaces []InterfaceXML `xml:"interface"`
+		HostDevs   []HostDevXML   `xml:"hostdev"`
+		Graphics   []GraphicsXML  `xml:"graphics"`
+		Channels   []ChannelXML   `xml:"channel"`
+	} `xml:"devices"`
+}
+
+// SizeXML is an amount of memory with its unit, KiB when the unit is left out.
+type SizeXML struct {
+	Unit  string `xml:"unit,attr"`
+	Value uint64 `xml:",chardata"`
+}
+
+// Bytes converts the size to bytes.
+func (s SizeXML) Bytes() uint64 {
+	switch strings.ToLower(s.Unit) {
+	case "b", "bytes":
+		return s.Value
+	case "kb":
+		return s.Value * 1000
+	case "mb":
+		return s.Value * 1000 * 1000
+	case "gb":
+		return s.Value * 1000 * 1000 * 1000
+	case "m", "mib":
+		return s.Value << 20
+	case "g", "gib":
+		return s.Value << 30
+	case "t", "tib":
+		return s.Value << 40
+	default: // "", "k", "KiB"
+		return s.Value << 10
+	}
+}
+
+// DiskXML describes a disk or CD-ROM drive.
+type DiskXML struct {
+	Type   string `xml:"type,attr"`
+	Device string `xml:"device,attr"`
+	Driver struct {
+		Type  string `xml:"type,attr"`
+		Cache string `xml:"cache,attr"`
+	} `xml:"driver"`
+	Source struct {
+		File string `xml:"file,attr"`
+		Dev  string `xml:"dev,attr"`
+	} `xml:"source"`
+	Target struct {
+		Dev string `xml:"dev,attr"`
+		Bus string `xml:"bus,attr"`
+	} `xml:"target"`
+	Boot *struct {
+		Order int `xml:"order,attr"`
+	} `xml:"boot"`
+	ReadOnly *struct{} `xml:"readonly"`
+	Serial   string    `xml:"serial"`
+}
+
+// Path returns the image file or block device backing the disk.
+func (d DiskXML) Path() string {
+	if d.Source.File != "" {
+		return d.Source.File
+	}
+	return d.Source.Dev
+}
+
+// InterfaceXML describes a network interface.
+type InterfaceXML struct {
+	Type string `xml:"type,attr"`
+	MAC  struct {
+		Address string `xml:"address,attr"`
+	} `xml:"mac"`
+	Source struct {
+		Bridge  string `xml:"bridge,attr"`
+		Network string `xml:"network,attr"`
+		Dev     string `xml:"dev,attr"`
+	} `xml:"source"`
+	Model struct {
+		Type string `xml:"type,attr"`
+	} `xml:"model"`
+	Target struct {
+		Dev string `xml:"dev,attr"`
+	} `xml:"target"`
+}
+
+// HostDevXML describes a PCI or USB device passed through from the host.
+type HostDevXML struct {
+	Mode    string `xml:"mode,attr"`
+	Type    string `xml:"type,attr"`
+	Managed string `xml:"managed,attr"`
+	Source  struct {
+		Vendor *struct {
+			ID string `xml:"id,attr"`
+		} `xml:"vendor"`
+		Product *struct {
+			ID string `xml:"id,attr"`
+		} `xml:"product"`
+		Address *AddressXML `xml:"address"`
+	} `xml:"source"`
+	ROM *struct {
+		File string `xml:"file,attr"`
+	} `xml:"rom"`
+}
+
+// AddressXML is a PCI address (domain, bus, slot, function) or a USB address (bus, device).
+type AddressXML struct {
+	Domain   string `xml:"domain,attr"`
+	Bus      string `xml:"bus,attr"`
+	Slot     string `xml:"slot,attr"`
+	Function string `xml:"function,attr"`
+	Device   string `xml:"device,attr"`
+}
+
+// PCI formats a PCI address the way the host names it in sysfs, such as "0000:01:00.0".
+func (a AddressXML) PCI() string {
+	return fmt.Sprintf("%04x:%02x:%02x.%x", parseHex(a.Domain), parseHex(a.Bus), parseHex(a.Slot), parseHex(a.Function))
+}
+
+func parseHex(s string) uint64 {
+	v, _ := strconv.ParseUint(strings.TrimPrefix(s, "0x"), 16, 32)
+	return v
+}
+
+// GraphicsXML describes a graphical console. Port and WebSocket are -1 until libvirt assigns
+// them when the domain starts.
+type GraphicsXML struct {
+	Type      string `xml:"type,attr"`
+	Port      int    `xml:"port,attr"`
+	AutoPort  string `xml:"autoport,attr"`
+	WebSocket int    `xml:"websocket,attr"`
+	Listen    string `xml:"listen,attr"`
+}
+
+// ChannelXML describes a host to guest channel such as the QEMU guest agent's.
+type ChannelXML struct {
+	Type   string `xml:"type,attr"`
+	Target struct {
+		Type  string `xml:"type,attr"`
+		Name  string `xml:"name,attr"`
+		State string `xml:"state,attr"`
+	} `xml:"target"`
+}
+
+// GuestAgentChannel is the channel name of the QEMU guest agent.
+const GuestAgentChannel = "org.qemu.guest_agent.0"
+
+// BlockInfo holds the sizes of a disk image in bytes: the virtual disk size (capacity), the
+// host space in use (allocation) and the size of the file or device (physical).
+type BlockInfo struct {
+	Allocation uint64
+	Capacity   uint64
+	Physical   uint64
+}
+
+// DomainDescription returns the parsed XML description of a domain. For a running domain it
+// includes what libvirt assigned at start, such as console ports and interface names.
+func (c *Client) DomainDescription(ctx context.Context, dom Domain) (*DomainXML, error) {
+	var e remote.Encoder
+	dom.encode(&e)
+	e.Uint32(0)
+	payload, err := c.call(ctx, remote.ProcDomainGetXMLDesc, e.Bytes())
+	if err != nil {
+		return nil, err
+	}
+
+	d := remote.NewDecoder(payload)
+	data := d.String()
+	if err := d.Err(); err != nil {
+		return nil, err
+	}
+	var desc DomainXML
+	if err := xml.Unmarshal([]byte(data), &desc); err != nil {
+		return nil, fmt.Errorf("invalid description of domain %s: %w", dom.Name, err)
+	}
+	return &desc, nil
+}
+
+// GetBlockInfo returns the sizes of a disk of a domain, identified by target device (such as
+// "hdc") or source path.
+func (c *Client) GetBlockInfo(ctx context.Context, dom Domain, disk string) (BlockInfo, error) {
+	var e remote.Encoder
+	dom.encode(&e)
+	e.String(disk)
+	e.Uint32(0)
+	payload, err := c.call(ctx, remote.ProcDomainGetBlockInfo, e.Bytes())
+	if err != nil {
+		return BlockInfo{}, err
+	}
+	d := remote.NewDecoder(payload)
+	info := BlockInfo{Allocation: d.Uint64(), Capacity: d.Uint64(), Physical: d.Uint64()}
+	return info, d.Err()
+}
+
+// ParseCPUSet expands a libvirt cpuset such as "0-3,^2,8" into sorted CPU numbers.
+func ParseCPUSet(cpuset string) ([]int, error) {
+	include := make(map[int]bool)
+	for _, part := range strings.Split(cpuset, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		exclude := strings.HasPrefix(part, "^")
+		part = strings.TrimPrefix(part, "^")
+
+		first, last, isRange := strings.Cut(part, "-")
+		lo, err := strconv.Atoi(first)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cpuset %q", cpuset)
+		}
+		hi := lo
+		if isRange {
+			if hi, err = strconv.Atoi(last); err != nil || hi < lo || exclude {
+				return nil, fmt.Errorf("invalid cpuset %q", cpuset)
+			}
+		}
+		for cpu := lo; cpu <= hi; cpu++ {
+			if exclude {
+				delete(include, cpu)
+			} else {
+				include[cpu] = true
+			}
+		}
+	}
+
+	cpus := make([]int, 0, len(include))
+	for cpu := range include {
+		cpus = append(cpus, cpu)
+	}
+	sort.Ints(cpus)
+	return cpus, nil
+}
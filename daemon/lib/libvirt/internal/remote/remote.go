@@ -0,0 +1,344 @@
+// Package remote encodes and decodes messages of the libvirt remote protocol: XDR-encoded
+// calls and replies framed by a length and a header. It is shared by the libvirt client and the
+// fake daemon used in tests.
+package remote
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// Program and version of the remote protocol, from remote_protocol.x.
+const (
+	Program         = 0x20008086
+	ProtocolVersion = 1
+)
+
+// Procedures of the remote program used by the agent.
+const (
+	ProcConnectOpen              = 1
+	ProcConnectClose             = 2
+	ProcDomainCreate             = 9
+	ProcDomainDestroy            = 12
+	ProcDomainGetXMLDesc         = 14
+	ProcDomainLookupByName       = 23
+	ProcDomainReboot             = 27
+	ProcDomainResume             = 28
+	ProcDomainShutdown           = 33
+	ProcDomainSuspend            = 34
+	ProcDomainManagedSave        = 182
+	ProcDomainSnapshotCreateXML  = 185
+	ProcDomainSnapshotGetXMLDesc = 186
+	ProcDomainSnapshotLookup     = 189
+	ProcDomainRevertToSnapshot   = 192
+	ProcDomainSnapshotDelete     = 193
+	ProcDomainGetBlockInfo       = 194
+	ProcDomainShutdownFlags      = 258
+	ProcConnectListAllDomains    = 273
+	ProcDomainListAllSnapshots   = 274
+	ProcDomainFSFreeze           = 335
+	ProcDomainFSThaw             = 336
+	ProcDomainSetTime            = 338
+	ProcConnectGetAllDomainStats = 344
+	ProcDomainInterfaceAddresses = 353
+	ProcDomainGetGuestInfo       = 418
+)
+
+// Message types and reply statuses.
+const (
+	TypeCall    = 0
+	TypeReply   = 1
+	TypeMessage = 2
+
+	StatusOK    = 0
+	StatusError = 1
+)
+
+// Typed parameter types.
+const (
+	ParamInt     = 1
+	ParamUint    = 2
+	ParamLLong   = 3
+	ParamULLong  = 4
+	ParamDouble  = 5
+	ParamBoolean = 6
+	ParamString  = 7
+)
+
+// maxMessage is the largest message accepted, matching libvirt's VIR_NET_MESSAGE_MAX.
+const maxMessage = 32 << 20
+
+// headerSize is the length word plus the six header fields.
+const headerSize = 28
+
+// Header precedes the payload of every message.
+type Header struct {
+	Program   uint32
+	Version   uint32
+	Procedure int32
+	Type      int32
+	Serial    uint32
+	Status    int32
+}
+
+// WriteMessage frames and writes one message.
+func WriteMessage(w io.Writer, h Header, payload []byte) error {
+	buf := make([]byte, headerSize, headerSize+len(payload))
+	binary.BigEndian.PutUint32(buf[0:], uint32(headerSize+len(payload)))
+	binary.BigEndian.PutUint32(buf[4:], h.Program)
+	binary.BigEndian.PutUint32(buf[8:], h.Version)
+	binary.BigEndian.PutUint32(buf[12:], uint32(h.Procedure))
+	binary.BigEndian.PutUint32(buf[16:], uint32(h.Type))
+	binary.BigEndian.PutUint32(buf[20:], h.Serial)
+	binary.BigEndian.PutUint32(buf[24:], uint32(h.Status))
+	_, err := w.Write(append(buf, payload...))
+	return err
+}
+
+// ReadMessage reads one message and returns its header and payload.
+func ReadMessage(r io.Reader) (Header, []byte, error) {
+	var buf [headerSize]byte
+	if _, err := io.ReadFull(r, buf[:]); err != nil {
+		return Header{}, nil, err
+	}
+	length := binary.BigEndian.Uint32(buf[0:])
+	if length < headerSize || length > maxMessage {
+		return Header{}, nil, fmt.Errorf("invalid message length %d", length)
+	}
+	h := Header{
+		Program:   binary.BigEndian.Uint32(buf[4:]),
+		Version:   binary.BigEndian.Uint32(buf[8:]),
+		Procedure: int32(binary.BigEndian.Uint32(buf[12:])),
+		Type:      int32(binary.BigEndian.Uint32(buf[16:])),
+		Serial:    binary.BigEndian.Uint32(buf[20:]),
+		Status:    int32(binary.BigEndian.Uint32(buf[24:])),
+	}
+	payload := make([]byte, length-headerSize)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return Header{}, nil, err
+	}
+	return h, payload, nil
+}
+
+// Encoder appends XDR values to a buffer.
+type Encoder struct {
+	buf []byte
+}
+
+// Bytes returns the encoded data.
+func (e *Encoder) Bytes() []byte {
+	return e.buf
+}
+
+// Uint32 encodes an unsigned int.
+func (e *Encoder) Uint32(v uint32) {
+	e.buf = binary.BigEndian.AppendUint32(e.buf, v)
+}
+
+// Int32 encodes an int.
+func (e *Encoder) Int32(v int32) {
+	e.Uint32(uint32(v))
+}
+
+// Uint64 encodes an unsigned hyper.
+func (e *Encoder) Uint64(v uint64) {
+	e.buf = binary.BigEndian.AppendUint64(e.buf, v)
+}
+
+// Bool encodes a bool as an int.
+func (e *Encoder) Bool(v bool) {
+	if v {
+		e.Uint32(1)
+	} else {
+		e.Uint32(0)
+	}
+}
+
+// String encodes a non-null string, padded to a multiple of four bytes.
+func (e *Encoder) String(s string) {
+	e.Uint32(uint32(len(s)))
+	e.buf = append(e.buf, s...)
+	e.pad(len(s))
+}
+
+// OptString encodes a nullable string; the empty string is sent as null.
+func (e *Encoder) OptString(s string) {
+	if s == "" {
+		e.Uint32(0)
+		return
+	}
+	e.Uint32(1)
+	e.String(s)
+}
+
+// Fixed encodes fixed-length opaque data.
+func (e *Encoder) Fixed(b []byte) {
+	e.buf = append(e.buf, b...)
+	e.pad(len(b))
+}
+
+// Domain encodes a remote_nonnull_domain.
+func (e *Encoder) Domain(name string, uuid [16]byte, id int32) {
+	e.String(name)
+	e.Fixed(uuid[:])
+	e.Int32(id)
+}
+
+// Param encodes a remote_typed_param. Values are int32, uint32, int64, uint64, float64, bool
+// or string.
+func (e *Encoder) Param(field string, value interface{}) {
+	e.String(field)
+	switch v := value.(type) {
+	case int32:
+		e.Int32(ParamInt)
+		e.Int32(v)
+	case uint32:
+		e.Int32(ParamUint)
+		e.Uint32(v)
+	case int64:
+		e.Int32(ParamLLong)
+		e.Uint64(uint64(v))
+	case uint64:
+		e.Int32(ParamULLong)
+		e.Uint64(v)
+	case float64:
+		e.Int32(ParamDouble)
+		e.Uint64(math.Float64bits(v))
+	case bool:
+		e.Int32(ParamBoolean)
+		e.Bool(v)
+	case string:
+		e.Int32(ParamString)
+		e.String(v)
+	default:
+		panic(fmt.Sprintf("unsupported typed parameter %T", value))
+	}
+}
+
+func (e *Encoder) pad(n int) {
+	for ; n%4 != 0; n++ {
+		e.buf = append(e.buf, 0)
+	}
+}
+
+// ErrShort is returned when a payload ends before all values were decoded.
+var ErrShort = errors.New("truncated XDR data")
+
+// Decoder reads XDR values. The first error sticks and is returned by Err; values read after it
+// are zero.
+type Decoder struct {
+	data []byte
+	err  error
+}
+
+// NewDecoder creates a decoder for data.
+func NewDecoder(data []byte) *Decoder {
+	return &Decoder{data: data}
+}
+
+// Err returns the first decoding error.
+func (d *Decoder) Err() error {
+	return d.err
+}
+
+func (d *Decoder) next(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if n < 0 || n > len(d.data) {
+		d.err = ErrShort
+		return nil
+	}
+	b := d.data[:n]
+	d.data = d.data[n:]
+	return b
+}
+
+// Uint32 decodes an unsigned int.
+func (d *Decoder) Uint32() uint32 {
+	b := d.next(4)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint32(b)
+}
+
+// Int32 decodes an int.
+func (d *Decoder) Int32() int32 {
+	return int32(d.Uint32())
+}
+
+// Uint64 decodes an unsigned hyper.
+func (d *Decoder) Uint64() uint64 {
+	b := d.next(8)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint64(b)
+}
+
+// Bool decodes a bool.
+func (d *Decoder) Bool() bool {
+	return d.Uint32() != 0
+}
+
+// String decodes a non-null string.
+func (d *Decoder) String() string {
+	n := int(d.Uint32())
+	b := d.next(n)
+	d.next((4 - n%4) % 4)
+	return string(b)
+}
+
+// OptString decodes a nullable string, returning "" for null.
+func (d *Decoder) OptString() string {
+	if !d.Bool() {
+		return ""
+	}
+	return d.String()
+}
+
+// Fixed decodes n bytes of fixed-length opaque data.
+func (d *Decoder) Fixed(n int) []byte {
+	b := d.next(n)
+	d.next((4 - n%4) % 4)
+	return b
+}
+
+// Domain decodes a remote_nonnull_domain.
+func (d *Decoder) Domain() (name string, uuid [16]byte, id int32) {
+	name = d.String()
+	copy(uuid[:], d.Fixed(16))
+	id = d.Int32()
+	return name, uuid, id
+}
+
+// Param decodes a remote_typed_param into its field name and a value of the Go type Param
+// encodes.
+func (d *Decoder) Param() (string, interface{}) {
+	field := d.String()
+	switch kind := d.Int32(); kind {
+	case ParamInt:
+		return field, d.Int32()
+	case ParamUint:
+		return field, d.Uint32()
+	case ParamLLong:
+		return field, int64(d.Uint64())
+	case ParamULLong:
+		return field, d.Uint64()
+	case ParamDouble:
+		return field, math.Float64frombits(d.Uint64())
+	case ParamBoolean:
+		return field, d.Bool()
+	case ParamString:
+		return field, d.String()
+	default:
+		if d.err == nil {
+			d.err = fmt.Errorf("unknown typed parameter type %d for %s", kind, field)
+		}
+		return field, nil
+	}
+}
@@ -0,0 +1,903 @@
+// Package libvirttest provides a fake libvirtd speaking the remote protocol on a Unix socket. It
+// serves domains and statistics recorded from an Unraid server (a running "Windows 11" and
+// "Home Assistant" VM and a shut off "Ubuntu Server") so code using the libvirt client can be
+// tested without libvirt. Lifecycle calls change the state of the fake domains. Domain
+// descriptions are the recorded XML, which does not follow state changes. Snapshots are kept in
+// memory; only "Home Assistant" has a connected guest agent, which answers with the recorded guest
+// information and quiesces snapshots.
+package libvirttest
+
+import (
+	"embed"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/lib/libvirt"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/lib/libvirt/internal/remote"
+)
+
+//go:embed testdata
+var fixtures embed.FS
+
+// procNames name the procedures in the request log, as in remote_protocol.x without the
+// REMOTE_PROC_ prefix.
+var procNames = map[int32]string{
+	remote.ProcConnectOpen:              "CONNECT_OPEN",
+	remote.ProcConnectClose:             "CONNECT_CLOSE",
+	remote.ProcDomainCreate:             "DOMAIN_CREATE",
+	remote.ProcDomainDestroy:            "DOMAIN_DESTROY",
+	remote.ProcDomainGetXMLDesc:         "DOMAIN_GET_XML_DESC",
+	remote.ProcDomainLookupByName:       "DOMAIN_LOOKUP_BY_NAME",
+	remote.ProcDomainReboot:             "DOMAIN_REBOOT",
+	remote.ProcDomainResume:             "DOMAIN_RESUME",
+	remote.ProcDomainShutdown:           "DOMAIN_SHUTDOWN",
+	remote.ProcDomainSuspend:            "DOMAIN_SUSPEND",
+	remote.ProcDomainManagedSave:        "DOMAIN_MANAGED_SAVE",
+	remote.ProcDomainSnapshotCreateXML:  "DOMAIN_SNAPSHOT_CREATE_XML",
+	remote.ProcDomainSnapshotGetXMLDesc: "DOMAIN_SNAPSHOT_GET_XML_DESC",
+	remote.ProcDomainSnapshotLookup:     "DOMAIN_SNAPSHOT_LOOKUP_BY_NAME",
+	remote.ProcDomainRevertToSnapshot:   "DOMAIN_REVERT_TO_SNAPSHOT",
+	remote.ProcDomainSnapshotDelete:     "DOMAIN_SNAPSHOT_DELETE",
+	remote.ProcDomainGetBlockInfo:       "DOMAIN_GET_BLOCK_INFO",
+	remote.ProcDomainShutdownFlags:      "DOMAIN_SHUTDOWN_FLAGS",
+	remote.ProcDomainFSFreeze:           "DOMAIN_FSFREEZE",
+	remote.ProcDomainFSThaw:             "DOMAIN_FSTHAW",
+	remote.ProcDomainSetTime:            "DOMAIN_SET_TIME",
+	remote.ProcDomainInterfaceAddresses: "DOMAIN_INTERFACE_ADDRESSES",
+	remote.ProcDomainGetGuestInfo:       "DOMAIN_GET_GUEST_INFO",
+	remote.ProcDomainListAllSnapshots:   "DOMAIN_LIST_ALL_SNAPSHOTS",
+	remote.ProcConnectListAllDomains:    "CONNECT_LIST_ALL_DOMAINS",
+	remote.ProcConnectGetAllDomainStats: "CONNECT_GET_ALL_DOMAIN_STATS",
+}
+
+// inactiveStats are the statistics libvirt reports for a domain that is not running; the others
+// only exist while it runs.
+var inactiveStats = []string{"state.", "balloon.current", "balloon.maximum", "vcpu.current", "vcpu.maximum"}
+
+type domain struct {
+	Name       string                 `json:"name"`
+	UUID       string                 `json:"uuid"`
+	State      int                    `json:"state"`
+	Autostart  bool                   `json:"autostart"`
+	Persistent bool                   `json:"persistent"`
+	Agent      bool                   `json:"agent"`
+	XML        string                 `json:"xml"`
+	Snapshots  []*libvirt.SnapshotXML `json:"snapshots"`
+	Blocks     map[string]blockInfo   `json:"blocks"`
+	Guest      *guestInfo             `json:"guest"`
+	Stats      map[string]interface{} `json:"stats"`
+	id         int32
+	uuid       [16]byte
+	current    string
+	desc       string
+	frozen     bool
+}
+
+// guestInfo is what the guest agent of a domain reports: the typed parameters of
+// virDomainGetGuestInfo and the interfaces of virDomainInterfaceAddresses.
+type guestInfo struct {
+	Info       map[string]interface{} `json:"info"`
+	Interfaces []struct {
+		Name   string `json:"name"`
+		HWAddr string `json:"hwaddr"`
+		Addrs  []struct {
+			Type   int32  `json:"type"`
+			Addr   string `json:"addr"`
+			Prefix uint32 `json:"prefix"`
+		} `json:"addrs"`
+	} `json:"interfaces"`
+}
+
+// guestInfoGroups map the GetGuestInfo type bits to the fields they return.
+var guestInfoGroups = map[uint32]string{
+	libvirt.GuestInfoUsers:      "users.",
+	libvirt.GuestInfoOS:         "os.",
+	libvirt.GuestInfoTimezone:   "timezone.",
+	libvirt.GuestInfoHostname:   "hostname",
+	libvirt.GuestInfoFilesystem: "fs.",
+}
+
+// blockInfo holds the sizes of a disk, keyed by target device in the fixture.
+type blockInfo struct {
+	Allocation uint64 `json:"allocation"`
+	Capacity   uint64 `json:"capacity"`
+	Physical   uint64 `json:"physical"`
+}
+
+func (d *domain) active() bool {
+	return d.State != libvirt.StateShutoff && d.State != libvirt.StateCrashed
+}
+
+// Server is a fake libvirtd.
+type Server struct {
+	socket   string
+	listener net.Listener
+
+	mu       sync.Mutex
+	domains  []*domain
+	nextID   int32
+	requests []string
+	conns    map[net.Conn]struct{}
+	held     map[string]chan struct{}
+}
+
+// NewServer starts a fake libvirtd on a fresh socket. It is shut down when the test finishes.
+func NewServer(t testing.TB) *Server {
+	t.Helper()
+
+	data, err := fixtures.ReadFile("testdata/domains.json")
+	if err != nil {
+		t.Fatalf("failed to read fixture: %v", err)
+	}
+	var domains []*domain
+	if err := json.Unmarshal(data, &domains); err != nil {
+		t.Fatalf("invalid fixture: %v", err)
+	}
+	s := &Server{domains: domains, nextID: 1, conns: make(map[net.Conn]struct{}), held: make(map[string]chan struct{})}
+	for _, d := range domains {
+		raw, err := hex.DecodeString(strings.ReplaceAll(d.UUID, "-", ""))
+		if err != nil || len(raw) != 16 {
+			t.Fatalf("invalid UUID %q in fixture", d.UUID)
+		}
+		copy(d.uuid[:], raw)
+		desc, err := fixtures.ReadFile("testdata/xml/" + d.XML)
+		if err != nil {
+			t.Fatalf("failed to read description of %s: %v", d.Name, err)
+		}
+		d.desc = string(desc)
+		if n := len(d.Snapshots); n > 0 {
+			d.current = d.Snapshots[n-1].Name
+		}
+		d.id = -1
+		if d.active() {
+			d.id = s.nextID
+			s.nextID++
+		}
+	}
+
+	// Unix socket paths are limited to ~100 bytes, which t.TempDir() can exceed
+	dir, err := os.MkdirTemp("", "libvirttest-")
+	if err != nil {
+		t.Fatalf("failed to create socket directory: %v", err)
+	}
+	s.socket = filepath.Join(dir, "libvirt-sock")
+	s.listener, err = net.Listen("unix", s.socket)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatalf("failed to listen on %s: %v", s.socket, err)
+	}
+	go s.accept()
+
+	t.Cleanup(func() {
+		_ = s.listener.Close()
+		s.mu.Lock()
+		for conn := range s.conns {
+			_ = conn.Close()
+		}
+		for proc, ch := range s.held {
+			delete(s.held, proc)
+			close(ch)
+		}
+		s.mu.Unlock()
+		os.RemoveAll(dir)
+	})
+	return s
+}
+
+// Socket returns the path the fake libvirtd listens on.
+func (s *Server) Socket() string {
+	return s.socket
+}
+
+// Requests returns every call received so far as the procedure name, followed by the domain
+// name for calls on a domain, such as "DOMAIN_SUSPEND Windows 11".
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string{}, s.requests...)
+}
+
+// Count returns how many calls matched a request exactly.
+func (s *Server) Count(request string) int {
+	n := 0
+	for _, r := range s.Requests() {
+		if r == request {
+			n++
+		}
+	}
+	return n
+}
+
+// State returns the current state of a domain, or -1 if it does not exist.
+func (s *Server) State(name string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if d := s.lookup(name); d != nil {
+		return d.State
+	}
+	return -1
+}
+
+// Frozen reports whether the guest filesystems of a domain are frozen.
+func (s *Server) Frozen(name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d := s.lookup(name)
+	return d != nil && d.frozen
+}
+
+// SetVNCPort changes the VNC port in the description of a domain, so tests can point it at a
+// listener of their own.
+func (s *Server) SetVNCPort(name string, port int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if d := s.lookup(name); d != nil {
+		d.desc = vncPort.ReplaceAllString(d.desc, "${1}"+strconv.Itoa(port)+"'")
+	}
+}
+
+// Hold makes calls of a procedure, named as in the request log, wait until release is called,
+// as a slow snapshot or an unresponsive guest agent would. Other calls are answered meanwhile.
+func (s *Server) Hold(proc string) (release func()) {
+	ch := make(chan struct{})
+	s.mu.Lock()
+	s.held[proc] = ch
+	s.mu.Unlock()
+
+	return func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.held[proc] == ch {
+			delete(s.held, proc)
+			close(ch)
+		}
+	}
+}
+
+var vncPort = regexp.MustCompile(`(<graphics type='vnc' port=')-?\d+'`)
+
+func (s *Server) accept() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+		go s.serve(conn)
+	}
+}
+
+func (s *Server) serve(conn net.Conn) {
+	var calls sync.WaitGroup
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		_ = conn.Close()
+		calls.Wait()
+	}()
+
+	// Like libvirtd, calls are handled concurrently and answered as they finish
+	var writeMu sync.Mutex
+	for {
+		h, payload, err := remote.ReadMessage(conn)
+		if err != nil {
+			return
+		}
+		if h.Procedure == remote.ProcConnectClose {
+			calls.Wait()
+			_ = s.answer(conn, &writeMu, h, payload)
+			return
+		}
+		calls.Add(1)
+		go func() {
+			defer calls.Done()
+			s.mu.Lock()
+			held := s.held[procNames[h.Procedure]]
+			s.mu.Unlock()
+			if held != nil {
+				<-held
+			}
+			_ = s.answer(conn, &writeMu, h, payload)
+		}()
+	}
+}
+
+// answer handles one call and writes its reply.
+func (s *Server) answer(conn net.Conn, writeMu *sync.Mutex, h remote.Header, payload []byte) error {
+	reply, err := s.handle(h.Procedure, payload)
+
+	h.Type = remote.TypeReply
+	h.Status = remote.StatusOK
+	var apiErr *libvirt.Error
+	if errors.As(err, &apiErr) {
+		h.Status = remote.StatusError
+		reply = encodeError(apiErr)
+	}
+	writeMu.Lock()
+	defer writeMu.Unlock()
+	return remote.WriteMessage(conn, h, reply)
+}
+
+// handle runs one call and returns its reply payload or a *libvirt.Error.
+func (s *Server) handle(proc int32, payload []byte) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	name, ok := procNames[proc]
+	if !ok {
+		return nil, &libvirt.Error{Code: 1, Message: fmt.Sprintf("unknown procedure: %d", proc)}
+	}
+	args := remote.NewDecoder(payload)
+	var e remote.Encoder
+
+	switch proc {
+	case remote.ProcConnectOpen, remote.ProcConnectClose:
+		s.requests = append(s.requests, name)
+
+	case remote.ProcConnectListAllDomains:
+		s.requests = append(s.requests, name)
+		_ = args.Int32()
+		flags := args.Uint32()
+		var matched []*domain
+		for _, d := range s.domains {
+			if matches(d, flags) {
+				matched = append(matched, d)
+			}
+		}
+		e.Uint32(uint32(len(matched)))
+		for _, d := range matched {
+			e.Domain(d.Name, d.uuid, d.id)
+		}
+		e.Uint32(uint32(len(matched)))
+
+	case remote.ProcConnectGetAllDomainStats:
+		s.requests = append(s.requests, name)
+		if n := args.Uint32(); n != 0 {
+			return nil, &libvirt.Error{Code: 3, Message: "this function is not supported by the fake: domain list"}
+		}
+		_ = args.Uint32() // stats groups; all recorded statistics are returned
+		flags := args.Uint32()
+		var matched []*domain
+		for _, d := range s.domains {
+			if matches(d, flags) {
+				matched = append(matched, d)
+			}
+		}
+		e.Uint32(uint32(len(matched)))
+		for _, d := range matched {
+			e.Domain(d.Name, d.uuid, d.id)
+			encodeStats(&e, d)
+		}
+
+	case remote.ProcDomainLookupByName:
+		target := args.String()
+		s.requests = append(s.requests, name+" "+target)
+		d := s.lookup(target)
+		if d == nil {
+			return nil, noDomain(target)
+		}
+		e.Domain(d.Name, d.uuid, d.id)
+
+	case remote.ProcDomainGetXMLDesc:
+		target, _, _ := args.Domain()
+		_ = args.Uint32()
+		s.requests = append(s.requests, name+" "+target)
+		d := s.lookup(target)
+		if d == nil {
+			return nil, noDomain(target)
+		}
+		e.String(d.desc)
+
+	case remote.ProcDomainGetBlockInfo:
+		target, _, _ := args.Domain()
+		path := args.String()
+		_ = args.Uint32()
+		s.requests = append(s.requests, name+" "+target+"/"+path)
+		d := s.lookup(target)
+		if d == nil {
+			return nil, noDomain(target)
+		}
+		info, ok := d.Blocks[path]
+		if !ok {
+			return nil, &libvirt.Error{Code: 8, Message: fmt.Sprintf("invalid argument: invalid path %s not assigned to domain", path)}
+		}
+		e.Uint64(info.Allocation)
+		e.Uint64(info.Capacity)
+		e.Uint64(info.Physical)
+
+	case remote.ProcDomainGetGuestInfo, remote.ProcDomainInterfaceAddresses, remote.ProcDomainShutdownFlags,
+		remote.ProcDomainFSFreeze, remote.ProcDomainFSThaw, remote.ProcDomainSetTime:
+		target, _, _ := args.Domain()
+		s.requests = append(s.requests, name+" "+target)
+		d := s.lookup(target)
+		if d == nil {
+			return nil, noDomain(target)
+		}
+		if err := s.agentCall(d, proc, args, &e); err != nil {
+			return nil, err
+		}
+
+	case remote.ProcDomainListAllSnapshots:
+		target, _, _ := args.Domain()
+		s.requests = append(s.requests, name+" "+target)
+		d := s.lookup(target)
+		if d == nil {
+			return nil, noDomain(target)
+		}
+		e.Uint32(uint32(len(d.Snapshots)))
+		for _, snap := range d.Snapshots {
+			e.String(snap.Name)
+			e.Domain(d.Name, d.uuid, d.id)
+		}
+		e.Int32(int32(len(d.Snapshots)))
+
+	case remote.ProcDomainSnapshotLookup:
+		target, _, _ := args.Domain()
+		snapName := args.String()
+		s.requests = append(s.requests, name+" "+target+"/"+snapName)
+		d, snap, err := s.lookupSnapshot(target, snapName)
+		if err != nil {
+			return nil, err
+		}
+		e.String(snap.Name)
+		e.Domain(d.Name, d.uuid, d.id)
+
+	case remote.ProcDomainSnapshotCreateXML:
+		target, _, _ := args.Domain()
+		desc := args.String()
+		flags := args.Uint32()
+		s.requests = append(s.requests, name+" "+target)
+		d := s.lookup(target)
+		if d == nil {
+			return nil, noDomain(target)
+		}
+		snap, err := s.createSnapshot(d, desc, flags)
+		if err != nil {
+			return nil, err
+		}
+		e.String(snap.Name)
+		e.Domain(d.Name, d.uuid, d.id)
+
+	case remote.ProcDomainSnapshotGetXMLDesc, remote.ProcDomainRevertToSnapshot, remote.ProcDomainSnapshotDelete:
+		snapName := args.String()
+		target, _, _ := args.Domain()
+		s.requests = append(s.requests, name+" "+target+"/"+snapName)
+		d, snap, err := s.lookupSnapshot(target, snapName)
+		if err != nil {
+			return nil, err
+		}
+		switch proc {
+		case remote.ProcDomainSnapshotGetXMLDesc:
+			data, _ := xml.Marshal(snap)
+			e.String(string(data))
+		case remote.ProcDomainRevertToSnapshot:
+			err = s.revertSnapshot(d, snap)
+		case remote.ProcDomainSnapshotDelete:
+			err = deleteSnapshot(d, snap)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+	default:
+		target, _, _ := args.Domain()
+		s.requests = append(s.requests, name+" "+target)
+		d := s.lookup(target)
+		if d == nil {
+			return nil, noDomain(target)
+		}
+		if err := s.transition(d, proc); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := args.Err(); err != nil {
+		return nil, &libvirt.Error{Code: 1, Message: "malformed call: " + err.Error()}
+	}
+	return e.Bytes(), nil
+}
+
+// transition applies a lifecycle call. Shutdown and reboot complete immediately, as if the guest
+// reacted at once.
+func (s *Server) transition(d *domain, proc int32) error {
+	switch proc {
+	case remote.ProcDomainCreate:
+		if d.active() {
+			return invalid("domain is already running")
+		}
+		d.State = libvirt.StateRunning
+		d.id = s.nextID
+		s.nextID++
+	case remote.ProcDomainShutdown, remote.ProcDomainDestroy:
+		if !d.active() {
+			return invalid("domain is not running")
+		}
+		d.State = libvirt.StateShutoff
+		d.id = -1
+	case remote.ProcDomainReboot:
+		if d.State != libvirt.StateRunning {
+			return invalid("domain is not running")
+		}
+	case remote.ProcDomainSuspend:
+		if !d.active() {
+			return invalid("domain is not running")
+		}
+		d.State = libvirt.StatePaused
+	case remote.ProcDomainResume:
+		if d.State != libvirt.StatePaused {
+			return invalid("domain is not paused")
+		}
+		d.State = libvirt.StateRunning
+	case remote.ProcDomainManagedSave:
+		if !d.active() {
+			return invalid("domain is not running")
+		}
+		d.State = libvirt.StateShutoff
+		d.id = -1
+	}
+	return nil
+}
+
+// agentCall answers a call that goes through the guest agent. Only running domains with an agent
+// answer; freezing filesystems that are already frozen fails as in the QEMU guest agent.
+func (s *Server) agentCall(d *domain, proc int32, args *remote.Decoder, e *remote.Encoder) error {
+	switch {
+	case !d.active():
+		return invalid("domain is not running")
+	case !d.Agent || d.Guest == nil:
+		return &libvirt.Error{Code: libvirt.ErrCodeAgentUnresponsive, Message: "Guest agent is not responding: QEMU guest agent is not connected"}
+	}
+
+	switch proc {
+	case remote.ProcDomainGetGuestInfo:
+		types := args.Uint32()
+		_ = args.Uint32()
+		encodeGuestInfo(e, d.Guest.Info, types)
+
+	case remote.ProcDomainInterfaceAddresses:
+		if source := args.Uint32(); source != 1 {
+			return &libvirt.Error{Code: 3, Message: "this function is not supported by the fake: interface address source"}
+		}
+		_ = args.Uint32()
+		e.Uint32(uint32(len(d.Guest.Interfaces)))
+		for _, iface := range d.Guest.Interfaces {
+			e.String(iface.Name)
+			e.OptString(iface.HWAddr)
+			e.Uint32(uint32(len(iface.Addrs)))
+			for _, addr := range iface.Addrs {
+				e.Int32(addr.Type)
+				e.String(addr.Addr)
+				e.Uint32(addr.Prefix)
+			}
+		}
+
+	case remote.ProcDomainShutdownFlags:
+		_ = args.Uint32()
+		d.State = libvirt.StateShutoff
+		d.id = -1
+		d.frozen = false
+
+	case remote.ProcDomainFSFreeze, remote.ProcDomainFSThaw:
+		if mountpoints := args.Uint32(); mountpoints != 0 {
+			return &libvirt.Error{Code: 3, Message: "this function is not supported by the fake: mountpoints"}
+		}
+		_ = args.Uint32()
+		freeze := proc == remote.ProcDomainFSFreeze
+		if freeze && d.frozen {
+			return &libvirt.Error{Code: 1, Message: "internal error: unable to execute QEMU agent command 'guest-fsfreeze-freeze': The command guest-fsfreeze-freeze has been disabled for this instance"}
+		}
+		count := int32(0)
+		if freeze || d.frozen {
+			n, _ := d.Guest.Info["fs.count"].(float64)
+			count = int32(n)
+		}
+		d.frozen = freeze
+		e.Int32(count)
+
+	case remote.ProcDomainSetTime:
+		_ = args.Uint64()
+		_ = args.Uint32()
+		_ = args.Uint32()
+	}
+	return nil
+}
+
+func (s *Server) lookupSnapshot(domainName, name string) (*domain, *libvirt.SnapshotXML, error) {
+	d := s.lookup(domainName)
+	if d == nil {
+		return nil, nil, noDomain(domainName)
+	}
+	for _, snap := range d.Snapshots {
+		if snap.Name == name {
+			return d, snap, nil
+		}
+	}
+	return nil, nil, &libvirt.Error{
+		Code:    libvirt.ErrCodeNoDomainSnapshot,
+		Message: fmt.Sprintf("Domain snapshot not found: no domain snapshot with matching name '%s'", name),
+	}
+}
+
+// createSnapshot adds a snapshot the way the QEMU driver describes it: disk-only snapshots write
+// an overlay next to each disk image, others capture the disks in their qcow2 images and the
+// memory of a running domain.
+func (s *Server) createSnapshot(d *domain, desc string, flags uint32) (*libvirt.SnapshotXML, error) {
+	var snap libvirt.SnapshotXML
+	if err := xml.Unmarshal([]byte(desc), &snap); err != nil {
+		return nil, &libvirt.Error{Code: 27, Message: "XML error: " + err.Error()}
+	}
+	if snap.Name == "" {
+		snap.Name = strconv.FormatInt(time.Now().Unix(), 10)
+	}
+	for _, existing := range d.Snapshots {
+		if existing.Name == snap.Name {
+			return nil, invalid(fmt.Sprintf("domain snapshot '%s' already exists", snap.Name))
+		}
+	}
+
+	diskOnly := flags&libvirt.SnapshotCreateDiskOnly != 0
+	if flags&libvirt.SnapshotCreateQuiesce != 0 {
+		switch {
+		case !diskOnly:
+			return nil, &libvirt.Error{Code: libvirt.ErrCodeArgumentUnsupported, Message: "unsupported flags (0x40) in function qemuSnapshotCreateXML"}
+		case !d.active():
+			return nil, invalid("domain is not running")
+		case !d.Agent:
+			return nil, &libvirt.Error{Code: libvirt.ErrCodeArgumentUnsupported, Message: "argument unsupported: QEMU guest agent is not configured"}
+		}
+	}
+
+	snap.CreationTime = time.Now().Unix()
+	if d.current != "" {
+		snap.Parent = &struct {
+			Name string `xml:"name"`
+		}{Name: d.current}
+	}
+	memory := "no"
+	if diskOnly {
+		snap.State = "disk-snapshot"
+	} else {
+		snap.State = strings.ReplaceAll(libvirt.StateName(d.State), " ", "")
+		if d.active() {
+			memory = "internal"
+		}
+	}
+	snap.Memory = &struct {
+		Snapshot string `xml:"snapshot,attr"`
+	}{Snapshot: memory}
+
+	snap.Disks = nil
+	for i := 0; ; i++ {
+		prefix := "block." + strconv.Itoa(i) + "."
+		diskName, ok := d.Stats[prefix+"name"].(string)
+		if !ok {
+			break
+		}
+		path, _ := d.Stats[prefix+"path"].(string)
+		disk := libvirt.SnapshotDiskXML{Name: diskName, Snapshot: "internal"}
+		switch {
+		case strings.HasSuffix(path, ".iso"):
+			disk.Snapshot = "no"
+		case diskOnly:
+			disk.Snapshot = "external"
+			disk.Source = &struct {
+				File string `xml:"file,attr"`
+			}{File: strings.TrimSuffix(path, filepath.Ext(path)) + "." + snap.Name}
+		}
+		snap.Disks = append(snap.Disks, disk)
+	}
+
+	d.Snapshots = append(d.Snapshots, &snap)
+	d.current = snap.Name
+	return &snap, nil
+}
+
+// isExternal reports whether a snapshot has disks in overlay files, which libvirt can neither
+// revert to nor delete.
+func isExternal(snap *libvirt.SnapshotXML) bool {
+	for _, disk := range snap.Disks {
+		if disk.Snapshot == "external" {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Server) revertSnapshot(d *domain, snap *libvirt.SnapshotXML) error {
+	if isExternal(snap) {
+		return &libvirt.Error{Code: libvirt.ErrCodeConfigUnsupported, Message: "unsupported configuration: revert to external snapshot not supported yet"}
+	}
+	switch snap.State {
+	case "running", "paused":
+		d.State = libvirt.StateRunning
+		if snap.State == "paused" {
+			d.State = libvirt.StatePaused
+		}
+		if d.id < 0 {
+			d.id = s.nextID
+			s.nextID++
+		}
+	default:
+		d.State = libvirt.StateShutoff
+		d.id = -1
+	}
+	d.current = snap.Name
+	return nil
+}
+
+// deleteSnapshot removes a snapshot and attaches its children to its parent.
+func deleteSnapshot(d *domain, snap *libvirt.SnapshotXML) error {
+	if isExternal(snap) {
+		return &libvirt.Error{Code: libvirt.ErrCodeConfigUnsupported, Message: "unsupported configuration: deletion of 1 external disk snapshots not supported yet"}
+	}
+	kept := d.Snapshots[:0]
+	for _, other := range d.Snapshots {
+		if other == snap {
+			continue
+		}
+		if other.Parent != nil && other.Parent.Name == snap.Name {
+			other.Parent = snap.Parent
+		}
+		kept = append(kept, other)
+	}
+	d.Snapshots = kept
+	if d.current == snap.Name {
+		d.current = ""
+		if snap.Parent != nil {
+			d.current = snap.Parent.Name
+		}
+	}
+	return nil
+}
+
+func (s *Server) lookup(name string) *domain {
+	for _, d := range s.domains {
+		if d.Name == name {
+			return d
+		}
+	}
+	return nil
+}
+
+// matches applies the filter flags shared by ListAllDomains and GetAllDomainStats. Flags of a
+// group that are all unset do not filter.
+func matches(d *domain, flags uint32) bool {
+	group := func(mask uint32, ok bool) bool {
+		return flags&mask == 0 || ok
+	}
+	state := map[int]uint32{
+		libvirt.StateRunning: libvirt.ListRunning,
+		libvirt.StatePaused:  libvirt.ListPaused,
+		libvirt.StateShutoff: libvirt.ListShutoff,
+	}[d.State]
+	return group(libvirt.ListActive|libvirt.ListInactive, flags&libvirt.ListActive != 0 && d.active() || flags&libvirt.ListInactive != 0 && !d.active()) &&
+		group(libvirt.ListPersistent|libvirt.ListTransient, flags&libvirt.ListPersistent != 0 && d.Persistent || flags&libvirt.ListTransient != 0 && !d.Persistent) &&
+		group(libvirt.ListRunning|libvirt.ListPaused|libvirt.ListShutoff, flags&state != 0) &&
+		group(libvirt.ListAutostart|libvirt.ListNoAutostart, flags&libvirt.ListAutostart != 0 && d.Autostart || flags&libvirt.ListNoAutostart != 0 && !d.Autostart)
+}
+
+// encodeStats writes the typed parameters of a domain, choosing the parameter types libvirt
+// uses for each field.
+func encodeStats(e *remote.Encoder, d *domain) {
+	type param struct {
+		field string
+		value interface{}
+	}
+	params := []param{{"state.state", int32(d.State)}}
+	for field, value := range d.Stats {
+		if !d.active() && !hasAnyPrefix(field, inactiveStats) {
+			continue
+		}
+		switch v := value.(type) {
+		case string:
+			params = append(params, param{field, v})
+		case float64:
+			switch {
+			case strings.HasPrefix(field, "state."):
+				params = append(params, param{field, int32(v)})
+			case strings.HasSuffix(field, ".count"), strings.HasSuffix(field, ".state"),
+				field == "vcpu.current", field == "vcpu.maximum":
+				params = append(params, param{field, uint32(v)})
+			default:
+				params = append(params, param{field, uint64(v)})
+			}
+		}
+	}
+	e.Uint32(uint32(len(params)))
+	for _, p := range params {
+		e.Param(p.field, p.value)
+	}
+}
+
+// encodeGuestInfo writes the guest information in the groups requested by types, all of them when
+// types is 0.
+func encodeGuestInfo(e *remote.Encoder, info map[string]interface{}, types uint32) {
+	type param struct {
+		field string
+		value interface{}
+	}
+	var params []param
+	for field, value := range info {
+		requested := types == 0
+		for bit, prefix := range guestInfoGroups {
+			if types&bit != 0 && strings.HasPrefix(field, prefix) {
+				requested = true
+			}
+		}
+		if !requested {
+			continue
+		}
+		switch v := value.(type) {
+		case string:
+			params = append(params, param{field, v})
+		case float64:
+			switch {
+			case field == "timezone.offset":
+				params = append(params, param{field, int32(v)})
+			case strings.HasSuffix(field, ".count"):
+				params = append(params, param{field, uint32(v)})
+			default:
+				params = append(params, param{field, uint64(v)})
+			}
+		}
+	}
+	e.Uint32(uint32(len(params)))
+	for _, p := range params {
+		e.Param(p.field, p.value)
+	}
+}
+
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if strings.HasPrefix(s, p) {
+			return true
+		}
+	}
+	return false
+}
+
+func noDomain(name string) error {
+	return &libvirt.Error{
+		Code:    libvirt.ErrCodeNoDomain,
+		Message: fmt.Sprintf("Domain not found: no domain with matching name '%s'", name),
+	}
+}
+
+func invalid(reason string) error {
+	return &libvirt.Error{
+		Code:    libvirt.ErrCodeOperationInvalid,
+		Message: "Requested operation is not valid: " + reason,
+	}
+}
+
+// encodeError writes a remote_error with the fields libvirt fills in for domain errors.
+func encodeError(err *libvirt.Error) []byte {
+	var e remote.Encoder
+	e.Int32(int32(err.Code))
+	e.Int32(10) // VIR_FROM_QEMU
+	e.OptString(err.Message)
+	e.Int32(2) // VIR_ERR_ERROR
+	e.Uint32(0)
+	e.OptString("")
+	e.OptString("")
+	e.OptString("")
+	e.Int32(0)
+	e.Int32(0)
+	e.Uint32(0)
+	return e.Bytes()
+}
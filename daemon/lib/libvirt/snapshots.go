@@ -0,0 +1,170 @@
+package libvirt
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/lib/libvirt/internal/remote"
+)
+
+// Flags for CreateSnapshot.
+const (
+	SnapshotCreateDiskOnly = 1 << 4
+	SnapshotCreateQuiesce  = 1 << 6
+	SnapshotCreateAtomic   = 1 << 7
+)
+
+// Snapshot identifies a snapshot of a domain.
+type Snapshot struct {
+	Name   string
+	Domain Domain
+}
+
+func (s Snapshot) encode(e *remote.Encoder) {
+	e.String(s.Name)
+	s.Domain.encode(e)
+}
+
+func decodeSnapshot(d *remote.Decoder) Snapshot {
+	name := d.String()
+	return Snapshot{Name: name, Domain: decodeDomain(d)}
+}
+
+// SnapshotXML is the part of a <domainsnapshot> description the agent uses.
+type SnapshotXML struct {
+	XMLName      xml.Name `xml:"domainsnapshot"`
+	Name         string   `xml:"name,omitempty"`
+	Description  string   `xml:"description,omitempty"`
+	State        string   `xml:"state,omitempty"`
+	CreationTime int64    `xml:"creationTime,omitempty"`
+	Parent       *struct {
+		Name string `xml:"name"`
+	} `xml:"parent,omitempty"`
+	Memory *struct {
+		Snapshot string `xml:"snapshot,attr"`
+	} `xml:"memory,omitempty"`
+	Disks []SnapshotDiskXML `xml:"disks>disk,omitempty"`
+}
+
+// SnapshotDiskXML describes how one disk was captured: "internal" in the qcow2 image, "external"
+// in a new overlay file or "no".
+type SnapshotDiskXML struct {
+	Name     string `xml:"name,attr"`
+	Snapshot string `xml:"snapshot,attr,omitempty"`
+	Source   *struct {
+		File string `xml:"file,attr"`
+	} `xml:"source,omitempty"`
+}
+
+// ListSnapshots lists the snapshots of a domain by name.
+func (c *Client) ListSnapshots(ctx context.Context, domainName string) ([]Snapshot, error) {
+	dom, err := c.LookupDomain(ctx, domainName)
+	if err != nil {
+		return nil, err
+	}
+
+	var e remote.Encoder
+	dom.encode(&e)
+	e.Int32(1) // need_results
+	e.Uint32(0)
+	payload, err := c.call(ctx, remote.ProcDomainListAllSnapshots, e.Bytes())
+	if err != nil {
+		return nil, err
+	}
+
+	d := remote.NewDecoder(payload)
+	n := d.Uint32()
+	snapshots := make([]Snapshot, 0, min(n, 1024))
+	for i := uint32(0); i < n && d.Err() == nil; i++ {
+		snapshots = append(snapshots, decodeSnapshot(d))
+	}
+	return snapshots, d.Err()
+}
+
+// LookupSnapshot finds a snapshot of a domain by name.
+func (c *Client) LookupSnapshot(ctx context.Context, domainName, name string) (Snapshot, error) {
+	dom, err := c.LookupDomain(ctx, domainName)
+	if err != nil {
+		return Snapshot{}, err
+	}
+
+	var e remote.Encoder
+	dom.encode(&e)
+	e.String(name)
+	e.Uint32(0)
+	payload, err := c.call(ctx, remote.ProcDomainSnapshotLookup, e.Bytes())
+	if err != nil {
+		return Snapshot{}, err
+	}
+	d := remote.NewDecoder(payload)
+	snap := decodeSnapshot(d)
+	return snap, d.Err()
+}
+
+// SnapshotDescription returns the parsed XML description of a snapshot.
+func (c *Client) SnapshotDescription(ctx context.Context, snap Snapshot) (*SnapshotXML, error) {
+	var e remote.Encoder
+	snap.encode(&e)
+	e.Uint32(0)
+	payload, err := c.call(ctx, remote.ProcDomainSnapshotGetXMLDesc, e.Bytes())
+	if err != nil {
+		return nil, err
+	}
+
+	d := remote.NewDecoder(payload)
+	data := d.String()
+	if err := d.Err(); err != nil {
+		return nil, err
+	}
+	var desc SnapshotXML
+	if err := xml.Unmarshal([]byte(data), &desc); err != nil {
+		return nil, fmt.Errorf("invalid description of snapshot %s: %w", snap.Name, err)
+	}
+	return &desc, nil
+}
+
+// CreateSnapshot creates a snapshot of a domain by name from desc. libvirt fills in what desc
+// leaves out, such as a name derived from the creation time and the overlay file names of
+// disk-only snapshots.
+func (c *Client) CreateSnapshot(ctx context.Context, domainName string, desc SnapshotXML, flags uint32) (Snapshot, error) {
+	data, err := xml.Marshal(desc)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	dom, err := c.LookupDomain(ctx, domainName)
+	if err != nil {
+		return Snapshot{}, err
+	}
+
+	var e remote.Encoder
+	dom.encode(&e)
+	e.String(string(data))
+	e.Uint32(flags)
+	payload, err := c.call(ctx, remote.ProcDomainSnapshotCreateXML, e.Bytes())
+	if err != nil {
+		return Snapshot{}, err
+	}
+	d := remote.NewDecoder(payload)
+	snap := decodeSnapshot(d)
+	return snap, d.Err()
+}
+
+// RevertSnapshot restores a domain to a snapshot, including its running state when the snapshot
+// captured memory.
+func (c *Client) RevertSnapshot(ctx context.Context, snap Snapshot) error {
+	var e remote.Encoder
+	snap.encode(&e)
+	e.Uint32(0)
+	_, err := c.call(ctx, remote.ProcDomainRevertToSnapshot, e.Bytes())
+	return err
+}
+
+// DeleteSnapshot deletes a snapshot. Its children are attached to its parent.
+func (c *Client) DeleteSnapshot(ctx context.Context, snap Snapshot) error {
+	var e remote.Encoder
+	snap.encode(&e)
+	e.Uint32(0)
+	_, err := c.call(ctx, remote.ProcDomainSnapshotDelete, e.Bytes())
+	return err
+}
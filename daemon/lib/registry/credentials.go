@@ -0,0 +1,89 @@
+package registry
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Credentials are the username and password `docker login` stored for a registry.
+type Credentials struct {
+	Username string
+	Password string
+}
+
+// DockerConfigFile returns the config file of the docker CLI: config.json in $DOCKER_CONFIG or
+// in ~/.docker.
+func DockerConfigFile() string {
+	dir := os.Getenv("DOCKER_CONFIG")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".docker")
+	}
+	return filepath.Join(dir, "config.json")
+}
+
+// LookupCredentials returns the credentials `docker login` saved for registry, such as
+// "docker.io" or "ghcr.io". Only credentials stored in the config file itself are found;
+// credential helpers configured with credsStore or credHelpers are not run.
+func LookupCredentials(registry string) (Credentials, bool, error) {
+	path := DockerConfigFile()
+	if path == "" {
+		return Credentials{}, false, nil
+	}
+	data, err := os.ReadFile(path) //nolint:gosec // G304: the docker CLI's own config file
+	if errors.Is(err, os.ErrNotExist) {
+		return Credentials{}, false, nil
+	}
+	if err != nil {
+		return Credentials{}, false, err
+	}
+
+	var config struct {
+		Auths map[string]struct {
+			Auth     string `json:"auth"`
+			Username string `json:"username"`
+			Password string `json:"password"`
+		} `json:"auths"`
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return Credentials{}, false, fmt.Errorf("invalid docker config %s: %w", path, err)
+	}
+
+	for server, entry := range config.Auths {
+		if serverName(server) != registry {
+			continue
+		}
+		creds := Credentials{Username: entry.Username, Password: entry.Password}
+		if entry.Auth != "" {
+			decoded, err := base64.StdEncoding.DecodeString(entry.Auth)
+			if err != nil {
+				return Credentials{}, false, fmt.Errorf("invalid credentials for %s in %s: %w", server, path, err)
+			}
+			creds.Username, creds.Password, _ = strings.Cut(string(decoded), ":")
+		}
+		if creds.Username != "" {
+			return creds, true, nil
+		}
+	}
+	return Credentials{}, false, nil
+}
+
+// serverName reduces a server address saved by `docker login`, such as
+// "https://index.docker.io/v1/", to the registry name used in references.
+func serverName(server string) string {
+	server = strings.TrimPrefix(strings.TrimPrefix(server, "https://"), "http://")
+	server, _, _ = strings.Cut(server, "/")
+	switch server {
+	case "index.docker.io", "registry-1.docker.io":
+		return DockerHub
+	}
+	return server
+}
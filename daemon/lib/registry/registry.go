@@ -0,0 +1,308 @@
+// Package registry resolves image tags to manifest digests using the OCI distribution API, so
+// locally pulled images can be compared with what a registry currently serves.
+package registry
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DockerHub is the registry name used for images without a registry host.
+const DockerHub = "docker.io"
+
+// dockerHubEndpoint serves the distribution API for Docker Hub.
+const dockerHubEndpoint = "https://registry-1.docker.io"
+
+// manifestTypes are accepted in order of preference. Multi-arch indexes come first because
+// their digest is the one recorded in RepoDigests after a pull.
+var manifestTypes = []string{
+	"application/vnd.oci.image.index.v1+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+}
+
+// ErrNotFound is returned when the repository or tag does not exist.
+var ErrNotFound = errors.New("manifest not found")
+
+// ErrUnauthorized is returned when the registry refuses access, either because the image needs
+// credentials `docker login` did not save or because the saved ones are rejected.
+var ErrUnauthorized = errors.New("registry requires authentication")
+
+// Reference is a parsed image reference such as "ghcr.io/home-assistant/home-assistant:stable".
+type Reference struct {
+	Registry   string
+	Repository string
+	Tag        string
+	// Digest is set for references pinned with "@sha256:..."
+	Digest string
+}
+
+// ParseReference parses an image reference the way the docker CLI does: images without a
+// registry host come from Docker Hub, official images live under "library/" and the tag
+// defaults to "latest".
+func ParseReference(image string) (Reference, error) {
+	if image == "" || strings.ContainsAny(image, " \t\n") {
+		return Reference{}, fmt.Errorf("invalid image reference %q", image)
+	}
+
+	ref := Reference{Registry: DockerHub}
+	name := image
+	if i := strings.Index(name, "@"); i >= 0 {
+		ref.Digest = name[i+1:]
+		name = name[:i]
+	}
+
+	// The first component is a registry host if it contains a dot or port, or is localhost
+	if i := strings.Index(name, "/"); i >= 0 {
+		host := name[:i]
+		if strings.ContainsAny(host, ".:") || host == "localhost" {
+			ref.Registry = host
+			name = name[i+1:]
+		}
+	}
+	if ref.Registry == "index.docker.io" {
+		ref.Registry = DockerHub
+	}
+
+	// A colon after the last slash separates the tag
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		ref.Tag = name[i+1:]
+		name = name[:i]
+	}
+	if ref.Tag == "" && ref.Digest == "" {
+		ref.Tag = "latest"
+	}
+	if name == "" {
+		return Reference{}, fmt.Errorf("invalid image reference %q", image)
+	}
+	if ref.Registry == DockerHub && !strings.Contains(name, "/") {
+		name = "library/" + name
+	}
+	ref.Repository = strings.ToLower(name)
+	return ref, nil
+}
+
+// String returns the fully qualified reference.
+func (r Reference) String() string {
+	s := r.Registry + "/" + r.Repository
+	if r.Tag != "" {
+		s += ":" + r.Tag
+	}
+	if r.Digest != "" {
+		s += "@" + r.Digest
+	}
+	return s
+}
+
+// Client queries registries with the credentials `docker login` saved for them, or anonymously
+// when there are none. Bearer tokens are cached until they expire.
+type Client struct {
+	http *http.Client
+
+	mu     sync.Mutex
+	tokens map[string]cachedToken
+}
+
+type cachedToken struct {
+	token   string
+	expires time.Time
+}
+
+// NewClient creates a registry client. A nil httpClient uses a client with a 30 second timeout.
+func NewClient(httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 30 * time.Second}
+	}
+	return &Client{
+		http:   httpClient,
+		tokens: make(map[string]cachedToken),
+	}
+}
+
+func endpoint(registry string) string {
+	if registry == DockerHub {
+		return dockerHubEndpoint
+	}
+	return "https://" + registry
+}
+
+// Digest returns the digest of the manifest the registry serves for ref's tag.
+func (c *Client) Digest(ctx context.Context, ref Reference) (string, error) {
+	if ref.Tag == "" {
+		return ref.Digest, nil
+	}
+	manifestURL := fmt.Sprintf("%s/v2/%s/manifests/%s", endpoint(ref.Registry), ref.Repository, url.PathEscape(ref.Tag))
+
+	resp, err := c.request(ctx, http.MethodHead, manifestURL, ref)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	if digest := resp.Header.Get("Docker-Content-Digest"); digest != "" {
+		return digest, nil
+	}
+
+	// Some registries only send the digest header on GET; hash the manifest ourselves then
+	resp, err = c.request(ctx, http.MethodGet, manifestURL, ref)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if digest := resp.Header.Get("Docker-Content-Digest"); digest != "" {
+		return digest, nil
+	}
+	hash := sha256.New()
+	if _, err := io.Copy(hash, io.LimitReader(resp.Body, 4<<20)); err != nil {
+		return "", err
+	}
+	return "sha256:" + hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// request performs a manifest request, fetching a bearer token when the registry asks for one.
+func (c *Client) request(ctx context.Context, method, target string, ref Reference) (*http.Response, error) {
+	var token string
+	for attempt := 0; attempt < 2; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, method, target, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Accept", strings.Join(manifestTypes, ", "))
+		req.Header.Set("User-Agent", "unraid-management-agent")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+
+		resp, err := c.http.Do(req)
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case resp.StatusCode == http.StatusUnauthorized && token == "":
+			challenge := resp.Header.Get("WWW-Authenticate")
+			resp.Body.Close()
+			if token, err = c.token(ctx, challenge, ref); err != nil {
+				return nil, err
+			}
+		case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+			resp.Body.Close()
+			return nil, ErrUnauthorized
+		case resp.StatusCode == http.StatusNotFound:
+			resp.Body.Close()
+			return nil, ErrNotFound
+		case resp.StatusCode >= 300:
+			resp.Body.Close()
+			return nil, fmt.Errorf("registry returned %s", resp.Status)
+		default:
+			return resp, nil
+		}
+	}
+	return nil, ErrUnauthorized
+}
+
+// token obtains a pull token for ref's repository from the realm named in a
+// `Bearer realm="...",service="...",scope="..."` challenge. The saved credentials for ref's
+// registry are sent along; without them the token is anonymous.
+func (c *Client) token(ctx context.Context, challenge string, ref Reference) (string, error) {
+	scheme, params, _ := strings.Cut(challenge, " ")
+	if !strings.EqualFold(scheme, "Bearer") {
+		return "", ErrUnauthorized
+	}
+	values := parseChallenge(params)
+	realm := values["realm"]
+	if realm == "" {
+		return "", ErrUnauthorized
+	}
+	scope := values["scope"]
+	if scope == "" {
+		scope = "repository:" + ref.Repository + ":pull"
+	}
+	creds, login, err := LookupCredentials(ref.Registry)
+	if err != nil {
+		return "", err
+	}
+
+	key := realm + "|" + values["service"] + "|" + scope + "|" + creds.Username
+	c.mu.Lock()
+	cached, ok := c.tokens[key]
+	c.mu.Unlock()
+	if ok && time.Now().Before(cached.expires) {
+		return cached.token, nil
+	}
+
+	query := url.Values{"scope": {scope}}
+	if service := values["service"]; service != "" {
+		query.Set("service", service)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, realm+"?"+query.Encode(), nil)
+	if err != nil {
+		return "", err
+	}
+	if login {
+		req.SetBasicAuth(creds.Username, creds.Password)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", ErrUnauthorized
+	}
+
+	var payload struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+		return "", fmt.Errorf("invalid token response: %w", err)
+	}
+	token := payload.Token
+	if token == "" {
+		token = payload.AccessToken
+	}
+	if token == "" {
+		return "", ErrUnauthorized
+	}
+
+	// Tokens without an expiry are valid for 60 seconds per the token specification
+	lifetime := time.Duration(payload.ExpiresIn) * time.Second
+	if lifetime <= 0 {
+		lifetime = 60 * time.Second
+	}
+	c.mu.Lock()
+	c.tokens[key] = cachedToken{token: token, expires: time.Now().Add(lifetime - 10*time.Second)}
+	c.mu.Unlock()
+	return token, nil
+}
+
+// parseChallenge splits `realm="...",service="..."` into its key/value pairs.
+func parseChallenge(params string) map[string]string {
+	values := make(map[string]string)
+	for params != "" {
+		var pair string
+		// Values are quoted and may contain commas, so split on `",` boundaries
+		if i := strings.Index(params, `",`); i >= 0 {
+			pair, params = params[:i+1], params[i+2:]
+		} else {
+			pair, params = params, ""
+		}
+		key, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if ok {
+			values[strings.ToLower(key)] = strings.Trim(value, `"`)
+		}
+	}
+	return values
+}
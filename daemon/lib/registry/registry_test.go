@@ -0,0 +1,129 @@
+package registry_test
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/lib/registry"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/lib/registry/registrytest"
+)
+
+func TestParseReference(t *testing.T) {
+	tests := []struct {
+		image string
+		want  string
+	}{
+		{"nginx", "docker.io/library/nginx:latest"},
+		{"linuxserver/sonarr", "docker.io/linuxserver/sonarr:latest"},
+		{"plexinc/pms-docker:1.40.2", "docker.io/plexinc/pms-docker:1.40.2"},
+		{"index.docker.io/library/redis:7", "docker.io/library/redis:7"},
+		{"ghcr.io/home-assistant/home-assistant:stable", "ghcr.io/home-assistant/home-assistant:stable"},
+		{"localhost:5000/tools/app", "localhost:5000/tools/app:latest"},
+		{"registry.example.com:8443/Team/App:v1", "registry.example.com:8443/team/app:v1"},
+		{"nginx@sha256:abc", "docker.io/library/nginx@sha256:abc"},
+		{"nginx:1.25@sha256:abc", "docker.io/library/nginx:1.25@sha256:abc"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.image, func(t *testing.T) {
+			ref, err := registry.ParseReference(tt.image)
+			if err != nil {
+				t.Fatalf("ParseReference(%q) error = %v", tt.image, err)
+			}
+			if got := ref.String(); got != tt.want {
+				t.Errorf("ParseReference(%q) = %s, want %s", tt.image, got, tt.want)
+			}
+		})
+	}
+
+	for _, invalid := range []string{"", "bad image", ":tag"} {
+		if _, err := registry.ParseReference(invalid); err == nil {
+			t.Errorf("ParseReference(%q) returned no error", invalid)
+		}
+	}
+}
+
+func TestDigest(t *testing.T) {
+	fake := registrytest.NewServer(t)
+	fake.SetDigest("linuxserver/sonarr", "latest", "sha256:1111")
+
+	client := registry.NewClient(fake.Client())
+
+	ref, _ := registry.ParseReference("linuxserver/sonarr")
+	digest, err := client.Digest(context.Background(), ref)
+	if err != nil {
+		t.Fatalf("Digest() error = %v", err)
+	}
+	if digest != "sha256:1111" {
+		t.Errorf("Digest() = %s, want sha256:1111", digest)
+	}
+
+	// The cached token is reused for the second lookup
+	if _, err := client.Digest(context.Background(), ref); err != nil {
+		t.Fatalf("Digest() error = %v", err)
+	}
+	tokens := 0
+	for _, r := range fake.Requests() {
+		if r == "GET /token" {
+			tokens++
+		}
+	}
+	if tokens != 1 {
+		t.Errorf("token fetched %d times, want 1: %v", tokens, fake.Requests())
+	}
+
+	t.Run("unknown tag", func(t *testing.T) {
+		ref, _ := registry.ParseReference("linuxserver/sonarr:develop")
+		if _, err := client.Digest(context.Background(), ref); !errors.Is(err, registry.ErrNotFound) {
+			t.Errorf("Digest() error = %v, want ErrNotFound", err)
+		}
+	})
+
+	t.Run("registry host from reference", func(t *testing.T) {
+		fake.SetDigest("tools/app", "v2", "sha256:2222")
+		ref, _ := registry.ParseReference(fake.Listener.Addr().String() + "/tools/app:v2")
+		digest, err := client.Digest(context.Background(), ref)
+		if err != nil || digest != "sha256:2222" {
+			t.Errorf("Digest() = %s, %v, want sha256:2222", digest, err)
+		}
+	})
+
+	t.Run("digest missing from HEAD", func(t *testing.T) {
+		fake.OmitHeadDigest()
+		digest, err := client.Digest(context.Background(), ref)
+		if err != nil || digest != "sha256:1111" {
+			t.Errorf("Digest() = %s, %v, want sha256:1111 from GET", digest, err)
+		}
+	})
+}
+
+func TestDigestWithCredentials(t *testing.T) {
+	fake := registrytest.NewServer(t)
+	fake.SetDigest("example/private", "latest", "sha256:3333")
+	fake.RequireLogin("unraid", "s3cret")
+	ref, _ := registry.ParseReference("example/private")
+
+	dir := t.TempDir()
+	t.Setenv("DOCKER_CONFIG", dir)
+	if _, err := registry.NewClient(fake.Client()).Digest(context.Background(), ref); !errors.Is(err, registry.ErrUnauthorized) {
+		t.Fatalf("Digest() without credentials error = %v, want ErrUnauthorized", err)
+	}
+
+	// Saved the way `docker login` does for Docker Hub
+	config := `{"auths":{"https://index.docker.io/v1/":{"auth":"` + base64.StdEncoding.EncodeToString([]byte("unraid:s3cret")) + `"}}}`
+	if err := os.WriteFile(filepath.Join(dir, "config.json"), []byte(config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	creds, ok, err := registry.LookupCredentials(registry.DockerHub)
+	if err != nil || !ok || creds.Username != "unraid" || creds.Password != "s3cret" {
+		t.Fatalf("LookupCredentials() = %+v, %v, %v", creds, ok, err)
+	}
+	digest, err := registry.NewClient(fake.Client()).Digest(context.Background(), ref)
+	if err != nil || digest != "sha256:3333" {
+		t.Errorf("Digest() = %s, %v, want sha256:3333", digest, err)
+	}
+}
@@ -0,0 +1,133 @@
+// Package registrytest provides a fake OCI registry that requires bearer tokens like Docker Hub
+// and serves configurable manifest digests over TLS.
+package registrytest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const token = "registrytest-token"
+
+// Server is a fake registry.
+type Server struct {
+	*httptest.Server
+
+	mu             sync.Mutex
+	digests        map[string]string
+	omitHeadDigest bool
+	login          [2]string
+	requests       []string
+}
+
+// NewServer starts a fake registry that is shut down when the test finishes.
+func NewServer(t testing.TB) *Server {
+	t.Helper()
+	s := &Server{digests: make(map[string]string)}
+	s.Server = httptest.NewTLSServer(http.HandlerFunc(s.serve))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// SetDigest makes the registry serve digest for repository:tag, e.g. ("library/nginx", "latest").
+func (s *Server) SetDigest(repository, tag, digest string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.digests[repository+":"+tag] = digest
+}
+
+// OmitHeadDigest stops HEAD responses from carrying the Docker-Content-Digest header, as some
+// registries do.
+func (s *Server) OmitHeadDigest() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.omitHeadDigest = true
+}
+
+// RequireLogin makes the token endpoint refuse requests without these basic auth credentials.
+func (s *Server) RequireLogin(username, password string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.login = [2]string{username, password}
+}
+
+// Client returns an HTTP client that trusts the server's certificate and connects to the server
+// whatever registry host a request names, so real references such as "linuxserver/sonarr" can
+// be looked up against it.
+func (s *Server) Client() *http.Client {
+	client := s.Server.Client()
+	transport := client.Transport.(*http.Transport).Clone()
+	address := s.Listener.Addr().String()
+	transport.DialContext = func(ctx context.Context, network, _ string) (net.Conn, error) {
+		var d net.Dialer
+		return d.DialContext(ctx, network, address)
+	}
+	// The test certificate is issued for example.com
+	transport.TLSClientConfig.ServerName = "example.com"
+	client.Transport = transport
+	return client
+}
+
+// Requests returns every request received so far as "METHOD /path".
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string{}, s.requests...)
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.requests = append(s.requests, r.Method+" "+r.URL.Path)
+	s.mu.Unlock()
+
+	if r.URL.Path == "/token" {
+		s.mu.Lock()
+		login := s.login
+		s.mu.Unlock()
+		if username, password, _ := r.BasicAuth(); login[0] != "" && [2]string{username, password} != login {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"token": token, "expires_in": 300})
+		return
+	}
+
+	rest, ok := strings.CutPrefix(r.URL.Path, "/v2/")
+	repository, tag, found := strings.Cut(rest, "/manifests/")
+	if !ok || !found {
+		http.NotFound(w, r)
+		return
+	}
+
+	if r.Header.Get("Authorization") != "Bearer "+token {
+		w.Header().Set("WWW-Authenticate", fmt.Sprintf(
+			`Bearer realm="%s/token",service="registrytest",scope="repository:%s:pull"`, s.URL, repository))
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	s.mu.Lock()
+	digest, exists := s.digests[repository+":"+tag]
+	omit := s.omitHeadDigest
+	s.mu.Unlock()
+	if !exists {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/vnd.oci.image.index.v1+json")
+	if r.Method != http.MethodHead || !omit {
+		w.Header().Set("Docker-Content-Digest", digest)
+	}
+	if r.Method == http.MethodGet {
+		_, _ = w.Write([]byte(`{"schemaVersion":2,"manifests":[]}`))
+	}
+}
@@ -0,0 +1,393 @@
+package lib
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/dto"
+)
+
+// ErrSMARTUnavailable is returned when smartctl could not read a device, because it could not be
+// opened or is in standby.
+var ErrSMARTUnavailable = errors.New("SMART data unavailable")
+
+// ErrDiskStandby is returned when -n standby kept smartctl from waking a disk in a low-power
+// mode. It wraps ErrSMARTUnavailable.
+var ErrDiskStandby = fmt.Errorf("%w: disk is in standby", ErrSMARTUnavailable)
+
+// lowPowerMessage is the message smartctl prints when -n skips a device, such as "Device is in
+// STANDBY mode, exit(2)".
+var lowPowerMessage = regexp.MustCompile(`(?i)^device is in \S+ mode`)
+
+// nvmeDataUnit is the size of an NVMe data unit, 1000 512-byte blocks.
+const nvmeDataUnit = 512 * 1000
+
+// smartctlOutput is the subset of the smartctl -j -a output that is parsed.
+type smartctlOutput struct {
+	smartctlStatus
+	Device struct {
+		Protocol string `json:"protocol"`
+	} `json:"device"`
+	ModelName       string `json:"model_name"`
+	SCSIModelName   string `json:"scsi_model_name"`
+	SerialNumber    string `json:"serial_number"`
+	FirmwareVersion string `json:"firmware_version"`
+	SCSIRevision    string `json:"scsi_revision"`
+	SmartStatus     *struct {
+		Passed bool `json:"passed"`
+	} `json:"smart_status"`
+	Temperature struct {
+		Current float64 `json:"current"`
+	} `json:"temperature"`
+	PowerOnTime struct {
+		Hours uint64 `json:"hours"`
+	} `json:"power_on_time"`
+	PowerCycleCount uint64 `json:"power_cycle_count"`
+
+	ATASmartData struct {
+		SelfTest struct {
+			Status         smartctlTestStatus `json:"status"`
+			PollingMinutes map[string]int     `json:"polling_minutes"`
+		} `json:"self_test"`
+	} `json:"ata_smart_data"`
+	ATASmartAttributes struct {
+		Table []struct {
+			ID         int    `json:"id"`
+			Name       string `json:"name"`
+			Value      int    `json:"value"`
+			Worst      int    `json:"worst"`
+			Thresh     int    `json:"thresh"`
+			WhenFailed string `json:"when_failed"`
+			Raw        struct {
+				Value  uint64 `json:"value"`
+				String string `json:"string"`
+			} `json:"raw"`
+		} `json:"table"`
+	} `json:"ata_smart_attributes"`
+	ATASmartErrorLog struct {
+		Summary struct {
+			Count uint64 `json:"count"`
+		} `json:"summary"`
+	} `json:"ata_smart_error_log"`
+	ATASmartSelfTestLog struct {
+		Standard struct {
+			Table []struct {
+				Type          smartctlValue      `json:"type"`
+				Status        smartctlTestStatus `json:"status"`
+				LifetimeHours uint64             `json:"lifetime_hours"`
+				LBA           *uint64            `json:"lba"`
+			} `json:"table"`
+		} `json:"standard"`
+	} `json:"ata_smart_self_test_log"`
+
+	NVMeHealth *struct {
+		CriticalWarning         int    `json:"critical_warning"`
+		AvailableSpare          int    `json:"available_spare"`
+		AvailableSpareThreshold int    `json:"available_spare_threshold"`
+		PercentageUsed          int    `json:"percentage_used"`
+		DataUnitsRead           uint64 `json:"data_units_read"`
+		DataUnitsWritten        uint64 `json:"data_units_written"`
+		MediaErrors             uint64 `json:"media_errors"`
+		UnsafeShutdowns         uint64 `json:"unsafe_shutdowns"`
+		NumErrLogEntries        uint64 `json:"num_err_log_entries"`
+		WarningTempTime         uint64 `json:"warning_temp_time"`
+		CriticalCompTime        uint64 `json:"critical_comp_time"`
+	} `json:"nvme_smart_health_information_log"`
+	NVMeSelfTestLog struct {
+		CurrentOperation         smartctlValue `json:"current_self_test_operation"`
+		CurrentCompletionPercent int           `json:"current_self_test_completion_percent"`
+		Table                    []struct {
+			SelfTestCode   smartctlValue `json:"self_test_code"`
+			SelfTestResult smartctlValue `json:"self_test_result"`
+			PowerOnHours   uint64        `json:"power_on_hours"`
+			LBA            *uint64       `json:"lba"`
+		} `json:"table"`
+	} `json:"nvme_self_test_log"`
+
+	SCSIGrownDefectList *uint64 `json:"scsi_grown_defect_list"`
+	SCSIErrorCounterLog map[string]struct {
+		TotalUncorrectedErrors uint64 `json:"total_uncorrected_errors"`
+	} `json:"scsi_error_counter_log"`
+	SCSIStartStopCycleCounter struct {
+		AccumulatedStartStopCycles uint64 `json:"accumulated_start_stop_cycles"`
+	} `json:"scsi_start_stop_cycle_counter"`
+}
+
+// smartctlStatus is the smartctl section of the output of any smartctl -j command.
+type smartctlStatus struct {
+	Smartctl struct {
+		ExitStatus int `json:"exit_status"`
+		Messages   []struct {
+			String string `json:"string"`
+		} `json:"messages"`
+	} `json:"smartctl"`
+}
+
+// smartctlValue is a value smartctl reports both as a number and as text.
+type smartctlValue struct {
+	Value  int    `json:"value"`
+	String string `json:"string"`
+}
+
+// smartctlTestStatus is the status of an ATA self-test.
+type smartctlTestStatus struct {
+	Value            int    `json:"value"`
+	String           string `json:"string"`
+	RemainingPercent int    `json:"remaining_percent"`
+	Passed           *bool  `json:"passed"`
+}
+
+// ReadSMART runs smartctl -j -a on a device such as sdb or nvme0n1. SATA and SAS disks are read
+// with -n standby so a spun down disk is not woken up; ErrSMARTUnavailable is returned instead.
+func ReadSMART(smartctl, device string) (*dto.DiskSMART, error) {
+	args := []string{"-j", "-a", "/dev/" + device}
+	if !strings.HasPrefix(device, "nvme") {
+		args = append([]string{"-n", "standby"}, args...)
+	}
+	// smartctl exits non-zero when the disk reports problems, which is data, not a failure
+	lines, err := ExecCommand(smartctl, args...)
+	if len(lines) == 0 {
+		if err == nil {
+			err = errors.New("no output")
+		}
+		return nil, fmt.Errorf("failed to run smartctl: %w", err)
+	}
+	smart, err := ParseSmartctl([]byte(strings.Join(lines, "\n")))
+	if err != nil {
+		return nil, err
+	}
+	smart.Device = device
+	return smart, nil
+}
+
+// NormalizeSelfTestType checks a self-test type and returns it as reported in the self-test
+// log: "short", "extended" (also accepted as "long") or "conveyance".
+func NormalizeSelfTestType(kind string) (string, error) {
+	switch kind = strings.ToLower(strings.TrimSpace(kind)); kind {
+	case "short", "extended", "conveyance":
+		return kind, nil
+	case "long":
+		return "extended", nil
+	}
+	return "", fmt.Errorf("invalid self-test type: %s (must be short, extended or conveyance)", kind)
+}
+
+// StartSelfTest starts a SMART self-test of the given type on a device. The disk runs the test
+// on its own; smartctl returns right away. NVMe devices do not support conveyance tests.
+func StartSelfTest(smartctl, device, kind string) error {
+	kind, err := NormalizeSelfTestType(kind)
+	if err != nil {
+		return err
+	}
+	if kind == "conveyance" && strings.HasPrefix(device, "nvme") {
+		return fmt.Errorf("NVMe devices do not support conveyance self-tests")
+	}
+	arg := kind
+	if kind == "extended" {
+		arg = "long"
+	}
+	return runSmartctl(smartctl, "-j", "-t", arg, "/dev/"+device)
+}
+
+// AbortSelfTest aborts the self-test a device is running.
+func AbortSelfTest(smartctl, device string) error {
+	return runSmartctl(smartctl, "-j", "-X", "/dev/"+device)
+}
+
+// runSmartctl runs a smartctl -j command that changes the device and checks its exit status.
+// Bits 0 to 2 mean the command could not be sent or the device rejected it; the other bits
+// report the health of the disk.
+func runSmartctl(smartctl string, args ...string) error {
+	lines, err := ExecCommand(smartctl, args...)
+	if len(lines) == 0 {
+		if err == nil {
+			err = errors.New("no output")
+		}
+		return fmt.Errorf("failed to run smartctl: %w", err)
+	}
+	var out smartctlStatus
+	if err := json.Unmarshal([]byte(strings.Join(lines, "\n")), &out); err != nil {
+		return fmt.Errorf("failed to parse smartctl output: %w", err)
+	}
+	if out.Smartctl.ExitStatus&0x7 != 0 {
+		msg := fmt.Sprintf("exit status %d", out.Smartctl.ExitStatus)
+		if len(out.Smartctl.Messages) > 0 {
+			msg = out.Smartctl.Messages[0].String
+		}
+		return fmt.Errorf("smartctl failed: %s", msg)
+	}
+	return nil
+}
+
+// ParseSmartctl parses the JSON output of smartctl -j -a for ATA, NVMe and SCSI devices.
+func ParseSmartctl(data []byte) (*dto.DiskSMART, error) {
+	var out smartctlOutput
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil, fmt.Errorf("failed to parse smartctl output: %w", err)
+	}
+	// Bits 0 and 1 of the exit status: bad command line, or the device could not be opened or
+	// is in a low-power mode; only the message tells the last two apart
+	if out.Smartctl.ExitStatus&0x3 != 0 {
+		msg := fmt.Sprintf("exit status %d", out.Smartctl.ExitStatus)
+		if len(out.Smartctl.Messages) > 0 {
+			msg = out.Smartctl.Messages[0].String
+		}
+		if lowPowerMessage.MatchString(msg) {
+			return nil, fmt.Errorf("%w: %s", ErrDiskStandby, msg)
+		}
+		return nil, fmt.Errorf("%w: %s", ErrSMARTUnavailable, msg)
+	}
+
+	smart := &dto.DiskSMART{
+		Protocol:        out.Device.Protocol,
+		Model:           out.ModelName,
+		SerialNumber:    out.SerialNumber,
+		Firmware:        out.FirmwareVersion,
+		Status:          "UNKNOWN",
+		Temperature:     out.Temperature.Current,
+		PowerOnHours:    out.PowerOnTime.Hours,
+		PowerCycleCount: out.PowerCycleCount,
+		SelfTests:       []dto.SMARTSelfTest{},
+		UpdatedAt:       time.Now(),
+	}
+	if smart.Model == "" {
+		smart.Model = out.SCSIModelName
+	}
+	if smart.Firmware == "" {
+		smart.Firmware = out.SCSIRevision
+	}
+	if out.SmartStatus != nil {
+		smart.Status = "FAILED"
+		if out.SmartStatus.Passed {
+			smart.Status = "PASSED"
+		}
+	}
+
+	switch {
+	case out.NVMeHealth != nil:
+		parseNVMeSMART(&out, smart)
+	case out.SCSIGrownDefectList != nil || out.SCSIErrorCounterLog != nil:
+		parseSCSISMART(&out, smart)
+	default:
+		parseATASMART(&out, smart)
+	}
+	return smart, nil
+}
+
+func parseATASMART(out *smartctlOutput, smart *dto.DiskSMART) {
+	smart.Attributes = make(map[string]dto.SMARTAttribute, len(out.ATASmartAttributes.Table))
+	for _, a := range out.ATASmartAttributes.Table {
+		attr := dto.SMARTAttribute{
+			ID:         a.ID,
+			Name:       a.Name,
+			Value:      a.Value,
+			Worst:      a.Worst,
+			Threshold:  a.Thresh,
+			RawValue:   a.Raw.String,
+			WhenFailed: a.WhenFailed,
+		}
+		// Vendor specific attributes all share the name Unknown_Attribute
+		key := a.Name
+		if _, dup := smart.Attributes[key]; dup {
+			key = fmt.Sprintf("%s_%d", a.Name, a.ID)
+		}
+		smart.Attributes[key] = attr
+
+		switch a.ID {
+		case 5:
+			smart.ReallocatedSectors = a.Raw.Value
+		case 197:
+			smart.PendingSectors = a.Raw.Value
+		case 198:
+			smart.OfflineUncorrectable = a.Raw.Value
+		}
+	}
+	smart.ErrorLogCount = out.ATASmartErrorLog.Summary.Count
+
+	// A status of 0xF0 to 0xF9 is a self-test in progress, with the remaining tenths in the low
+	// nibble
+	if status := out.ATASmartData.SelfTest.Status; status.Value>>4 == 0xF {
+		smart.SelfTestRunning = &dto.SMARTSelfTestProgress{ProgressPercent: 100 - status.RemainingPercent}
+	}
+	for kind, minutes := range out.ATASmartData.SelfTest.PollingMinutes {
+		if smart.SelfTestMinutes == nil {
+			smart.SelfTestMinutes = make(map[string]int)
+		}
+		smart.SelfTestMinutes[selfTestType(kind)] = minutes
+	}
+
+	for _, entry := range out.ATASmartSelfTestLog.Standard.Table {
+		test := dto.SMARTSelfTest{
+			Type:             selfTestType(entry.Type.String),
+			Status:           entry.Status.String,
+			Passed:           entry.Status.Passed != nil && *entry.Status.Passed,
+			RemainingPercent: entry.Status.RemainingPercent,
+			LifetimeHours:    entry.LifetimeHours,
+			FirstErrorLBA:    entry.LBA,
+		}
+		smart.SelfTests = append(smart.SelfTests, test)
+	}
+}
+
+func parseNVMeSMART(out *smartctlOutput, smart *dto.DiskSMART) {
+	h := out.NVMeHealth
+	smart.NVMeHealth = &dto.NVMeHealth{
+		CriticalWarning:         h.CriticalWarning,
+		AvailableSpare:          h.AvailableSpare,
+		AvailableSpareThreshold: h.AvailableSpareThreshold,
+		PercentageUsed:          h.PercentageUsed,
+		BytesRead:               h.DataUnitsRead * nvmeDataUnit,
+		BytesWritten:            h.DataUnitsWritten * nvmeDataUnit,
+		MediaErrors:             h.MediaErrors,
+		UnsafeShutdowns:         h.UnsafeShutdowns,
+		ErrorLogEntries:         h.NumErrLogEntries,
+		WarningTempMinutes:      h.WarningTempTime,
+		CriticalTempMinutes:     h.CriticalCompTime,
+	}
+	smart.ErrorLogCount = h.NumErrLogEntries
+
+	if op := out.NVMeSelfTestLog.CurrentOperation; op.Value != 0 {
+		smart.SelfTestRunning = &dto.SMARTSelfTestProgress{
+			Type:            selfTestType(op.String),
+			ProgressPercent: out.NVMeSelfTestLog.CurrentCompletionPercent,
+		}
+	}
+
+	for _, entry := range out.NVMeSelfTestLog.Table {
+		smart.SelfTests = append(smart.SelfTests, dto.SMARTSelfTest{
+			Type:          selfTestType(entry.SelfTestCode.String),
+			Status:        entry.SelfTestResult.String,
+			Passed:        entry.SelfTestResult.Value == 0,
+			LifetimeHours: entry.PowerOnHours,
+			FirstErrorLBA: entry.LBA,
+		})
+	}
+}
+
+func parseSCSISMART(out *smartctlOutput, smart *dto.DiskSMART) {
+	if out.SCSIGrownDefectList != nil {
+		smart.ReallocatedSectors = *out.SCSIGrownDefectList
+	}
+	for _, counters := range out.SCSIErrorCounterLog {
+		smart.ErrorLogCount += counters.TotalUncorrectedErrors
+	}
+	if smart.PowerCycleCount == 0 {
+		smart.PowerCycleCount = out.SCSIStartStopCycleCounter.AccumulatedStartStopCycles
+	}
+}
+
+// selfTestType shortens the self-test types smartctl reports, such as "Extended offline" or
+// "Short", to "short", "extended", "conveyance" or "selective".
+func selfTestType(s string) string {
+	lower := strings.ToLower(s)
+	for _, kind := range []string{"short", "extended", "conveyance", "selective"} {
+		if strings.HasPrefix(lower, kind) {
+			return kind
+		}
+	}
+	return s
+}
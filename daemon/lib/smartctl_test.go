@@ -0,0 +1,226 @@
+package lib
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/dto"
+)
+
+func parseSmartctlFile(t *testing.T, name string) (*dto.DiskSMART, error) {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ParseSmartctl(data)
+}
+
+func TestParseSmartctlATA(t *testing.T) {
+	smart, err := parseSmartctlFile(t, "smartctl_ata.json")
+	if err != nil {
+		t.Fatalf("ParseSmartctl() error: %v", err)
+	}
+	if smart.Protocol != "ATA" || smart.Status != "PASSED" || smart.Model != "WDC  WUH721816ALE6L4" || smart.Temperature != 35 {
+		t.Errorf("unexpected identity: %+v", smart)
+	}
+	if smart.PowerOnHours != 28123 || smart.PowerCycleCount != 45 || smart.ErrorLogCount != 3 {
+		t.Errorf("hours = %d, cycles = %d, errors = %d", smart.PowerOnHours, smart.PowerCycleCount, smart.ErrorLogCount)
+	}
+	if smart.ReallocatedSectors != 8 || smart.PendingSectors != 2 || smart.OfflineUncorrectable != 0 {
+		t.Errorf("reallocated = %d, pending = %d, uncorrectable = %d", smart.ReallocatedSectors, smart.PendingSectors, smart.OfflineUncorrectable)
+	}
+
+	if len(smart.Attributes) != 11 {
+		t.Errorf("got %d attributes, want 11", len(smart.Attributes))
+	}
+	temp := smart.Attributes["Temperature_Celsius"]
+	if temp.ID != 194 || temp.Value != 58 || temp.Worst != 45 || temp.RawValue != "35 (Min/Max 20/45)" {
+		t.Errorf("unexpected temperature attribute: %+v", temp)
+	}
+	if smart.Attributes["Unknown_Attribute"].ID != 22 || smart.Attributes["Unknown_Attribute_240"].ID != 240 {
+		t.Error("attributes with the same name are not kept apart")
+	}
+
+	if len(smart.SelfTests) != 2 {
+		t.Fatalf("got %d self-tests, want 2", len(smart.SelfTests))
+	}
+	if st := smart.SelfTests[0]; st.Type != "short" || !st.Passed || st.LifetimeHours != 28100 || st.FirstErrorLBA != nil {
+		t.Errorf("unexpected self-test: %+v", st)
+	}
+	if st := smart.SelfTests[1]; st.Type != "extended" || st.Passed || st.RemainingPercent != 10 || st.FirstErrorLBA == nil || *st.FirstErrorLBA != 1234567890 {
+		t.Errorf("unexpected failed self-test: %+v", st)
+	}
+	if smart.SelfTestRunning != nil || smart.SelfTestMinutes["short"] != 2 || smart.SelfTestMinutes["extended"] != 1651 {
+		t.Errorf("running = %+v, minutes = %v", smart.SelfTestRunning, smart.SelfTestMinutes)
+	}
+}
+
+func TestParseSmartctlSelfTestRunning(t *testing.T) {
+	tests := []struct {
+		name, json, wantType string
+		wantProgress         int
+	}{
+		{
+			name:         "ATA",
+			json:         `{"device": {"protocol": "ATA"}, "ata_smart_data": {"self_test": {"status": {"value": 249, "string": "in progress, 90% remaining", "remaining_percent": 90}}}}`,
+			wantProgress: 10,
+		},
+		{
+			name:         "NVMe",
+			json:         `{"device": {"protocol": "NVMe"}, "nvme_smart_health_information_log": {}, "nvme_self_test_log": {"current_self_test_operation": {"value": 2, "string": "Extended self-test in progress"}, "current_self_test_completion_percent": 35}}`,
+			wantType:     "extended",
+			wantProgress: 35,
+		},
+	}
+	for _, tt := range tests {
+		smart, err := ParseSmartctl([]byte(tt.json))
+		if err != nil {
+			t.Fatalf("%s: ParseSmartctl() error: %v", tt.name, err)
+		}
+		if r := smart.SelfTestRunning; r == nil || r.Type != tt.wantType || r.ProgressPercent != tt.wantProgress {
+			t.Errorf("%s: running = %+v", tt.name, r)
+		}
+	}
+}
+
+func TestParseSmartctlNVMe(t *testing.T) {
+	smart, err := parseSmartctlFile(t, "smartctl_nvme.json")
+	if err != nil {
+		t.Fatalf("ParseSmartctl() error: %v", err)
+	}
+	if smart.Protocol != "NVMe" || smart.Status != "PASSED" || smart.PowerOnHours != 8760 || smart.PowerCycleCount != 120 || smart.ErrorLogCount != 42 {
+		t.Errorf("unexpected data: %+v", smart)
+	}
+	h := smart.NVMeHealth
+	if h == nil {
+		t.Fatal("no NVMe health log")
+	}
+	if h.PercentageUsed != 3 || h.AvailableSpare != 100 || h.AvailableSpareThreshold != 10 || h.UnsafeShutdowns != 17 || h.WarningTempMinutes != 5 {
+		t.Errorf("unexpected health log: %+v", h)
+	}
+	if h.BytesWritten != 23456789*512000 {
+		t.Errorf("bytes written = %d", h.BytesWritten)
+	}
+	if len(smart.SelfTests) != 2 || smart.SelfTests[0].Type != "extended" || !smart.SelfTests[0].Passed || smart.SelfTests[1].Passed {
+		t.Errorf("unexpected self-tests: %+v", smart.SelfTests)
+	}
+	if smart.Attributes != nil {
+		t.Errorf("NVMe device has ATA attributes: %v", smart.Attributes)
+	}
+}
+
+func TestParseSmartctlSAS(t *testing.T) {
+	smart, err := parseSmartctlFile(t, "smartctl_sas.json")
+	if err != nil {
+		t.Fatalf("ParseSmartctl() error: %v", err)
+	}
+	if smart.Model != "SEAGATE ST12000NM0027" || smart.Firmware != "E004" || smart.ReallocatedSectors != 12 || smart.ErrorLogCount != 1 || smart.PowerCycleCount != 88 {
+		t.Errorf("unexpected data: %+v", smart)
+	}
+}
+
+func TestParseSmartctlStandby(t *testing.T) {
+	if _, err := parseSmartctlFile(t, "smartctl_standby.json"); !errors.Is(err, ErrDiskStandby) || !errors.Is(err, ErrSMARTUnavailable) {
+		t.Errorf("error = %v, want ErrDiskStandby", err)
+	}
+	// A disk that dropped off the bus exits with the same status but is not in standby
+	if _, err := parseSmartctlFile(t, "smartctl_open_failed.json"); !errors.Is(err, ErrSMARTUnavailable) || errors.Is(err, ErrDiskStandby) {
+		t.Errorf("error = %v, want ErrSMARTUnavailable only", err)
+	}
+	if _, err := ParseSmartctl([]byte("smartctl: not json")); err == nil || errors.Is(err, ErrSMARTUnavailable) {
+		t.Errorf("error = %v, want a parse error", err)
+	}
+}
+
+func TestReadSMART(t *testing.T) {
+	dir := t.TempDir()
+	args := filepath.Join(dir, "args")
+	fixture, err := filepath.Abs(filepath.Join("testdata", "smartctl_ata.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// smartctl sets exit status bits for logged errors; the output is still valid
+	script := "#!/bin/sh\nprintf '%s\\n' \"$*\" >> " + args + "\ncat " + fixture + "\nexit 64\n"
+	smartctl := filepath.Join(dir, "smartctl")
+	if err := os.WriteFile(smartctl, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	smart, err := ReadSMART(smartctl, "sdc")
+	if err != nil {
+		t.Fatalf("ReadSMART() error: %v", err)
+	}
+	if smart.Device != "sdc" || smart.ReallocatedSectors != 8 {
+		t.Errorf("unexpected data: %+v", smart)
+	}
+	if _, err := ReadSMART(smartctl, "nvme0n1"); err != nil {
+		t.Fatalf("ReadSMART() error: %v", err)
+	}
+
+	data, err := os.ReadFile(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "-n standby -j -a /dev/sdc\n-j -a /dev/nvme0n1\n"
+	if string(data) != want {
+		t.Errorf("smartctl called with %q, want %q", data, want)
+	}
+}
+
+func TestSelfTestCommands(t *testing.T) {
+	dir := t.TempDir()
+	args := filepath.Join(dir, "args")
+	// Rejects tests on sdd, as a disk without self-test support does
+	script := `#!/bin/sh
+printf '%s\n' "$*" >> ` + args + `
+case "$*" in
+*/dev/sdd) echo '{"smartctl": {"exit_status": 4, "messages": [{"string": "Warning: device does not support Self-Test functions."}]}}' ;;
+*) echo '{"smartctl": {"exit_status": 64}}' ;;
+esac
+`
+	smartctl := filepath.Join(dir, "smartctl")
+	if err := os.WriteFile(smartctl, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := StartSelfTest(smartctl, "sdc", "extended"); err != nil {
+		t.Errorf("StartSelfTest() error: %v", err)
+	}
+	if err := StartSelfTest(smartctl, "nvme0n1", "short"); err != nil {
+		t.Errorf("StartSelfTest() error: %v", err)
+	}
+	if err := AbortSelfTest(smartctl, "sdc"); err != nil {
+		t.Errorf("AbortSelfTest() error: %v", err)
+	}
+	if err := StartSelfTest(smartctl, "sdd", "short"); err == nil || !strings.Contains(err.Error(), "does not support") {
+		t.Errorf("error = %v, want the smartctl message", err)
+	}
+	if err := StartSelfTest(smartctl, "sdc", "offline"); err == nil {
+		t.Error("invalid type accepted")
+	}
+	if err := StartSelfTest(smartctl, "nvme0n1", "conveyance"); err == nil {
+		t.Error("conveyance test started on an NVMe device")
+	}
+
+	data, err := os.ReadFile(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "-j -t long /dev/sdc\n-j -t short /dev/nvme0n1\n-j -X /dev/sdc\n-j -t short /dev/sdd\n"
+	if string(data) != want {
+		t.Errorf("smartctl called with %q, want %q", data, want)
+	}
+}
+
+func TestNormalizeSelfTestType(t *testing.T) {
+	for in, want := range map[string]string{"short": "short", "Long": "extended", "extended": "extended", "conveyance": "conveyance", "selective": ""} {
+		got, err := NormalizeSelfTestType(in)
+		if got != want || (err != nil) != (want == "") {
+			t.Errorf("NormalizeSelfTestType(%q) = %q, %v", in, got, err)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package lib
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// SelfSignedCertValidity is how long generated self-signed certificates remain valid.
+const SelfSignedCertValidity = 825 * 24 * time.Hour
+
+// EnsureSelfSignedCert generates a self-signed certificate and private key at the given paths
+// unless both files already exist. Returns true if a new certificate was generated.
+// The certificate covers localhost, the system hostname, and all local unicast IP addresses.
+func EnsureSelfSignedCert(certPath, keyPath string) (bool, error) {
+	if FileExists(certPath) && FileExists(keyPath) {
+		return false, nil
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unraid"
+	}
+
+	dnsNames := []string{"localhost", hostname}
+	ips := []net.IP{net.ParseIP("127.0.0.1"), net.ParseIP("::1")}
+	if addrs, err := net.InterfaceAddrs(); err == nil {
+		for _, addr := range addrs {
+			if ipNet, ok := addr.(*net.IPNet); ok && !ipNet.IP.IsLoopback() && !ipNet.IP.IsLinkLocalUnicast() {
+				ips = append(ips, ipNet.IP)
+			}
+		}
+	}
+
+	certPEM, keyPEM, err := GenerateSelfSignedCert(hostname, dnsNames, ips, SelfSignedCertValidity)
+	if err != nil {
+		return false, err
+	}
+
+	// #nosec G301 - Unraid standard permissions (0755 for directories)
+	if err := os.MkdirAll(filepath.Dir(certPath), 0755); err != nil {
+		return false, fmt.Errorf("failed to create certificate directory: %w", err)
+	}
+	// #nosec G301 - Unraid standard permissions (0755 for directories)
+	if err := os.MkdirAll(filepath.Dir(keyPath), 0755); err != nil {
+		return false, fmt.Errorf("failed to create key directory: %w", err)
+	}
+
+	// #nosec G306 - Certificates are public (0644)
+	if err := os.WriteFile(certPath, certPEM, 0644); err != nil {
+		return false, fmt.Errorf("failed to write certificate: %w", err)
+	}
+	if err := os.WriteFile(keyPath, keyPEM, 0600); err != nil {
+		return false, fmt.Errorf("failed to write private key: %w", err)
+	}
+
+	return true, nil
+}
+
+// GenerateSelfSignedCert creates a PEM-encoded ECDSA P-256 certificate and private key.
+func GenerateSelfSignedCert(commonName string, dnsNames []string, ips []net.IP, validity time.Duration) ([]byte, []byte, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate private key: %w", err)
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate serial number: %w", err)
+	}
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject: pkix.Name{
+			CommonName:   commonName,
+			Organization: []string{"Unraid Management Agent"},
+		},
+		NotBefore:             now.Add(-1 * time.Hour),
+		NotAfter:              now.Add(validity),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		DNSNames:              dnsNames,
+		IPAddresses:           ips,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create certificate: %w", err)
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encode private key: %w", err)
+	}
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	return certPEM, keyPEM, nil
+}
+
+// LoadCertPool reads a PEM bundle of CA certificates into a certificate pool.
+func LoadCertPool(path string) (*x509.CertPool, error) {
+	// #nosec G304 - the CA bundle the administrator passed with --tls-client-ca
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no valid certificates found in %s", path)
+	}
+	return pool, nil
+}
@@ -0,0 +1,103 @@
+package lib
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestGenerateSelfSignedCert(t *testing.T) {
+	certPEM, keyPEM, err := GenerateSelfSignedCert("tower", []string{"tower", "localhost"}, []net.IP{net.ParseIP("192.168.1.10")}, time.Hour)
+	if err != nil {
+		t.Fatalf("GenerateSelfSignedCert() error = %v", err)
+	}
+
+	pair, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatalf("generated certificate and key do not match: %v", err)
+	}
+
+	cert, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		t.Fatalf("failed to parse certificate: %v", err)
+	}
+
+	if err := cert.VerifyHostname("tower"); err != nil {
+		t.Errorf("certificate not valid for hostname: %v", err)
+	}
+	if err := cert.VerifyHostname("192.168.1.10"); err != nil {
+		t.Errorf("certificate not valid for IP: %v", err)
+	}
+	if cert.NotAfter.After(time.Now().Add(2 * time.Hour)) {
+		t.Errorf("certificate validity %v exceeds requested duration", cert.NotAfter)
+	}
+}
+
+func TestEnsureSelfSignedCert(t *testing.T) {
+	dir := t.TempDir()
+	certPath := filepath.Join(dir, "tls", "agent.crt")
+	keyPath := filepath.Join(dir, "tls", "agent.key")
+
+	generated, err := EnsureSelfSignedCert(certPath, keyPath)
+	if err != nil {
+		t.Fatalf("EnsureSelfSignedCert() error = %v", err)
+	}
+	if !generated {
+		t.Error("expected a certificate to be generated on first call")
+	}
+
+	info, err := os.Stat(keyPath)
+	if err != nil {
+		t.Fatalf("private key not written: %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("private key permissions = %o, want 600", perm)
+	}
+
+	original, _ := os.ReadFile(certPath)
+
+	generated, err = EnsureSelfSignedCert(certPath, keyPath)
+	if err != nil {
+		t.Fatalf("EnsureSelfSignedCert() second call error = %v", err)
+	}
+	if generated {
+		t.Error("existing certificate should not be regenerated")
+	}
+
+	current, _ := os.ReadFile(certPath)
+	if string(original) != string(current) {
+		t.Error("existing certificate was overwritten")
+	}
+}
+
+func TestLoadCertPool(t *testing.T) {
+	dir := t.TempDir()
+
+	certPEM, _, err := GenerateSelfSignedCert("ca", nil, nil, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	valid := filepath.Join(dir, "ca.pem")
+	if err := os.WriteFile(valid, certPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadCertPool(valid); err != nil {
+		t.Errorf("LoadCertPool() error = %v", err)
+	}
+
+	invalid := filepath.Join(dir, "invalid.pem")
+	if err := os.WriteFile(invalid, []byte("not a certificate"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadCertPool(invalid); err == nil {
+		t.Error("LoadCertPool() expected error for invalid bundle")
+	}
+
+	if _, err := LoadCertPool(filepath.Join(dir, "missing.pem")); err == nil {
+		t.Error("LoadCertPool() expected error for missing file")
+	}
+}
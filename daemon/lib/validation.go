@@ -26,6 +26,15 @@ var (
 	// User script names: alphanumeric, hyphens, underscores, dots (max 255 chars)
 	// Must not contain path separators or parent directory references
 	userScriptNameRegex = regexp.MustCompile(`^[a-zA-Z0-9_.-]{1,255}$`)
+
+	// Container names: the Docker Engine's rule, starting with an alphanumeric character
+	containerNameRegex = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]+$`)
+
+	// Compose project names: lowercase alphanumerics, hyphens and underscores
+	stackNameRegex = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+	// VM snapshot names: alphanumerics, dots, hyphens and underscores, usable in overlay file names
+	snapshotNameRegex = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
 )
 
 // ValidateContainerID validates a Docker container ID format
@@ -46,6 +55,61 @@ func ValidateContainerID(id string) error {
 	return fmt.Errorf("invalid container ID format: must be 12 or 64 hexadecimal characters")
 }
 
+// ValidateContainerName validates a Docker container name
+// Names must start with an alphanumeric character followed by alphanumerics, underscores,
+// dots or hyphens, which also rules out path separators
+func ValidateContainerName(name string) error {
+	if name == "" {
+		return fmt.Errorf("container name cannot be empty")
+	}
+
+	if len(name) > 255 {
+		return fmt.Errorf("container name too long: maximum 255 characters, got %d", len(name))
+	}
+
+	if !containerNameRegex.MatchString(name) {
+		return fmt.Errorf("invalid container name format: must start with an alphanumeric character and contain only alphanumeric characters, underscores, dots, and hyphens")
+	}
+
+	return nil
+}
+
+// ValidateStackName validates a Docker Compose project name
+// Compose normalises project names to lowercase alphanumerics, hyphens and underscores
+func ValidateStackName(name string) error {
+	if name == "" {
+		return fmt.Errorf("stack name cannot be empty")
+	}
+
+	if len(name) > 255 {
+		return fmt.Errorf("stack name too long: maximum 255 characters, got %d", len(name))
+	}
+
+	if !stackNameRegex.MatchString(name) {
+		return fmt.Errorf("invalid stack name format: must start with a lowercase letter or digit and contain only lowercase letters, digits, hyphens, and underscores")
+	}
+
+	return nil
+}
+
+// ValidateSnapshotName validates a VM snapshot name
+// Disk-only snapshots use the name as the extension of their overlay files
+func ValidateSnapshotName(name string) error {
+	if name == "" {
+		return fmt.Errorf("snapshot name cannot be empty")
+	}
+
+	if len(name) > 128 {
+		return fmt.Errorf("snapshot name too long: maximum 128 characters, got %d", len(name))
+	}
+
+	if !snapshotNameRegex.MatchString(name) {
+		return fmt.Errorf("invalid snapshot name format: must start with a letter or digit and contain only letters, digits, dots, hyphens, and underscores")
+	}
+
+	return nil
+}
+
 // ValidateVMName validates a virtual machine name
 // Allows alphanumeric characters, spaces, hyphens, underscores, and dots
 // Maximum length: 253 characters (DNS hostname limit)
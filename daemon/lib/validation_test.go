@@ -85,6 +85,89 @@ func TestValidateContainerID(t *testing.T) {
 	}
 }
 
+func TestValidateContainerName(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		wantErr bool
+	}{
+		{name: "simple name", input: "plex", wantErr: false},
+		{name: "name with separators", input: "binhex-qbittorrent_vpn.2", wantErr: false},
+		{name: "mixed case", input: "Plex-Media-Server", wantErr: false},
+		{name: "empty", input: "", wantErr: true},
+		{name: "single character", input: "a", wantErr: true},
+		{name: "leading hyphen", input: "-plex", wantErr: true},
+		{name: "leading dot", input: ".plex", wantErr: true},
+		{name: "path traversal", input: "../plex", wantErr: true},
+		{name: "slash", input: "media/plex", wantErr: true},
+		{name: "space", input: "plex server", wantErr: true},
+		{name: "too long", input: strings.Repeat("a", 256), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateContainerName(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateContainerName(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateStackName(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		wantErr bool
+	}{
+		{name: "simple name", input: "paperless", wantErr: false},
+		{name: "single character", input: "a", wantErr: false},
+		{name: "name with separators", input: "immich_app-2", wantErr: false},
+		{name: "empty", input: "", wantErr: true},
+		{name: "uppercase", input: "Paperless", wantErr: true},
+		{name: "leading hyphen", input: "-paperless", wantErr: true},
+		{name: "dot", input: "paperless.ngx", wantErr: true},
+		{name: "path traversal", input: "../paperless", wantErr: true},
+		{name: "too long", input: strings.Repeat("a", 256), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateStackName(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateStackName(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateSnapshotName(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		wantErr bool
+	}{
+		{name: "simple name", input: "pre-update", wantErr: false},
+		{name: "version", input: "before-2024.11", wantErr: false},
+		{name: "timestamp", input: "1730800000", wantErr: false},
+		{name: "empty", input: "", wantErr: true},
+		{name: "space", input: "pre update", wantErr: true},
+		{name: "leading dot", input: ".hidden", wantErr: true},
+		{name: "path traversal", input: "../vdisk1", wantErr: true},
+		{name: "xml", input: "a<b>", wantErr: true},
+		{name: "too long", input: strings.Repeat("a", 129), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateSnapshotName(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateSnapshotName(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+		})
+	}
+}
+
 func TestValidateVMName(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -0,0 +1,345 @@
+package alerts
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cskr/pubsub"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/constants"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/dto"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/logger"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/services/controllers"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/services/metrics"
+)
+
+// Alert states reported in events and status.
+const (
+	StatePending  = "pending"
+	StateFiring   = "firing"
+	StateResolved = "resolved"
+)
+
+// An entity that stops reporting, such as a disk that spun down or a container that was removed,
+// expires after staleIntervals of its own report interval. defaultStaleAge applies while the
+// interval is unknown because the entity reported only once.
+const (
+	staleIntervals     = 3
+	defaultStaleAge    = 15 * time.Minute
+	staleCheckInterval = 30 * time.Second
+)
+
+type stateKey struct {
+	RuleID string
+	Entity string
+}
+
+// ruleState tracks one rule for one entity.
+type ruleState struct {
+	pendingSince time.Time // when the condition started to hold; zero when it does not
+	firing       bool
+	firingSince  time.Time
+	lastFired    time.Time
+	value        float64
+	lastSeen     time.Time     // when the entity last reported
+	interval     time.Duration // time between its last two reports
+}
+
+// Engine holds the alert rules and evaluates them against metric samples.
+type Engine struct {
+	path   string
+	hub    *pubsub.PubSub
+	mu     sync.Mutex
+	rules  []dto.AlertRule
+	states map[stateKey]*ruleState
+	now    func() time.Time
+
+	// notify creates an Unraid notification; replaced in tests
+	notify func(title, subject, description, importance, link string) error
+}
+
+// NewEngine loads the rules stored at path. An empty path keeps rules in memory only.
+func NewEngine(path string, hub *pubsub.PubSub) (*Engine, error) {
+	rules, err := loadRules(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Engine{
+		path:   path,
+		hub:    hub,
+		rules:  rules,
+		states: make(map[stateKey]*ruleState),
+		now:    time.Now,
+		notify: controllers.CreateNotification,
+	}, nil
+}
+
+// Run evaluates every collector event against the rules until ctx is cancelled.
+func (e *Engine) Run(ctx context.Context) {
+	ch := e.hub.Sub(constants.CollectorTopics()...)
+	logger.Info("Alerts: Evaluating %d rules", len(e.Rules()))
+
+	ticker := time.NewTicker(staleCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Info("Alert engine stopping due to context cancellation")
+			e.hub.Unsub(ch)
+			return
+		case msg := <-ch:
+			for _, event := range e.Evaluate(metrics.FromEvent(msg)) {
+				e.dispatch(event)
+			}
+		case <-ticker.C:
+			for _, event := range e.Expire() {
+				e.dispatch(event)
+			}
+		}
+	}
+}
+
+// Evaluate applies the samples to every enabled rule and returns the resulting firing and
+// resolved events. A rule fires once its condition has held for ForSeconds and its cooldown
+// has elapsed, and resolves once the value is past the threshold by at least Hysteresis.
+func (e *Engine) Evaluate(samples []metrics.Sample) []*dto.AlertEvent {
+	if len(samples) == 0 {
+		return nil
+	}
+	now := e.now()
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var events []*dto.AlertEvent
+	for _, rule := range e.rules {
+		if !rule.Enabled {
+			continue
+		}
+		for _, sample := range samples {
+			if sample.Metric != rule.Metric || (rule.Entity != "" && sample.Entity != rule.Entity) {
+				continue
+			}
+
+			key := stateKey{RuleID: rule.ID, Entity: sample.Entity}
+			state, ok := e.states[key]
+			if !ok {
+				state = &ruleState{}
+				e.states[key] = state
+			}
+			state.value = sample.Value
+			if !state.lastSeen.IsZero() && now.After(state.lastSeen) {
+				state.interval = now.Sub(state.lastSeen)
+			}
+			state.lastSeen = now
+
+			if state.firing {
+				if operators[rule.Operator](sample.Value, resolveThreshold(rule)) {
+					continue
+				}
+				state.firing = false
+				state.pendingSince = time.Time{}
+				events = append(events, newEvent(rule, sample, StateResolved, now))
+				continue
+			}
+
+			if !operators[rule.Operator](sample.Value, rule.Threshold) {
+				state.pendingSince = time.Time{}
+				continue
+			}
+			if state.pendingSince.IsZero() {
+				state.pendingSince = now
+			}
+			if now.Sub(state.pendingSince) < time.Duration(rule.ForSeconds)*time.Second {
+				continue
+			}
+			if !state.lastFired.IsZero() && now.Sub(state.lastFired) < time.Duration(rule.CooldownSeconds)*time.Second {
+				continue
+			}
+			state.firing = true
+			state.firingSince = now
+			state.lastFired = now
+			events = append(events, newEvent(rule, sample, StateFiring, now))
+		}
+	}
+	return events
+}
+
+// Expire drops the state of entities that stopped reporting, so a rule does not stay pending or
+// firing for a disk that spun down or a container that was removed. Firing alerts are resolved.
+func (e *Engine) Expire() []*dto.AlertEvent {
+	now := e.now()
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	rules := make(map[string]dto.AlertRule, len(e.rules))
+	for _, rule := range e.rules {
+		rules[rule.ID] = rule
+	}
+
+	var events []*dto.AlertEvent
+	for key, state := range e.states {
+		maxAge := defaultStaleAge
+		if state.interval > 0 {
+			maxAge = staleIntervals * state.interval
+		}
+		if now.Sub(state.lastSeen) <= maxAge {
+			continue
+		}
+
+		rule := rules[key.RuleID]
+		if state.firing {
+			event := newEvent(rule, metrics.Sample{Metric: rule.Metric, Entity: key.Entity, Value: state.value}, StateResolved, now)
+			event.Message = fmt.Sprintf("%s is no longer reported (last value %s)", subjectOf(rule, key.Entity), formatValue(state.value))
+			events = append(events, event)
+		}
+		// Keep the firing time while the cooldown runs, so an entity that comes back does not fire
+		// again right away
+		if !state.lastFired.IsZero() && now.Sub(state.lastFired) < time.Duration(rule.CooldownSeconds)*time.Second {
+			state.firing = false
+			state.pendingSince = time.Time{}
+			state.lastSeen, state.interval = time.Time{}, 0
+			continue
+		}
+		delete(e.states, key)
+	}
+	return events
+}
+
+// resolveThreshold shifts the threshold by the rule's hysteresis so a value hovering around
+// the threshold does not flap between firing and resolved.
+func resolveThreshold(rule dto.AlertRule) float64 {
+	switch rule.Operator {
+	case ">", ">=":
+		return rule.Threshold - rule.Hysteresis
+	case "<", "<=":
+		return rule.Threshold + rule.Hysteresis
+	}
+	return rule.Threshold
+}
+
+func newEvent(rule dto.AlertRule, sample metrics.Sample, state string, now time.Time) *dto.AlertEvent {
+	subject := subjectOf(rule, sample.Entity)
+
+	var message string
+	if state == StateFiring {
+		message = fmt.Sprintf("%s is %s (%s %s", subject, formatValue(sample.Value), rule.Operator, formatValue(rule.Threshold))
+		if rule.ForSeconds > 0 {
+			message += fmt.Sprintf(" for %s", time.Duration(rule.ForSeconds)*time.Second)
+		}
+		message += ")"
+	} else {
+		message = fmt.Sprintf("%s returned to normal at %s", subject, formatValue(sample.Value))
+	}
+
+	return &dto.AlertEvent{
+		RuleID:     rule.ID,
+		RuleName:   rule.Name,
+		Metric:     rule.Metric,
+		Entity:     sample.Entity,
+		State:      state,
+		Value:      sample.Value,
+		Operator:   rule.Operator,
+		Threshold:  rule.Threshold,
+		Importance: rule.Importance,
+		Message:    message,
+		Timestamp:  now.UTC(),
+	}
+}
+
+// subjectOf names the metric and entity an alert is about, such as "disk5 disk_temperature_celsius".
+func subjectOf(rule dto.AlertRule, entity string) string {
+	if entity == "" {
+		return rule.Metric
+	}
+	return entity + " " + rule.Metric
+}
+
+func formatValue(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// dispatch raises the Unraid notification and publishes the event on the hub, where the
+// webhook dispatcher picks it up for targets subscribed to alert_event. Resolutions are sent as
+// info notifications.
+func (e *Engine) dispatch(event *dto.AlertEvent) {
+	logger.Info("Alerts: %s %s: %s", event.RuleName, event.State, event.Message)
+
+	importance := event.Importance
+	if event.State == StateResolved {
+		importance = ImportanceInfo
+	}
+	// The title becomes part of the notification filename, so include the entity to keep
+	// simultaneous alerts for several disks or containers apart
+	title := event.RuleName
+	if event.Entity != "" {
+		title += " " + event.Entity
+	}
+	subject := fmt.Sprintf("Alert %s: %s", event.State, event.RuleName)
+	if err := e.notify(title, subject, event.Message, importance, ""); err != nil {
+		logger.Error("Alerts: Failed to create notification for %s: %v", event.RuleName, err)
+	}
+
+	if e.hub != nil {
+		e.hub.Pub(event, constants.TopicAlertEvent)
+	}
+}
+
+// Status returns the rules that are currently pending or firing, per entity.
+func (e *Engine) Status() []dto.AlertStatus {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	names := make(map[string]dto.AlertRule, len(e.rules))
+	for _, rule := range e.rules {
+		names[rule.ID] = rule
+	}
+
+	list := []dto.AlertStatus{}
+	for key, state := range e.states {
+		rule, ok := names[key.RuleID]
+		if !ok {
+			continue
+		}
+		status := dto.AlertStatus{
+			RuleID:   rule.ID,
+			RuleName: rule.Name,
+			Metric:   rule.Metric,
+			Entity:   key.Entity,
+			Value:    state.value,
+		}
+		switch {
+		case state.firing:
+			status.State = StateFiring
+			status.Since = state.firingSince.UTC()
+		case !state.pendingSince.IsZero():
+			status.State = StatePending
+			status.Since = state.pendingSince.UTC()
+		default:
+			continue
+		}
+		list = append(list, status)
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].RuleName != list[j].RuleName {
+			return list[i].RuleName < list[j].RuleName
+		}
+		return list[i].Entity < list[j].Entity
+	})
+	return list
+}
+
+// resetState forgets the state of a changed or deleted rule. Callers hold e.mu.
+func (e *Engine) resetState(ruleID string) {
+	for key := range e.states {
+		if key.RuleID == ruleID {
+			delete(e.states, key)
+		}
+	}
+}
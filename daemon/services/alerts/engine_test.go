@@ -0,0 +1,311 @@
+package alerts
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cskr/pubsub"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/constants"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/dto"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/services/metrics"
+)
+
+// testEngine returns an in-memory engine with a controllable clock.
+func testEngine(t *testing.T, rules ...dto.AlertRule) (*Engine, *time.Time) {
+	t.Helper()
+	engine, err := NewEngine("", nil)
+	if err != nil {
+		t.Fatalf("NewEngine error: %v", err)
+	}
+	now := time.Unix(1_700_000_000, 0)
+	engine.now = func() time.Time { return now }
+	for _, rule := range rules {
+		if _, err := engine.CreateRule(rule); err != nil {
+			t.Fatalf("CreateRule error: %v", err)
+		}
+	}
+	return engine, &now
+}
+
+func diskTemp(entity string, value float64) []metrics.Sample {
+	return []metrics.Sample{{Metric: metrics.DiskTemperatureCelsius, Entity: entity, Value: value}}
+}
+
+func TestEvaluateForDurationAndHysteresis(t *testing.T) {
+	rule := NewRule()
+	rule.Name = "Hot disk"
+	rule.Metric = metrics.DiskTemperatureCelsius
+	rule.Operator = ">"
+	rule.Threshold = 50
+	rule.ForSeconds = 300
+	rule.Hysteresis = 3
+	engine, now := testEngine(t, rule)
+
+	steps := []struct {
+		advance time.Duration
+		value   float64
+		want    string // expected event state, empty for none
+	}{
+		{0, 52, ""},                    // pending
+		{2 * time.Minute, 40, ""},      // condition cleared, pending reset
+		{time.Minute, 55, ""},          // pending again
+		{4 * time.Minute, 56, ""},      // 4 minutes, not yet
+		{time.Minute, 53, StateFiring}, // 5 minutes
+		{time.Minute, 49, ""},          // below threshold but within hysteresis
+		{time.Minute, 47, StateResolved},
+	}
+
+	for i, step := range steps {
+		*now = now.Add(step.advance)
+		events := engine.Evaluate(diskTemp("disk5", step.value))
+		got := ""
+		if len(events) > 0 {
+			got = events[0].State
+		}
+		if got != step.want || len(events) > 1 {
+			t.Fatalf("step %d (value %.0f): events = %+v, want %q", i, step.value, events, step.want)
+		}
+		if got == StateFiring && (events[0].Entity != "disk5" || events[0].Importance != ImportanceWarning) {
+			t.Errorf("firing event = %+v", events[0])
+		}
+	}
+}
+
+func TestEvaluateCooldown(t *testing.T) {
+	rule := NewRule()
+	rule.Name = "UPS on battery"
+	rule.Metric = metrics.UPSOnBattery
+	rule.Operator = "=="
+	rule.Threshold = 1
+	rule.CooldownSeconds = 600
+	engine, now := testEngine(t, rule)
+
+	onBattery := []metrics.Sample{{Metric: metrics.UPSOnBattery, Value: 1}}
+	online := []metrics.Sample{{Metric: metrics.UPSOnBattery, Value: 0}}
+
+	if events := engine.Evaluate(onBattery); len(events) != 1 || events[0].State != StateFiring {
+		t.Fatalf("expected firing event, got %+v", events)
+	}
+	*now = now.Add(time.Minute)
+	if events := engine.Evaluate(online); len(events) != 1 || events[0].State != StateResolved {
+		t.Fatalf("expected resolved event, got %+v", events)
+	}
+	*now = now.Add(time.Minute)
+	if events := engine.Evaluate(onBattery); len(events) != 0 {
+		t.Fatalf("expected no event during cooldown, got %+v", events)
+	}
+	*now = now.Add(10 * time.Minute)
+	if events := engine.Evaluate(onBattery); len(events) != 1 || events[0].State != StateFiring {
+		t.Fatalf("expected firing event after cooldown, got %+v", events)
+	}
+}
+
+func TestExpireStaleStates(t *testing.T) {
+	rule := NewRule()
+	rule.Name = "Hot disk"
+	rule.Metric = metrics.DiskTemperatureCelsius
+	rule.Operator = ">"
+	rule.Threshold = 50
+	rule.CooldownSeconds = 3600
+	pending := rule
+	pending.Name = "Warm disk"
+	pending.Threshold = 45
+	pending.ForSeconds = 3600
+	engine, now := testEngine(t, rule, pending)
+
+	// disk5 reports every 30 seconds and fires; disk6 reports once and only goes pending
+	engine.Evaluate(append(diskTemp("disk5", 55), diskTemp("disk6", 48)...))
+	*now = now.Add(30 * time.Second)
+	engine.Evaluate(diskTemp("disk5", 56))
+	if got := len(engine.Status()); got != 3 {
+		t.Fatalf("expected 3 pending or firing alerts, got %d", got)
+	}
+
+	// disk5 spins down and stops reporting its temperature
+	*now = now.Add(time.Minute)
+	if events := engine.Expire(); len(events) != 0 {
+		t.Fatalf("expired after two missed reports: %+v", events)
+	}
+	*now = now.Add(time.Minute)
+	events := engine.Expire()
+	if len(events) != 1 || events[0].State != StateResolved || events[0].Entity != "disk5" || events[0].Message != "disk5 disk_temperature_celsius is no longer reported (last value 56)" {
+		t.Fatalf("expected disk5 to resolve, got %+v", events)
+	}
+	if status := engine.Status(); len(status) != 1 || status[0].Entity != "disk6" {
+		t.Fatalf("unexpected alerts: %+v", status)
+	}
+
+	// disk6 never reported again and its report interval is unknown
+	*now = now.Add(defaultStaleAge)
+	if events := engine.Expire(); len(events) != 0 || len(engine.Status()) != 0 {
+		t.Fatalf("expected disk6 to expire quietly, got %+v, %+v", events, engine.Status())
+	}
+
+	// disk5 spins up again within the cooldown and does not fire again yet
+	*now = now.Add(time.Minute)
+	if events := engine.Evaluate(diskTemp("disk5", 57)); len(events) != 0 {
+		t.Fatalf("fired again during the cooldown: %+v", events)
+	}
+}
+
+func TestEvaluateEntityFilterAndDisabled(t *testing.T) {
+	stopped := NewRule()
+	stopped.Name = "Plex stopped"
+	stopped.Metric = metrics.ContainerRunning
+	stopped.Entity = "plex"
+	stopped.Operator = "=="
+	stopped.Threshold = 0
+
+	disabled := stopped
+	disabled.Name = "Disabled"
+	disabled.Enabled = false
+
+	engine, _ := testEngine(t, stopped, disabled)
+	events := engine.Evaluate([]metrics.Sample{
+		{Metric: metrics.ContainerRunning, Entity: "plex", Value: 0},
+		{Metric: metrics.ContainerRunning, Entity: "sonarr", Value: 0},
+	})
+	if len(events) != 1 || events[0].Entity != "plex" || events[0].RuleName != "Plex stopped" {
+		t.Fatalf("events = %+v, want one firing for plex", events)
+	}
+
+	status := engine.Status()
+	if len(status) != 1 || status[0].State != StateFiring || status[0].Entity != "plex" {
+		t.Errorf("Status() = %+v", status)
+	}
+}
+
+func TestValidateRule(t *testing.T) {
+	valid := func() dto.AlertRule {
+		rule := NewRule()
+		rule.Name = "rule"
+		rule.Metric = metrics.CPUUsagePercent
+		rule.Operator = ">="
+		rule.Threshold = 90
+		return rule
+	}
+
+	tests := []struct {
+		name    string
+		modify  func(*dto.AlertRule)
+		wantErr bool
+	}{
+		{"valid", func(*dto.AlertRule) {}, false},
+		{"empty name", func(r *dto.AlertRule) { r.Name = " " }, true},
+		{"unknown metric", func(r *dto.AlertRule) { r.Metric = "cpu" }, true},
+		{"bad operator", func(r *dto.AlertRule) { r.Operator = "=>" }, true},
+		{"negative for", func(r *dto.AlertRule) { r.ForSeconds = -1 }, true},
+		{"bad importance", func(r *dto.AlertRule) { r.Importance = "critical" }, true},
+		{"empty importance defaults", func(r *dto.AlertRule) { r.Importance = "" }, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := valid()
+			tt.modify(&rule)
+			err := ValidateRule(&rule)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateRule() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRulePersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "alerts.json")
+	engine, err := NewEngine(path, nil)
+	if err != nil {
+		t.Fatalf("NewEngine error: %v", err)
+	}
+
+	rule := NewRule()
+	rule.Name = "Array full"
+	rule.Metric = metrics.ArrayUsedPercent
+	rule.Operator = ">"
+	rule.Threshold = 90
+	created, err := engine.CreateRule(rule)
+	if err != nil {
+		t.Fatalf("CreateRule error: %v", err)
+	}
+
+	created.Threshold = 95
+	if _, err := engine.UpdateRule(created.ID, created); err != nil {
+		t.Fatalf("UpdateRule error: %v", err)
+	}
+
+	reloaded, err := NewEngine(path, nil)
+	if err != nil {
+		t.Fatalf("reload error: %v", err)
+	}
+	got, err := reloaded.Rule(created.ID)
+	if err != nil || got.Threshold != 95 || got.Name != "Array full" {
+		t.Fatalf("reloaded rule = %+v, %v", got, err)
+	}
+
+	if err := reloaded.DeleteRule(created.ID); err != nil {
+		t.Fatalf("DeleteRule error: %v", err)
+	}
+	if err := reloaded.DeleteRule(created.ID); !errors.Is(err, ErrRuleNotFound) {
+		t.Errorf("second DeleteRule error = %v, want ErrRuleNotFound", err)
+	}
+	if _, err := reloaded.UpdateRule("missing", created); !errors.Is(err, ErrRuleNotFound) {
+		t.Errorf("UpdateRule(missing) error = %v, want ErrRuleNotFound", err)
+	}
+}
+
+func TestRunDispatchesNotificationAndEvent(t *testing.T) {
+	hub := pubsub.New(16)
+	engine, err := NewEngine("", hub)
+	if err != nil {
+		t.Fatalf("NewEngine error: %v", err)
+	}
+
+	var mu sync.Mutex
+	var notifications []string
+	engine.notify = func(title, subject, description, importance, link string) error {
+		mu.Lock()
+		defer mu.Unlock()
+		notifications = append(notifications, importance+": "+subject)
+		return nil
+	}
+
+	rule := NewRule()
+	rule.Name = "Pool degraded"
+	rule.Metric = metrics.ZFSPoolOnline
+	rule.Operator = "=="
+	rule.Threshold = 0
+	rule.Importance = ImportanceAlert
+	if _, err := engine.CreateRule(rule); err != nil {
+		t.Fatalf("CreateRule error: %v", err)
+	}
+
+	alertsCh := hub.Sub(constants.TopicAlertEvent)
+	defer hub.Unsub(alertsCh)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go engine.Run(ctx)
+	time.Sleep(50 * time.Millisecond) // let Run subscribe
+
+	hub.Pub([]dto.ZFSPool{{Name: "tank", Health: "DEGRADED"}}, constants.TopicZFSPoolsUpdate)
+
+	select {
+	case msg := <-alertsCh:
+		event, ok := msg.(*dto.AlertEvent)
+		if !ok || event.State != StateFiring || event.Entity != "tank" {
+			t.Fatalf("hub event = %#v", msg)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for alert event on the hub")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(notifications) != 1 || notifications[0] != "alert: Alert firing: Pool degraded" {
+		t.Errorf("notifications = %v", notifications)
+	}
+}
@@ -0,0 +1,195 @@
+// Package alerts evaluates threshold rules against collector metrics and raises an Unraid
+// notification and an alert event when a rule fires or resolves. Webhook targets subscribed to
+// alert events deliver them to HTTP endpoints.
+package alerts
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/dto"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/lib"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/services/metrics"
+)
+
+// ErrRuleNotFound is returned when a rule ID does not exist.
+var ErrRuleNotFound = errors.New("alert rule not found")
+
+// Importance levels accepted by Unraid notifications.
+const (
+	ImportanceAlert   = "alert"
+	ImportanceWarning = "warning"
+	ImportanceInfo    = "info"
+)
+
+var operators = map[string]func(value, threshold float64) bool{
+	">":  func(v, t float64) bool { return v > t },
+	">=": func(v, t float64) bool { return v >= t },
+	"<":  func(v, t float64) bool { return v < t },
+	"<=": func(v, t float64) bool { return v <= t },
+	"==": func(v, t float64) bool { return v == t },
+	"!=": func(v, t float64) bool { return v != t },
+}
+
+// NewRule returns a rule with the defaults applied to omitted fields of a create request.
+func NewRule() dto.AlertRule {
+	return dto.AlertRule{Enabled: true, Importance: ImportanceWarning}
+}
+
+// ValidateRule normalizes and checks a rule.
+func ValidateRule(rule *dto.AlertRule) error {
+	rule.Name = strings.TrimSpace(rule.Name)
+	rule.Metric = strings.TrimSpace(rule.Metric)
+	rule.Entity = strings.TrimSpace(rule.Entity)
+	rule.Importance = strings.ToLower(strings.TrimSpace(rule.Importance))
+
+	if rule.Name == "" {
+		return fmt.Errorf("rule name cannot be empty")
+	}
+	if len(rule.Name) > 64 {
+		return fmt.Errorf("rule name too long: maximum 64 characters, got %d", len(rule.Name))
+	}
+	if !metrics.IsKnown(rule.Metric) {
+		return fmt.Errorf("unknown metric: %s", rule.Metric)
+	}
+	if _, ok := operators[rule.Operator]; !ok {
+		return fmt.Errorf("invalid operator: %s (must be >, >=, <, <=, == or !=)", rule.Operator)
+	}
+	if rule.ForSeconds < 0 || rule.CooldownSeconds < 0 || rule.Hysteresis < 0 {
+		return fmt.Errorf("for_seconds, cooldown_seconds and hysteresis cannot be negative")
+	}
+	if rule.Importance == "" {
+		rule.Importance = ImportanceWarning
+	}
+	if rule.Importance != ImportanceAlert && rule.Importance != ImportanceWarning && rule.Importance != ImportanceInfo {
+		return fmt.Errorf("invalid importance level: %s (must be alert, warning, or info)", rule.Importance)
+	}
+	return nil
+}
+
+// loadRules reads the rule file. A missing file yields no rules.
+func loadRules(path string) ([]dto.AlertRule, error) {
+	var rules []dto.AlertRule
+	if _, err := lib.ReadJSONFile(path, &rules); err != nil {
+		return nil, fmt.Errorf("failed to load alert rules: %w", err)
+	}
+	for i := range rules {
+		if err := ValidateRule(&rules[i]); err != nil {
+			return nil, fmt.Errorf("invalid alert rule %q: %w", rules[i].ID, err)
+		}
+	}
+	return rules, nil
+}
+
+func saveRules(path string, rules []dto.AlertRule) error {
+	if rules == nil {
+		rules = []dto.AlertRule{}
+	}
+	if err := lib.WriteJSONFile(path, rules); err != nil {
+		return fmt.Errorf("failed to save alert rules: %w", err)
+	}
+	return nil
+}
+
+// Rules returns a copy of the configured rules.
+func (e *Engine) Rules() []dto.AlertRule {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]dto.AlertRule{}, e.rules...)
+}
+
+// Rule returns the rule with the given ID.
+func (e *Engine) Rule(id string) (dto.AlertRule, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if i := e.indexOf(id); i >= 0 {
+		return e.rules[i], nil
+	}
+	return dto.AlertRule{}, ErrRuleNotFound
+}
+
+// CreateRule validates, stores and persists a new rule.
+func (e *Engine) CreateRule(rule dto.AlertRule) (dto.AlertRule, error) {
+	if err := ValidateRule(&rule); err != nil {
+		return dto.AlertRule{}, err
+	}
+
+	buf := make([]byte, 4)
+	if _, err := rand.Read(buf); err != nil {
+		return dto.AlertRule{}, fmt.Errorf("failed to generate rule ID: %w", err)
+	}
+	rule.ID = hex.EncodeToString(buf)
+	rule.CreatedAt = time.Now().UTC()
+	rule.UpdatedAt = rule.CreatedAt
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.rules = append(e.rules, rule)
+	if err := saveRules(e.path, e.rules); err != nil {
+		e.rules = e.rules[:len(e.rules)-1]
+		return dto.AlertRule{}, err
+	}
+	return rule, nil
+}
+
+// UpdateRule replaces the rule with the given ID. Its alert state is reset.
+func (e *Engine) UpdateRule(id string, rule dto.AlertRule) (dto.AlertRule, error) {
+	if err := ValidateRule(&rule); err != nil {
+		return dto.AlertRule{}, err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	i := e.indexOf(id)
+	if i < 0 {
+		return dto.AlertRule{}, ErrRuleNotFound
+	}
+
+	previous := e.rules[i]
+	rule.ID = previous.ID
+	rule.CreatedAt = previous.CreatedAt
+	rule.UpdatedAt = time.Now().UTC()
+
+	e.rules[i] = rule
+	if err := saveRules(e.path, e.rules); err != nil {
+		e.rules[i] = previous
+		return dto.AlertRule{}, err
+	}
+	e.resetState(id)
+	return rule, nil
+}
+
+// DeleteRule removes the rule with the given ID.
+func (e *Engine) DeleteRule(id string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	i := e.indexOf(id)
+	if i < 0 {
+		return ErrRuleNotFound
+	}
+
+	previous := e.rules
+	e.rules = append(append([]dto.AlertRule{}, e.rules[:i]...), e.rules[i+1:]...)
+	if err := saveRules(e.path, e.rules); err != nil {
+		e.rules = previous
+		return err
+	}
+	e.resetState(id)
+	return nil
+}
+
+func (e *Engine) indexOf(id string) int {
+	for i, rule := range e.rules {
+		if rule.ID == id {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,84 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/dto"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/services/alerts"
+)
+
+// handleAlerts returns the alert rules that are currently pending or firing
+func (s *Server) handleAlerts(w http.ResponseWriter, _ *http.Request) {
+	respondJSON(w, http.StatusOK, dto.AlertStatusList{
+		Alerts:    s.alerts.Status(),
+		Timestamp: time.Now(),
+	})
+}
+
+// handleAlertRules lists the configured alert rules
+func (s *Server) handleAlertRules(w http.ResponseWriter, _ *http.Request) {
+	respondJSON(w, http.StatusOK, s.alerts.Rules())
+}
+
+// handleAlertRule returns a single alert rule
+func (s *Server) handleAlertRule(w http.ResponseWriter, r *http.Request) {
+	rule, err := s.alerts.Rule(mux.Vars(r)["id"])
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	respondJSON(w, http.StatusOK, rule)
+}
+
+// handleCreateAlertRule creates an alert rule; enabled defaults to true and importance to warning
+func (s *Server) handleCreateAlertRule(w http.ResponseWriter, r *http.Request) {
+	rule := alerts.NewRule()
+	if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	created, err := s.alerts.CreateRule(rule)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	respondJSON(w, http.StatusCreated, created)
+}
+
+// handleUpdateAlertRule replaces an alert rule
+func (s *Server) handleUpdateAlertRule(w http.ResponseWriter, r *http.Request) {
+	rule := alerts.NewRule()
+	if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	updated, err := s.alerts.UpdateRule(mux.Vars(r)["id"], rule)
+	if errors.Is(err, alerts.ErrRuleNotFound) {
+		respondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	respondJSON(w, http.StatusOK, updated)
+}
+
+// handleDeleteAlertRule deletes an alert rule
+func (s *Server) handleDeleteAlertRule(w http.ResponseWriter, r *http.Request) {
+	if err := s.alerts.DeleteRule(mux.Vars(r)["id"]); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, alerts.ErrRuleNotFound) {
+			status = http.StatusNotFound
+		}
+		respondWithError(w, status, err.Error())
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]string{"message": "Alert rule deleted successfully"})
+}
@@ -0,0 +1,93 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/dto"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/services/metrics"
+)
+
+func TestAlertRuleEndpoints(t *testing.T) {
+	server, _ := setupTestServer()
+
+	do := func(method, url, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		server.router.ServeHTTP(rr, httptest.NewRequest(method, url, bytes.NewBufferString(body)))
+		return rr
+	}
+
+	// Create with defaults for enabled and importance
+	rr := do("POST", "/api/v1/alerts/rules", `{"name":"Hot disk","metric":"disk_temperature_celsius","operator":">","threshold":50,"for_seconds":300}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("create status = %d: %s", rr.Code, rr.Body.String())
+	}
+	var created dto.AlertRule
+	if err := json.Unmarshal(rr.Body.Bytes(), &created); err != nil {
+		t.Fatalf("failed to decode rule: %v", err)
+	}
+	if created.ID == "" || !created.Enabled || created.Importance != "warning" {
+		t.Errorf("created rule = %+v", created)
+	}
+
+	tests := []struct {
+		name       string
+		method     string
+		url        string
+		body       string
+		wantStatus int
+	}{
+		{"list", "GET", "/api/v1/alerts/rules", "", http.StatusOK},
+		{"get", "GET", "/api/v1/alerts/rules/" + created.ID, "", http.StatusOK},
+		{"get missing", "GET", "/api/v1/alerts/rules/missing", "", http.StatusNotFound},
+		{"create invalid metric", "POST", "/api/v1/alerts/rules", `{"name":"x","metric":"nope","operator":">"}`, http.StatusBadRequest},
+		{"create invalid body", "POST", "/api/v1/alerts/rules", `{`, http.StatusBadRequest},
+		{"update", "PUT", "/api/v1/alerts/rules/" + created.ID, `{"name":"Hot disk","metric":"disk_temperature_celsius","operator":">","threshold":55,"enabled":false}`, http.StatusOK},
+		{"update missing", "PUT", "/api/v1/alerts/rules/missing", `{"name":"x","metric":"cpu_usage_percent","operator":">"}`, http.StatusNotFound},
+		{"active alerts", "GET", "/api/v1/alerts", "", http.StatusOK},
+		{"delete", "DELETE", "/api/v1/alerts/rules/" + created.ID, "", http.StatusOK},
+		{"delete missing", "DELETE", "/api/v1/alerts/rules/" + created.ID, "", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := do(tt.method, tt.url, tt.body)
+			if rr.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d: %s", rr.Code, tt.wantStatus, rr.Body.String())
+			}
+		})
+	}
+
+	if rules := server.alerts.Rules(); len(rules) != 0 {
+		t.Errorf("rules after delete = %+v", rules)
+	}
+}
+
+func TestActiveAlertsEndpoint(t *testing.T) {
+	server, _ := setupTestServer()
+
+	rule, err := server.alerts.CreateRule(dto.AlertRule{
+		Name: "Busy CPU", Enabled: true, Metric: metrics.CPUUsagePercent, Operator: ">", Threshold: 90,
+	})
+	if err != nil {
+		t.Fatalf("CreateRule error: %v", err)
+	}
+	server.alerts.Evaluate([]metrics.Sample{{Metric: metrics.CPUUsagePercent, Value: 97}})
+
+	rr := httptest.NewRecorder()
+	server.router.ServeHTTP(rr, httptest.NewRequest("GET", "/api/v1/alerts", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status = %d", rr.Code)
+	}
+
+	var list dto.AlertStatusList
+	if err := json.Unmarshal(rr.Body.Bytes(), &list); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(list.Alerts) != 1 || list.Alerts[0].RuleID != rule.ID || list.Alerts[0].State != "firing" || list.Alerts[0].Value != 97 {
+		t.Errorf("alerts = %+v", list.Alerts)
+	}
+}
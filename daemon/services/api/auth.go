@@ -0,0 +1,288 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/dto"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/logger"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/services/auth"
+)
+
+type contextKey string
+
+const apiKeyContextKey contextKey = "api_key"
+
+// publicRoutes are reachable without credentials even when authentication is enabled.
+var publicRoutes = map[string]bool{
+	"/api/v1/health": true,
+}
+
+// adminRoutes require the admin scope, keyed by method and route template.
+// All other GET requests require read, and all other methods require control.
+var adminRoutes = map[string]bool{
+	"POST /api/v1/shares/{name}/config":                  true,
+	"POST /api/v1/docker/prune/images":                   true,
+	"POST /api/v1/docker/prune/volumes":                  true,
+	"POST /api/v1/docker/prune/containers":               true,
+	"POST /api/v1/vm/{name}/snapshots/{snapshot}/revert": true,
+	"DELETE /api/v1/vm/{name}/snapshots/{snapshot}":      true,
+	"POST /api/v1/settings/system":                       true,
+	"POST /api/v1/user-scripts/{name}/execute":           true,
+	"GET /api/v1/auth/keys":                              true,
+	"POST /api/v1/auth/keys":                             true,
+	"DELETE /api/v1/auth/keys/{id}":                      true,
+	"POST /api/v1/alerts/rules":                          true,
+	"PUT /api/v1/alerts/rules/{id}":                      true,
+	"DELETE /api/v1/alerts/rules/{id}":                   true,
+	"GET /api/v1/webhooks":                               true,
+	"POST /api/v1/webhooks":                              true,
+	"GET /api/v1/webhooks/{id}":                          true,
+	"PUT /api/v1/webhooks/{id}":                          true,
+	"DELETE /api/v1/webhooks/{id}":                       true,
+	"POST /api/v1/webhooks/{id}/test":                    true,
+	"GET /api/v1/webhooks/dead-letters":                  true,
+	"DELETE /api/v1/webhooks/dead-letters":               true,
+	"POST /api/v1/webhooks/dead-letters/{id}/retry":      true,
+	"DELETE /api/v1/webhooks/dead-letters/{id}":          true,
+	"PUT /api/v1/disks/self-test/schedule":               true,
+}
+
+// keyRoutes manage API keys. While no key exists the API is open, so they are only served to
+// requests from the server itself; otherwise any host on the network could create the first
+// admin key.
+var keyRoutes = map[string]bool{
+	"GET /api/v1/auth/keys":         true,
+	"POST /api/v1/auth/keys":        true,
+	"DELETE /api/v1/auth/keys/{id}": true,
+}
+
+// controlRoutes are GET routes that require the control scope because they hand over control,
+// such as the keyboard and mouse of a VM console.
+var controlRoutes = map[string]bool{
+	"GET /api/v1/vm/{name}/console": true,
+}
+
+// routeKey returns the method and route template of the matched route, such as
+// "DELETE /api/v1/auth/keys/{id}".
+func routeKey(r *http.Request) string {
+	template := r.URL.Path
+	if route := mux.CurrentRoute(r); route != nil {
+		if t, err := route.GetPathTemplate(); err == nil {
+			template = t
+		}
+	}
+	return r.Method + " " + template
+}
+
+// requiredScope determines the scope needed to access the matched route.
+func requiredScope(r *http.Request) auth.Scope {
+	key := routeKey(r)
+	if adminRoutes[key] {
+		return auth.ScopeAdmin
+	}
+
+	if controlRoutes[key] {
+		return auth.ScopeControl
+	}
+
+	if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		return auth.ScopeRead
+	}
+	return auth.ScopeControl
+}
+
+// extractToken returns the API key presented with the request.
+// Keys are accepted as a bearer token or X-API-Key header. Browsers cannot set headers on
+// WebSocket upgrades, so the api_key query parameter is also accepted for upgrade requests.
+func extractToken(r *http.Request) string {
+	if header := r.Header.Get("Authorization"); header != "" {
+		if scheme, token, ok := strings.Cut(header, " "); ok && strings.EqualFold(scheme, "Bearer") {
+			return strings.TrimSpace(token)
+		}
+	}
+
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return strings.TrimSpace(key)
+	}
+
+	if strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		return r.URL.Query().Get("api_key")
+	}
+
+	return ""
+}
+
+// authMiddleware enforces API key authentication and scopes when at least one key is configured.
+func (s *Server) authMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.keyStore.Enabled() {
+			if keyRoutes[routeKey(r)] && !isLoopback(r) {
+				logger.Warning("Auth: Rejected key management from %s while no API keys exist", r.RemoteAddr)
+				respondWithError(w, http.StatusForbidden, "No API keys exist yet; create the first one on the server with the apikey command")
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if route := mux.CurrentRoute(r); route != nil {
+			if template, err := route.GetPathTemplate(); err == nil && publicRoutes[template] {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+
+		token := extractToken(r)
+		if token == "" {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="unraid-management-agent"`)
+			respondJSON(w, http.StatusUnauthorized, dto.Response{
+				Success:   false,
+				Message:   "Authentication required",
+				Timestamp: time.Now(),
+			})
+			return
+		}
+
+		key, ok := s.keyStore.Authenticate(token)
+		if !ok {
+			logger.Warning("Auth: Rejected invalid API key from %s for %s %s", r.RemoteAddr, r.Method, r.URL.Path)
+			w.Header().Set("WWW-Authenticate", `Bearer realm="unraid-management-agent", error="invalid_token"`)
+			respondJSON(w, http.StatusUnauthorized, dto.Response{
+				Success:   false,
+				Message:   "Invalid API key",
+				Timestamp: time.Now(),
+			})
+			return
+		}
+
+		scope := requiredScope(r)
+		if !key.HasScope(scope) {
+			logger.Warning("Auth: API key %s (%s) lacks %s scope for %s %s", key.ID, key.Name, scope, r.Method, r.URL.Path)
+			respondJSON(w, http.StatusForbidden, dto.Response{
+				Success:   false,
+				Message:   fmt.Sprintf("API key lacks required scope: %s", scope),
+				Timestamp: time.Now(),
+			})
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), apiKeyContextKey, key)))
+	})
+}
+
+// isLoopback reports whether a request comes from the server itself.
+func isLoopback(r *http.Request) bool {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// apiKeyFromContext returns the API key that authenticated the request, if any.
+func apiKeyFromContext(ctx context.Context) *auth.APIKey {
+	key, _ := ctx.Value(apiKeyContextKey).(*auth.APIKey)
+	return key
+}
+
+func toAPIKeyInfo(ks *auth.KeyStore, key auth.APIKey) dto.APIKeyInfo {
+	info := dto.APIKeyInfo{
+		ID:        key.ID,
+		Name:      key.Name,
+		CreatedAt: key.CreatedAt,
+	}
+	for _, scope := range key.Scopes {
+		info.Scopes = append(info.Scopes, string(scope))
+	}
+	if lastUsed, ok := ks.LastUsed(key.ID); ok {
+		info.LastUsedAt = &lastUsed
+	}
+	return info
+}
+
+// handleAuthWhoami returns the API key used for the request, or reports that authentication is disabled.
+func (s *Server) handleAuthWhoami(w http.ResponseWriter, r *http.Request) {
+	key := apiKeyFromContext(r.Context())
+	if key == nil {
+		respondJSON(w, http.StatusOK, map[string]interface{}{
+			"auth_enabled": s.keyStore.Enabled(),
+		})
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"auth_enabled": true,
+		"key":          toAPIKeyInfo(s.keyStore, *key),
+	})
+}
+
+// handleAPIKeys lists configured API keys without their secrets
+func (s *Server) handleAPIKeys(w http.ResponseWriter, _ *http.Request) {
+	keys := s.keyStore.List()
+	infos := make([]dto.APIKeyInfo, 0, len(keys))
+	for _, key := range keys {
+		infos = append(infos, toAPIKeyInfo(s.keyStore, key))
+	}
+
+	respondJSON(w, http.StatusOK, infos)
+}
+
+// handleCreateAPIKey creates a new API key and returns its plaintext value once
+func (s *Server) handleCreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	var req dto.APIKeyCreateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	scopes := make([]auth.Scope, 0, len(req.Scopes))
+	for _, value := range req.Scopes {
+		scope, err := auth.ParseScope(value)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		scopes = append(scopes, scope)
+	}
+
+	plaintext, key, err := s.keyStore.Create(req.Name, scopes)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, dto.APIKeyCreateResponse{
+		APIKeyInfo: toAPIKeyInfo(s.keyStore, *key),
+		Key:        plaintext,
+	})
+}
+
+// handleRevokeAPIKey deletes an API key by ID or name. The last admin key is only deleted with
+// disable_auth=true.
+func (s *Server) handleRevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	disableAuth := r.URL.Query().Get("disable_auth") == "true"
+
+	if err := s.keyStore.Revoke(id, disableAuth); err != nil {
+		switch {
+		case errors.Is(err, auth.ErrKeyNotFound):
+			respondWithError(w, http.StatusNotFound, err.Error())
+		case errors.Is(err, auth.ErrLastAdminKey):
+			respondWithError(w, http.StatusConflict, err.Error()+"; pass disable_auth=true to revoke it anyway")
+		default:
+			respondWithError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]string{"message": "API key revoked successfully"})
+}
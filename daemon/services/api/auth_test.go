@@ -0,0 +1,347 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/domain"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/dto"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/services/auth"
+)
+
+func setupAuthTestServer(t *testing.T) (*Server, map[auth.Scope]string) {
+	t.Helper()
+
+	ctx := &domain.Context{
+		Config: domain.Config{
+			Port:        8080,
+			APIKeysFile: filepath.Join(t.TempDir(), "apikeys.json"),
+		},
+	}
+	server := NewServer(ctx)
+
+	keys := make(map[auth.Scope]string)
+	for _, scope := range []auth.Scope{auth.ScopeRead, auth.ScopeControl, auth.ScopeAdmin} {
+		plaintext, _, err := server.keyStore.Create(string(scope)+"-key", []auth.Scope{scope})
+		if err != nil {
+			t.Fatalf("failed to create %s key: %v", scope, err)
+		}
+		keys[scope] = plaintext
+	}
+
+	return server, keys
+}
+
+func TestAuthDisabledWithoutKeys(t *testing.T) {
+	server, _ := setupTestServer()
+
+	req := httptest.NewRequest("GET", "/api/v1/system", nil)
+	rr := httptest.NewRecorder()
+	server.router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("unauthenticated request with no keys configured returned %d, want %d", rr.Code, http.StatusOK)
+	}
+}
+
+func TestKeyManagementWithoutKeys(t *testing.T) {
+	server, _ := setupTestServer()
+
+	// Only the server itself may create the first key
+	req := httptest.NewRequest("POST", "/api/v1/auth/keys", strings.NewReader(`{"name":"admin","scopes":["admin"]}`))
+	rr := httptest.NewRecorder()
+	server.router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusForbidden {
+		t.Fatalf("create key from the network returned %d, want %d", rr.Code, http.StatusForbidden)
+	}
+
+	req = httptest.NewRequest("POST", "/api/v1/auth/keys", strings.NewReader(`{"name":"admin","scopes":["admin"]}`))
+	req.RemoteAddr = "127.0.0.1:40000"
+	rr = httptest.NewRecorder()
+	server.router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusCreated {
+		t.Errorf("create key from loopback returned %d, want %d: %s", rr.Code, http.StatusCreated, rr.Body.String())
+	}
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	server, keys := setupAuthTestServer(t)
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		setup      func(r *http.Request)
+		wantStatus int
+	}{
+		{
+			name:       "health is public",
+			method:     "GET",
+			path:       "/api/v1/health",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "missing key",
+			method:     "GET",
+			path:       "/api/v1/system",
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:   "invalid key",
+			method: "GET",
+			path:   "/api/v1/system",
+			setup: func(r *http.Request) {
+				r.Header.Set("Authorization", "Bearer uma_invalid")
+			},
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:   "read key via bearer token",
+			method: "GET",
+			path:   "/api/v1/system",
+			setup: func(r *http.Request) {
+				r.Header.Set("Authorization", "Bearer "+keys[auth.ScopeRead])
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:   "read key via X-API-Key header",
+			method: "GET",
+			path:   "/api/v1/system",
+			setup: func(r *http.Request) {
+				r.Header.Set("X-API-Key", keys[auth.ScopeRead])
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "query parameter ignored for plain requests",
+			method:     "GET",
+			path:       "/api/v1/system?api_key=" + keys[auth.ScopeRead],
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:   "read key cannot stop the array",
+			method: "POST",
+			path:   "/api/v1/array/stop",
+			setup: func(r *http.Request) {
+				r.Header.Set("Authorization", "Bearer "+keys[auth.ScopeRead])
+			},
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:   "control key cannot execute user scripts",
+			method: "POST",
+			path:   "/api/v1/user-scripts/backup/execute",
+			setup: func(r *http.Request) {
+				r.Header.Set("Authorization", "Bearer "+keys[auth.ScopeControl])
+			},
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:   "control key cannot list API keys",
+			method: "GET",
+			path:   "/api/v1/auth/keys",
+			setup: func(r *http.Request) {
+				r.Header.Set("Authorization", "Bearer "+keys[auth.ScopeControl])
+			},
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:   "control key cannot create alert rules",
+			method: "POST",
+			path:   "/api/v1/alerts/rules",
+			setup: func(r *http.Request) {
+				r.Header.Set("Authorization", "Bearer "+keys[auth.ScopeControl])
+			},
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:   "read key cannot list webhooks",
+			method: "GET",
+			path:   "/api/v1/webhooks",
+			setup: func(r *http.Request) {
+				r.Header.Set("Authorization", "Bearer "+keys[auth.ScopeRead])
+			},
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:   "control key cannot prune Docker volumes",
+			method: "POST",
+			path:   "/api/v1/docker/prune/volumes?confirm=true",
+			setup: func(r *http.Request) {
+				r.Header.Set("Authorization", "Bearer "+keys[auth.ScopeControl])
+			},
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:   "read key cannot create containers from templates",
+			method: "POST",
+			path:   "/api/v1/docker/templates/plex/create",
+			setup: func(r *http.Request) {
+				r.Header.Set("Authorization", "Bearer "+keys[auth.ScopeRead])
+			},
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:   "read key cannot stop Compose stacks",
+			method: "POST",
+			path:   "/api/v1/docker/stacks/paperless/stop",
+			setup: func(r *http.Request) {
+				r.Header.Set("Authorization", "Bearer "+keys[auth.ScopeRead])
+			},
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:   "read key cannot start batch operations",
+			method: "POST",
+			path:   "/api/v1/docker/batch",
+			setup: func(r *http.Request) {
+				r.Header.Set("Authorization", "Bearer "+keys[auth.ScopeRead])
+			},
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:   "read key cannot snapshot VMs",
+			method: "POST",
+			path:   "/api/v1/vm/Windows%2011/snapshots",
+			setup: func(r *http.Request) {
+				r.Header.Set("Authorization", "Bearer "+keys[auth.ScopeRead])
+			},
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:   "control key cannot delete VM snapshots",
+			method: "DELETE",
+			path:   "/api/v1/vm/Windows%2011/snapshots/pre-update",
+			setup: func(r *http.Request) {
+				r.Header.Set("Authorization", "Bearer "+keys[auth.ScopeControl])
+			},
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:   "read key cannot open VM consoles",
+			method: "GET",
+			path:   "/api/v1/vm/Windows%2011/console?api_key=" + keys[auth.ScopeRead],
+			setup: func(r *http.Request) {
+				r.Header.Set("Upgrade", "websocket")
+			},
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:   "admin key lists API keys",
+			method: "GET",
+			path:   "/api/v1/auth/keys",
+			setup: func(r *http.Request) {
+				r.Header.Set("Authorization", "Bearer "+keys[auth.ScopeAdmin])
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:   "websocket upgrade requires a key",
+			method: "GET",
+			path:   "/api/v1/ws",
+			setup: func(r *http.Request) {
+				r.Header.Set("Upgrade", "websocket")
+			},
+			wantStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.setup != nil {
+				tt.setup(req)
+			}
+			rr := httptest.NewRecorder()
+			server.router.ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Errorf("%s %s returned %d, want %d", tt.method, tt.path, rr.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestAPIKeyEndpoints(t *testing.T) {
+	server, keys := setupAuthTestServer(t)
+	admin := "Bearer " + keys[auth.ScopeAdmin]
+
+	body := strings.NewReader(`{"name":"scripts","scopes":["control"]}`)
+	req := httptest.NewRequest("POST", "/api/v1/auth/keys", body)
+	req.Header.Set("Authorization", admin)
+	rr := httptest.NewRecorder()
+	server.router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("create key returned %d, want %d: %s", rr.Code, http.StatusCreated, rr.Body.String())
+	}
+
+	var created dto.APIKeyCreateResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &created); err != nil {
+		t.Fatalf("failed to parse response: %v", err)
+	}
+	if created.Key == "" || created.Name != "scripts" {
+		t.Errorf("unexpected create response: %+v", created)
+	}
+
+	req = httptest.NewRequest("GET", "/api/v1/auth/whoami", nil)
+	req.Header.Set("Authorization", "Bearer "+created.Key)
+	rr = httptest.NewRecorder()
+	server.router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"scripts"`) {
+		t.Errorf("whoami returned %d: %s", rr.Code, rr.Body.String())
+	}
+
+	t.Run("rejects invalid scope", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/api/v1/auth/keys", strings.NewReader(`{"name":"x","scopes":["root"]}`))
+		req.Header.Set("Authorization", admin)
+		rr := httptest.NewRecorder()
+		server.router.ServeHTTP(rr, req)
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("create with invalid scope returned %d, want %d", rr.Code, http.StatusBadRequest)
+		}
+	})
+
+	t.Run("revokes key", func(t *testing.T) {
+		req := httptest.NewRequest("DELETE", "/api/v1/auth/keys/"+created.ID, nil)
+		req.Header.Set("Authorization", admin)
+		rr := httptest.NewRecorder()
+		server.router.ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("revoke returned %d, want %d", rr.Code, http.StatusOK)
+		}
+
+		req = httptest.NewRequest("GET", "/api/v1/system", nil)
+		req.Header.Set("Authorization", "Bearer "+created.Key)
+		rr = httptest.NewRecorder()
+		server.router.ServeHTTP(rr, req)
+		if rr.Code != http.StatusUnauthorized {
+			t.Errorf("revoked key returned %d, want %d", rr.Code, http.StatusUnauthorized)
+		}
+	})
+
+	t.Run("keeps the last admin key", func(t *testing.T) {
+		tests := []struct {
+			path       string
+			wantStatus int
+		}{
+			{"/api/v1/auth/keys/missing", http.StatusNotFound},
+			{"/api/v1/auth/keys/admin-key", http.StatusConflict},
+			{"/api/v1/auth/keys/admin-key?disable_auth=true", http.StatusOK},
+		}
+		for _, tt := range tests {
+			req := httptest.NewRequest("DELETE", tt.path, nil)
+			req.Header.Set("Authorization", admin)
+			rr := httptest.NewRecorder()
+			server.router.ServeHTTP(rr, req)
+			if rr.Code != tt.wantStatus {
+				t.Errorf("DELETE %s returned %d, want %d: %s", tt.path, rr.Code, tt.wantStatus, rr.Body.String())
+			}
+		}
+	})
+}
@@ -0,0 +1,249 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/constants"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/dto"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/lib"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/logger"
+)
+
+// arrayDiskRoles are the roles of the disks spun up or down when no disks are named.
+var arrayDiskRoles = map[string]bool{"parity": true, "parity2": true, "data": true}
+
+func (s *Server) handleDiskSpinDown(w http.ResponseWriter, r *http.Request) {
+	s.handleDiskSpin(w, r, "standby")
+}
+
+func (s *Server) handleDiskSpinUp(w http.ResponseWriter, r *http.Request) {
+	s.handleDiskSpin(w, r, "active")
+}
+
+func (s *Server) handleDisksSpinDown(w http.ResponseWriter, r *http.Request) {
+	s.handleDisksSpin(w, r, "standby")
+}
+
+func (s *Server) handleDisksSpinUp(w http.ResponseWriter, r *http.Request) {
+	s.handleDisksSpin(w, r, "active")
+}
+
+// handleDiskSpin spins the disk with the device named in the route up or down.
+func (s *Server) handleDiskSpin(w http.ResponseWriter, r *http.Request, state string) {
+	device := mux.Vars(r)["id"]
+	if err := lib.ValidateDiskID(device); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	disks, err := s.spinTargets([]string{device})
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	resp := s.spinDisks(disks, state)
+	status := http.StatusOK
+	if !resp.Success {
+		status = http.StatusInternalServerError
+	}
+	respondJSON(w, status, resp)
+}
+
+// handleDisksSpin spins the disks named in the request body up or down, or all array disks when
+// the body is empty or names none. The response lists the outcome for each disk.
+func (s *Server) handleDisksSpin(w http.ResponseWriter, r *http.Request, state string) {
+	var req dto.DiskSpinRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	for _, device := range req.Disks {
+		if err := lib.ValidateDiskID(device); err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	disks, err := s.spinTargets(req.Disks)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	resp := s.spinDisks(disks, state)
+	status := http.StatusOK
+	if !resp.Success {
+		status = http.StatusInternalServerError
+	}
+	respondJSON(w, status, resp)
+}
+
+// spinTargets returns the cached disks with the given devices, or the array disks when devices is
+// empty.
+func (s *Server) spinTargets(devices []string) ([]dto.DiskInfo, error) {
+	s.cacheMutex.RLock()
+	cached := s.disksCache
+	s.cacheMutex.RUnlock()
+
+	var disks []dto.DiskInfo
+	if len(devices) == 0 {
+		for _, disk := range cached {
+			if disk.Device != "" && arrayDiskRoles[disk.Role] {
+				disks = append(disks, disk)
+			}
+		}
+		return disks, nil
+	}
+
+	for _, device := range devices {
+		found := false
+		for _, disk := range cached {
+			if disk.Device == device {
+				disks = append(disks, disk)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("disk not found: %s", device)
+		}
+	}
+	return disks, nil
+}
+
+// spinDisks spins disks up or down in parallel, asks each drive for its state afterwards and
+// publishes the disk list with the new states right away rather than at the next collection.
+func (s *Server) spinDisks(disks []dto.DiskInfo, state string) dto.DiskSpinResponse {
+	verb := "spin down"
+	if state == "active" {
+		verb = "spin up"
+	}
+	logger.Info("API: Request to %s %d disks", verb, len(disks))
+
+	results := make([]dto.DiskSpinResult, len(disks))
+	var wg sync.WaitGroup
+	for i, disk := range disks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.spin.Expect(disk.Device, state)
+			spin := s.array.SpinDownDisk
+			if state == "active" {
+				spin = s.array.SpinUpDisk
+			}
+			result := dto.DiskSpinResult{Device: disk.Device, Name: disk.Name, Success: true}
+			if err := spin(disk.Name); err != nil {
+				result.Success = false
+				result.Error = err.Error()
+			}
+			result.SpinState = s.array.DiskSpinState(disk.Device)
+			results[i] = result
+		}()
+	}
+	wg.Wait()
+
+	resp := dto.DiskSpinResponse{Success: true, Results: results, Timestamp: time.Now()}
+	states := make(map[string]string, len(results))
+	failed := 0
+	for _, result := range results {
+		if !result.Success {
+			failed++
+		}
+		if result.SpinState != "unknown" {
+			states[result.Device] = result.SpinState
+		}
+	}
+	resp.Success = failed == 0
+	resp.Message = fmt.Sprintf("Requested %s of %d disks, %d failed", verb, len(results), failed)
+	s.publishSpinStates(states)
+	return resp
+}
+
+// publishSpinStates updates the spin state of the cached disks and publishes the updated list.
+func (s *Server) publishSpinStates(states map[string]string) {
+	if len(states) == 0 {
+		return
+	}
+	s.cacheMutex.Lock()
+	disks := make([]dto.DiskInfo, len(s.disksCache))
+	copy(disks, s.disksCache)
+	for i := range disks {
+		if state, ok := states[disks[i].Device]; ok {
+			disks[i].SpinState = state
+		}
+	}
+	s.disksCache = disks
+	s.cacheMutex.Unlock()
+
+	s.ctx.Hub.Pub(disks, constants.TopicDiskListUpdate)
+}
+
+// handleDiskSMART returns the SMART data of a disk found by ID, device or name. Disks in standby
+// return the data of their last reading, marked as standby.
+func (s *Server) handleDiskSMART(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	disk, ok := s.findDisk(id)
+	if !ok {
+		respondWithError(w, http.StatusNotFound, "Disk not found: "+id)
+		return
+	}
+	smart, ok := s.cachedSMART(disk.Device)
+	if !ok {
+		// USB flash drives, and disks that stayed in standby since the agent started
+		respondWithError(w, http.StatusNotFound, "No SMART data for disk "+id)
+		return
+	}
+	respondJSON(w, http.StatusOK, smart)
+}
+
+// findDisk returns the cached disk with the given ID, device or name.
+func (s *Server) findDisk(id string) (dto.DiskInfo, bool) {
+	s.cacheMutex.RLock()
+	defer s.cacheMutex.RUnlock()
+
+	for _, disk := range s.disksCache {
+		if disk.Device != "" && (disk.ID == id || disk.Device == id || disk.Name == id) {
+			return disk, true
+		}
+	}
+	return dto.DiskInfo{}, false
+}
+
+// cachedSMART returns the SMART data of the last disk collection for a device.
+func (s *Server) cachedSMART(device string) (dto.DiskSMART, bool) {
+	s.cacheMutex.RLock()
+	defer s.cacheMutex.RUnlock()
+
+	for _, smart := range s.smartCache {
+		if smart.Device == device {
+			return smart, true
+		}
+	}
+	return dto.DiskSMART{}, false
+}
+
+// handleDisksSpinHistory returns the spin state summary of every tracked disk.
+func (s *Server) handleDisksSpinHistory(w http.ResponseWriter, _ *http.Request) {
+	respondJSON(w, http.StatusOK, s.spin.Summary())
+}
+
+// handleDiskSpinHistory returns the spin state changes of one disk with what woke it up.
+func (s *Server) handleDiskSpinHistory(w http.ResponseWriter, r *http.Request) {
+	device := mux.Vars(r)["id"]
+	if err := lib.ValidateDiskID(device); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	h, ok := s.spin.History(device)
+	if !ok {
+		respondWithError(w, http.StatusNotFound, "No spin history for disk "+device)
+		return
+	}
+	respondJSON(w, http.StatusOK, h)
+}
@@ -0,0 +1,140 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/dto"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/lib"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/services/selftest"
+)
+
+// handleDiskSelfTest returns the running self-test of a disk, how long each test type takes and
+// the self-test log. Disks in standby are not woken up; they return their last reading.
+func (s *Server) handleDiskSelfTest(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	disk, ok := s.findDisk(id)
+	if !ok {
+		respondWithError(w, http.StatusNotFound, "Disk not found: "+id)
+		return
+	}
+	smart, ok := s.currentSMART(disk)
+	if !ok {
+		respondWithError(w, http.StatusNotFound, "No SMART data for disk "+id)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, dto.DiskSelfTest{
+		Device:          disk.Device,
+		Name:            disk.Name,
+		Running:         smart.SelfTestRunning,
+		DurationMinutes: smart.SelfTestMinutes,
+		Log:             smart.SelfTests,
+		Standby:         smart.Standby,
+		UpdatedAt:       smart.UpdatedAt,
+	})
+}
+
+// handleStartDiskSelfTest starts a self-test of the type in the request body, short by default.
+// A disk already running a test is left alone.
+func (s *Server) handleStartDiskSelfTest(w http.ResponseWriter, r *http.Request) {
+	req := dto.DiskSelfTestRequest{Type: "short"}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	kind, err := lib.NormalizeSelfTestType(req.Type)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	id := mux.Vars(r)["id"]
+	disk, ok := s.findDisk(id)
+	if !ok {
+		respondWithError(w, http.StatusNotFound, "Disk not found: "+id)
+		return
+	}
+	smart, ok := s.currentSMART(disk)
+	if ok && !smart.Standby && smart.SelfTestRunning != nil {
+		respondWithError(w, http.StatusConflict, fmt.Sprintf("A self-test is already running on %s (%d%% done)", disk.Name, smart.SelfTestRunning.ProgressPercent))
+		return
+	}
+
+	if err := s.smart.StartSelfTest(disk.Device, kind); err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	s.selftest.Started(disk, kind)
+
+	message := fmt.Sprintf("Started %s self-test on %s", kind, disk.Name)
+	if minutes := smart.SelfTestMinutes[kind]; minutes > 0 {
+		message += fmt.Sprintf(", expected to take %d minutes", minutes)
+	}
+	respondJSON(w, http.StatusOK, dto.Response{Success: true, Message: message, Timestamp: time.Now()})
+}
+
+// handleAbortDiskSelfTest aborts the self-test a disk is running.
+func (s *Server) handleAbortDiskSelfTest(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	disk, ok := s.findDisk(id)
+	if !ok {
+		respondWithError(w, http.StatusNotFound, "Disk not found: "+id)
+		return
+	}
+
+	if err := s.smart.AbortSelfTest(disk.Device); err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	s.selftest.Aborted(disk)
+
+	respondJSON(w, http.StatusOK, dto.Response{
+		Success:   true,
+		Message:   "Aborted self-test on " + disk.Name,
+		Timestamp: time.Now(),
+	})
+}
+
+// handleSelfTestSchedule returns the self-test schedule with the state of each scheduled disk.
+func (s *Server) handleSelfTestSchedule(w http.ResponseWriter, _ *http.Request) {
+	respondJSON(w, http.StatusOK, s.selftest.Status())
+}
+
+// handleUpdateSelfTestSchedule replaces the self-test schedule; omitted fields take their
+// defaults.
+func (s *Server) handleUpdateSelfTestSchedule(w http.ResponseWriter, r *http.Request) {
+	schedule := selftest.DefaultSchedule()
+	if err := json.NewDecoder(r.Body).Decode(&schedule); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	updated, err := s.selftest.SetSchedule(schedule)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	respondJSON(w, http.StatusOK, updated)
+}
+
+// currentSMART reads the SMART data of a disk, or returns the last reading when the disk is in
+// standby. A disk that cannot be read for another reason has no SMART data.
+func (s *Server) currentSMART(disk dto.DiskInfo) (dto.DiskSMART, bool) {
+	cached, ok := s.cachedSMART(disk.Device)
+	smart, err := s.smart.ReadSMART(disk.Device)
+	if err != nil {
+		if !errors.Is(err, lib.ErrDiskStandby) && disk.SpinState != "standby" {
+			return dto.DiskSMART{}, false
+		}
+		cached.Standby = true
+		return cached, ok
+	}
+	smart.Name = cached.Name
+	return *smart, true
+}
@@ -0,0 +1,143 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/dto"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/services/controllers"
+)
+
+// fakeSmartctl writes a smartctl stand-in that logs the commands it is given. sdc is running an
+// extended test, sdd is in standby, sde is idle and sdf dropped off the bus.
+func fakeSmartctl(t *testing.T) (smartctl, log string) {
+	t.Helper()
+	dir := t.TempDir()
+	log = filepath.Join(dir, "smartctl.log")
+	script := `#!/bin/sh
+case "$*" in
+*-a*/dev/sdc) echo '{"device": {"protocol": "ATA"}, "ata_smart_data": {"self_test": {"status": {"value": 246, "remaining_percent": 60}, "polling_minutes": {"short": 2, "extended": 1651}}}, "ata_smart_self_test_log": {"standard": {"table": [{"type": {"string": "Short offline"}, "status": {"string": "Completed without error", "passed": true}, "lifetime_hours": 100}]}}}' ;;
+*-a*/dev/sdd) echo '{"smartctl": {"exit_status": 2}}' ;;
+*-a*/dev/sdf) echo '{"smartctl": {"exit_status": 2, "messages": [{"string": "Smartctl open device: /dev/sdf failed: No such device"}]}}' ;;
+*-a*) echo '{"device": {"protocol": "ATA"}, "ata_smart_data": {"self_test": {"status": {"value": 0}, "polling_minutes": {"short": 2, "extended": 1651}}}}' ;;
+*) printf '%s\n' "$*" >> ` + log + `; echo '{"smartctl": {"exit_status": 0}}' ;;
+esac
+`
+	smartctl = filepath.Join(dir, "smartctl")
+	if err := os.WriteFile(smartctl, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return smartctl, log
+}
+
+func TestDiskSelfTest(t *testing.T) {
+	server, _ := setupTestServer()
+	smartctl, log := fakeSmartctl(t)
+	server.smart = controllers.NewSMARTControllerWithBinary(smartctl)
+	server.disksCache = []dto.DiskInfo{
+		{ID: "DISK_1", Device: "sdc", Name: "disk1", Role: "data"},
+		{ID: "DISK_2", Device: "sdd", Name: "disk2", Role: "data", SpinState: "standby"},
+		{ID: "DISK_3", Device: "sde", Name: "disk3", Role: "data"},
+		{ID: "DISK_4", Device: "sdf", Name: "disk4", Role: "data", SpinState: "active"},
+	}
+	server.smartCache = []dto.DiskSMART{
+		{Device: "sdd", Name: "disk2", Status: "PASSED", SelfTests: []dto.SMARTSelfTest{{Type: "extended", Passed: true}}},
+		{Device: "sdf", Name: "disk4", Status: "PASSED", SelfTests: []dto.SMARTSelfTest{{Type: "extended", Passed: true}}},
+	}
+
+	var running dto.DiskSelfTest
+	getDockerJSON(t, server, "/api/v1/disks/disk1/self-test", &running)
+	if running.Running == nil || running.Running.ProgressPercent != 40 || running.DurationMinutes["extended"] != 1651 || len(running.Log) != 1 {
+		t.Errorf("unexpected self-test state: %+v", running)
+	}
+
+	// Disks in standby report their last reading
+	var standby dto.DiskSelfTest
+	getDockerJSON(t, server, "/api/v1/disks/DISK_2/self-test", &standby)
+	if !standby.Standby || len(standby.Log) != 1 || standby.Name != "disk2" {
+		t.Errorf("unexpected standby self-test state: %+v", standby)
+	}
+
+	// A disk that cannot be opened does not report its last reading as if it were in standby
+	rr := httptest.NewRecorder()
+	server.router.ServeHTTP(rr, httptest.NewRequest("GET", "/api/v1/disks/disk4/self-test", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("GET self-test of unreadable disk returned %d: %s", rr.Code, rr.Body.String())
+	}
+
+	tests := []struct {
+		path, body  string
+		wantStatus  int
+		wantMessage string
+	}{
+		{"/api/v1/disks/sde/self-test", `{"type": "long"}`, http.StatusOK, "Started extended self-test on disk3, expected to take 1651 minutes"},
+		{"/api/v1/disks/sdd/self-test", "", http.StatusOK, "Started short self-test on disk2"},
+		{"/api/v1/disks/disk1/self-test", `{"type": "short"}`, http.StatusConflict, "already running on disk1 (40% done)"},
+		{"/api/v1/disks/disk3/self-test", `{"type": "offline"}`, http.StatusBadRequest, "invalid self-test type"},
+		{"/api/v1/disks/sdz/self-test", "", http.StatusNotFound, "Disk not found"},
+		{"/api/v1/disks/disk3/self-test/abort", "", http.StatusOK, "Aborted self-test on disk3"},
+	}
+	for _, tt := range tests {
+		rr := httptest.NewRecorder()
+		server.router.ServeHTTP(rr, httptest.NewRequest("POST", tt.path, strings.NewReader(tt.body)))
+		if rr.Code != tt.wantStatus || !strings.Contains(rr.Body.String(), tt.wantMessage) {
+			t.Errorf("POST %s returned %d: %s", tt.path, rr.Code, rr.Body.String())
+		}
+	}
+
+	data, err := os.ReadFile(log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "-j -t long /dev/sde\n-j -t short /dev/sdd\n-j -X /dev/sde\n"
+	if string(data) != want {
+		t.Errorf("smartctl called with %q, want %q", data, want)
+	}
+
+	// Tests started through the API show up in the schedule status
+	server.selftest.UpdateDisks(server.disksCache)
+	var status dto.SelfTestScheduleStatus
+	getDockerJSON(t, server, "/api/v1/disks/self-test/schedule", &status)
+	if len(status.Disks) != 4 || status.Schedule.Enabled {
+		t.Fatalf("unexpected schedule status: %+v", status)
+	}
+	for _, disk := range status.Disks {
+		if disk.Name == "disk2" && (!disk.Running || disk.LastType != "short") {
+			t.Errorf("unexpected disk2 status: %+v", disk)
+		}
+		if disk.Name == "disk3" && (disk.Running || disk.LastType != "extended" || disk.LastResult != "aborted") {
+			t.Errorf("unexpected disk3 status: %+v", disk)
+		}
+	}
+}
+
+func TestSelfTestScheduleUpdate(t *testing.T) {
+	server, _ := setupTestServer()
+
+	tests := []struct {
+		body       string
+		wantStatus int
+	}{
+		{`{"enabled": true, "disks": ["disk1"], "window_start_hour": 1, "window_end_hour": 6}`, http.StatusOK},
+		{`{"enabled": true, "interval_days": 0}`, http.StatusBadRequest},
+		{`{"type": "offline"}`, http.StatusBadRequest},
+		{`{"enabled": `, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		rr := httptest.NewRecorder()
+		server.router.ServeHTTP(rr, httptest.NewRequest("PUT", "/api/v1/disks/self-test/schedule", strings.NewReader(tt.body)))
+		if rr.Code != tt.wantStatus {
+			t.Errorf("PUT %s returned %d, want %d: %s", tt.body, rr.Code, tt.wantStatus, rr.Body.String())
+		}
+	}
+
+	// Omitted fields take their defaults, and invalid updates leave the schedule alone
+	schedule := server.selftest.Schedule()
+	if !schedule.Enabled || schedule.Type != "extended" || schedule.IntervalDays != 30 || schedule.MaxConcurrent != 1 || schedule.WindowEndHour != 6 {
+		t.Errorf("unexpected schedule: %+v", schedule)
+	}
+}
@@ -0,0 +1,159 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/cskr/pubsub"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/constants"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/dto"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/services/controllers"
+)
+
+// fakeSpinBinaries writes emcmd and hdparm stand-ins. emcmd logs its arguments, fails for disk3
+// and stores the requested state, which hdparm reports back.
+func fakeSpinBinaries(t *testing.T) (emcmd, hdparm, log string) {
+	t.Helper()
+	dir := t.TempDir()
+	log = filepath.Join(dir, "emcmd.log")
+	state := filepath.Join(dir, "state")
+	scripts := map[string]string{
+		"emcmd": `#!/bin/sh
+echo "$1" >> ` + log + `
+case "$1" in
+*=disk3) exit 1 ;;
+cmdSpindown=*) echo standby > ` + state + `.$$ ;;
+cmdSpinup=*) echo active/idle > ` + state + `.$$ ;;
+esac
+# Disks are spun in parallel; replace the state at once so hdparm never reads it half written
+if [ -f ` + state + `.$$ ]; then mv ` + state + `.$$ ` + state + `; fi
+`,
+		"hdparm": `#!/bin/sh
+printf '\n%s:\n drive state is:  %s\n' "$2" "$(cat ` + state + `)"
+`,
+	}
+	for name, script := range scripts {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(script), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return filepath.Join(dir, "emcmd"), filepath.Join(dir, "hdparm"), log
+}
+
+func TestDiskSpin(t *testing.T) {
+	server, ctx := setupTestServer()
+	ctx.Hub = pubsub.New(16)
+	emcmd, hdparm, log := fakeSpinBinaries(t)
+	server.array = controllers.NewArrayControllerWithOptions(ctx, controllers.ArrayOptions{Emcmd: emcmd, Hdparm: hdparm})
+	server.disksCache = []dto.DiskInfo{
+		{Device: "sdb", Name: "parity", Role: "parity", SpinState: "active"},
+		{Device: "sdc", Name: "disk1", Role: "data", SpinState: "active"},
+		{Device: "sdd", Name: "disk3", Role: "data", SpinState: "active"},
+		{Device: "nvme0n1", Name: "cache", Role: "cache", SpinState: "active"},
+	}
+	updates := ctx.Hub.Sub(constants.TopicDiskListUpdate)
+
+	tests := []struct {
+		method, path, body string
+		wantStatus         int
+	}{
+		{"POST", "/api/v1/disks/sdc/spin-down", "", http.StatusOK},
+		{"POST", "/api/v1/disks/sdd/spin-down", "", http.StatusInternalServerError},
+		{"POST", "/api/v1/disks/sdz/spin-down", "", http.StatusNotFound},
+		{"POST", "/api/v1/disks/disk1/spin-up", "", http.StatusBadRequest},
+		{"POST", "/api/v1/disks/spin-up", `{"disks": ["sdb", "nvme0n1"]}`, http.StatusOK},
+		{"POST", "/api/v1/disks/spin-up", `{"disks": ["../sda"]}`, http.StatusBadRequest},
+		{"POST", "/api/v1/disks/spin-down", `{"disks": [`, http.StatusBadRequest},
+		{"GET", "/api/v1/disks/sdz/spin-history", "", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		rr := httptest.NewRecorder()
+		server.router.ServeHTTP(rr, httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body)))
+		if rr.Code != tt.wantStatus {
+			t.Errorf("%s %s returned %d, want %d: %s", tt.method, tt.path, rr.Code, tt.wantStatus, rr.Body.String())
+		}
+	}
+
+	// The new state is published without waiting for the disk collector
+	disks := (<-updates).([]dto.DiskInfo)
+	if disks[1].SpinState != "standby" || disks[0].SpinState != "active" {
+		t.Errorf("published states: %s %s", disks[0].SpinState, disks[1].SpinState)
+	}
+
+	// Without a body all array disks are spun down, but not the cache
+	rr := httptest.NewRecorder()
+	server.router.ServeHTTP(rr, httptest.NewRequest("POST", "/api/v1/disks/spin-down", nil))
+	var resp dto.DiskSpinResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if rr.Code != http.StatusInternalServerError || len(resp.Results) != 3 || resp.Results[2].Error == "" || resp.Results[0].SpinState != "standby" {
+		t.Errorf("spin down all returned %d: %+v", rr.Code, resp)
+	}
+
+	data, err := os.ReadFile(log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	calls := strings.Fields(string(data))
+	sort.Strings(calls)
+	want := []string{
+		"cmdSpindown=disk1", "cmdSpindown=disk1", "cmdSpindown=disk3", "cmdSpindown=disk3", "cmdSpindown=parity",
+		"cmdSpinup=cache", "cmdSpinup=parity",
+	}
+	if strings.Join(calls, " ") != strings.Join(want, " ") {
+		t.Errorf("emcmd calls = %v, want %v", calls, want)
+	}
+	if server.disksCache[3].SpinState != "active" {
+		t.Errorf("NVMe cache spin state = %s", server.disksCache[3].SpinState)
+	}
+}
+
+func TestDiskSpinHistory(t *testing.T) {
+	server, _ := setupTestServer()
+	server.spin.Update([]dto.DiskInfo{{Device: "sdc", Name: "disk1", SpinState: "active"}})
+	server.spin.Update([]dto.DiskInfo{{Device: "sdc", Name: "disk1", SpinState: "standby"}})
+
+	var h dto.DiskSpinHistory
+	getDockerJSON(t, server, "/api/v1/disks/sdc/spin-history", &h)
+	if h.Name != "disk1" || h.SpinDowns24h != 1 || len(h.Events) != 1 || h.Events[0].Cause.Source != "idle" {
+		t.Errorf("unexpected history: %+v", h)
+	}
+
+	var list []dto.DiskSpinHistory
+	getDockerJSON(t, server, "/api/v1/disks/spin-history", &list)
+	if len(list) != 1 || list[0].Device != "sdc" || list[0].Events != nil {
+		t.Errorf("unexpected summary: %+v", list)
+	}
+}
+
+func TestDiskSMART(t *testing.T) {
+	server, _ := setupTestServer()
+	server.disksCache = []dto.DiskInfo{
+		{ID: "WDC_WUH721816ALE6L4_2CGV0URP", Device: "sdc", Name: "disk1"},
+		{ID: "SanDisk_Cruzer", Device: "sda", Name: "flash"},
+	}
+	server.smartCache = []dto.DiskSMART{{Device: "sdc", Name: "disk1", Status: "PASSED", ReallocatedSectors: 8, Standby: true}}
+
+	for _, id := range []string{"sdc", "disk1", "WDC_WUH721816ALE6L4_2CGV0URP"} {
+		var smart dto.DiskSMART
+		getDockerJSON(t, server, "/api/v1/disks/"+id+"/smart", &smart)
+		if smart.Device != "sdc" || smart.ReallocatedSectors != 8 || !smart.Standby {
+			t.Errorf("%s: unexpected SMART data: %+v", id, smart)
+		}
+	}
+
+	for _, id := range []string{"flash", "sdz"} {
+		rr := httptest.NewRecorder()
+		server.router.ServeHTTP(rr, httptest.NewRequest("GET", "/api/v1/disks/"+id+"/smart", nil))
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("%s returned %d, want 404", id, rr.Code)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/dto"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/services/controllers"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/services/jobs"
+)
+
+// handleDockerBatch starts a batch operation on many containers and returns its job right away
+// with 202 Accepted. Progress is polled at the Location URL or followed on the docker_batch_job
+// WebSocket topic.
+func (s *Server) handleDockerBatch(w http.ResponseWriter, r *http.Request) {
+	var req dto.DockerBatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	job, err := s.jobs.Start(r.Context(), req)
+	if errors.Is(err, controllers.ErrInvalidBatch) {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		respondDockerError(w, "start batch "+req.Action, err)
+		return
+	}
+	w.Header().Set("Location", "/api/v1/docker/batch/"+job.ID)
+	respondJSON(w, http.StatusAccepted, job)
+}
+
+// handleDockerBatchJobs lists recent batch jobs, newest first.
+func (s *Server) handleDockerBatchJobs(w http.ResponseWriter, _ *http.Request) {
+	respondJSON(w, http.StatusOK, s.jobs.List())
+}
+
+// handleDockerBatchJob returns the progress of a batch job.
+func (s *Server) handleDockerBatchJob(w http.ResponseWriter, r *http.Request) {
+	job, err := s.jobs.Get(mux.Vars(r)["id"])
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	respondJSON(w, http.StatusOK, job)
+}
+
+// handleDockerBatchCancel cancels a running batch job.
+func (s *Server) handleDockerBatchCancel(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	switch err := s.jobs.Cancel(id); {
+	case errors.Is(err, jobs.ErrJobNotFound):
+		respondWithError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, jobs.ErrJobFinished):
+		respondWithError(w, http.StatusConflict, err.Error())
+	default:
+		respondJSON(w, http.StatusOK, dto.Response{Success: true, Message: "Batch job " + id + " canceled", Timestamp: time.Now()})
+	}
+}
@@ -0,0 +1,75 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/dto"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/services/jobs"
+)
+
+func TestDockerBatch(t *testing.T) {
+	server, engine := setupDockerTestServer(t)
+	engine.AddComposeStack()
+	server.jobs = jobs.NewManager(context.Background(), server.docker, nil)
+
+	body := `{"action": "stop", "selector": {"labels": {"com.docker.compose.project": "paperless"}, "state": "running"}}`
+	rr := httptest.NewRecorder()
+	server.router.ServeHTTP(rr, httptest.NewRequest("POST", "/api/v1/docker/batch", strings.NewReader(body)))
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("batch returned %d: %s", rr.Code, rr.Body.String())
+	}
+	var job dto.DockerBatchJob
+	if err := json.Unmarshal(rr.Body.Bytes(), &job); err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+	if location := rr.Header().Get("Location"); location != "/api/v1/docker/batch/"+job.ID {
+		t.Errorf("Location = %q", location)
+	}
+	if job.Total != 2 || job.Action != "stop" {
+		t.Errorf("unexpected job: %+v", job)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for job.FinishedAt == nil {
+		if time.Now().After(deadline) {
+			t.Fatalf("job did not finish: %+v", job)
+		}
+		time.Sleep(5 * time.Millisecond)
+		getDockerJSON(t, server, "/api/v1/docker/batch/"+job.ID, &job)
+	}
+	if job.Status != jobs.StatusSucceeded || job.Succeeded != 2 {
+		t.Errorf("unexpected finished job: %+v", job)
+	}
+
+	var list []dto.DockerBatchJob
+	getDockerJSON(t, server, "/api/v1/docker/batch", &list)
+	if len(list) != 1 || list[0].ID != job.ID {
+		t.Errorf("unexpected job list: %+v", list)
+	}
+
+	tests := []struct {
+		method     string
+		path       string
+		body       string
+		wantStatus int
+	}{
+		{"POST", "/api/v1/docker/batch", `{"action": "stop", "containers": ["radarr"]}`, http.StatusBadRequest},
+		{"POST", "/api/v1/docker/batch", `{"action": "stop", "containers": "plex"}`, http.StatusBadRequest},
+		{"GET", "/api/v1/docker/batch/0000", "", http.StatusNotFound},
+		{"DELETE", "/api/v1/docker/batch/0000", "", http.StatusNotFound},
+		{"DELETE", "/api/v1/docker/batch/" + job.ID, "", http.StatusConflict},
+	}
+	for _, tt := range tests {
+		rr := httptest.NewRecorder()
+		server.router.ServeHTTP(rr, httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body)))
+		if rr.Code != tt.wantStatus {
+			t.Errorf("%s %s returned %d, want %d: %s", tt.method, tt.path, rr.Code, tt.wantStatus, rr.Body.String())
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/dto"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/lib"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/lib/dockerapi"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/logger"
+)
+
+// Container log limits. Without follow, at most maxLogTail lines are returned.
+const (
+	defaultLogTail = 100
+	maxLogTail     = 10000
+)
+
+// handleDockerLogs returns the logs of a container with stdout and stderr tagged separately.
+// Query parameters: tail (lines, or "all"), since (RFC3339, unix seconds or relative like
+// "-15m"), timestamps (bool) and follow (bool). With follow=true the response is a stream of
+// newline-delimited JSON lines that stays open until the client disconnects.
+func (s *Server) handleDockerLogs(w http.ResponseWriter, r *http.Request) {
+	containerID := mux.Vars(r)["id"]
+	if err := lib.ValidateContainerID(containerID); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	opts, err := parseLogOptions(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if opts.Follow {
+		s.streamDockerLogs(w, r, containerID, opts)
+		return
+	}
+
+	logs := dto.ContainerLogs{
+		ContainerID: containerID,
+		Lines:       []dto.ContainerLogLine{},
+	}
+	err = s.docker.Logs(r.Context(), containerID, opts, func(line dto.ContainerLogLine) error {
+		logs.Lines = append(logs.Lines, line)
+		return nil
+	})
+	if err != nil {
+		respondDockerAPIError(w, containerID, "read logs of", err)
+		return
+	}
+
+	logs.Timestamp = time.Now()
+	respondJSON(w, http.StatusOK, logs)
+}
+
+// streamDockerLogs writes each log line as a JSON object followed by a newline and flushes it
+// immediately. The response starts with the first line, so errors found before any output
+// (unknown container, Docker unavailable) are still returned as a JSON error.
+func (s *Server) streamDockerLogs(w http.ResponseWriter, r *http.Request, containerID string, opts dockerapi.LogOptions) {
+	// The server's write timeout would otherwise cut long-running streams
+	rc := http.NewResponseController(w)
+	_ = rc.SetWriteDeadline(time.Time{})
+
+	started := false
+	encoder := json.NewEncoder(w)
+	err := s.docker.Logs(r.Context(), containerID, opts, func(line dto.ContainerLogLine) error {
+		if !started {
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			w.Header().Set("Cache-Control", "no-cache")
+			w.WriteHeader(http.StatusOK)
+			started = true
+		}
+		if err := encoder.Encode(line); err != nil {
+			return err
+		}
+		return rc.Flush()
+	})
+
+	switch {
+	case err != nil && !started:
+		respondDockerAPIError(w, containerID, "read logs of", err)
+	case err != nil && r.Context().Err() == nil:
+		logger.Warning("API: Log stream for container %s ended: %v", containerID, err)
+	case !started:
+		// Nothing was logged before the container stopped or the client left
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// parseLogOptions reads the tail, since, timestamps and follow query parameters.
+func parseLogOptions(r *http.Request) (dockerapi.LogOptions, error) {
+	query := r.URL.Query()
+	var opts dockerapi.LogOptions
+
+	var err error
+	if value := query.Get("follow"); value != "" {
+		if opts.Follow, err = strconv.ParseBool(value); err != nil {
+			return opts, fmt.Errorf("invalid follow: %s", value)
+		}
+	}
+	if value := query.Get("timestamps"); value != "" {
+		if opts.Timestamps, err = strconv.ParseBool(value); err != nil {
+			return opts, fmt.Errorf("invalid timestamps: %s", value)
+		}
+	}
+	if value := query.Get("since"); value != "" {
+		if opts.Since, err = parseHistoryTime(value, time.Time{}, time.Now()); err != nil {
+			return opts, fmt.Errorf("invalid since: %v", err)
+		}
+	}
+
+	tail := query.Get("tail")
+	switch {
+	case tail == "":
+		opts.Tail = strconv.Itoa(defaultLogTail)
+	case tail == "all" && opts.Follow:
+		opts.Tail = "all"
+	case tail == "all":
+		opts.Tail = strconv.Itoa(maxLogTail)
+	default:
+		n, err := strconv.Atoi(tail)
+		if err != nil || n < 0 {
+			return opts, fmt.Errorf("invalid tail: %s", tail)
+		}
+		if n > maxLogTail && !opts.Follow {
+			n = maxLogTail
+		}
+		opts.Tail = strconv.Itoa(n)
+	}
+
+	return opts, nil
+}
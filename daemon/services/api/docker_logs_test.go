@@ -0,0 +1,133 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/dto"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/lib/dockerapi/dockertest"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/services/controllers"
+)
+
+func setupDockerTestServer(t *testing.T) (*Server, *dockertest.Server) {
+	t.Helper()
+	engine := dockertest.NewServer(t)
+	server, _ := setupTestServer()
+	server.docker = controllers.NewDockerControllerWithOptions(controllers.DockerOptions{Socket: engine.Socket()})
+	return server, engine
+}
+
+func TestDockerLogs(t *testing.T) {
+	server, _ := setupDockerTestServer(t)
+	plex := dockertest.PlexID[:12]
+
+	tests := []struct {
+		name       string
+		path       string
+		wantStatus int
+		wantLines  int
+	}{
+		{name: "default tail", path: "/api/v1/docker/" + plex + "/logs", wantStatus: http.StatusOK, wantLines: 5},
+		{name: "tail", path: "/api/v1/docker/" + plex + "/logs?tail=2", wantStatus: http.StatusOK, wantLines: 2},
+		{name: "since", path: "/api/v1/docker/" + plex + "/logs?since=2024-05-13T08:00:02Z", wantStatus: http.StatusOK, wantLines: 2},
+		{name: "full ID", path: "/api/v1/docker/" + dockertest.PlexID + "/logs?tail=all", wantStatus: http.StatusOK, wantLines: 5},
+		{name: "invalid ID", path: "/api/v1/docker/plex/logs", wantStatus: http.StatusBadRequest},
+		{name: "invalid tail", path: "/api/v1/docker/" + plex + "/logs?tail=-1", wantStatus: http.StatusBadRequest},
+		{name: "invalid since", path: "/api/v1/docker/" + plex + "/logs?since=yesterday", wantStatus: http.StatusBadRequest},
+		{name: "unknown container", path: "/api/v1/docker/0123456789ab/logs", wantStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tt.path, nil)
+			rr := httptest.NewRecorder()
+			server.router.ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("GET %s returned %d, want %d: %s", tt.path, rr.Code, tt.wantStatus, rr.Body.String())
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			var logs dto.ContainerLogs
+			if err := json.Unmarshal(rr.Body.Bytes(), &logs); err != nil {
+				t.Fatalf("failed to parse response: %v", err)
+			}
+			if len(logs.Lines) != tt.wantLines {
+				t.Errorf("got %d lines, want %d", len(logs.Lines), tt.wantLines)
+			}
+		})
+	}
+
+	t.Run("streams are tagged and timestamps parsed", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/api/v1/docker/"+plex+"/logs?timestamps=true", nil)
+		rr := httptest.NewRecorder()
+		server.router.ServeHTTP(rr, req)
+
+		var logs dto.ContainerLogs
+		if err := json.Unmarshal(rr.Body.Bytes(), &logs); err != nil {
+			t.Fatalf("failed to parse response: %v", err)
+		}
+		last := logs.Lines[len(logs.Lines)-1]
+		if last.Stream != "stderr" || last.Timestamp == nil || last.Message != "Error: Unable to set up server: Error: Unauthorized (401)" {
+			t.Errorf("unexpected last line: %+v", last)
+		}
+	})
+}
+
+func TestDockerLogsFollow(t *testing.T) {
+	server, _ := setupDockerTestServer(t)
+	ts := httptest.NewServer(server.router)
+	defer ts.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	req, _ := http.NewRequestWithContext(ctx, "GET", ts.URL+"/api/v1/docker/"+dockertest.PlexID[:12]+"/logs?follow=true", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "application/x-ndjson" {
+		t.Fatalf("got %d %q, want 200 application/x-ndjson", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	// The fake engine keeps following after the recorded lines, so read them and disconnect
+	scanner := bufio.NewScanner(resp.Body)
+	var streams []string
+	for len(streams) < 5 && scanner.Scan() {
+		var line dto.ContainerLogLine
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			t.Fatalf("invalid NDJSON line %q: %v", scanner.Text(), err)
+		}
+		streams = append(streams, line.Stream)
+	}
+	want := []string{"stdout", "stdout", "stderr", "stdout", "stderr"}
+	for i := range want {
+		if i >= len(streams) || streams[i] != want[i] {
+			t.Fatalf("streams = %v, want %v", streams, want)
+		}
+	}
+}
+
+func TestDockerLogsUnavailable(t *testing.T) {
+	server, _ := setupTestServer()
+	server.docker = controllers.NewDockerControllerWithOptions(controllers.DockerOptions{Socket: filepath.Join(t.TempDir(), "missing.sock")})
+
+	req := httptest.NewRequest("GET", "/api/v1/docker/"+dockertest.PlexID[:12]+"/logs", nil)
+	rr := httptest.NewRecorder()
+	server.router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("GET logs without Docker returned %d, want %d", rr.Code, http.StatusServiceUnavailable)
+	}
+}
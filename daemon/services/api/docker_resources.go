@@ -0,0 +1,106 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/lib/dockerapi"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/logger"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/services/controllers"
+)
+
+// handleDockerImages lists local images, largest first, with the containers using each one.
+func (s *Server) handleDockerImages(w http.ResponseWriter, r *http.Request) {
+	images, err := s.docker.Images(r.Context())
+	if err != nil {
+		respondDockerError(w, "list Docker images", err)
+		return
+	}
+	respondJSON(w, http.StatusOK, images)
+}
+
+// handleDockerNetworks lists networks with their subnets and attached containers.
+func (s *Server) handleDockerNetworks(w http.ResponseWriter, r *http.Request) {
+	networks, err := s.docker.Networks(r.Context())
+	if err != nil {
+		respondDockerError(w, "list Docker networks", err)
+		return
+	}
+	respondJSON(w, http.StatusOK, networks)
+}
+
+// handleDockerVolumes lists volumes, largest first, with their size and the containers using them.
+func (s *Server) handleDockerVolumes(w http.ResponseWriter, r *http.Request) {
+	volumes, err := s.docker.Volumes(r.Context())
+	if err != nil {
+		respondDockerError(w, "list Docker volumes", err)
+		return
+	}
+	respondJSON(w, http.StatusOK, volumes)
+}
+
+// handleDockerDiskUsage reports the space used by images, containers, volumes and build cache.
+func (s *Server) handleDockerDiskUsage(w http.ResponseWriter, r *http.Request) {
+	usage, err := s.docker.DiskUsage(r.Context())
+	if err != nil {
+		respondDockerError(w, "get Docker disk usage", err)
+		return
+	}
+	respondJSON(w, http.StatusOK, usage)
+}
+
+func (s *Server) handleDockerPruneImages(w http.ResponseWriter, r *http.Request) {
+	s.handleDockerPrune(w, r, controllers.PruneImages)
+}
+
+func (s *Server) handleDockerPruneVolumes(w http.ResponseWriter, r *http.Request) {
+	s.handleDockerPrune(w, r, controllers.PruneVolumes)
+}
+
+func (s *Server) handleDockerPruneContainers(w http.ResponseWriter, r *http.Request) {
+	s.handleDockerPrune(w, r, controllers.PruneContainers)
+}
+
+// handleDockerPrune removes unused resources. Pruning cannot be undone, so the request must
+// carry confirm=true. For volumes, all=true also removes unused named volumes.
+func (s *Server) handleDockerPrune(w http.ResponseWriter, r *http.Request, target string) {
+	query := r.URL.Query()
+	if confirm, _ := strconv.ParseBool(query.Get("confirm")); !confirm {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Pruning %s cannot be undone; repeat the request with confirm=true", target))
+		return
+	}
+	var all bool
+	if value := query.Get("all"); value != "" {
+		var err error
+		if all, err = strconv.ParseBool(value); err != nil {
+			respondWithError(w, http.StatusBadRequest, fmt.Sprintf("invalid all: %s", value))
+			return
+		}
+	}
+
+	// Finish the prune even if the client disconnects
+	result, err := s.docker.Prune(context.WithoutCancel(r.Context()), target, all)
+	if err != nil {
+		respondDockerError(w, "prune Docker "+target, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, result)
+}
+
+// respondDockerError maps an error from an Engine API call that is not about a single container:
+// 500 for errors reported by the Engine and 503 when the socket cannot be reached.
+func respondDockerError(w http.ResponseWriter, operation string, err error) {
+	switch {
+	case dockerapi.IsAPIError(err):
+		logger.Error("API: Failed to %s: %v", operation, err)
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to %s: %v", operation, err))
+	case errors.Is(err, context.Canceled):
+		// Client went away; nothing to report
+	default:
+		logger.Error("API: Docker API unavailable to %s: %v", operation, err)
+		respondWithError(w, http.StatusServiceUnavailable, "Docker API unavailable")
+	}
+}
@@ -0,0 +1,143 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/dto"
+)
+
+func TestDockerInventory(t *testing.T) {
+	server, _ := setupDockerTestServer(t)
+
+	t.Run("images", func(t *testing.T) {
+		var images []dto.DockerImage
+		getDockerJSON(t, server, "/api/v1/docker/images", &images)
+		if len(images) != 3 {
+			t.Fatalf("got %d images, want 3", len(images))
+		}
+		// Sorted by size: plex, the untagged old plex image, sonarr
+		plex, old := images[0], images[1]
+		if len(plex.Tags) != 1 || plex.Dangling || len(plex.Containers) != 1 || plex.Containers[0] != "plex" {
+			t.Errorf("unexpected plex image: %+v", plex)
+		}
+		if !old.Dangling || len(old.Tags) != 0 || len(old.Containers) != 0 {
+			t.Errorf("unexpected dangling image: %+v", old)
+		}
+	})
+
+	t.Run("networks", func(t *testing.T) {
+		var networks []dto.DockerNetwork
+		getDockerJSON(t, server, "/api/v1/docker/networks", &networks)
+		if len(networks) != 4 {
+			t.Fatalf("got %d networks, want 4", len(networks))
+		}
+		bridge := networks[0]
+		if bridge.Name != "bridge" || len(bridge.Subnets) != 1 || bridge.Subnets[0].Subnet != "172.17.0.0/16" {
+			t.Errorf("unexpected bridge network: %+v", bridge)
+		}
+		if len(bridge.Containers) != 1 || bridge.Containers[0].Name != "plex" || bridge.Containers[0].IPAddress != "172.17.0.2" {
+			t.Errorf("unexpected bridge containers: %+v", bridge.Containers)
+		}
+		if br0 := networks[3]; br0.Driver != "macvlan" || br0.Subnets[0].IPRange != "192.168.1.128/25" || len(br0.Containers) != 0 {
+			t.Errorf("unexpected br0 network: %+v", br0)
+		}
+	})
+
+	t.Run("volumes", func(t *testing.T) {
+		var volumes []dto.DockerVolume
+		getDockerJSON(t, server, "/api/v1/docker/volumes", &volumes)
+		if len(volumes) != 3 {
+			t.Fatalf("got %d volumes, want 3", len(volumes))
+		}
+		transcode, old, anon := volumes[0], volumes[1], volumes[2]
+		if transcode.Name != "plex-transcode" || !transcode.InUse || len(transcode.Containers) != 1 || transcode.SizeBytes != 1288490188 {
+			t.Errorf("unexpected plex-transcode volume: %+v", transcode)
+		}
+		if old.Name != "mariadb-old" || old.InUse || old.Anonymous {
+			t.Errorf("unexpected mariadb-old volume: %+v", old)
+		}
+		if !anon.Anonymous || anon.InUse {
+			t.Errorf("unexpected anonymous volume: %+v", anon)
+		}
+	})
+
+	t.Run("disk usage", func(t *testing.T) {
+		var usage dto.DockerDiskUsage
+		getDockerJSON(t, server, "/api/v1/docker/disk-usage", &usage)
+		if usage.Images.Count != 3 || usage.Images.Active != 2 || usage.Images.ReclaimableBytes != 338690048 {
+			t.Errorf("unexpected image usage: %+v", usage.Images)
+		}
+		if usage.Containers.Active != 1 || usage.Containers.ReclaimableBytes != 4194304 {
+			t.Errorf("unexpected container usage: %+v", usage.Containers)
+		}
+		if usage.Volumes.Count != 3 || usage.Volumes.ReclaimableBytes != 314572800+54525952 {
+			t.Errorf("unexpected volume usage: %+v", usage.Volumes)
+		}
+		if usage.TotalBytes != 894173184+52428800+4194304+1288490188+314572800+54525952 {
+			t.Errorf("TotalBytes = %d", usage.TotalBytes)
+		}
+	})
+}
+
+func TestDockerPrune(t *testing.T) {
+	server, engine := setupDockerTestServer(t)
+
+	tests := []struct {
+		name          string
+		path          string
+		wantStatus    int
+		wantDeleted   int
+		wantReclaimed uint64
+	}{
+		{name: "requires confirmation", path: "/api/v1/docker/prune/images", wantStatus: http.StatusBadRequest},
+		{name: "images", path: "/api/v1/docker/prune/images?confirm=true", wantStatus: http.StatusOK, wantDeleted: 1, wantReclaimed: 338690048},
+		{name: "containers", path: "/api/v1/docker/prune/containers?confirm=true", wantStatus: http.StatusOK, wantDeleted: 1, wantReclaimed: 4194304},
+		{name: "anonymous volumes", path: "/api/v1/docker/prune/volumes?confirm=true", wantStatus: http.StatusOK, wantDeleted: 1, wantReclaimed: 54525952},
+		{name: "all volumes", path: "/api/v1/docker/prune/volumes?confirm=true&all=true", wantStatus: http.StatusOK, wantDeleted: 2, wantReclaimed: 314572800 + 54525952},
+		{name: "invalid all", path: "/api/v1/docker/prune/volumes?confirm=true&all=maybe", wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", tt.path, nil)
+			rr := httptest.NewRecorder()
+			server.router.ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("POST %s returned %d, want %d: %s", tt.path, rr.Code, tt.wantStatus, rr.Body.String())
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			var result dto.DockerPruneResult
+			if err := json.Unmarshal(rr.Body.Bytes(), &result); err != nil {
+				t.Fatalf("invalid response: %v", err)
+			}
+			if len(result.Deleted) != tt.wantDeleted || result.ReclaimedBytes != tt.wantReclaimed {
+				t.Errorf("deleted %v reclaiming %d, want %d items and %d bytes", result.Deleted, result.ReclaimedBytes, tt.wantDeleted, tt.wantReclaimed)
+			}
+		})
+	}
+
+	// Unconfirmed requests never reach the Engine
+	if n := engine.Count("POST /images/prune"); n != 1 {
+		t.Errorf("images pruned %d times, want 1", n)
+	}
+}
+
+func getDockerJSON(t *testing.T, server *Server, path string, v interface{}) {
+	t.Helper()
+	req := httptest.NewRequest("GET", path, nil)
+	rr := httptest.NewRecorder()
+	server.router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("GET %s returned %d: %s", path, rr.Code, rr.Body.String())
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), v); err != nil {
+		t.Fatalf("invalid response from %s: %v", path, err)
+	}
+}
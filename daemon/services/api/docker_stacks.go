@@ -0,0 +1,87 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/lib"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/lib/compose"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/services/controllers"
+)
+
+// handleDockerStacks lists the Docker Compose stacks built from the cached containers.
+func (s *Server) handleDockerStacks(w http.ResponseWriter, _ *http.Request) {
+	s.cacheMutex.RLock()
+	containers := s.dockerCache
+	s.cacheMutex.RUnlock()
+
+	respondJSON(w, http.StatusOK, compose.Stacks(containers))
+}
+
+// handleDockerStack returns one Docker Compose stack.
+func (s *Server) handleDockerStack(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+	if err := lib.ValidateStackName(name); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	s.cacheMutex.RLock()
+	containers := s.dockerCache
+	s.cacheMutex.RUnlock()
+
+	for _, stack := range compose.Stacks(containers) {
+		if stack.Name == name {
+			respondJSON(w, http.StatusOK, stack)
+			return
+		}
+	}
+	respondWithError(w, http.StatusNotFound, fmt.Sprintf("Docker Compose stack not found: %s", name))
+}
+
+func (s *Server) handleDockerStackStart(w http.ResponseWriter, r *http.Request) {
+	s.handleDockerStackAction(w, r, controllers.StackStart)
+}
+
+func (s *Server) handleDockerStackStop(w http.ResponseWriter, r *http.Request) {
+	s.handleDockerStackAction(w, r, controllers.StackStop)
+}
+
+func (s *Server) handleDockerStackRestart(w http.ResponseWriter, r *http.Request) {
+	s.handleDockerStackAction(w, r, controllers.StackRestart)
+}
+
+func (s *Server) handleDockerStackPull(w http.ResponseWriter, r *http.Request) {
+	s.handleDockerStackAction(w, r, controllers.StackPull)
+}
+
+// handleDockerStackAction runs an action on every container of a stack in dependency order.
+// Stopping a stack waits for each container in turn and pulling may download several images, so
+// like an update the request is not aborted when the client disconnects.
+func (s *Server) handleDockerStackAction(w http.ResponseWriter, r *http.Request, action string) {
+	name := mux.Vars(r)["name"]
+	if err := lib.ValidateStackName(name); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	_ = http.NewResponseController(w).SetWriteDeadline(time.Now().Add(dockerUpdateTimeout))
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(r.Context()), dockerUpdateTimeout)
+	defer cancel()
+
+	result, err := s.docker.StackAction(ctx, name, action)
+	switch {
+	case errors.Is(err, controllers.ErrStackNotFound):
+		respondWithError(w, http.StatusNotFound, fmt.Sprintf("Docker Compose stack not found: %s", name))
+	case errors.Is(err, compose.ErrCycle):
+		respondWithError(w, http.StatusConflict, err.Error())
+	case err != nil:
+		respondDockerError(w, action+" stack "+name, err)
+	default:
+		respondJSON(w, http.StatusOK, result)
+	}
+}
@@ -0,0 +1,158 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/dto"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/lib/compose"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/lib/dockerapi/dockertest"
+)
+
+func TestDockerStacks(t *testing.T) {
+	server, _ := setupTestServer()
+	server.dockerCache = []dto.ContainerInfo{
+		{ID: "5a1f0c3e9b7d", Name: "paperless-webserver-1", State: "exited", Labels: map[string]string{
+			compose.LabelProject: "paperless", compose.LabelService: "webserver", compose.LabelDependsOn: "db:service_healthy:false",
+		}},
+		{ID: "7c3b2e5a1d9f", Name: "paperless-db-1", State: "running", CPUPercent: 2.5, Labels: map[string]string{
+			compose.LabelProject: "paperless", compose.LabelService: "db",
+		}},
+		{ID: "3f4e9a1c2b7d", Name: "plex", State: "running"},
+	}
+
+	var stacks []dto.DockerStack
+	getDockerJSON(t, server, "/api/v1/docker/stacks", &stacks)
+	if len(stacks) != 1 || stacks[0].Name != "paperless" || stacks[0].State != compose.StatePartial || len(stacks[0].Services) != 2 {
+		t.Fatalf("unexpected stacks: %+v", stacks)
+	}
+
+	var stack dto.DockerStack
+	getDockerJSON(t, server, "/api/v1/docker/stacks/paperless", &stack)
+	if stack.Running != 1 || stack.CPUPercent != 2.5 || stack.Services[1].DependsOn[0] != "db" {
+		t.Errorf("unexpected stack: %+v", stack)
+	}
+
+	for path, want := range map[string]int{
+		"/api/v1/docker/stacks/immich":    http.StatusNotFound,
+		"/api/v1/docker/stacks/Paperless": http.StatusBadRequest,
+	} {
+		rr := httptest.NewRecorder()
+		server.router.ServeHTTP(rr, httptest.NewRequest("GET", path, nil))
+		if rr.Code != want {
+			t.Errorf("GET %s returned %d, want %d", path, rr.Code, want)
+		}
+	}
+}
+
+// stackActions returns the container actions the fake Engine received, in order.
+func stackActions(engine *dockertest.Server) []string {
+	names := map[string]string{
+		dockertest.PaperlessWebserverID: "webserver",
+		dockertest.PaperlessBrokerID:    "broker",
+		dockertest.PaperlessDBID:        "db",
+	}
+	var actions []string
+	for _, r := range engine.Requests() {
+		rest, ok := strings.CutPrefix(r, "POST /containers/")
+		if !ok {
+			continue
+		}
+		if id, action, ok := strings.Cut(rest, "/"); ok && names[id] != "" {
+			actions = append(actions, action+" "+names[id])
+		}
+	}
+	return actions
+}
+
+func postStackAction(t *testing.T, server *Server, path string) dto.DockerStackResult {
+	t.Helper()
+	rr := httptest.NewRecorder()
+	server.router.ServeHTTP(rr, httptest.NewRequest("POST", path, nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("POST %s returned %d: %s", path, rr.Code, rr.Body.String())
+	}
+	var result dto.DockerStackResult
+	if err := json.Unmarshal(rr.Body.Bytes(), &result); err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+	return result
+}
+
+func TestDockerStackActions(t *testing.T) {
+	t.Run("restart stops in reverse order and starts dependencies first", func(t *testing.T) {
+		server, engine := setupDockerTestServer(t)
+		engine.AddComposeStack()
+
+		result := postStackAction(t, server, "/api/v1/docker/stacks/paperless/restart")
+		if !result.Success || len(result.Steps) != 6 {
+			t.Errorf("unexpected result: %+v", result)
+		}
+		want := []string{"stop webserver", "stop db", "stop broker", "start broker", "start db", "start webserver"}
+		if got := stackActions(engine); !reflect.DeepEqual(got, want) {
+			t.Errorf("actions = %v, want %v", got, want)
+		}
+	})
+
+	t.Run("skips services whose dependencies failed to start", func(t *testing.T) {
+		server, engine := setupDockerTestServer(t)
+		engine.AddComposeStack()
+		engine.Handle("POST /containers/"+dockertest.PaperlessDBID+"/start", func(w http.ResponseWriter, _ *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write([]byte(`{"message": "driver failed programming external connectivity: port is already allocated"}`))
+		})
+
+		result := postStackAction(t, server, "/api/v1/docker/stacks/paperless/start")
+		if result.Success || len(result.Steps) != 3 {
+			t.Fatalf("unexpected result: %+v", result)
+		}
+		db, webserver := result.Steps[1], result.Steps[2]
+		if db.Success || !strings.Contains(db.Error, "port is already allocated") {
+			t.Errorf("unexpected db step: %+v", db)
+		}
+		if !webserver.Skipped || webserver.Error != "dependency db failed to start" {
+			t.Errorf("unexpected webserver step: %+v", webserver)
+		}
+		if n := engine.Count("POST /containers/" + dockertest.PaperlessWebserverID + "/start"); n != 0 {
+			t.Errorf("webserver started %d times", n)
+		}
+	})
+
+	t.Run("pull reports updated images", func(t *testing.T) {
+		server, engine := setupDockerTestServer(t)
+		engine.AddComposeStack()
+		engine.PublishImage("docker.io/library/redis:7", "sha256:0e2c4a6b8d0f2e4c6a8b0d2f4e6c8a0b2d4f6e8c0a2b4d6f8e0c2a4b6d8f0e2c", "sha256:aa11")
+		engine.PublishImage("docker.io/library/postgres:16", "sha256:2d4f6b8a0c2e4d6f8b0a2c4e6d8f0b2a4c6e8d0f2b4a6c8e0d2f4b6a8c0e2d4f", "sha256:bb22")
+
+		result := postStackAction(t, server, "/api/v1/docker/stacks/paperless/pull")
+		steps := make(map[string]dto.DockerStackStep)
+		for _, step := range result.Steps {
+			steps[step.Service] = step
+		}
+		if !steps["broker"].Updated || steps["db"].Updated || !steps["db"].Success {
+			t.Errorf("unexpected steps: %+v", result.Steps)
+		}
+		// The webserver image is neither published nor known locally
+		if result.Success || steps["webserver"].Success {
+			t.Errorf("failed pull reported as success: %+v", result)
+		}
+		if got := stackActions(engine); len(got) != 0 {
+			t.Errorf("pull touched containers: %v", got)
+		}
+	})
+
+	t.Run("unknown stack", func(t *testing.T) {
+		server, engine := setupDockerTestServer(t)
+		engine.AddComposeStack()
+		rr := httptest.NewRecorder()
+		server.router.ServeHTTP(rr, httptest.NewRequest("POST", "/api/v1/docker/stacks/immich/stop", nil))
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("stop of unknown stack returned %d, want %d", rr.Code, http.StatusNotFound)
+		}
+	})
+}
@@ -0,0 +1,78 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/lib"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/services/controllers"
+)
+
+// handleDockerTemplates lists the user's dockerMan templates and whether each is installed.
+func (s *Server) handleDockerTemplates(w http.ResponseWriter, r *http.Request) {
+	templates, err := s.docker.Templates(r.Context())
+	if err != nil {
+		respondDockerError(w, "list Docker templates", err)
+		return
+	}
+	respondJSON(w, http.StatusOK, templates)
+}
+
+// handleDockerTemplate returns the dockerMan template of one container.
+func (s *Server) handleDockerTemplate(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+	if err := lib.ValidateContainerName(name); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	template, err := s.docker.Template(r.Context(), name)
+	if errors.Is(err, controllers.ErrTemplateNotFound) {
+		respondWithError(w, http.StatusNotFound, fmt.Sprintf("Docker template not found: %s", name))
+		return
+	}
+	if err != nil {
+		respondDockerError(w, "get Docker template", err)
+		return
+	}
+	respondJSON(w, http.StatusOK, template)
+}
+
+// handleDockerTemplateCreate pulls the image of a dockerMan template and (re)creates its
+// container, which is started unless start=false. Like an update, the request blocks until the
+// container is created and is not aborted when the client disconnects.
+func (s *Server) handleDockerTemplateCreate(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+	if err := lib.ValidateContainerName(name); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	start := true
+	if value := r.URL.Query().Get("start"); value != "" {
+		var err error
+		if start, err = strconv.ParseBool(value); err != nil {
+			respondWithError(w, http.StatusBadRequest, fmt.Sprintf("invalid start: %s", value))
+			return
+		}
+	}
+
+	_ = http.NewResponseController(w).SetWriteDeadline(time.Now().Add(dockerUpdateTimeout))
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(r.Context()), dockerUpdateTimeout)
+	defer cancel()
+
+	result, err := s.docker.CreateFromTemplate(ctx, name, start)
+	if errors.Is(err, controllers.ErrTemplateNotFound) {
+		respondWithError(w, http.StatusNotFound, fmt.Sprintf("Docker template not found: %s", name))
+		return
+	}
+	if err != nil {
+		respondDockerError(w, "create container "+name+" from template", err)
+		return
+	}
+	respondJSON(w, http.StatusOK, result)
+}
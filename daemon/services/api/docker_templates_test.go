@@ -0,0 +1,172 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/dto"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/lib/dockerapi/dockertest"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/services/controllers"
+)
+
+var testTemplates = map[string]string{
+	"my-plex.xml": `<?xml version="1.0"?>
+<Container version="2">
+  <Name>plex</Name>
+  <Repository>plexinc/pms-docker:1.40.2</Repository>
+  <Network>bridge</Network>
+  <Support>https://forums.plex.tv</Support>
+  <Category>MediaServer:Video</Category>
+  <WebUI>http://[IP]:[PORT:32400]/web</WebUI>
+  <ExtraParams>--restart=unless-stopped --sysctl net.ipv4.ip_forward=1</ExtraParams>
+  <Config Name="Web UI" Target="32400" Default="32400" Mode="tcp" Type="Port" Display="always" Required="true" Mask="false">32400</Config>
+  <Config Name="Claim Token" Target="PLEX_CLAIM" Default="" Mode="" Type="Variable" Display="always" Required="false" Mask="true">claim-s3cr3t</Config>
+</Container>`,
+	"my-jellyfin.xml": `<?xml version="1.0"?>
+<Container version="2">
+  <Name>jellyfin</Name>
+  <Repository>jellyfin/jellyfin:latest</Repository>
+  <Network>host</Network>
+</Container>`,
+}
+
+// setupDockerTemplateServer is setupDockerTestServer with a dockerMan template directory.
+func setupDockerTemplateServer(t *testing.T) (*Server, *dockertest.Server) {
+	t.Helper()
+	dir := t.TempDir()
+	for name, data := range testTemplates {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	engine := dockertest.NewServer(t)
+	server, _ := setupTestServer()
+	server.docker = controllers.NewDockerControllerWithOptions(controllers.DockerOptions{Socket: engine.Socket(), TemplatesDir: dir})
+	return server, engine
+}
+
+func TestDockerTemplates(t *testing.T) {
+	server, _ := setupDockerTemplateServer(t)
+
+	var templates []dto.DockerTemplate
+	getDockerJSON(t, server, "/api/v1/docker/templates", &templates)
+	if len(templates) != 2 {
+		t.Fatalf("got %d templates, want 2", len(templates))
+	}
+	jellyfin, plex := templates[0], templates[1]
+	if jellyfin.Installed || jellyfin.ContainerID != "" {
+		t.Errorf("jellyfin reported as installed: %+v", jellyfin)
+	}
+	if !plex.Installed || plex.ContainerID != dockertest.PlexID[:12] || plex.State != "running" {
+		t.Errorf("plex not linked to its container: %+v", plex)
+	}
+	if plex.Support != "https://forums.plex.tv" || len(plex.Variables) != 2 || plex.Variables[1].Value != "" {
+		t.Errorf("unexpected plex template: %+v", plex)
+	}
+
+	var template dto.DockerTemplate
+	getDockerJSON(t, server, "/api/v1/docker/templates/plex", &template)
+	if template.Name != "plex" || template.WebUI != "http://[IP]:[PORT:32400]/web" {
+		t.Errorf("unexpected template: %+v", template)
+	}
+
+	for path, want := range map[string]int{
+		"/api/v1/docker/templates/sonarr":  http.StatusNotFound,
+		"/api/v1/docker/templates/-plex":   http.StatusBadRequest,
+		"/api/v1/docker/templates/plex%20": http.StatusBadRequest,
+	} {
+		rr := httptest.NewRecorder()
+		server.router.ServeHTTP(rr, httptest.NewRequest("GET", path, nil))
+		if rr.Code != want {
+			t.Errorf("GET %s returned %d, want %d", path, rr.Code, want)
+		}
+	}
+}
+
+func TestDockerTemplateCreate(t *testing.T) {
+	t.Run("replaces the existing container", func(t *testing.T) {
+		server, engine := setupDockerTemplateServer(t)
+
+		rr := httptest.NewRecorder()
+		server.router.ServeHTTP(rr, httptest.NewRequest("POST", "/api/v1/docker/templates/plex/create", nil))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("create returned %d: %s", rr.Code, rr.Body.String())
+		}
+		var result dto.ContainerTemplateResult
+		if err := json.Unmarshal(rr.Body.Bytes(), &result); err != nil {
+			t.Fatalf("invalid response: %v", err)
+		}
+		if !result.Success || !result.Replaced || !result.Started || result.ContainerID == "" {
+			t.Errorf("unexpected result: %+v", result)
+		}
+		if len(result.Warnings) != 1 || !strings.Contains(result.Warnings[0], "--sysctl") {
+			t.Errorf("warnings = %v", result.Warnings)
+		}
+
+		var config struct {
+			Image      string
+			Env        []string
+			HostConfig struct {
+				RestartPolicy struct{ Name string }
+			}
+		}
+		_ = json.Unmarshal(engine.Body("POST /containers/create"), &config)
+		if config.Image != "plexinc/pms-docker:1.40.2" || config.HostConfig.RestartPolicy.Name != "unless-stopped" {
+			t.Errorf("unexpected create body: %s", engine.Body("POST /containers/create"))
+		}
+		if engine.Count("DELETE /containers/"+dockertest.PlexID) != 1 || engine.Count("POST /containers/"+result.ContainerID+"/start") != 1 {
+			t.Errorf("container not replaced, requests: %v", engine.Requests())
+		}
+	})
+
+	t.Run("uses the local image when the pull fails", func(t *testing.T) {
+		server, engine := setupDockerTemplateServer(t)
+		engine.Handle("POST /images/create", func(w http.ResponseWriter, _ *http.Request) {
+			_, _ = w.Write([]byte(`{"error": "Get \"https://registry-1.docker.io/v2/\": dial tcp: lookup registry-1.docker.io: no such host"}`))
+		})
+
+		rr := httptest.NewRecorder()
+		server.router.ServeHTTP(rr, httptest.NewRequest("POST", "/api/v1/docker/templates/plex/create?start=false", nil))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("create returned %d: %s", rr.Code, rr.Body.String())
+		}
+		var result dto.ContainerTemplateResult
+		_ = json.Unmarshal(rr.Body.Bytes(), &result)
+		if result.Started || len(result.Warnings) != 2 || !strings.Contains(result.Warnings[1], "using the local image") {
+			t.Errorf("unexpected result: %+v", result)
+		}
+		for _, r := range engine.Requests() {
+			if strings.HasSuffix(r, "/start") {
+				t.Errorf("container started with start=false: %s", r)
+			}
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		server, engine := setupDockerTemplateServer(t)
+		tests := []struct {
+			path       string
+			wantStatus int
+		}{
+			{"/api/v1/docker/templates/sonarr/create", http.StatusNotFound},
+			{"/api/v1/docker/templates/plex/create?start=maybe", http.StatusBadRequest},
+			// The image of the jellyfin template exists neither in the registry nor locally
+			{"/api/v1/docker/templates/jellyfin/create", http.StatusInternalServerError},
+		}
+		for _, tt := range tests {
+			rr := httptest.NewRecorder()
+			server.router.ServeHTTP(rr, httptest.NewRequest("POST", tt.path, nil))
+			if rr.Code != tt.wantStatus {
+				t.Errorf("POST %s returned %d, want %d: %s", tt.path, rr.Code, tt.wantStatus, rr.Body.String())
+			}
+		}
+		if n := engine.Count("POST /containers/create"); n != 0 {
+			t.Errorf("containers created %d times after errors", n)
+		}
+	})
+}
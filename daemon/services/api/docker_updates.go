@@ -0,0 +1,70 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/dto"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/lib"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/services/controllers"
+)
+
+// dockerUpdateTimeout bounds pulling a new image and recreating the container.
+const dockerUpdateTimeout = 30 * time.Minute
+
+// handleDockerUpdates summarizes the image update status of every container from the last
+// registry check.
+func (s *Server) handleDockerUpdates(w http.ResponseWriter, _ *http.Request) {
+	s.cacheMutex.RLock()
+	containers := s.dockerCache
+	s.cacheMutex.RUnlock()
+
+	updates := dto.ContainerUpdates{
+		Containers: make([]dto.ContainerUpdateInfo, 0, len(containers)),
+		Timestamp:  time.Now(),
+	}
+	for _, container := range containers {
+		updates.Containers = append(updates.Containers, dto.ContainerUpdateInfo{
+			ID:              container.ID,
+			Name:            container.Name,
+			Image:           container.Image,
+			UpdateAvailable: container.UpdateAvailable,
+			ImageUpdate:     container.ImageUpdate,
+		})
+		if container.UpdateAvailable {
+			updates.UpdatesAvailable++
+		}
+	}
+
+	respondJSON(w, http.StatusOK, updates)
+}
+
+// handleDockerUpdate pulls the image of a container and recreates it when a newer image was
+// downloaded. The request blocks until the update finishes and is not aborted when the client
+// disconnects, so a container is never left half-recreated.
+func (s *Server) handleDockerUpdate(w http.ResponseWriter, r *http.Request) {
+	containerID := mux.Vars(r)["id"]
+	if err := lib.ValidateContainerID(containerID); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Pulls of large images outlast the server's write timeout
+	_ = http.NewResponseController(w).SetWriteDeadline(time.Now().Add(dockerUpdateTimeout))
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(r.Context()), dockerUpdateTimeout)
+	defer cancel()
+
+	result, err := s.docker.Update(ctx, containerID)
+	if errors.Is(err, controllers.ErrNoImageTag) {
+		respondWithError(w, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		respondDockerAPIError(w, containerID, "update", err)
+		return
+	}
+	respondJSON(w, http.StatusOK, result)
+}
@@ -0,0 +1,97 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/dto"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/lib/dockerapi/dockertest"
+)
+
+func TestDockerUpdates(t *testing.T) {
+	server, _ := setupTestServer()
+	checked := time.Now()
+	server.dockerCache = []dto.ContainerInfo{
+		{ID: "3f4e9a1c2b7d", Name: "plex", Image: "plexinc/pms-docker:1.40.2", UpdateAvailable: true,
+			ImageUpdate: &dto.ImageUpdateStatus{LocalDigest: "sha256:aaaa", RemoteDigest: "sha256:bbbb", CheckedAt: checked}},
+		{ID: "8c1d2e3f4a5b", Name: "sonarr", Image: "linuxserver/sonarr"},
+	}
+
+	req := httptest.NewRequest("GET", "/api/v1/docker/updates", nil)
+	rr := httptest.NewRecorder()
+	server.router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("GET /docker/updates returned %d: %s", rr.Code, rr.Body.String())
+	}
+	var updates dto.ContainerUpdates
+	if err := json.Unmarshal(rr.Body.Bytes(), &updates); err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+	if updates.UpdatesAvailable != 1 || len(updates.Containers) != 2 {
+		t.Fatalf("unexpected summary: %+v", updates)
+	}
+	if plex := updates.Containers[0]; !plex.UpdateAvailable || plex.ImageUpdate == nil || plex.ImageUpdate.RemoteDigest != "sha256:bbbb" {
+		t.Errorf("unexpected plex entry: %+v", plex)
+	}
+}
+
+func TestDockerUpdate(t *testing.T) {
+	plex := dockertest.PlexID[:12]
+
+	t.Run("newer image", func(t *testing.T) {
+		server, engine := setupDockerTestServer(t)
+		engine.PublishImage("plexinc/pms-docker:1.40.2", "sha256:0123", "sha256:4567")
+
+		result := postDockerUpdate(t, server, plex, http.StatusOK)
+		if !result.Updated || result.ImageID != "sha256:0123" || result.ContainerID == dockertest.PlexID {
+			t.Errorf("unexpected result: %+v", result)
+		}
+		if engine.Count("POST /containers/create") != 1 || engine.Count("DELETE /containers/"+dockertest.PlexID) != 1 {
+			t.Errorf("container not recreated, requests: %v", engine.Requests())
+		}
+	})
+
+	t.Run("already up to date", func(t *testing.T) {
+		server, engine := setupDockerTestServer(t)
+
+		result := postDockerUpdate(t, server, plex, http.StatusOK)
+		if result.Updated || result.ImageID != result.PreviousImageID {
+			t.Errorf("unexpected result: %+v", result)
+		}
+		if engine.Count("POST /images/create") != 1 || engine.Count("POST /containers/create") != 0 {
+			t.Errorf("unexpected requests: %v", engine.Requests())
+		}
+	})
+
+	t.Run("unknown container", func(t *testing.T) {
+		server, _ := setupDockerTestServer(t)
+		postDockerUpdate(t, server, "0123456789ab", http.StatusNotFound)
+	})
+
+	t.Run("invalid ID", func(t *testing.T) {
+		server, _ := setupDockerTestServer(t)
+		postDockerUpdate(t, server, "plex", http.StatusBadRequest)
+	})
+}
+
+func postDockerUpdate(t *testing.T, server *Server, id string, wantStatus int) dto.ContainerUpdateResult {
+	t.Helper()
+	req := httptest.NewRequest("POST", "/api/v1/docker/"+id+"/update", nil)
+	rr := httptest.NewRecorder()
+	server.router.ServeHTTP(rr, req)
+
+	if rr.Code != wantStatus {
+		t.Fatalf("POST /docker/%s/update returned %d, want %d: %s", id, rr.Code, wantStatus, rr.Body.String())
+	}
+	var result dto.ContainerUpdateResult
+	if wantStatus == http.StatusOK {
+		if err := json.Unmarshal(rr.Body.Bytes(), &result); err != nil {
+			t.Fatalf("invalid response: %v", err)
+		}
+	}
+	return result
+}
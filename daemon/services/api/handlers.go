@@ -2,7 +2,9 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"time"
@@ -10,6 +12,8 @@ import (
 	"github.com/gorilla/mux"
 	"github.com/ruaan-deysel/unraid-management-agent/daemon/dto"
 	"github.com/ruaan-deysel/unraid-management-agent/daemon/lib"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/lib/dockerapi"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/lib/libvirt"
 	"github.com/ruaan-deysel/unraid-management-agent/daemon/logger"
 	"github.com/ruaan-deysel/unraid-management-agent/daemon/services/collectors"
 	"github.com/ruaan-deysel/unraid-management-agent/daemon/services/controllers"
@@ -179,6 +183,23 @@ func (s *Server) handleVMInfo(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// handleVMDetails returns the configuration of a VM read from libvirt: disks, NICs,
+// passed-through devices, vCPU pinning, firmware and consoles.
+func (s *Server) handleVMDetails(w http.ResponseWriter, r *http.Request) {
+	vmName := mux.Vars(r)["name"]
+	if err := lib.ValidateVMName(vmName); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	details, err := s.vm.Details(r.Context(), vmName)
+	if err != nil {
+		respondLibvirtError(w, "get details of VM "+vmName, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, details)
+}
+
 func (s *Server) handleUPS(w http.ResponseWriter, _ *http.Request) {
 	// Get latest UPS status from cache
 	s.cacheMutex.RLock()
@@ -258,6 +279,28 @@ func (s *Server) handleDockerOperation(w http.ResponseWriter, r *http.Request, o
 	})
 }
 
+// respondDockerAPIError maps an error from an Engine API call on a container to a response:
+// 404 for an unknown container, 500 for other Engine errors and 503 when the socket cannot be
+// reached. Nothing is written when the client has already gone away.
+func respondDockerAPIError(w http.ResponseWriter, containerID, operation string, err error) {
+	switch {
+	case dockerapi.IsNotFound(err):
+		respondJSON(w, http.StatusNotFound, dto.Response{
+			Success:   false,
+			Message:   fmt.Sprintf("Container not found: %s", containerID),
+			Timestamp: time.Now(),
+		})
+	case dockerapi.IsAPIError(err):
+		logger.Error("API: Failed to %s container %s: %v", operation, containerID, err)
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to %s container: %v", operation, err))
+	case errors.Is(err, context.Canceled):
+		// Client went away; nothing to report
+	default:
+		logger.Error("API: Docker API unavailable to %s container %s: %v", operation, containerID, err)
+		respondWithError(w, http.StatusServiceUnavailable, "Docker API unavailable")
+	}
+}
+
 // Generic VM operation handler to reduce code duplication
 //
 //nolint:dupl // Similar to handleDockerOperation but serves different purpose (VM vs Docker)
@@ -279,6 +322,14 @@ func (s *Server) handleVMOperation(w http.ResponseWriter, r *http.Request, opera
 	logger.Info("%s VM %s", operation, vmName)
 
 	if err := operationFunc(vmName); err != nil {
+		if libvirt.IsNotFound(err) {
+			respondJSON(w, http.StatusNotFound, dto.Response{
+				Success:   false,
+				Message:   fmt.Sprintf("VM not found: %s", vmName),
+				Timestamp: time.Now(),
+			})
+			return
+		}
 		logger.Error("Failed to %s VM %s: %v", operation, vmName, err)
 		respondJSON(w, http.StatusInternalServerError, dto.Response{
 			Success:   false,
@@ -297,64 +348,52 @@ func (s *Server) handleVMOperation(w http.ResponseWriter, r *http.Request, opera
 
 // Docker control handlers
 func (s *Server) handleDockerStart(w http.ResponseWriter, r *http.Request) {
-	controller := controllers.NewDockerController()
-	s.handleDockerOperation(w, r, "started", controller.Start)
+	s.handleDockerOperation(w, r, "started", s.docker.Start)
 }
 
 func (s *Server) handleDockerStop(w http.ResponseWriter, r *http.Request) {
-	controller := controllers.NewDockerController()
-	s.handleDockerOperation(w, r, "stopped", controller.Stop)
+	s.handleDockerOperation(w, r, "stopped", s.docker.Stop)
 }
 
 func (s *Server) handleDockerRestart(w http.ResponseWriter, r *http.Request) {
-	controller := controllers.NewDockerController()
-	s.handleDockerOperation(w, r, "restarted", controller.Restart)
+	s.handleDockerOperation(w, r, "restarted", s.docker.Restart)
 }
 
 func (s *Server) handleDockerPause(w http.ResponseWriter, r *http.Request) {
-	controller := controllers.NewDockerController()
-	s.handleDockerOperation(w, r, "paused", controller.Pause)
+	s.handleDockerOperation(w, r, "paused", s.docker.Pause)
 }
 
 func (s *Server) handleDockerUnpause(w http.ResponseWriter, r *http.Request) {
-	controller := controllers.NewDockerController()
-	s.handleDockerOperation(w, r, "unpaused", controller.Unpause)
+	s.handleDockerOperation(w, r, "unpaused", s.docker.Unpause)
 }
 
 // VM control handlers
 func (s *Server) handleVMStart(w http.ResponseWriter, r *http.Request) {
-	controller := controllers.NewVMController()
-	s.handleVMOperation(w, r, "started", controller.Start)
+	s.handleVMOperation(w, r, "started", s.vm.Start)
 }
 
 func (s *Server) handleVMStop(w http.ResponseWriter, r *http.Request) {
-	controller := controllers.NewVMController()
-	s.handleVMOperation(w, r, "stopped", controller.Stop)
+	s.handleVMOperation(w, r, "stopped", s.vm.Stop)
 }
 
 func (s *Server) handleVMRestart(w http.ResponseWriter, r *http.Request) {
-	controller := controllers.NewVMController()
-	s.handleVMOperation(w, r, "restarted", controller.Restart)
+	s.handleVMOperation(w, r, "restarted", s.vm.Restart)
 }
 
 func (s *Server) handleVMPause(w http.ResponseWriter, r *http.Request) {
-	controller := controllers.NewVMController()
-	s.handleVMOperation(w, r, "paused", controller.Pause)
+	s.handleVMOperation(w, r, "paused", s.vm.Pause)
 }
 
 func (s *Server) handleVMResume(w http.ResponseWriter, r *http.Request) {
-	controller := controllers.NewVMController()
-	s.handleVMOperation(w, r, "resumed", controller.Resume)
+	s.handleVMOperation(w, r, "resumed", s.vm.Resume)
 }
 
 func (s *Server) handleVMHibernate(w http.ResponseWriter, r *http.Request) {
-	controller := controllers.NewVMController()
-	s.handleVMOperation(w, r, "hibernated", controller.Hibernate)
+	s.handleVMOperation(w, r, "hibernated", s.vm.Hibernate)
 }
 
 func (s *Server) handleVMForceStop(w http.ResponseWriter, r *http.Request) {
-	controller := controllers.NewVMController()
-	s.handleVMOperation(w, r, "force stopped", controller.ForceStop)
+	s.handleVMOperation(w, r, "force stopped", s.vm.ForceStop)
 }
 
 // Array control handlers
@@ -9,6 +9,8 @@ import (
 
 	"github.com/ruaan-deysel/unraid-management-agent/daemon/domain"
 	"github.com/ruaan-deysel/unraid-management-agent/daemon/dto"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/lib/libvirt/libvirttest"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/services/controllers"
 )
 
 func setupTestServer() (*Server, *domain.Context) {
@@ -273,6 +275,61 @@ func TestVMControlEndpoint(t *testing.T) {
 	}
 }
 
+func TestVMControlUsesLibvirt(t *testing.T) {
+	server, _ := setupTestServer()
+	libvirtd := libvirttest.NewServer(t)
+	server.vm = controllers.NewVMControllerWithOptions(controllers.VMOptions{Socket: libvirtd.Socket()})
+
+	tests := []struct {
+		path       string
+		wantStatus int
+	}{
+		{"/api/v1/vm/Windows%2011/pause", http.StatusOK},
+		{"/api/v1/vm/Ubuntu%20Server/pause", http.StatusInternalServerError},
+		{"/api/v1/vm/Windows%2011/resume", http.StatusOK},
+		{"/api/v1/vm/Windows%2010/start", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		rr := httptest.NewRecorder()
+		server.router.ServeHTTP(rr, httptest.NewRequest("POST", tt.path, nil))
+		if rr.Code != tt.wantStatus {
+			t.Errorf("POST %s returned %d, want %d: %s", tt.path, rr.Code, tt.wantStatus, rr.Body.String())
+		}
+	}
+	if n := libvirtd.Count("DOMAIN_SUSPEND Windows 11"); n != 1 {
+		t.Errorf("suspend called %d times: %v", n, libvirtd.Requests())
+	}
+}
+
+func TestVMDetails(t *testing.T) {
+	server, _ := setupTestServer()
+	server.vm = controllers.NewVMControllerWithOptions(controllers.VMOptions{Socket: libvirttest.NewServer(t).Socket()})
+
+	rr := httptest.NewRecorder()
+	server.router.ServeHTTP(rr, httptest.NewRequest("GET", "/api/v1/vm/Home%20Assistant/details", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("GET details returned %d: %s", rr.Code, rr.Body.String())
+	}
+	var details dto.VMDetails
+	if err := json.Unmarshal(rr.Body.Bytes(), &details); err != nil {
+		t.Fatal(err)
+	}
+	if details.Name != "Home Assistant" || len(details.Disks) != 1 || details.Disks[0].Format != "qcow2" || details.GuestAgent == nil {
+		t.Errorf("unexpected details: %+v", details)
+	}
+
+	for path, want := range map[string]int{
+		"/api/v1/vm/Windows%2010/details": http.StatusNotFound,
+		"/api/v1/vm/-bad-/details":        http.StatusBadRequest,
+	} {
+		rr := httptest.NewRecorder()
+		server.router.ServeHTTP(rr, httptest.NewRequest("GET", path, nil))
+		if rr.Code != want {
+			t.Errorf("GET %s returned %d, want %d", path, rr.Code, want)
+		}
+	}
+}
+
 func TestCORS(t *testing.T) {
 	server, _ := setupTestServer()
 
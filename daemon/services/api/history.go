@@ -0,0 +1,157 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/constants"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/dto"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/services/history"
+)
+
+// defaultHistoryRange is the query range used when from is omitted.
+const defaultHistoryRange = time.Hour
+
+// handleHistoryMetrics lists the recorded metrics, their entities and the retention tiers
+func (s *Server) handleHistoryMetrics(w http.ResponseWriter, _ *http.Request) {
+	recorded := s.history.Metrics()
+
+	list := dto.HistoryMetricList{
+		Metrics:   make([]dto.HistoryMetric, 0, len(recorded)),
+		Timestamp: time.Now(),
+	}
+	for name, entities := range recorded {
+		if entities == nil {
+			entities = []string{}
+		}
+		list.Metrics = append(list.Metrics, dto.HistoryMetric{Name: name, Entities: entities})
+	}
+	sort.Slice(list.Metrics, func(i, j int) bool { return list.Metrics[i].Name < list.Metrics[j].Name })
+
+	for _, tier := range s.history.Tiers() {
+		list.Tiers = append(list.Tiers, dto.HistoryTier{
+			ResolutionSeconds: int64(tier.Resolution / time.Second),
+			RetentionSeconds:  int64(tier.Retention / time.Second),
+		})
+	}
+
+	respondJSON(w, http.StatusOK, list)
+}
+
+// handleHistory returns the history of one metric.
+// Query parameters: from, to (RFC3339, unix seconds or relative like "-6h"), step (duration or
+// seconds) and entity (limit to one disk, container, VM, ...).
+func (s *Server) handleHistory(w http.ResponseWriter, r *http.Request) {
+	metric := mux.Vars(r)["metric"]
+	query := r.URL.Query()
+	now := time.Now()
+
+	to, err := parseHistoryTime(query.Get("to"), now, now)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("invalid to: %v", err))
+		return
+	}
+	from, err := parseHistoryTime(query.Get("from"), to.Add(-defaultHistoryRange), now)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("invalid from: %v", err))
+		return
+	}
+	step, err := parseHistoryStep(query.Get("step"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("invalid step: %v", err))
+		return
+	}
+
+	result, err := s.history.Query(history.Query{
+		Metric: metric,
+		Entity: query.Get("entity"),
+		From:   from,
+		To:     to,
+		Step:   step,
+	})
+	if errors.Is(err, history.ErrUnknownMetric) {
+		respondJSON(w, http.StatusNotFound, dto.Response{
+			Success:   false,
+			Message:   fmt.Sprintf("Metric not found: %s", metric),
+			Timestamp: time.Now(),
+		})
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response := dto.HistoryResponse{
+		Metric:            result.Metric,
+		From:              result.From.UTC(),
+		To:                result.To.UTC(),
+		StepSeconds:       int64(result.Step / time.Second),
+		ResolutionSeconds: int64(result.Resolution / time.Second),
+		Series:            make([]dto.HistorySeries, 0, len(result.Series)),
+	}
+	for _, series := range result.Series {
+		points := make([]dto.HistoryPoint, len(series.Points))
+		for i, p := range series.Points {
+			points[i] = dto.HistoryPoint{Timestamp: p.Timestamp, Value: p.Value, Min: p.Min, Max: p.Max}
+		}
+		response.Series = append(response.Series, dto.HistorySeries{Entity: series.Entity, Points: points})
+	}
+
+	respondJSON(w, http.StatusOK, response)
+}
+
+// parseHistoryTime parses an absolute RFC3339 time, unix seconds, or a duration relative to now
+// such as "-6h". An empty value returns def.
+func parseHistoryTime(value string, def, now time.Time) (time.Time, error) {
+	if value == "" {
+		return def, nil
+	}
+	if strings.HasPrefix(value, "-") {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return now.Add(d), nil
+	}
+	if unix, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(unix, 0), nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// parseHistoryStep parses a step given as a duration ("5m") or a number of seconds.
+func parseHistoryStep(value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		if seconds <= 0 {
+			return 0, fmt.Errorf("must be positive")
+		}
+		return time.Duration(seconds) * time.Second, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, err
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("must be positive")
+	}
+	return d, nil
+}
+
+// historySaveInterval returns how often the metric history is saved to path. A file on the flash
+// drive is only written once a day, besides on shutdown, to limit wear.
+func historySaveInterval(path string) time.Duration {
+	if path == "/boot" || strings.HasPrefix(path, "/boot/") {
+		return constants.IntervalHistorySaveFlash * time.Second
+	}
+	return constants.IntervalHistorySave * time.Second
+}
@@ -0,0 +1,106 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/dto"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/services/metrics"
+)
+
+func TestHistoryEndpoints(t *testing.T) {
+	server, _ := setupTestServer()
+
+	now := time.Now()
+	server.history.Add([]metrics.Sample{
+		{Metric: metrics.DiskTemperatureCelsius, Entity: "disk1", Value: 34},
+		{Metric: metrics.DiskTemperatureCelsius, Entity: "disk5", Value: 41},
+	}, now.Add(-2*time.Minute))
+	server.history.Add([]metrics.Sample{
+		{Metric: metrics.DiskTemperatureCelsius, Entity: "disk5", Value: 43},
+	}, now.Add(-time.Minute))
+
+	t.Run("list metrics", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		server.router.ServeHTTP(rr, httptest.NewRequest("GET", "/api/v1/history", nil))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("status = %d, want 200: %s", rr.Code, rr.Body.String())
+		}
+
+		var list dto.HistoryMetricList
+		if err := json.Unmarshal(rr.Body.Bytes(), &list); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		if len(list.Metrics) != 1 || list.Metrics[0].Name != metrics.DiskTemperatureCelsius || len(list.Metrics[0].Entities) != 2 {
+			t.Errorf("metrics = %+v", list.Metrics)
+		}
+		if len(list.Tiers) == 0 {
+			t.Error("expected retention tiers in response")
+		}
+	})
+
+	tests := []struct {
+		name       string
+		url        string
+		wantStatus int
+		wantSeries int
+	}{
+		{"default range", "/api/v1/history/disk_temperature_celsius", http.StatusOK, 2},
+		{"entity filter", "/api/v1/history/disk_temperature_celsius?entity=disk5&from=-10m&step=1m", http.StatusOK, 1},
+		{"unix range", "/api/v1/history/disk_temperature_celsius?from=" + strconv.FormatInt(now.Add(-time.Hour).Unix(), 10) + "&to=" + strconv.FormatInt(now.Unix(), 10) + "&step=60", http.StatusOK, 2},
+		{"rfc3339 range", "/api/v1/history/disk_temperature_celsius?from=" + now.Add(-time.Hour).UTC().Format(time.RFC3339), http.StatusOK, 2},
+		{"unknown metric", "/api/v1/history/nope", http.StatusNotFound, 0},
+		{"invalid from", "/api/v1/history/disk_temperature_celsius?from=yesterday", http.StatusBadRequest, 0},
+		{"invalid step", "/api/v1/history/disk_temperature_celsius?step=-5", http.StatusBadRequest, 0},
+		{"inverted range", "/api/v1/history/disk_temperature_celsius?from=-1h&to=-2h", http.StatusBadRequest, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			server.router.ServeHTTP(rr, httptest.NewRequest("GET", tt.url, nil))
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rr.Code, tt.wantStatus, rr.Body.String())
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			var resp dto.HistoryResponse
+			if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if len(resp.Series) != tt.wantSeries {
+				t.Fatalf("got %d series, want %d", len(resp.Series), tt.wantSeries)
+			}
+			for _, series := range resp.Series {
+				if len(series.Points) == 0 {
+					t.Errorf("series %s has no points", series.Entity)
+				}
+			}
+			if resp.StepSeconds < resp.ResolutionSeconds {
+				t.Errorf("step %ds finer than resolution %ds", resp.StepSeconds, resp.ResolutionSeconds)
+			}
+		})
+	}
+}
+
+func TestHistorySaveInterval(t *testing.T) {
+	tests := []struct {
+		path string
+		want time.Duration
+	}{
+		{"/var/local/unraid-management-agent/history.gob.gz", 15 * time.Minute},
+		{"/boot/config/plugins/unraid-management-agent/history.gob.gz", 24 * time.Hour},
+		{"/bootstrap/history.gob.gz", 15 * time.Minute},
+	}
+	for _, tt := range tests {
+		if got := historySaveInterval(tt.path); got != tt.want {
+			t.Errorf("historySaveInterval(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
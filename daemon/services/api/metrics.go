@@ -0,0 +1,383 @@
+package api
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/dto"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/logger"
+)
+
+// prometheusContentType is the Prometheus text exposition format content type
+const prometheusContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// metricSample is a single labelled value of a metric family
+type metricSample struct {
+	labels []string // alternating label names and values
+	value  float64
+}
+
+// metricFamily groups samples sharing a name, help text and type
+type metricFamily struct {
+	name    string
+	help    string
+	typ     string // "gauge" or "counter"
+	samples []metricSample
+}
+
+// metricsWriter accumulates metric families in registration order
+type metricsWriter struct {
+	families []*metricFamily
+	index    map[string]*metricFamily
+}
+
+func newMetricsWriter() *metricsWriter {
+	return &metricsWriter{index: make(map[string]*metricFamily)}
+}
+
+func (m *metricsWriter) add(name, typ, help string, value float64, labels ...string) {
+	family, ok := m.index[name]
+	if !ok {
+		family = &metricFamily{name: name, help: help, typ: typ}
+		m.index[name] = family
+		m.families = append(m.families, family)
+	}
+	family.samples = append(family.samples, metricSample{labels: labels, value: value})
+}
+
+func (m *metricsWriter) gauge(name, help string, value float64, labels ...string) {
+	m.add(name, "gauge", help, value, labels...)
+}
+
+func (m *metricsWriter) counter(name, help string, value float64, labels ...string) {
+	m.add(name, "counter", help, value, labels...)
+}
+
+func (m *metricsWriter) writeTo(w *bufio.Writer) error {
+	for _, family := range m.families {
+		if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", family.name, escapeHelp(family.help), family.name, family.typ); err != nil {
+			return err
+		}
+		for _, sample := range family.samples {
+			if _, err := w.WriteString(family.name); err != nil {
+				return err
+			}
+			if len(sample.labels) > 0 {
+				_ = w.WriteByte('{')
+				for i := 0; i+1 < len(sample.labels); i += 2 {
+					if i > 0 {
+						_ = w.WriteByte(',')
+					}
+					_, _ = fmt.Fprintf(w, `%s="%s"`, sample.labels[i], escapeLabelValue(sample.labels[i+1]))
+				}
+				_ = w.WriteByte('}')
+			}
+			if _, err := fmt.Fprintf(w, " %s\n", formatMetricValue(sample.value)); err != nil {
+				return err
+			}
+		}
+	}
+	return w.Flush()
+}
+
+func escapeHelp(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return strings.ReplaceAll(s, "\n", `\n`)
+}
+
+func escapeLabelValue(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	return strings.ReplaceAll(s, "\n", `\n`)
+}
+
+func formatMetricValue(v float64) string {
+	switch {
+	case math.IsNaN(v):
+		return "NaN"
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// handleMetrics exposes the collector caches in Prometheus text format
+func (s *Server) handleMetrics(w http.ResponseWriter, _ *http.Request) {
+	m := newMetricsWriter()
+
+	s.cacheMutex.RLock()
+	s.collectSystemMetrics(m)
+	s.collectArrayMetrics(m)
+	s.collectDiskMetrics(m)
+	s.collectShareMetrics(m)
+	s.collectContainerMetrics(m)
+	s.collectVMMetrics(m)
+	s.collectUPSMetrics(m)
+	s.collectGPUMetrics(m)
+	s.collectNetworkMetrics(m)
+	s.collectZFSMetrics(m)
+	s.collectNotificationMetrics(m)
+	s.cacheMutex.RUnlock()
+
+	w.Header().Set("Content-Type", prometheusContentType)
+	w.WriteHeader(http.StatusOK)
+	if err := m.writeTo(bufio.NewWriter(w)); err != nil {
+		logger.Debug("Failed to write metrics response: %v", err)
+	}
+}
+
+func (s *Server) collectSystemMetrics(m *metricsWriter) {
+	m.gauge("unraid_agent_info", "Unraid Management Agent build information.", 1, "version", s.ctx.Version)
+
+	info := s.systemCache
+	if info == nil {
+		return
+	}
+
+	m.gauge("unraid_system_info", "Unraid system information.", 1,
+		"hostname", info.Hostname, "version", info.Version, "kernel", info.KernelVersion, "cpu_model", info.CPUModel)
+	m.gauge("unraid_system_uptime_seconds", "System uptime in seconds.", float64(info.Uptime))
+	m.gauge("unraid_cpu_usage_percent", "Overall CPU usage percentage.", info.CPUUsage)
+	m.gauge("unraid_cpu_cores", "Number of physical CPU cores.", float64(info.CPUCores))
+	m.gauge("unraid_cpu_threads", "Number of CPU threads.", float64(info.CPUThreads))
+	m.gauge("unraid_cpu_temperature_celsius", "CPU package temperature in degrees Celsius.", info.CPUTemp)
+
+	cores := make([]string, 0, len(info.CPUPerCore))
+	for core := range info.CPUPerCore {
+		cores = append(cores, core)
+	}
+	sort.Strings(cores)
+	for _, core := range cores {
+		m.gauge("unraid_cpu_core_usage_percent", "CPU usage percentage per core.", info.CPUPerCore[core], "core", core)
+	}
+
+	m.gauge("unraid_memory_total_bytes", "Total system memory in bytes.", float64(info.RAMTotal))
+	m.gauge("unraid_memory_used_bytes", "Used system memory in bytes.", float64(info.RAMUsed))
+	m.gauge("unraid_memory_free_bytes", "Free system memory in bytes.", float64(info.RAMFree))
+	m.gauge("unraid_memory_buffers_bytes", "Memory used for buffers in bytes.", float64(info.RAMBuffers))
+	m.gauge("unraid_memory_cached_bytes", "Memory used for page cache in bytes.", float64(info.RAMCached))
+	m.gauge("unraid_memory_usage_percent", "System memory usage percentage.", info.RAMUsage)
+	m.gauge("unraid_motherboard_temperature_celsius", "Motherboard temperature in degrees Celsius.", info.MotherboardTemp)
+
+	for _, fan := range info.Fans {
+		m.gauge("unraid_fan_speed_rpm", "Fan speed in revolutions per minute.", float64(fan.RPM), "fan", fan.Name)
+	}
+}
+
+func (s *Server) collectArrayMetrics(m *metricsWriter) {
+	array := s.arrayCache
+	if array == nil {
+		return
+	}
+
+	m.gauge("unraid_array_started", "Whether the array is started (1) or stopped (0).", boolToFloat(strings.EqualFold(array.State, "STARTED")), "state", array.State)
+	m.gauge("unraid_array_total_bytes", "Total array capacity in bytes.", float64(array.TotalBytes))
+	m.gauge("unraid_array_free_bytes", "Free array capacity in bytes.", float64(array.FreeBytes))
+	m.gauge("unraid_array_used_percent", "Array usage percentage.", array.UsedPercent)
+	m.gauge("unraid_array_disks", "Number of disks in the array by type.", float64(array.NumDataDisks), "type", "data")
+	m.gauge("unraid_array_disks", "Number of disks in the array by type.", float64(array.NumParityDisks), "type", "parity")
+	m.gauge("unraid_array_parity_valid", "Whether parity is valid.", boolToFloat(array.ParityValid))
+	m.gauge("unraid_array_parity_check_progress_percent", "Parity check progress percentage.", array.ParityCheckProgress)
+}
+
+func (s *Server) collectDiskMetrics(m *metricsWriter) {
+	for _, disk := range s.disksCache {
+		serial := disk.SerialNumber
+		if serial == "" {
+			serial = disk.ID
+		}
+		labels := []string{"disk", disk.Name, "device", disk.Device, "serial", serial, "role", disk.Role}
+
+		m.gauge("unraid_disk_temperature_celsius", "Disk temperature in degrees Celsius (0 when spun down).", disk.Temperature, labels...)
+		m.gauge("unraid_disk_size_bytes", "Disk size in bytes.", float64(disk.Size), labels...)
+		m.gauge("unraid_disk_used_bytes", "Used filesystem space in bytes.", float64(disk.Used), labels...)
+		m.gauge("unraid_disk_free_bytes", "Free filesystem space in bytes.", float64(disk.Free), labels...)
+		m.gauge("unraid_disk_usage_percent", "Filesystem usage percentage.", disk.UsagePercent, labels...)
+		m.gauge("unraid_disk_smart_errors", "Number of disk errors reported by Unraid.", float64(disk.SMARTErrors), labels...)
+		m.gauge("unraid_disk_smart_healthy", "Whether the SMART overall health assessment passed.", boolToFloat(disk.SMARTStatus == "PASSED"), labels...)
+		m.gauge("unraid_disk_spun_down", "Whether the disk is spun down (standby).", boolToFloat(disk.SpinState == "standby"), labels...)
+		m.counter("unraid_disk_read_bytes_total", "Total bytes read from the disk.", float64(disk.ReadBytes), labels...)
+		m.counter("unraid_disk_written_bytes_total", "Total bytes written to the disk.", float64(disk.WriteBytes), labels...)
+		m.counter("unraid_disk_reads_completed_total", "Total read operations completed.", float64(disk.ReadOps), labels...)
+		m.counter("unraid_disk_writes_completed_total", "Total write operations completed.", float64(disk.WriteOps), labels...)
+		if disk.PowerOnHours > 0 {
+			m.counter("unraid_disk_power_on_hours_total", "Disk power-on hours from SMART.", float64(disk.PowerOnHours), labels...)
+		}
+		if smart := s.diskSMART(disk.Device); smart != nil {
+			collectSMARTMetrics(m, smart, labels)
+		}
+	}
+}
+
+// diskSMART returns the cached SMART data of a device. The cache lock must be held.
+func (s *Server) diskSMART(device string) *dto.DiskSMART {
+	for i := range s.smartCache {
+		if s.smartCache[i].Device == device {
+			return &s.smartCache[i]
+		}
+	}
+	return nil
+}
+
+func collectSMARTMetrics(m *metricsWriter, smart *dto.DiskSMART, labels []string) {
+	m.counter("unraid_disk_power_cycles_total", "Disk power cycles from SMART.", float64(smart.PowerCycleCount), labels...)
+	m.gauge("unraid_disk_reallocated_sectors", "Reallocated sectors (ATA attribute 5, SCSI grown defects).", float64(smart.ReallocatedSectors), labels...)
+	m.gauge("unraid_disk_pending_sectors", "Sectors pending reallocation (ATA attribute 197).", float64(smart.PendingSectors), labels...)
+	m.gauge("unraid_disk_offline_uncorrectable_sectors", "Sectors found uncorrectable by offline scans (ATA attribute 198).", float64(smart.OfflineUncorrectable), labels...)
+	m.gauge("unraid_disk_smart_error_log_entries", "Entries in the SMART error log.", float64(smart.ErrorLogCount), labels...)
+	if h := smart.NVMeHealth; h != nil {
+		m.gauge("unraid_disk_nvme_percentage_used", "Percentage of the NVMe endurance used.", float64(h.PercentageUsed), labels...)
+		m.gauge("unraid_disk_nvme_available_spare_percent", "Remaining NVMe spare capacity.", float64(h.AvailableSpare), labels...)
+		m.counter("unraid_disk_nvme_media_errors_total", "NVMe unrecovered data integrity errors.", float64(h.MediaErrors), labels...)
+		m.counter("unraid_disk_nvme_unsafe_shutdowns_total", "NVMe shutdowns without prior notification.", float64(h.UnsafeShutdowns), labels...)
+	}
+}
+
+func (s *Server) collectShareMetrics(m *metricsWriter) {
+	for _, share := range s.sharesCache {
+		m.gauge("unraid_share_used_bytes", "Used space of the share in bytes.", float64(share.Used), "share", share.Name)
+		m.gauge("unraid_share_free_bytes", "Free space of the share in bytes.", float64(share.Free), "share", share.Name)
+		m.gauge("unraid_share_total_bytes", "Total space of the share in bytes.", float64(share.Total), "share", share.Name)
+	}
+}
+
+func (s *Server) collectContainerMetrics(m *metricsWriter) {
+	for _, c := range s.dockerCache {
+		m.gauge("unraid_container_running", "Whether the container is running.", boolToFloat(c.State == "running"), "name", c.Name, "image", c.Image, "state", c.State)
+		m.gauge("unraid_container_cpu_percent", "Container CPU usage percentage.", c.CPUPercent, "name", c.Name)
+		m.gauge("unraid_container_memory_usage_bytes", "Container memory usage in bytes.", float64(c.MemoryUsage), "name", c.Name)
+		m.gauge("unraid_container_memory_limit_bytes", "Container memory limit in bytes.", float64(c.MemoryLimit), "name", c.Name)
+		m.counter("unraid_container_network_receive_bytes_total", "Total bytes received by the container.", float64(c.NetworkRX), "name", c.Name)
+		m.counter("unraid_container_network_transmit_bytes_total", "Total bytes transmitted by the container.", float64(c.NetworkTX), "name", c.Name)
+	}
+}
+
+func (s *Server) collectVMMetrics(m *metricsWriter) {
+	for _, vm := range s.vmsCache {
+		m.gauge("unraid_vm_running", "Whether the VM is running.", boolToFloat(vm.State == "running"), "name", vm.Name, "state", vm.State)
+		m.gauge("unraid_vm_vcpus", "Number of virtual CPUs allocated to the VM.", float64(vm.CPUCount), "name", vm.Name)
+		m.gauge("unraid_vm_guest_cpu_percent", "VM guest CPU usage percentage.", vm.GuestCPUPercent, "name", vm.Name)
+		m.gauge("unraid_vm_host_cpu_percent", "Host CPU usage percentage of the VM process.", vm.HostCPUPercent, "name", vm.Name)
+		m.gauge("unraid_vm_memory_allocated_bytes", "Memory allocated to the VM in bytes.", float64(vm.MemoryAllocated), "name", vm.Name)
+		m.gauge("unraid_vm_memory_used_bytes", "Memory used by the VM in bytes.", float64(vm.MemoryUsed), "name", vm.Name)
+		m.counter("unraid_vm_disk_read_bytes_total", "Total bytes read by the VM.", float64(vm.DiskReadBytes), "name", vm.Name)
+		m.counter("unraid_vm_disk_written_bytes_total", "Total bytes written by the VM.", float64(vm.DiskWriteBytes), "name", vm.Name)
+		m.counter("unraid_vm_network_receive_bytes_total", "Total bytes received by the VM.", float64(vm.NetworkRXBytes), "name", vm.Name)
+		m.counter("unraid_vm_network_transmit_bytes_total", "Total bytes transmitted by the VM.", float64(vm.NetworkTXBytes), "name", vm.Name)
+	}
+}
+
+func (s *Server) collectUPSMetrics(m *metricsWriter) {
+	ups := s.upsCache
+	if ups == nil {
+		return
+	}
+
+	m.gauge("unraid_ups_connected", "Whether a UPS is connected.", boolToFloat(ups.Connected), "model", ups.Model, "status", ups.Status)
+	if !ups.Connected {
+		return
+	}
+	m.gauge("unraid_ups_battery_charge_percent", "UPS battery charge percentage.", ups.BatteryCharge)
+	m.gauge("unraid_ups_load_percent", "UPS load percentage.", ups.LoadPercent)
+	m.gauge("unraid_ups_runtime_seconds", "Estimated UPS runtime remaining in seconds.", float64(ups.RuntimeLeft))
+	m.gauge("unraid_ups_power_watts", "UPS output power in watts.", ups.PowerWatts)
+	m.gauge("unraid_ups_nominal_power_watts", "UPS nominal power in watts.", ups.NominalPower)
+}
+
+func (s *Server) collectGPUMetrics(m *metricsWriter) {
+	for _, gpu := range s.gpuCache {
+		if gpu == nil || !gpu.Available {
+			continue
+		}
+		labels := []string{"index", strconv.Itoa(gpu.Index), "name", gpu.Name, "vendor", gpu.Vendor}
+		m.gauge("unraid_gpu_temperature_celsius", "GPU temperature in degrees Celsius.", gpu.Temperature, labels...)
+		m.gauge("unraid_gpu_utilization_percent", "GPU utilization percentage.", gpu.UtilizationGPU, labels...)
+		m.gauge("unraid_gpu_memory_utilization_percent", "GPU memory utilization percentage.", gpu.UtilizationMemory, labels...)
+		m.gauge("unraid_gpu_memory_total_bytes", "GPU memory size in bytes.", float64(gpu.MemoryTotal), labels...)
+		m.gauge("unraid_gpu_memory_used_bytes", "GPU memory used in bytes.", float64(gpu.MemoryUsed), labels...)
+		m.gauge("unraid_gpu_power_watts", "GPU power draw in watts.", gpu.PowerDraw, labels...)
+	}
+}
+
+func (s *Server) collectNetworkMetrics(m *metricsWriter) {
+	for _, iface := range s.networkCache {
+		m.gauge("unraid_network_up", "Whether the network interface is up.", boolToFloat(iface.State == "up"), "interface", iface.Name)
+		m.gauge("unraid_network_speed_mbps", "Network interface link speed in Mbps.", float64(iface.Speed), "interface", iface.Name)
+		m.counter("unraid_network_receive_bytes_total", "Total bytes received on the interface.", float64(iface.BytesReceived), "interface", iface.Name)
+		m.counter("unraid_network_transmit_bytes_total", "Total bytes transmitted on the interface.", float64(iface.BytesSent), "interface", iface.Name)
+		m.counter("unraid_network_receive_packets_total", "Total packets received on the interface.", float64(iface.PacketsReceived), "interface", iface.Name)
+		m.counter("unraid_network_transmit_packets_total", "Total packets transmitted on the interface.", float64(iface.PacketsSent), "interface", iface.Name)
+		m.counter("unraid_network_receive_errors_total", "Total receive errors on the interface.", float64(iface.ErrorsReceived), "interface", iface.Name)
+		m.counter("unraid_network_transmit_errors_total", "Total transmit errors on the interface.", float64(iface.ErrorsSent), "interface", iface.Name)
+	}
+}
+
+func (s *Server) collectZFSMetrics(m *metricsWriter) {
+	for _, pool := range s.zfsPoolsCache {
+		m.gauge("unraid_zfs_pool_online", "Whether the ZFS pool health is ONLINE.", boolToFloat(pool.Health == "ONLINE"), "pool", pool.Name, "health", pool.Health)
+		m.gauge("unraid_zfs_pool_size_bytes", "ZFS pool size in bytes.", float64(pool.SizeBytes), "pool", pool.Name)
+		m.gauge("unraid_zfs_pool_allocated_bytes", "ZFS pool allocated space in bytes.", float64(pool.AllocatedBytes), "pool", pool.Name)
+		m.gauge("unraid_zfs_pool_free_bytes", "ZFS pool free space in bytes.", float64(pool.FreeBytes), "pool", pool.Name)
+		m.gauge("unraid_zfs_pool_fragmentation_percent", "ZFS pool fragmentation percentage.", pool.FragmentationPct, "pool", pool.Name)
+		m.gauge("unraid_zfs_pool_capacity_percent", "ZFS pool capacity usage percentage.", pool.CapacityPct, "pool", pool.Name)
+		m.gauge("unraid_zfs_pool_dedup_ratio", "ZFS pool deduplication ratio.", pool.DedupRatio, "pool", pool.Name)
+		m.counter("unraid_zfs_pool_read_errors_total", "ZFS pool read errors.", float64(pool.ReadErrors), "pool", pool.Name)
+		m.counter("unraid_zfs_pool_write_errors_total", "ZFS pool write errors.", float64(pool.WriteErrors), "pool", pool.Name)
+		m.counter("unraid_zfs_pool_checksum_errors_total", "ZFS pool checksum errors.", float64(pool.ChecksumErrors), "pool", pool.Name)
+	}
+
+	for _, dataset := range s.zfsDatasetsCache {
+		m.gauge("unraid_zfs_dataset_used_bytes", "ZFS dataset used space in bytes.", float64(dataset.UsedBytes), "dataset", dataset.Name, "type", dataset.Type)
+		m.gauge("unraid_zfs_dataset_available_bytes", "ZFS dataset available space in bytes.", float64(dataset.AvailableBytes), "dataset", dataset.Name, "type", dataset.Type)
+	}
+
+	if len(s.zfsSnapshotsCache) > 0 {
+		m.gauge("unraid_zfs_snapshots", "Number of ZFS snapshots.", float64(len(s.zfsSnapshotsCache)))
+	}
+
+	if arc := s.zfsARCStatsCache; arc != nil {
+		m.gauge("unraid_zfs_arc_size_bytes", "Current ZFS ARC size in bytes.", float64(arc.SizeBytes))
+		m.gauge("unraid_zfs_arc_target_size_bytes", "Target ZFS ARC size in bytes.", float64(arc.TargetSizeBytes))
+		m.gauge("unraid_zfs_arc_max_size_bytes", "Maximum ZFS ARC size in bytes.", float64(arc.MaxSizeBytes))
+		m.gauge("unraid_zfs_arc_hit_ratio", "ZFS ARC hit ratio (0-1).", arc.HitRatioPct/100)
+		m.counter("unraid_zfs_arc_hits_total", "Total ZFS ARC hits.", float64(arc.Hits))
+		m.counter("unraid_zfs_arc_misses_total", "Total ZFS ARC misses.", float64(arc.Misses))
+		if arc.L2SizeBytes > 0 {
+			m.gauge("unraid_zfs_l2arc_size_bytes", "Current L2ARC size in bytes.", float64(arc.L2SizeBytes))
+			m.counter("unraid_zfs_l2arc_hits_total", "Total L2ARC hits.", float64(arc.L2Hits))
+			m.counter("unraid_zfs_l2arc_misses_total", "Total L2ARC misses.", float64(arc.L2Misses))
+		}
+	}
+}
+
+func (s *Server) collectNotificationMetrics(m *metricsWriter) {
+	notifications := s.notificationsCache
+	if notifications == nil {
+		return
+	}
+
+	counts := map[string]dto.NotificationCounts{
+		"unread":  notifications.Overview.Unread,
+		"archive": notifications.Overview.Archive,
+	}
+	for _, state := range []string{"unread", "archive"} {
+		c := counts[state]
+		m.gauge("unraid_notifications", "Number of notifications by state and importance.", float64(c.Alert), "state", state, "importance", "alert")
+		m.gauge("unraid_notifications", "Number of notifications by state and importance.", float64(c.Warning), "state", state, "importance", "warning")
+		m.gauge("unraid_notifications", "Number of notifications by state and importance.", float64(c.Info), "state", state, "importance", "info")
+	}
+}
@@ -0,0 +1,92 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/dto"
+)
+
+func TestMetricsEndpoint(t *testing.T) {
+	server, _ := setupTestServer()
+
+	server.systemCache = &dto.SystemInfo{Hostname: "tower", CPUUsage: 12.5, RAMTotal: 1024, Uptime: 3600}
+	server.disksCache = []dto.DiskInfo{
+		{ID: "WDC_WD40_ABC123", Name: "disk1", Device: "sdb", SerialNumber: "ABC123", Role: "data", Temperature: 34, SpinState: "active", SMARTStatus: "PASSED"},
+	}
+	server.smartCache = []dto.DiskSMART{{Device: "sdb", Name: "disk1", ReallocatedSectors: 8}}
+	server.dockerCache = []dto.ContainerInfo{
+		{ID: "abc", Name: `plex"server`, Image: "plexinc/pms-docker", State: "running", CPUPercent: 3.25, NetworkRX: 2048},
+	}
+	server.zfsARCStatsCache = &dto.ZFSARCStats{SizeBytes: 4096, HitRatioPct: 95, Hits: 950, Misses: 50}
+
+	req := httptest.NewRequest("GET", "/metrics", nil)
+	rr := httptest.NewRecorder()
+	server.router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("/metrics returned %d, want %d", rr.Code, http.StatusOK)
+	}
+	if ct := rr.Header().Get("Content-Type"); ct != prometheusContentType {
+		t.Errorf("Content-Type = %q, want %q", ct, prometheusContentType)
+	}
+
+	body := rr.Body.String()
+	expected := []string{
+		"# TYPE unraid_cpu_usage_percent gauge",
+		"unraid_cpu_usage_percent 12.5",
+		"unraid_system_uptime_seconds 3600",
+		`unraid_disk_temperature_celsius{disk="disk1",device="sdb",serial="ABC123",role="data"} 34`,
+		`unraid_disk_smart_healthy{disk="disk1",device="sdb",serial="ABC123",role="data"} 1`,
+		`unraid_disk_reallocated_sectors{disk="disk1",device="sdb",serial="ABC123",role="data"} 8`,
+		`unraid_container_cpu_percent{name="plex\"server"} 3.25`,
+		"# TYPE unraid_container_network_receive_bytes_total counter",
+		`unraid_container_network_receive_bytes_total{name="plex\"server"} 2048`,
+		"unraid_zfs_arc_hit_ratio 0.95",
+		"unraid_zfs_arc_misses_total 50",
+	}
+	for _, want := range expected {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics output missing %q", want)
+		}
+	}
+
+	if strings.Count(body, "# TYPE unraid_disk_temperature_celsius ") != 1 {
+		t.Error("metric family metadata should be written exactly once")
+	}
+}
+
+func TestMetricsEndpointEmptyCaches(t *testing.T) {
+	server, _ := setupTestServer()
+
+	req := httptest.NewRequest("GET", "/metrics", nil)
+	rr := httptest.NewRecorder()
+	server.router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("/metrics returned %d, want %d", rr.Code, http.StatusOK)
+	}
+	if strings.Contains(rr.Body.String(), "unraid_system_info") {
+		t.Error("system metrics should be omitted before the first collection")
+	}
+}
+
+func TestEscapeLabelValue(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"plain", "plain"},
+		{`a"b`, `a\"b`},
+		{`a\b`, `a\\b`},
+		{"a\nb", `a\nb`},
+	}
+
+	for _, tt := range tests {
+		if got := escapeLabelValue(tt.in); got != tt.want {
+			t.Errorf("escapeLabelValue(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
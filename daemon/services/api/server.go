@@ -2,15 +2,25 @@ package api
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
 	"net/http"
 	"sync"
 	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/constants"
 	"github.com/ruaan-deysel/unraid-management-agent/daemon/domain"
 	"github.com/ruaan-deysel/unraid-management-agent/daemon/dto"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/lib"
 	"github.com/ruaan-deysel/unraid-management-agent/daemon/logger"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/services/alerts"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/services/auth"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/services/controllers"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/services/history"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/services/jobs"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/services/selftest"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/services/webhooks"
 )
 
 // Server represents the HTTP API server that handles REST endpoints and WebSocket connections.
@@ -20,6 +30,17 @@ type Server struct {
 	httpServer *http.Server
 	router     *mux.Router
 	wsHub      *WSHub
+	keyStore   *auth.KeyStore
+	history    *history.Store
+	spin       *history.SpinTracker
+	alerts     *alerts.Engine
+	webhooks   *webhooks.Dispatcher
+	selftest   *selftest.Scheduler
+	docker     *controllers.DockerController
+	vm         *controllers.VMController
+	array      *controllers.ArrayController
+	smart      *controllers.SMARTController
+	jobs       *jobs.Manager
 	cancelCtx  context.Context
 	cancelFunc context.CancelFunc
 
@@ -28,6 +49,7 @@ type Server struct {
 	systemCache        *dto.SystemInfo
 	arrayCache         *dto.ArrayStatus
 	disksCache         []dto.DiskInfo
+	smartCache         []dto.DiskSMART
 	sharesCache        []dto.ShareInfo
 	dockerCache        []dto.ContainerInfo
 	vmsCache           []dto.VMInfo
@@ -48,10 +70,66 @@ type Server struct {
 // It initializes the HTTP router, WebSocket hub, and sets up all API routes.
 func NewServer(ctx *domain.Context) *Server {
 	cancelCtx, cancelFunc := context.WithCancel(context.Background())
+
+	keyStore, err := auth.NewKeyStore(ctx.APIKeysFile)
+	if err != nil {
+		// Fail closed: an unreadable key file must not silently disable authentication
+		logger.Error("Auth: Failed to load API keys from %s: %v", ctx.APIKeysFile, err)
+		logger.Fatal("Refusing to start with an unreadable API key file")
+	}
+	if !keyStore.Enabled() {
+		logger.Warning("Auth: No API keys configured, the API is accessible without authentication")
+	}
+
+	tiers, err := history.ParseTiers(ctx.HistoryRetention)
+	if err != nil {
+		logger.Warning("History: %v, using default retention", err)
+		tiers = history.DefaultTiers
+	}
+	historyStore := history.NewStore(tiers)
+	if ctx.HistoryFile != "" {
+		if err := historyStore.Load(ctx.HistoryFile); err != nil {
+			logger.Warning("History: Failed to load %s, starting empty: %v", ctx.HistoryFile, err)
+		}
+	}
+
+	alertEngine, err := alerts.NewEngine(ctx.AlertRulesFile, ctx.Hub)
+	if err != nil {
+		// Keep the broken file untouched so the rules can be fixed by hand
+		logger.Error("Alerts: Failed to load rules from %s, alerting disabled until fixed: %v", ctx.AlertRulesFile, err)
+		alertEngine, _ = alerts.NewEngine("", ctx.Hub)
+	}
+
+	dispatcher, err := webhooks.NewDispatcher(ctx.WebhooksFile, ctx.Hub)
+	if err != nil {
+		logger.Error("Webhooks: Failed to load targets from %s, webhooks disabled until fixed: %v", ctx.WebhooksFile, err)
+		dispatcher, _ = webhooks.NewDispatcher("", ctx.Hub)
+	}
+
+	smart := controllers.NewSMARTController()
+	array := controllers.NewArrayController(ctx)
+	scheduler, err := selftest.NewScheduler(ctx.SelfTestFile, smart, array)
+	if err != nil {
+		logger.Error("Self-test: Failed to load schedule from %s, scheduled self-tests disabled until fixed: %v", ctx.SelfTestFile, err)
+		scheduler, _ = selftest.NewScheduler("", smart, array)
+	}
+
+	docker := controllers.NewDockerController()
 	s := &Server{
 		ctx:        ctx,
 		router:     mux.NewRouter(),
 		wsHub:      NewWSHub(),
+		keyStore:   keyStore,
+		history:    historyStore,
+		spin:       history.NewSpinTracker(constants.ProcDir, constants.DiskSpinHistorySize),
+		alerts:     alertEngine,
+		webhooks:   dispatcher,
+		selftest:   scheduler,
+		docker:     docker,
+		vm:         controllers.NewVMController(),
+		array:      array,
+		smart:      smart,
+		jobs:       jobs.NewManager(cancelCtx, docker, ctx.Hub),
 		cancelCtx:  cancelCtx,
 		cancelFunc: cancelFunc,
 	}
@@ -65,6 +143,10 @@ func (s *Server) setupRoutes() {
 	s.router.Use(corsMiddleware)
 	s.router.Use(loggingMiddleware)
 	s.router.Use(recoveryMiddleware)
+	s.router.Use(s.authMiddleware)
+
+	// Prometheus metrics (served at the root so scrapers can use the default path)
+	s.router.HandleFunc("/metrics", s.handleMetrics).Methods("GET")
 
 	api := s.router.PathPrefix("/api/v1").Subrouter()
 
@@ -75,16 +157,41 @@ func (s *Server) setupRoutes() {
 	api.HandleFunc("/system", s.handleSystem).Methods("GET")
 	api.HandleFunc("/array", s.handleArray).Methods("GET")
 	api.HandleFunc("/disks", s.handleDisks).Methods("GET")
+	api.HandleFunc("/disks/spin-history", s.handleDisksSpinHistory).Methods("GET")
+	api.HandleFunc("/disks/self-test/schedule", s.handleSelfTestSchedule).Methods("GET")
 	api.HandleFunc("/disks/{id}", s.handleDisk).Methods("GET")
+	api.HandleFunc("/disks/{id}/smart", s.handleDiskSMART).Methods("GET")
+	api.HandleFunc("/disks/{id}/self-test", s.handleDiskSelfTest).Methods("GET")
 	api.HandleFunc("/shares", s.handleShares).Methods("GET")
 	api.HandleFunc("/docker", s.handleDockerList).Methods("GET")
+	api.HandleFunc("/docker/updates", s.handleDockerUpdates).Methods("GET")
+	api.HandleFunc("/docker/images", s.handleDockerImages).Methods("GET")
+	api.HandleFunc("/docker/networks", s.handleDockerNetworks).Methods("GET")
+	api.HandleFunc("/docker/volumes", s.handleDockerVolumes).Methods("GET")
+	api.HandleFunc("/docker/disk-usage", s.handleDockerDiskUsage).Methods("GET")
+	api.HandleFunc("/docker/templates", s.handleDockerTemplates).Methods("GET")
+	api.HandleFunc("/docker/templates/{name}", s.handleDockerTemplate).Methods("GET")
+	api.HandleFunc("/docker/stacks", s.handleDockerStacks).Methods("GET")
+	api.HandleFunc("/docker/stacks/{name}", s.handleDockerStack).Methods("GET")
+	api.HandleFunc("/docker/batch", s.handleDockerBatchJobs).Methods("GET")
+	api.HandleFunc("/docker/batch/{id}", s.handleDockerBatchJob).Methods("GET")
 	api.HandleFunc("/docker/{id}", s.handleDockerInfo).Methods("GET")
+	api.HandleFunc("/docker/{id}/logs", s.handleDockerLogs).Methods("GET")
 	api.HandleFunc("/vm", s.handleVMList).Methods("GET")
 	api.HandleFunc("/vm/{id}", s.handleVMInfo).Methods("GET")
+	api.HandleFunc("/vm/{name}/details", s.handleVMDetails).Methods("GET")
+	api.HandleFunc("/vm/{name}/console", s.handleVMConsole).Methods("GET")
+	api.HandleFunc("/vm/{name}/guest", s.handleVMGuest).Methods("GET")
+	api.HandleFunc("/vm/{name}/snapshots", s.handleVMSnapshots).Methods("GET")
+	api.HandleFunc("/vm/{name}/snapshots/{snapshot}", s.handleVMSnapshot).Methods("GET")
 	api.HandleFunc("/ups", s.handleUPS).Methods("GET")
 	api.HandleFunc("/gpu", s.handleGPU).Methods("GET")
 	api.HandleFunc("/network", s.handleNetwork).Methods("GET")
 
+	// Metric history endpoints
+	api.HandleFunc("/history", s.handleHistoryMetrics).Methods("GET")
+	api.HandleFunc("/history/{metric}", s.handleHistory).Methods("GET")
+
 	// ZFS endpoints
 	api.HandleFunc("/zfs/pools", s.handleZFSPools).Methods("GET")
 	api.HandleFunc("/zfs/pools/{name}", s.handleZFSPool).Methods("GET")
@@ -102,11 +209,22 @@ func (s *Server) setupRoutes() {
 	api.HandleFunc("/hardware/memory-devices", s.handleHardwareMemoryDevices).Methods("GET")
 
 	// Control endpoints
+	api.HandleFunc("/docker/prune/images", s.handleDockerPruneImages).Methods("POST")
+	api.HandleFunc("/docker/prune/volumes", s.handleDockerPruneVolumes).Methods("POST")
+	api.HandleFunc("/docker/prune/containers", s.handleDockerPruneContainers).Methods("POST")
+	api.HandleFunc("/docker/templates/{name}/create", s.handleDockerTemplateCreate).Methods("POST")
+	api.HandleFunc("/docker/stacks/{name}/start", s.handleDockerStackStart).Methods("POST")
+	api.HandleFunc("/docker/stacks/{name}/stop", s.handleDockerStackStop).Methods("POST")
+	api.HandleFunc("/docker/stacks/{name}/restart", s.handleDockerStackRestart).Methods("POST")
+	api.HandleFunc("/docker/stacks/{name}/pull", s.handleDockerStackPull).Methods("POST")
+	api.HandleFunc("/docker/batch", s.handleDockerBatch).Methods("POST")
+	api.HandleFunc("/docker/batch/{id}", s.handleDockerBatchCancel).Methods("DELETE")
 	api.HandleFunc("/docker/{id}/start", s.handleDockerStart).Methods("POST")
 	api.HandleFunc("/docker/{id}/stop", s.handleDockerStop).Methods("POST")
 	api.HandleFunc("/docker/{id}/restart", s.handleDockerRestart).Methods("POST")
 	api.HandleFunc("/docker/{id}/pause", s.handleDockerPause).Methods("POST")
 	api.HandleFunc("/docker/{id}/unpause", s.handleDockerUnpause).Methods("POST")
+	api.HandleFunc("/docker/{id}/update", s.handleDockerUpdate).Methods("POST")
 
 	api.HandleFunc("/vm/{name}/start", s.handleVMStart).Methods("POST")
 	api.HandleFunc("/vm/{name}/stop", s.handleVMStop).Methods("POST")
@@ -115,6 +233,33 @@ func (s *Server) setupRoutes() {
 	api.HandleFunc("/vm/{name}/resume", s.handleVMResume).Methods("POST")
 	api.HandleFunc("/vm/{name}/hibernate", s.handleVMHibernate).Methods("POST")
 	api.HandleFunc("/vm/{name}/force-stop", s.handleVMForceStop).Methods("POST")
+	api.HandleFunc("/vm/{name}/guest/shutdown", s.handleVMGuestShutdown).Methods("POST")
+	api.HandleFunc("/vm/{name}/guest/fsfreeze", s.handleVMGuestFSFreeze).Methods("POST")
+	api.HandleFunc("/vm/{name}/guest/fsthaw", s.handleVMGuestFSThaw).Methods("POST")
+	api.HandleFunc("/vm/{name}/guest/sync-time", s.handleVMGuestSyncTime).Methods("POST")
+	api.HandleFunc("/vm/{name}/snapshots", s.handleVMSnapshotCreate).Methods("POST")
+	api.HandleFunc("/vm/{name}/snapshots/{snapshot}/revert", s.handleVMSnapshotRevert).Methods("POST")
+	api.HandleFunc("/vm/{name}/snapshots/{snapshot}", s.handleVMSnapshotDelete).Methods("DELETE")
+
+	// Alert endpoints
+	api.HandleFunc("/alerts", s.handleAlerts).Methods("GET")
+	api.HandleFunc("/alerts/rules", s.handleAlertRules).Methods("GET")
+	api.HandleFunc("/alerts/rules", s.handleCreateAlertRule).Methods("POST")
+	api.HandleFunc("/alerts/rules/{id}", s.handleAlertRule).Methods("GET")
+	api.HandleFunc("/alerts/rules/{id}", s.handleUpdateAlertRule).Methods("PUT")
+	api.HandleFunc("/alerts/rules/{id}", s.handleDeleteAlertRule).Methods("DELETE")
+
+	// Webhook endpoints
+	api.HandleFunc("/webhooks", s.handleWebhooks).Methods("GET")
+	api.HandleFunc("/webhooks", s.handleCreateWebhook).Methods("POST")
+	api.HandleFunc("/webhooks/dead-letters", s.handleWebhookDeadLetters).Methods("GET")
+	api.HandleFunc("/webhooks/dead-letters", s.handleClearWebhookDeadLetters).Methods("DELETE")
+	api.HandleFunc("/webhooks/dead-letters/{id}/retry", s.handleRetryWebhookDeadLetter).Methods("POST")
+	api.HandleFunc("/webhooks/dead-letters/{id}", s.handleDeleteWebhookDeadLetter).Methods("DELETE")
+	api.HandleFunc("/webhooks/{id}", s.handleWebhook).Methods("GET")
+	api.HandleFunc("/webhooks/{id}", s.handleUpdateWebhook).Methods("PUT")
+	api.HandleFunc("/webhooks/{id}", s.handleDeleteWebhook).Methods("DELETE")
+	api.HandleFunc("/webhooks/{id}/test", s.handleTestWebhook).Methods("POST")
 
 	// Array control endpoints
 	api.HandleFunc("/array/start", s.handleArrayStart).Methods("POST")
@@ -125,6 +270,18 @@ func (s *Server) setupRoutes() {
 	api.HandleFunc("/array/parity-check/resume", s.handleParityCheckResume).Methods("POST")
 	api.HandleFunc("/array/parity-check/history", s.handleParityCheckHistory).Methods("GET")
 
+	// Disk spin control endpoints
+	api.HandleFunc("/disks/spin-down", s.handleDisksSpinDown).Methods("POST")
+	api.HandleFunc("/disks/spin-up", s.handleDisksSpinUp).Methods("POST")
+	api.HandleFunc("/disks/{id}/spin-down", s.handleDiskSpinDown).Methods("POST")
+	api.HandleFunc("/disks/{id}/spin-up", s.handleDiskSpinUp).Methods("POST")
+	api.HandleFunc("/disks/{id}/spin-history", s.handleDiskSpinHistory).Methods("GET")
+
+	// SMART self-test endpoints
+	api.HandleFunc("/disks/self-test/schedule", s.handleUpdateSelfTestSchedule).Methods("PUT")
+	api.HandleFunc("/disks/{id}/self-test", s.handleStartDiskSelfTest).Methods("POST")
+	api.HandleFunc("/disks/{id}/self-test/abort", s.handleAbortDiskSelfTest).Methods("POST")
+
 	// Configuration endpoints (read-only)
 	api.HandleFunc("/shares/{name}/config", s.handleShareConfig).Methods("GET")
 	api.HandleFunc("/network/{interface}/config", s.handleNetworkConfig).Methods("GET")
@@ -161,6 +318,12 @@ func (s *Server) setupRoutes() {
 	api.HandleFunc("/notifications/{id}", s.handleDeleteNotification).Methods("DELETE")
 	api.HandleFunc("/notifications/archive/all", s.handleArchiveAllNotifications).Methods("POST")
 
+	// Authentication endpoints
+	api.HandleFunc("/auth/whoami", s.handleAuthWhoami).Methods("GET")
+	api.HandleFunc("/auth/keys", s.handleAPIKeys).Methods("GET")
+	api.HandleFunc("/auth/keys", s.handleCreateAPIKey).Methods("POST")
+	api.HandleFunc("/auth/keys/{id}", s.handleRevokeAPIKey).Methods("DELETE")
+
 	// Unassigned Devices endpoints (monitoring)
 	api.HandleFunc("/unassigned", s.handleUnassignedDevices).Methods("GET")
 	api.HandleFunc("/unassigned/devices", s.handleUnassignedDevicesList).Methods("GET")
@@ -184,10 +347,25 @@ func (s *Server) StartSubscriptions() {
 	// Broadcast events to WebSocket clients
 	go s.broadcastEvents(s.cancelCtx)
 
+	// Evaluate alert rules
+	go s.alerts.Run(s.cancelCtx)
+
+	// Deliver events to webhook targets
+	go s.webhooks.Run(s.cancelCtx)
+
+	// Record metric history
+	go s.history.Run(s.cancelCtx, s.ctx.Hub, s.ctx.HistoryFile, historySaveInterval(s.ctx.HistoryFile))
+
+	// Record disk spin state changes
+	go s.spin.Run(s.cancelCtx, s.ctx.Hub)
+
+	// Start and follow SMART self-tests
+	go s.selftest.Run(s.cancelCtx, s.ctx.Hub)
+
 	logger.Info("API server subscriptions started")
 }
 
-// StartHTTP starts the HTTP server
+// StartHTTP starts the HTTP server, or the HTTPS server when TLS is enabled
 func (s *Server) StartHTTP() error {
 	s.httpServer = &http.Server{
 		Addr:         fmt.Sprintf(":%d", s.ctx.Port),
@@ -196,8 +374,63 @@ func (s *Server) StartHTTP() error {
 		WriteTimeout: 30 * time.Second,
 	}
 
-	logger.Info("HTTP server listening on %s", s.httpServer.Addr)
-	return s.httpServer.ListenAndServe()
+	if !s.ctx.TLSEnabled {
+		logger.Info("HTTP server listening on %s", s.httpServer.Addr)
+		return s.httpServer.ListenAndServe()
+	}
+
+	certFile, keyFile, err := s.resolveTLSFiles()
+	if err != nil {
+		return err
+	}
+
+	tlsConfig, err := s.buildTLSConfig()
+	if err != nil {
+		return err
+	}
+	s.httpServer.TLSConfig = tlsConfig
+
+	logger.Info("HTTPS server listening on %s (client certificates required: %v)", s.httpServer.Addr, s.ctx.TLSClientCAFile != "")
+	return s.httpServer.ListenAndServeTLS(certFile, keyFile)
+}
+
+// resolveTLSFiles returns the configured certificate and key, generating a
+// self-signed pair on first boot when none is configured
+func (s *Server) resolveTLSFiles() (string, string, error) {
+	if s.ctx.TLSCertFile != "" || s.ctx.TLSKeyFile != "" {
+		if s.ctx.TLSCertFile == "" || s.ctx.TLSKeyFile == "" {
+			return "", "", fmt.Errorf("both TLS certificate and key must be configured")
+		}
+		return s.ctx.TLSCertFile, s.ctx.TLSKeyFile, nil
+	}
+
+	generated, err := lib.EnsureSelfSignedCert(constants.SelfSignedCertFile, constants.SelfSignedKeyFile)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate self-signed certificate: %w", err)
+	}
+	if generated {
+		logger.Warning("TLS: Generated self-signed certificate at %s", constants.SelfSignedCertFile)
+	}
+	return constants.SelfSignedCertFile, constants.SelfSignedKeyFile, nil
+}
+
+// buildTLSConfig creates the TLS configuration, requiring client certificates signed
+// by the configured CA when mutual TLS is enabled
+func (s *Server) buildTLSConfig() (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+	}
+
+	if s.ctx.TLSClientCAFile != "" {
+		pool, err := lib.LoadCertPool(s.ctx.TLSClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client CA: %w", err)
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return tlsConfig, nil
 }
 
 // Start starts both subscriptions and HTTP server (legacy method)
@@ -219,30 +452,19 @@ func (s *Server) Stop() {
 	if err := s.httpServer.Shutdown(ctx); err != nil {
 		logger.Error("Server shutdown error: %v", err)
 	}
+
+	// Persist metric history so it survives the restart
+	if s.ctx.HistoryFile != "" {
+		if err := s.history.Save(s.ctx.HistoryFile); err != nil {
+			logger.Warning("History: Failed to save %s: %v", s.ctx.HistoryFile, err)
+		}
+	}
 }
 
 func (s *Server) subscribeToEvents(ctx context.Context) {
 	// Subscribe to specific events to update cache
 	logger.Info("Cache: Subscribing to event topics...")
-	ch := s.ctx.Hub.Sub(
-		"system_update",
-		"array_status_update",
-		"disk_list_update",
-		"share_list_update",
-		"container_list_update",
-		"vm_list_update",
-		"ups_status_update",
-		"gpu_metrics_update",
-		"network_list_update",
-		"hardware_update",
-		"registration_update",
-		"notifications_update",
-		"unassigned_devices_update",
-		"zfs_pools_update",
-		"zfs_datasets_update",
-		"zfs_snapshots_update",
-		"zfs_arc_stats_update",
-	)
+	ch := s.ctx.Hub.Sub(constants.CollectorTopics()...)
 	logger.Info("Cache: Subscription ready, waiting for events...")
 
 	for {
@@ -269,6 +491,11 @@ func (s *Server) subscribeToEvents(ctx context.Context) {
 				s.disksCache = v
 				s.cacheMutex.Unlock()
 				logger.Debug("Cache: Updated disk list - count=%d", len(v))
+			case []dto.DiskSMART:
+				s.cacheMutex.Lock()
+				s.smartCache = v
+				s.cacheMutex.Unlock()
+				logger.Debug("Cache: Updated disk SMART data - count=%d", len(v))
 			case []dto.ShareInfo:
 				s.cacheMutex.Lock()
 				s.sharesCache = v
@@ -371,28 +598,26 @@ func (s *Server) subscribeToEvents(ctx context.Context) {
 }
 
 func (s *Server) broadcastEvents(ctx context.Context) {
-	// Subscribe to all event topics for WebSocket broadcasting
-	ch := s.ctx.Hub.Sub(
-		"system_update",
-		"array_status_update",
-		"disk_list_update",
-		"share_list_update",
-		"container_list_update",
-		"vm_list_update",
-		"ups_status_update",
-		"gpu_metrics_update",
-		"network_list_update",
-		"hardware_update",
-	)
-
-	for {
-		select {
-		case <-ctx.Done():
-			logger.Info("WebSocket broadcast stopping due to context cancellation")
-			s.ctx.Hub.Unsub(ch)
-			return
-		case msg := <-ch:
-			s.wsHub.Broadcast(msg)
-		}
+	// Subscribe to each topic separately so events are broadcast with their topic name.
+	// Every cached collector topic is streamed, along with agent topics such as alerts.
+	var wg sync.WaitGroup
+	for _, topic := range constants.AllTopics() {
+		ch := s.ctx.Hub.Sub(topic)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					s.ctx.Hub.Unsub(ch)
+					return
+				case msg := <-ch:
+					s.wsHub.Broadcast(topic, msg)
+				}
+			}
+		}()
 	}
+
+	wg.Wait()
+	logger.Info("WebSocket broadcast stopping due to context cancellation")
 }
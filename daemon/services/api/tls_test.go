@@ -0,0 +1,79 @@
+package api
+
+import (
+	"crypto/tls"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/domain"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/lib"
+)
+
+func TestBuildTLSConfig(t *testing.T) {
+	t.Run("server-only TLS", func(t *testing.T) {
+		server := NewServer(&domain.Context{Config: domain.Config{TLSEnabled: true}})
+
+		cfg, err := server.buildTLSConfig()
+		if err != nil {
+			t.Fatalf("buildTLSConfig() error = %v", err)
+		}
+		if cfg.MinVersion != tls.VersionTLS12 {
+			t.Errorf("MinVersion = %x, want TLS 1.2", cfg.MinVersion)
+		}
+		if cfg.ClientAuth != tls.NoClientCert {
+			t.Errorf("ClientAuth = %v, want NoClientCert", cfg.ClientAuth)
+		}
+	})
+
+	t.Run("mutual TLS", func(t *testing.T) {
+		caPEM, _, err := lib.GenerateSelfSignedCert("test-ca", nil, nil, time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		caPath := filepath.Join(t.TempDir(), "ca.pem")
+		if err := os.WriteFile(caPath, caPEM, 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		server := NewServer(&domain.Context{Config: domain.Config{TLSEnabled: true, TLSClientCAFile: caPath}})
+		cfg, err := server.buildTLSConfig()
+		if err != nil {
+			t.Fatalf("buildTLSConfig() error = %v", err)
+		}
+		if cfg.ClientAuth != tls.RequireAndVerifyClientCert {
+			t.Errorf("ClientAuth = %v, want RequireAndVerifyClientCert", cfg.ClientAuth)
+		}
+		if cfg.ClientCAs == nil {
+			t.Error("ClientCAs not set")
+		}
+	})
+
+	t.Run("missing client CA", func(t *testing.T) {
+		server := NewServer(&domain.Context{Config: domain.Config{TLSEnabled: true, TLSClientCAFile: "/nonexistent/ca.pem"}})
+		if _, err := server.buildTLSConfig(); err == nil {
+			t.Error("buildTLSConfig() expected error for missing CA bundle")
+		}
+	})
+}
+
+func TestResolveTLSFiles(t *testing.T) {
+	t.Run("configured certificate", func(t *testing.T) {
+		server := NewServer(&domain.Context{Config: domain.Config{TLSCertFile: "/a.crt", TLSKeyFile: "/a.key"}})
+		cert, key, err := server.resolveTLSFiles()
+		if err != nil {
+			t.Fatalf("resolveTLSFiles() error = %v", err)
+		}
+		if cert != "/a.crt" || key != "/a.key" {
+			t.Errorf("resolveTLSFiles() = %s, %s", cert, key)
+		}
+	})
+
+	t.Run("certificate without key", func(t *testing.T) {
+		server := NewServer(&domain.Context{Config: domain.Config{TLSCertFile: "/a.crt"}})
+		if _, _, err := server.resolveTLSFiles(); err == nil {
+			t.Error("resolveTLSFiles() expected error when key is missing")
+		}
+	})
+}
@@ -0,0 +1,211 @@
+package api
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/constants"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/lib"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/logger"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/services/controllers"
+)
+
+// Console proxy timeouts.
+const (
+	consoleDialTimeout  = 5 * time.Second
+	consoleWriteTimeout = 10 * time.Second
+)
+
+// consoleUpgrader accepts noVNC, which asks for the "binary" subprotocol.
+var consoleUpgrader = websocket.Upgrader{
+	ReadBufferSize:  32 * 1024,
+	WriteBufferSize: 32 * 1024,
+	Subprotocols:    []string{"binary"},
+	CheckOrigin: func(_ *http.Request) bool {
+		return true // Checked by handleVMConsole before connecting to the VM
+	},
+}
+
+// handleVMConsole upgrades to a WebSocket and relays raw RFB between the client and the VM's
+// VNC server, so a noVNC client can attach. The session is closed after the idle timeout
+// (idle_timeout query parameter in seconds) passes without keyboard or mouse input.
+func (s *Server) handleVMConsole(w http.ResponseWriter, r *http.Request) {
+	vmName := mux.Vars(r)["name"]
+	if err := lib.ValidateVMName(vmName); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// With API keys a page on another site cannot authenticate. Without them, any page the user
+	// opens could attach to the console from their browser, so only the agent's own origin may
+	if !s.keyStore.Enabled() && !sameOrigin(r) {
+		logger.Warning("API: Rejected console of VM %s for origin %s while no API keys exist", vmName, r.Header.Get("Origin"))
+		respondWithError(w, http.StatusForbidden, "Cross-origin console connections require API key authentication")
+		return
+	}
+
+	idle := constants.VMConsoleIdleTimeout * time.Second
+	if value := r.URL.Query().Get("idle_timeout"); value != "" {
+		seconds, err := strconv.Atoi(value)
+		if err != nil || seconds < 1 || seconds > constants.VMConsoleMaxIdleTimeout {
+			respondWithError(w, http.StatusBadRequest, fmt.Sprintf("idle_timeout must be between 1 and %d seconds", constants.VMConsoleMaxIdleTimeout))
+			return
+		}
+		idle = time.Duration(seconds) * time.Second
+	}
+
+	addr, err := s.vm.ConsoleAddress(r.Context(), vmName)
+	if errors.Is(err, controllers.ErrNoConsole) {
+		respondWithError(w, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		respondLibvirtError(w, "find console of VM "+vmName, err)
+		return
+	}
+
+	// Connect before upgrading so failures are still reported as HTTP errors
+	vnc, err := net.DialTimeout("tcp", addr, consoleDialTimeout)
+	if err != nil {
+		logger.Error("API: Failed to connect to console of VM %s at %s: %v", vmName, addr, err)
+		respondWithError(w, http.StatusBadGateway, "Failed to connect to VM console")
+		return
+	}
+
+	conn, err := consoleUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// The upgrader has already replied
+		_ = vnc.Close()
+		return
+	}
+
+	logger.Info("VM console: %s attached to %s (%s)", r.RemoteAddr, vmName, addr)
+	reason := proxyConsole(conn, vnc, idle, s.cancelCtx.Done())
+	logger.Info("VM console: %s detached from %s: %s", r.RemoteAddr, vmName, reason)
+}
+
+// proxyConsole relays between the WebSocket and the VNC connection until either side closes,
+// the session is idle or stop is closed. It closes both connections and returns why the session
+// ended.
+func proxyConsole(ws *websocket.Conn, vnc net.Conn, idle time.Duration, stop <-chan struct{}) string {
+	defer func() {
+		_ = vnc.Close()
+		_ = ws.Close()
+	}()
+
+	// The HTTP server's deadlines still apply to the hijacked connection
+	_ = ws.SetReadDeadline(time.Time{})
+
+	var lastInput atomic.Int64
+	lastInput.Store(time.Now().UnixNano())
+	done := make(chan string, 2)
+
+	go func() {
+		buf := make([]byte, 32*1024)
+		for {
+			n, err := vnc.Read(buf)
+			if n > 0 {
+				_ = ws.SetWriteDeadline(time.Now().Add(consoleWriteTimeout))
+				if werr := ws.WriteMessage(websocket.BinaryMessage, buf[:n]); werr != nil {
+					done <- "client write failed"
+					return
+				}
+			}
+			if err != nil {
+				done <- "console closed"
+				return
+			}
+		}
+	}()
+
+	go func() {
+		for {
+			kind, data, err := ws.ReadMessage()
+			if err != nil {
+				done <- "client disconnected"
+				return
+			}
+			if kind != websocket.BinaryMessage {
+				continue
+			}
+			if isUserInput(data) {
+				lastInput.Store(time.Now().UnixNano())
+			}
+			if _, err := vnc.Write(data); err != nil {
+				done <- "console closed"
+				return
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(min(idle/4, 30*time.Second))
+	defer ticker.Stop()
+	for {
+		select {
+		case reason := <-done:
+			return reason
+		case <-stop:
+			closeConsole(ws, websocket.CloseGoingAway, "agent shutting down")
+			return "agent shutting down"
+		case <-ticker.C:
+			if time.Since(time.Unix(0, lastInput.Load())) >= idle {
+				closeConsole(ws, websocket.CloseNormalClosure, "idle timeout")
+				return "idle timeout"
+			}
+		}
+	}
+}
+
+func closeConsole(ws *websocket.Conn, code int, text string) {
+	msg := websocket.FormatCloseMessage(code, text)
+	_ = ws.WriteControl(websocket.CloseMessage, msg, time.Now().Add(time.Second))
+}
+
+// isUserInput reports whether a message from the client carries keyboard or mouse input. noVNC
+// keeps requesting screen updates while the screen changes, so only messages made up entirely of
+// update requests and format or encoding settings do not count. Anything that does not parse as
+// those, such as the handshake, counts as input.
+func isUserInput(data []byte) bool {
+	for len(data) > 0 {
+		var size int
+		switch data[0] {
+		case 0: // SetPixelFormat
+			size = 20
+		case 2: // SetEncodings
+			if len(data) < 4 {
+				return true
+			}
+			size = 4 + 4*int(binary.BigEndian.Uint16(data[2:4]))
+		case 3: // FramebufferUpdateRequest
+			size = 10
+		default: // KeyEvent, PointerEvent, cut text, extensions
+			return true
+		}
+		if len(data) < size {
+			return true
+		}
+		data = data[size:]
+	}
+	return false
+}
+
+// sameOrigin reports whether the request's Origin header, if any, names the host it was sent to.
+// Clients other than browsers send no Origin.
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, r.Host)
+}
@@ -0,0 +1,156 @@
+package api
+
+import (
+	"bytes"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/lib/libvirt/libvirttest"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/services/controllers"
+)
+
+// startVNC starts a fake VNC server that sends the RFB greeting and echoes what it receives.
+func startVNC(t *testing.T) int {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				_, _ = conn.Write([]byte("RFB 003.008\n"))
+				buf := make([]byte, 1024)
+				for {
+					n, err := conn.Read(buf)
+					if err != nil {
+						return
+					}
+					_, _ = conn.Write(buf[:n])
+				}
+			}()
+		}
+	}()
+	return listener.Addr().(*net.TCPAddr).Port
+}
+
+func TestVMConsole(t *testing.T) {
+	server, _ := setupTestServer()
+	libvirtd := libvirttest.NewServer(t)
+	libvirtd.SetVNCPort("Home Assistant", startVNC(t))
+	server.vm = controllers.NewVMControllerWithOptions(controllers.VMOptions{Socket: libvirtd.Socket()})
+	httpServer := httptest.NewServer(server.router)
+	defer httpServer.Close()
+	wsURL := "ws" + strings.TrimPrefix(httpServer.URL, "http") + "/api/v1/vm/Home%20Assistant/console?idle_timeout=1"
+
+	dialer := websocket.Dialer{Subprotocols: []string{"binary"}}
+	conn, resp, err := dialer.Dial(wsURL, nil)
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	defer conn.Close()
+	if resp.Header.Get("Sec-WebSocket-Protocol") != "binary" {
+		t.Errorf("subprotocol = %q", resp.Header.Get("Sec-WebSocket-Protocol"))
+	}
+
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, data, err := conn.ReadMessage(); err != nil || string(data) != "RFB 003.008\n" {
+		t.Fatalf("greeting = %q, %v", data, err)
+	}
+	keyEvent := []byte{4, 1, 0, 0, 0, 0, 0xff, 0x0d}
+	if err := conn.WriteMessage(websocket.BinaryMessage, keyEvent); err != nil {
+		t.Fatal(err)
+	}
+	if _, data, err := conn.ReadMessage(); err != nil || !bytes.Equal(data, keyEvent) {
+		t.Fatalf("echo = %v, %v", data, err)
+	}
+
+	// Screen update requests alone do not keep the session open
+	started := time.Now()
+	updateRequest := []byte{3, 1, 0, 0, 0, 0, 0x04, 0, 0x03, 0}
+	for {
+		if err := conn.WriteMessage(websocket.BinaryMessage, updateRequest); err != nil {
+			break
+		}
+		if _, _, err := conn.ReadMessage(); err != nil {
+			if !websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+				t.Errorf("session ended with %v, want idle timeout", err)
+			}
+			break
+		}
+		if time.Since(started) > 4*time.Second {
+			t.Fatal("idle session was not closed")
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+func TestVMConsoleErrors(t *testing.T) {
+	server, _ := setupTestServer()
+	libvirtd := libvirttest.NewServer(t)
+	server.vm = controllers.NewVMControllerWithOptions(controllers.VMOptions{Socket: libvirtd.Socket()})
+
+	// Nothing listens on the recorded port of Home Assistant
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	libvirtd.SetVNCPort("Home Assistant", listener.Addr().(*net.TCPAddr).Port)
+	_ = listener.Close()
+
+	tests := []struct {
+		path       string
+		origin     string
+		wantStatus int
+	}{
+		{"/api/v1/vm/Ubuntu%20Server/console", "", http.StatusConflict},
+		{"/api/v1/vm/Windows%2011/console", "", http.StatusConflict},
+		{"/api/v1/vm/Windows%2010/console", "", http.StatusNotFound},
+		{"/api/v1/vm/Home%20Assistant/console", "http://example.com", http.StatusBadGateway},
+		{"/api/v1/vm/Home%20Assistant/console?idle_timeout=0", "", http.StatusBadRequest},
+		// Other sites may not use the open API from the user's browser
+		{"/api/v1/vm/Home%20Assistant/console", "https://attacker.example", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", tt.path, nil)
+		if tt.origin != "" {
+			req.Header.Set("Origin", tt.origin)
+		}
+		server.router.ServeHTTP(rr, req)
+		if rr.Code != tt.wantStatus {
+			t.Errorf("GET %s from %q returned %d, want %d: %s", tt.path, tt.origin, rr.Code, tt.wantStatus, rr.Body.String())
+		}
+	}
+}
+
+func TestIsUserInput(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want bool
+	}{
+		{"update request", []byte{3, 1, 0, 0, 0, 0, 4, 0, 3, 0}, false},
+		{"encodings and update request", []byte{2, 0, 0, 1, 0, 0, 0, 7, 3, 1, 0, 0, 0, 0, 4, 0, 3, 0}, false},
+		{"pointer event", []byte{5, 0, 1, 0, 0, 200}, true},
+		{"update request then key event", []byte{3, 1, 0, 0, 0, 0, 4, 0, 3, 0, 4, 1, 0, 0, 0, 0, 0, 0x61}, true},
+		{"handshake", []byte("RFB 003.008\n"), true},
+		{"truncated", []byte{3, 1, 0}, true},
+	}
+	for _, tt := range tests {
+		if got := isUserInput(tt.data); got != tt.want {
+			t.Errorf("%s: isUserInput() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
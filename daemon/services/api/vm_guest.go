@@ -0,0 +1,75 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/dto"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/lib"
+)
+
+// handleVMGuest returns what the QEMU guest agent of a running VM reports.
+func (s *Server) handleVMGuest(w http.ResponseWriter, r *http.Request) {
+	vmName := mux.Vars(r)["name"]
+	if err := lib.ValidateVMName(vmName); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	info, err := s.vm.GuestInfo(r.Context(), vmName)
+	if err != nil {
+		respondLibvirtError(w, "query guest agent of VM "+vmName, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, info)
+}
+
+func (s *Server) handleVMGuestShutdown(w http.ResponseWriter, r *http.Request) {
+	s.handleVMGuestAction(w, r, "shut down", func(ctx context.Context, vmName string) (string, error) {
+		return fmt.Sprintf("VM %s is shutting down", vmName), s.vm.GuestShutdown(ctx, vmName)
+	})
+}
+
+func (s *Server) handleVMGuestFSFreeze(w http.ResponseWriter, r *http.Request) {
+	s.handleVMGuestAction(w, r, "freeze filesystems of", func(ctx context.Context, vmName string) (string, error) {
+		n, err := s.vm.FreezeFilesystems(ctx, vmName)
+		return fmt.Sprintf("Froze %d filesystems of VM %s", n, vmName), err
+	})
+}
+
+func (s *Server) handleVMGuestFSThaw(w http.ResponseWriter, r *http.Request) {
+	s.handleVMGuestAction(w, r, "thaw filesystems of", func(ctx context.Context, vmName string) (string, error) {
+		n, err := s.vm.ThawFilesystems(ctx, vmName)
+		return fmt.Sprintf("Thawed %d filesystems of VM %s", n, vmName), err
+	})
+}
+
+func (s *Server) handleVMGuestSyncTime(w http.ResponseWriter, r *http.Request) {
+	s.handleVMGuestAction(w, r, "sync guest time of", func(ctx context.Context, vmName string) (string, error) {
+		return fmt.Sprintf("Guest time of VM %s synchronized", vmName), s.vm.SyncTime(ctx, vmName)
+	})
+}
+
+// handleVMGuestAction runs a guest agent action on the VM named in the route. The action is not
+// aborted when the client disconnects, so filesystems are not left frozen without the client
+// knowing.
+func (s *Server) handleVMGuestAction(w http.ResponseWriter, r *http.Request, operation string, action func(context.Context, string) (string, error)) {
+	vmName := mux.Vars(r)["name"]
+	if err := lib.ValidateVMName(vmName); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	message, err := action(context.WithoutCancel(r.Context()), vmName)
+	if err != nil {
+		respondLibvirtError(w, operation+" VM "+vmName, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, dto.Response{
+		Success:   true,
+		Message:   message,
+		Timestamp: time.Now(),
+	})
+}
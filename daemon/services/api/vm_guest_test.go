@@ -0,0 +1,49 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/dto"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/lib/libvirt"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/lib/libvirt/libvirttest"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/services/controllers"
+)
+
+func TestVMGuest(t *testing.T) {
+	server, _ := setupTestServer()
+	libvirtd := libvirttest.NewServer(t)
+	server.vm = controllers.NewVMControllerWithOptions(controllers.VMOptions{Socket: libvirtd.Socket()})
+
+	var info dto.VMGuestInfo
+	getDockerJSON(t, server, "/api/v1/vm/Home%20Assistant/guest", &info)
+	if info.Hostname != "homeassistant" || len(info.IPAddresses) != 2 || info.IPAddresses[0] != "192.168.20.57" {
+		t.Errorf("unexpected guest info: %+v", info)
+	}
+
+	tests := []struct {
+		method, path string
+		wantStatus   int
+	}{
+		{"POST", "/api/v1/vm/Home%20Assistant/guest/fsfreeze", http.StatusOK},
+		{"POST", "/api/v1/vm/Home%20Assistant/guest/fsfreeze", http.StatusInternalServerError},
+		{"POST", "/api/v1/vm/Home%20Assistant/guest/fsthaw", http.StatusOK},
+		{"POST", "/api/v1/vm/Home%20Assistant/guest/sync-time", http.StatusOK},
+		{"GET", "/api/v1/vm/Windows%2011/guest", http.StatusConflict},
+		{"POST", "/api/v1/vm/Windows%2011/guest/shutdown", http.StatusConflict},
+		{"POST", "/api/v1/vm/Ubuntu%20Server/guest/sync-time", http.StatusConflict},
+		{"GET", "/api/v1/vm/Windows%2010/guest", http.StatusNotFound},
+		{"POST", "/api/v1/vm/Home%20Assistant/guest/shutdown", http.StatusOK},
+	}
+	for _, tt := range tests {
+		rr := httptest.NewRecorder()
+		server.router.ServeHTTP(rr, httptest.NewRequest(tt.method, tt.path, nil))
+		if rr.Code != tt.wantStatus {
+			t.Errorf("%s %s returned %d, want %d: %s", tt.method, tt.path, rr.Code, tt.wantStatus, rr.Body.String())
+		}
+	}
+	if libvirtd.Frozen("Home Assistant") || libvirtd.State("Home Assistant") != libvirt.StateShutoff {
+		t.Errorf("Home Assistant frozen = %v, state = %d", libvirtd.Frozen("Home Assistant"), libvirtd.State("Home Assistant"))
+	}
+}
@@ -0,0 +1,169 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/dto"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/lib"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/lib/libvirt"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/logger"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/services/controllers"
+)
+
+// vmSnapshotTimeout bounds creating and reverting snapshots, which save or load the memory of a
+// running VM.
+const vmSnapshotTimeout = 10 * time.Minute
+
+// vmSnapshotVars returns the validated VM name and, when the route has one, snapshot name.
+func vmSnapshotVars(w http.ResponseWriter, r *http.Request) (vmName, snapshot string, ok bool) {
+	vars := mux.Vars(r)
+	vmName = vars["name"]
+	if err := lib.ValidateVMName(vmName); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return "", "", false
+	}
+	snapshot, hasSnapshot := vars["snapshot"]
+	if hasSnapshot {
+		if err := lib.ValidateSnapshotName(snapshot); err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return "", "", false
+		}
+	}
+	return vmName, snapshot, true
+}
+
+// handleVMSnapshots lists the snapshots of a VM.
+func (s *Server) handleVMSnapshots(w http.ResponseWriter, r *http.Request) {
+	vmName, _, ok := vmSnapshotVars(w, r)
+	if !ok {
+		return
+	}
+	snapshots, err := s.vm.Snapshots(r.Context(), vmName)
+	if err != nil {
+		respondLibvirtError(w, "list snapshots of VM "+vmName, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, snapshots)
+}
+
+// handleVMSnapshot returns one snapshot of a VM.
+func (s *Server) handleVMSnapshot(w http.ResponseWriter, r *http.Request) {
+	vmName, name, ok := vmSnapshotVars(w, r)
+	if !ok {
+		return
+	}
+	snapshot, err := s.vm.Snapshot(r.Context(), vmName, name)
+	if err != nil {
+		respondLibvirtError(w, "get snapshot "+name+" of VM "+vmName, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, snapshot)
+}
+
+// handleVMSnapshotCreate takes a snapshot of a VM and returns it with 201 Created. Saving the
+// memory of a running VM can take minutes, so the request is not aborted when the client
+// disconnects.
+func (s *Server) handleVMSnapshotCreate(w http.ResponseWriter, r *http.Request) {
+	vmName, _, ok := vmSnapshotVars(w, r)
+	if !ok {
+		return
+	}
+	var req dto.VMSnapshotRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if req.Name != "" {
+		if err := lib.ValidateSnapshotName(req.Name); err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	if err := lib.ValidateMaxLength(req.Description, "description", 1024); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	_ = http.NewResponseController(w).SetWriteDeadline(time.Now().Add(vmSnapshotTimeout))
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(r.Context()), vmSnapshotTimeout)
+	defer cancel()
+
+	result, err := s.vm.CreateSnapshot(ctx, vmName, req)
+	if errors.Is(err, controllers.ErrInvalidSnapshot) {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		respondLibvirtError(w, "snapshot VM "+vmName, err)
+		return
+	}
+	w.Header().Set("Location", "/api/v1/vm/"+url.PathEscape(vmName)+"/snapshots/"+result.Name)
+	respondJSON(w, http.StatusCreated, result)
+}
+
+// handleVMSnapshotRevert restores a VM to a snapshot.
+func (s *Server) handleVMSnapshotRevert(w http.ResponseWriter, r *http.Request) {
+	vmName, name, ok := vmSnapshotVars(w, r)
+	if !ok {
+		return
+	}
+
+	_ = http.NewResponseController(w).SetWriteDeadline(time.Now().Add(vmSnapshotTimeout))
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(r.Context()), vmSnapshotTimeout)
+	defer cancel()
+
+	if err := s.vm.RevertSnapshot(ctx, vmName, name); err != nil {
+		respondLibvirtError(w, "revert VM "+vmName+" to snapshot "+name, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, dto.Response{
+		Success:   true,
+		Message:   fmt.Sprintf("VM %s reverted to snapshot %s", vmName, name),
+		Timestamp: time.Now(),
+	})
+}
+
+// handleVMSnapshotDelete deletes a snapshot of a VM.
+func (s *Server) handleVMSnapshotDelete(w http.ResponseWriter, r *http.Request) {
+	vmName, name, ok := vmSnapshotVars(w, r)
+	if !ok {
+		return
+	}
+	if err := s.vm.DeleteSnapshot(r.Context(), vmName, name); err != nil {
+		respondLibvirtError(w, "delete snapshot "+name+" of VM "+vmName, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, dto.Response{
+		Success:   true,
+		Message:   fmt.Sprintf("Snapshot %s of VM %s deleted", name, vmName),
+		Timestamp: time.Now(),
+	})
+}
+
+// respondLibvirtError maps an error from a libvirt call to a response: 404 for an unknown VM or
+// snapshot, 409 when the VM's state or configuration does not allow the operation or its guest
+// agent does not answer, 500 for other libvirt errors and 503 when the socket cannot be reached.
+// Nothing is written when the client has already gone away.
+func respondLibvirtError(w http.ResponseWriter, operation string, err error) {
+	switch {
+	case libvirt.IsNotFound(err):
+		respondWithError(w, http.StatusNotFound, err.Error())
+	case libvirt.IsConflict(err), libvirt.IsAgentUnavailable(err):
+		respondWithError(w, http.StatusConflict, fmt.Sprintf("Failed to %s: %v", operation, err))
+	case libvirt.IsAPIError(err):
+		logger.Error("API: Failed to %s: %v", operation, err)
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to %s: %v", operation, err))
+	case errors.Is(err, context.Canceled):
+		// Client went away; nothing to report
+	default:
+		logger.Error("API: libvirt unavailable to %s: %v", operation, err)
+		respondWithError(w, http.StatusServiceUnavailable, "libvirt unavailable")
+	}
+}
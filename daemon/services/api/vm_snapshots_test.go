@@ -0,0 +1,88 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/dto"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/lib/libvirt/libvirttest"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/services/controllers"
+)
+
+func TestVMSnapshots(t *testing.T) {
+	server, _ := setupTestServer()
+	libvirtd := libvirttest.NewServer(t)
+	server.vm = controllers.NewVMControllerWithOptions(controllers.VMOptions{Socket: libvirtd.Socket()})
+
+	var snapshots []dto.VMSnapshot
+	getDockerJSON(t, server, "/api/v1/vm/Home%20Assistant/snapshots", &snapshots)
+	if len(snapshots) != 2 || snapshots[1].Parent != "before-2024.10" || snapshots[1].Type != "internal" || !snapshots[1].Memory {
+		t.Fatalf("unexpected snapshots: %+v", snapshots)
+	}
+
+	var snapshot dto.VMSnapshot
+	getDockerJSON(t, server, "/api/v1/vm/Windows%2011/snapshots/pre-update-23H2", &snapshot)
+	if snapshot.Type != "external" || snapshot.Disks[0].File == "" {
+		t.Errorf("unexpected snapshot: %+v", snapshot)
+	}
+
+	rr := httptest.NewRecorder()
+	body := strings.NewReader(`{"name":"pre-24H2","disk_only":true,"quiesce":true}`)
+	server.router.ServeHTTP(rr, httptest.NewRequest("POST", "/api/v1/vm/Windows%2011/snapshots", body))
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("create returned %d: %s", rr.Code, rr.Body.String())
+	}
+	if loc := rr.Header().Get("Location"); loc != "/api/v1/vm/Windows%2011/snapshots/pre-24H2" {
+		t.Errorf("Location = %q", loc)
+	}
+	var result dto.VMSnapshotResult
+	if err := json.Unmarshal(rr.Body.Bytes(), &result); err != nil {
+		t.Fatal(err)
+	}
+	if result.Name != "pre-24H2" || result.Quiesced || result.Warning == "" {
+		t.Errorf("unexpected result: %+v", result)
+	}
+
+	tests := []struct {
+		method, path, body string
+		wantStatus         int
+	}{
+		{"POST", "/api/v1/vm/Home%20Assistant/snapshots/before-2024.10/revert", "", http.StatusOK},
+		{"POST", "/api/v1/vm/Windows%2011/snapshots/pre-24H2/revert", "", http.StatusConflict},
+		{"DELETE", "/api/v1/vm/Windows%2011/snapshots/pre-update-23H2", "", http.StatusConflict},
+		{"DELETE", "/api/v1/vm/Home%20Assistant/snapshots/before-2024.10", "", http.StatusOK},
+		{"GET", "/api/v1/vm/Home%20Assistant/snapshots/before-2024.10", "", http.StatusNotFound},
+		{"GET", "/api/v1/vm/Windows%2010/snapshots", "", http.StatusNotFound},
+		{"GET", "/api/v1/vm/Windows%2011/snapshots/..hidden", "", http.StatusBadRequest},
+		{"POST", "/api/v1/vm/Windows%2011/snapshots", `{"name":"../escape"}`, http.StatusBadRequest},
+		{"POST", "/api/v1/vm/Windows%2011/snapshots", `{"quiesce":true}`, http.StatusBadRequest},
+		{"POST", "/api/v1/vm/Ubuntu%20Server/snapshots", `{"disk_only":true,"quiesce":true}`, http.StatusConflict},
+	}
+	for _, tt := range tests {
+		rr := httptest.NewRecorder()
+		server.router.ServeHTTP(rr, httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body)))
+		if rr.Code != tt.wantStatus {
+			t.Errorf("%s %s returned %d, want %d: %s", tt.method, tt.path, rr.Code, tt.wantStatus, rr.Body.String())
+		}
+	}
+
+	var remaining []dto.VMSnapshot
+	getDockerJSON(t, server, "/api/v1/vm/Home%20Assistant/snapshots", &remaining)
+	if len(remaining) != 1 || remaining[0].Parent != "" {
+		t.Errorf("child not reparented after delete: %+v", remaining)
+	}
+}
+
+func TestVMSnapshotsWithoutLibvirt(t *testing.T) {
+	server, _ := setupTestServer()
+	server.vm = controllers.NewVMControllerWithOptions(controllers.VMOptions{Socket: t.TempDir() + "/libvirt-sock"})
+
+	rr := httptest.NewRecorder()
+	server.router.ServeHTTP(rr, httptest.NewRequest("GET", "/api/v1/vm/Windows%2011/snapshots", nil))
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("GET snapshots without libvirt returned %d, want 503", rr.Code)
+	}
+}
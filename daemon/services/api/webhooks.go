@@ -0,0 +1,121 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/services/webhooks"
+)
+
+// handleWebhooks lists the webhook targets (secrets are never returned)
+func (s *Server) handleWebhooks(w http.ResponseWriter, _ *http.Request) {
+	respondJSON(w, http.StatusOK, s.webhooks.Targets())
+}
+
+// handleWebhook returns a single webhook target
+func (s *Server) handleWebhook(w http.ResponseWriter, r *http.Request) {
+	target, err := s.webhooks.Target(mux.Vars(r)["id"])
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	respondJSON(w, http.StatusOK, target)
+}
+
+// handleCreateWebhook registers a webhook target; enabled defaults to true
+func (s *Server) handleCreateWebhook(w http.ResponseWriter, r *http.Request) {
+	target := webhooks.NewTarget()
+	if err := json.NewDecoder(r.Body).Decode(&target); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	created, err := s.webhooks.CreateTarget(target)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	respondJSON(w, http.StatusCreated, created)
+}
+
+// handleUpdateWebhook replaces a webhook target; an omitted secret keeps the current one
+func (s *Server) handleUpdateWebhook(w http.ResponseWriter, r *http.Request) {
+	target := webhooks.NewTarget()
+	if err := json.NewDecoder(r.Body).Decode(&target); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	updated, err := s.webhooks.UpdateTarget(mux.Vars(r)["id"], target)
+	if errors.Is(err, webhooks.ErrTargetNotFound) {
+		respondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	respondJSON(w, http.StatusOK, updated)
+}
+
+// handleDeleteWebhook removes a webhook target
+func (s *Server) handleDeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	if err := s.webhooks.DeleteTarget(mux.Vars(r)["id"]); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, webhooks.ErrTargetNotFound) {
+			status = http.StatusNotFound
+		}
+		respondWithError(w, status, err.Error())
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]string{"message": "Webhook deleted successfully"})
+}
+
+// handleTestWebhook sends a test event to a webhook target and reports the outcome
+func (s *Server) handleTestWebhook(w http.ResponseWriter, r *http.Request) {
+	err := s.webhooks.SendTest(r.Context(), mux.Vars(r)["id"])
+	if errors.Is(err, webhooks.ErrTargetNotFound) {
+		respondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusBadGateway, "Test delivery failed: "+err.Error())
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]string{"message": "Test delivery succeeded"})
+}
+
+// handleWebhookDeadLetters lists deliveries that failed after all retries
+func (s *Server) handleWebhookDeadLetters(w http.ResponseWriter, _ *http.Request) {
+	respondJSON(w, http.StatusOK, s.webhooks.DeadLetters())
+}
+
+// handleRetryWebhookDeadLetter queues a failed delivery again
+func (s *Server) handleRetryWebhookDeadLetter(w http.ResponseWriter, r *http.Request) {
+	if err := s.webhooks.RetryDeadLetter(mux.Vars(r)["id"]); err != nil {
+		status := http.StatusNotFound
+		if errors.Is(err, webhooks.ErrDeadLetterTruncated) {
+			status = http.StatusConflict
+		}
+		respondWithError(w, status, err.Error())
+		return
+	}
+	respondJSON(w, http.StatusAccepted, map[string]string{"message": "Delivery queued for retry"})
+}
+
+// handleDeleteWebhookDeadLetter discards a failed delivery
+func (s *Server) handleDeleteWebhookDeadLetter(w http.ResponseWriter, r *http.Request) {
+	if err := s.webhooks.DeleteDeadLetter(mux.Vars(r)["id"]); err != nil {
+		respondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]string{"message": "Dead-letter delivery deleted successfully"})
+}
+
+// handleClearWebhookDeadLetters discards all failed deliveries
+func (s *Server) handleClearWebhookDeadLetters(w http.ResponseWriter, _ *http.Request) {
+	s.webhooks.ClearDeadLetters()
+	respondJSON(w, http.StatusOK, map[string]string{"message": "Dead-letter queue cleared"})
+}
@@ -0,0 +1,76 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/dto"
+)
+
+func TestWebhookEndpoints(t *testing.T) {
+	server, _ := setupTestServer()
+
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	do := func(method, url, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		server.router.ServeHTTP(rr, httptest.NewRequest(method, url, bytes.NewBufferString(body)))
+		return rr
+	}
+
+	rr := do("POST", "/api/v1/webhooks", `{"name":"n8n","url":"`+receiver.URL+`","secret":"s3cret","topics":["alert_event"]}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("create status = %d: %s", rr.Code, rr.Body.String())
+	}
+	if strings.Contains(rr.Body.String(), "s3cret") {
+		t.Fatal("create response leaks the secret")
+	}
+	var created dto.WebhookTarget
+	if err := json.Unmarshal(rr.Body.Bytes(), &created); err != nil {
+		t.Fatalf("failed to decode target: %v", err)
+	}
+	if !created.Enabled || !created.HasSecret || created.ContentType != "application/json" {
+		t.Errorf("created target = %+v", created)
+	}
+
+	tests := []struct {
+		name       string
+		method     string
+		url        string
+		body       string
+		wantStatus int
+	}{
+		{"list", "GET", "/api/v1/webhooks", "", http.StatusOK},
+		{"get", "GET", "/api/v1/webhooks/" + created.ID, "", http.StatusOK},
+		{"get missing", "GET", "/api/v1/webhooks/missing", "", http.StatusNotFound},
+		{"create unknown topic", "POST", "/api/v1/webhooks", `{"name":"x","url":"https://x.local","topics":["nope"]}`, http.StatusBadRequest},
+		{"update", "PUT", "/api/v1/webhooks/" + created.ID, `{"name":"n8n","url":"` + receiver.URL + `","topics":["*"]}`, http.StatusOK},
+		{"update missing", "PUT", "/api/v1/webhooks/missing", `{"name":"x","url":"https://x.local","topics":["*"]}`, http.StatusNotFound},
+		{"test delivery", "POST", "/api/v1/webhooks/" + created.ID + "/test", "", http.StatusOK},
+		{"dead letters", "GET", "/api/v1/webhooks/dead-letters", "", http.StatusOK},
+		{"retry missing dead letter", "POST", "/api/v1/webhooks/dead-letters/missing/retry", "", http.StatusNotFound},
+		{"delete missing dead letter", "DELETE", "/api/v1/webhooks/dead-letters/missing", "", http.StatusNotFound},
+		{"clear dead letters", "DELETE", "/api/v1/webhooks/dead-letters", "", http.StatusOK},
+		{"delete", "DELETE", "/api/v1/webhooks/" + created.ID, "", http.StatusOK},
+		{"delete missing", "DELETE", "/api/v1/webhooks/" + created.ID, "", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := do(tt.method, tt.url, tt.body)
+			if rr.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d: %s", rr.Code, tt.wantStatus, rr.Body.String())
+			}
+			if strings.Contains(rr.Body.String(), "s3cret") {
+				t.Error("response leaks the secret")
+			}
+		})
+	}
+}
@@ -2,7 +2,11 @@ package api
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"net/http"
+	"reflect"
+	"sort"
 	"sync"
 	"time"
 
@@ -22,18 +26,35 @@ var upgrader = websocket.Upgrader{
 // It handles client registration, unregistration, and message broadcasting in a thread-safe manner.
 type WSHub struct {
 	clients    map[*WSClient]bool
-	broadcast  chan interface{}
+	broadcast  chan wsMessage
+	direct     chan wsDirect
 	register   chan *WSClient
 	unregister chan *WSClient
 	mu         sync.RWMutex
 }
 
+// wsMessage is an event bus message queued for broadcast together with its topic.
+type wsMessage struct {
+	topic string
+	data  interface{}
+}
+
+// wsDirect is an event addressed to a single client, such as a subscription reply.
+type wsDirect struct {
+	client *WSClient
+	event  dto.WSEvent
+}
+
 // WSClient represents a single WebSocket client connection.
 // It maintains the connection to the hub, the WebSocket connection, and a send channel for outgoing messages.
+// A client receives every topic until it sends its first subscribe request.
 type WSClient struct {
 	hub  *WSHub
 	conn *websocket.Conn
 	send chan dto.WSEvent
+
+	subMu         sync.RWMutex
+	subscriptions map[string]map[string]bool // topic -> entity IDs/names (empty = all entities); nil = all topics
 }
 
 // NewWSHub creates and initializes a new WebSocket hub.
@@ -41,7 +62,8 @@ type WSClient struct {
 func NewWSHub() *WSHub {
 	return &WSHub{
 		clients:    make(map[*WSClient]bool),
-		broadcast:  make(chan interface{}, constants.WSBufferSize),
+		broadcast:  make(chan wsMessage, constants.WSBufferSize),
+		direct:     make(chan wsDirect, constants.WSBufferSize),
 		register:   make(chan *WSClient),
 		unregister: make(chan *WSClient),
 	}
@@ -78,14 +100,31 @@ func (h *WSHub) Run(ctx context.Context) {
 			}
 			h.mu.Unlock()
 
-		case message := <-h.broadcast:
-			h.mu.RLock()
-			event := dto.WSEvent{
-				Event:     "update",
-				Timestamp: time.Now(),
-				Data:      message,
+		case direct := <-h.direct:
+			// Only the hub goroutine sends on client channels, so replies cannot race with close
+			h.mu.Lock()
+			if _, ok := h.clients[direct.client]; ok {
+				select {
+				case direct.client.send <- direct.event:
+				default:
+					logger.Debug("WebSocket client send buffer full, dropping %s reply", direct.event.Event)
+				}
 			}
+			h.mu.Unlock()
+
+		case message := <-h.broadcast:
+			now := time.Now()
+			h.mu.Lock()
 			for client := range h.clients {
+				data, ok := client.filter(message.topic, message.data)
+				if !ok {
+					continue
+				}
+				event := dto.WSEvent{
+					Event:     message.topic,
+					Timestamp: now,
+					Data:      data,
+				}
 				select {
 				case client.send <- event:
 				default:
@@ -93,15 +132,15 @@ func (h *WSHub) Run(ctx context.Context) {
 					delete(h.clients, client)
 				}
 			}
-			h.mu.RUnlock()
+			h.mu.Unlock()
 		}
 	}
 }
 
-// Broadcast sends a message to all connected WebSocket clients.
-// The message is wrapped in a WSEvent and sent asynchronously to each client.
-func (h *WSHub) Broadcast(message interface{}) {
-	h.broadcast <- message
+// Broadcast sends a message published on the given topic to all subscribed WebSocket clients.
+// The message is wrapped in a WSEvent whose event name is the topic.
+func (h *WSHub) Broadcast(topic string, message interface{}) {
+	h.broadcast <- wsMessage{topic: topic, data: message}
 }
 
 func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
@@ -175,9 +214,220 @@ func (c *WSClient) readPump() {
 	})
 
 	for {
-		_, _, err := c.conn.ReadMessage()
+		_, message, err := c.conn.ReadMessage()
 		if err != nil {
 			break
 		}
+		c.handleMessage(message)
+	}
+}
+
+// handleMessage processes a subscription request sent by the client:
+//
+//	{"action": "subscribe", "topics": ["disk_list_update"], "ids": ["disk1"]}
+//	{"action": "unsubscribe", "topics": ["gpu_metrics_update"]}
+//
+// The reply is a "subscriptions" event listing the active subscriptions, or an "error" event.
+func (c *WSClient) handleMessage(message []byte) {
+	var req dto.WSSubscriptionRequest
+	if err := json.Unmarshal(message, &req); err != nil {
+		c.reply("error", "invalid message: expected JSON with action and topics")
+		return
+	}
+
+	for _, topic := range req.Topics {
+		if !constants.IsTopic(topic) {
+			c.reply("error", fmt.Sprintf("unknown topic: %s", topic))
+			return
+		}
+	}
+
+	switch req.Action {
+	case "subscribe":
+		if len(req.Topics) == 0 {
+			c.reply("error", "subscribe requires at least one topic")
+			return
+		}
+		c.subscribe(req.Topics, req.IDs)
+	case "unsubscribe":
+		c.unsubscribe(req.Topics, req.IDs)
+	case "subscriptions":
+	default:
+		c.reply("error", fmt.Sprintf("unknown action: %s", req.Action))
+		return
+	}
+
+	c.reply("subscriptions", c.subscriptionList())
+}
+
+func (c *WSClient) subscribe(topics, ids []string) {
+	c.subMu.Lock()
+	defer c.subMu.Unlock()
+
+	if c.subscriptions == nil {
+		c.subscriptions = make(map[string]map[string]bool)
+	}
+	for _, topic := range topics {
+		filter, exists := c.subscriptions[topic]
+		if len(ids) == 0 {
+			// Subscribing without IDs widens the topic to all entities
+			c.subscriptions[topic] = map[string]bool{}
+			continue
+		}
+		if exists && len(filter) == 0 {
+			continue
+		}
+		if filter == nil {
+			filter = make(map[string]bool)
+			c.subscriptions[topic] = filter
+		}
+		for _, id := range ids {
+			filter[id] = true
+		}
+	}
+}
+
+// unsubscribe removes topics, or only the given entity IDs from them. Unsubscribing with no
+// topics stops all events.
+func (c *WSClient) unsubscribe(topics, ids []string) {
+	c.subMu.Lock()
+	defer c.subMu.Unlock()
+
+	if c.subscriptions == nil {
+		c.subscriptions = make(map[string]map[string]bool)
+		if len(topics) > 0 {
+			// Unsubscribing from the implicit "all topics" state keeps every other topic
+			for _, topic := range constants.AllTopics() {
+				c.subscriptions[topic] = map[string]bool{}
+			}
+		}
+	}
+	if len(topics) == 0 {
+		c.subscriptions = make(map[string]map[string]bool)
+		return
+	}
+
+	for _, topic := range topics {
+		filter, ok := c.subscriptions[topic]
+		if !ok {
+			continue
+		}
+		if len(ids) == 0 || len(filter) == 0 {
+			delete(c.subscriptions, topic)
+			continue
+		}
+		for _, id := range ids {
+			delete(filter, id)
+		}
+		if len(filter) == 0 {
+			delete(c.subscriptions, topic)
+		}
+	}
+}
+
+func (c *WSClient) subscriptionList() []dto.WSSubscription {
+	c.subMu.RLock()
+	defer c.subMu.RUnlock()
+
+	if c.subscriptions == nil {
+		list := make([]dto.WSSubscription, 0, len(constants.AllTopics()))
+		for _, topic := range constants.AllTopics() {
+			list = append(list, dto.WSSubscription{Topic: topic})
+		}
+		return list
+	}
+
+	list := make([]dto.WSSubscription, 0, len(c.subscriptions))
+	for topic, filter := range c.subscriptions {
+		sub := dto.WSSubscription{Topic: topic}
+		for id := range filter {
+			sub.IDs = append(sub.IDs, id)
+		}
+		sort.Strings(sub.IDs)
+		list = append(list, sub)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Topic < list[j].Topic })
+	return list
+}
+
+func (c *WSClient) reply(event string, data interface{}) {
+	c.hub.direct <- wsDirect{
+		client: c,
+		event:  dto.WSEvent{Event: event, Timestamp: time.Now(), Data: data},
+	}
+}
+
+// filter returns the payload to deliver for a topic, or false when the client is not subscribed.
+// Entity filters apply to list payloads and match an element's ID or Name field.
+func (c *WSClient) filter(topic string, data interface{}) (interface{}, bool) {
+	c.subMu.RLock()
+	defer c.subMu.RUnlock()
+
+	if c.subscriptions == nil {
+		return data, true
+	}
+	ids, ok := c.subscriptions[topic]
+	if !ok {
+		return nil, false
+	}
+	if len(ids) == 0 {
+		return data, true
+	}
+	return filterEntities(data, ids)
+}
+
+// filterEntities keeps the elements of a slice payload whose ID or Name is in ids. Single
+// events that carry an ID or Name, such as container_event, are delivered only when they match;
+// other payloads are passed through unchanged.
+func filterEntities(data interface{}, ids map[string]bool) (interface{}, bool) {
+	v := reflect.ValueOf(data)
+	if v.Kind() != reflect.Slice {
+		if isEntity(v) {
+			return data, entityMatches(v, ids)
+		}
+		return data, true
+	}
+
+	filtered := reflect.MakeSlice(v.Type(), 0, v.Len())
+	for i := 0; i < v.Len(); i++ {
+		elem := v.Index(i)
+		if entityMatches(elem, ids) {
+			filtered = reflect.Append(filtered, elem)
+		}
+	}
+	if filtered.Len() == 0 {
+		return nil, false
+	}
+	return filtered.Interface(), true
+}
+
+// isEntity reports whether a payload is a struct with an ID or Name field.
+func isEntity(v reflect.Value) bool {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return false
+		}
+		v = v.Elem()
+	}
+	return v.Kind() == reflect.Struct && (v.FieldByName("ID").IsValid() || v.FieldByName("Name").IsValid())
+}
+
+func entityMatches(elem reflect.Value, ids map[string]bool) bool {
+	for elem.Kind() == reflect.Ptr || elem.Kind() == reflect.Interface {
+		if elem.IsNil() {
+			return false
+		}
+		elem = elem.Elem()
+	}
+	if elem.Kind() != reflect.Struct {
+		return false
+	}
+
+	for _, name := range []string{"ID", "Name"} {
+		field := elem.FieldByName(name)
+		if field.IsValid() && ids[fmt.Sprint(field.Interface())] {
+			return true
+		}
 	}
+	return false
 }
@@ -2,9 +2,15 @@ package api
 
 import (
 	"context"
+	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/cskr/pubsub"
+	"github.com/gorilla/websocket"
+
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/constants"
 	"github.com/ruaan-deysel/unraid-management-agent/daemon/dto"
 )
 
@@ -125,13 +131,16 @@ func TestWSHubBroadcast(t *testing.T) {
 	time.Sleep(10 * time.Millisecond)
 
 	testMessage := map[string]string{"test": "message"}
-	hub.Broadcast(testMessage)
+	hub.Broadcast("system_update", testMessage)
 
 	select {
 	case msg := <-clientSend:
 		if msg.Data == nil {
 			t.Error("Received nil data")
 		}
+		if msg.Event != "system_update" {
+			t.Errorf("event = %q, want the topic name %q", msg.Event, "system_update")
+		}
 	case <-time.After(500 * time.Millisecond):
 		t.Error("Did not receive broadcast message")
 	}
@@ -169,7 +178,7 @@ func TestWSHubMultipleClients(t *testing.T) {
 	}
 
 	testMessage := "test broadcast"
-	hub.Broadcast(testMessage)
+	hub.Broadcast("system_update", testMessage)
 
 	for i := 0; i < numClients; i++ {
 		select {
@@ -245,6 +254,212 @@ func TestWSHubClosesClientsOnShutdown(t *testing.T) {
 	}
 }
 
+func TestWSClientSubscriptions(t *testing.T) {
+	disks := []dto.DiskInfo{{ID: "WDC_1", Name: "disk1"}, {ID: "WDC_2", Name: "disk2"}}
+	containers := []*dto.ContainerInfo{{ID: "abc123", Name: "plex"}, {ID: "def456", Name: "sonarr"}}
+
+	tests := []struct {
+		name      string
+		setup     func(c *WSClient)
+		topic     string
+		data      interface{}
+		wantSent  bool
+		wantCount int
+	}{
+		{
+			name:     "receives everything by default",
+			topic:    "gpu_metrics_update",
+			data:     []*dto.GPUMetrics{},
+			wantSent: true,
+		},
+		{
+			name:     "drops unsubscribed topics",
+			setup:    func(c *WSClient) { c.subscribe([]string{"disk_list_update"}, nil) },
+			topic:    "gpu_metrics_update",
+			data:     []*dto.GPUMetrics{},
+			wantSent: false,
+		},
+		{
+			name:      "filters list entries by name",
+			setup:     func(c *WSClient) { c.subscribe([]string{"disk_list_update"}, []string{"disk2"}) },
+			topic:     "disk_list_update",
+			data:      disks,
+			wantSent:  true,
+			wantCount: 1,
+		},
+		{
+			name:      "filters pointer entries by ID",
+			setup:     func(c *WSClient) { c.subscribe([]string{"container_list_update"}, []string{"abc123"}) },
+			topic:     "container_list_update",
+			data:      containers,
+			wantSent:  true,
+			wantCount: 1,
+		},
+		{
+			name:     "drops events without matching entities",
+			setup:    func(c *WSClient) { c.subscribe([]string{"container_list_update"}, []string{"radarr"}) },
+			topic:    "container_list_update",
+			data:     containers,
+			wantSent: false,
+		},
+		{
+			name:     "passes through non-list payloads",
+			setup:    func(c *WSClient) { c.subscribe([]string{"system_update"}, []string{"ignored"}) },
+			topic:    "system_update",
+			data:     &dto.SystemInfo{Hostname: "tower"},
+			wantSent: true,
+		},
+		{
+			name:     "filters single events by ID",
+			setup:    func(c *WSClient) { c.subscribe([]string{"docker_batch_job"}, []string{"5f0c9a1e"}) },
+			topic:    "docker_batch_job",
+			data:     &dto.DockerBatchJob{ID: "5f0c9a1e"},
+			wantSent: true,
+		},
+		{
+			name:     "drops single events of other entities",
+			setup:    func(c *WSClient) { c.subscribe([]string{"container_event"}, []string{"plex"}) },
+			topic:    "container_event",
+			data:     &dto.ContainerEvent{ID: "abc123", Name: "sonarr"},
+			wantSent: false,
+		},
+		{
+			name: "unsubscribe from implicit all keeps other topics",
+			setup: func(c *WSClient) {
+				c.unsubscribe([]string{"gpu_metrics_update"}, nil)
+			},
+			topic:     "disk_list_update",
+			data:      disks,
+			wantSent:  true,
+			wantCount: 2,
+		},
+		{
+			name: "unsubscribe removes topic",
+			setup: func(c *WSClient) {
+				c.subscribe([]string{"disk_list_update", "system_update"}, nil)
+				c.unsubscribe([]string{"disk_list_update"}, nil)
+			},
+			topic:    "disk_list_update",
+			data:     disks,
+			wantSent: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &WSClient{}
+			if tt.setup != nil {
+				tt.setup(client)
+			}
+
+			got, sent := client.filter(tt.topic, tt.data)
+			if sent != tt.wantSent {
+				t.Fatalf("filter() sent = %v, want %v", sent, tt.wantSent)
+			}
+			if tt.wantCount > 0 {
+				switch v := got.(type) {
+				case []dto.DiskInfo:
+					if len(v) != tt.wantCount {
+						t.Errorf("got %d disks, want %d", len(v), tt.wantCount)
+					}
+				case []*dto.ContainerInfo:
+					if len(v) != tt.wantCount {
+						t.Errorf("got %d containers, want %d", len(v), tt.wantCount)
+					}
+				default:
+					t.Errorf("unexpected filtered type %T", got)
+				}
+			}
+		})
+	}
+}
+
+func TestWebSocketSubscribeMessages(t *testing.T) {
+	server, _ := setupTestServer()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go server.wsHub.Run(ctx)
+
+	ts := httptest.NewServer(server.router)
+	defer ts.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http")+"/api/v1/ws", nil)
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	defer conn.Close()
+
+	readEvent := func() dto.WSEvent {
+		t.Helper()
+		var event dto.WSEvent
+		_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		if err := conn.ReadJSON(&event); err != nil {
+			t.Fatalf("failed to read event: %v", err)
+		}
+		return event
+	}
+
+	if err := conn.WriteJSON(dto.WSSubscriptionRequest{Action: "subscribe", Topics: []string{"bogus_update"}}); err != nil {
+		t.Fatal(err)
+	}
+	if event := readEvent(); event.Event != "error" {
+		t.Errorf("unknown topic reply = %q, want error", event.Event)
+	}
+
+	if err := conn.WriteJSON(dto.WSSubscriptionRequest{Action: "subscribe", Topics: []string{"disk_list_update"}, IDs: []string{"disk1"}}); err != nil {
+		t.Fatal(err)
+	}
+	event := readEvent()
+	if event.Event != "subscriptions" {
+		t.Fatalf("subscribe reply = %q, want subscriptions", event.Event)
+	}
+
+	server.wsHub.Broadcast("gpu_metrics_update", []*dto.GPUMetrics{{Name: "GPU"}})
+	server.wsHub.Broadcast("disk_list_update", []dto.DiskInfo{{Name: "disk1"}, {Name: "disk2"}})
+
+	event = readEvent()
+	if event.Event != "disk_list_update" {
+		t.Fatalf("received %q, want only disk_list_update", event.Event)
+	}
+	if list, ok := event.Data.([]interface{}); !ok || len(list) != 1 {
+		t.Errorf("expected one filtered disk, got %v", event.Data)
+	}
+}
+
+func TestBroadcastEventsStreamsAllCollectorTopics(t *testing.T) {
+	server, ctx := setupTestServer()
+	ctx.Hub = pubsub.New(16)
+
+	runCtx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go server.wsHub.Run(runCtx)
+	go server.broadcastEvents(runCtx)
+	time.Sleep(50 * time.Millisecond)
+
+	send := make(chan dto.WSEvent, 256)
+	server.wsHub.register <- &WSClient{hub: server.wsHub, send: send}
+
+	received := make(map[string]bool)
+	for _, topic := range constants.CollectorTopics() {
+		ctx.Hub.Pub(struct{}{}, topic)
+	}
+
+	deadline := time.After(2 * time.Second)
+	for len(received) < len(constants.CollectorTopics()) {
+		select {
+		case event := <-send:
+			received[event.Event] = true
+		case <-deadline:
+			for _, topic := range constants.CollectorTopics() {
+				if !received[topic] {
+					t.Errorf("topic %s was not streamed to WebSocket clients", topic)
+				}
+			}
+			return
+		}
+	}
+}
+
 func BenchmarkWSHubBroadcast(b *testing.B) {
 	hub := NewWSHub()
 	ctx, cancel := context.WithCancel(context.Background())
@@ -270,6 +485,6 @@ func BenchmarkWSHubBroadcast(b *testing.B) {
 	b.ResetTimer()
 
 	for i := 0; i < b.N; i++ {
-		hub.Broadcast(map[string]int{"count": i})
+		hub.Broadcast("system_update", map[string]int{"count": i})
 	}
 }
@@ -0,0 +1,373 @@
+// Package auth provides API key management and scope checks for the REST and WebSocket API.
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/lib"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/logger"
+)
+
+// Scope represents a permission level granted to an API key.
+type Scope string
+
+const (
+	// ScopeRead allows read-only access to monitoring endpoints and the WebSocket stream.
+	ScopeRead Scope = "read"
+	// ScopeControl allows lifecycle operations such as starting containers or VMs.
+	ScopeControl Scope = "control"
+	// ScopeAdmin allows configuration changes, script execution and key management.
+	ScopeAdmin Scope = "admin"
+)
+
+// keyPrefix is prepended to generated keys so they are easy to recognise in configs and logs.
+const keyPrefix = "uma_"
+
+// ErrKeyNotFound is returned when no API key has the given ID or name.
+var ErrKeyNotFound = errors.New("API key not found")
+
+// ErrLastAdminKey is returned when revoking a key would leave no key that can manage the others.
+var ErrLastAdminKey = errors.New("cannot revoke the last admin key")
+
+// reloadInterval is how often the key file modification time is checked for external changes.
+const reloadInterval = 5 * time.Second
+
+var scopeRank = map[Scope]int{
+	ScopeRead:    1,
+	ScopeControl: 2,
+	ScopeAdmin:   3,
+}
+
+// Includes reports whether the scope grants at least the required permission level.
+// Scopes are hierarchical: admin includes control, and control includes read.
+func (s Scope) Includes(required Scope) bool {
+	rank, ok := scopeRank[s]
+	if !ok {
+		return false
+	}
+	return rank >= scopeRank[required]
+}
+
+// ParseScope validates and returns a scope from its string form.
+func ParseScope(value string) (Scope, error) {
+	scope := Scope(strings.ToLower(strings.TrimSpace(value)))
+	if _, ok := scopeRank[scope]; !ok {
+		return "", fmt.Errorf("invalid scope: %s (must be read, control, or admin)", value)
+	}
+	return scope, nil
+}
+
+// APIKey is a named API key as stored on disk. Only the SHA-256 hash of the key is persisted.
+type APIKey struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Hash      string    `json:"hash"`
+	Scopes    []Scope   `json:"scopes"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// HasScope reports whether any of the key's scopes grants the required permission level.
+func (k *APIKey) HasScope(required Scope) bool {
+	for _, scope := range k.Scopes {
+		if scope.Includes(required) {
+			return true
+		}
+	}
+	return false
+}
+
+// KeyStore holds the configured API keys and persists them to a JSON file.
+// When the store contains no keys, authentication is considered disabled.
+type KeyStore struct {
+	path      string
+	mu        sync.RWMutex
+	keys      []APIKey
+	lastUsed  map[string]time.Time
+	modTime   time.Time
+	lastCheck time.Time
+	missing   bool // the key file was removed after keys were loaded
+}
+
+// NewKeyStore loads the key store from the given path.
+// A missing file yields an empty store. An empty path creates an in-memory store that is never persisted.
+func NewKeyStore(path string) (*KeyStore, error) {
+	ks := &KeyStore{
+		path:     path,
+		lastUsed: make(map[string]time.Time),
+	}
+	if err := ks.load(); err != nil {
+		return nil, err
+	}
+	return ks, nil
+}
+
+func (ks *KeyStore) load() error {
+	if ks.path == "" {
+		return nil
+	}
+
+	info, err := os.Stat(ks.path)
+	if os.IsNotExist(err) {
+		ks.keys = nil
+		ks.modTime = time.Time{}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to stat API key file: %w", err)
+	}
+
+	var keys []APIKey
+	if _, err := lib.ReadJSONFile(ks.path, &keys); err != nil {
+		return fmt.Errorf("failed to load API keys: %w", err)
+	}
+
+	ks.keys = keys
+	ks.modTime = info.ModTime()
+	return nil
+}
+
+func (ks *KeyStore) save() error {
+	if ks.path == "" {
+		return nil
+	}
+	if err := lib.WriteJSONFile(ks.path, ks.keys); err != nil {
+		return fmt.Errorf("failed to save API keys: %w", err)
+	}
+
+	if info, err := os.Stat(ks.path); err == nil {
+		ks.modTime = info.ModTime()
+	}
+	ks.missing = false
+	return nil
+}
+
+// reloadIfChanged re-reads the key file when it was modified outside this process,
+// for example by the "apikey" CLI command while the agent is running.
+func (ks *KeyStore) reloadIfChanged() {
+	if ks.path == "" {
+		return
+	}
+
+	ks.mu.RLock()
+	due := time.Since(ks.lastCheck) >= reloadInterval
+	ks.mu.RUnlock()
+	if !due {
+		return
+	}
+
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	ks.lastCheck = time.Now()
+
+	reloaded, err := ks.refresh()
+	if err != nil {
+		logger.Error("Auth: Failed to reload API keys: %v", err)
+		return
+	}
+	if reloaded {
+		logger.Info("Auth: Reloaded %d API keys", len(ks.keys))
+	}
+}
+
+// refresh loads the key file when its modification time differs from the last load, and reports
+// whether it did. A key file that disappears while keys are loaded does not disable
+// authentication: the last loaded keys stay in effect until the file is back or rewritten.
+// The caller must hold mu.
+func (ks *KeyStore) refresh() (bool, error) {
+	if ks.path == "" {
+		return false, nil
+	}
+
+	info, err := os.Stat(ks.path)
+	switch {
+	case os.IsNotExist(err):
+		if len(ks.keys) > 0 && !ks.missing {
+			logger.Error("Auth: API key file %s removed, keeping the %d loaded keys", ks.path, len(ks.keys))
+		}
+		ks.missing = true
+		return false, nil
+	case err != nil:
+		return false, fmt.Errorf("failed to stat API key file: %w", err)
+	case info.ModTime().Equal(ks.modTime):
+		return false, nil
+	}
+	ks.missing = false
+	if err := ks.load(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Enabled reports whether at least one API key is configured.
+func (ks *KeyStore) Enabled() bool {
+	ks.reloadIfChanged()
+
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	return len(ks.keys) > 0
+}
+
+// Create generates a new API key with the given name and scopes and persists it.
+// The plaintext key is returned exactly once; only its hash is stored.
+func (ks *KeyStore) Create(name string, scopes []Scope) (string, *APIKey, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", nil, fmt.Errorf("API key name cannot be empty")
+	}
+	if len(name) > 64 {
+		return "", nil, fmt.Errorf("API key name too long: maximum 64 characters, got %d", len(name))
+	}
+	if len(scopes) == 0 {
+		return "", nil, fmt.Errorf("at least one scope is required")
+	}
+	for _, scope := range scopes {
+		if _, ok := scopeRank[scope]; !ok {
+			return "", nil, fmt.Errorf("invalid scope: %s", scope)
+		}
+	}
+
+	secret, err := randomHex(24)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to generate API key: %w", err)
+	}
+	id, err := randomHex(4)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to generate API key ID: %w", err)
+	}
+
+	plaintext := keyPrefix + secret
+	key := APIKey{
+		ID:        id,
+		Name:      name,
+		Hash:      hashKey(plaintext),
+		Scopes:    scopes,
+		CreatedAt: time.Now().UTC(),
+	}
+
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	// Pick up keys added with the "apikey" CLI so saving does not overwrite them
+	if _, err := ks.refresh(); err != nil {
+		return "", nil, err
+	}
+
+	for _, existing := range ks.keys {
+		if existing.Name == name {
+			return "", nil, fmt.Errorf("API key with name %q already exists", name)
+		}
+	}
+
+	ks.keys = append(ks.keys, key)
+	if err := ks.save(); err != nil {
+		ks.keys = ks.keys[:len(ks.keys)-1]
+		return "", nil, err
+	}
+
+	logger.Info("Auth: Created API key %s (%s)", key.ID, key.Name)
+	return plaintext, &key, nil
+}
+
+// Revoke deletes the API key with the given ID or name. The last key with the admin scope is
+// only deleted when lastAdmin is set, since without it keys can no longer be managed through the
+// API, and without any keys authentication is off.
+func (ks *KeyStore) Revoke(idOrName string, lastAdmin bool) error {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	if _, err := ks.refresh(); err != nil {
+		return err
+	}
+
+	for i, key := range ks.keys {
+		if key.ID == idOrName || key.Name == idOrName {
+			if !lastAdmin && key.HasScope(ScopeAdmin) && ks.adminKeys() == 1 {
+				return fmt.Errorf("%w: %s", ErrLastAdminKey, key.Name)
+			}
+			previous := ks.keys
+			ks.keys = append(append([]APIKey{}, ks.keys[:i]...), ks.keys[i+1:]...)
+			if err := ks.save(); err != nil {
+				ks.keys = previous
+				return err
+			}
+			delete(ks.lastUsed, key.ID)
+			logger.Info("Auth: Revoked API key %s (%s)", key.ID, key.Name)
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: %s", ErrKeyNotFound, idOrName)
+}
+
+// adminKeys counts the keys with the admin scope. The caller holds mu.
+func (ks *KeyStore) adminKeys() int {
+	n := 0
+	for i := range ks.keys {
+		if ks.keys[i].HasScope(ScopeAdmin) {
+			n++
+		}
+	}
+	return n
+}
+
+// List returns a copy of the configured API keys.
+func (ks *KeyStore) List() []APIKey {
+	ks.reloadIfChanged()
+
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	keys := make([]APIKey, len(ks.keys))
+	copy(keys, ks.keys)
+	return keys
+}
+
+// LastUsed returns when the key with the given ID last authenticated a request in this process.
+func (ks *KeyStore) LastUsed(id string) (time.Time, bool) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	t, ok := ks.lastUsed[id]
+	return t, ok
+}
+
+// Authenticate returns the API key matching the plaintext token, if any.
+func (ks *KeyStore) Authenticate(token string) (*APIKey, bool) {
+	if token == "" {
+		return nil, false
+	}
+	ks.reloadIfChanged()
+
+	hash := []byte(hashKey(token))
+
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	for i := range ks.keys {
+		if subtle.ConstantTimeCompare(hash, []byte(ks.keys[i].Hash)) == 1 {
+			ks.lastUsed[ks.keys[i].ID] = time.Now()
+			key := ks.keys[i]
+			return &key, true
+		}
+	}
+	return nil, false
+}
+
+func hashKey(plaintext string) string {
+	sum := sha256.Sum256([]byte(plaintext))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomHex(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,234 @@
+package auth
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestScopeIncludes(t *testing.T) {
+	tests := []struct {
+		scope    Scope
+		required Scope
+		want     bool
+	}{
+		{ScopeRead, ScopeRead, true},
+		{ScopeRead, ScopeControl, false},
+		{ScopeRead, ScopeAdmin, false},
+		{ScopeControl, ScopeRead, true},
+		{ScopeControl, ScopeControl, true},
+		{ScopeControl, ScopeAdmin, false},
+		{ScopeAdmin, ScopeRead, true},
+		{ScopeAdmin, ScopeAdmin, true},
+		{Scope("bogus"), ScopeRead, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.scope)+"_"+string(tt.required), func(t *testing.T) {
+			if got := tt.scope.Includes(tt.required); got != tt.want {
+				t.Errorf("%s.Includes(%s) = %v, want %v", tt.scope, tt.required, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseScope(t *testing.T) {
+	if scope, err := ParseScope(" Control "); err != nil || scope != ScopeControl {
+		t.Errorf("ParseScope(\" Control \") = %q, %v", scope, err)
+	}
+	if _, err := ParseScope("superuser"); err == nil {
+		t.Error("ParseScope(\"superuser\") expected error")
+	}
+}
+
+func TestKeyStoreLifecycle(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "apikeys.json")
+
+	store, err := NewKeyStore(path)
+	if err != nil {
+		t.Fatalf("NewKeyStore() error = %v", err)
+	}
+	if store.Enabled() {
+		t.Fatal("empty store should not enable authentication")
+	}
+
+	plaintext, key, err := store.Create("home-assistant", []Scope{ScopeRead})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if !strings.HasPrefix(plaintext, keyPrefix) {
+		t.Errorf("plaintext key %q missing prefix %q", plaintext, keyPrefix)
+	}
+	if !store.Enabled() {
+		t.Error("store with a key should enable authentication")
+	}
+
+	t.Run("only the hash is persisted", func(t *testing.T) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("failed to read key file: %v", err)
+		}
+		if strings.Contains(string(data), plaintext) {
+			t.Error("key file contains the plaintext key")
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatalf("failed to stat key file: %v", err)
+		}
+		if perm := info.Mode().Perm(); perm != 0600 {
+			t.Errorf("key file permissions = %o, want 600", perm)
+		}
+	})
+
+	t.Run("authenticates the plaintext key", func(t *testing.T) {
+		got, ok := store.Authenticate(plaintext)
+		if !ok {
+			t.Fatal("Authenticate() rejected a valid key")
+		}
+		if got.ID != key.ID {
+			t.Errorf("Authenticate() returned key %s, want %s", got.ID, key.ID)
+		}
+		if _, ok := store.LastUsed(key.ID); !ok {
+			t.Error("LastUsed() not recorded after authentication")
+		}
+	})
+
+	t.Run("rejects unknown keys", func(t *testing.T) {
+		if _, ok := store.Authenticate(plaintext + "x"); ok {
+			t.Error("Authenticate() accepted a modified key")
+		}
+		if _, ok := store.Authenticate(""); ok {
+			t.Error("Authenticate() accepted an empty key")
+		}
+	})
+
+	t.Run("rejects duplicate names", func(t *testing.T) {
+		if _, _, err := store.Create("home-assistant", []Scope{ScopeAdmin}); err == nil {
+			t.Error("Create() accepted a duplicate name")
+		}
+	})
+
+	t.Run("reloads from disk", func(t *testing.T) {
+		reloaded, err := NewKeyStore(path)
+		if err != nil {
+			t.Fatalf("NewKeyStore() error = %v", err)
+		}
+		if _, ok := reloaded.Authenticate(plaintext); !ok {
+			t.Error("reloaded store rejected a valid key")
+		}
+	})
+
+	t.Run("revokes keys", func(t *testing.T) {
+		if err := store.Revoke(key.Name, false); err != nil {
+			t.Fatalf("Revoke() error = %v", err)
+		}
+		if _, ok := store.Authenticate(plaintext); ok {
+			t.Error("Authenticate() accepted a revoked key")
+		}
+		if err := store.Revoke(key.ID, false); !errors.Is(err, ErrKeyNotFound) {
+			t.Errorf("Revoke() of a missing key error = %v", err)
+		}
+	})
+
+	t.Run("keeps the last admin key", func(t *testing.T) {
+		if _, _, err := store.Create("admin", []Scope{ScopeAdmin}); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.Revoke("admin", false); !errors.Is(err, ErrLastAdminKey) {
+			t.Fatalf("Revoke() of the last admin key error = %v", err)
+		}
+		if err := store.Revoke("admin", true); err != nil || store.Enabled() {
+			t.Errorf("Revoke() with lastAdmin error = %v, enabled = %v", err, store.Enabled())
+		}
+	})
+}
+
+func TestKeyStoreCreateValidation(t *testing.T) {
+	store, err := NewKeyStore("")
+	if err != nil {
+		t.Fatalf("NewKeyStore() error = %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		key    string
+		scopes []Scope
+	}{
+		{"empty name", "  ", []Scope{ScopeRead}},
+		{"long name", strings.Repeat("a", 65), []Scope{ScopeRead}},
+		{"no scopes", "scripts", nil},
+		{"invalid scope", "scripts", []Scope{"root"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := store.Create(tt.key, tt.scopes); err == nil {
+				t.Error("Create() expected error")
+			}
+		})
+	}
+}
+
+func TestKeyStoreInvalidFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "apikeys.json")
+	if err := os.WriteFile(path, []byte("{not json"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewKeyStore(path); err == nil {
+		t.Error("NewKeyStore() expected error for malformed file")
+	}
+}
+
+func TestKeyStoreExternalChanges(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "apikeys.json")
+	store, err := NewKeyStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, _, err := store.Create("home-assistant", []Scope{ScopeRead})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A key added by the "apikey" CLI survives keys created and revoked through the agent
+	cli, err := NewKeyStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	added, _, err := cli.Create("scripts", []Scope{ScopeControl})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := store.Create("grafana", []Scope{ScopeRead}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Revoke("grafana", false); err != nil {
+		t.Fatal(err)
+	}
+	reloaded, err := NewKeyStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := reloaded.Authenticate(added); !ok {
+		t.Error("key added by another process was overwritten")
+	}
+	if len(reloaded.List()) != 2 {
+		t.Errorf("unexpected keys: %+v", reloaded.List())
+	}
+
+	// Removing the key file keeps the loaded keys instead of disabling authentication
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	store.lastCheck = time.Time{}
+	if !store.Enabled() {
+		t.Error("authentication disabled after the key file was removed")
+	}
+	if _, ok := store.Authenticate(first); !ok {
+		t.Error("loaded key rejected after the key file was removed")
+	}
+}
@@ -78,7 +78,7 @@ func (c *ArrayCollector) Collect() {
 
 	logger.Debug("Array: Successfully collected, publishing event")
 	// Publish event
-	c.ctx.Hub.Pub(arrayStatus, "array_status_update")
+	c.ctx.Hub.Pub(arrayStatus, constants.TopicArrayStatusUpdate)
 	logger.Debug("Array: Published array_status_update event - state=%s, disks=%d", arrayStatus.State, arrayStatus.NumDisks)
 }
 
@@ -168,6 +168,15 @@ func (c *ArrayCollector) collectArrayStatus() (*dto.ArrayStatus, error) {
 		status.ParityCheckStatus = strings.Trim(section.Key("sbSyncAction").String(), `"`)
 	}
 
+	// mdResync is the size of the running parity check or sync, 0 when none is running, and
+	// mdResyncPos how far it got
+	resync, _ := strconv.ParseFloat(strings.Trim(section.Key("mdResync").String(), `"`), 64)
+	if resync > 0 {
+		status.ParityCheckRunning = true
+		pos, _ := strconv.ParseFloat(strings.Trim(section.Key("mdResyncPos").String(), `"`), 64)
+		status.ParityCheckProgress = pos / resync * 100
+	}
+
 	// Get array size information from /mnt/user filesystem
 	// /mnt/user is the shfs (Unraid user share filesystem) that represents the entire array
 	c.enrichWithArraySize(status)
@@ -3,6 +3,7 @@ package collectors
 import (
 	"bufio"
 	"context"
+	"errors"
 	"os"
 	"strconv"
 	"strings"
@@ -20,11 +21,15 @@ import (
 // It gathers disk metrics, SMART data, temperature, and usage statistics for array and cache disks.
 type DiskCollector struct {
 	ctx *domain.Context
+
+	// smart keeps the last SMART data read from each device, reported again while the disk is
+	// in standby
+	smart map[string]*dto.DiskSMART
 }
 
 // NewDiskCollector creates a new disk information collector with the given context.
 func NewDiskCollector(ctx *domain.Context) *DiskCollector {
-	return &DiskCollector{ctx: ctx}
+	return &DiskCollector{ctx: ctx, smart: make(map[string]*dto.DiskSMART)}
 }
 
 // Start begins the disk collector's periodic data collection.
@@ -77,8 +82,9 @@ func (c *DiskCollector) Collect() {
 
 	logger.Debug("Disk: Successfully collected %d disks, publishing event", len(disks))
 	// Publish event
-	c.ctx.Hub.Pub(disks, "disk_list_update")
+	c.ctx.Hub.Pub(disks, constants.TopicDiskListUpdate)
 	logger.Debug("Disk: Published disk_list_update event with %d disks", len(disks))
+	c.ctx.Hub.Pub(c.smartList(disks), constants.TopicDiskSMARTUpdate)
 }
 
 func (c *DiskCollector) collectDisks() ([]dto.DiskInfo, error) {
@@ -181,6 +187,10 @@ func (c *DiskCollector) parseDiskKeyValue(disk *dto.DiskInfo, line string) {
 		disk.Device = value
 	case "id":
 		disk.ID = value
+	case "idx":
+		if idx, err := strconv.Atoi(value); err == nil {
+			disk.Index = idx
+		}
 	case "status":
 		disk.Status = value
 	case "size":
@@ -204,6 +214,14 @@ func (c *DiskCollector) parseDiskKeyValue(disk *dto.DiskInfo, line string) {
 		}
 	case "format":
 		disk.FileSystem = value
+	case "spundown":
+		// emhttpd tracks spin state itself and updates it when it spins disks up or down
+		switch value {
+		case "1":
+			disk.SpinState = "standby"
+		case "0":
+			disk.SpinState = "active"
+		}
 	}
 }
 
@@ -348,7 +366,8 @@ func (c *DiskCollector) isNVMeDevice(device string) bool {
 	return isNVMe
 }
 
-// enrichWithSMARTData adds SMART attributes using smartctl
+// enrichWithSMARTData adds the SMART health, attributes and power counters read with
+// smartctl -j -a. Spun down disks are not woken up; they keep the data of their last reading.
 func (c *DiskCollector) enrichWithSMARTData(disk *dto.DiskInfo) {
 	devicePath := "/dev/" + disk.Device
 
@@ -372,69 +391,50 @@ func (c *DiskCollector) enrichWithSMARTData(disk *dto.DiskInfo) {
 		return
 	}
 
-	// Detect device type for optimized SMART collection
-	isNVMe := c.isNVMeDevice(disk.Device)
-
-	var lines []string
-	var err error
-
-	if isNVMe {
-		// NVMe drives don't support standby mode, so we skip the -n standby flag
-		// Use smartctl -H directly for NVMe drives
-		logger.Debug("Disk: Collecting SMART data for NVMe device %s (no standby check)", disk.Device)
-		lines, err = lib.ExecCommand("smartctl", "-H", devicePath)
+	// SATA/SAS drives are read with -n standby so spun down disks stay down; NVMe drives have
+	// no standby mode
+	smart, err := lib.ReadSMART(constants.SmartctlBin, disk.Device)
+	if err != nil {
+		// Only a disk in standby keeps its last reading. One that cannot be opened, for example
+		// because it dropped off the bus, must not go on reporting its old health status.
+		if !errors.Is(err, lib.ErrDiskStandby) && disk.SpinState != "standby" {
+			logger.Debug("Disk: Failed to read SMART data for %s: %v", disk.Device, err)
+			delete(c.smart, disk.Device)
+			return
+		}
+		logger.Debug("Disk: Skipping SMART check for %s (disk is in standby mode): %v", disk.Device, err)
+		smart = c.smart[disk.Device]
+		if smart == nil {
+			return
+		}
+		smart.Standby = true
 	} else {
-		// SATA/SAS drives support standby mode
-		// Run smartctl with -n standby to avoid waking up spun-down disks
-		// The -n standby flag tells smartctl to skip the check if the disk is in standby mode
-		// This preserves Unraid's disk spin-down functionality
-		// Exit codes:
-		//   0 = Success, disk is active, SMART data retrieved
-		//   2 = Disk is in standby/sleep mode, check skipped (disk NOT woken up)
-		//   Other = Error accessing disk
-		logger.Debug("Disk: Collecting SMART data for SATA/SAS device %s (with standby check)", disk.Device)
-		lines, err = lib.ExecCommand("smartctl", "-n", "standby", "-H", devicePath)
+		logger.Debug("Disk: Successfully retrieved SMART data for %s: %s", disk.Device, smart.Status)
+		c.smart[disk.Device] = smart
 	}
+	smart.Name = disk.Name
 
-	if err != nil {
-		// Check if this is a "disk in standby" error (exit code 2)
-		// In this case, we preserve the disk's spun-down state and skip SMART check
-		// The SMART status will remain as the last known value or UNKNOWN
-		logger.Debug("Disk: Skipping SMART check for %s (disk may be in standby mode): %v", disk.Device, err)
-		return
+	disk.SMARTStatus = smart.Status
+	disk.SMARTAttributes = smart.Attributes
+	disk.PowerOnHours = smart.PowerOnHours
+	disk.PowerCycleCount = smart.PowerCycleCount
+	if disk.Model == "" {
+		disk.Model = smart.Model
 	}
+	if disk.SerialNumber == "" {
+		disk.SerialNumber = smart.SerialNumber
+	}
+}
 
-	logger.Debug("Disk: Successfully retrieved SMART health for %s", disk.Device)
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-
-		// Parse SMART health status (SATA/SAS drives)
-		// Example: "SMART overall-health self-assessment test result: PASSED"
-		if strings.Contains(line, "SMART overall-health self-assessment test result:") {
-			parts := strings.SplitN(line, ":", 2)
-			if len(parts) == 2 {
-				status := strings.TrimSpace(parts[1])
-				disk.SMARTStatus = strings.ToUpper(status)
-				logger.Debug("Disk: Parsed SATA/SAS SMART status for %s: %s", disk.Device, disk.SMARTStatus)
-			}
-		}
-
-		// Parse SMART health status (NVMe drives)
-		// Example: "SMART Health Status: OK"
-		if strings.Contains(line, "SMART Health Status:") {
-			parts := strings.SplitN(line, ":", 2)
-			if len(parts) == 2 {
-				status := strings.TrimSpace(parts[1])
-				// Normalize NVMe "OK" to "PASSED" for consistency
-				if strings.ToUpper(status) == "OK" {
-					disk.SMARTStatus = "PASSED"
-				} else {
-					disk.SMARTStatus = strings.ToUpper(status)
-				}
-				logger.Debug("Disk: Parsed NVMe SMART status for %s: %s (original: %s)", disk.Device, disk.SMARTStatus, status)
-			}
+// smartList returns the SMART data of the given disks that have any.
+func (c *DiskCollector) smartList(disks []dto.DiskInfo) []dto.DiskSMART {
+	list := make([]dto.DiskSMART, 0, len(disks))
+	for _, disk := range disks {
+		if smart, ok := c.smart[disk.Device]; ok {
+			list = append(list, *smart)
 		}
 	}
+	return list
 }
 
 // enrichWithMountInfo adds mount point and usage information
@@ -535,6 +535,11 @@ func (c *DiskCollector) enrichWithSpinState(disk *dto.DiskInfo) {
 		return
 	}
 
+	// Keep the state emhttpd reported in disks.ini
+	if disk.SpinState != "" {
+		return
+	}
+
 	// Read spin state from /var/local/emhttp/var.ini or check temperature
 	// If temperature is "*", disk is spun down
 	if disk.Temperature == 0 {
@@ -6,64 +6,138 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/constants"
 	"github.com/ruaan-deysel/unraid-management-agent/daemon/domain"
 	"github.com/ruaan-deysel/unraid-management-agent/daemon/dto"
 	"github.com/ruaan-deysel/unraid-management-agent/daemon/lib"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/lib/dockerapi"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/lib/dockerman"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/lib/registry"
 	"github.com/ruaan-deysel/unraid-management-agent/daemon/logger"
 )
 
+// inspectTTL bounds how long inspect results are reused for a container whose state is unchanged.
+const inspectTTL = 5 * time.Minute
+
 // DockerCollector collects information about Docker containers running on the Unraid system.
 // It gathers container status, resource usage, network information, and configuration details.
+// It talks to the Engine API on the Docker socket and falls back to the docker CLI when the
+// socket is unavailable. Container images are periodically compared with their registry to
+// report available updates. Containers are linked to the dockerMan templates Unraid created
+// them from.
 type DockerCollector struct {
-	ctx *domain.Context
+	ctx       *domain.Context
+	client    *dockerapi.Client
+	registry  *registry.Client
+	templates *dockerman.Store
+	nginxIni  string
+
+	// refresh requests an out-of-cycle collection after a container event
+	refresh chan struct{}
+
+	mu       sync.Mutex
+	runCtx   context.Context
+	inspect  map[string]inspectEntry
+	watchers map[string]*statsWatcher
+	// remote holds registry digests by image reference, images local repo digests by image ID
+	remote map[string]remoteDigest
+	images map[string][]string
+}
+
+// inspectEntry is a cached inspect result for one container.
+type inspectEntry struct {
+	state   string
+	fetched time.Time
+	info    *dockerapi.ContainerJSON
+}
+
+// statsWatcher follows the stats stream of one running container and keeps its latest sample.
+type statsWatcher struct {
+	cancel context.CancelFunc
+	stats  *containerStats
 }
 
 // NewDockerCollector creates a new Docker container collector with the given context.
 func NewDockerCollector(ctx *domain.Context) *DockerCollector {
-	return &DockerCollector{ctx: ctx}
+	return &DockerCollector{
+		ctx:       ctx,
+		client:    dockerapi.NewClient(constants.DockerSocket),
+		registry:  registry.NewClient(nil),
+		templates: dockerman.NewStore(constants.DockerTemplatesDir),
+		nginxIni:  constants.NginxIni,
+		refresh:   make(chan struct{}, 1),
+		inspect:   make(map[string]inspectEntry),
+		watchers:  make(map[string]*statsWatcher),
+		remote:    make(map[string]remoteDigest),
+		images:    make(map[string][]string),
+	}
 }
 
 // Start begins the Docker collector's periodic data collection.
 // It runs in a goroutine and publishes container information updates at the specified interval until the context is cancelled.
+// Container events from the Engine are published as they happen and trigger an immediate collection.
 func (c *DockerCollector) Start(ctx context.Context, interval time.Duration) {
 	logger.Info("Starting docker collector (interval: %v)", interval)
 	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
+	c.mu.Lock()
+	c.runCtx = ctx
+	c.mu.Unlock()
+
+	go c.watchEvents(ctx)
+	go c.watchUpdates(ctx, constants.IntervalDockerUpdates*time.Second)
+
 	for {
 		select {
 		case <-ctx.Done():
+			c.stopWatchers()
 			logger.Info("Docker collector stopping due to context cancellation")
 			return
 		case <-ticker.C:
 			c.Collect()
+		case <-c.refresh:
+			c.Collect()
 		}
 	}
 }
 
 // Collect gathers Docker container information and publishes it to the event bus.
-// It uses the Docker CLI to inspect all containers and extract detailed information.
+// It uses a single Engine API list call with cached inspect results and streamed stats, and
+// falls back to the Docker CLI when the Engine socket cannot be reached.
 func (c *DockerCollector) Collect() {
 
 	logger.Debug("Collecting docker data...")
 
-	// Check if docker is available
-	if !lib.CommandExists("docker") {
-		logger.Warning("Docker command not found, skipping collection")
+	containers, err := c.collectFromAPI()
+	if err != nil && dockerapi.IsAPIError(err) {
+		logger.Error("Failed to collect containers: %v", err)
 		return
 	}
-
-	// Collect container information
-	containers, err := c.collectContainers()
 	if err != nil {
-		logger.Error("Failed to collect containers: %v", err)
-		return
+		logger.Debug("Docker API unavailable, falling back to CLI: %v", err)
+
+		// Check if docker is available
+		if !lib.CommandExists("docker") {
+			logger.Warning("Docker command not found, skipping collection")
+			return
+		}
+
+		// Collect container information
+		containers, err = c.collectContainers()
+		if err != nil {
+			logger.Error("Failed to collect containers: %v", err)
+			return
+		}
 	}
 
+	c.applyTemplates(containers)
+
 	// Publish event
-	c.ctx.Hub.Pub(containers, "container_list_update")
+	c.ctx.Hub.Pub(containers, constants.TopicContainerListUpdate)
 	logger.Debug("Published container_list_update event with %d containers", len(containers))
 }
 
@@ -112,24 +186,13 @@ func (c *DockerCollector) collectContainers() ([]*dto.ContainerInfo, error) {
 
 		// Get enhanced container details using docker inspect
 		if details, err := c.getContainerDetails(container.ID); err == nil {
-			container.Version = details.Version
-			container.NetworkMode = details.NetworkMode
-			container.IPAddress = details.IPAddress
-			container.PortMappings = details.PortMappings
-			container.VolumeMappings = details.VolumeMappings
-			container.RestartPolicy = details.RestartPolicy
-			container.Uptime = details.Uptime
+			c.applyDetails(container, details)
 		}
 
 		// Get container stats if running
 		if container.State == "running" {
 			if stats, err := c.getContainerStats(container.ID); err == nil {
-				container.CPUPercent = stats.CPUPercent
-				container.MemoryUsage = stats.MemoryUsage
-				container.MemoryLimit = stats.MemoryLimit
-				container.NetworkRX = stats.NetworkRX
-				container.NetworkTX = stats.NetworkTX
-				container.MemoryDisplay = c.formatMemoryDisplay(stats.MemoryUsage, stats.MemoryLimit)
+				c.applyStats(container, stats)
 			}
 		}
 
@@ -139,6 +202,223 @@ func (c *DockerCollector) collectContainers() ([]*dto.ContainerInfo, error) {
 	return containers, nil
 }
 
+// collectFromAPI lists containers with one Engine API call. Inspect results are cached until a
+// container changes state, and stats come from per-container streams kept open between runs.
+func (c *DockerCollector) collectFromAPI() ([]*dto.ContainerInfo, error) {
+	ctx := c.runContext()
+	list, err := c.client.ListContainers(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+
+	containers := make([]*dto.ContainerInfo, 0, len(list))
+	seen := make(map[string]bool, len(list))
+	images := make(map[string]bool)
+	running := make(map[string]bool)
+
+	for _, item := range list {
+		seen[item.ID] = true
+		images[item.ImageID] = true
+		container := &dto.ContainerInfo{
+			ID:        shortContainerID(item.ID),
+			Name:      containerName(item.Names),
+			Image:     item.Image,
+			State:     strings.ToLower(item.State),
+			Status:    item.Status,
+			Ports:     apiPorts(item.Ports),
+			Labels:    item.Labels,
+			Timestamp: time.Now(),
+		}
+
+		if info, err := c.inspectCached(ctx, item); err == nil {
+			c.applyDetails(container, c.detailsFromInspect(info))
+		} else {
+			logger.Debug("Failed to inspect container %s: %v", container.Name, err)
+		}
+		c.applyUpdate(ctx, container, item)
+
+		if container.State == "running" {
+			running[item.ID] = true
+			if stats := c.latestStats(item.ID); stats != nil {
+				c.applyStats(container, stats)
+			}
+		}
+
+		containers = append(containers, container)
+	}
+
+	c.pruneInspect(seen)
+	c.pruneImages(images)
+	c.syncWatchers(ctx, running)
+	return containers, nil
+}
+
+// runContext returns the collector's run context, or a background context before Start.
+func (c *DockerCollector) runContext() context.Context {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.runCtx != nil {
+		return c.runCtx
+	}
+	return context.Background()
+}
+
+// inspectCached returns the inspect result for a container, reusing the cached one while the
+// container keeps the same state and the entry is younger than inspectTTL.
+func (c *DockerCollector) inspectCached(ctx context.Context, item dockerapi.Container) (*dockerapi.ContainerJSON, error) {
+	c.mu.Lock()
+	entry, ok := c.inspect[item.ID]
+	c.mu.Unlock()
+	if ok && entry.state == item.State && time.Since(entry.fetched) < inspectTTL {
+		return entry.info, nil
+	}
+
+	info, err := c.client.InspectContainer(ctx, item.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.inspect[item.ID] = inspectEntry{state: item.State, fetched: time.Now(), info: info}
+	c.mu.Unlock()
+	return info, nil
+}
+
+// pruneInspect drops cached inspect results of containers that no longer exist.
+func (c *DockerCollector) pruneInspect(seen map[string]bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for id := range c.inspect {
+		if !seen[id] {
+			delete(c.inspect, id)
+		}
+	}
+}
+
+// latestStats returns the most recent streamed stats sample of a container, if any.
+func (c *DockerCollector) latestStats(id string) *containerStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if w, ok := c.watchers[id]; ok {
+		return w.stats
+	}
+	return nil
+}
+
+// syncWatchers starts a stats stream for every running container and stops the streams of
+// containers that are no longer running.
+func (c *DockerCollector) syncWatchers(ctx context.Context, running map[string]bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for id, w := range c.watchers {
+		if !running[id] {
+			w.cancel()
+			delete(c.watchers, id)
+		}
+	}
+	for id := range running {
+		if _, ok := c.watchers[id]; ok {
+			continue
+		}
+		watchCtx, cancel := context.WithCancel(ctx)
+		w := &statsWatcher{cancel: cancel}
+		c.watchers[id] = w
+		go c.watchStats(watchCtx, id, w)
+	}
+}
+
+func (c *DockerCollector) watchStats(ctx context.Context, id string, w *statsWatcher) {
+	defer w.cancel()
+
+	err := c.client.StreamStats(ctx, id, func(s *dockerapi.Stats) {
+		rx, tx := s.NetworkIO()
+		stats := &containerStats{
+			CPUPercent:  s.CPUPercent(),
+			MemoryUsage: s.MemoryUsage(),
+			MemoryLimit: s.MemoryStats.Limit,
+			NetworkRX:   rx,
+			NetworkTX:   tx,
+		}
+		c.mu.Lock()
+		w.stats = stats
+		c.mu.Unlock()
+	})
+	if err != nil && ctx.Err() == nil {
+		logger.Debug("Stats stream for container %s ended: %v", shortContainerID(id), err)
+	}
+
+	// Forget the watcher so the next collection restarts it if the container is still running
+	c.mu.Lock()
+	if c.watchers[id] == w {
+		delete(c.watchers, id)
+	}
+	c.mu.Unlock()
+}
+
+// stopWatchers closes every open stats stream.
+func (c *DockerCollector) stopWatchers() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for id, w := range c.watchers {
+		w.cancel()
+		delete(c.watchers, id)
+	}
+}
+
+// shortContainerID truncates a full container ID to the 12 characters shown by docker ps.
+func shortContainerID(id string) string {
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
+
+// containerName returns the primary name of a container without the leading slash.
+func containerName(names []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	return strings.TrimPrefix(names[0], "/")
+}
+
+// apiPorts converts the ports of an Engine API container list entry.
+func apiPorts(ports []dockerapi.Port) []dto.PortMapping {
+	mappings := make([]dto.PortMapping, 0, len(ports))
+	for _, p := range ports {
+		mappings = append(mappings, dto.PortMapping{
+			PrivatePort: p.PrivatePort,
+			PublicPort:  p.PublicPort,
+			Type:        p.Type,
+		})
+	}
+	return mappings
+}
+
+// applyDetails copies inspect-derived details onto a container.
+func (c *DockerCollector) applyDetails(container *dto.ContainerInfo, details *containerDetails) {
+	container.Version = details.Version
+	container.NetworkMode = details.NetworkMode
+	container.IPAddress = details.IPAddress
+	container.PortMappings = details.PortMappings
+	container.VolumeMappings = details.VolumeMappings
+	container.RestartPolicy = details.RestartPolicy
+	container.Uptime = details.Uptime
+	container.WebUI = details.WebUI
+	container.Icon = details.Icon
+	container.Labels = details.Labels
+}
+
+// applyStats copies resource usage onto a container.
+func (c *DockerCollector) applyStats(container *dto.ContainerInfo, stats *containerStats) {
+	container.CPUPercent = stats.CPUPercent
+	container.MemoryUsage = stats.MemoryUsage
+	container.MemoryLimit = stats.MemoryLimit
+	container.NetworkRX = stats.NetworkRX
+	container.NetworkTX = stats.NetworkTX
+	container.MemoryDisplay = c.formatMemoryDisplay(stats.MemoryUsage, stats.MemoryLimit)
+}
+
 type containerStats struct {
 	CPUPercent  float64
 	MemoryUsage uint64
@@ -282,6 +562,9 @@ type containerDetails struct {
 	VolumeMappings []dto.VolumeMapping
 	RestartPolicy  string
 	Uptime         string
+	WebUI          string
+	Icon           string
+	Labels         map[string]string
 }
 
 // getContainerDetails retrieves detailed container information using docker inspect
@@ -291,31 +574,7 @@ func (c *DockerCollector) getContainerDetails(containerID string) (*containerDet
 		return nil, err
 	}
 
-	var inspectOutput []struct {
-		Config struct {
-			Image string `json:"Image"`
-		} `json:"Config"`
-		NetworkSettings struct {
-			Networks map[string]struct {
-				IPAddress string `json:"IPAddress"`
-			} `json:"Networks"`
-		} `json:"NetworkSettings"`
-		HostConfig struct {
-			NetworkMode   string `json:"NetworkMode"`
-			RestartPolicy struct {
-				Name string `json:"Name"`
-			} `json:"RestartPolicy"`
-			PortBindings map[string][]struct {
-				HostIP   string `json:"HostIp"`
-				HostPort string `json:"HostPort"`
-			} `json:"PortBindings"`
-			Binds []string `json:"Binds"`
-		} `json:"HostConfig"`
-		State struct {
-			StartedAt string `json:"StartedAt"`
-		} `json:"State"`
-	}
-
+	var inspectOutput []dockerapi.ContainerJSON
 	if err := json.Unmarshal([]byte(output), &inspectOutput); err != nil {
 		return nil, err
 	}
@@ -324,7 +583,12 @@ func (c *DockerCollector) getContainerDetails(containerID string) (*containerDet
 		return nil, fmt.Errorf("no inspect data returned")
 	}
 
-	inspect := inspectOutput[0]
+	return c.detailsFromInspect(&inspectOutput[0]), nil
+}
+
+// detailsFromInspect extracts container details from an inspect result, which has the same
+// shape whether it came from the Engine API or from docker inspect.
+func (c *DockerCollector) detailsFromInspect(inspect *dockerapi.ContainerJSON) *containerDetails {
 	details := &containerDetails{}
 
 	// Extract version from image tag
@@ -381,6 +645,11 @@ func (c *DockerCollector) getContainerDetails(containerID string) (*containerDet
 		details.RestartPolicy = "no"
 	}
 
+	// WebUI and icon labels set by Unraid when the container was created from a template
+	details.Labels = inspect.Config.Labels
+	details.WebUI = inspect.Config.Labels[dockerman.LabelWebUI]
+	details.Icon = inspect.Config.Labels[dockerman.LabelIcon]
+
 	// Calculate uptime
 	if inspect.State.StartedAt != "" {
 		startTime, err := time.Parse(time.RFC3339Nano, inspect.State.StartedAt)
@@ -390,7 +659,7 @@ func (c *DockerCollector) getContainerDetails(containerID string) (*containerDet
 		}
 	}
 
-	return details, nil
+	return details
 }
 
 // formatUptime formats a duration into a human-readable uptime string
@@ -0,0 +1,100 @@
+package collectors
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/constants"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/dto"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/lib/dockerapi"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/logger"
+)
+
+// Reconnect delays for the Docker event stream.
+const (
+	eventsRetryInitial = 2 * time.Second
+	eventsRetryMax     = time.Minute
+)
+
+// containerEventFilters selects the container events that are published and trigger an
+// out-of-cycle refresh. "health_status" matches every health status change.
+var containerEventFilters = map[string][]string{
+	"type":  {"container"},
+	"event": {"start", "die", "oom", "health_status", "restart", "pause", "unpause", "destroy"},
+}
+
+// watchEvents follows the Engine event stream until ctx is cancelled, reconnecting with backoff
+// when the stream ends or the socket is unavailable.
+func (c *DockerCollector) watchEvents(ctx context.Context) {
+	wait := eventsRetryInitial
+	for {
+		received := false
+		err := c.client.StreamEvents(ctx, containerEventFilters, func(e dockerapi.Event) {
+			received = true
+			c.handleEvent(e)
+		})
+		if ctx.Err() != nil {
+			return
+		}
+		if received {
+			wait = eventsRetryInitial
+		}
+		if err != nil {
+			logger.Debug("Docker event stream unavailable, retrying in %s: %v", wait, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+		if wait *= 2; wait > eventsRetryMax {
+			wait = eventsRetryMax
+		}
+	}
+}
+
+// handleEvent publishes a container event, drops the cached inspect result of the container
+// and requests an immediate refresh of the container list.
+func (c *DockerCollector) handleEvent(e dockerapi.Event) {
+	event := containerEventFromAPI(e)
+	if event.Action == "oom" {
+		logger.Warning("Docker container %s was killed by the OOM killer", event.Name)
+	}
+
+	c.ctx.Hub.Pub(event, constants.TopicContainerEvent)
+	logger.Debug("Published container_event %s for %s", event.Action, event.Name)
+
+	c.mu.Lock()
+	delete(c.inspect, e.Actor.ID)
+	c.mu.Unlock()
+
+	// A pending refresh already covers this event
+	c.requestRefresh()
+}
+
+// containerEventFromAPI converts an Engine event. Health events carry their status in the
+// action ("health_status: unhealthy") and die events carry the exit code as an attribute.
+func containerEventFromAPI(e dockerapi.Event) *dto.ContainerEvent {
+	event := &dto.ContainerEvent{
+		ID:        shortContainerID(e.Actor.ID),
+		Name:      e.Actor.Attributes["name"],
+		Image:     e.Actor.Attributes["image"],
+		Action:    e.Action,
+		Timestamp: time.Unix(0, e.TimeNano),
+	}
+	if e.TimeNano == 0 {
+		event.Timestamp = time.Unix(e.Time, 0)
+	}
+
+	if action, status, ok := strings.Cut(e.Action, ":"); ok {
+		event.Action = action
+		event.HealthStatus = strings.TrimSpace(status)
+	}
+	if code, err := strconv.Atoi(e.Actor.Attributes["exitCode"]); err == nil {
+		event.ExitCode = &code
+	}
+	return event
+}
@@ -0,0 +1,108 @@
+package collectors
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/cskr/pubsub"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/constants"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/domain"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/dto"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/lib/dockerapi"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/lib/dockerapi/dockertest"
+)
+
+func TestContainerEventFromAPI(t *testing.T) {
+	recorded := dockertest.RecordedEvents()
+
+	tests := []struct {
+		name       string
+		raw        string
+		wantAction string
+		wantHealth string
+		wantExit   *int
+	}{
+		{name: "start", raw: recorded[0], wantAction: "start"},
+		{name: "health status", raw: recorded[1], wantAction: "health_status", wantHealth: "unhealthy"},
+		{name: "oom", raw: recorded[2], wantAction: "oom"},
+		{name: "die with exit code", raw: recorded[3], wantAction: "die", wantExit: intPtr(137)},
+		{name: "restart", raw: recorded[4], wantAction: "restart"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var e dockerapi.Event
+			if err := json.Unmarshal([]byte(tt.raw), &e); err != nil {
+				t.Fatalf("failed to parse recorded event: %v", err)
+			}
+			got := containerEventFromAPI(e)
+
+			if got.ID != dockertest.PlexID[:12] || got.Name != "plex" || got.Image != "plexinc/pms-docker:1.40.2" {
+				t.Errorf("unexpected actor fields: %+v", got)
+			}
+			if got.Action != tt.wantAction || got.HealthStatus != tt.wantHealth {
+				t.Errorf("action = %q, health = %q, want %q, %q", got.Action, got.HealthStatus, tt.wantAction, tt.wantHealth)
+			}
+			if (got.ExitCode == nil) != (tt.wantExit == nil) || (got.ExitCode != nil && *got.ExitCode != *tt.wantExit) {
+				t.Errorf("exit code = %v, want %v", got.ExitCode, tt.wantExit)
+			}
+			if got.Timestamp.UnixNano() != e.TimeNano {
+				t.Errorf("timestamp = %v, want %d", got.Timestamp, e.TimeNano)
+			}
+		})
+	}
+}
+
+func intPtr(v int) *int {
+	return &v
+}
+
+func TestDockerCollectorPublishesContainerEvents(t *testing.T) {
+	server := dockertest.NewServer(t)
+	hub := pubsub.New(10)
+	collector := NewDockerCollector(&domain.Context{Hub: hub})
+	collector.client = dockerapi.NewClient(server.Socket())
+
+	events := hub.Sub(constants.TopicContainerEvent)
+	lists := hub.Sub(constants.TopicContainerListUpdate)
+	defer hub.Unsub(events)
+	defer hub.Unsub(lists)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		// The ticker never fires during the test; collections come from events only
+		collector.Start(ctx, time.Hour)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	// Create is not subscribed to and must not be published
+	recorded := dockertest.RecordedEvents()
+	server.Emit(recorded[5])
+	server.Emit(recorded[2])
+
+	select {
+	case msg := <-events:
+		event, ok := msg.(*dto.ContainerEvent)
+		if !ok || event.Action != "oom" || event.Name != "plex" {
+			t.Errorf("unexpected container_event: %#v", msg)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no container_event published")
+	}
+
+	select {
+	case msg := <-lists:
+		if containers, ok := msg.([]*dto.ContainerInfo); !ok || len(containers) != 2 {
+			t.Errorf("unexpected container_list_update payload: %#v", msg)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("container event did not trigger a container list refresh")
+	}
+}
@@ -0,0 +1,60 @@
+package collectors
+
+import (
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/dto"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/lib"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/lib/dockerman"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/logger"
+)
+
+// applyTemplates links containers to the dockerMan template of the same name and resolves their
+// WebUI address. Template values take precedence over the net.unraid.docker labels, which are
+// all that is known about containers whose template was deleted.
+func (c *DockerCollector) applyTemplates(containers []*dto.ContainerInfo) {
+	templates, errs := c.templates.Templates()
+	for _, err := range errs {
+		logger.Debug("Skipping Docker template: %v", err)
+	}
+	byName := make(map[string]*dockerman.Template, len(templates))
+	for _, t := range templates {
+		byName[t.Name] = t
+	}
+	hostIP := c.hostIP()
+
+	for _, container := range containers {
+		webUI := container.WebUI
+		if t, ok := byName[container.Name]; ok {
+			info := t.Info()
+			container.Template = &info
+			if info.WebUI != "" {
+				webUI = info.WebUI
+			}
+			if info.Icon != "" {
+				container.Icon = info.Icon
+			}
+		}
+
+		network := container.NetworkMode
+		if network == "" || network == "default" {
+			network = "bridge"
+		}
+		published := make(map[int]int, len(container.Ports))
+		for _, p := range container.Ports {
+			if p.PublicPort > 0 {
+				published[p.PrivatePort] = p.PublicPort
+			}
+		}
+		container.WebUI = dockerman.ResolveWebUI(webUI, network, hostIP, container.IPAddress, published)
+	}
+}
+
+// hostIP returns the server's LAN address as configured for the Unraid web UI, which is where
+// containers on the host and bridge networks are reached.
+func (c *DockerCollector) hostIP() string {
+	cfg, err := lib.ParseINIFile(c.nginxIni)
+	if err != nil {
+		logger.Debug("Failed to read server address: %v", err)
+		return ""
+	}
+	return cfg["NGINX_LANIP"]
+}
@@ -0,0 +1,74 @@
+package collectors
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cskr/pubsub"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/domain"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/lib/dockerapi"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/lib/dockerapi/dockertest"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/lib/dockerman"
+)
+
+const plexTemplate = `<?xml version="1.0"?>
+<Container version="2">
+  <Name>plex</Name>
+  <Repository>plexinc/pms-docker:1.40.2</Repository>
+  <Network>bridge</Network>
+  <Support>https://forums.plex.tv</Support>
+  <Project>https://www.plex.tv/</Project>
+  <Category>MediaServer:Video Status:Stable</Category>
+  <WebUI>http://[IP]:[PORT:32400]/web</WebUI>
+  <Icon>https://raw.githubusercontent.com/plexinc/pms-docker/master/img/plex-server.png</Icon>
+  <Config Name="Web UI" Target="32400" Default="32400" Mode="tcp" Type="Port" Display="always" Required="true" Mask="false">32400</Config>
+  <Config Name="Claim Token" Target="PLEX_CLAIM" Default="" Mode="" Type="Variable" Display="always" Required="false" Mask="true">claim-s3cr3t</Config>
+</Container>
+`
+
+func TestDockerCollectorLinksTemplates(t *testing.T) {
+	server := dockertest.NewServer(t)
+	collector := NewDockerCollector(&domain.Context{Hub: pubsub.New(10)})
+	collector.client = dockerapi.NewClient(server.Socket())
+	t.Cleanup(collector.stopWatchers)
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "my-plex.xml"), []byte(plexTemplate), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	nginxIni := filepath.Join(dir, "nginx.ini")
+	if err := os.WriteFile(nginxIni, []byte("NGINX_LANIP=\"192.168.1.10\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	collector.templates = dockerman.NewStore(dir)
+	collector.nginxIni = nginxIni
+
+	containers, err := collector.collectFromAPI()
+	if err != nil {
+		t.Fatalf("collectFromAPI() error = %v", err)
+	}
+	collector.applyTemplates(containers)
+	plex, sonarr := containers[0], containers[1]
+
+	if plex.Template == nil || plex.Template.Support != "https://forums.plex.tv" || len(plex.Template.Categories) != 2 {
+		t.Fatalf("plex not linked to its template: %+v", plex.Template)
+	}
+	if plex.WebUI != "http://192.168.1.10:32400/web" {
+		t.Errorf("plex WebUI = %q", plex.WebUI)
+	}
+	if plex.Icon != plex.Template.Icon {
+		t.Errorf("plex Icon = %q", plex.Icon)
+	}
+	if claim := plex.Template.Variables[1]; !claim.Masked || claim.Value != "" {
+		t.Errorf("masked variable exposed: %+v", claim)
+	}
+
+	// sonarr has no template, so its labels are used
+	if sonarr.Template != nil {
+		t.Errorf("sonarr linked to template %+v", sonarr.Template)
+	}
+	if sonarr.WebUI != "http://192.168.1.10:8989/" || sonarr.Icon == "" {
+		t.Errorf("sonarr WebUI = %q, Icon = %q", sonarr.WebUI, sonarr.Icon)
+	}
+}
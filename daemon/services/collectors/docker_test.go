@@ -3,9 +3,14 @@ package collectors
 import (
 	"encoding/json"
 	"testing"
+	"time"
 
 	"github.com/cskr/pubsub"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/constants"
 	"github.com/ruaan-deysel/unraid-management-agent/daemon/domain"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/dto"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/lib/dockerapi"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/lib/dockerapi/dockertest"
 )
 
 func TestNewDockerCollector(t *testing.T) {
@@ -99,3 +104,99 @@ func TestDockerStateMapping(t *testing.T) {
 		})
 	}
 }
+
+func TestDockerCollectorUsesEngineAPI(t *testing.T) {
+	server := dockertest.NewServer(t)
+	hub := pubsub.New(10)
+	collector := NewDockerCollector(&domain.Context{Hub: hub})
+	collector.client = dockerapi.NewClient(server.Socket())
+	t.Cleanup(collector.stopWatchers)
+
+	first, err := collector.collectFromAPI()
+	if err != nil {
+		t.Fatalf("collectFromAPI() error = %v", err)
+	}
+	if len(first) != 2 {
+		t.Fatalf("collectFromAPI() returned %d containers, want 2", len(first))
+	}
+
+	plex, sonarr := first[0], first[1]
+	if plex.ID != dockertest.PlexID[:12] || plex.Name != "plex" || plex.State != "running" {
+		t.Errorf("unexpected plex container: %+v", plex)
+	}
+	if plex.Version != "1.40.2" || plex.IPAddress != "172.17.0.2" || plex.RestartPolicy != "unless-stopped" {
+		t.Errorf("inspect details not applied: %+v", plex)
+	}
+	if len(plex.VolumeMappings) != 2 || plex.VolumeMappings[1].Mode != "ro" {
+		t.Errorf("unexpected volume mappings: %+v", plex.VolumeMappings)
+	}
+	if len(plex.Ports) != 2 || plex.Ports[0].PublicPort != 32400 {
+		t.Errorf("unexpected ports: %+v", plex.Ports)
+	}
+	if sonarr.State != "exited" || sonarr.RestartPolicy != "no" || sonarr.NetworkMode != "host" {
+		t.Errorf("unexpected sonarr container: %+v", sonarr)
+	}
+	if sonarr.Labels["net.unraid.docker.webui"] != "http://[IP]:[PORT:8989]/" {
+		t.Errorf("labels not surfaced: %v", sonarr.Labels)
+	}
+
+	// Stats arrive asynchronously on the stream opened for the running container
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if stats := collector.latestStats(dockertest.PlexID); stats != nil && stats.CPUPercent > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for streamed stats")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	second, err := collector.collectFromAPI()
+	if err != nil {
+		t.Fatalf("collectFromAPI() error = %v", err)
+	}
+	plex = second[0]
+	if plex.CPUPercent != 20 || plex.MemoryUsage != 384*1024*1024 || plex.MemoryLimit != 8*1024*1024*1024 {
+		t.Errorf("streamed stats not applied: cpu=%v mem=%d limit=%d", plex.CPUPercent, plex.MemoryUsage, plex.MemoryLimit)
+	}
+	if plex.MemoryDisplay != "0.38 GB / 8.00 GB" {
+		t.Errorf("MemoryDisplay = %q", plex.MemoryDisplay)
+	}
+
+	// Inspect results are reused while the state is unchanged, and only one stream is opened
+	for _, id := range []string{dockertest.PlexID, dockertest.SonarrID} {
+		if n := server.Count("GET /containers/" + id + "/json"); n != 1 {
+			t.Errorf("container %s inspected %d times, want 1", id[:12], n)
+		}
+	}
+	if n := server.Count("GET /containers/" + dockertest.PlexID + "/stats"); n != 1 {
+		t.Errorf("stats stream opened %d times, want 1", n)
+	}
+	if n := server.Count("GET /containers/" + dockertest.SonarrID + "/stats"); n != 0 {
+		t.Errorf("stats requested for a stopped container %d times", n)
+	}
+}
+
+func TestDockerCollectorPublishesFromEngineAPI(t *testing.T) {
+	server := dockertest.NewServer(t)
+	hub := pubsub.New(10)
+	collector := NewDockerCollector(&domain.Context{Hub: hub})
+	collector.client = dockerapi.NewClient(server.Socket())
+	t.Cleanup(collector.stopWatchers)
+
+	ch := hub.Sub(constants.TopicContainerListUpdate)
+	defer hub.Unsub(ch)
+
+	collector.Collect()
+
+	select {
+	case msg := <-ch:
+		containers, ok := msg.([]*dto.ContainerInfo)
+		if !ok || len(containers) != 2 {
+			t.Errorf("unexpected container_list_update payload: %#v", msg)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no container_list_update published")
+	}
+}
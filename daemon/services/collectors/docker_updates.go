@@ -0,0 +1,173 @@
+package collectors
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/dto"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/lib/dockerapi"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/lib/registry"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/logger"
+)
+
+// registryTimeout bounds the digest lookup of a single image.
+const registryTimeout = 30 * time.Second
+
+// remoteDigest is the result of the last registry lookup for an image reference.
+type remoteDigest struct {
+	digest  string
+	checked time.Time
+	err     string
+}
+
+// watchUpdates checks the images of all containers against their registries once shortly after
+// start and then every interval until ctx is cancelled.
+func (c *DockerCollector) watchUpdates(ctx context.Context, interval time.Duration) {
+	timer := time.NewTimer(time.Minute)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+			c.CheckUpdates(ctx)
+			timer.Reset(interval)
+		}
+	}
+}
+
+// CheckUpdates looks up the current registry digest of every image used by a container and
+// triggers a collection so update_available reflects the result. Images referenced by ID or
+// pinned to a digest are skipped since their tag cannot move.
+func (c *DockerCollector) CheckUpdates(ctx context.Context) {
+	list, err := c.client.ListContainers(ctx, true)
+	if err != nil {
+		logger.Debug("Skipping image update check, Docker API unavailable: %v", err)
+		return
+	}
+
+	checked := make(map[string]bool)
+	for _, item := range list {
+		if checked[item.Image] || strings.HasPrefix(item.Image, "sha256:") {
+			continue
+		}
+		checked[item.Image] = true
+
+		ref, err := registry.ParseReference(item.Image)
+		if err != nil || ref.Tag == "" || ref.Digest != "" {
+			continue
+		}
+
+		lookupCtx, cancel := context.WithTimeout(ctx, registryTimeout)
+		digest, err := c.registry.Digest(lookupCtx, ref)
+		cancel()
+		if ctx.Err() != nil {
+			return
+		}
+
+		result := remoteDigest{digest: digest, checked: time.Now()}
+		if err != nil {
+			logger.Debug("Failed to check %s for updates: %v", item.Image, err)
+			result.err = err.Error()
+		}
+		c.mu.Lock()
+		c.remote[item.Image] = result
+		c.mu.Unlock()
+	}
+
+	// Forget images no container uses anymore
+	c.mu.Lock()
+	for image := range c.remote {
+		if !checked[image] {
+			delete(c.remote, image)
+		}
+	}
+	c.mu.Unlock()
+
+	logger.Debug("Checked %d container images for updates", len(checked))
+	c.requestRefresh()
+}
+
+// requestRefresh asks the collection loop for an out-of-cycle run without blocking.
+func (c *DockerCollector) requestRefresh() {
+	select {
+	case c.refresh <- struct{}{}:
+	default:
+	}
+}
+
+// applyUpdate sets the image update status of a container from the last registry lookup. An
+// update is available when the registry serves a digest that none of the local image's repo
+// digests match. Images built locally have no repo digests and never report updates.
+func (c *DockerCollector) applyUpdate(ctx context.Context, container *dto.ContainerInfo, item dockerapi.Container) {
+	c.mu.Lock()
+	remote, ok := c.remote[item.Image]
+	c.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	status := &dto.ImageUpdateStatus{
+		RemoteDigest: remote.digest,
+		CheckedAt:    remote.checked,
+		Error:        remote.err,
+	}
+	container.ImageUpdate = status
+
+	digests, err := c.localDigests(ctx, item.ImageID)
+	if err != nil {
+		logger.Debug("Failed to inspect image of container %s: %v", container.Name, err)
+		return
+	}
+	if len(digests) == 0 {
+		return
+	}
+	status.LocalDigest = digests[0]
+	for _, digest := range digests {
+		if digest == remote.digest {
+			status.LocalDigest = digest
+			return
+		}
+	}
+	container.UpdateAvailable = remote.digest != ""
+}
+
+// localDigests returns the registry digests recorded for a local image. Image IDs are content
+// addressed, so results are cached until the image is no longer used.
+func (c *DockerCollector) localDigests(ctx context.Context, imageID string) ([]string, error) {
+	c.mu.Lock()
+	digests, ok := c.images[imageID]
+	c.mu.Unlock()
+	if ok {
+		return digests, nil
+	}
+
+	image, err := c.client.InspectImage(ctx, imageID)
+	if err != nil {
+		return nil, err
+	}
+	digests = make([]string, 0, len(image.RepoDigests))
+	for _, repoDigest := range image.RepoDigests {
+		if _, digest, found := strings.Cut(repoDigest, "@"); found {
+			digests = append(digests, digest)
+		}
+	}
+
+	c.mu.Lock()
+	c.images[imageID] = digests
+	c.mu.Unlock()
+	return digests, nil
+}
+
+// pruneImages drops cached repo digests of images no container uses anymore.
+func (c *DockerCollector) pruneImages(used map[string]bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for id := range c.images {
+		if !used[id] {
+			delete(c.images, id)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package collectors
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cskr/pubsub"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/domain"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/lib/dockerapi"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/lib/dockerapi/dockertest"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/lib/registry"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/lib/registry/registrytest"
+)
+
+func TestDockerCollectorDetectsImageUpdates(t *testing.T) {
+	engine := dockertest.NewServer(t)
+	fake := registrytest.NewServer(t)
+	// plex has a newer image on the registry, sonarr is current
+	fake.SetDigest("plexinc/pms-docker", "1.40.2", "sha256:feed")
+	fake.SetDigest("linuxserver/sonarr", "latest", dockertest.SonarrImageDigest)
+
+	collector := NewDockerCollector(&domain.Context{Hub: pubsub.New(10)})
+	collector.client = dockerapi.NewClient(engine.Socket())
+	collector.registry = registry.NewClient(fake.Client())
+	t.Cleanup(collector.stopWatchers)
+
+	// Nothing is reported before the first check
+	containers, err := collector.collectFromAPI()
+	if err != nil {
+		t.Fatalf("collectFromAPI() error = %v", err)
+	}
+	if containers[0].UpdateAvailable || containers[0].ImageUpdate != nil {
+		t.Errorf("update status reported before checking: %+v", containers[0].ImageUpdate)
+	}
+
+	collector.CheckUpdates(context.Background())
+	select {
+	case <-collector.refresh:
+	default:
+		t.Error("CheckUpdates() did not request a refresh")
+	}
+
+	containers, err = collector.collectFromAPI()
+	if err != nil {
+		t.Fatalf("collectFromAPI() error = %v", err)
+	}
+	plex, sonarr := containers[0], containers[1]
+	if !plex.UpdateAvailable || plex.ImageUpdate == nil {
+		t.Fatalf("plex update not detected: %+v", plex.ImageUpdate)
+	}
+	if plex.ImageUpdate.LocalDigest != dockertest.PlexImageDigest || plex.ImageUpdate.RemoteDigest != "sha256:feed" {
+		t.Errorf("unexpected plex digests: %+v", plex.ImageUpdate)
+	}
+	if sonarr.UpdateAvailable || sonarr.ImageUpdate == nil || sonarr.ImageUpdate.LocalDigest != dockertest.SonarrImageDigest {
+		t.Errorf("unexpected sonarr update status: %v %+v", sonarr.UpdateAvailable, sonarr.ImageUpdate)
+	}
+
+	// Local image digests are cached by image ID
+	if n := engine.Count("GET /images/sha256:5b1e7f0c2a4d6e8f0a1b3c5d7e9f1a3b5c7d9e1f3a5b7c9d1e3f5a7b9c1d3e5f/json"); n != 1 {
+		t.Errorf("plex image inspected %d times, want 1", n)
+	}
+}
+
+func TestDockerCollectorReportsRegistryErrors(t *testing.T) {
+	engine := dockertest.NewServer(t)
+	fake := registrytest.NewServer(t)
+	fake.SetDigest("linuxserver/sonarr", "latest", dockertest.SonarrImageDigest)
+
+	collector := NewDockerCollector(&domain.Context{Hub: pubsub.New(10)})
+	collector.client = dockerapi.NewClient(engine.Socket())
+	collector.registry = registry.NewClient(fake.Client())
+	t.Cleanup(collector.stopWatchers)
+
+	collector.CheckUpdates(context.Background())
+	containers, err := collector.collectFromAPI()
+	if err != nil {
+		t.Fatalf("collectFromAPI() error = %v", err)
+	}
+	plex := containers[0]
+	if plex.UpdateAvailable || plex.ImageUpdate == nil || plex.ImageUpdate.Error == "" {
+		t.Errorf("expected a lookup error for plex, got %v %+v", plex.UpdateAvailable, plex.ImageUpdate)
+	}
+}
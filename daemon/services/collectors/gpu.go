@@ -10,6 +10,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/constants"
 	"github.com/ruaan-deysel/unraid-management-agent/daemon/domain"
 	"github.com/ruaan-deysel/unraid-management-agent/daemon/dto"
 	"github.com/ruaan-deysel/unraid-management-agent/daemon/lib"
@@ -86,7 +87,7 @@ func (c *GPUCollector) Collect() {
 	}
 
 	// Publish event
-	c.ctx.Hub.Pub(gpuMetrics, "gpu_metrics_update")
+	c.ctx.Hub.Pub(gpuMetrics, constants.TopicGPUMetricsUpdate)
 	logger.Debug("Published gpu_metrics_update event for %d total GPU(s)", len(gpuMetrics))
 }
 
@@ -9,6 +9,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/constants"
 	"github.com/ruaan-deysel/unraid-management-agent/daemon/domain"
 	"github.com/ruaan-deysel/unraid-management-agent/daemon/dto"
 	"github.com/ruaan-deysel/unraid-management-agent/daemon/lib"
@@ -76,7 +77,7 @@ func (c *NetworkCollector) Collect() {
 
 	logger.Debug("Network: Successfully collected %d interfaces, publishing event", len(interfaces))
 	// Publish event
-	c.ctx.Hub.Pub(interfaces, "network_list_update")
+	c.ctx.Hub.Pub(interfaces, constants.TopicNetworkListUpdate)
 	logger.Debug("Network: Published network_list_update event with %d interfaces", len(interfaces))
 }
 
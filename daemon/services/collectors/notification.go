@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/fsnotify/fsnotify"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/constants"
 	"github.com/ruaan-deysel/unraid-management-agent/daemon/domain"
 	"github.com/ruaan-deysel/unraid-management-agent/daemon/dto"
 	"github.com/ruaan-deysel/unraid-management-agent/daemon/logger"
@@ -109,7 +110,7 @@ func (c *NotificationCollector) collect() {
 		Timestamp:     time.Now(),
 	}
 
-	c.ctx.Hub.Pub(notificationList, "notifications_update")
+	c.ctx.Hub.Pub(notificationList, constants.TopicNotificationsUpdate)
 }
 
 // collectNotifications reads all notification files from a directory
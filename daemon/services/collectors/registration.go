@@ -69,7 +69,7 @@ func (c *RegistrationCollector) Collect() {
 	}
 
 	logger.Debug("Registration: Successfully collected, publishing event")
-	c.ctx.Hub.Pub(registration, "registration_update")
+	c.ctx.Hub.Pub(registration, constants.TopicRegistrationUpdate)
 	logger.Debug("Registration: Published registration_update event - type=%s, state=%s", registration.Type, registration.State)
 }
 
@@ -75,7 +75,7 @@ func (c *ShareCollector) Collect() {
 
 	logger.Debug("Share: Successfully collected %d shares, publishing event", len(shares))
 	// Publish event
-	c.ctx.Hub.Pub(shares, "share_list_update")
+	c.ctx.Hub.Pub(shares, constants.TopicShareListUpdate)
 	logger.Debug("Share: Published share_list_update event with %d shares", len(shares))
 }
 
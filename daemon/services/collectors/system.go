@@ -10,6 +10,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/constants"
 	"github.com/ruaan-deysel/unraid-management-agent/daemon/domain"
 	"github.com/ruaan-deysel/unraid-management-agent/daemon/dto"
 	"github.com/ruaan-deysel/unraid-management-agent/daemon/lib"
@@ -76,7 +77,7 @@ func (c *SystemCollector) Collect() {
 	}
 
 	// Publish event
-	c.ctx.Hub.Pub(systemInfo, "system_update")
+	c.ctx.Hub.Pub(systemInfo, constants.TopicSystemUpdate)
 	logger.Debug("Published system_update event")
 }
 
@@ -0,0 +1,66 @@
+package collectors
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestCollectorsPublishConstantTopics ensures collectors publish only on topics declared in
+// the constants package, so every published topic is part of constants.CollectorTopics().
+func TestCollectorsPublishConstantTopics(t *testing.T) {
+	files, err := filepath.Glob("*.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fset := token.NewFileSet()
+	calls := 0
+	for _, path := range files {
+		if strings.HasSuffix(path, "_test.go") {
+			continue
+		}
+		file, err := parser.ParseFile(fset, path, nil, 0)
+		if err != nil {
+			t.Fatalf("failed to parse %s: %v", path, err)
+		}
+
+		ast.Inspect(file, func(n ast.Node) bool {
+			call, ok := n.(*ast.CallExpr)
+			if !ok {
+				return true
+			}
+			sel, ok := call.Fun.(*ast.SelectorExpr)
+			if !ok || sel.Sel.Name != "Pub" || len(call.Args) < 2 {
+				return true
+			}
+			calls++
+			for _, arg := range call.Args[1:] {
+				topic, ok := arg.(*ast.SelectorExpr)
+				pkg, _ := topicPackage(topic)
+				if !ok || pkg != "constants" || !strings.HasPrefix(topic.Sel.Name, "Topic") {
+					t.Errorf("%s: Hub.Pub topic must be a constants.Topic* constant", fset.Position(arg.Pos()))
+				}
+			}
+			return true
+		})
+	}
+
+	if calls == 0 {
+		t.Fatal("no Hub.Pub calls found")
+	}
+}
+
+func topicPackage(sel *ast.SelectorExpr) (string, bool) {
+	if sel == nil {
+		return "", false
+	}
+	ident, ok := sel.X.(*ast.Ident)
+	if !ok {
+		return "", false
+	}
+	return ident.Name, true
+}
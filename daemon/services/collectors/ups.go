@@ -7,6 +7,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/constants"
 	"github.com/ruaan-deysel/unraid-management-agent/daemon/domain"
 	"github.com/ruaan-deysel/unraid-management-agent/daemon/dto"
 	"github.com/ruaan-deysel/unraid-management-agent/daemon/lib"
@@ -55,7 +56,7 @@ func (c *UPSCollector) Collect() {
 	if lib.CommandExists("apcaccess") {
 		upsData, err = c.collectAPC()
 		if err == nil {
-			c.ctx.Hub.Pub(upsData, "ups_status_update")
+			c.ctx.Hub.Pub(upsData, constants.TopicUPSStatusUpdate)
 			logger.Debug("Published ups_status_update event (APC)")
 			return
 		}
@@ -66,7 +67,7 @@ func (c *UPSCollector) Collect() {
 	if lib.CommandExists("upsc") {
 		upsData, err = c.collectNUT()
 		if err == nil {
-			c.ctx.Hub.Pub(upsData, "ups_status_update")
+			c.ctx.Hub.Pub(upsData, constants.TopicUPSStatusUpdate)
 			logger.Debug("Published ups_status_update event (NUT)")
 			return
 		}
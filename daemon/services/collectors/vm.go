@@ -9,9 +9,11 @@ import (
 	"sync"
 	"time"
 
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/constants"
 	"github.com/ruaan-deysel/unraid-management-agent/daemon/domain"
 	"github.com/ruaan-deysel/unraid-management-agent/daemon/dto"
 	"github.com/ruaan-deysel/unraid-management-agent/daemon/lib"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/lib/libvirt"
 	"github.com/ruaan-deysel/unraid-management-agent/daemon/logger"
 )
 
@@ -24,8 +26,11 @@ type cpuStats struct {
 
 // VMCollector collects information about virtual machines managed by libvirt/virsh.
 // It gathers VM status, resource allocation, CPU usage, and configuration details.
+// It reads the statistics of all domains in one call on the libvirt socket and falls back to
+// running virsh per VM when the socket is unavailable.
 type VMCollector struct {
 	ctx           *domain.Context
+	client        *libvirt.Client
 	cpuStatsMutex sync.RWMutex
 	previousStats map[string]*cpuStats // vmName -> previous CPU stats
 }
@@ -34,6 +39,7 @@ type VMCollector struct {
 func NewVMCollector(ctx *domain.Context) *VMCollector {
 	return &VMCollector{
 		ctx:           ctx,
+		client:        libvirt.NewClient(constants.LibvirtSocket),
 		previousStats: make(map[string]*cpuStats),
 	}
 }
@@ -57,29 +63,121 @@ func (c *VMCollector) Start(ctx context.Context, interval time.Duration) {
 }
 
 // Collect gathers virtual machine information and publishes it to the event bus.
-// It uses virsh to query VM status and calculates CPU usage based on previous measurements.
+// It queries libvirt over its socket, falling back to virsh when the socket cannot be reached,
+// and calculates CPU usage based on previous measurements.
 func (c *VMCollector) Collect() {
 
 	logger.Debug("Collecting vm data...")
 
-	// Check if virsh is available
-	if !lib.CommandExists("virsh") {
-		logger.Warning("virsh command not found, skipping collection")
+	vms, err := c.collectFromAPI()
+	if err != nil && !libvirt.IsUnreachable(err) {
+		logger.Error("Failed to collect VMs: %v", err)
 		return
 	}
-
-	// Collect VM information
-	vms, err := c.collectVMs()
 	if err != nil {
-		logger.Error("Failed to collect VMs: %v", err)
-		return
+		logger.Debug("libvirt socket unavailable, falling back to virsh: %v", err)
+
+		// Check if virsh is available
+		if !lib.CommandExists("virsh") {
+			logger.Warning("virsh command not found, skipping collection")
+			return
+		}
+
+		// Collect VM information
+		vms, err = c.collectVMs()
+		if err != nil {
+			logger.Error("Failed to collect VMs: %v", err)
+			return
+		}
 	}
 
 	// Publish event
-	c.ctx.Hub.Pub(vms, "vm_list_update")
+	c.ctx.Hub.Pub(vms, constants.TopicVMListUpdate)
 	logger.Debug("Published vm_list_update event with %d VMs", len(vms))
 }
 
+// collectFromAPI builds the VM list from one bulk statistics call plus the autostart and
+// persistent domain lists.
+func (c *VMCollector) collectFromAPI() ([]*dto.VMInfo, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	records, err := c.client.GetAllDomainStats(ctx, libvirt.StatsState|libvirt.StatsCPUTotal|
+		libvirt.StatsBalloon|libvirt.StatsVCPU|libvirt.StatsInterface|libvirt.StatsBlock, 0)
+	if err != nil {
+		return nil, err
+	}
+	autostart, err := c.domainSet(ctx, libvirt.ListAutostart)
+	if err != nil {
+		return nil, err
+	}
+	persistent, err := c.domainSet(ctx, libvirt.ListPersistent)
+	if err != nil {
+		return nil, err
+	}
+
+	vms := make([]*dto.VMInfo, 0, len(records))
+	for _, record := range records {
+		name := record.Domain.Name
+		state, _ := record.Uint("state.state")
+		vcpus, _ := record.Uint("vcpu.current")
+		maxMemory, _ := record.Uint("balloon.maximum")
+
+		vm := &dto.VMInfo{
+			ID:              record.Domain.UUIDString(),
+			Name:            name,
+			State:           libvirt.StateName(int(state)),
+			CPUCount:        int(vcpus),
+			MemoryAllocated: maxMemory * 1024, // KiB to bytes
+			Autostart:       autostart[name],
+			PersistentState: persistent[name],
+			Timestamp:       time.Now(),
+		}
+
+		if state == libvirt.StateRunning {
+			if current, ok := record.Uint("balloon.current"); ok {
+				vm.MemoryUsed = current * 1024
+			}
+
+			// Guest time is what the vCPUs ran; host time includes the emulator threads
+			guestCPUTime := record.Sum("vcpu", "time")
+			if guestCPUTime == 0 {
+				guestCPUTime, _ = record.Uint("cpu.time")
+			}
+			user, _ := record.Uint("cpu.user")
+			system, _ := record.Uint("cpu.system")
+			if vm.CPUCount > 0 {
+				// cpu.user and cpu.system are in nanoseconds; convert to clock ticks
+				vm.GuestCPUPercent, vm.HostCPUPercent = c.updateCPUUsage(name, guestCPUTime, (user+system)/1e7, vm.CPUCount, time.Now())
+			}
+
+			vm.DiskReadBytes = record.Sum("block", "rd.bytes")
+			vm.DiskWriteBytes = record.Sum("block", "wr.bytes")
+			vm.NetworkRXBytes = record.Sum("net", "rx.bytes")
+			vm.NetworkTXBytes = record.Sum("net", "tx.bytes")
+		} else {
+			c.clearCPUStats(name)
+		}
+
+		vm.MemoryDisplay = c.formatMemoryDisplay(vm.MemoryUsed, vm.MemoryAllocated)
+		vms = append(vms, vm)
+	}
+	return vms, nil
+}
+
+// domainSet returns the names of the domains matching flags.
+func (c *VMCollector) domainSet(ctx context.Context, flags uint32) (map[string]bool, error) {
+	domains, err := c.client.ListAllDomains(ctx, flags)
+	if err != nil {
+		return nil, err
+	}
+	set := make(map[string]bool, len(domains))
+	for _, d := range domains {
+		set[d.Name] = true
+	}
+	return set, nil
+}
+
 func (c *VMCollector) collectVMs() ([]*dto.VMInfo, error) {
 	// Get list of all VM names (one per line)
 	// This approach handles VM names with spaces correctly
@@ -291,6 +389,14 @@ func (c *VMCollector) getVMCPUUsage(vmName string, numVCPUs int) (float64, float
 		hostCPUTime = 0
 	}
 
+	guestCPUPercent, hostCPUPercent := c.updateCPUUsage(vmName, guestCPUTime, hostCPUTime, numVCPUs, currentTime)
+	return guestCPUPercent, hostCPUPercent, nil
+}
+
+// updateCPUUsage stores a CPU time sample for a VM and returns the guest and host CPU
+// percentages since the previous sample. Guest time is in nanoseconds, host time in clock ticks;
+// a host time of 0 means it is not available.
+func (c *VMCollector) updateCPUUsage(vmName string, guestCPUTime, hostCPUTime uint64, numVCPUs int, currentTime time.Time) (float64, float64) {
 	// Calculate percentages using historical data
 	c.cpuStatsMutex.Lock()
 	defer c.cpuStatsMutex.Unlock()
@@ -339,7 +445,7 @@ func (c *VMCollector) getVMCPUUsage(vmName string, numVCPUs int) (float64, float
 		timestamp:    currentTime,
 	}
 
-	return guestCPUPercent, hostCPUPercent, nil
+	return guestCPUPercent, hostCPUPercent
 }
 
 // getGuestCPUTime returns cumulative guest CPU time in nanoseconds
@@ -3,9 +3,12 @@ package collectors
 import (
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/cskr/pubsub"
 	"github.com/ruaan-deysel/unraid-management-agent/daemon/domain"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/lib/libvirt"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/lib/libvirt/libvirttest"
 )
 
 func TestNewVMCollector(t *testing.T) {
@@ -96,3 +99,51 @@ func TestVMStateMapping(t *testing.T) {
 		})
 	}
 }
+
+func TestVMCollectorUsesLibvirtSocket(t *testing.T) {
+	server := libvirttest.NewServer(t)
+	collector := NewVMCollector(&domain.Context{Hub: pubsub.New(10)})
+	collector.client = libvirt.NewClient(server.Socket())
+	t.Cleanup(func() { _ = collector.client.Close() })
+
+	vms, err := collector.collectFromAPI()
+	if err != nil {
+		t.Fatalf("collectFromAPI() error = %v", err)
+	}
+	if len(vms) != 3 {
+		t.Fatalf("collectFromAPI() returned %d VMs, want 3", len(vms))
+	}
+
+	windows, ubuntu := vms[0], vms[2]
+	if windows.ID != "5b0e4a2c-8f1d-4c3e-9a6b-2d7f1e0c4b8a" || windows.Name != "Windows 11" || windows.State != "running" {
+		t.Errorf("unexpected Windows VM: %+v", windows)
+	}
+	if windows.CPUCount != 4 || windows.MemoryAllocated != 8<<30 || windows.MemoryUsed != 8<<30 || windows.MemoryDisplay != "8.00 GB / 8.00 GB" {
+		t.Errorf("unexpected Windows resources: %+v", windows)
+	}
+	if windows.DiskReadBytes != 21474836480+52428800 || windows.NetworkRXBytes != 7340121088 || !windows.Autostart || !windows.PersistentState {
+		t.Errorf("unexpected Windows I/O: %+v", windows)
+	}
+	if ubuntu.State != "shut off" || ubuntu.CPUCount != 2 || ubuntu.MemoryAllocated != 2<<30 || ubuntu.MemoryUsed != 0 || ubuntu.Autostart {
+		t.Errorf("unexpected Ubuntu VM: %+v", ubuntu)
+	}
+
+	// All VMs come from three calls on one connection instead of virsh runs per VM
+	if got := len(server.Requests()); got != 4 {
+		t.Errorf("collection made %d calls: %v", got, server.Requests())
+	}
+}
+
+func TestUpdateCPUUsage(t *testing.T) {
+	collector := NewVMCollector(&domain.Context{Hub: pubsub.New(10)})
+	start := time.Now()
+
+	if guest, host := collector.updateCPUUsage("vm", 0, 100, 2, start); guest != 0 || host != 0 {
+		t.Errorf("first sample = %v, %v, want 0, 0", guest, host)
+	}
+	// One vCPU of two busy for 10s, 5s of host CPU time (500 ticks)
+	guest, host := collector.updateCPUUsage("vm", 10e9, 600, 2, start.Add(10*time.Second))
+	if guest != 50 || host != 50 {
+		t.Errorf("second sample = %v, %v, want 50, 50", guest, host)
+	}
+}
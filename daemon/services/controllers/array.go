@@ -3,8 +3,11 @@ package controllers
 
 import (
 	"fmt"
+	"strings"
 
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/constants"
 	"github.com/ruaan-deysel/unraid-management-agent/daemon/domain"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/dto"
 	"github.com/ruaan-deysel/unraid-management-agent/daemon/lib"
 	"github.com/ruaan-deysel/unraid-management-agent/daemon/logger"
 )
@@ -12,12 +15,32 @@ import (
 // ArrayController provides control operations for the Unraid array.
 // It handles array start/stop, parity check operations, and array management commands.
 type ArrayController struct {
-	ctx *domain.Context
+	ctx    *domain.Context
+	emcmd  string
+	hdparm string
+}
+
+// ArrayOptions overrides the binaries an ArrayController runs for disk control. Empty fields keep
+// the Unraid defaults.
+type ArrayOptions struct {
+	Emcmd  string
+	Hdparm string
 }
 
 // NewArrayController creates a new array controller with the given context.
 func NewArrayController(ctx *domain.Context) *ArrayController {
-	return &ArrayController{ctx: ctx}
+	return NewArrayControllerWithOptions(ctx, ArrayOptions{})
+}
+
+// NewArrayControllerWithOptions creates an array controller with the given binaries.
+func NewArrayControllerWithOptions(ctx *domain.Context, opts ArrayOptions) *ArrayController {
+	if opts.Emcmd == "" {
+		opts.Emcmd = constants.EmcmdBin
+	}
+	if opts.Hdparm == "" {
+		opts.Hdparm = constants.HdparmBin
+	}
+	return &ArrayController{ctx: ctx, emcmd: opts.Emcmd, hdparm: opts.Hdparm}
 }
 
 // StartArray starts the Unraid array
@@ -117,12 +140,13 @@ func (c *ArrayController) ResumeParityCheck() error {
 	return nil
 }
 
-// SpinDownDisk spins down a specific disk
+// SpinDownDisk spins down a disk by its Unraid name (parity, disk1, cache). The command goes
+// through emhttpd, like the webGUI's spin buttons, so emhttpd's own spin state stays in sync and
+// pool devices are covered too.
 func (c *ArrayController) SpinDownDisk(diskName string) error {
 	logger.Info("Array: Spinning down disk %s...", diskName)
 
-	// Use mdcmd to spin down disk
-	_, err := lib.ExecCommand("/usr/local/sbin/mdcmd", "spindown", diskName)
+	_, err := lib.ExecCommand(c.emcmd, "cmdSpindown="+diskName)
 	if err != nil {
 		logger.Error("Array: Failed to spin down disk %s: %v", diskName, err)
 		return fmt.Errorf("failed to spin down disk: %w", err)
@@ -132,12 +156,11 @@ func (c *ArrayController) SpinDownDisk(diskName string) error {
 	return nil
 }
 
-// SpinUpDisk spins up a specific disk
+// SpinUpDisk spins up a disk by its Unraid name
 func (c *ArrayController) SpinUpDisk(diskName string) error {
 	logger.Info("Array: Spinning up disk %s...", diskName)
 
-	// Use mdcmd to spin up disk
-	_, err := lib.ExecCommand("/usr/local/sbin/mdcmd", "spinup", diskName)
+	_, err := lib.ExecCommand(c.emcmd, "cmdSpinup="+diskName)
 	if err != nil {
 		logger.Error("Array: Failed to spin up disk %s: %v", diskName, err)
 		return fmt.Errorf("failed to spin up disk: %w", err)
@@ -146,3 +169,43 @@ func (c *ArrayController) SpinUpDisk(diskName string) error {
 	logger.Info("Array: Disk %s spun up successfully", diskName)
 	return nil
 }
+
+// SetSpindownDelay changes the spin-down delay of a disk through emhttpd, as the disk settings
+// page does. The delay takes the values of disks.ini: 0 never spins the disk down and -1 uses the
+// default delay.
+func (c *ArrayController) SetSpindownDelay(disk dto.DiskInfo, delay int) error {
+	_, err := lib.ExecCommand(c.emcmd, fmt.Sprintf("changeDisk=apply&diskSpindownDelay.%d=%d", disk.Index, delay))
+	if err != nil {
+		logger.Error("Array: Failed to set spin-down delay of disk %s: %v", disk.Name, err)
+		return fmt.Errorf("failed to set spin-down delay: %w", err)
+	}
+
+	logger.Info("Array: Spin-down delay of disk %s set to %d", disk.Name, delay)
+	return nil
+}
+
+// DiskSpinState asks a drive for its power state with hdparm -C, which does not wake it. It
+// returns "active", "standby" or "unknown". NVMe devices have no standby and are always active.
+func (c *ArrayController) DiskSpinState(device string) string {
+	if strings.HasPrefix(device, "nvme") {
+		return "active"
+	}
+	lines, err := lib.ExecCommand(c.hdparm, "-C", "/dev/"+device)
+	if err != nil {
+		logger.Debug("Array: Failed to read spin state of %s: %v", device, err)
+		return "unknown"
+	}
+	for _, line := range lines {
+		_, state, ok := strings.Cut(line, "drive state is:")
+		if !ok {
+			continue
+		}
+		switch strings.TrimSpace(state) {
+		case "standby", "sleeping":
+			return "standby"
+		case "active/idle", "idle", "active":
+			return "active"
+		}
+	}
+	return "unknown"
+}
@@ -1,9 +1,12 @@
 package controllers
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/ruaan-deysel/unraid-management-agent/daemon/domain"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/dto"
 )
 
 func TestNewArrayController(t *testing.T) {
@@ -60,6 +63,30 @@ func TestArrayControllerInterface(t *testing.T) {
 	})
 }
 
+func TestSetSpindownDelay(t *testing.T) {
+	dir := t.TempDir()
+	log := filepath.Join(dir, "emcmd.log")
+	emcmd := filepath.Join(dir, "emcmd")
+	if err := os.WriteFile(emcmd, []byte("#!/bin/sh\nprintf '%s\\n' \"$*\" >> "+log+"\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	ac := NewArrayControllerWithOptions(&domain.Context{}, ArrayOptions{Emcmd: emcmd, Hdparm: "hdparm"})
+
+	if err := ac.SetSpindownDelay(dto.DiskInfo{Name: "disk3", Index: 3}, 0); err != nil {
+		t.Fatalf("SetSpindownDelay() error = %v", err)
+	}
+	if err := ac.SetSpindownDelay(dto.DiskInfo{Name: "disk3", Index: 3}, -1); err != nil {
+		t.Fatalf("SetSpindownDelay() error = %v", err)
+	}
+	data, err := os.ReadFile(log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "changeDisk=apply&diskSpindownDelay.3=0\nchangeDisk=apply&diskSpindownDelay.3=-1\n"; string(data) != want {
+		t.Errorf("emcmd called with %q, want %q", data, want)
+	}
+}
+
 func TestArrayControllerParityCheckModes(t *testing.T) {
 	// Skip if not in integration test mode
 	if testing.Short() {
@@ -1,51 +1,182 @@
 package controllers
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
 	"github.com/ruaan-deysel/unraid-management-agent/daemon/constants"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/dto"
 	"github.com/ruaan-deysel/unraid-management-agent/daemon/lib"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/lib/dockerapi"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/lib/dockerman"
 	"github.com/ruaan-deysel/unraid-management-agent/daemon/logger"
 )
 
+// ErrNoImageTag is returned by Update for containers created from an image ID, which have no
+// tag that could point to a newer image.
+var ErrNoImageTag = errors.New("container was created from an image ID and has no tag to update")
+
 // DockerController provides control operations for Docker containers.
 // It handles container lifecycle operations including start, stop, restart, pause, and unpause.
-type DockerController struct{}
+// Operations go through the Engine API on the Docker socket, with the docker CLI as a fallback
+// when the socket cannot be reached. Containers can also be created from the user's dockerMan
+// templates.
+type DockerController struct {
+	client        *dockerapi.Client
+	templates     *dockerman.Store
+	autostartFile string
+}
+
+// DockerOptions overrides the paths a DockerController uses. Empty fields keep the Unraid
+// defaults.
+type DockerOptions struct {
+	// Socket is the Engine API socket
+	Socket string
+	// TemplatesDir holds the user's dockerMan templates
+	TemplatesDir string
+	// AutostartFile is dockerMan's autostart list
+	AutostartFile string
+}
 
 // NewDockerController creates a new Docker controller.
 func NewDockerController() *DockerController {
-	return &DockerController{}
+	return NewDockerControllerWithOptions(DockerOptions{})
+}
+
+// NewDockerControllerWithOptions creates a Docker controller with the given paths.
+func NewDockerControllerWithOptions(opts DockerOptions) *DockerController {
+	if opts.Socket == "" {
+		opts.Socket = constants.DockerSocket
+	}
+	if opts.TemplatesDir == "" {
+		opts.TemplatesDir = constants.DockerTemplatesDir
+	}
+	if opts.AutostartFile == "" {
+		opts.AutostartFile = constants.DockerAutostartFile
+	}
+	return &DockerController{
+		client:        dockerapi.NewClient(opts.Socket),
+		templates:     dockerman.NewStore(opts.TemplatesDir),
+		autostartFile: opts.AutostartFile,
+	}
 }
 
 // Start starts a Docker container by ID or name.
 func (dc *DockerController) Start(containerID string) error {
 	logger.Info("Starting Docker container: %s", containerID)
-	_, err := lib.ExecCommand(constants.DockerBin, "start", containerID)
-	return err
+	return dc.run("start", containerID)
 }
 
 // Stop stops a Docker container by ID or name.
 func (dc *DockerController) Stop(containerID string) error {
 	logger.Info("Stopping Docker container: %s", containerID)
-	_, err := lib.ExecCommand(constants.DockerBin, "stop", containerID)
-	return err
+	return dc.run("stop", containerID)
 }
 
 // Restart restarts a Docker container by ID or name.
 func (dc *DockerController) Restart(containerID string) error {
 	logger.Info("Restarting Docker container: %s", containerID)
-	_, err := lib.ExecCommand(constants.DockerBin, "restart", containerID)
-	return err
+	return dc.run("restart", containerID)
 }
 
 // Pause pauses a running Docker container by ID or name.
 func (dc *DockerController) Pause(containerID string) error {
 	logger.Info("Pausing Docker container: %s", containerID)
-	_, err := lib.ExecCommand(constants.DockerBin, "pause", containerID)
-	return err
+	return dc.run("pause", containerID)
 }
 
 // Unpause resumes a paused Docker container by ID or name.
 func (dc *DockerController) Unpause(containerID string) error {
 	logger.Info("Unpausing Docker container: %s", containerID)
-	_, err := lib.ExecCommand(constants.DockerBin, "unpause", containerID)
+	return dc.run("unpause", containerID)
+}
+
+// run performs a lifecycle action through the Engine API. Only a failure to connect to the
+// socket falls back to the docker CLI; Engine errors and timeouts are returned as is, since the
+// Engine may still be carrying out the action.
+func (dc *DockerController) run(action, containerID string) error {
+	err := dc.client.ContainerAction(context.Background(), containerID, action)
+	if err == nil || !dockerapi.IsUnreachable(err) {
+		return err
+	}
+
+	logger.Debug("Docker API unavailable, falling back to CLI: %v", err)
+	_, err = lib.ExecCommand(constants.DockerBin, action, containerID)
 	return err
 }
+
+// Logs reads the stdout and stderr logs of a container and calls fn for every line until the
+// log ends, ctx is cancelled or fn returns an error. Logs are only available through the
+// Engine API; there is no CLI fallback.
+func (dc *DockerController) Logs(ctx context.Context, containerID string, opts dockerapi.LogOptions, fn func(dto.ContainerLogLine) error) error {
+	info, err := dc.client.InspectContainer(ctx, containerID)
+	if err != nil {
+		return err
+	}
+
+	return dc.client.ContainerLogs(ctx, info.ID, info.Config.Tty, opts, func(l dockerapi.LogLine) error {
+		line := dto.ContainerLogLine{Stream: l.Stream, Message: l.Text}
+		if !l.Timestamp.IsZero() {
+			ts := l.Timestamp
+			line.Timestamp = &ts
+		}
+		return fn(line)
+	})
+}
+
+// Update pulls the image a container was created from and, when the pull brought a new image,
+// recreates the container from its existing configuration. Updates need the Engine API; there
+// is no CLI fallback. Pulls can take a long time, so callers should not tie ctx to a request.
+func (dc *DockerController) Update(ctx context.Context, containerID string) (*dto.ContainerUpdateResult, error) {
+	info, err := dc.client.InspectContainer(ctx, containerID)
+	if err != nil {
+		return nil, err
+	}
+	name := strings.TrimPrefix(info.Name, "/")
+	image := info.Config.Image
+	if strings.HasPrefix(image, "sha256:") {
+		return nil, fmt.Errorf("container %s: %w", name, ErrNoImageTag)
+	}
+
+	logger.Info("Updating Docker container %s: pulling %s", name, image)
+	if err := dc.client.PullImage(ctx, image); err != nil {
+		return nil, fmt.Errorf("failed to pull %s: %w", image, err)
+	}
+	pulled, err := dc.client.InspectImage(ctx, image)
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect pulled image %s: %w", image, err)
+	}
+
+	result := &dto.ContainerUpdateResult{
+		Success:         true,
+		Name:            name,
+		Image:           image,
+		ContainerID:     info.ID,
+		PreviousImageID: info.Image,
+		ImageID:         pulled.ID,
+		Timestamp:       time.Now(),
+	}
+	if pulled.ID == info.Image {
+		result.Message = fmt.Sprintf("Container %s is already up to date", name)
+		return result, nil
+	}
+
+	newID, err := dc.client.RecreateContainer(ctx, info.ID)
+	if newID == "" {
+		return nil, fmt.Errorf("failed to recreate container %s: %w", name, err)
+	}
+	if err != nil {
+		// The update itself succeeded; only the renamed old container was left behind
+		logger.Warning("Updated Docker container %s, but %v", name, err)
+	}
+	logger.Info("Updated Docker container %s to image %s", name, pulled.ID)
+
+	result.Updated = true
+	result.ContainerID = newID
+	result.Message = fmt.Sprintf("Container %s updated", name)
+	result.Timestamp = time.Now()
+	return result, nil
+}
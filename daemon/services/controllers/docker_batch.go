@@ -0,0 +1,171 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/dto"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/lib/dockerapi"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/lib/dockerman"
+)
+
+// Orders of batch operations.
+const (
+	// BatchOrderAutostart follows the Unraid autostart list and its wait delays when starting,
+	// and reverses it when stopping.
+	BatchOrderAutostart = "autostart"
+	// BatchOrderNone keeps the containers in the order they were requested in.
+	BatchOrderNone = "none"
+)
+
+// ErrInvalidBatch is returned when a batch request is malformed or matches no container.
+var ErrInvalidBatch = errors.New("invalid batch request")
+
+// BatchTarget is a container a batch operation acts on. Wait is the delay to hold back the
+// following containers after this one has been started.
+type BatchTarget struct {
+	ID   string
+	Name string
+	Wait time.Duration
+}
+
+// BatchTargets resolves the containers of a batch request, by ID or name and by selector, and
+// puts them in the order the action should run in. With the autostart order, containers are
+// started in the order of the Unraid autostart list, followed by the others by name, and
+// stopped or paused in the reverse order. Wait delays only apply when starting or restarting.
+func (dc *DockerController) BatchTargets(ctx context.Context, req dto.DockerBatchRequest) ([]BatchTarget, error) {
+	switch req.Action {
+	case "start", "stop", "restart", "pause", "unpause":
+	default:
+		return nil, fmt.Errorf("%w: unknown action %q", ErrInvalidBatch, req.Action)
+	}
+	if req.Order != BatchOrderAutostart && req.Order != BatchOrderNone {
+		return nil, fmt.Errorf("%w: unknown order %q", ErrInvalidBatch, req.Order)
+	}
+	if len(req.Containers) == 0 && req.Selector == nil {
+		return nil, fmt.Errorf("%w: containers or selector required", ErrInvalidBatch)
+	}
+
+	containers, err := dc.client.ListContainers(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+
+	var autostart []dockerman.Autostart
+	if req.Order == BatchOrderAutostart || (req.Selector != nil && req.Selector.Autostart != nil) {
+		if autostart, err = dockerman.ReadAutostart(dc.autostartFile); err != nil {
+			return nil, fmt.Errorf("failed to read autostart list: %w", err)
+		}
+	}
+	position := make(map[string]int, len(autostart))
+	for i, entry := range autostart {
+		position[entry.Name] = i
+	}
+
+	candidates := containers
+	if len(req.Containers) > 0 {
+		if candidates, err = resolveContainers(containers, req.Containers); err != nil {
+			return nil, err
+		}
+	} else {
+		sort.Slice(candidates, func(i, j int) bool {
+			return containerName(candidates[i].Names) < containerName(candidates[j].Names)
+		})
+	}
+
+	var targets []BatchTarget
+	for _, c := range candidates {
+		name := containerName(c.Names)
+		if req.Selector != nil && !selectorMatches(req.Selector, c, position) {
+			continue
+		}
+		targets = append(targets, BatchTarget{ID: c.ID, Name: name})
+	}
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("%w: no containers match", ErrInvalidBatch)
+	}
+
+	if req.Order == BatchOrderAutostart {
+		sort.SliceStable(targets, func(i, j int) bool {
+			pi, oki := position[targets[i].Name]
+			pj, okj := position[targets[j].Name]
+			switch {
+			case oki && okj:
+				return pi < pj
+			case oki != okj:
+				return oki
+			default:
+				return targets[i].Name < targets[j].Name
+			}
+		})
+		switch req.Action {
+		case "start", "restart":
+			for i := range targets {
+				if p, ok := position[targets[i].Name]; ok {
+					targets[i].Wait = autostart[p].Wait
+				}
+			}
+		case "stop", "pause":
+			for i, j := 0, len(targets)-1; i < j; i, j = i+1, j-1 {
+				targets[i], targets[j] = targets[j], targets[i]
+			}
+		}
+	}
+	return targets, nil
+}
+
+// ContainerAction runs a lifecycle action on a container through the Engine API.
+func (dc *DockerController) ContainerAction(ctx context.Context, containerID, action string) error {
+	return dc.client.ContainerAction(ctx, containerID, action)
+}
+
+// resolveContainers looks up containers by full or short ID or by name, keeping the requested
+// order and dropping duplicates. Every reference must match a container.
+func resolveContainers(containers []dockerapi.Container, refs []string) ([]dockerapi.Container, error) {
+	var resolved []dockerapi.Container
+	var unknown []string
+	seen := make(map[string]bool)
+	for _, ref := range refs {
+		found := false
+		for _, c := range containers {
+			if c.ID == ref || (len(ref) >= 12 && strings.HasPrefix(c.ID, ref)) || containerName(c.Names) == ref {
+				found = true
+				if !seen[c.ID] {
+					seen[c.ID] = true
+					resolved = append(resolved, c)
+				}
+				break
+			}
+		}
+		if !found {
+			unknown = append(unknown, ref)
+		}
+	}
+	if len(unknown) > 0 {
+		return nil, fmt.Errorf("%w: unknown containers: %s", ErrInvalidBatch, strings.Join(unknown, ", "))
+	}
+	return resolved, nil
+}
+
+func selectorMatches(sel *dto.DockerBatchSelector, c dockerapi.Container, autostart map[string]int) bool {
+	for key, value := range sel.Labels {
+		label, ok := c.Labels[key]
+		if !ok || (value != "" && label != value) {
+			return false
+		}
+	}
+	if sel.State != "" && c.State != sel.State {
+		return false
+	}
+	if sel.Autostart != nil {
+		_, enabled := autostart[containerName(c.Names)]
+		if enabled != *sel.Autostart {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,287 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/dto"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/lib/dockerapi"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/logger"
+)
+
+// Prune targets accepted by Prune.
+const (
+	PruneImages     = "images"
+	PruneVolumes    = "volumes"
+	PruneContainers = "containers"
+)
+
+// anonymousVolumeLabel marks volumes the Engine created for a container without a name.
+const anonymousVolumeLabel = "com.docker.volume.anonymous"
+
+// Images returns all local images with the names of the containers created from each.
+func (dc *DockerController) Images(ctx context.Context) ([]dto.DockerImage, error) {
+	images, err := dc.client.ListImages(ctx)
+	if err != nil {
+		return nil, err
+	}
+	containers, err := dc.client.ListContainers(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+
+	users := make(map[string][]string)
+	for _, c := range containers {
+		users[c.ImageID] = append(users[c.ImageID], containerName(c.Names))
+	}
+
+	result := make([]dto.DockerImage, 0, len(images))
+	for _, image := range images {
+		tags := make([]string, 0, len(image.RepoTags))
+		for _, tag := range image.RepoTags {
+			if tag != "<none>:<none>" {
+				tags = append(tags, tag)
+			}
+		}
+		digests := image.RepoDigests
+		if digests == nil {
+			digests = []string{}
+		}
+		used := users[image.ID]
+		if used == nil {
+			used = []string{}
+		}
+		result = append(result, dto.DockerImage{
+			ID:         image.ID,
+			Tags:       tags,
+			Digests:    digests,
+			SizeBytes:  image.Size,
+			Created:    time.Unix(image.Created, 0),
+			Dangling:   image.Dangling(),
+			Containers: used,
+		})
+	}
+
+	// Largest first, since the usual question is what fills docker.img
+	sort.SliceStable(result, func(i, j int) bool { return result[i].SizeBytes > result[j].SizeBytes })
+	return result, nil
+}
+
+// Networks returns all networks with their subnets and the containers attached to them.
+func (dc *DockerController) Networks(ctx context.Context) ([]dto.DockerNetwork, error) {
+	networks, err := dc.client.ListNetworks(ctx)
+	if err != nil {
+		return nil, err
+	}
+	containers, err := dc.client.ListContainers(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+
+	attached := make(map[string][]dto.DockerNetworkContainer)
+	for _, c := range containers {
+		for name, endpoint := range c.NetworkSettings.Networks {
+			attached[name] = append(attached[name], dto.DockerNetworkContainer{
+				ID:         shortID(c.ID),
+				Name:       containerName(c.Names),
+				IPAddress:  endpoint.IPAddress,
+				MacAddress: endpoint.MacAddress,
+			})
+		}
+	}
+
+	result := make([]dto.DockerNetwork, 0, len(networks))
+	for _, network := range networks {
+		subnets := make([]dto.DockerNetworkSubnet, 0, len(network.IPAM.Config))
+		for _, pool := range network.IPAM.Config {
+			subnets = append(subnets, dto.DockerNetworkSubnet{
+				Subnet:  pool.Subnet,
+				Gateway: pool.Gateway,
+				IPRange: pool.IPRange,
+			})
+		}
+		members := attached[network.Name]
+		if members == nil {
+			members = []dto.DockerNetworkContainer{}
+		}
+		result = append(result, dto.DockerNetwork{
+			ID:         shortID(network.ID),
+			Name:       network.Name,
+			Driver:     network.Driver,
+			Scope:      network.Scope,
+			Internal:   network.Internal,
+			Subnets:    subnets,
+			Containers: members,
+		})
+	}
+	return result, nil
+}
+
+// Volumes returns all volumes with their size and the containers mounting them. Sizes come from
+// the Engine's disk usage report, which walks every volume and can take a while.
+func (dc *DockerController) Volumes(ctx context.Context) ([]dto.DockerVolume, error) {
+	usage, err := dc.client.DiskUsage(ctx)
+	if err != nil {
+		return nil, err
+	}
+	containers, err := dc.client.ListContainers(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+
+	mounts := make(map[string][]string)
+	for _, c := range containers {
+		for _, m := range c.Mounts {
+			if m.Type == "volume" {
+				mounts[m.Name] = append(mounts[m.Name], containerName(c.Names))
+			}
+		}
+	}
+
+	result := make([]dto.DockerVolume, 0, len(usage.Volumes))
+	for _, volume := range usage.Volumes {
+		_, anonymous := volume.Labels[anonymousVolumeLabel]
+		v := dto.DockerVolume{
+			Name:       volume.Name,
+			Driver:     volume.Driver,
+			Mountpoint: volume.Mountpoint,
+			Created:    volume.CreatedAt,
+			Anonymous:  anonymous,
+			SizeBytes:  -1,
+			Containers: mounts[volume.Name],
+			Labels:     volume.Labels,
+		}
+		if volume.UsageData != nil {
+			v.SizeBytes = volume.UsageData.Size
+			v.InUse = volume.UsageData.RefCount > 0
+		}
+		if v.Containers == nil {
+			v.Containers = []string{}
+		}
+		v.InUse = v.InUse || len(v.Containers) > 0
+		result = append(result, v)
+	}
+
+	sort.SliceStable(result, func(i, j int) bool { return result[i].SizeBytes > result[j].SizeBytes })
+	return result, nil
+}
+
+// DiskUsage summarizes the space used by images, containers, volumes and the build cache, and
+// how much of it a prune could reclaim.
+func (dc *DockerController) DiskUsage(ctx context.Context) (*dto.DockerDiskUsage, error) {
+	usage, err := dc.client.DiskUsage(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &dto.DockerDiskUsage{Timestamp: time.Now()}
+
+	// Layers shared between images are counted once in LayersSize
+	result.Images.SizeBytes = usage.LayersSize
+	for _, image := range usage.Images {
+		result.Images.Count++
+		if image.Containers > 0 {
+			result.Images.Active++
+		} else {
+			result.Images.ReclaimableBytes += image.Size - max(image.SharedSize, 0)
+		}
+	}
+
+	for _, c := range usage.Containers {
+		result.Containers.Count++
+		result.Containers.SizeBytes += c.SizeRw
+		if c.State == "running" || c.State == "paused" || c.State == "restarting" {
+			result.Containers.Active++
+		} else {
+			result.Containers.ReclaimableBytes += c.SizeRw
+		}
+	}
+
+	for _, volume := range usage.Volumes {
+		result.Volumes.Count++
+		if volume.UsageData == nil || volume.UsageData.Size < 0 {
+			continue
+		}
+		result.Volumes.SizeBytes += volume.UsageData.Size
+		if volume.UsageData.RefCount > 0 {
+			result.Volumes.Active++
+		} else {
+			result.Volumes.ReclaimableBytes += volume.UsageData.Size
+		}
+	}
+
+	for _, entry := range usage.BuildCache {
+		result.BuildCache.Count++
+		result.BuildCache.SizeBytes += entry.Size
+		if entry.InUse {
+			result.BuildCache.Active++
+		} else if !entry.Shared {
+			result.BuildCache.ReclaimableBytes += entry.Size
+		}
+	}
+
+	result.TotalBytes = result.Images.SizeBytes + result.Containers.SizeBytes +
+		result.Volumes.SizeBytes + result.BuildCache.SizeBytes
+	return result, nil
+}
+
+// Prune removes unused resources of one kind: dangling images, stopped containers, or volumes no
+// container uses. Volumes are limited to anonymous ones unless allVolumes is set.
+func (dc *DockerController) Prune(ctx context.Context, target string, allVolumes bool) (*dto.DockerPruneResult, error) {
+	var (
+		report *dockerapi.PruneReport
+		err    error
+	)
+	switch target {
+	case PruneImages:
+		report, err = dc.client.PruneImages(ctx)
+	case PruneVolumes:
+		report, err = dc.client.PruneVolumes(ctx, allVolumes)
+	case PruneContainers:
+		report, err = dc.client.PruneContainers(ctx)
+	default:
+		return nil, fmt.Errorf("unknown prune target: %s", target)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result := &dto.DockerPruneResult{
+		Success:        true,
+		Type:           target,
+		Deleted:        []string{},
+		ReclaimedBytes: report.SpaceReclaimed,
+		Timestamp:      time.Now(),
+	}
+	for _, id := range report.ContainersDeleted {
+		result.Deleted = append(result.Deleted, shortID(id))
+	}
+	for _, image := range report.ImagesDeleted {
+		if image.Deleted != "" {
+			result.Deleted = append(result.Deleted, image.Deleted)
+		}
+	}
+	result.Deleted = append(result.Deleted, report.VolumesDeleted...)
+
+	logger.Info("Pruned Docker %s: %d removed, %d bytes reclaimed", target, len(result.Deleted), result.ReclaimedBytes)
+	return result, nil
+}
+
+// shortID truncates a container or network ID to the 12 characters shown by the docker CLI.
+func shortID(id string) string {
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
+
+// containerName returns the primary name of a container without the leading slash.
+func containerName(names []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	return strings.TrimPrefix(names[0], "/")
+}
@@ -0,0 +1,200 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/dto"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/lib/compose"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/lib/dockerapi"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/logger"
+)
+
+// Stack actions accepted by StackAction.
+const (
+	StackStart   = "start"
+	StackStop    = "stop"
+	StackRestart = "restart"
+	StackPull    = "pull"
+)
+
+// ErrStackNotFound is returned when no container belongs to the requested Compose stack.
+var ErrStackNotFound = errors.New("docker compose stack not found")
+
+// stack is a Compose project's containers grouped by service, with the services in start order.
+type stack struct {
+	name     string
+	order    []string
+	services map[string][]dockerapi.Container
+	deps     map[string][]string
+}
+
+// StackAction starts, stops, restarts or pulls the images of every container of a Compose stack.
+// Services are started after the services they depend on and stopped before them, like
+// docker compose does; when a service fails to start, the services depending on it are skipped.
+// Pull only downloads images; containers keep running their current image until recreated.
+// Per-container outcomes are reported in the result rather than as an error.
+func (dc *DockerController) StackAction(ctx context.Context, name, action string) (*dto.DockerStackResult, error) {
+	switch action {
+	case StackStart, StackStop, StackRestart, StackPull:
+	default:
+		return nil, fmt.Errorf("unknown stack action: %s", action)
+	}
+	s, err := dc.loadStack(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Info("Running %s on Docker Compose stack %s (%d services)", action, name, len(s.order))
+	result := &dto.DockerStackResult{Stack: name, Action: action, Steps: []dto.DockerStackStep{}}
+	switch action {
+	case StackStart:
+		result.Steps = dc.startStack(ctx, s)
+	case StackStop:
+		result.Steps = dc.stopStack(ctx, s)
+	case StackRestart:
+		result.Steps = append(dc.stopStack(ctx, s), dc.startStack(ctx, s)...)
+	case StackPull:
+		result.Steps = dc.pullStack(ctx, s)
+	}
+
+	failed := 0
+	for _, step := range result.Steps {
+		if !step.Success {
+			failed++
+		}
+	}
+	result.Success = failed == 0
+	result.Message = fmt.Sprintf("Stack %s: %s completed", name, action)
+	if failed > 0 {
+		result.Message = fmt.Sprintf("Stack %s: %s failed for %d of %d containers", name, action, failed, len(result.Steps))
+		logger.Warning("%s", result.Message)
+	}
+	result.Timestamp = time.Now()
+	return result, nil
+}
+
+// loadStack lists the containers of a Compose project and orders its services.
+func (dc *DockerController) loadStack(ctx context.Context, name string) (*stack, error) {
+	containers, err := dc.client.ListContainers(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &stack{name: name, services: make(map[string][]dockerapi.Container), deps: make(map[string][]string)}
+	for _, c := range containers {
+		if compose.Project(c.Labels) != name {
+			continue
+		}
+		service := c.Labels[compose.LabelService]
+		s.services[service] = append(s.services[service], c)
+		s.deps[service] = compose.DependsOn(c.Labels)
+	}
+	if len(s.services) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrStackNotFound, name)
+	}
+	for _, replicas := range s.services {
+		sort.Slice(replicas, func(i, j int) bool { return containerName(replicas[i].Names) < containerName(replicas[j].Names) })
+	}
+
+	if s.order, err = compose.Order(s.deps); err != nil {
+		return nil, fmt.Errorf("stack %s: %w", name, err)
+	}
+	return s, nil
+}
+
+// startStack starts services in dependency order, skipping services whose dependencies failed.
+func (dc *DockerController) startStack(ctx context.Context, s *stack) []dto.DockerStackStep {
+	var steps []dto.DockerStackStep
+	failed := make(map[string]string)
+	for _, service := range s.order {
+		var blocker string
+		for _, dep := range s.deps[service] {
+			if _, ok := failed[dep]; ok {
+				blocker = dep
+				break
+			}
+		}
+
+		for _, c := range s.services[service] {
+			step := stackStep(StackStart, service, c)
+			if blocker != "" {
+				step.Skipped = true
+				step.Error = fmt.Sprintf("dependency %s failed to start", blocker)
+				failed[service] = step.Error
+			} else if err := dc.client.ContainerAction(ctx, c.ID, StackStart); err != nil {
+				step.Error = err.Error()
+				failed[service] = step.Error
+			} else {
+				step.Success = true
+			}
+			steps = append(steps, step)
+		}
+	}
+	return steps
+}
+
+// stopStack stops services in reverse dependency order. A failure does not stop the others.
+func (dc *DockerController) stopStack(ctx context.Context, s *stack) []dto.DockerStackStep {
+	var steps []dto.DockerStackStep
+	for i := len(s.order) - 1; i >= 0; i-- {
+		service := s.order[i]
+		for _, c := range s.services[service] {
+			step := stackStep(StackStop, service, c)
+			if err := dc.client.ContainerAction(ctx, c.ID, StackStop); err != nil {
+				step.Error = err.Error()
+			} else {
+				step.Success = true
+			}
+			steps = append(steps, step)
+		}
+	}
+	return steps
+}
+
+// pullStack pulls each image of the stack once and reports for every container whether a newer
+// image than the one it runs was downloaded.
+func (dc *DockerController) pullStack(ctx context.Context, s *stack) []dto.DockerStackStep {
+	type pull struct {
+		id  string
+		err error
+	}
+	pulled := make(map[string]pull)
+
+	var steps []dto.DockerStackStep
+	for _, service := range s.order {
+		for _, c := range s.services[service] {
+			step := stackStep(StackPull, service, c)
+			p, ok := pulled[c.Image]
+			if !ok {
+				if p.err = dc.client.PullImage(ctx, c.Image); p.err == nil {
+					var image *dockerapi.ImageInspect
+					if image, p.err = dc.client.InspectImage(ctx, c.Image); p.err == nil {
+						p.id = image.ID
+					}
+				}
+				pulled[c.Image] = p
+			}
+			if p.err != nil {
+				step.Error = p.err.Error()
+			} else {
+				step.Success = true
+				step.Updated = p.id != c.ImageID
+			}
+			steps = append(steps, step)
+		}
+	}
+	return steps
+}
+
+func stackStep(action, service string, c dockerapi.Container) dto.DockerStackStep {
+	return dto.DockerStackStep{
+		Action:      action,
+		Service:     service,
+		Name:        containerName(c.Names),
+		ContainerID: shortID(c.ID),
+	}
+}
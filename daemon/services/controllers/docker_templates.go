@@ -0,0 +1,112 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/dto"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/logger"
+)
+
+// ErrTemplateNotFound is returned when no dockerMan template has the requested name.
+var ErrTemplateNotFound = errors.New("docker template not found")
+
+// Templates returns the user's dockerMan templates with the container created from each.
+// Templates that cannot be parsed are logged and left out.
+func (dc *DockerController) Templates(ctx context.Context) ([]dto.DockerTemplate, error) {
+	templates, errs := dc.templates.Templates()
+	for _, err := range errs {
+		logger.Warning("Skipping Docker template: %v", err)
+	}
+	containers, err := dc.client.ListContainers(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+
+	byName := make(map[string]int, len(containers))
+	for i, c := range containers {
+		byName[containerName(c.Names)] = i
+	}
+	result := make([]dto.DockerTemplate, 0, len(templates))
+	for _, t := range templates {
+		template := dto.DockerTemplate{ContainerTemplate: t.Info()}
+		if i, ok := byName[t.Name]; ok {
+			template.Installed = true
+			template.ContainerID = shortID(containers[i].ID)
+			template.State = containers[i].State
+		}
+		result = append(result, template)
+	}
+	return result, nil
+}
+
+// Template returns the dockerMan template for the container name.
+func (dc *DockerController) Template(ctx context.Context, name string) (*dto.DockerTemplate, error) {
+	templates, err := dc.Templates(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range templates {
+		if templates[i].Name == name {
+			return &templates[i], nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrTemplateNotFound, name)
+}
+
+// CreateFromTemplate pulls the image of a dockerMan template and creates its container the way
+// Unraid does, replacing an existing container of the same name. The new container is started
+// when start is set. Template options that cannot be applied are reported as warnings.
+func (dc *DockerController) CreateFromTemplate(ctx context.Context, name string, start bool) (*dto.ContainerTemplateResult, error) {
+	t := dc.templates.Get(name)
+	if t == nil {
+		return nil, fmt.Errorf("%w: %s", ErrTemplateNotFound, name)
+	}
+	config, warnings := t.CreateConfig()
+	if warnings == nil {
+		warnings = []string{}
+	}
+
+	logger.Info("Creating Docker container %s from template: pulling %s", name, t.Repository)
+	if err := dc.client.PullImage(ctx, t.Repository); err != nil {
+		// Without registry access a local copy of the image still does the job
+		if _, inspectErr := dc.client.InspectImage(ctx, t.Repository); inspectErr != nil {
+			return nil, fmt.Errorf("failed to pull %s: %w", t.Repository, err)
+		}
+		warnings = append(warnings, fmt.Sprintf("Pulling %s failed, using the local image: %v", t.Repository, err))
+	}
+
+	_, err := dc.client.InspectContainer(ctx, name)
+	replaced := err == nil
+
+	newID, err := dc.client.ReplaceContainer(ctx, name, config, start)
+	if newID == "" {
+		return nil, fmt.Errorf("failed to create container %s: %w", name, err)
+	}
+	if err != nil {
+		logger.Warning("Created Docker container %s, but %v", name, err)
+		warnings = append(warnings, err.Error())
+	}
+	for _, warning := range warnings {
+		logger.Warning("Docker template %s: %s", name, warning)
+	}
+	logger.Info("Created Docker container %s from template %s", name, t.Path)
+
+	message := fmt.Sprintf("Container %s created", name)
+	if replaced {
+		message = fmt.Sprintf("Container %s recreated", name)
+	}
+	return &dto.ContainerTemplateResult{
+		Success:     true,
+		Message:     message,
+		Name:        name,
+		Image:       t.Repository,
+		ContainerID: newID,
+		Replaced:    replaced,
+		Started:     start,
+		Warnings:    warnings,
+		Timestamp:   time.Now(),
+	}, nil
+}
@@ -1,7 +1,11 @@
 package controllers
 
 import (
+	"net/http"
 	"testing"
+
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/lib/dockerapi"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/lib/dockerapi/dockertest"
 )
 
 func TestNewDockerController(t *testing.T) {
@@ -67,3 +71,42 @@ func TestDockerControllerWithInvalidContainer(t *testing.T) {
 		}
 	})
 }
+
+func TestDockerControllerUsesEngineAPI(t *testing.T) {
+	server := dockertest.NewServer(t)
+	dc := &DockerController{client: dockerapi.NewClient(server.Socket())}
+
+	operations := []struct {
+		name string
+		run  func(string) error
+		want string
+	}{
+		{"start", dc.Start, "POST /containers/sonarr/start"},
+		{"stop", dc.Stop, "POST /containers/sonarr/stop"},
+		{"restart", dc.Restart, "POST /containers/sonarr/restart"},
+		{"pause", dc.Pause, "POST /containers/sonarr/pause"},
+		{"unpause", dc.Unpause, "POST /containers/sonarr/unpause"},
+	}
+
+	for _, op := range operations {
+		t.Run(op.name, func(t *testing.T) {
+			if err := op.run("sonarr"); err != nil {
+				t.Fatalf("%s returned error: %v", op.name, err)
+			}
+			if server.Count(op.want) != 1 {
+				t.Errorf("expected %q, got requests %v", op.want, server.Requests())
+			}
+		})
+	}
+
+	t.Run("engine errors are not retried with the CLI", func(t *testing.T) {
+		server.Handle("POST /containers/plex/pause", func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusConflict)
+			_, _ = w.Write([]byte(`{"message":"container is not running"}`))
+		})
+		err := dc.Pause("plex")
+		if !dockerapi.IsAPIError(err) {
+			t.Errorf("Pause() error = %v, want the Engine error", err)
+		}
+	})
+}
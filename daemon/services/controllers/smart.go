@@ -0,0 +1,56 @@
+package controllers
+
+import (
+	"fmt"
+
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/constants"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/dto"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/lib"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/logger"
+)
+
+// SMARTController starts and aborts SMART self-tests and reads their progress.
+type SMARTController struct {
+	smartctl string
+}
+
+// NewSMARTController creates a SMART controller that runs the system smartctl.
+func NewSMARTController() *SMARTController {
+	return NewSMARTControllerWithBinary(constants.SmartctlBin)
+}
+
+// NewSMARTControllerWithBinary creates a SMART controller that runs the given smartctl binary.
+func NewSMARTControllerWithBinary(smartctl string) *SMARTController {
+	return &SMARTController{smartctl: smartctl}
+}
+
+// StartSelfTest starts a "short", "extended" or "conveyance" self-test on a device such as sdb
+func (c *SMARTController) StartSelfTest(device, kind string) error {
+	logger.Info("SMART: Starting %s self-test on %s...", kind, device)
+
+	if err := lib.StartSelfTest(c.smartctl, device, kind); err != nil {
+		logger.Error("SMART: Failed to start %s self-test on %s: %v", kind, device, err)
+		return fmt.Errorf("failed to start self-test: %w", err)
+	}
+
+	logger.Info("SMART: Started %s self-test on %s", kind, device)
+	return nil
+}
+
+// AbortSelfTest aborts the self-test a device is running
+func (c *SMARTController) AbortSelfTest(device string) error {
+	logger.Info("SMART: Aborting self-test on %s...", device)
+
+	if err := lib.AbortSelfTest(c.smartctl, device); err != nil {
+		logger.Error("SMART: Failed to abort self-test on %s: %v", device, err)
+		return fmt.Errorf("failed to abort self-test: %w", err)
+	}
+
+	logger.Info("SMART: Aborted self-test on %s", device)
+	return nil
+}
+
+// ReadSMART reads the SMART data of a device without waking it from standby.
+func (c *SMARTController) ReadSMART(device string) (*dto.DiskSMART, error) {
+	return lib.ReadSMART(c.smartctl, device)
+}
@@ -1,65 +1,100 @@
 package controllers
 
 import (
+	"context"
+	"time"
+
 	"github.com/ruaan-deysel/unraid-management-agent/daemon/constants"
 	"github.com/ruaan-deysel/unraid-management-agent/daemon/lib"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/lib/libvirt"
 	"github.com/ruaan-deysel/unraid-management-agent/daemon/logger"
 )
 
+// vmSaveTimeout bounds hibernation, which writes the whole guest memory to disk.
+const vmSaveTimeout = 10 * time.Minute
+
 // VMController provides control operations for virtual machines managed by libvirt.
 // It handles VM lifecycle operations including start, stop, restart, pause, resume, hibernate, and force stop.
-type VMController struct{}
+// Operations go through the libvirt socket, with virsh as a fallback when the socket cannot be
+// reached.
+type VMController struct {
+	client *libvirt.Client
+	sysPCI string
+}
+
+// VMOptions overrides the paths a VMController uses. Empty fields keep the Unraid defaults.
+type VMOptions struct {
+	// Socket is the libvirtd socket
+	Socket string
+}
 
 // NewVMController creates a new VM controller.
 func NewVMController() *VMController {
-	return &VMController{}
+	return NewVMControllerWithOptions(VMOptions{})
+}
+
+// NewVMControllerWithOptions creates a VM controller with the given paths.
+func NewVMControllerWithOptions(opts VMOptions) *VMController {
+	if opts.Socket == "" {
+		opts.Socket = constants.LibvirtSocket
+	}
+	return &VMController{client: libvirt.NewClient(opts.Socket), sysPCI: constants.SysPCIDevices}
 }
 
 // Start starts a virtual machine by name.
 func (vc *VMController) Start(vmName string) error {
 	logger.Info("Starting VM: %s", vmName)
-	_, err := lib.ExecCommand(constants.VirshBin, "start", vmName)
-	return err
+	return vc.run(context.Background(), "start", vmName)
 }
 
 // Stop gracefully shuts down a virtual machine by name.
 func (vc *VMController) Stop(vmName string) error {
 	logger.Info("Stopping VM: %s", vmName)
-	_, err := lib.ExecCommand(constants.VirshBin, "shutdown", vmName)
-	return err
+	return vc.run(context.Background(), "shutdown", vmName)
 }
 
 // Restart reboots a virtual machine by name.
 func (vc *VMController) Restart(vmName string) error {
 	logger.Info("Restarting VM: %s", vmName)
-	_, err := lib.ExecCommand(constants.VirshBin, "reboot", vmName)
-	return err
+	return vc.run(context.Background(), "reboot", vmName)
 }
 
 // Pause suspends a running virtual machine by name.
 func (vc *VMController) Pause(vmName string) error {
 	logger.Info("Pausing VM: %s", vmName)
-	_, err := lib.ExecCommand(constants.VirshBin, "suspend", vmName)
-	return err
+	return vc.run(context.Background(), "suspend", vmName)
 }
 
 // Resume resumes a paused virtual machine by name.
 func (vc *VMController) Resume(vmName string) error {
 	logger.Info("Resuming VM: %s", vmName)
-	_, err := lib.ExecCommand(constants.VirshBin, "resume", vmName)
-	return err
+	return vc.run(context.Background(), "resume", vmName)
 }
 
 // Hibernate saves the VM state to disk and stops it.
 func (vc *VMController) Hibernate(vmName string) error {
 	logger.Info("Hibernating VM: %s", vmName)
-	_, err := lib.ExecCommand(constants.VirshBin, "managedsave", vmName)
-	return err
+	ctx, cancel := context.WithTimeout(context.Background(), vmSaveTimeout)
+	defer cancel()
+	return vc.run(ctx, "managedsave", vmName)
 }
 
 // ForceStop immediately terminates a virtual machine by name without graceful shutdown.
 func (vc *VMController) ForceStop(vmName string) error {
 	logger.Info("Force stopping VM: %s", vmName)
-	_, err := lib.ExecCommand(constants.VirshBin, "destroy", vmName)
+	return vc.run(context.Background(), "destroy", vmName)
+}
+
+// run performs a lifecycle action through the libvirt socket. Only a failure to reach the socket
+// falls back to virsh; after a libvirt error or a timeout libvirtd may still be carrying out the
+// action.
+func (vc *VMController) run(ctx context.Context, action, vmName string) error {
+	err := vc.client.DomainAction(ctx, vmName, action)
+	if err == nil || !libvirt.IsUnreachable(err) {
+		return err
+	}
+
+	logger.Debug("libvirt socket unavailable, falling back to virsh: %v", err)
+	_, err = lib.ExecCommand(constants.VirshBin, action, vmName)
 	return err
 }
@@ -0,0 +1,277 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/dto"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/lib/libvirt"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/logger"
+)
+
+// ErrNoConsole is returned for a VM without a VNC console to attach to, because it has no VNC
+// graphics or is not running.
+var ErrNoConsole = errors.New("VM has no VNC console")
+
+// pciClasses name the PCI base classes (and the USB controller subclass) of devices commonly
+// passed through to VMs.
+var pciClasses = map[string]string{
+	"0x01":   "storage",
+	"0x02":   "network",
+	"0x03":   "gpu",
+	"0x04":   "audio",
+	"0x0c03": "usb",
+}
+
+// Details returns the configuration of a VM from its domain XML, with the size of each disk and
+// what the host knows about each passed-through PCI device. While the guest agent is connected it
+// adds what the agent reports, including the addresses of each NIC. Like snapshots, it needs the
+// libvirt socket.
+func (vc *VMController) Details(ctx context.Context, vmName string) (*dto.VMDetails, error) {
+	dom, err := vc.client.LookupDomain(ctx, vmName)
+	if err != nil {
+		return nil, err
+	}
+	desc, err := vc.client.DomainDescription(ctx, dom)
+	if err != nil {
+		return nil, err
+	}
+
+	details := &dto.VMDetails{
+		Name:        desc.Name,
+		UUID:        desc.UUID,
+		Description: desc.Description,
+		Arch:        desc.OS.Type.Arch,
+		MachineType: desc.OS.Type.Machine,
+		BIOS:        "SeaBIOS",
+		NVRAM:       desc.OS.NVRAM,
+		MemoryBytes: desc.Memory.Bytes(),
+		VCPUs:       desc.VCPU.Count,
+		CPUMode:     desc.CPU.Mode,
+		VCPUPins:    make([]dto.VMVCPUPin, 0, len(desc.CPUTune.VCPUPins)),
+		PinnedCPUs:  []int{},
+		Disks:       make([]dto.VMDisk, 0, len(desc.Devices.Disks)),
+		NICs:        make([]dto.VMNIC, 0, len(desc.Devices.Interfaces)),
+		PCIDevices:  []dto.VMPCIDevice{},
+		USBDevices:  []dto.VMUSBDevice{},
+		Graphics:    make([]dto.VMGraphics, 0, len(desc.Devices.Graphics)),
+		Timestamp:   time.Now(),
+	}
+	if loader := desc.OS.Loader; loader != nil {
+		details.Loader = strings.TrimSpace(loader.Path)
+	}
+	if desc.OS.Firmware == "efi" || strings.Contains(strings.ToUpper(details.Loader), "OVMF") {
+		details.BIOS = "OVMF"
+	}
+	if t := desc.CPU.Topology; t != nil {
+		details.CPUTopology = &dto.VMCPUTopology{Sockets: t.Sockets, Dies: t.Dies, Cores: t.Cores, Threads: t.Threads}
+	}
+	if pin := desc.CPUTune.EmulatorPin; pin != nil {
+		details.EmulatorPin = pin.CPUSet
+	}
+
+	pinned := make(map[int]bool)
+	for _, pin := range desc.CPUTune.VCPUPins {
+		cpus, err := libvirt.ParseCPUSet(pin.CPUSet)
+		if err != nil {
+			logger.Warning("VM %s: %v", vmName, err)
+			cpus = []int{}
+		}
+		for _, cpu := range cpus {
+			if !pinned[cpu] {
+				pinned[cpu] = true
+				details.PinnedCPUs = append(details.PinnedCPUs, cpu)
+			}
+		}
+		details.VCPUPins = append(details.VCPUPins, dto.VMVCPUPin{VCPU: pin.VCPU, CPUSet: pin.CPUSet, CPUs: cpus})
+	}
+	sort.Ints(details.PinnedCPUs)
+
+	for _, disk := range desc.Devices.Disks {
+		d := dto.VMDisk{
+			Target:   disk.Target.Dev,
+			Device:   disk.Device,
+			Bus:      disk.Target.Bus,
+			Format:   disk.Driver.Type,
+			Source:   disk.Path(),
+			Cache:    disk.Driver.Cache,
+			Serial:   disk.Serial,
+			ReadOnly: disk.ReadOnly != nil,
+		}
+		if disk.Boot != nil {
+			d.BootOrder = disk.Boot.Order
+		}
+		// An empty CD-ROM drive has no size
+		if d.Source != "" {
+			info, err := vc.client.GetBlockInfo(ctx, dom, disk.Target.Dev)
+			if err != nil {
+				logger.Debug("VM %s: no size for disk %s: %v", vmName, disk.Target.Dev, err)
+			}
+			d.CapacityBytes, d.AllocationBytes = info.Capacity, info.Allocation
+		}
+		details.Disks = append(details.Disks, d)
+	}
+
+	for _, iface := range desc.Devices.Interfaces {
+		nic := dto.VMNIC{MAC: iface.MAC.Address, Type: iface.Type, Model: iface.Model.Type, Target: iface.Target.Dev}
+		switch {
+		case iface.Source.Bridge != "":
+			nic.Source = iface.Source.Bridge
+		case iface.Source.Network != "":
+			nic.Source = iface.Source.Network
+		default:
+			nic.Source = iface.Source.Dev
+		}
+		details.NICs = append(details.NICs, nic)
+	}
+
+	for _, dev := range desc.Devices.HostDevs {
+		if dev.Mode != "" && dev.Mode != "subsystem" {
+			continue
+		}
+		switch dev.Type {
+		case "pci":
+			if dev.Source.Address == nil {
+				continue
+			}
+			pci := vc.pciDevice(dev.Source.Address.PCI())
+			pci.Managed = dev.Managed == "yes"
+			if dev.ROM != nil {
+				pci.ROMFile = dev.ROM.File
+			}
+			details.PCIDevices = append(details.PCIDevices, pci)
+		case "usb":
+			var usb dto.VMUSBDevice
+			if dev.Source.Vendor != nil {
+				usb.VendorID = dev.Source.Vendor.ID
+			}
+			if dev.Source.Product != nil {
+				usb.ProductID = dev.Source.Product.ID
+			}
+			if addr := dev.Source.Address; addr != nil {
+				usb.Bus, _ = strconv.Atoi(addr.Bus)
+				usb.Device, _ = strconv.Atoi(addr.Device)
+			}
+			details.USBDevices = append(details.USBDevices, usb)
+		}
+	}
+
+	for _, g := range desc.Devices.Graphics {
+		details.Graphics = append(details.Graphics, dto.VMGraphics{
+			Type:          g.Type,
+			Port:          max(g.Port, 0),
+			WebsocketPort: max(g.WebSocket, 0),
+			Listen:        g.Listen,
+			AutoPort:      g.AutoPort == "yes",
+		})
+	}
+
+	for _, ch := range desc.Devices.Channels {
+		if ch.Target.Name == libvirt.GuestAgentChannel {
+			details.GuestAgent = &dto.VMGuestAgent{Channel: ch.Target.Name, State: ch.Target.State}
+		}
+	}
+
+	// Only ask a connected agent, so VMs without one do not wait for the agent timeout
+	if details.GuestAgent != nil && details.GuestAgent.State == "connected" {
+		macs := make([]string, 0, len(details.NICs))
+		for _, nic := range details.NICs {
+			macs = append(macs, nic.MAC)
+		}
+		guest, err := vc.guestInfo(ctx, dom, macs)
+		if err != nil {
+			logger.Debug("VM %s: guest agent did not answer: %v", vmName, err)
+		} else {
+			details.Guest = guest
+			for i := range details.NICs {
+				details.NICs[i].IPAddresses = nicAddresses(guest, details.NICs[i].MAC)
+			}
+		}
+	}
+
+	return details, nil
+}
+
+// nicAddresses returns the addresses the guest reports for the interface with a MAC, without
+// link-local ones.
+func nicAddresses(guest *dto.VMGuestInfo, mac string) []string {
+	var addrs []string
+	for _, iface := range guest.Interfaces {
+		if !strings.EqualFold(iface.MAC, mac) {
+			continue
+		}
+		for _, addr := range iface.IPAddresses {
+			if ip := net.ParseIP(addr.Address); ip != nil && !ip.IsLinkLocalUnicast() {
+				addrs = append(addrs, addr.Address)
+			}
+		}
+	}
+	return addrs
+}
+
+// ConsoleAddress returns the host and port of a running VM's VNC server. QEMU listening on all
+// addresses is reached through the loopback address.
+func (vc *VMController) ConsoleAddress(ctx context.Context, vmName string) (string, error) {
+	dom, err := vc.client.LookupDomain(ctx, vmName)
+	if err != nil {
+		return "", err
+	}
+	desc, err := vc.client.DomainDescription(ctx, dom)
+	if err != nil {
+		return "", err
+	}
+
+	for _, g := range desc.Devices.Graphics {
+		if g.Type != "vnc" {
+			continue
+		}
+		if g.Port <= 0 {
+			return "", fmt.Errorf("%w: VM %s is not running", ErrNoConsole, vmName)
+		}
+		host := g.Listen
+		if host == "" || net.ParseIP(host).IsUnspecified() {
+			host = "127.0.0.1"
+		}
+		return net.JoinHostPort(host, strconv.Itoa(g.Port)), nil
+	}
+	return "", fmt.Errorf("%w: VM %s has no VNC graphics", ErrNoConsole, vmName)
+}
+
+// pciDevice describes a host PCI device from sysfs. A device that is missing, for example
+// because the card was removed, is reported with its address only.
+func (vc *VMController) pciDevice(address string) dto.VMPCIDevice {
+	dev := dto.VMPCIDevice{Address: address}
+	dir := filepath.Join(vc.sysPCI, address)
+	read := func(name string) string {
+		//nolint:gosec // G304: Path is built from the sysfs PCI directory and a formatted PCI address
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return ""
+		}
+		return strings.TrimSpace(string(data))
+	}
+
+	dev.VendorID = read("vendor")
+	dev.DeviceID = read("device")
+	dev.Class = read("class")
+	if len(dev.Class) >= 6 {
+		dev.Type = "other"
+		if t, ok := pciClasses[dev.Class[:6]]; ok {
+			dev.Type = t
+		} else if t, ok := pciClasses[dev.Class[:4]]; ok {
+			dev.Type = t
+		}
+	}
+	if driver, err := os.Readlink(filepath.Join(dir, "driver")); err == nil {
+		dev.Driver = filepath.Base(driver)
+	}
+	return dev
+}
@@ -0,0 +1,159 @@
+package controllers
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/dto"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/lib/libvirt"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/logger"
+)
+
+// guestInfoTypes are the information groups requested from the guest agent.
+const guestInfoTypes = libvirt.GuestInfoUsers | libvirt.GuestInfoOS | libvirt.GuestInfoTimezone |
+	libvirt.GuestInfoHostname | libvirt.GuestInfoFilesystem
+
+// GuestInfo asks the QEMU guest agent of a running VM for its operating system, hostname,
+// network addresses, filesystems and logged in users. Like the other guest agent calls it fails
+// with an error for which libvirt.IsAgentUnavailable is true when the VM has no agent or the
+// agent does not answer.
+func (vc *VMController) GuestInfo(ctx context.Context, vmName string) (*dto.VMGuestInfo, error) {
+	dom, err := vc.client.LookupDomain(ctx, vmName)
+	if err != nil {
+		return nil, err
+	}
+	desc, err := vc.client.DomainDescription(ctx, dom)
+	if err != nil {
+		return nil, err
+	}
+	macs := make([]string, 0, len(desc.Devices.Interfaces))
+	for _, iface := range desc.Devices.Interfaces {
+		macs = append(macs, iface.MAC.Address)
+	}
+	return vc.guestInfo(ctx, dom, macs)
+}
+
+// guestInfo queries the guest agent of dom. The addresses of guest interfaces whose MAC is one of
+// macs, the VM's own NICs, are also listed in IPAddresses.
+func (vc *VMController) guestInfo(ctx context.Context, dom libvirt.Domain, macs []string) (*dto.VMGuestInfo, error) {
+	params, err := vc.client.GetGuestInfo(ctx, dom, guestInfoTypes)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &dto.VMGuestInfo{
+		Hostname:    params.String("hostname"),
+		Timezone:    params.String("timezone.name"),
+		IPAddresses: []string{},
+		Interfaces:  []dto.VMGuestInterface{},
+		Filesystems: []dto.VMGuestFilesystem{},
+		Users:       []dto.VMGuestUser{},
+		Timestamp:   time.Now(),
+	}
+	if offset, ok := params.Int("timezone.offset"); ok {
+		info.TimezoneOffset = int(offset)
+	}
+	if name := params.String("os.name"); name != "" {
+		info.OS = &dto.VMGuestOS{
+			ID:            params.String("os.id"),
+			Name:          name,
+			PrettyName:    params.String("os.pretty-name"),
+			Version:       params.String("os.version"),
+			VersionID:     params.String("os.version-id"),
+			KernelRelease: params.String("os.kernel-release"),
+			KernelVersion: params.String("os.kernel-version"),
+			Machine:       params.String("os.machine"),
+		}
+	}
+
+	count, _ := params.Uint("fs.count")
+	for i := uint64(0); i < count; i++ {
+		prefix := "fs." + strconv.FormatUint(i, 10) + "."
+		fs := dto.VMGuestFilesystem{
+			Mountpoint: params.String(prefix + "mountpoint"),
+			Device:     params.String(prefix + "name"),
+			Type:       params.String(prefix + "fstype"),
+		}
+		fs.TotalBytes, _ = params.Uint(prefix + "total-bytes")
+		fs.UsedBytes, _ = params.Uint(prefix + "used-bytes")
+		if fs.TotalBytes > 0 {
+			fs.UsagePercent = float64(fs.UsedBytes) / float64(fs.TotalBytes) * 100
+		}
+		info.Filesystems = append(info.Filesystems, fs)
+	}
+
+	count, _ = params.Uint("users.count")
+	for i := uint64(0); i < count; i++ {
+		prefix := "users." + strconv.FormatUint(i, 10) + "."
+		user := dto.VMGuestUser{Name: params.String(prefix + "name"), Domain: params.String(prefix + "domain")}
+		if ms, ok := params.Uint(prefix + "login-time"); ok {
+			user.LoginTime = time.UnixMilli(int64(ms))
+		}
+		info.Users = append(info.Users, user)
+	}
+
+	// Agents too old for interface queries still report the rest
+	ifaces, err := vc.client.InterfaceAddresses(ctx, dom)
+	if err != nil {
+		logger.Debug("VM %s: no guest interfaces: %v", dom.Name, err)
+	}
+	for _, iface := range ifaces {
+		gi := dto.VMGuestInterface{Name: iface.Name, MAC: iface.HWAddr, IPAddresses: make([]dto.VMGuestIPAddress, 0, len(iface.Addrs))}
+		for _, addr := range iface.Addrs {
+			kind := "ipv4"
+			if addr.Type == libvirt.AddressIPv6 {
+				kind = "ipv6"
+			}
+			gi.IPAddresses = append(gi.IPAddresses, dto.VMGuestIPAddress{Type: kind, Address: addr.Addr, Prefix: addr.Prefix})
+		}
+		info.Interfaces = append(info.Interfaces, gi)
+	}
+	for _, mac := range macs {
+		info.IPAddresses = append(info.IPAddresses, nicAddresses(info, mac)...)
+	}
+	return info, nil
+}
+
+// GuestShutdown shuts a VM down through its guest agent instead of the ACPI power button.
+func (vc *VMController) GuestShutdown(ctx context.Context, vmName string) error {
+	logger.Info("Shutting down VM %s through the guest agent", vmName)
+	dom, err := vc.client.LookupDomain(ctx, vmName)
+	if err != nil {
+		return err
+	}
+	return vc.client.ShutdownGuest(ctx, dom)
+}
+
+// FreezeFilesystems flushes and freezes the guest filesystems of a VM, for example before backing
+// up its disk images from the host, and returns how many were frozen. Guest writes block until
+// ThawFilesystems is called.
+func (vc *VMController) FreezeFilesystems(ctx context.Context, vmName string) (int, error) {
+	logger.Info("Freezing filesystems of VM %s", vmName)
+	dom, err := vc.client.LookupDomain(ctx, vmName)
+	if err != nil {
+		return 0, err
+	}
+	return vc.client.FSFreeze(ctx, dom)
+}
+
+// ThawFilesystems thaws the guest filesystems of a VM and returns how many were thawed.
+func (vc *VMController) ThawFilesystems(ctx context.Context, vmName string) (int, error) {
+	logger.Info("Thawing filesystems of VM %s", vmName)
+	dom, err := vc.client.LookupDomain(ctx, vmName)
+	if err != nil {
+		return 0, err
+	}
+	return vc.client.FSThaw(ctx, dom)
+}
+
+// SyncTime sets the guest clock of a VM from its hardware clock, which lags after the VM was
+// paused, hibernated or reverted to a snapshot.
+func (vc *VMController) SyncTime(ctx context.Context, vmName string) error {
+	logger.Info("Synchronizing guest time of VM %s", vmName)
+	dom, err := vc.client.LookupDomain(ctx, vmName)
+	if err != nil {
+		return err
+	}
+	return vc.client.SyncTime(ctx, dom)
+}
@@ -0,0 +1,145 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/dto"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/lib/libvirt"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/logger"
+)
+
+// ErrInvalidSnapshot is returned for snapshot requests that cannot be satisfied as given.
+var ErrInvalidSnapshot = errors.New("invalid snapshot request")
+
+// Snapshots lists the snapshots of a VM, oldest first. Snapshots need the libvirt socket; there
+// is no virsh fallback.
+func (vc *VMController) Snapshots(ctx context.Context, vmName string) ([]dto.VMSnapshot, error) {
+	snaps, err := vc.client.ListSnapshots(ctx, vmName)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]dto.VMSnapshot, 0, len(snaps))
+	for _, snap := range snaps {
+		desc, err := vc.client.SnapshotDescription(ctx, snap)
+		if libvirt.IsNotFound(err) {
+			continue // deleted while listing
+		}
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, snapshotInfo(desc))
+	}
+	return result, nil
+}
+
+// Snapshot returns one snapshot of a VM.
+func (vc *VMController) Snapshot(ctx context.Context, vmName, name string) (*dto.VMSnapshot, error) {
+	snap, err := vc.client.LookupSnapshot(ctx, vmName, name)
+	if err != nil {
+		return nil, err
+	}
+	desc, err := vc.client.SnapshotDescription(ctx, snap)
+	if err != nil {
+		return nil, err
+	}
+	info := snapshotInfo(desc)
+	return &info, nil
+}
+
+// CreateSnapshot takes a snapshot of a VM. Without DiskOnly the disks are captured inside their
+// qcow2 images, together with the memory of a running VM. DiskOnly snapshots write new overlay
+// files and can be quiesced through the QEMU guest agent; when the agent is not available the
+// snapshot is taken without quiescing and the result carries a warning. Internal snapshots of a
+// running VM save its memory, which can take minutes.
+func (vc *VMController) CreateSnapshot(ctx context.Context, vmName string, req dto.VMSnapshotRequest) (*dto.VMSnapshotResult, error) {
+	if req.Quiesce && !req.DiskOnly {
+		return nil, fmt.Errorf("%w: quiesce requires a disk-only snapshot", ErrInvalidSnapshot)
+	}
+
+	desc := libvirt.SnapshotXML{Name: req.Name, Description: req.Description}
+	var flags uint32
+	if req.DiskOnly {
+		flags |= libvirt.SnapshotCreateDiskOnly | libvirt.SnapshotCreateAtomic
+	}
+
+	logger.Info("Creating snapshot %q of VM %s (disk-only: %v, quiesce: %v)", req.Name, vmName, req.DiskOnly, req.Quiesce)
+	result := &dto.VMSnapshotResult{}
+	snap, err := vc.client.CreateSnapshot(ctx, vmName, desc, flags|quiesceFlag(req.Quiesce))
+	if err != nil && req.Quiesce && libvirt.IsAgentUnavailable(err) {
+		logger.Warning("Guest agent of VM %s unavailable, taking snapshot without quiescing: %v", vmName, err)
+		result.Warning = "guest agent unavailable, snapshot was not quiesced"
+		snap, err = vc.client.CreateSnapshot(ctx, vmName, desc, flags)
+	} else if err == nil {
+		result.Quiesced = req.Quiesce
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	created, err := vc.client.SnapshotDescription(ctx, snap)
+	if err != nil {
+		return nil, err
+	}
+	result.VMSnapshot = snapshotInfo(created)
+	return result, nil
+}
+
+func quiesceFlag(quiesce bool) uint32 {
+	if quiesce {
+		return libvirt.SnapshotCreateQuiesce
+	}
+	return 0
+}
+
+// RevertSnapshot restores a VM to a snapshot. libvirt only reverts to internal snapshots.
+func (vc *VMController) RevertSnapshot(ctx context.Context, vmName, name string) error {
+	snap, err := vc.client.LookupSnapshot(ctx, vmName, name)
+	if err != nil {
+		return err
+	}
+	logger.Info("Reverting VM %s to snapshot %s", vmName, name)
+	return vc.client.RevertSnapshot(ctx, snap)
+}
+
+// DeleteSnapshot deletes a snapshot of a VM; its children are attached to its parent. libvirt
+// only deletes internal snapshots.
+func (vc *VMController) DeleteSnapshot(ctx context.Context, vmName, name string) error {
+	snap, err := vc.client.LookupSnapshot(ctx, vmName, name)
+	if err != nil {
+		return err
+	}
+	logger.Info("Deleting snapshot %s of VM %s", name, vmName)
+	return vc.client.DeleteSnapshot(ctx, snap)
+}
+
+// snapshotInfo converts a libvirt snapshot description. A snapshot is external when any disk was
+// captured in an overlay file.
+func snapshotInfo(desc *libvirt.SnapshotXML) dto.VMSnapshot {
+	info := dto.VMSnapshot{
+		Name:        desc.Name,
+		Description: desc.Description,
+		Type:        "internal",
+		State:       desc.State,
+		Memory:      desc.Memory != nil && desc.Memory.Snapshot != "no",
+		Disks:       make([]dto.VMSnapshotDisk, 0, len(desc.Disks)),
+		CreatedAt:   time.Unix(desc.CreationTime, 0),
+	}
+	if desc.Parent != nil {
+		info.Parent = desc.Parent.Name
+	}
+	for _, disk := range desc.Disks {
+		d := dto.VMSnapshotDisk{Name: disk.Name, Snapshot: disk.Snapshot}
+		if disk.Source != nil {
+			d.File = disk.Source.File
+		}
+		if disk.Snapshot == "external" {
+			info.Type = "external"
+		}
+		info.Disks = append(info.Disks, d)
+	}
+	return info
+}
@@ -1,7 +1,17 @@
 package controllers
 
 import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
 	"testing"
+	"time"
+
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/dto"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/lib/libvirt"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/lib/libvirt/libvirttest"
 )
 
 func TestNewVMController(t *testing.T) {
@@ -81,3 +91,227 @@ func TestVMControllerWithInvalidVM(t *testing.T) {
 		}
 	})
 }
+
+func TestVMControllerUsesLibvirtSocket(t *testing.T) {
+	server := libvirttest.NewServer(t)
+	vc := NewVMControllerWithOptions(VMOptions{Socket: server.Socket()})
+
+	// Each operation is valid in the state the previous one left the VM in
+	operations := []struct {
+		name  string
+		run   func(string) error
+		want  string
+		state int
+	}{
+		{"pause", vc.Pause, "DOMAIN_SUSPEND Windows 11", libvirt.StatePaused},
+		{"resume", vc.Resume, "DOMAIN_RESUME Windows 11", libvirt.StateRunning},
+		{"restart", vc.Restart, "DOMAIN_REBOOT Windows 11", libvirt.StateRunning},
+		{"stop", vc.Stop, "DOMAIN_SHUTDOWN Windows 11", libvirt.StateShutoff},
+		{"start", vc.Start, "DOMAIN_CREATE Windows 11", libvirt.StateRunning},
+		{"hibernate", vc.Hibernate, "DOMAIN_MANAGED_SAVE Windows 11", libvirt.StateShutoff},
+		{"start again", vc.Start, "DOMAIN_CREATE Windows 11", libvirt.StateRunning},
+		{"force stop", vc.ForceStop, "DOMAIN_DESTROY Windows 11", libvirt.StateShutoff},
+	}
+
+	for _, op := range operations {
+		t.Run(op.name, func(t *testing.T) {
+			if err := op.run("Windows 11"); err != nil {
+				t.Fatalf("%s returned error: %v", op.name, err)
+			}
+			if server.Count(op.want) == 0 {
+				t.Errorf("expected %q, got requests %v", op.want, server.Requests())
+			}
+			if state := server.State("Windows 11"); state != op.state {
+				t.Errorf("state = %s, want %s", libvirt.StateName(state), libvirt.StateName(op.state))
+			}
+		})
+	}
+
+	t.Run("libvirt errors are not retried with virsh", func(t *testing.T) {
+		if err := vc.Pause("Ubuntu Server"); !libvirt.IsAPIError(err) {
+			t.Errorf("Pause() error = %v, want the libvirt error", err)
+		}
+		if err := vc.Start("Windows 10"); !libvirt.IsNotFound(err) {
+			t.Errorf("Start() error = %v, want not found", err)
+		}
+	})
+
+	t.Run("timeouts are not retried with virsh", func(t *testing.T) {
+		release := server.Hold("DOMAIN_MANAGED_SAVE")
+		defer release()
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		if err := vc.run(ctx, "managedsave", "Windows 11"); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("run() error = %v, want the deadline", err)
+		}
+	})
+}
+
+func TestCreateSnapshot(t *testing.T) {
+	server := libvirttest.NewServer(t)
+	vc := NewVMControllerWithOptions(VMOptions{Socket: server.Socket()})
+	ctx := context.Background()
+
+	t.Run("quiesce falls back without guest agent", func(t *testing.T) {
+		result, err := vc.CreateSnapshot(ctx, "Windows 11", dto.VMSnapshotRequest{Name: "pre-24H2", DiskOnly: true, Quiesce: true})
+		if err != nil {
+			t.Fatalf("CreateSnapshot() error = %v", err)
+		}
+		if result.Quiesced || result.Warning == "" {
+			t.Errorf("Quiesced = %v, Warning = %q", result.Quiesced, result.Warning)
+		}
+		if result.Type != "external" || result.Disks[0].File == "" {
+			t.Errorf("unexpected snapshot: %+v", result.VMSnapshot)
+		}
+	})
+
+	t.Run("quiesce with guest agent", func(t *testing.T) {
+		result, err := vc.CreateSnapshot(ctx, "Home Assistant", dto.VMSnapshotRequest{Name: "backup", DiskOnly: true, Quiesce: true})
+		if err != nil {
+			t.Fatalf("CreateSnapshot() error = %v", err)
+		}
+		if !result.Quiesced || result.Warning != "" {
+			t.Errorf("Quiesced = %v, Warning = %q", result.Quiesced, result.Warning)
+		}
+	})
+
+	t.Run("internal snapshot of running VM saves memory", func(t *testing.T) {
+		result, err := vc.CreateSnapshot(ctx, "Home Assistant", dto.VMSnapshotRequest{Name: "before-2024.12"})
+		if err != nil {
+			t.Fatalf("CreateSnapshot() error = %v", err)
+		}
+		if result.Type != "internal" || !result.Memory || result.Parent != "backup" {
+			t.Errorf("unexpected snapshot: %+v", result.VMSnapshot)
+		}
+	})
+
+	t.Run("quiesce requires disk-only", func(t *testing.T) {
+		_, err := vc.CreateSnapshot(ctx, "Home Assistant", dto.VMSnapshotRequest{Quiesce: true})
+		if !errors.Is(err, ErrInvalidSnapshot) {
+			t.Errorf("CreateSnapshot() error = %v, want ErrInvalidSnapshot", err)
+		}
+	})
+}
+
+func TestVMDetails(t *testing.T) {
+	server := libvirttest.NewServer(t)
+	vc := NewVMControllerWithOptions(VMOptions{Socket: server.Socket()})
+
+	// The GPU of the Windows 11 VM bound to vfio-pci; its audio function is missing from sysfs
+	vc.sysPCI = t.TempDir()
+	gpu := filepath.Join(vc.sysPCI, "0000:01:00.0")
+	if err := os.MkdirAll(gpu, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for name, value := range map[string]string{"vendor": "0x10de\n", "device": "0x2484\n", "class": "0x030000\n"} {
+		if err := os.WriteFile(filepath.Join(gpu, name), []byte(value), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink("../../../bus/pci/drivers/vfio-pci", filepath.Join(gpu, "driver")); err != nil {
+		t.Fatal(err)
+	}
+
+	details, err := vc.Details(context.Background(), "Windows 11")
+	if err != nil {
+		t.Fatalf("Details() error = %v", err)
+	}
+	if details.BIOS != "OVMF" || details.MachineType != "pc-q35-7.2" || details.MemoryBytes != 8<<30 || details.VCPUs != 4 {
+		t.Errorf("unexpected details: %+v", details)
+	}
+	if !reflect.DeepEqual(details.PinnedCPUs, []int{4, 5, 16, 17}) || details.EmulatorPin != "0,12" {
+		t.Errorf("PinnedCPUs = %v, EmulatorPin = %q", details.PinnedCPUs, details.EmulatorPin)
+	}
+	if len(details.Disks) != 2 || details.Disks[0].CapacityBytes != 107374182400 || details.Disks[0].Bus != "virtio" || !details.Disks[1].ReadOnly {
+		t.Errorf("unexpected disks: %+v", details.Disks)
+	}
+	if len(details.NICs) != 1 || details.NICs[0].Source != "br0" || details.NICs[0].MAC != "52:54:00:3a:7c:11" {
+		t.Errorf("unexpected NICs: %+v", details.NICs)
+	}
+	wantPCI := []dto.VMPCIDevice{
+		{Address: "0000:01:00.0", Managed: true, VendorID: "0x10de", DeviceID: "0x2484", Class: "0x030000", Type: "gpu", Driver: "vfio-pci", ROMFile: "/mnt/user/isos/vbios/RTX3070.rom"},
+		{Address: "0000:01:00.1", Managed: true},
+	}
+	if !reflect.DeepEqual(details.PCIDevices, wantPCI) {
+		t.Errorf("PCIDevices = %+v", details.PCIDevices)
+	}
+	if len(details.USBDevices) != 1 || details.USBDevices[0] != (dto.VMUSBDevice{VendorID: "0x046d", ProductID: "0xc52b", Bus: 1, Device: 4}) {
+		t.Errorf("unexpected USB devices: %+v", details.USBDevices)
+	}
+	if details.GuestAgent == nil || details.GuestAgent.State != "disconnected" || len(details.Graphics) != 0 {
+		t.Errorf("GuestAgent = %+v, Graphics = %+v", details.GuestAgent, details.Graphics)
+	}
+	if details.Guest != nil || server.Count("DOMAIN_GET_GUEST_INFO Windows 11") != 0 {
+		t.Errorf("disconnected guest agent was queried: %+v", details.Guest)
+	}
+
+	ha, err := vc.Details(context.Background(), "Home Assistant")
+	if err != nil {
+		t.Fatalf("Details() error = %v", err)
+	}
+	if len(ha.Graphics) != 1 || ha.Graphics[0] != (dto.VMGraphics{Type: "vnc", Port: 5900, WebsocketPort: 5700, Listen: "0.0.0.0", AutoPort: true}) {
+		t.Errorf("unexpected graphics: %+v", ha.Graphics)
+	}
+	if ha.Guest == nil || ha.Guest.Hostname != "homeassistant" || !reflect.DeepEqual(ha.NICs[0].IPAddresses, []string{"192.168.20.57", "fd12:3456:789a:1::57"}) {
+		t.Errorf("Guest = %+v, NICs = %+v", ha.Guest, ha.NICs)
+	}
+
+	// A shut off VM has no console ports yet
+	ubuntu, err := vc.Details(context.Background(), "Ubuntu Server")
+	if err != nil {
+		t.Fatalf("Details() error = %v", err)
+	}
+	if ubuntu.BIOS != "SeaBIOS" || ubuntu.Graphics[0].Port != 0 || ubuntu.Disks[0].Source == "" || ubuntu.GuestAgent != nil || len(ubuntu.VCPUPins) != 0 {
+		t.Errorf("unexpected details: %+v", ubuntu)
+	}
+}
+
+func TestGuestAgent(t *testing.T) {
+	server := libvirttest.NewServer(t)
+	vc := NewVMControllerWithOptions(VMOptions{Socket: server.Socket()})
+	ctx := context.Background()
+
+	info, err := vc.GuestInfo(ctx, "Home Assistant")
+	if err != nil {
+		t.Fatalf("GuestInfo() error = %v", err)
+	}
+	if info.OS == nil || info.OS.PrettyName != "Home Assistant OS 12.1" || info.Timezone != "AEST" || info.TimezoneOffset != 36000 {
+		t.Errorf("unexpected guest info: %+v", info)
+	}
+	// Addresses of container bridges inside the guest are not the VM's addresses
+	if !reflect.DeepEqual(info.IPAddresses, []string{"192.168.20.57", "fd12:3456:789a:1::57"}) || len(info.Interfaces) != 4 {
+		t.Errorf("IPAddresses = %v, Interfaces = %+v", info.IPAddresses, info.Interfaces)
+	}
+	if info.Interfaces[1].IPAddresses[1] != (dto.VMGuestIPAddress{Type: "ipv6", Address: "fd12:3456:789a:1::57", Prefix: 64}) {
+		t.Errorf("unexpected address: %+v", info.Interfaces[1].IPAddresses[1])
+	}
+	if len(info.Filesystems) != 2 || info.Filesystems[0].Mountpoint != "/mnt/data" || info.Filesystems[1].UsagePercent != 100 {
+		t.Errorf("unexpected filesystems: %+v", info.Filesystems)
+	}
+	if len(info.Users) != 1 || info.Users[0].Name != "root" || info.Users[0].LoginTime.Unix() != 1730812345 {
+		t.Errorf("unexpected users: %+v", info.Users)
+	}
+
+	if n, err := vc.FreezeFilesystems(ctx, "Home Assistant"); err != nil || n != 2 || !server.Frozen("Home Assistant") {
+		t.Errorf("FreezeFilesystems() = %d, %v", n, err)
+	}
+	if n, err := vc.ThawFilesystems(ctx, "Home Assistant"); err != nil || n != 2 || server.Frozen("Home Assistant") {
+		t.Errorf("ThawFilesystems() = %d, %v", n, err)
+	}
+	if err := vc.SyncTime(ctx, "Home Assistant"); err != nil || server.Count("DOMAIN_SET_TIME Home Assistant") != 1 {
+		t.Errorf("SyncTime() error = %v", err)
+	}
+	if err := vc.GuestShutdown(ctx, "Home Assistant"); err != nil || server.State("Home Assistant") != libvirt.StateShutoff {
+		t.Errorf("GuestShutdown() error = %v", err)
+	}
+
+	if _, err := vc.GuestInfo(ctx, "Windows 11"); !libvirt.IsAgentUnavailable(err) {
+		t.Errorf("GuestInfo() without agent error = %v", err)
+	}
+	if err := vc.SyncTime(ctx, "Ubuntu Server"); !libvirt.IsConflict(err) {
+		t.Errorf("SyncTime() of shut off VM error = %v", err)
+	}
+	if _, err := vc.GuestInfo(ctx, "Windows 10"); !libvirt.IsNotFound(err) {
+		t.Errorf("GuestInfo() of unknown VM error = %v", err)
+	}
+}
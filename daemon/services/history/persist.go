@@ -0,0 +1,128 @@
+package history
+
+import (
+	"compress/gzip"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// snapshotVersion is bumped whenever the on-disk format changes incompatibly.
+const snapshotVersion = 1
+
+type snapshot struct {
+	Version int
+	Tiers   []Tier
+	Series  []snapshotSeries
+}
+
+type snapshotSeries struct {
+	Metric string
+	Entity string
+	Tiers  []ring
+}
+
+// Save writes the store to path as gzip-compressed gob. The file is written to a temporary
+// name first and renamed so a crash never leaves a truncated history behind.
+func (s *Store) Save(path string) error {
+	s.mu.RLock()
+	snap := snapshot{Version: snapshotVersion, Tiers: s.tiers, Series: make([]snapshotSeries, 0, len(s.series))}
+	for key, ser := range s.series {
+		tiers := make([]ring, len(ser.tiers))
+		for i := range ser.tiers {
+			tiers[i] = ser.tiers[i].trim(len(ser.tiers[i].Buckets))
+		}
+		snap.Series = append(snap.Series, snapshotSeries{Metric: key.Metric, Entity: key.Entity, Tiers: tiers})
+	}
+	s.mu.RUnlock()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create history directory: %w", err)
+	}
+
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to create history file: %w", err)
+	}
+
+	zw := gzip.NewWriter(f)
+	if err := gob.NewEncoder(zw).Encode(&snap); err != nil {
+		_ = f.Close()
+		_ = os.Remove(tmp)
+		return fmt.Errorf("failed to encode history: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		_ = f.Close()
+		_ = os.Remove(tmp)
+		return fmt.Errorf("failed to compress history: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("failed to write history file: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("failed to replace history file: %w", err)
+	}
+	return nil
+}
+
+// Load replaces the store contents with the history saved at path. A missing file is not an
+// error. Tiers are matched by resolution, so changing the retention keeps the data of tiers
+// whose resolution is unchanged; buckets beyond the new retention are dropped.
+func (s *Store) Load(path string) error {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open history file: %w", err)
+	}
+	defer f.Close()
+
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return fmt.Errorf("failed to read history file: %w", err)
+	}
+	defer zr.Close()
+
+	var snap snapshot
+	if err := gob.NewDecoder(zr).Decode(&snap); err != nil {
+		return fmt.Errorf("failed to decode history file: %w", err)
+	}
+	if snap.Version != snapshotVersion {
+		return fmt.Errorf("unsupported history file version %d", snap.Version)
+	}
+
+	// Map each saved tier onto the configured tier with the same resolution
+	mapping := make([]int, len(snap.Tiers))
+	for i, saved := range snap.Tiers {
+		mapping[i] = -1
+		for j, tier := range s.tiers {
+			if tier.Resolution == saved.Resolution {
+				mapping[i] = j
+			}
+		}
+	}
+
+	loaded := make(map[seriesKey]*series, len(snap.Series))
+	for _, saved := range snap.Series {
+		ser := &series{tiers: make([]ring, len(s.tiers))}
+		for i, r := range saved.Tiers {
+			if i >= len(mapping) || mapping[i] < 0 {
+				continue
+			}
+			j := mapping[i]
+			ser.tiers[j] = r.trim(s.tiers[j].capacity())
+		}
+		loaded[seriesKey{Metric: saved.Metric, Entity: saved.Entity}] = ser
+	}
+
+	s.mu.Lock()
+	s.series = loaded
+	s.mu.Unlock()
+	return nil
+}
@@ -0,0 +1,40 @@
+package history
+
+import (
+	"context"
+	"time"
+
+	"github.com/cskr/pubsub"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/constants"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/logger"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/services/metrics"
+)
+
+// Run records every collector event published on hub until ctx is cancelled. When path is set the
+// store is saved there every saveInterval; the final save on shutdown is left to the caller so it
+// can complete before the process exits.
+func (s *Store) Run(ctx context.Context, hub *pubsub.PubSub, path string, saveInterval time.Duration) {
+	ch := hub.Sub(constants.CollectorTopics()...)
+
+	var save <-chan time.Time
+	if path != "" && saveInterval > 0 {
+		ticker := time.NewTicker(saveInterval)
+		defer ticker.Stop()
+		save = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Info("History recorder stopping due to context cancellation")
+			hub.Unsub(ch)
+			return
+		case msg := <-ch:
+			s.Add(metrics.FromEvent(msg), s.now())
+		case <-save:
+			if err := s.Save(path); err != nil {
+				logger.Warning("History: Failed to save %s: %v", path, err)
+			}
+		}
+	}
+}
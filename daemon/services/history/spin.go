@@ -0,0 +1,282 @@
+package history
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cskr/pubsub"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/constants"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/dto"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/logger"
+)
+
+// spinRequestWindow is how long after a spin request through the API a matching state change is
+// attributed to it. The disk collector only samples every 30 seconds.
+const spinRequestWindow = 2 * time.Minute
+
+// maxSpinProcesses caps the processes recorded for one wake-up.
+const maxSpinProcesses = 20
+
+// SpinTracker keeps the recent spin state changes of each disk, taken from the disk list updates
+// on the hub, with what was accessing the disk when it woke up.
+type SpinTracker struct {
+	mu      sync.Mutex
+	procDir string
+	size    int
+	now     func() time.Time
+	disks   map[string]*spinDisk
+}
+
+// spinDisk is the tracked state of one disk, keyed by device.
+type spinDisk struct {
+	name        string
+	state       string
+	readBytes   uint64
+	writeBytes  uint64
+	since       time.Time
+	events      []dto.DiskSpinEvent
+	requested   string
+	requestedAt time.Time
+}
+
+// NewSpinTracker creates a tracker that keeps up to size events per disk and looks for open
+// files of woken disks in procDir.
+func NewSpinTracker(procDir string, size int) *SpinTracker {
+	return &SpinTracker{
+		procDir: procDir,
+		size:    size,
+		now:     time.Now,
+		disks:   make(map[string]*spinDisk),
+	}
+}
+
+// Run records the disk lists published on hub until ctx is cancelled.
+func (t *SpinTracker) Run(ctx context.Context, hub *pubsub.PubSub) {
+	ch := hub.Sub(constants.TopicDiskListUpdate)
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Info("Spin tracker stopping due to context cancellation")
+			hub.Unsub(ch)
+			return
+		case msg := <-ch:
+			if disks, ok := msg.([]dto.DiskInfo); ok {
+				t.Update(disks)
+			}
+		}
+	}
+}
+
+// Expect notes that the API asked for device to change to state, so the change is attributed to
+// the API rather than to disk access or the spin-down timer.
+func (t *SpinTracker) Expect(device, state string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if d, ok := t.disks[device]; ok {
+		d.requested = state
+		d.requestedAt = t.now()
+	}
+}
+
+// Update records the spin state of each disk and an event for every disk whose state changed
+// since the previous update. Disks in an unknown state are left alone.
+func (t *SpinTracker) Update(disks []dto.DiskInfo) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	for _, disk := range disks {
+		if disk.Device == "" || (disk.SpinState != "active" && disk.SpinState != "standby") {
+			continue
+		}
+		d, ok := t.disks[disk.Device]
+		if !ok {
+			t.disks[disk.Device] = &spinDisk{
+				name:       disk.Name,
+				state:      disk.SpinState,
+				readBytes:  disk.ReadBytes,
+				writeBytes: disk.WriteBytes,
+				since:      now,
+			}
+			continue
+		}
+
+		if disk.SpinState != d.state {
+			event := dto.DiskSpinEvent{
+				Time: now,
+				From: d.state,
+				To:   disk.SpinState,
+				Cause: dto.DiskSpinCause{
+					ReadBytes:  counterDelta(d.readBytes, disk.ReadBytes),
+					WriteBytes: counterDelta(d.writeBytes, disk.WriteBytes),
+				},
+			}
+			t.attribute(d, disk, &event)
+			d.events = append(d.events, event)
+			if len(d.events) > t.size {
+				d.events = append([]dto.DiskSpinEvent(nil), d.events[len(d.events)-t.size:]...)
+				d.since = d.events[0].Time
+			}
+		}
+
+		d.name = disk.Name
+		d.state = disk.SpinState
+		d.readBytes = disk.ReadBytes
+		d.writeBytes = disk.WriteBytes
+	}
+}
+
+// attribute fills in the source of a spin state change and, for wake-ups that were not
+// requested, the processes and shares with files open on the disk.
+func (t *SpinTracker) attribute(d *spinDisk, disk dto.DiskInfo, event *dto.DiskSpinEvent) {
+	cause := &event.Cause
+	if d.requested == event.To && event.Time.Sub(d.requestedAt) <= spinRequestWindow {
+		cause.Source = "api"
+		d.requested = ""
+		return
+	}
+	if event.To == "standby" {
+		cause.Source = "idle"
+		return
+	}
+
+	if disk.MountPoint != "" {
+		cause.Processes, cause.Shares = t.openFiles(disk.MountPoint)
+	}
+	if cause.ReadBytes > 0 || cause.WriteBytes > 0 || len(cause.Processes) > 0 {
+		cause.Source = "io"
+	} else {
+		cause.Source = "unknown"
+	}
+}
+
+// openFiles lists the processes with a file open below mount and the shares, the top level
+// directories of the disk, those files are in.
+func (t *SpinTracker) openFiles(mount string) ([]dto.DiskSpinProcess, []string) {
+	entries, err := os.ReadDir(t.procDir)
+	if err != nil {
+		logger.Debug("Spin tracker: Failed to read %s: %v", t.procDir, err)
+		return nil, nil
+	}
+
+	prefix := strings.TrimSuffix(mount, "/") + "/"
+	var procs []dto.DiskSpinProcess
+	shares := make(map[string]bool)
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil || len(procs) >= maxSpinProcesses {
+			continue
+		}
+		fdDir := filepath.Join(t.procDir, entry.Name(), "fd")
+		fds, err := os.ReadDir(fdDir)
+		if err != nil {
+			continue
+		}
+		found := ""
+		for _, fd := range fds {
+			target, err := os.Readlink(filepath.Join(fdDir, fd.Name()))
+			if err != nil || !strings.HasPrefix(target, prefix) {
+				continue
+			}
+			if found == "" {
+				found = target
+			}
+			if share, _, _ := strings.Cut(strings.TrimPrefix(target, prefix), "/"); share != "" {
+				shares[share] = true
+			}
+		}
+		if found == "" {
+			continue
+		}
+		comm, _ := os.ReadFile(filepath.Join(t.procDir, entry.Name(), "comm"))
+		procs = append(procs, dto.DiskSpinProcess{PID: pid, Name: strings.TrimSpace(string(comm)), Path: found})
+	}
+
+	names := make([]string, 0, len(shares))
+	for share := range shares {
+		names = append(names, share)
+	}
+	sort.Strings(names)
+	return procs, names
+}
+
+// History returns the spin state history of a disk with its events, oldest first.
+func (t *SpinTracker) History(device string) (dto.DiskSpinHistory, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	d, ok := t.disks[device]
+	if !ok {
+		return dto.DiskSpinHistory{}, false
+	}
+	h := t.summarize(device, d)
+	h.Events = append([]dto.DiskSpinEvent{}, d.events...)
+	return h, true
+}
+
+// Summary returns the spin state history of all tracked disks without their events, sorted by
+// disk name.
+func (t *SpinTracker) Summary() []dto.DiskSpinHistory {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	list := make([]dto.DiskSpinHistory, 0, len(t.disks))
+	for device, d := range t.disks {
+		list = append(list, t.summarize(device, d))
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// summarize counts the spin state changes of the last 24 hours, or since the disk is tracked,
+// and the time the disk spent in standby.
+func (t *SpinTracker) summarize(device string, d *spinDisk) dto.DiskSpinHistory {
+	now := t.now()
+	h := dto.DiskSpinHistory{Device: device, Name: d.name, SpinState: d.state, TrackedSince: d.since}
+	if n := len(d.events); n > 0 {
+		last := d.events[n-1].Time
+		h.LastChange = &last
+	}
+
+	start := now.Add(-24 * time.Hour)
+	if d.since.After(start) {
+		start = d.since
+	}
+	// The state at the start of the window is the one the first event in it changed from
+	first := sort.Search(len(d.events), func(i int) bool { return !d.events[i].Time.Before(start) })
+	state, at := d.state, start
+	if first < len(d.events) {
+		state = d.events[first].From
+	}
+	for _, event := range d.events[first:] {
+		if state == "standby" {
+			h.StandbySeconds24h += int64(event.Time.Sub(at).Seconds())
+		}
+		switch event.To {
+		case "active":
+			h.WakeUps24h++
+		case "standby":
+			h.SpinDowns24h++
+		}
+		state, at = event.To, event.Time
+	}
+	if state == "standby" {
+		h.StandbySeconds24h += int64(now.Sub(at).Seconds())
+	}
+	if window := now.Sub(start).Seconds(); window > 0 {
+		h.StandbyPercent24h = float64(h.StandbySeconds24h) / window * 100
+	}
+	return h
+}
+
+// counterDelta returns how much a byte counter grew, or 0 when it was reset.
+func counterDelta(prev, cur uint64) uint64 {
+	if cur < prev {
+		return 0
+	}
+	return cur - prev
+}
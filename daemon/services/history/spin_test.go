@@ -0,0 +1,116 @@
+package history
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/dto"
+)
+
+// fakeProc creates a /proc like directory where each pid has a comm file and fd symlinks to the
+// given paths.
+func fakeProc(t *testing.T, procs map[string][]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for pid, paths := range procs {
+		fdDir := filepath.Join(dir, pid, "fd")
+		if err := os.MkdirAll(fdDir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		name := "shfs"
+		if pid != "100" {
+			name = "plex"
+		}
+		if err := os.WriteFile(filepath.Join(dir, pid, "comm"), []byte(name+"\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		for i, path := range paths {
+			if err := os.Symlink(path, filepath.Join(fdDir, string(rune('3'+i)))); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	return dir
+}
+
+func TestSpinTracker(t *testing.T) {
+	proc := fakeProc(t, map[string][]string{
+		"100": {"/mnt/disk1/media/movie.mkv", "/mnt/disk1/backups/db.tar"},
+		"200": {"/mnt/disk2/media/show.mkv", "/dev/null"},
+	})
+	tracker := NewSpinTracker(proc, 3)
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	tracker.now = func() time.Time { return now }
+
+	disk1 := dto.DiskInfo{Device: "sdb", Name: "disk1", MountPoint: "/mnt/disk1", SpinState: "active", ReadBytes: 1000}
+	parity := dto.DiskInfo{Device: "sdc", Name: "parity", SpinState: "standby"}
+	update := func(after time.Duration, disks ...dto.DiskInfo) {
+		now = now.Add(after)
+		tracker.Update(disks)
+	}
+
+	update(0, disk1, parity)
+	disk1.SpinState = "standby"
+	update(time.Hour, disk1, parity)
+
+	// Woken by reads of the media share
+	disk1.SpinState, disk1.ReadBytes = "active", 5000
+	update(2*time.Hour, disk1, parity)
+
+	// Spun down and up again through the API
+	tracker.Expect("sdb", "standby")
+	disk1.SpinState = "standby"
+	update(time.Minute, disk1, parity)
+	tracker.Expect("sdc", "active")
+	parity.SpinState = "active"
+	update(time.Minute, disk1, parity)
+
+	h, ok := tracker.History("sdb")
+	if !ok {
+		t.Fatal("disk1 not tracked")
+	}
+	if len(h.Events) != 3 {
+		t.Fatalf("got %d events, want 3: %+v", len(h.Events), h.Events)
+	}
+	if h.Events[0].Cause.Source != "idle" || h.Events[2].Cause.Source != "api" {
+		t.Errorf("unexpected spin-down sources: %q, %q", h.Events[0].Cause.Source, h.Events[2].Cause.Source)
+	}
+	wake := h.Events[1].Cause
+	if wake.Source != "io" || wake.ReadBytes != 4000 || len(wake.Processes) != 1 || wake.Processes[0].Name != "shfs" || wake.Processes[0].PID != 100 {
+		t.Errorf("unexpected wake cause: %+v", wake)
+	}
+	if len(wake.Shares) != 2 || wake.Shares[0] != "backups" || wake.Shares[1] != "media" {
+		t.Errorf("shares = %v, want [backups media]", wake.Shares)
+	}
+
+	// Tracked for 3h02m: standby from 1h to 3h and for the last minute
+	if h.WakeUps24h != 1 || h.SpinDowns24h != 2 || h.StandbySeconds24h != 2*3600+60 || h.SpinState != "standby" {
+		t.Errorf("unexpected summary: %+v", h)
+	}
+
+	// The API request for parity is matched too
+	p, _ := tracker.History("sdc")
+	if len(p.Events) != 1 || p.Events[0].Cause.Source != "api" {
+		t.Errorf("unexpected parity events: %+v", p.Events)
+	}
+
+	// Only the newest events are kept
+	for _, state := range []string{"active", "standby", "active", "standby"} {
+		disk1.SpinState = state
+		update(time.Minute, disk1, parity)
+	}
+	h, _ = tracker.History("sdb")
+	if len(h.Events) != 3 || !h.TrackedSince.Equal(h.Events[0].Time) {
+		t.Errorf("got %d events tracked since %v", len(h.Events), h.TrackedSince)
+	}
+
+	summary := tracker.Summary()
+	if len(summary) != 2 || summary[0].Name != "disk1" || summary[0].Events != nil {
+		t.Errorf("unexpected summary: %+v", summary)
+	}
+	if _, ok := tracker.History("sdz"); ok {
+		t.Error("untracked disk has a history")
+	}
+}
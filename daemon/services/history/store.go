@@ -0,0 +1,346 @@
+// Package history keeps a downsampled time series of collector metrics in memory, persists it to
+// disk, and answers range queries for the /history API.
+package history
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/services/metrics"
+)
+
+// maxPoints caps the number of points returned per series; larger ranges get a coarser step.
+const maxPoints = 1000
+
+// ErrUnknownMetric is returned when a query names a metric that has never been recorded.
+var ErrUnknownMetric = errors.New("unknown metric")
+
+// Tier is one retention level: samples are aggregated into buckets of Resolution and the
+// buckets are kept for Retention.
+type Tier struct {
+	Resolution time.Duration
+	Retention  time.Duration
+}
+
+// capacity returns the number of buckets the tier keeps per series.
+func (t Tier) capacity() int {
+	return int(t.Retention / t.Resolution)
+}
+
+func (t Tier) String() string {
+	return fmt.Sprintf("%s:%s", t.Resolution, t.Retention)
+}
+
+// DefaultTiers keeps 5s resolution for an hour, 1m for a day and 15m for 30 days.
+var DefaultTiers = []Tier{
+	{Resolution: 5 * time.Second, Retention: time.Hour},
+	{Resolution: time.Minute, Retention: 24 * time.Hour},
+	{Resolution: 15 * time.Minute, Retention: 30 * 24 * time.Hour},
+}
+
+// ParseTiers parses a retention spec such as "5s:1h,1m:24h,15m:720h". Tiers must be listed from
+// the finest to the coarsest resolution. An empty spec returns DefaultTiers.
+func ParseTiers(spec string) ([]Tier, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return DefaultTiers, nil
+	}
+
+	var tiers []Tier
+	for _, part := range strings.Split(spec, ",") {
+		resolution, retention, ok := strings.Cut(strings.TrimSpace(part), ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid history tier %q: expected resolution:retention", part)
+		}
+		res, err := time.ParseDuration(resolution)
+		if err != nil {
+			return nil, fmt.Errorf("invalid history tier resolution %q: %w", resolution, err)
+		}
+		ret, err := time.ParseDuration(retention)
+		if err != nil {
+			return nil, fmt.Errorf("invalid history tier retention %q: %w", retention, err)
+		}
+		if res < time.Second || ret < res {
+			return nil, fmt.Errorf("invalid history tier %q: resolution must be at least 1s and not exceed retention", part)
+		}
+		if n := len(tiers); n > 0 && (res <= tiers[n-1].Resolution || ret <= tiers[n-1].Retention) {
+			return nil, fmt.Errorf("invalid history tier %q: tiers must increase in resolution and retention", part)
+		}
+		tiers = append(tiers, Tier{Resolution: res, Retention: ret})
+	}
+	return tiers, nil
+}
+
+// bucket aggregates the samples of one resolution interval starting at Start (unix seconds).
+type bucket struct {
+	Start int64
+	Sum   float64
+	Count uint32
+	Min   float64
+	Max   float64
+}
+
+// ring is a fixed-capacity circular buffer of buckets in chronological order. It grows on demand
+// up to its capacity so short-lived series stay small.
+type ring struct {
+	Buckets []bucket
+	Head    int // index of the oldest bucket once the ring is full
+}
+
+func (r *ring) last() *bucket {
+	if len(r.Buckets) == 0 {
+		return nil
+	}
+	i := r.Head - 1
+	if i < 0 {
+		i = len(r.Buckets) - 1
+	}
+	return &r.Buckets[i]
+}
+
+func (r *ring) add(start int64, value float64, capacity int) {
+	if b := r.last(); b != nil && b.Start == start {
+		b.Sum += value
+		b.Count++
+		b.Min = math.Min(b.Min, value)
+		b.Max = math.Max(b.Max, value)
+		return
+	}
+
+	// Ignore samples older than the newest bucket (e.g. clock adjustments)
+	if b := r.last(); b != nil && start < b.Start {
+		return
+	}
+
+	nb := bucket{Start: start, Sum: value, Count: 1, Min: value, Max: value}
+	if len(r.Buckets) < capacity {
+		r.Buckets = append(r.Buckets, nb)
+		r.Head = 0
+		return
+	}
+	r.Buckets[r.Head] = nb
+	r.Head = (r.Head + 1) % len(r.Buckets)
+}
+
+// each calls fn for every bucket from oldest to newest.
+func (r *ring) each(fn func(b bucket)) {
+	n := len(r.Buckets)
+	for i := 0; i < n; i++ {
+		fn(r.Buckets[(r.Head+i)%n])
+	}
+}
+
+// trim drops the oldest buckets so at most capacity remain, returning a compacted ring.
+func (r *ring) trim(capacity int) ring {
+	ordered := make([]bucket, 0, len(r.Buckets))
+	r.each(func(b bucket) { ordered = append(ordered, b) })
+	if len(ordered) > capacity {
+		ordered = ordered[len(ordered)-capacity:]
+	}
+	return ring{Buckets: ordered}
+}
+
+type seriesKey struct {
+	Metric string
+	Entity string
+}
+
+// series holds one ring per tier.
+type series struct {
+	tiers []ring
+}
+
+// Store is a thread-safe multi-tier time series store.
+type Store struct {
+	mu     sync.RWMutex
+	tiers  []Tier
+	series map[seriesKey]*series
+	now    func() time.Time
+}
+
+// NewStore creates an empty store with the given retention tiers.
+func NewStore(tiers []Tier) *Store {
+	if len(tiers) == 0 {
+		tiers = DefaultTiers
+	}
+	return &Store{
+		tiers:  tiers,
+		series: make(map[seriesKey]*series),
+		now:    time.Now,
+	}
+}
+
+// Tiers returns the retention tiers of the store.
+func (s *Store) Tiers() []Tier {
+	return append([]Tier(nil), s.tiers...)
+}
+
+// Add records samples taken at time t in every tier.
+func (s *Store) Add(samples []metrics.Sample, t time.Time) {
+	if len(samples) == 0 {
+		return
+	}
+	unix := t.Unix()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, sample := range samples {
+		if math.IsNaN(sample.Value) || math.IsInf(sample.Value, 0) {
+			continue
+		}
+		key := seriesKey{Metric: sample.Metric, Entity: sample.Entity}
+		ser, ok := s.series[key]
+		if !ok {
+			ser = &series{tiers: make([]ring, len(s.tiers))}
+			s.series[key] = ser
+		}
+		for i, tier := range s.tiers {
+			res := int64(tier.Resolution / time.Second)
+			ser.tiers[i].add(unix-unix%res, sample.Value, tier.capacity())
+		}
+	}
+}
+
+// Metrics returns every recorded metric with its sorted entity names.
+func (s *Store) Metrics() map[string][]string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make(map[string][]string)
+	for key := range s.series {
+		entities := result[key.Metric]
+		if key.Entity != "" {
+			entities = append(entities, key.Entity)
+		}
+		result[key.Metric] = entities
+	}
+	for metric := range result {
+		sort.Strings(result[metric])
+	}
+	return result
+}
+
+// Query selects a metric over a time range. An empty Entity returns every entity of the metric.
+type Query struct {
+	Metric string
+	Entity string
+	From   time.Time
+	To     time.Time
+	Step   time.Duration // zero selects the resolution of the tier covering From
+}
+
+// Point is an aggregated value over one step.
+type Point struct {
+	Timestamp time.Time
+	Value     float64 // average
+	Min       float64
+	Max       float64
+}
+
+// Series is the result for one entity.
+type Series struct {
+	Entity string
+	Points []Point
+}
+
+// Result is the answer to a Query.
+type Result struct {
+	Metric     string
+	From       time.Time
+	To         time.Time
+	Step       time.Duration
+	Resolution time.Duration
+	Series     []Series
+}
+
+// Query returns the metric's points between From and To aggregated to Step. The finest tier
+// whose retention still covers From is used; the step is never finer than that tier's resolution.
+func (s *Store) Query(q Query) (*Result, error) {
+	if !q.To.After(q.From) {
+		return nil, fmt.Errorf("invalid range: to must be after from")
+	}
+
+	tierIndex := len(s.tiers) - 1
+	age := s.now().Sub(q.From)
+	for i, tier := range s.tiers {
+		if age <= tier.Retention {
+			tierIndex = i
+			break
+		}
+	}
+	tier := s.tiers[tierIndex]
+
+	step := q.Step
+	if step < tier.Resolution {
+		step = tier.Resolution
+	}
+	if span := q.To.Sub(q.From); span/step > maxPoints {
+		step = span / maxPoints
+	}
+	// Align the step to a whole number of tier buckets
+	if rem := step % tier.Resolution; rem != 0 {
+		step += tier.Resolution - rem
+	}
+	stepSec := int64(step / time.Second)
+	from, to := q.From.Unix(), q.To.Unix()
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := &Result{Metric: q.Metric, From: q.From, To: q.To, Step: step, Resolution: tier.Resolution}
+	found := false
+	for key, ser := range s.series {
+		if key.Metric != q.Metric {
+			continue
+		}
+		found = true
+		if q.Entity != "" && key.Entity != q.Entity {
+			continue
+		}
+
+		var points []Point
+		var current *bucket
+		flush := func() {
+			if current != nil {
+				points = append(points, Point{
+					Timestamp: time.Unix(current.Start, 0).UTC(),
+					Value:     current.Sum / float64(current.Count),
+					Min:       current.Min,
+					Max:       current.Max,
+				})
+			}
+		}
+		ser.tiers[tierIndex].each(func(b bucket) {
+			if b.Start < from-from%stepSec || b.Start > to {
+				return
+			}
+			window := b.Start - b.Start%stepSec
+			if current == nil || current.Start != window {
+				flush()
+				current = &bucket{Start: window, Min: b.Min, Max: b.Max}
+			}
+			current.Sum += b.Sum
+			current.Count += b.Count
+			current.Min = math.Min(current.Min, b.Min)
+			current.Max = math.Max(current.Max, b.Max)
+		})
+		flush()
+
+		if points == nil {
+			points = []Point{}
+		}
+		result.Series = append(result.Series, Series{Entity: key.Entity, Points: points})
+	}
+
+	if !found {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownMetric, q.Metric)
+	}
+	sort.Slice(result.Series, func(i, j int) bool { return result.Series[i].Entity < result.Series[j].Entity })
+	return result, nil
+}
@@ -0,0 +1,225 @@
+package history
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/services/metrics"
+)
+
+func TestParseTiers(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		want    []Tier
+		wantErr bool
+	}{
+		{name: "empty uses defaults", spec: "", want: DefaultTiers},
+		{name: "day unit unsupported", spec: "10s:1h,5m:7d", wantErr: true},
+		{name: "two tiers", spec: "10s:1h,5m:168h", want: []Tier{{10 * time.Second, time.Hour}, {5 * time.Minute, 168 * time.Hour}}},
+		{name: "missing retention", spec: "10s", wantErr: true},
+		{name: "bad duration", spec: "ten:1h", wantErr: true},
+		{name: "retention shorter than resolution", spec: "1h:1m", wantErr: true},
+		{name: "sub-second resolution", spec: "500ms:1h", wantErr: true},
+		{name: "not increasing", spec: "1m:24h,10s:1h", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseTiers(tt.spec)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParseTiers(%q) expected error, got %v", tt.spec, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseTiers(%q) error: %v", tt.spec, err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("ParseTiers(%q) = %v, want %v", tt.spec, got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("tier %d = %v, want %v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+// newTestStore returns a store whose clock is fixed at now.
+func newTestStore(tiers []Tier, now time.Time) *Store {
+	s := NewStore(tiers)
+	s.now = func() time.Time { return now }
+	return s
+}
+
+func TestStoreQueryDownsamples(t *testing.T) {
+	base := time.Unix(1_700_000_000, 0)
+	tiers := []Tier{{Resolution: 10 * time.Second, Retention: time.Hour}, {Resolution: time.Minute, Retention: 24 * time.Hour}}
+	s := newTestStore(tiers, base.Add(10*time.Minute))
+
+	// One sample per 10s for 10 minutes: values 0..59
+	for i := 0; i < 60; i++ {
+		s.Add([]metrics.Sample{{Metric: "cpu", Value: float64(i)}}, base.Add(time.Duration(i)*10*time.Second))
+	}
+
+	res, err := s.Query(Query{Metric: "cpu", From: base, To: base.Add(10 * time.Minute), Step: time.Minute})
+	if err != nil {
+		t.Fatalf("Query error: %v", err)
+	}
+	if res.Resolution != 10*time.Second {
+		t.Errorf("Resolution = %v, want 10s (finest tier)", res.Resolution)
+	}
+	if len(res.Series) != 1 {
+		t.Fatalf("got %d series, want 1", len(res.Series))
+	}
+
+	points := res.Series[0].Points
+	if len(points) < 10 || len(points) > 11 {
+		t.Fatalf("got %d points, want 10 or 11 one-minute steps", len(points))
+	}
+	for _, p := range points {
+		if p.Min > p.Value || p.Value > p.Max {
+			t.Errorf("point %v: avg %.1f outside [%.1f, %.1f]", p.Timestamp, p.Value, p.Min, p.Max)
+		}
+		if p.Timestamp.Unix()%60 != 0 {
+			t.Errorf("point timestamp %v not aligned to the step", p.Timestamp)
+		}
+	}
+	if points[0].Min != 0 || points[len(points)-1].Max != 59 {
+		t.Errorf("range = [%.0f, %.0f], want [0, 59]", points[0].Min, points[len(points)-1].Max)
+	}
+}
+
+func TestStoreQueryUsesCoarserTierForOldRanges(t *testing.T) {
+	base := time.Unix(1_700_000_000, 0)
+	tiers := []Tier{{Resolution: 10 * time.Second, Retention: time.Hour}, {Resolution: time.Minute, Retention: 24 * time.Hour}}
+	s := newTestStore(tiers, base.Add(3*time.Hour))
+
+	s.Add([]metrics.Sample{{Metric: "cpu", Value: 10}}, base)
+	s.Add([]metrics.Sample{{Metric: "cpu", Value: 20}}, base.Add(30*time.Second))
+
+	res, err := s.Query(Query{Metric: "cpu", From: base.Add(-time.Minute), To: base.Add(time.Hour)})
+	if err != nil {
+		t.Fatalf("Query error: %v", err)
+	}
+	if res.Resolution != time.Minute {
+		t.Errorf("Resolution = %v, want 1m for a range older than the first tier", res.Resolution)
+	}
+	if res.Step < time.Minute {
+		t.Errorf("Step = %v, must not be finer than the tier resolution", res.Step)
+	}
+}
+
+func TestStoreQueryCapsPoints(t *testing.T) {
+	base := time.Unix(1_700_000_000, 0)
+	s := newTestStore([]Tier{{Resolution: time.Second, Retention: 24 * time.Hour}}, base)
+	s.Add([]metrics.Sample{{Metric: "cpu", Value: 1}}, base)
+
+	res, err := s.Query(Query{Metric: "cpu", From: base.Add(-24 * time.Hour), To: base})
+	if err != nil {
+		t.Fatalf("Query error: %v", err)
+	}
+	if n := (24 * time.Hour) / res.Step; n > maxPoints {
+		t.Errorf("step %v yields %d points, want at most %d", res.Step, n, maxPoints)
+	}
+	if res.Step%time.Second != 0 {
+		t.Errorf("step %v is not a multiple of the resolution", res.Step)
+	}
+}
+
+func TestStoreEntities(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	s := newTestStore(nil, now)
+	s.Add([]metrics.Sample{
+		{Metric: "disk_temp", Entity: "disk2", Value: 35},
+		{Metric: "disk_temp", Entity: "disk1", Value: 30},
+		{Metric: "cpu", Value: 5},
+	}, now)
+
+	got := s.Metrics()
+	if len(got) != 2 {
+		t.Fatalf("Metrics() = %v, want 2 metrics", got)
+	}
+	if e := got["disk_temp"]; len(e) != 2 || e[0] != "disk1" || e[1] != "disk2" {
+		t.Errorf("disk_temp entities = %v, want [disk1 disk2]", e)
+	}
+	if e := got["cpu"]; len(e) != 0 {
+		t.Errorf("cpu entities = %v, want none", e)
+	}
+
+	res, err := s.Query(Query{Metric: "disk_temp", Entity: "disk2", From: now.Add(-time.Minute), To: now.Add(time.Minute)})
+	if err != nil {
+		t.Fatalf("Query error: %v", err)
+	}
+	if len(res.Series) != 1 || res.Series[0].Entity != "disk2" || res.Series[0].Points[0].Value != 35 {
+		t.Errorf("entity filter returned %+v", res.Series)
+	}
+
+	if _, err := s.Query(Query{Metric: "missing", From: now.Add(-time.Minute), To: now}); !errors.Is(err, ErrUnknownMetric) {
+		t.Errorf("unknown metric error = %v, want ErrUnknownMetric", err)
+	}
+	if _, err := s.Query(Query{Metric: "cpu", From: now, To: now}); err == nil {
+		t.Error("expected error for empty range")
+	}
+}
+
+func TestRingWrapsAtCapacity(t *testing.T) {
+	var r ring
+	for i := int64(0); i < 10; i++ {
+		r.add(i*10, float64(i), 4)
+	}
+	// Older samples are ignored once newer buckets exist
+	r.add(0, 100, 4)
+
+	var starts []int64
+	r.each(func(b bucket) { starts = append(starts, b.Start) })
+	want := []int64{60, 70, 80, 90}
+	if len(starts) != len(want) {
+		t.Fatalf("buckets = %v, want %v", starts, want)
+	}
+	for i := range want {
+		if starts[i] != want[i] {
+			t.Fatalf("buckets = %v, want %v", starts, want)
+		}
+	}
+}
+
+func TestStoreSaveLoad(t *testing.T) {
+	base := time.Unix(1_700_000_000, 0)
+	path := filepath.Join(t.TempDir(), "nested", "history.gob.gz")
+
+	src := newTestStore(DefaultTiers, base)
+	for i := 0; i < 100; i++ {
+		src.Add([]metrics.Sample{{Metric: "cpu", Value: float64(i)}, {Metric: "disk_temp", Entity: "disk1", Value: 30}}, base.Add(time.Duration(i)*5*time.Second))
+	}
+	if err := src.Save(path); err != nil {
+		t.Fatalf("Save error: %v", err)
+	}
+
+	// Reload with the 5s tier removed and a shorter 1m retention
+	dst := newTestStore([]Tier{{Resolution: time.Minute, Retention: 5 * time.Minute}, {Resolution: 15 * time.Minute, Retention: 720 * time.Hour}}, base.Add(10*time.Minute))
+	if err := dst.Load(path); err != nil {
+		t.Fatalf("Load error: %v", err)
+	}
+	if len(dst.Metrics()) != 2 {
+		t.Fatalf("loaded metrics = %v, want 2", dst.Metrics())
+	}
+	if n := len(dst.series[seriesKey{Metric: "cpu"}].tiers[0].Buckets); n != 5 {
+		t.Errorf("1m tier has %d buckets after load, want 5 (trimmed to retention)", n)
+	}
+
+	// Data keeps accumulating after a load
+	dst.Add([]metrics.Sample{{Metric: "cpu", Value: 1}}, base.Add(time.Hour))
+	if _, err := dst.Query(Query{Metric: "cpu", From: base, To: base.Add(2 * time.Hour)}); err != nil {
+		t.Errorf("Query after load error: %v", err)
+	}
+
+	if err := NewStore(nil).Load(filepath.Join(t.TempDir(), "missing")); err != nil {
+		t.Errorf("Load of missing file should succeed, got %v", err)
+	}
+}
@@ -0,0 +1,302 @@
+// Package jobs runs batch operations on Docker containers in the background. Each batch is a
+// job whose per-container progress can be polled or followed on the docker_batch_job topic.
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/cskr/pubsub"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/constants"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/dto"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/logger"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/services/controllers"
+)
+
+// Job and item states.
+const (
+	StatusPending   = "pending"
+	StatusRunning   = "running"
+	StatusWaiting   = "waiting"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+	StatusCanceled  = "canceled"
+)
+
+const (
+	// DefaultConcurrency is how many containers a batch acts on at once unless requested otherwise.
+	DefaultConcurrency = 4
+	// MaxConcurrency bounds the requested concurrency.
+	MaxConcurrency = 16
+	// maxJobs is how many jobs are kept; the oldest finished jobs are dropped first.
+	maxJobs = 50
+	// resolveTimeout bounds listing and ordering the containers of a new job.
+	resolveTimeout = 30 * time.Second
+)
+
+// ErrJobNotFound is returned for unknown or expired job IDs.
+var ErrJobNotFound = errors.New("job not found")
+
+// ErrJobFinished is returned when canceling a job that has already finished.
+var ErrJobFinished = errors.New("job already finished")
+
+type job struct {
+	state  dto.DockerBatchJob
+	cancel context.CancelFunc
+}
+
+// Manager starts batch jobs and keeps the most recent ones.
+type Manager struct {
+	ctx    context.Context
+	docker *controllers.DockerController
+	hub    *pubsub.PubSub
+
+	mu   sync.Mutex
+	jobs map[string]*job
+	ids  []string // oldest first
+
+	// after waits for a container's autostart delay; replaced in tests
+	after func(time.Duration) <-chan time.Time
+}
+
+// NewManager creates a job manager. Running jobs are canceled when ctx is done. Progress is
+// published on hub when it is not nil.
+func NewManager(ctx context.Context, docker *controllers.DockerController, hub *pubsub.PubSub) *Manager {
+	return &Manager{
+		ctx:    ctx,
+		docker: docker,
+		hub:    hub,
+		jobs:   make(map[string]*job),
+		after:  time.After,
+	}
+}
+
+// Start resolves the containers of a batch request and runs the action on them in the
+// background, returning the new job. Errors wrapping controllers.ErrInvalidBatch mean the
+// request was rejected.
+func (m *Manager) Start(ctx context.Context, req dto.DockerBatchRequest) (dto.DockerBatchJob, error) {
+	if req.Order == "" {
+		req.Order = controllers.BatchOrderAutostart
+	}
+	if req.Concurrency == 0 {
+		req.Concurrency = DefaultConcurrency
+	}
+	if req.Concurrency < 1 || req.Concurrency > MaxConcurrency {
+		return dto.DockerBatchJob{}, fmt.Errorf("%w: concurrency must be between 1 and %d", controllers.ErrInvalidBatch, MaxConcurrency)
+	}
+
+	resolveCtx, cancel := context.WithTimeout(ctx, resolveTimeout)
+	targets, err := m.docker.BatchTargets(resolveCtx, req)
+	cancel()
+	if err != nil {
+		return dto.DockerBatchJob{}, err
+	}
+
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return dto.DockerBatchJob{}, fmt.Errorf("failed to generate job ID: %w", err)
+	}
+	j := &job{state: dto.DockerBatchJob{
+		ID:          hex.EncodeToString(buf),
+		Action:      req.Action,
+		Status:      StatusRunning,
+		Order:       req.Order,
+		Concurrency: req.Concurrency,
+		Total:       len(targets),
+		Items:       make([]dto.DockerBatchItem, len(targets)),
+		CreatedAt:   time.Now(),
+	}}
+	for i, target := range targets {
+		j.state.Items[i] = dto.DockerBatchItem{
+			ContainerID: target.ID[:min(12, len(target.ID))],
+			Name:        target.Name,
+			Status:      StatusPending,
+			WaitSeconds: int(target.Wait / time.Second),
+		}
+	}
+
+	runCtx, runCancel := context.WithCancel(m.ctx)
+	j.cancel = runCancel
+
+	m.mu.Lock()
+	m.jobs[j.state.ID] = j
+	m.ids = append(m.ids, j.state.ID)
+	m.prune()
+	snapshot := j.snapshot()
+	m.mu.Unlock()
+
+	logger.Info("Docker batch job %s: %s %d containers (order %s, concurrency %d)", snapshot.ID, req.Action, len(targets), req.Order, req.Concurrency)
+	m.publish(snapshot)
+	go m.run(runCtx, j, targets)
+	return snapshot, nil
+}
+
+// Get returns a job by ID.
+func (m *Manager) Get(id string) (dto.DockerBatchJob, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	j, ok := m.jobs[id]
+	if !ok {
+		return dto.DockerBatchJob{}, ErrJobNotFound
+	}
+	return j.snapshot(), nil
+}
+
+// List returns the kept jobs, newest first.
+func (m *Manager) List() []dto.DockerBatchJob {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	list := make([]dto.DockerBatchJob, 0, len(m.ids))
+	for i := len(m.ids) - 1; i >= 0; i-- {
+		list = append(list, m.jobs[m.ids[i]].snapshot())
+	}
+	return list
+}
+
+// Cancel stops a running job. Containers already being acted on finish their action; the
+// others are marked canceled.
+func (m *Manager) Cancel(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	j, ok := m.jobs[id]
+	if !ok {
+		return ErrJobNotFound
+	}
+	if j.state.FinishedAt != nil {
+		return ErrJobFinished
+	}
+	j.cancel()
+	return nil
+}
+
+// run acts on the targets in order with at most Concurrency actions in flight. A target with a
+// wait delay holds back the following targets until it has been started and the delay has
+// passed, the way Unraid's autostart does.
+func (m *Manager) run(ctx context.Context, j *job, targets []controllers.BatchTarget) {
+	defer j.cancel()
+
+	slots := make(chan struct{}, j.state.Concurrency)
+	var wg sync.WaitGroup
+dispatch:
+	for i, target := range targets {
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			break dispatch
+		}
+		if ctx.Err() != nil {
+			<-slots
+			break dispatch
+		}
+
+		done := make(chan bool, 1)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+			done <- m.act(ctx, j, i, target.ID)
+		}()
+
+		if target.Wait > 0 {
+			if ok := <-done; ok {
+				m.update(j, i, func(item *dto.DockerBatchItem) { item.Status = StatusWaiting })
+				select {
+				case <-m.after(target.Wait):
+				case <-ctx.Done():
+				}
+				m.update(j, i, func(item *dto.DockerBatchItem) { item.Status = StatusSucceeded })
+			}
+		}
+	}
+	wg.Wait()
+
+	m.mu.Lock()
+	now := time.Now()
+	state := &j.state
+	for i := range state.Items {
+		if state.Items[i].Status == StatusPending {
+			state.Items[i].Status = StatusCanceled
+		}
+	}
+	switch {
+	case ctx.Err() != nil && state.Succeeded+state.Failed < state.Total:
+		state.Status = StatusCanceled
+	case state.Failed > 0:
+		state.Status = StatusFailed
+	default:
+		state.Status = StatusSucceeded
+	}
+	state.FinishedAt = &now
+	snapshot := j.snapshot()
+	m.mu.Unlock()
+
+	logger.Info("Docker batch job %s %s: %d succeeded, %d failed of %d", snapshot.ID, snapshot.Status, snapshot.Succeeded, snapshot.Failed, snapshot.Total)
+	m.publish(snapshot)
+}
+
+// act runs the job's action on one container and records the outcome.
+func (m *Manager) act(ctx context.Context, j *job, i int, id string) bool {
+	m.update(j, i, func(item *dto.DockerBatchItem) {
+		now := time.Now()
+		item.Status = StatusRunning
+		item.StartedAt = &now
+	})
+
+	// A started action is not interrupted by canceling the job
+	err := m.docker.ContainerAction(context.WithoutCancel(ctx), id, j.state.Action)
+
+	m.update(j, i, func(item *dto.DockerBatchItem) {
+		now := time.Now()
+		item.FinishedAt = &now
+		if err != nil {
+			item.Status = StatusFailed
+			item.Error = err.Error()
+			j.state.Failed++
+			logger.Warning("Docker batch job %s: failed to %s %s: %v", j.state.ID, j.state.Action, item.Name, err)
+			return
+		}
+		item.Status = StatusSucceeded
+		j.state.Succeeded++
+	})
+	return err == nil
+}
+
+// update changes an item under the lock and publishes the job.
+func (m *Manager) update(j *job, i int, fn func(*dto.DockerBatchItem)) {
+	m.mu.Lock()
+	fn(&j.state.Items[i])
+	snapshot := j.snapshot()
+	m.mu.Unlock()
+	m.publish(snapshot)
+}
+
+func (m *Manager) publish(snapshot dto.DockerBatchJob) {
+	if m.hub != nil {
+		m.hub.Pub(&snapshot, constants.TopicDockerBatchJob)
+	}
+}
+
+// prune drops the oldest finished jobs beyond maxJobs. Running jobs are always kept.
+func (m *Manager) prune() {
+	for i := 0; len(m.ids) > maxJobs && i < len(m.ids); {
+		id := m.ids[i]
+		if m.jobs[id].state.FinishedAt == nil {
+			i++
+			continue
+		}
+		delete(m.jobs, id)
+		m.ids = append(m.ids[:i], m.ids[i+1:]...)
+	}
+}
+
+// snapshot copies the job state so it can be read without the lock.
+func (j *job) snapshot() dto.DockerBatchJob {
+	state := j.state
+	state.Items = append([]dto.DockerBatchItem(nil), j.state.Items...)
+	return state
+}
@@ -0,0 +1,206 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/dto"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/lib/dockerapi/dockertest"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/services/controllers"
+)
+
+// setupManager returns a manager for the fake Engine with the Compose stack added, where sonarr
+// autostarts first with a 10 second wait, followed by plex.
+func setupManager(t *testing.T) (*Manager, *dockertest.Server) {
+	t.Helper()
+	engine := dockertest.NewServer(t)
+	engine.AddComposeStack()
+	autostart := filepath.Join(t.TempDir(), "unraid-autostart")
+	if err := os.WriteFile(autostart, []byte("sonarr 10\nplex\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	docker := controllers.NewDockerControllerWithOptions(controllers.DockerOptions{Socket: engine.Socket(), TemplatesDir: t.TempDir(), AutostartFile: autostart})
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	return NewManager(ctx, docker, nil), engine
+}
+
+func waitForJob(t *testing.T, m *Manager, id string, done func(dto.DockerBatchJob) bool) dto.DockerBatchJob {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		job, err := m.Get(id)
+		if err != nil {
+			t.Fatalf("Get(%s) error = %v", id, err)
+		}
+		if done(job) {
+			return job
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for job: %+v", job)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func finished(job dto.DockerBatchJob) bool {
+	return job.FinishedAt != nil
+}
+
+// actions returns the container actions the fake Engine received, as "action name".
+func actions(engine *dockertest.Server) []string {
+	names := map[string]string{
+		dockertest.PlexID:        "plex",
+		dockertest.SonarrID:      "sonarr",
+		dockertest.PaperlessDBID: "paperless-db-1",
+	}
+	var got []string
+	for _, r := range engine.Requests() {
+		rest, ok := strings.CutPrefix(r, "POST /containers/")
+		if !ok {
+			continue
+		}
+		if id, action, ok := strings.Cut(rest, "/"); ok && names[id] != "" {
+			got = append(got, action+" "+names[id])
+		}
+	}
+	return got
+}
+
+func TestStartFollowsAutostartOrder(t *testing.T) {
+	m, engine := setupManager(t)
+	var mu sync.Mutex
+	var waits []time.Duration
+	m.after = func(d time.Duration) <-chan time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		waits = append(waits, d)
+		ch := make(chan time.Time, 1)
+		ch <- time.Now()
+		return ch
+	}
+
+	enabled := true
+	job, err := m.Start(context.Background(), dto.DockerBatchRequest{
+		Action:   "start",
+		Selector: &dto.DockerBatchSelector{Autostart: &enabled},
+	})
+	if err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	if job.Status != StatusRunning || job.Total != 2 || job.Concurrency != DefaultConcurrency || job.Order != controllers.BatchOrderAutostart {
+		t.Errorf("unexpected new job: %+v", job)
+	}
+
+	job = waitForJob(t, m, job.ID, finished)
+	if job.Status != StatusSucceeded || job.Succeeded != 2 {
+		t.Errorf("unexpected finished job: %+v", job)
+	}
+	if job.Items[0].Name != "sonarr" || job.Items[0].WaitSeconds != 10 || job.Items[1].Name != "plex" {
+		t.Errorf("unexpected items: %+v", job.Items)
+	}
+	if got := actions(engine); !reflect.DeepEqual(got, []string{"start sonarr", "start plex"}) {
+		t.Errorf("actions = %v", got)
+	}
+	if !reflect.DeepEqual(waits, []time.Duration{10 * time.Second}) {
+		t.Errorf("waits = %v", waits)
+	}
+}
+
+func TestStopReversesAutostartOrder(t *testing.T) {
+	m, engine := setupManager(t)
+	engine.Handle("POST /containers/"+dockertest.PlexID+"/stop", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = w.Write([]byte(`{"message": "cannot stop container: permission denied"}`))
+	})
+
+	job, err := m.Start(context.Background(), dto.DockerBatchRequest{
+		Action:      "stop",
+		Containers:  []string{"plex", dockertest.SonarrID[:12], "paperless-db-1", "plex"},
+		Concurrency: 1,
+	})
+	if err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	job = waitForJob(t, m, job.ID, finished)
+
+	// Containers without autostart come last when starting, so they are stopped first
+	want := []string{"stop paperless-db-1", "stop plex", "stop sonarr"}
+	if got := actions(engine); !reflect.DeepEqual(got, want) {
+		t.Errorf("actions = %v, want %v", got, want)
+	}
+	if job.Status != StatusFailed || job.Succeeded != 2 || job.Failed != 1 {
+		t.Errorf("unexpected job: %+v", job)
+	}
+	if plex := job.Items[1]; plex.Status != StatusFailed || !strings.Contains(plex.Error, "permission denied") || plex.WaitSeconds != 0 {
+		t.Errorf("unexpected plex item: %+v", plex)
+	}
+}
+
+func TestCancel(t *testing.T) {
+	m, engine := setupManager(t)
+	m.after = func(time.Duration) <-chan time.Time { return nil }
+
+	job, err := m.Start(context.Background(), dto.DockerBatchRequest{Action: "restart", Containers: []string{"plex", "sonarr"}})
+	if err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	waitForJob(t, m, job.ID, func(job dto.DockerBatchJob) bool { return job.Items[0].Status == StatusWaiting })
+
+	if err := m.Cancel(job.ID); err != nil {
+		t.Fatalf("Cancel() error = %v", err)
+	}
+	job = waitForJob(t, m, job.ID, finished)
+	if job.Status != StatusCanceled || job.Items[0].Status != StatusSucceeded || job.Items[1].Status != StatusCanceled {
+		t.Errorf("unexpected job: %+v", job)
+	}
+	if n := engine.Count("POST /containers/" + dockertest.PlexID + "/restart"); n != 0 {
+		t.Errorf("plex restarted %d times after cancel", n)
+	}
+
+	if err := m.Cancel(job.ID); !errors.Is(err, ErrJobFinished) {
+		t.Errorf("second Cancel() error = %v", err)
+	}
+	if err := m.Cancel("0000"); !errors.Is(err, ErrJobNotFound) {
+		t.Errorf("Cancel() of unknown job error = %v", err)
+	}
+	if list := m.List(); len(list) != 1 || list[0].ID != job.ID {
+		t.Errorf("List() = %+v", list)
+	}
+}
+
+func TestStartRejectsInvalidRequests(t *testing.T) {
+	m, _ := setupManager(t)
+	tests := []struct {
+		name string
+		req  dto.DockerBatchRequest
+		want string
+	}{
+		{"unknown action", dto.DockerBatchRequest{Action: "remove", Containers: []string{"plex"}}, `unknown action "remove"`},
+		{"unknown order", dto.DockerBatchRequest{Action: "stop", Containers: []string{"plex"}, Order: "random"}, `unknown order "random"`},
+		{"no containers", dto.DockerBatchRequest{Action: "stop"}, "containers or selector required"},
+		{"unknown container", dto.DockerBatchRequest{Action: "stop", Containers: []string{"plex", "radarr"}}, "unknown containers: radarr"},
+		{"nothing selected", dto.DockerBatchRequest{Action: "stop", Selector: &dto.DockerBatchSelector{State: "paused"}}, "no containers match"},
+		{"concurrency", dto.DockerBatchRequest{Action: "stop", Containers: []string{"plex"}, Concurrency: 17}, "concurrency must be between 1 and 16"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := m.Start(context.Background(), tt.req)
+			if !errors.Is(err, controllers.ErrInvalidBatch) || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Start() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+	if list := m.List(); len(list) != 0 {
+		t.Errorf("rejected requests created jobs: %+v", list)
+	}
+}
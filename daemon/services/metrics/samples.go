@@ -0,0 +1,221 @@
+// Package metrics flattens collector events into named numeric samples.
+// It is shared by the history store and the alerting engine so both use the same metric names.
+package metrics
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/dto"
+)
+
+// Sample is a single numeric value of a metric. Entity identifies the disk, container, VM,
+// pool, interface or GPU the value belongs to and is empty for system-wide metrics.
+type Sample struct {
+	Metric string
+	Entity string
+	Value  float64
+}
+
+// Metric names produced by FromEvent.
+const (
+	CPUUsagePercent        = "cpu_usage_percent"
+	CPUTempCelsius         = "cpu_temp_celsius"
+	RAMUsagePercent        = "ram_usage_percent"
+	MotherboardTempCelsius = "motherboard_temp_celsius"
+
+	ArrayStarted     = "array_started"
+	ArrayUsedPercent = "array_used_percent"
+
+	DiskTemperatureCelsius = "disk_temperature_celsius"
+	DiskUsagePercent       = "disk_usage_percent"
+	DiskSMARTErrors        = "disk_smart_errors"
+
+	DiskReallocatedSectors = "disk_reallocated_sectors"
+	DiskPendingSectors     = "disk_pending_sectors"
+	DiskPercentageUsed     = "disk_percentage_used"
+
+	ShareUsedBytes = "share_used_bytes"
+
+	ContainerRunning     = "container_running"
+	ContainerCPUPercent  = "container_cpu_percent"
+	ContainerMemoryBytes = "container_memory_bytes"
+
+	VMRunning         = "vm_running"
+	VMGuestCPUPercent = "vm_guest_cpu_percent"
+	VMMemoryUsedBytes = "vm_memory_used_bytes"
+
+	UPSOnBattery            = "ups_on_battery"
+	UPSBatteryChargePercent = "ups_battery_charge_percent"
+	UPSLoadPercent          = "ups_load_percent"
+	UPSRuntimeSeconds       = "ups_runtime_seconds"
+
+	GPUUtilizationPercent = "gpu_utilization_percent"
+	GPUTemperatureCelsius = "gpu_temperature_celsius"
+
+	NetworkRXBytes = "network_rx_bytes"
+	NetworkTXBytes = "network_tx_bytes"
+
+	ZFSPoolOnline          = "zfs_pool_online"
+	ZFSPoolCapacityPercent = "zfs_pool_capacity_percent"
+	ZFSARCHitRatioPercent  = "zfs_arc_hit_ratio_percent"
+)
+
+// Names returns every metric name produced by FromEvent.
+func Names() []string {
+	return []string{
+		CPUUsagePercent, CPUTempCelsius, RAMUsagePercent, MotherboardTempCelsius,
+		ArrayStarted, ArrayUsedPercent,
+		DiskTemperatureCelsius, DiskUsagePercent, DiskSMARTErrors,
+		DiskReallocatedSectors, DiskPendingSectors, DiskPercentageUsed,
+		ShareUsedBytes,
+		ContainerRunning, ContainerCPUPercent, ContainerMemoryBytes,
+		VMRunning, VMGuestCPUPercent, VMMemoryUsedBytes,
+		UPSOnBattery, UPSBatteryChargePercent, UPSLoadPercent, UPSRuntimeSeconds,
+		GPUUtilizationPercent, GPUTemperatureCelsius,
+		NetworkRXBytes, NetworkTXBytes,
+		ZFSPoolOnline, ZFSPoolCapacityPercent, ZFSARCHitRatioPercent,
+	}
+}
+
+// IsKnown reports whether name is a metric produced by FromEvent.
+func IsKnown(name string) bool {
+	for _, n := range Names() {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// FromEvent extracts samples from an event bus payload. Unknown payloads yield no samples.
+func FromEvent(data interface{}) []Sample {
+	switch v := data.(type) {
+	case *dto.SystemInfo:
+		return []Sample{
+			{Metric: CPUUsagePercent, Value: v.CPUUsage},
+			{Metric: CPUTempCelsius, Value: v.CPUTemp},
+			{Metric: RAMUsagePercent, Value: v.RAMUsage},
+			{Metric: MotherboardTempCelsius, Value: v.MotherboardTemp},
+		}
+	case *dto.ArrayStatus:
+		return []Sample{
+			{Metric: ArrayStarted, Value: boolValue(strings.EqualFold(v.State, "STARTED"))},
+			{Metric: ArrayUsedPercent, Value: v.UsedPercent},
+		}
+	case []dto.DiskInfo:
+		samples := make([]Sample, 0, len(v)*3)
+		for _, disk := range v {
+			// Spun-down disks report no temperature; skip them rather than record 0°C
+			if disk.SpinState != "standby" {
+				samples = append(samples, Sample{Metric: DiskTemperatureCelsius, Entity: disk.Name, Value: disk.Temperature})
+			}
+			samples = append(samples,
+				Sample{Metric: DiskUsagePercent, Entity: disk.Name, Value: disk.UsagePercent},
+				Sample{Metric: DiskSMARTErrors, Entity: disk.Name, Value: float64(disk.SMARTErrors)},
+			)
+		}
+		return samples
+	case []dto.DiskSMART:
+		samples := make([]Sample, 0, len(v)*2)
+		for _, smart := range v {
+			samples = append(samples,
+				Sample{Metric: DiskReallocatedSectors, Entity: smart.Name, Value: float64(smart.ReallocatedSectors)},
+				Sample{Metric: DiskPendingSectors, Entity: smart.Name, Value: float64(smart.PendingSectors)},
+			)
+			// NVMe wear: the percentage of the rated endurance used
+			if smart.NVMeHealth != nil {
+				samples = append(samples, Sample{Metric: DiskPercentageUsed, Entity: smart.Name, Value: float64(smart.NVMeHealth.PercentageUsed)})
+			}
+		}
+		return samples
+	case []dto.ShareInfo:
+		samples := make([]Sample, 0, len(v))
+		for _, share := range v {
+			samples = append(samples, Sample{Metric: ShareUsedBytes, Entity: share.Name, Value: float64(share.Used)})
+		}
+		return samples
+	case []*dto.ContainerInfo:
+		samples := make([]Sample, 0, len(v)*3)
+		for _, c := range v {
+			samples = append(samples,
+				Sample{Metric: ContainerRunning, Entity: c.Name, Value: boolValue(c.State == "running")},
+				Sample{Metric: ContainerCPUPercent, Entity: c.Name, Value: c.CPUPercent},
+				Sample{Metric: ContainerMemoryBytes, Entity: c.Name, Value: float64(c.MemoryUsage)},
+			)
+		}
+		return samples
+	case []*dto.VMInfo:
+		samples := make([]Sample, 0, len(v)*3)
+		for _, vm := range v {
+			samples = append(samples,
+				Sample{Metric: VMRunning, Entity: vm.Name, Value: boolValue(vm.State == "running")},
+				Sample{Metric: VMGuestCPUPercent, Entity: vm.Name, Value: vm.GuestCPUPercent},
+				Sample{Metric: VMMemoryUsedBytes, Entity: vm.Name, Value: float64(vm.MemoryUsed)},
+			)
+		}
+		return samples
+	case *dto.UPSStatus:
+		if !v.Connected {
+			return nil
+		}
+		return []Sample{
+			{Metric: UPSOnBattery, Value: boolValue(IsOnBattery(v.Status))},
+			{Metric: UPSBatteryChargePercent, Value: v.BatteryCharge},
+			{Metric: UPSLoadPercent, Value: v.LoadPercent},
+			{Metric: UPSRuntimeSeconds, Value: float64(v.RuntimeLeft)},
+		}
+	case []*dto.GPUMetrics:
+		samples := make([]Sample, 0, len(v)*2)
+		for _, gpu := range v {
+			if gpu == nil || !gpu.Available {
+				continue
+			}
+			entity := strconv.Itoa(gpu.Index)
+			samples = append(samples,
+				Sample{Metric: GPUUtilizationPercent, Entity: entity, Value: gpu.UtilizationGPU},
+				Sample{Metric: GPUTemperatureCelsius, Entity: entity, Value: gpu.Temperature},
+			)
+		}
+		return samples
+	case []dto.NetworkInfo:
+		samples := make([]Sample, 0, len(v)*2)
+		for _, iface := range v {
+			samples = append(samples,
+				Sample{Metric: NetworkRXBytes, Entity: iface.Name, Value: float64(iface.BytesReceived)},
+				Sample{Metric: NetworkTXBytes, Entity: iface.Name, Value: float64(iface.BytesSent)},
+			)
+		}
+		return samples
+	case []dto.ZFSPool:
+		samples := make([]Sample, 0, len(v)*2)
+		for _, pool := range v {
+			samples = append(samples,
+				Sample{Metric: ZFSPoolOnline, Entity: pool.Name, Value: boolValue(pool.Health == "ONLINE")},
+				Sample{Metric: ZFSPoolCapacityPercent, Entity: pool.Name, Value: pool.CapacityPct},
+			)
+		}
+		return samples
+	case dto.ZFSARCStats:
+		return []Sample{{Metric: ZFSARCHitRatioPercent, Value: v.HitRatioPct}}
+	}
+	return nil
+}
+
+// IsOnBattery reports whether a UPS status string from apcupsd ("ONBATT") or NUT ("OB DISCHRG")
+// indicates the UPS is running on battery.
+func IsOnBattery(status string) bool {
+	for _, flag := range strings.Fields(strings.ToUpper(status)) {
+		if flag == "ONBATT" || flag == "OB" {
+			return true
+		}
+	}
+	return false
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
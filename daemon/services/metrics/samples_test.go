@@ -0,0 +1,120 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/dto"
+)
+
+func TestFromEvent(t *testing.T) {
+	tests := []struct {
+		name string
+		data interface{}
+		want []Sample
+	}{
+		{
+			name: "system",
+			data: &dto.SystemInfo{CPUUsage: 12.5, CPUTemp: 45, RAMUsage: 60, MotherboardTemp: 30},
+			want: []Sample{
+				{Metric: CPUUsagePercent, Value: 12.5},
+				{Metric: CPUTempCelsius, Value: 45},
+				{Metric: RAMUsagePercent, Value: 60},
+				{Metric: MotherboardTempCelsius, Value: 30},
+			},
+		},
+		{
+			name: "standby disk has no temperature",
+			data: []dto.DiskInfo{{Name: "disk1", SpinState: "standby", Temperature: 0, UsagePercent: 50, SMARTErrors: 2}},
+			want: []Sample{
+				{Metric: DiskUsagePercent, Entity: "disk1", Value: 50},
+				{Metric: DiskSMARTErrors, Entity: "disk1", Value: 2},
+			},
+		},
+		{
+			name: "smart",
+			data: []dto.DiskSMART{
+				{Name: "disk1", ReallocatedSectors: 8, PendingSectors: 2},
+				{Name: "cache", NVMeHealth: &dto.NVMeHealth{PercentageUsed: 3}},
+			},
+			want: []Sample{
+				{Metric: DiskReallocatedSectors, Entity: "disk1", Value: 8},
+				{Metric: DiskPendingSectors, Entity: "disk1", Value: 2},
+				{Metric: DiskReallocatedSectors, Entity: "cache", Value: 0},
+				{Metric: DiskPendingSectors, Entity: "cache", Value: 0},
+				{Metric: DiskPercentageUsed, Entity: "cache", Value: 3},
+			},
+		},
+		{
+			name: "containers",
+			data: []*dto.ContainerInfo{{Name: "plex", State: "running", CPUPercent: 3, MemoryUsage: 1024}},
+			want: []Sample{
+				{Metric: ContainerRunning, Entity: "plex", Value: 1},
+				{Metric: ContainerCPUPercent, Entity: "plex", Value: 3},
+				{Metric: ContainerMemoryBytes, Entity: "plex", Value: 1024},
+			},
+		},
+		{
+			name: "disconnected ups",
+			data: &dto.UPSStatus{Connected: false, BatteryCharge: 100},
+			want: nil,
+		},
+		{
+			name: "ups on battery",
+			data: &dto.UPSStatus{Connected: true, Status: "OB DISCHRG", BatteryCharge: 80, LoadPercent: 20, RuntimeLeft: 600},
+			want: []Sample{
+				{Metric: UPSOnBattery, Value: 1},
+				{Metric: UPSBatteryChargePercent, Value: 80},
+				{Metric: UPSLoadPercent, Value: 20},
+				{Metric: UPSRuntimeSeconds, Value: 600},
+			},
+		},
+		{
+			name: "unavailable gpu skipped",
+			data: []*dto.GPUMetrics{{Index: 0, Available: false}, {Index: 1, Available: true, UtilizationGPU: 40, Temperature: 55}},
+			want: []Sample{
+				{Metric: GPUUtilizationPercent, Entity: "1", Value: 40},
+				{Metric: GPUTemperatureCelsius, Entity: "1", Value: 55},
+			},
+		},
+		{
+			name: "arc stats",
+			data: dto.ZFSARCStats{HitRatioPct: 97.5},
+			want: []Sample{{Metric: ZFSARCHitRatioPercent, Value: 97.5}},
+		},
+		{
+			name: "unknown payload",
+			data: &dto.HardwareInfo{},
+			want: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := FromEvent(tt.data)
+			if len(got) != len(tt.want) {
+				t.Fatalf("FromEvent() = %+v, want %+v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("sample %d = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestIsOnBattery(t *testing.T) {
+	tests := map[string]bool{
+		"ONLINE":     false,
+		"ONBATT":     true,
+		"OL CHRG":    false,
+		"OB DISCHRG": true,
+		"ob":         true,
+		"":           false,
+	}
+	for status, want := range tests {
+		if got := IsOnBattery(status); got != want {
+			t.Errorf("IsOnBattery(%q) = %v, want %v", status, got, want)
+		}
+	}
+}
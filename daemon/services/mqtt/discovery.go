@@ -0,0 +1,163 @@
+package mqtt
+
+import (
+	"strings"
+)
+
+// discoveryDevice groups all entities of the agent under one Home Assistant device.
+type discoveryDevice struct {
+	Identifiers  []string `json:"identifiers"`
+	Name         string   `json:"name"`
+	Manufacturer string   `json:"manufacturer"`
+	Model        string   `json:"model"`
+	SWVersion    string   `json:"sw_version,omitempty"`
+}
+
+// discoveryConfig is a Home Assistant MQTT discovery payload for a sensor or switch.
+type discoveryConfig struct {
+	Name              string          `json:"name"`
+	UniqueID          string          `json:"unique_id"`
+	ObjectID          string          `json:"object_id"`
+	StateTopic        string          `json:"state_topic"`
+	ValueTemplate     string          `json:"value_template,omitempty"`
+	CommandTopic      string          `json:"command_topic,omitempty"`
+	PayloadOn         string          `json:"payload_on,omitempty"`
+	PayloadOff        string          `json:"payload_off,omitempty"`
+	StateOn           string          `json:"state_on,omitempty"`
+	StateOff          string          `json:"state_off,omitempty"`
+	UnitOfMeasurement string          `json:"unit_of_measurement,omitempty"`
+	DeviceClass       string          `json:"device_class,omitempty"`
+	StateClass        string          `json:"state_class,omitempty"`
+	Icon              string          `json:"icon,omitempty"`
+	AvailabilityTopic string          `json:"availability_topic"`
+	Device            discoveryDevice `json:"device"`
+}
+
+// sensorSpec describes a numeric sensor read from a JSON state topic.
+type sensorSpec struct {
+	key         string
+	name        string
+	field       string
+	unit        string
+	deviceClass string
+	icon        string
+}
+
+var systemSensors = []sensorSpec{
+	{key: "cpu_usage", name: "CPU Usage", field: "cpu_usage_percent", unit: "%", icon: "mdi:cpu-64-bit"},
+	{key: "cpu_temperature", name: "CPU Temperature", field: "cpu_temp_celsius", unit: "°C", deviceClass: "temperature"},
+	{key: "ram_usage", name: "RAM Usage", field: "ram_usage_percent", unit: "%", icon: "mdi:memory"},
+	{key: "motherboard_temperature", name: "Motherboard Temperature", field: "motherboard_temp_celsius", unit: "°C", deviceClass: "temperature"},
+}
+
+var upsSensors = []sensorSpec{
+	{key: "ups_battery_charge", name: "UPS Battery Charge", field: "battery_charge_percent", unit: "%", deviceClass: "battery"},
+	{key: "ups_load", name: "UPS Load", field: "load_percent", unit: "%", icon: "mdi:gauge"},
+	{key: "ups_runtime", name: "UPS Runtime", field: "runtime_left_seconds", unit: "s", deviceClass: "duration"},
+}
+
+func (p *Publisher) announceSystem() {
+	for _, s := range systemSensors {
+		p.announceSensor(s, p.topic("system"))
+	}
+}
+
+func (p *Publisher) announceUPS() {
+	for _, s := range upsSensors {
+		p.announceSensor(s, p.topic("ups_status"))
+	}
+}
+
+func (p *Publisher) announceDisk(slug, name string) {
+	p.announceSensor(sensorSpec{
+		key:         "disk_" + slug + "_temperature",
+		name:        "Disk " + name + " Temperature",
+		field:       "temperature_celsius",
+		unit:        "°C",
+		deviceClass: "temperature",
+	}, p.topic("disk", slug))
+}
+
+func (p *Publisher) announceSensor(s sensorSpec, stateTopic string) {
+	objectID := p.nodeID + "_" + s.key
+	p.announce("sensor", objectID, discoveryConfig{
+		Name:              s.name,
+		UniqueID:          objectID,
+		ObjectID:          objectID,
+		StateTopic:        stateTopic,
+		ValueTemplate:     "{{ value_json." + s.field + " }}",
+		UnitOfMeasurement: s.unit,
+		DeviceClass:       s.deviceClass,
+		StateClass:        "measurement",
+		Icon:              s.icon,
+	})
+}
+
+// announceSwitch publishes a switch for a container or VM; ON starts it and OFF stops it.
+func (p *Publisher) announceSwitch(kind, slug, name string) {
+	objectID := p.nodeID + "_" + kind + "_" + slug
+	icon := "mdi:docker"
+	label := "Container "
+	if kind == "vm" {
+		icon = "mdi:monitor"
+		label = "VM "
+	}
+
+	p.announce("switch", objectID, discoveryConfig{
+		Name:          label + name,
+		UniqueID:      objectID,
+		ObjectID:      objectID,
+		StateTopic:    p.topic(kind, slug),
+		ValueTemplate: "{{ 'ON' if value_json.state == 'running' else 'OFF' }}",
+		CommandTopic:  p.topic(kind, slug, "set"),
+		PayloadOn:     "ON",
+		PayloadOff:    "OFF",
+		StateOn:       "ON",
+		StateOff:      "OFF",
+		Icon:          icon,
+	})
+}
+
+// retire removes the discovery configs of containers or VMs that no longer exist.
+func (p *Publisher) retire(kind string, slugs []string) {
+	if p.discoveryPrefix == "" {
+		return
+	}
+	for _, slug := range slugs {
+		topic := p.discoveryTopic("switch", p.nodeID+"_"+kind+"_"+slug)
+		p.mu.Lock()
+		delete(p.discovered, topic)
+		p.mu.Unlock()
+		p.client.Publish(topic, 1, true, "")
+	}
+}
+
+// announce publishes a retained discovery config once per connection.
+func (p *Publisher) announce(component, objectID string, cfg discoveryConfig) {
+	if p.discoveryPrefix == "" {
+		return
+	}
+
+	topic := p.discoveryTopic(component, objectID)
+	p.mu.Lock()
+	if p.discovered[topic] {
+		p.mu.Unlock()
+		return
+	}
+	p.discovered[topic] = true
+	p.mu.Unlock()
+
+	cfg.AvailabilityTopic = p.availabilityTopic()
+	cfg.Device = discoveryDevice{
+		Identifiers:  []string{"unraid_" + p.nodeID},
+		Name:         p.hostname,
+		Manufacturer: "Lime Technology",
+		Model:        "Unraid Server",
+		SWVersion:    p.ctx.Version,
+	}
+	p.publishJSON(topic, cfg, true)
+}
+
+func (p *Publisher) discoveryTopic(component, objectID string) string {
+	return strings.Join([]string{p.discoveryPrefix, component, p.nodeID, objectID, "config"}, "/")
+}
@@ -0,0 +1,407 @@
+// Package mqtt republishes collector events to an MQTT broker, announces entities through
+// Home Assistant MQTT discovery, and executes container and VM commands received over MQTT.
+package mqtt
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/constants"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/domain"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/dto"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/logger"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/services/controllers"
+)
+
+const (
+	defaultTopicPrefix   = "unraid"
+	connectRetryInterval = 10 * time.Second
+	disconnectQuiesce    = 250 // milliseconds
+	publishTimeout       = 5 * time.Second
+
+	payloadOnline  = "online"
+	payloadOffline = "offline"
+)
+
+// containerController is the subset of controllers.DockerController used for MQTT commands.
+type containerController interface {
+	Start(containerID string) error
+	Stop(containerID string) error
+	Restart(containerID string) error
+	Pause(containerID string) error
+	Unpause(containerID string) error
+}
+
+// vmController is the subset of controllers.VMController used for MQTT commands.
+type vmController interface {
+	Start(vmName string) error
+	Stop(vmName string) error
+	Restart(vmName string) error
+	Pause(vmName string) error
+	Resume(vmName string) error
+	Hibernate(vmName string) error
+	ForceStop(vmName string) error
+}
+
+type hubEvent struct {
+	topic string
+	data  interface{}
+}
+
+// Publisher bridges the event bus to an MQTT broker.
+//
+// State is published to <prefix>/<node>/<event>, e.g. unraid/tower/system, with
+// per-entity topics for disks, containers and VMs. Containers and VMs accept commands on
+// <prefix>/<node>/{container,vm}/<name>/set (ON/OFF) and .../command (start, stop, restart, ...).
+type Publisher struct {
+	ctx    *domain.Context
+	client paho.Client
+
+	nodeID          string
+	hostname        string
+	prefix          string
+	discoveryPrefix string
+
+	docker containerController
+	vm     vmController
+
+	mu         sync.Mutex
+	discovered map[string]bool   // discovery config topics published on the current connection
+	containers map[string]string // topic slug -> container name
+	vms        map[string]string // topic slug -> VM name
+}
+
+// NewPublisher creates a new MQTT publisher with the given context.
+func NewPublisher(ctx *domain.Context) *Publisher {
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "unraid"
+	}
+
+	prefix := strings.Trim(ctx.MQTTTopicPrefix, "/")
+	if prefix == "" {
+		prefix = defaultTopicPrefix
+	}
+
+	return &Publisher{
+		ctx:             ctx,
+		nodeID:          slugify(hostname),
+		hostname:        hostname,
+		prefix:          prefix,
+		discoveryPrefix: strings.Trim(ctx.MQTTDiscoveryPrefix, "/"),
+		docker:          controllers.NewDockerController(),
+		vm:              controllers.NewVMController(),
+		discovered:      make(map[string]bool),
+		containers:      make(map[string]string),
+		vms:             make(map[string]string),
+	}
+}
+
+// Start connects to the broker and republishes events until the context is cancelled.
+// Connection failures are retried in the background and do not stop the agent.
+func (p *Publisher) Start(ctx context.Context) {
+	logger.Info("Starting MQTT publisher (broker: %s, prefix: %s/%s)", p.ctx.MQTTBroker, p.prefix, p.nodeID)
+
+	events := p.subscribe(ctx)
+
+	p.client = paho.NewClient(p.clientOptions())
+	token := p.client.Connect()
+	go func() {
+		token.Wait()
+		if err := token.Error(); err != nil {
+			logger.Error("MQTT: Failed to connect to %s: %v", p.ctx.MQTTBroker, err)
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Info("MQTT publisher stopping due to context cancellation")
+			if p.client.IsConnectionOpen() {
+				p.client.Publish(p.availabilityTopic(), 1, true, payloadOffline).WaitTimeout(publishTimeout)
+			}
+			p.client.Disconnect(disconnectQuiesce)
+			return
+		case ev := <-events:
+			if !p.client.IsConnectionOpen() {
+				continue
+			}
+			p.handleEvent(ev.topic, ev.data)
+		}
+	}
+}
+
+func (p *Publisher) clientOptions() *paho.ClientOptions {
+	clientID := p.ctx.MQTTClientID
+	if clientID == "" {
+		clientID = "unraid-management-agent-" + p.nodeID
+	}
+
+	opts := paho.NewClientOptions().
+		AddBroker(p.ctx.MQTTBroker).
+		SetClientID(clientID).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		SetConnectRetryInterval(connectRetryInterval).
+		SetOrderMatters(false).
+		SetWill(p.availabilityTopic(), payloadOffline, 1, true).
+		SetOnConnectHandler(p.onConnect).
+		SetConnectionLostHandler(func(_ paho.Client, err error) {
+			logger.Warning("MQTT: Connection lost: %v", err)
+		})
+
+	if p.ctx.MQTTUsername != "" {
+		opts.SetUsername(p.ctx.MQTTUsername)
+		opts.SetPassword(p.ctx.MQTTPassword)
+	}
+
+	return opts
+}
+
+// onConnect runs on every (re)connect: it marks the agent online, re-subscribes to command
+// topics and forces discovery configs to be re-announced with the next events.
+func (p *Publisher) onConnect(client paho.Client) {
+	logger.Success("MQTT: Connected to %s", p.ctx.MQTTBroker)
+
+	p.mu.Lock()
+	p.discovered = make(map[string]bool)
+	p.mu.Unlock()
+
+	client.Publish(p.availabilityTopic(), 1, true, payloadOnline)
+
+	filters := map[string]byte{
+		p.topic("container", "+", "set"):     1,
+		p.topic("container", "+", "command"): 1,
+		p.topic("vm", "+", "set"):            1,
+		p.topic("vm", "+", "command"):        1,
+	}
+	token := client.SubscribeMultiple(filters, p.handleCommand)
+	if token.WaitTimeout(publishTimeout) && token.Error() != nil {
+		logger.Error("MQTT: Failed to subscribe to command topics: %v", token.Error())
+	}
+}
+
+// subscribe forwards every event bus topic into a single channel tagged with its topic name.
+func (p *Publisher) subscribe(ctx context.Context) <-chan hubEvent {
+	topics := constants.CollectorTopics()
+	events := make(chan hubEvent, len(topics))
+
+	for _, topic := range topics {
+		ch := p.ctx.Hub.Sub(topic)
+		go func() {
+			for {
+				select {
+				case <-ctx.Done():
+					p.ctx.Hub.Unsub(ch)
+					return
+				case msg, ok := <-ch:
+					if !ok {
+						return
+					}
+					select {
+					case events <- hubEvent{topic: topic, data: msg}:
+					case <-ctx.Done():
+					}
+				}
+			}
+		}()
+	}
+
+	return events
+}
+
+func (p *Publisher) handleEvent(topic string, data interface{}) {
+	p.publishJSON(p.topic(strings.TrimSuffix(topic, "_update")), data, false)
+
+	switch v := data.(type) {
+	case *dto.SystemInfo:
+		p.announceSystem()
+	case []dto.DiskInfo:
+		for i := range v {
+			disk := &v[i]
+			slug := slugify(disk.Name)
+			p.publishJSON(p.topic("disk", slug), disk, false)
+			p.announceDisk(slug, disk.Name)
+		}
+	case []*dto.ContainerInfo:
+		current := make(map[string]string, len(v))
+		for _, c := range v {
+			slug := slugify(c.Name)
+			current[slug] = c.Name
+			p.publishJSON(p.topic("container", slug), c, false)
+			p.announceSwitch("container", slug, c.Name)
+		}
+		p.retire("container", p.replaceEntities(&p.containers, current))
+	case []*dto.VMInfo:
+		current := make(map[string]string, len(v))
+		for _, vm := range v {
+			slug := slugify(vm.Name)
+			current[slug] = vm.Name
+			p.publishJSON(p.topic("vm", slug), vm, false)
+			p.announceSwitch("vm", slug, vm.Name)
+		}
+		p.retire("vm", p.replaceEntities(&p.vms, current))
+	case *dto.UPSStatus:
+		if v.Connected {
+			p.announceUPS()
+		}
+	}
+}
+
+// replaceEntities swaps in the current entity set and returns the slugs that disappeared.
+func (p *Publisher) replaceEntities(entities *map[string]string, current map[string]string) []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var removed []string
+	for slug := range *entities {
+		if _, ok := current[slug]; !ok {
+			removed = append(removed, slug)
+		}
+	}
+	*entities = current
+	return removed
+}
+
+func (p *Publisher) handleCommand(_ paho.Client, msg paho.Message) {
+	kind, slug, verb, ok := p.parseCommandTopic(msg.Topic())
+	if !ok {
+		return
+	}
+
+	action := strings.ToLower(strings.TrimSpace(string(msg.Payload())))
+	if verb == "set" {
+		switch action {
+		case "on":
+			action = "start"
+		case "off":
+			action = "stop"
+		default:
+			logger.Warning("MQTT: Ignoring invalid %s switch payload %q", kind, action)
+			return
+		}
+	}
+
+	if err := p.execute(kind, slug, action); err != nil {
+		logger.Error("MQTT: Command %s %s/%s failed: %v", action, kind, slug, err)
+		return
+	}
+	logger.Info("MQTT: Executed %s on %s %s", action, kind, slug)
+}
+
+// parseCommandTopic splits <prefix>/<node>/<kind>/<slug>/<verb> into its parts.
+func (p *Publisher) parseCommandTopic(topic string) (kind, slug, verb string, ok bool) {
+	rest, found := strings.CutPrefix(topic, p.topic()+"/")
+	if !found {
+		return "", "", "", false
+	}
+	parts := strings.Split(rest, "/")
+	if len(parts) != 3 || (parts[2] != "set" && parts[2] != "command") {
+		return "", "", "", false
+	}
+	return parts[0], parts[1], parts[2], true
+}
+
+// execute runs an action against a container or VM that the collectors have reported.
+// Unknown entities are rejected so the command topics cannot target arbitrary names.
+func (p *Publisher) execute(kind, slug, action string) error {
+	p.mu.Lock()
+	var name string
+	var known bool
+	switch kind {
+	case "container":
+		name, known = p.containers[slug]
+	case "vm":
+		name, known = p.vms[slug]
+	}
+	p.mu.Unlock()
+
+	if !known {
+		return fmt.Errorf("unknown %s %q", kind, slug)
+	}
+
+	if kind == "container" {
+		switch action {
+		case "start":
+			return p.docker.Start(name)
+		case "stop":
+			return p.docker.Stop(name)
+		case "restart":
+			return p.docker.Restart(name)
+		case "pause":
+			return p.docker.Pause(name)
+		case "unpause":
+			return p.docker.Unpause(name)
+		}
+	} else {
+		switch action {
+		case "start":
+			return p.vm.Start(name)
+		case "stop":
+			return p.vm.Stop(name)
+		case "restart":
+			return p.vm.Restart(name)
+		case "pause":
+			return p.vm.Pause(name)
+		case "resume":
+			return p.vm.Resume(name)
+		case "hibernate":
+			return p.vm.Hibernate(name)
+		case "force-stop":
+			return p.vm.ForceStop(name)
+		}
+	}
+
+	return fmt.Errorf("unsupported %s action %q", kind, action)
+}
+
+func (p *Publisher) publishJSON(topic string, payload interface{}, retained bool) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		logger.Error("MQTT: Failed to encode payload for %s: %v", topic, err)
+		return
+	}
+	// Retained discovery configs use QoS 1 so Home Assistant does not miss them
+	qos := byte(0)
+	if retained {
+		qos = 1
+	}
+	p.client.Publish(topic, qos, retained, data)
+}
+
+// topic builds a state or command topic below <prefix>/<node>.
+func (p *Publisher) topic(parts ...string) string {
+	return strings.Join(append([]string{p.prefix, p.nodeID}, parts...), "/")
+}
+
+func (p *Publisher) availabilityTopic() string {
+	return p.topic("availability")
+}
+
+// slugify converts a name into a lowercase identifier safe for MQTT topics and entity IDs.
+func slugify(name string) string {
+	var b strings.Builder
+	lastUnderscore := false
+	for _, r := range strings.ToLower(name) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '-':
+			b.WriteRune(r)
+			lastUnderscore = false
+		case !lastUnderscore && b.Len() > 0:
+			b.WriteByte('_')
+			lastUnderscore = true
+		}
+	}
+	slug := strings.TrimSuffix(b.String(), "_")
+	if slug == "" {
+		return "unnamed"
+	}
+	return slug
+}
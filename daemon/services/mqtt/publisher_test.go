@@ -0,0 +1,265 @@
+package mqtt
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cskr/pubsub"
+	mochi "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/hooks/auth"
+	"github.com/mochi-mqtt/server/v2/listeners"
+	"github.com/mochi-mqtt/server/v2/packets"
+
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/domain"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/dto"
+)
+
+// fakeController records container and VM actions instead of running docker or virsh.
+type fakeController struct {
+	mu      sync.Mutex
+	actions []string
+}
+
+func (f *fakeController) record(action, name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.actions = append(f.actions, action+":"+name)
+	return nil
+}
+
+func (f *fakeController) has(action string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, a := range f.actions {
+		if a == action {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *fakeController) Start(name string) error     { return f.record("start", name) }
+func (f *fakeController) Stop(name string) error      { return f.record("stop", name) }
+func (f *fakeController) Restart(name string) error   { return f.record("restart", name) }
+func (f *fakeController) Pause(name string) error     { return f.record("pause", name) }
+func (f *fakeController) Unpause(name string) error   { return f.record("unpause", name) }
+func (f *fakeController) Resume(name string) error    { return f.record("resume", name) }
+func (f *fakeController) Hibernate(name string) error { return f.record("hibernate", name) }
+func (f *fakeController) ForceStop(name string) error { return f.record("force-stop", name) }
+
+// testBroker is an embedded MQTT broker whose inline client records every published message.
+type testBroker struct {
+	server *mochi.Server
+	addr   string
+
+	mu       sync.Mutex
+	messages map[string][]byte
+}
+
+func startTestBroker(t *testing.T) *testBroker {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	_ = l.Close()
+
+	server := mochi.New(&mochi.Options{
+		InlineClient: true,
+		Logger:       slog.New(slog.NewTextHandler(io.Discard, nil)),
+	})
+	if err := server.AddHook(new(auth.AllowHook), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.AddListener(listeners.NewTCP(listeners.Config{ID: "test", Address: addr})); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.Serve(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = server.Close() })
+
+	b := &testBroker{server: server, addr: addr, messages: make(map[string][]byte)}
+	err = server.Subscribe("#", 1, func(_ *mochi.Client, _ packets.Subscription, pk packets.Packet) {
+		b.mu.Lock()
+		b.messages[pk.TopicName] = append([]byte(nil), pk.Payload...)
+		b.mu.Unlock()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// waitFor polls until a message has been published to the topic and returns its payload.
+func (b *testBroker) waitFor(t *testing.T, topic string, want func([]byte) bool) []byte {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		b.mu.Lock()
+		payload, ok := b.messages[topic]
+		b.mu.Unlock()
+		if ok && (want == nil || want(payload)) {
+			return payload
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for message on %s", topic)
+	return nil
+}
+
+func equals(s string) func([]byte) bool {
+	return func(b []byte) bool { return string(b) == s }
+}
+
+func TestPublisherWithEmbeddedBroker(t *testing.T) {
+	broker := startTestBroker(t)
+
+	appCtx := &domain.Context{
+		Hub: pubsub.New(16),
+		Config: domain.Config{
+			Version:             "test",
+			MQTTBroker:          "tcp://" + broker.addr,
+			MQTTTopicPrefix:     "unraid",
+			MQTTDiscoveryPrefix: "homeassistant",
+		},
+	}
+	fake := &fakeController{}
+	p := NewPublisher(appCtx)
+	p.nodeID = "tower"
+	p.hostname = "Tower"
+	p.docker = fake
+	p.vm = fake
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		p.Start(ctx)
+		close(done)
+	}()
+
+	broker.waitFor(t, "unraid/tower/availability", equals("online"))
+
+	appCtx.Hub.Pub(&dto.SystemInfo{Hostname: "Tower", CPUUsage: 42}, "system_update")
+	appCtx.Hub.Pub([]*dto.ContainerInfo{{ID: "abc", Name: "plex", State: "running"}}, "container_list_update")
+	appCtx.Hub.Pub([]*dto.VMInfo{{ID: "1", Name: "Windows 11", State: "shut off"}}, "vm_list_update")
+
+	t.Run("republishes events", func(t *testing.T) {
+		payload := broker.waitFor(t, "unraid/tower/system", nil)
+		var info dto.SystemInfo
+		if err := json.Unmarshal(payload, &info); err != nil {
+			t.Fatalf("invalid system payload: %v", err)
+		}
+		if info.CPUUsage != 42 {
+			t.Errorf("cpu_usage_percent = %v, want 42", info.CPUUsage)
+		}
+		broker.waitFor(t, "unraid/tower/container/plex", nil)
+	})
+
+	t.Run("announces discovery configs", func(t *testing.T) {
+		payload := broker.waitFor(t, "homeassistant/sensor/tower/tower_cpu_usage/config", nil)
+		var cfg discoveryConfig
+		if err := json.Unmarshal(payload, &cfg); err != nil {
+			t.Fatalf("invalid discovery payload: %v", err)
+		}
+		if cfg.StateTopic != "unraid/tower/system" || cfg.AvailabilityTopic != "unraid/tower/availability" {
+			t.Errorf("unexpected sensor config: %+v", cfg)
+		}
+
+		payload = broker.waitFor(t, "homeassistant/switch/tower/tower_vm_windows_11/config", nil)
+		if err := json.Unmarshal(payload, &cfg); err != nil {
+			t.Fatalf("invalid discovery payload: %v", err)
+		}
+		if cfg.CommandTopic != "unraid/tower/vm/windows_11/set" {
+			t.Errorf("command_topic = %q", cfg.CommandTopic)
+		}
+	})
+
+	t.Run("executes commands", func(t *testing.T) {
+		if err := broker.server.Publish("unraid/tower/container/plex/set", []byte("OFF"), false, 1); err != nil {
+			t.Fatal(err)
+		}
+		if err := broker.server.Publish("unraid/tower/vm/windows_11/command", []byte("hibernate"), false, 1); err != nil {
+			t.Fatal(err)
+		}
+		if err := broker.server.Publish("unraid/tower/container/unknown/command", []byte("start"), false, 1); err != nil {
+			t.Fatal(err)
+		}
+
+		deadline := time.Now().Add(5 * time.Second)
+		for !(fake.has("stop:plex") && fake.has("hibernate:Windows 11")) {
+			if time.Now().After(deadline) {
+				t.Fatalf("commands not executed, got %v", fake.actions)
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+		if fake.has("start:unknown") {
+			t.Error("command for an unknown container was executed")
+		}
+	})
+
+	t.Run("retires removed containers", func(t *testing.T) {
+		appCtx.Hub.Pub([]*dto.ContainerInfo{}, "container_list_update")
+		broker.waitFor(t, "homeassistant/switch/tower/tower_container_plex/config", equals(""))
+	})
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("publisher did not stop after cancellation")
+	}
+	broker.waitFor(t, "unraid/tower/availability", equals("offline"))
+}
+
+func TestSlugify(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"plex", "plex"},
+		{"Windows 11", "windows_11"},
+		{"binhex-qbittorrentvpn", "binhex-qbittorrentvpn"},
+		{"  Home/Assistant++ ", "home_assistant"},
+		{"+#", "unnamed"},
+	}
+
+	for _, tt := range tests {
+		if got := slugify(tt.in); got != tt.want {
+			t.Errorf("slugify(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseCommandTopic(t *testing.T) {
+	p := &Publisher{prefix: "unraid", nodeID: "tower"}
+
+	tests := []struct {
+		topic string
+		kind  string
+		slug  string
+		verb  string
+		ok    bool
+	}{
+		{"unraid/tower/container/plex/set", "container", "plex", "set", true},
+		{"unraid/tower/vm/windows_11/command", "vm", "windows_11", "command", true},
+		{"unraid/tower/container/plex", "", "", "", false},
+		{"unraid/other/container/plex/set", "", "", "", false},
+		{"unraid/tower/container/plex/delete", "", "", "", false},
+	}
+
+	for _, tt := range tests {
+		kind, slug, verb, ok := p.parseCommandTopic(tt.topic)
+		if kind != tt.kind || slug != tt.slug || verb != tt.verb || ok != tt.ok {
+			t.Errorf("parseCommandTopic(%q) = %q, %q, %q, %v", tt.topic, kind, slug, verb, ok)
+		}
+	}
+}
@@ -14,6 +14,7 @@ import (
 	"github.com/ruaan-deysel/unraid-management-agent/daemon/logger"
 	"github.com/ruaan-deysel/unraid-management-agent/daemon/services/api"
 	"github.com/ruaan-deysel/unraid-management-agent/daemon/services/collectors"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/services/mqtt"
 )
 
 // Orchestrator coordinates the lifecycle of all collectors, API server, and handles graceful shutdown.
@@ -46,6 +47,16 @@ func (o *Orchestrator) Run() error {
 	apiServer.StartSubscriptions()
 	logger.Success("API server subscriptions ready")
 
+	// Start the optional MQTT publisher before collectors so no events are missed
+	if o.ctx.MQTTBroker != "" {
+		mqttPublisher := mqtt.NewPublisher(o.ctx)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			mqttPublisher.Start(ctx)
+		}()
+	}
+
 	// Small delay to ensure subscriptions are fully set up
 	time.Sleep(100 * time.Millisecond)
 
@@ -0,0 +1,118 @@
+// Package selftest runs SMART self-tests of the array disks on a schedule, one disk after the
+// other, without waking disks in standby or starting tests during a parity check. Spin-down is
+// suspended for a disk while it runs a test.
+package selftest
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/dto"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/lib"
+)
+
+// Reasons a due test has not started, reported in the disk status.
+const (
+	WaitingDisabled    = "disabled"
+	WaitingParityCheck = "parity_check"
+	WaitingWindow      = "window"
+	WaitingConcurrency = "concurrency"
+	WaitingStandby     = "standby"
+	WaitingInterrupted = "interrupted"
+)
+
+// DefaultSchedule returns the schedule with the defaults applied to omitted fields of an
+// update request: a monthly extended test of one disk at a time.
+func DefaultSchedule() dto.SelfTestSchedule {
+	return dto.SelfTestSchedule{Type: "extended", IntervalDays: 30, MaxConcurrent: 1}
+}
+
+// ValidateSchedule normalizes and checks a schedule.
+func ValidateSchedule(schedule *dto.SelfTestSchedule) error {
+	kind, err := lib.NormalizeSelfTestType(schedule.Type)
+	if err != nil {
+		return err
+	}
+	schedule.Type = kind
+
+	if schedule.IntervalDays < 1 {
+		return fmt.Errorf("interval_days must be at least 1, got %d", schedule.IntervalDays)
+	}
+	if schedule.MaxConcurrent < 1 {
+		return fmt.Errorf("max_concurrent must be at least 1, got %d", schedule.MaxConcurrent)
+	}
+	if schedule.WindowStartHour < 0 || schedule.WindowStartHour > 23 || schedule.WindowEndHour < 0 || schedule.WindowEndHour > 23 {
+		return fmt.Errorf("window hours must be between 0 and 23")
+	}
+	for i, name := range schedule.Disks {
+		schedule.Disks[i] = strings.TrimSpace(name)
+		if schedule.Disks[i] == "" {
+			return fmt.Errorf("disk names cannot be empty")
+		}
+	}
+	return nil
+}
+
+// inWindow reports whether tests may start at the given time. The window may wrap around
+// midnight, such as 22 to 6.
+func inWindow(schedule dto.SelfTestSchedule, now time.Time) bool {
+	start, end, hour := schedule.WindowStartHour, schedule.WindowEndHour, now.Hour()
+	switch {
+	case start == end:
+		return true
+	case start < end:
+		return hour >= start && hour < end
+	default:
+		return hour >= start || hour < end
+	}
+}
+
+// record is what is known about the self-tests of one disk. It is persisted so the interval
+// holds across restarts.
+type record struct {
+	LastStarted   time.Time `json:"last_started"`
+	LastCompleted time.Time `json:"last_completed"` // last test of the scheduled type that ran to the end
+	LastType      string    `json:"last_type,omitempty"`
+	LastResult    string    `json:"last_result,omitempty"`
+	LastPassed    *bool     `json:"last_passed,omitempty"`
+	Interruptions int       `json:"interruptions,omitempty"` // tests interrupted in a row
+	// SpindownDelay is the spin-down delay to restore once the running test ends; spin-down is
+	// suspended until then. It is persisted so the delay is restored after a restart.
+	SpindownDelay *int `json:"spindown_delay,omitempty"`
+
+	running  bool
+	progress int
+}
+
+// stateFile is the content of the schedule file.
+type stateFile struct {
+	Schedule dto.SelfTestSchedule `json:"schedule"`
+	Disks    map[string]*record   `json:"disks"` // keyed by disk ID, which follows a disk to another slot
+}
+
+// loadState reads the schedule file. A missing file yields the default, disabled schedule.
+func loadState(path string) (*stateFile, error) {
+	state := &stateFile{Schedule: DefaultSchedule(), Disks: make(map[string]*record)}
+	if path == "" {
+		return state, nil
+	}
+
+	if _, err := lib.ReadJSONFile(path, state); err != nil {
+		return nil, fmt.Errorf("failed to load self-test schedule: %w", err)
+	}
+	if err := ValidateSchedule(&state.Schedule); err != nil {
+		return nil, fmt.Errorf("invalid self-test schedule: %w", err)
+	}
+	if state.Disks == nil {
+		state.Disks = make(map[string]*record)
+	}
+	return state, nil
+}
+
+func saveState(path string, state *stateFile) error {
+	if err := lib.WriteJSONFile(path, state); err != nil {
+		return fmt.Errorf("failed to save self-test schedule: %w", err)
+	}
+	return nil
+}
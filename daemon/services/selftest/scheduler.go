@@ -0,0 +1,459 @@
+package selftest
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cskr/pubsub"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/constants"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/dto"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/logger"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/services/controllers"
+)
+
+// retryDelay is how long a disk waits for another try after its test was interrupted, aborted
+// or could not be started.
+const retryDelay = 6 * time.Hour
+
+// startGrace is how long after a test started the SMART readings may still predate it.
+const startGrace = time.Minute
+
+// maxInterruptions is how many tests in a row a disk may lose to a spin-down before the schedule
+// stops trying it. Testing the disk through the API or saving the schedule tries it again.
+const maxInterruptions = 3
+
+// arrayDiskRoles are the roles of the disks tested when the schedule names none.
+var arrayDiskRoles = map[string]bool{"parity": true, "parity2": true, "data": true}
+
+// Scheduler starts the self-tests of the schedule as disks become due and follows every test
+// it knows of, scheduled or started through the API, until it shows up in the self-test log.
+type Scheduler struct {
+	path     string
+	start    func(device, kind string) error
+	spindown func(disk dto.DiskInfo, delay int) error
+	now      func() time.Time
+
+	mu          sync.Mutex
+	state       *stateFile
+	disks       []dto.DiskInfo
+	smart       map[string]dto.DiskSMART // by device
+	parityCheck bool
+}
+
+// diskPlan is where a scheduled disk stands at one evaluation.
+type diskPlan struct {
+	disk    dto.DiskInfo
+	rec     *record
+	due     time.Time
+	waiting string
+	start   bool
+}
+
+// NewScheduler loads the schedule stored at path. An empty path keeps it in memory only.
+func NewScheduler(path string, smart *controllers.SMARTController, array *controllers.ArrayController) (*Scheduler, error) {
+	state, err := loadState(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Scheduler{
+		path:     path,
+		start:    smart.StartSelfTest,
+		spindown: array.SetSpindownDelay,
+		now:      time.Now,
+		state:    state,
+		smart:    make(map[string]dto.DiskSMART),
+	}, nil
+}
+
+// Run follows the disk, SMART and array updates until ctx is cancelled. Tests are started and
+// checked on each SMART update, which comes with every disk collection.
+func (s *Scheduler) Run(ctx context.Context, hub *pubsub.PubSub) {
+	ch := hub.Sub(constants.TopicDiskListUpdate, constants.TopicDiskSMARTUpdate, constants.TopicArrayStatusUpdate)
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Info("Self-test scheduler stopping due to context cancellation")
+			hub.Unsub(ch)
+			return
+		case msg := <-ch:
+			switch v := msg.(type) {
+			case []dto.DiskInfo:
+				s.UpdateDisks(v)
+			case []dto.DiskSMART:
+				s.UpdateSMART(v)
+			case *dto.ArrayStatus:
+				s.UpdateArray(v)
+			}
+		}
+	}
+}
+
+// UpdateDisks records the disks with their roles and spin states.
+func (s *Scheduler) UpdateDisks(disks []dto.DiskInfo) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.disks = disks
+}
+
+// UpdateArray records whether a parity check is running; no tests start while one is.
+func (s *Scheduler) UpdateArray(array *dto.ArrayStatus) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.parityCheck = array.ParityCheckRunning
+}
+
+// UpdateSMART records the SMART data of the disks, follows the running tests and starts the
+// tests that are due.
+func (s *Scheduler) UpdateSMART(list []dto.DiskSMART) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.smart = make(map[string]dto.DiskSMART, len(list))
+	for _, smart := range list {
+		s.smart[smart.Device] = smart
+	}
+
+	now := s.now()
+	changed := s.track(now)
+	kind := s.state.Schedule.Type
+	for _, p := range s.plan(now) {
+		if !p.start {
+			continue
+		}
+		rec := s.record(p.disk)
+		rec.LastStarted, rec.LastType, rec.LastPassed, rec.progress = now, kind, nil, 0
+		s.holdSpindown(p.disk, rec)
+		if err := s.start(p.disk.Device, kind); err != nil {
+			rec.LastResult = "failed to start: " + err.Error()
+			logger.Warning("Self-test: Failed to start scheduled %s self-test on %s: %v", kind, p.disk.Name, err)
+			s.restoreSpindown(p.disk, rec)
+		} else {
+			rec.running, rec.LastResult = true, ""
+			logger.Info("Self-test: Started scheduled %s self-test on %s", kind, p.disk.Name)
+		}
+		changed = true
+	}
+	if changed {
+		s.save()
+	}
+}
+
+// track follows the running tests, restores the spin-down delay of disks whose test ended and
+// fills in the last test of disks that have no record yet from their self-test log. It reports
+// whether a record changed.
+func (s *Scheduler) track(now time.Time) bool {
+	changed := false
+	for _, disk := range s.disks {
+		rec := s.state.Disks[diskKey(disk)]
+		smart, ok := s.smart[disk.Device]
+		// Only tests are tracked across restarts, not whether they run, so a delay held for a
+		// test that is still running stays held until the test is adopted below
+		if rec != nil && !rec.running && rec.SpindownDelay != nil && (!ok || smart.Standby || smart.SelfTestRunning == nil) {
+			changed = s.restoreSpindown(disk, rec) || changed
+		}
+		if !ok {
+			continue
+		}
+
+		switch {
+		case rec != nil && rec.running:
+			if now.Sub(rec.LastStarted) < startGrace {
+				continue
+			}
+			if smart.Standby {
+				// Drives abort self-tests when they spin down, such as when spun down by hand
+				rec.running, rec.LastResult = false, "interrupted: disk spun down"
+				rec.Interruptions++
+				logger.Warning("Self-test: %s self-test on %s interrupted by a spin-down (%d in a row)", rec.LastType, disk.Name, rec.Interruptions)
+				s.restoreSpindown(disk, rec)
+				changed = true
+				continue
+			}
+			if smart.SelfTestRunning != nil {
+				rec.progress = smart.SelfTestRunning.ProgressPercent
+				continue
+			}
+			s.finish(disk, rec, smart, now)
+			s.restoreSpindown(disk, rec)
+			changed = true
+
+		case smart.Standby || !s.scheduled(disk):
+			// Nothing to learn from the stale readings of disks in standby
+
+		case smart.SelfTestRunning != nil:
+			// Started outside the agent, such as from the webGUI, or before the agent restarted
+			rec = s.record(disk)
+			rec.running, rec.progress = true, smart.SelfTestRunning.ProgressPercent
+			rec.LastStarted, rec.LastType, rec.LastResult, rec.LastPassed = now, smart.SelfTestRunning.Type, "", nil
+			s.holdSpindown(disk, rec)
+			changed = true
+
+		case rec == nil || rec.LastCompleted.IsZero():
+			changed = s.fromLog(disk, smart, now) || changed
+		}
+	}
+	return changed
+}
+
+// finish records the result of a test that is no longer running, taken from the newest entry
+// of the self-test log.
+func (s *Scheduler) finish(disk dto.DiskInfo, rec *record, smart dto.DiskSMART, now time.Time) {
+	rec.running = false
+	if len(smart.SelfTests) == 0 {
+		rec.LastResult, rec.LastPassed = "unknown", nil
+		return
+	}
+	entry := smart.SelfTests[0]
+	passed := entry.Passed
+	rec.LastType, rec.LastResult, rec.LastPassed = entry.Type, entry.Status, &passed
+	if completed(entry) {
+		rec.Interruptions = 0
+		if entry.Type == s.state.Schedule.Type {
+			rec.LastCompleted = now
+		}
+	}
+	if passed {
+		logger.Info("Self-test: %s self-test on %s completed: %s", entry.Type, disk.Name, entry.Status)
+	} else {
+		logger.Warning("Self-test: %s self-test on %s did not pass: %s", entry.Type, disk.Name, entry.Status)
+	}
+}
+
+// fromLog takes the last completed test of the scheduled type from the self-test log, so disks
+// tested before the schedule was set up are not tested again right away. The log counts power-on
+// hours, so time the server was off moves the test closer to now.
+func (s *Scheduler) fromLog(disk dto.DiskInfo, smart dto.DiskSMART, now time.Time) bool {
+	for _, entry := range smart.SelfTests {
+		if entry.Type != s.state.Schedule.Type || !completed(entry) || entry.LifetimeHours > smart.PowerOnHours {
+			continue
+		}
+		passed := entry.Passed
+		rec := s.record(disk)
+		rec.LastCompleted = now.Add(-time.Duration(smart.PowerOnHours-entry.LifetimeHours) * time.Hour)
+		rec.LastType, rec.LastResult, rec.LastPassed = entry.Type, entry.Status, &passed
+		return true
+	}
+	return false
+}
+
+// completed reports whether a self-test log entry is a test that ran to the end, whatever
+// its result.
+func completed(entry dto.SMARTSelfTest) bool {
+	status := strings.ToLower(entry.Status)
+	return !strings.Contains(status, "abort") && !strings.Contains(status, "interrupt")
+}
+
+// plan orders the scheduled disks by when they are due and decides which due disks start a
+// test now. The others get the reason they wait. Callers hold s.mu.
+func (s *Scheduler) plan(now time.Time) []diskPlan {
+	schedule := s.state.Schedule
+	interval := time.Duration(schedule.IntervalDays) * 24 * time.Hour
+
+	var plans []diskPlan
+	running := 0
+	for _, disk := range s.disks {
+		if !s.scheduled(disk) {
+			continue
+		}
+		p := diskPlan{disk: disk, rec: s.state.Disks[diskKey(disk)]}
+		if p.rec != nil {
+			if p.rec.running {
+				running++
+			}
+			p.due = p.rec.LastCompleted.Add(interval)
+			if retry := p.rec.LastStarted.Add(retryDelay); p.rec.LastStarted.After(p.rec.LastCompleted) && retry.After(p.due) {
+				p.due = retry
+			}
+		}
+		plans = append(plans, p)
+	}
+	sort.SliceStable(plans, func(i, j int) bool { return plans[i].due.Before(plans[j].due) })
+
+	for i := range plans {
+		p := &plans[i]
+		if (p.rec != nil && p.rec.running) || p.due.After(now) {
+			continue
+		}
+		switch {
+		case !schedule.Enabled:
+			p.waiting = WaitingDisabled
+		case p.rec != nil && p.rec.Interruptions >= maxInterruptions:
+			p.waiting = WaitingInterrupted
+		case s.parityCheck:
+			p.waiting = WaitingParityCheck
+		case !inWindow(schedule, now):
+			p.waiting = WaitingWindow
+		case running >= schedule.MaxConcurrent:
+			p.waiting = WaitingConcurrency
+		case p.disk.SpinState == "standby" && !schedule.WakeDisks:
+			p.waiting = WaitingStandby
+		default:
+			p.start = true
+			running++
+		}
+	}
+	return plans
+}
+
+// holdSpindown suspends spin-down of a disk for the test it starts, since drives abort self-tests
+// when they spin down. The delay is kept in the record to be restored when the test ends. Disks
+// that never spin down are left alone. Callers hold s.mu.
+func (s *Scheduler) holdSpindown(disk dto.DiskInfo, rec *record) {
+	if rec.SpindownDelay != nil || disk.SpindownDelay == 0 {
+		return
+	}
+	if err := s.spindown(disk, 0); err != nil {
+		logger.Warning("Self-test: Failed to suspend spin-down of %s, the test may be interrupted: %v", disk.Name, err)
+		return
+	}
+	delay := disk.SpindownDelay
+	rec.SpindownDelay = &delay
+}
+
+// restoreSpindown restores the spin-down delay of a disk after its test. A failure is retried
+// on the next SMART update. It reports whether the record changed. Callers hold s.mu.
+func (s *Scheduler) restoreSpindown(disk dto.DiskInfo, rec *record) bool {
+	if rec.SpindownDelay == nil {
+		return false
+	}
+	if err := s.spindown(disk, *rec.SpindownDelay); err != nil {
+		logger.Warning("Self-test: Failed to restore the spin-down delay of %s: %v", disk.Name, err)
+		return false
+	}
+	rec.SpindownDelay = nil
+	return true
+}
+
+// scheduled reports whether the schedule covers a disk.
+func (s *Scheduler) scheduled(disk dto.DiskInfo) bool {
+	if disk.Device == "" {
+		return false
+	}
+	if len(s.state.Schedule.Disks) == 0 {
+		return arrayDiskRoles[disk.Role]
+	}
+	for _, name := range s.state.Schedule.Disks {
+		if name == disk.Name {
+			return true
+		}
+	}
+	return false
+}
+
+// record returns the record of a disk, creating it if needed. Callers hold s.mu.
+func (s *Scheduler) record(disk dto.DiskInfo) *record {
+	key := diskKey(disk)
+	rec, ok := s.state.Disks[key]
+	if !ok {
+		rec = &record{}
+		s.state.Disks[key] = rec
+	}
+	return rec
+}
+
+// diskKey identifies a disk by its ID, or by name for disks without one.
+func diskKey(disk dto.DiskInfo) string {
+	if disk.ID != "" {
+		return disk.ID
+	}
+	return disk.Name
+}
+
+// save persists the schedule and records. Callers hold s.mu.
+func (s *Scheduler) save() {
+	if err := saveState(s.path, s.state); err != nil {
+		logger.Warning("Self-test: Failed to save schedule: %v", err)
+	}
+}
+
+// Started records a test started through the API, so it is followed like a scheduled one.
+func (s *Scheduler) Started(disk dto.DiskInfo, kind string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rec := s.record(disk)
+	rec.running, rec.progress, rec.Interruptions = true, 0, 0
+	rec.LastStarted, rec.LastType, rec.LastResult, rec.LastPassed = s.now(), kind, "", nil
+	s.holdSpindown(disk, rec)
+	s.save()
+}
+
+// Aborted records a test aborted through the API. A scheduled disk is tried again later.
+func (s *Scheduler) Aborted(disk dto.DiskInfo) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rec, ok := s.state.Disks[diskKey(disk)]
+	if !ok || !rec.running {
+		return
+	}
+	rec.running, rec.LastResult, rec.LastPassed = false, "aborted", nil
+	s.restoreSpindown(disk, rec)
+	s.save()
+}
+
+// Schedule returns the current schedule.
+func (s *Scheduler) Schedule() dto.SelfTestSchedule {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state.Schedule
+}
+
+// SetSchedule validates and stores a new schedule, which applies from the next SMART update.
+func (s *Scheduler) SetSchedule(schedule dto.SelfTestSchedule) (dto.SelfTestSchedule, error) {
+	if err := ValidateSchedule(&schedule); err != nil {
+		return dto.SelfTestSchedule{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Disks given up on after repeated interruptions are tried again
+	for _, rec := range s.state.Disks {
+		rec.Interruptions = 0
+	}
+	previous := s.state.Schedule
+	s.state.Schedule = schedule
+	if err := saveState(s.path, s.state); err != nil {
+		s.state.Schedule = previous
+		return dto.SelfTestSchedule{}, err
+	}
+	logger.Info("Self-test: Schedule updated (enabled=%v, type=%s, every %d days)", schedule.Enabled, schedule.Type, schedule.IntervalDays)
+	return schedule, nil
+}
+
+// Status returns the schedule with the scheduled disks in the order they are due.
+func (s *Scheduler) Status() dto.SelfTestScheduleStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	status := dto.SelfTestScheduleStatus{
+		Schedule:           s.state.Schedule,
+		ParityCheckRunning: s.parityCheck,
+		Disks:              []dto.SelfTestDiskStatus{},
+		Timestamp:          now,
+	}
+	for _, p := range s.plan(now) {
+		disk := dto.SelfTestDiskStatus{Name: p.disk.Name, Device: p.disk.Device, NextDue: p.due, Waiting: p.waiting}
+		if disk.NextDue.Before(now) {
+			disk.NextDue = now
+		}
+		if rec := p.rec; rec != nil {
+			disk.Running, disk.LastType, disk.LastResult, disk.LastPassed = rec.running, rec.LastType, rec.LastResult, rec.LastPassed
+			disk.Interruptions = rec.Interruptions
+			if rec.running {
+				disk.ProgressPercent = rec.progress
+			}
+			if !rec.LastStarted.IsZero() {
+				started := rec.LastStarted
+				disk.LastStarted = &started
+			}
+		}
+		status.Disks = append(status.Disks, disk)
+	}
+	return status
+}
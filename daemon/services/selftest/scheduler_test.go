@@ -0,0 +1,290 @@
+package selftest
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/dto"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/services/controllers"
+)
+
+func diskStatus(t *testing.T, s *Scheduler, name string) dto.SelfTestDiskStatus {
+	t.Helper()
+	for _, disk := range s.Status().Disks {
+		if disk.Name == name {
+			return disk
+		}
+	}
+	t.Fatalf("%s is not scheduled", name)
+	return dto.SelfTestDiskStatus{}
+}
+
+// newTestScheduler creates a scheduler that starts no tests and logs the spin-down delays it
+// sets as "disk delay".
+func newTestScheduler(t *testing.T, path string) (*Scheduler, *[]string) {
+	t.Helper()
+	s, err := NewScheduler(path, controllers.NewSMARTController(), controllers.NewArrayController(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var delays []string
+	s.start = func(device, kind string) error { return nil }
+	s.spindown = func(disk dto.DiskInfo, delay int) error {
+		delays = append(delays, fmt.Sprintf("%s %d", disk.Name, delay))
+		return nil
+	}
+	return s, &delays
+}
+
+func TestScheduler(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "selftest.json")
+	s, delays := newTestScheduler(t, path)
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+	var started []string
+	s.start = func(device, kind string) error {
+		started = append(started, device+" "+kind)
+		if device == "sdd" {
+			return errors.New("device does not support Self-Test functions")
+		}
+		return nil
+	}
+
+	schedule := DefaultSchedule()
+	schedule.Enabled, schedule.Type = true, "long"
+	schedule, err := s.SetSchedule(schedule)
+	if err != nil || schedule.Type != "extended" {
+		t.Fatalf("SetSchedule() = %+v, %v", schedule, err)
+	}
+
+	disks := []dto.DiskInfo{
+		{ID: "PARITY_1", Device: "sdb", Name: "parity", Role: "parity", SpinState: "active", Index: 0, SpindownDelay: -1},
+		{ID: "DISK_1", Device: "sdc", Name: "disk1", Role: "data", SpinState: "active", Index: 1, SpindownDelay: 30},
+		{ID: "DISK_2", Device: "sdd", Name: "disk2", Role: "data", SpinState: "standby", Index: 2},
+		{ID: "CACHE_1", Device: "nvme0n1", Name: "cache", Role: "cache", SpinState: "active"},
+	}
+	// Parity had an extended test 100 power-on hours ago
+	parity := dto.DiskSMART{Device: "sdb", PowerOnHours: 1000, SelfTests: []dto.SMARTSelfTest{
+		{Type: "short", Status: "Completed without error", Passed: true, LifetimeHours: 990},
+		{Type: "extended", Status: "Completed without error", Passed: true, LifetimeHours: 900},
+	}}
+	disk1 := dto.DiskSMART{Device: "sdc", SelfTests: []dto.SMARTSelfTest{}}
+	disk2 := dto.DiskSMART{Device: "sdd", Standby: true}
+	update := func(after time.Duration) {
+		now = now.Add(after)
+		s.UpdateSMART([]dto.DiskSMART{parity, disk1, disk2})
+	}
+
+	s.UpdateDisks(disks)
+	s.UpdateArray(&dto.ArrayStatus{ParityCheckRunning: true})
+	update(0)
+	if len(started) != 0 || diskStatus(t, s, "disk1").Waiting != WaitingParityCheck {
+		t.Fatalf("started %v during a parity check", started)
+	}
+	if st := diskStatus(t, s, "parity"); st.Waiting != "" || !st.NextDue.Equal(now.Add(30*24*time.Hour-100*time.Hour)) {
+		t.Errorf("parity is due %v, waiting %q", st.NextDue, st.Waiting)
+	}
+	if len(s.Status().Disks) != 3 {
+		t.Errorf("cache disk is scheduled: %+v", s.Status().Disks)
+	}
+
+	// One disk at a time; disk2 would wait for disk1 even if it were spinning
+	s.UpdateArray(&dto.ArrayStatus{})
+	update(time.Minute)
+	if len(started) != 1 || started[0] != "sdc extended" || diskStatus(t, s, "disk2").Waiting != WaitingConcurrency {
+		t.Fatalf("started %v, disk2 waiting %q", started, diskStatus(t, s, "disk2").Waiting)
+	}
+
+	disk1.SelfTestRunning = &dto.SMARTSelfTestProgress{ProgressPercent: 40}
+	update(2 * time.Minute)
+	if st := diskStatus(t, s, "disk1"); !st.Running || st.ProgressPercent != 40 || st.LastType != "extended" {
+		t.Errorf("unexpected running test: %+v", st)
+	}
+
+	// Done: the result is taken from the log and disk2 no longer waits for a slot, but for its
+	// disk to spin up
+	disk1.SelfTestRunning = nil
+	disk1.SelfTests = []dto.SMARTSelfTest{{Type: "extended", Status: "Completed without error", Passed: true}}
+	update(20 * time.Hour)
+	st := diskStatus(t, s, "disk1")
+	if st.Running || st.LastPassed == nil || !*st.LastPassed || !st.NextDue.Equal(now.Add(30*24*time.Hour)) {
+		t.Errorf("unexpected finished test: %+v", st)
+	}
+	if w := diskStatus(t, s, "disk2").Waiting; w != WaitingStandby || len(started) != 1 {
+		t.Errorf("disk2 waiting %q, started %v", w, started)
+	}
+
+	// A failed start is retried later
+	disks[2].SpinState = "active"
+	disk2.Standby = false
+	s.UpdateDisks(disks)
+	update(time.Minute)
+	if st := diskStatus(t, s, "disk2"); len(started) != 2 || st.Running || !st.NextDue.Equal(now.Add(retryDelay)) {
+		t.Errorf("started %v, disk2 %+v", started, st)
+	}
+
+	// Tests started through the API are followed too; a spin-down interrupts them
+	s.Started(disks[0], "short")
+	parity.Standby = true
+	update(2 * time.Minute)
+	if st := diskStatus(t, s, "parity"); st.Running || st.LastResult != "interrupted: disk spun down" || st.Interruptions != 1 {
+		t.Errorf("unexpected interrupted test: %+v", st)
+	}
+
+	// Spin-down is suspended while a test runs and restored after it; disk2 never spins down
+	if want := []string{"disk1 0", "disk1 30", "parity 0", "parity -1"}; !reflect.DeepEqual(*delays, want) {
+		t.Errorf("spin-down delays set %v, want %v", *delays, want)
+	}
+
+	// The schedule and the test records survive a restart
+	reloaded, _ := newTestScheduler(t, path)
+	reloaded.now = s.now
+	reloaded.UpdateDisks(disks)
+	if reloaded.Schedule().Type != "extended" || !reloaded.Schedule().Enabled {
+		t.Errorf("unexpected reloaded schedule: %+v", reloaded.Schedule())
+	}
+	if st := diskStatus(t, reloaded, "disk1"); st.LastResult != "Completed without error" || !st.NextDue.After(now.Add(29*24*time.Hour)) {
+		t.Errorf("unexpected reloaded disk1: %+v", st)
+	}
+}
+
+func TestSchedulerHoldsSpindownOfRunningTests(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "selftest.json")
+	s, delays := newTestScheduler(t, path)
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+	schedule := DefaultSchedule()
+	schedule.Enabled, schedule.MaxConcurrent = true, 2
+	if _, err := s.SetSchedule(schedule); err != nil {
+		t.Fatal(err)
+	}
+	disks := []dto.DiskInfo{
+		{ID: "DISK_1", Device: "sdc", Name: "disk1", Role: "data", SpinState: "active", Index: 1, SpindownDelay: 15},
+		{ID: "DISK_2", Device: "sdd", Name: "disk2", Role: "data", SpinState: "active", Index: 2, SpindownDelay: 30},
+	}
+	running := &dto.SMARTSelfTestProgress{Type: "short", ProgressPercent: 10}
+	s.UpdateDisks(disks)
+	s.UpdateSMART([]dto.DiskSMART{{Device: "sdc"}, {Device: "sdd", SelfTestRunning: running}})
+	// disk2's test was started from the webGUI
+	if want := []string{"disk2 0", "disk1 0"}; !reflect.DeepEqual(*delays, want) {
+		t.Fatalf("spin-down delays set %v, want %v", *delays, want)
+	}
+
+	// The agent restarts while both tests run; the disks now report the suspended delay
+	disks[0].SpindownDelay, disks[1].SpindownDelay = 0, 0
+	reloaded, reloadedDelays := newTestScheduler(t, path)
+	now = now.Add(5 * time.Minute)
+	reloaded.now = s.now
+	reloaded.UpdateDisks(disks)
+	reloaded.UpdateSMART([]dto.DiskSMART{{Device: "sdc", SelfTestRunning: running}, {Device: "sdd", SelfTestRunning: running}})
+	if len(*reloadedDelays) != 0 || !diskStatus(t, reloaded, "disk1").Running {
+		t.Fatalf("spin-down delays set %v while the tests run", *reloadedDelays)
+	}
+
+	now = now.Add(time.Hour)
+	done := []dto.SMARTSelfTest{{Type: "short", Status: "Completed without error", Passed: true}}
+	reloaded.UpdateSMART([]dto.DiskSMART{{Device: "sdc", SelfTests: done}, {Device: "sdd", SelfTests: done}})
+	if want := []string{"disk1 15", "disk2 30"}; !reflect.DeepEqual(*reloadedDelays, want) {
+		t.Errorf("spin-down delays set %v after the tests, want %v", *reloadedDelays, want)
+	}
+}
+
+func TestSchedulerGivesUpAfterInterruptions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "selftest.json")
+	s, delays := newTestScheduler(t, path)
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+	starts := 0
+	s.start = func(device, kind string) error {
+		starts++
+		return nil
+	}
+	schedule := DefaultSchedule()
+	schedule.Enabled = true
+	if _, err := s.SetSchedule(schedule); err != nil {
+		t.Fatal(err)
+	}
+	s.UpdateDisks([]dto.DiskInfo{{ID: "DISK_1", Device: "sdc", Name: "disk1", Role: "data", SpinState: "active", Index: 1, SpindownDelay: 15}})
+
+	// Each test is cut short by a spin-down, and retried later until the limit is reached
+	for i := 1; i <= maxInterruptions; i++ {
+		s.UpdateSMART([]dto.DiskSMART{{Device: "sdc"}})
+		if starts != i {
+			t.Fatalf("attempt %d: %d tests started", i, starts)
+		}
+		now = now.Add(2 * time.Minute)
+		s.UpdateSMART([]dto.DiskSMART{{Device: "sdc", Standby: true}})
+		now = now.Add(retryDelay)
+	}
+	s.UpdateSMART([]dto.DiskSMART{{Device: "sdc"}})
+	st := diskStatus(t, s, "disk1")
+	if starts != maxInterruptions || st.Waiting != WaitingInterrupted || st.Interruptions != maxInterruptions {
+		t.Errorf("%d tests started, disk1 %+v", starts, st)
+	}
+	if len(*delays) != 2*maxInterruptions || (*delays)[len(*delays)-1] != "disk1 15" {
+		t.Errorf("spin-down delays set %v", *delays)
+	}
+
+	// The count survives a restart, and saving the schedule tries the disk again
+	reloaded, _ := newTestScheduler(t, path)
+	reloaded.now = s.now
+	reloaded.UpdateDisks(s.disks)
+	if st := diskStatus(t, reloaded, "disk1"); st.Waiting != WaitingInterrupted {
+		t.Errorf("reloaded disk1 %+v", st)
+	}
+	if _, err := s.SetSchedule(schedule); err != nil {
+		t.Fatal(err)
+	}
+	s.UpdateSMART([]dto.DiskSMART{{Device: "sdc"}})
+	if starts != maxInterruptions+1 {
+		t.Errorf("%d tests started after saving the schedule", starts)
+	}
+}
+
+func TestValidateSchedule(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(*dto.SelfTestSchedule)
+		wantErr bool
+	}{
+		{"defaults", func(*dto.SelfTestSchedule) {}, false},
+		{"named disks", func(s *dto.SelfTestSchedule) { s.Disks = []string{" disk1 ", "parity"} }, false},
+		{"unknown type", func(s *dto.SelfTestSchedule) { s.Type = "offline" }, true},
+		{"zero interval", func(s *dto.SelfTestSchedule) { s.IntervalDays = 0 }, true},
+		{"zero concurrency", func(s *dto.SelfTestSchedule) { s.MaxConcurrent = 0 }, true},
+		{"hour out of range", func(s *dto.SelfTestSchedule) { s.WindowEndHour = 24 }, true},
+		{"empty disk name", func(s *dto.SelfTestSchedule) { s.Disks = []string{""} }, true},
+	}
+	for _, tt := range tests {
+		schedule := DefaultSchedule()
+		tt.modify(&schedule)
+		if err := ValidateSchedule(&schedule); (err != nil) != tt.wantErr {
+			t.Errorf("%s: error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestInWindow(t *testing.T) {
+	at := func(hour int) time.Time { return time.Date(2026, 10, 1, hour, 30, 0, 0, time.Local) }
+	tests := []struct {
+		start, end, hour int
+		want             bool
+	}{
+		{0, 0, 13, true},
+		{1, 6, 3, true},
+		{1, 6, 6, false},
+		{22, 6, 23, true},
+		{22, 6, 2, true},
+		{22, 6, 12, false},
+	}
+	for _, tt := range tests {
+		schedule := dto.SelfTestSchedule{WindowStartHour: tt.start, WindowEndHour: tt.end}
+		if got := inWindow(schedule, at(tt.hour)); got != tt.want {
+			t.Errorf("inWindow(%d-%d, %d:30) = %v, want %v", tt.start, tt.end, tt.hour, got, tt.want)
+		}
+	}
+}
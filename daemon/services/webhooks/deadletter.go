@@ -0,0 +1,171 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/dto"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/lib"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/logger"
+)
+
+// ErrDeadLetterNotFound is returned when a dead-letter ID does not exist.
+var ErrDeadLetterNotFound = errors.New("dead-letter delivery not found")
+
+// ErrDeadLetterTruncated is returned when retrying a delivery whose body was too long to keep.
+var ErrDeadLetterTruncated = errors.New("dead-letter delivery body was truncated and cannot be retried")
+
+// deadLetter records a failed delivery and schedules a save of the queue. The oldest entries are
+// dropped once maxDeadLetters is reached, and long bodies are truncated to maxDeadLetterBody.
+func (d *Dispatcher) deadLetter(dl *delivery, err error) {
+	logger.Warning("Webhooks: Giving up on %s delivery to %s after %d attempts: %v",
+		dl.topic, dl.target.Name, dl.attempts, err)
+
+	body, truncated := dl.body, false
+	if len(body) > maxDeadLetterBody {
+		body, truncated = body[:maxDeadLetterBody], true
+	}
+
+	d.mu.Lock()
+	d.deadLetters = append(d.deadLetters, dto.WebhookDelivery{
+		ID:         dl.id,
+		TargetID:   dl.target.ID,
+		TargetName: dl.target.Name,
+		Topic:      dl.topic,
+		Body:       string(body),
+		Truncated:  truncated,
+		Attempts:   dl.attempts,
+		LastError:  err.Error(),
+		CreatedAt:  dl.created,
+		FailedAt:   time.Now().UTC(),
+	})
+	if n := len(d.deadLetters); n > maxDeadLetters {
+		d.deadLetters = append([]dto.WebhookDelivery{}, d.deadLetters[n-maxDeadLetters:]...)
+	}
+	d.mu.Unlock()
+
+	d.deadLettersUpdated()
+}
+
+// deadLettersUpdated schedules a save of the dead-letter queue without waiting for it.
+func (d *Dispatcher) deadLettersUpdated() {
+	select {
+	case d.deadLettersChanged <- struct{}{}:
+	default:
+	}
+}
+
+// persistDeadLetters saves the dead-letter queue deadLetterSaveDelay after it changed, so a
+// target that keeps failing causes one write per delay instead of one per delivery.
+func (d *Dispatcher) persistDeadLetters(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-d.deadLettersChanged:
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(deadLetterSaveDelay):
+		}
+		d.saveDeadLetters()
+	}
+}
+
+// saveDeadLetters writes the dead-letter queue to disk. The file is written outside d.mu so
+// deliveries and hub events are not held up by the flash drive.
+func (d *Dispatcher) saveDeadLetters() {
+	if d.deadLetterPath == "" {
+		return
+	}
+
+	d.saveMu.Lock()
+	defer d.saveMu.Unlock()
+
+	d.mu.Lock()
+	list := append([]dto.WebhookDelivery{}, d.deadLetters...)
+	d.mu.Unlock()
+
+	if err := lib.WriteJSONFile(d.deadLetterPath, list); err != nil {
+		logger.Error("Webhooks: Failed to persist dead-letter queue: %v", err)
+	}
+}
+
+// DeadLetters returns the failed deliveries, oldest first.
+func (d *Dispatcher) DeadLetters() []dto.WebhookDelivery {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]dto.WebhookDelivery{}, d.deadLetters...)
+}
+
+// RetryDeadLetter removes a failed delivery from the queue and sends it again to the current
+// configuration of its target.
+func (d *Dispatcher) RetryDeadLetter(id string) error {
+	d.mu.Lock()
+	i := d.deadLetterIndex(id)
+	if i < 0 {
+		d.mu.Unlock()
+		return ErrDeadLetterNotFound
+	}
+	failed := d.deadLetters[i]
+	if failed.Truncated {
+		d.mu.Unlock()
+		return ErrDeadLetterTruncated
+	}
+	t := d.indexOf(failed.TargetID)
+	if t < 0 {
+		d.mu.Unlock()
+		return ErrTargetNotFound
+	}
+	target := d.targets[t]
+	d.removeDeadLetter(i)
+	d.mu.Unlock()
+
+	d.enqueue(&delivery{
+		id:      failed.ID,
+		target:  target,
+		topic:   failed.Topic,
+		body:    []byte(failed.Body),
+		created: failed.CreatedAt,
+	})
+	return nil
+}
+
+// DeleteDeadLetter discards one failed delivery.
+func (d *Dispatcher) DeleteDeadLetter(id string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	i := d.deadLetterIndex(id)
+	if i < 0 {
+		return ErrDeadLetterNotFound
+	}
+	d.removeDeadLetter(i)
+	return nil
+}
+
+// ClearDeadLetters discards every failed delivery.
+func (d *Dispatcher) ClearDeadLetters() {
+	d.mu.Lock()
+	d.deadLetters = nil
+	d.mu.Unlock()
+
+	d.deadLettersUpdated()
+}
+
+// removeDeadLetter deletes entry i and schedules a save of the queue. Callers hold d.mu.
+func (d *Dispatcher) removeDeadLetter(i int) {
+	d.deadLetters = append(append([]dto.WebhookDelivery{}, d.deadLetters[:i]...), d.deadLetters[i+1:]...)
+	d.deadLettersUpdated()
+}
+
+func (d *Dispatcher) deadLetterIndex(id string) int {
+	for i, dl := range d.deadLetters {
+		if dl.ID == id {
+			return i
+		}
+	}
+	return -1
+}
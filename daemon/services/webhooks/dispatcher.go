@@ -0,0 +1,335 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/cskr/pubsub"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/constants"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/dto"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/lib"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/logger"
+)
+
+// Delivery tuning.
+const (
+	queueSize      = 256
+	workers        = 4
+	maxAttempts    = 5
+	initialBackoff = 2 * time.Second
+	maxBackoff     = 5 * time.Minute
+	requestTimeout = 10 * time.Second
+	maxDeadLetters = 500
+	// maxDeadLetterBody caps the body kept for a failed delivery; longer bodies are truncated
+	// and can no longer be retried
+	maxDeadLetterBody = 8 * 1024
+	// deadLetterSaveDelay batches dead-letter queue changes into one write of the file, which
+	// lives on the flash drive by default
+	deadLetterSaveDelay = 5 * time.Minute
+)
+
+// Request headers sent with every delivery.
+const (
+	HeaderEvent     = "X-UMA-Event"
+	HeaderDelivery  = "X-UMA-Delivery"
+	HeaderSignature = "X-UMA-Signature"
+)
+
+// TestTopic is the topic of deliveries sent by SendTest.
+const TestTopic = "webhook_test"
+
+// delivery is one queued POST of a rendered body to a target.
+type delivery struct {
+	id       string
+	target   dto.WebhookTarget
+	topic    string
+	body     []byte
+	attempts int
+	created  time.Time
+}
+
+// Dispatcher renders hub events for matching targets and delivers them.
+type Dispatcher struct {
+	path           string
+	deadLetterPath string
+	hub            *pubsub.PubSub
+	client         *http.Client
+	queue          chan *delivery
+
+	mu          sync.Mutex
+	targets     []dto.WebhookTarget
+	templates   map[string]*template.Template
+	deadLetters []dto.WebhookDelivery
+	dropped     map[string]int64 // deliveries per target dropped because the queue was full
+
+	// deadLettersChanged schedules a save of the dead-letter queue; saveMu orders the saves
+	deadLettersChanged chan struct{}
+	saveMu             sync.Mutex
+
+	// backoff returns the wait before the given retry attempt; replaced in tests
+	backoff func(attempt int) time.Duration
+}
+
+// NewDispatcher loads the targets stored at path and the dead-letter queue stored next to it.
+// An empty path keeps both in memory only.
+func NewDispatcher(path string, hub *pubsub.PubSub) (*Dispatcher, error) {
+	d := &Dispatcher{
+		path:      path,
+		hub:       hub,
+		client:    &http.Client{Timeout: requestTimeout},
+		queue:     make(chan *delivery, queueSize),
+		templates: make(map[string]*template.Template),
+		dropped:   make(map[string]int64),
+		backoff:   exponentialBackoff,
+
+		deadLettersChanged: make(chan struct{}, 1),
+	}
+	if path != "" {
+		d.deadLetterPath = strings.TrimSuffix(path, ".json") + "-deadletter.json"
+	}
+
+	if _, err := lib.ReadJSONFile(path, &d.targets); err != nil {
+		return nil, err
+	}
+	for i := range d.targets {
+		tmpl, err := ValidateTarget(&d.targets[i])
+		if err != nil {
+			return nil, fmt.Errorf("invalid webhook %q: %w", d.targets[i].ID, err)
+		}
+		d.templates[d.targets[i].ID] = tmpl
+	}
+	if _, err := lib.ReadJSONFile(d.deadLetterPath, &d.deadLetters); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// exponentialBackoff doubles the wait after each failed attempt, capped at maxBackoff.
+func exponentialBackoff(attempt int) time.Duration {
+	wait := initialBackoff << (attempt - 1)
+	if wait <= 0 || wait > maxBackoff {
+		return maxBackoff
+	}
+	return wait
+}
+
+// Run delivers events from every collector and agent topic until ctx is cancelled.
+func (d *Dispatcher) Run(ctx context.Context) {
+	saved := make(chan struct{})
+	go func() {
+		defer close(saved)
+		d.persistDeadLetters(ctx)
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			d.work(ctx)
+		}()
+	}
+
+	// Subscribe to each topic separately so the topic name is known for filtering
+	for _, topic := range constants.AllTopics() {
+		ch := d.hub.Sub(topic)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					d.hub.Unsub(ch)
+					return
+				case msg := <-ch:
+					d.Publish(topic, msg)
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+	<-saved
+	// Deliveries given up on while shutting down are saved too
+	d.saveDeadLetters()
+	logger.Info("Webhook dispatcher stopping due to context cancellation")
+}
+
+// Publish renders an event for every enabled target subscribed to topic and queues it.
+func (d *Dispatcher) Publish(topic string, data interface{}) {
+	event := dto.WSEvent{Event: topic, Timestamp: time.Now().UTC(), Data: data}
+
+	d.mu.Lock()
+	var pending []*delivery
+	for _, target := range d.targets {
+		if !target.Enabled || !matches(target, topic) {
+			continue
+		}
+		body, err := render(d.templates[target.ID], event)
+		if err != nil {
+			logger.Warning("Webhooks: Failed to render %s payload for %s: %v", topic, target.Name, err)
+			continue
+		}
+		pending = append(pending, &delivery{target: target, topic: topic, body: body, created: event.Timestamp})
+	}
+	d.mu.Unlock()
+
+	for _, dl := range pending {
+		d.enqueue(dl)
+	}
+}
+
+// render produces the request body: the template output, or the WSEvent envelope as JSON.
+func render(tmpl *template.Template, event dto.WSEvent) ([]byte, error) {
+	if tmpl == nil {
+		return json.Marshal(event)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, event); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (d *Dispatcher) enqueue(dl *delivery) {
+	if dl.id == "" {
+		id, err := randomID()
+		if err != nil {
+			logger.Error("Webhooks: Failed to generate delivery ID: %v", err)
+			return
+		}
+		dl.id = id
+	}
+
+	// Never block or touch the disk here: Publish runs on the hub's subscriber goroutines, and
+	// a stalled subscriber stalls every collector
+	select {
+	case d.queue <- dl:
+	default:
+		d.mu.Lock()
+		d.dropped[dl.target.ID]++
+		n := d.dropped[dl.target.ID]
+		d.mu.Unlock()
+		if n%100 == 1 {
+			logger.Warning("Webhooks: Delivery queue full, dropped %d deliveries to %s so far", n, dl.target.Name)
+		}
+	}
+}
+
+func (d *Dispatcher) work(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case dl := <-d.queue:
+			d.deliverWithRetry(ctx, dl)
+		}
+	}
+}
+
+// deliverWithRetry attempts a delivery up to maxAttempts times with exponential backoff.
+// Client errors other than 408 and 429 are not retried.
+func (d *Dispatcher) deliverWithRetry(ctx context.Context, dl *delivery) {
+	for {
+		dl.attempts++
+		retryable, err := d.send(ctx, dl)
+		if err == nil {
+			logger.Debug("Webhooks: Delivered %s to %s", dl.topic, dl.target.Name)
+			return
+		}
+		if ctx.Err() != nil {
+			d.deadLetter(dl, fmt.Errorf("agent shutting down: %w", err))
+			return
+		}
+		if !retryable || dl.attempts >= maxAttempts {
+			d.deadLetter(dl, err)
+			return
+		}
+
+		wait := d.backoff(dl.attempts)
+		logger.Debug("Webhooks: Delivery of %s to %s failed (attempt %d), retrying in %s: %v",
+			dl.topic, dl.target.Name, dl.attempts, wait, err)
+		select {
+		case <-ctx.Done():
+			d.deadLetter(dl, fmt.Errorf("agent shutting down: %w", err))
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+// send performs one delivery attempt and reports whether a failure may be retried.
+func (d *Dispatcher) send(ctx context.Context, dl *delivery) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, dl.target.URL, bytes.NewReader(dl.body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", dl.target.ContentType)
+	req.Header.Set("User-Agent", "unraid-management-agent")
+	req.Header.Set(HeaderEvent, dl.topic)
+	req.Header.Set(HeaderDelivery, dl.id)
+	if dl.target.Secret != "" {
+		req.Header.Set(HeaderSignature, "sha256="+Sign(dl.target.Secret, dl.body))
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode < 300 {
+		return false, nil
+	}
+	retryable := resp.StatusCode >= 500 || resp.StatusCode == http.StatusRequestTimeout || resp.StatusCode == http.StatusTooManyRequests
+	return retryable, fmt.Errorf("unexpected status %s", resp.Status)
+}
+
+// Sign returns the hex HMAC-SHA256 of body keyed with secret, as sent in the X-UMA-Signature
+// header (prefixed with "sha256=").
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// SendTest delivers a test event to a target once, without retries, and returns the result.
+func (d *Dispatcher) SendTest(ctx context.Context, id string) error {
+	d.mu.Lock()
+	i := d.indexOf(id)
+	if i < 0 {
+		d.mu.Unlock()
+		return ErrTargetNotFound
+	}
+	target := d.targets[i]
+	tmpl := d.templates[id]
+	d.mu.Unlock()
+
+	event := dto.WSEvent{
+		Event:     TestTopic,
+		Timestamp: time.Now().UTC(),
+		Data:      map[string]string{"message": "Test delivery from Unraid Management Agent"},
+	}
+	body, err := render(tmpl, event)
+	if err != nil {
+		return fmt.Errorf("failed to render payload: %w", err)
+	}
+	deliveryID, err := randomID()
+	if err != nil {
+		return err
+	}
+	_, err = d.send(ctx, &delivery{id: deliveryID, target: target, topic: TestTopic, body: body, created: event.Timestamp})
+	return err
+}
@@ -0,0 +1,322 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cskr/pubsub"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/constants"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/dto"
+)
+
+type received struct {
+	header http.Header
+	body   []byte
+}
+
+// receiver is a test endpoint that answers with the given status codes in turn, repeating the last one.
+func receiver(t *testing.T, statuses ...int) (*httptest.Server, chan received, *int32) {
+	t.Helper()
+	ch := make(chan received, 16)
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(atomic.AddInt32(&calls, 1))
+		body, _ := io.ReadAll(r.Body)
+		ch <- received{header: r.Header.Clone(), body: body}
+		status := statuses[len(statuses)-1]
+		if n <= len(statuses) {
+			status = statuses[n-1]
+		}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, ch, &calls
+}
+
+func newTestDispatcher(t *testing.T, path string) *Dispatcher {
+	t.Helper()
+	d, err := NewDispatcher(path, pubsub.New(16))
+	if err != nil {
+		t.Fatalf("NewDispatcher error: %v", err)
+	}
+	d.backoff = func(int) time.Duration { return time.Millisecond }
+	return d
+}
+
+func createTarget(t *testing.T, d *Dispatcher, target dto.WebhookTarget) dto.WebhookTarget {
+	t.Helper()
+	created, err := d.CreateTarget(target)
+	if err != nil {
+		t.Fatalf("CreateTarget error: %v", err)
+	}
+	return created
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for condition")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestValidateTarget(t *testing.T) {
+	tests := []struct {
+		name    string
+		target  dto.WebhookTarget
+		wantErr bool
+	}{
+		{"valid", dto.WebhookTarget{Name: "n8n", URL: "https://n8n.local/hook", Topics: []string{"alert_event"}}, false},
+		{"all topics", dto.WebhookTarget{Name: "n8n", URL: "http://n8n.local/hook", Topics: []string{"*"}}, false},
+		{"missing name", dto.WebhookTarget{URL: "https://n8n.local/hook", Topics: []string{"*"}}, true},
+		{"bad url", dto.WebhookTarget{Name: "n8n", URL: "ftp://n8n.local", Topics: []string{"*"}}, true},
+		{"no topics", dto.WebhookTarget{Name: "n8n", URL: "https://n8n.local/hook"}, true},
+		{"unknown topic", dto.WebhookTarget{Name: "n8n", URL: "https://n8n.local/hook", Topics: []string{"update"}}, true},
+		{"bad template", dto.WebhookTarget{Name: "n8n", URL: "https://n8n.local/hook", Topics: []string{"*"}, Template: "{{ .Event "}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := tt.target
+			_, err := ValidateTarget(&target)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ValidateTarget() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && target.ContentType != "application/json" {
+				t.Errorf("ContentType = %q, want default", target.ContentType)
+			}
+		})
+	}
+}
+
+func TestRunDeliversMatchingTopicsWithSignature(t *testing.T) {
+	srv, ch, calls := receiver(t, http.StatusOK)
+	d := newTestDispatcher(t, "")
+	createTarget(t, d, dto.WebhookTarget{Name: "alerts", URL: srv.URL, Secret: "s3cret", Topics: []string{constants.TopicAlertEvent}, Enabled: true})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go d.Run(ctx)
+	time.Sleep(50 * time.Millisecond) // let Run subscribe
+
+	d.hub.Pub(&dto.SystemInfo{Hostname: "tower"}, constants.TopicSystemUpdate)
+	d.hub.Pub(&dto.AlertEvent{RuleName: "Hot disk", State: "firing"}, constants.TopicAlertEvent)
+
+	var got received
+	select {
+	case got = <-ch:
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for delivery")
+	}
+
+	if got.header.Get(HeaderEvent) != constants.TopicAlertEvent || got.header.Get(HeaderDelivery) == "" {
+		t.Errorf("headers = %v", got.header)
+	}
+	if want := "sha256=" + Sign("s3cret", got.body); got.header.Get(HeaderSignature) != want {
+		t.Errorf("signature = %q, want %q", got.header.Get(HeaderSignature), want)
+	}
+
+	var event struct {
+		Event string         `json:"event"`
+		Data  dto.AlertEvent `json:"data"`
+	}
+	if err := json.Unmarshal(got.body, &event); err != nil {
+		t.Fatalf("body is not a WSEvent: %v", err)
+	}
+	if event.Event != constants.TopicAlertEvent || event.Data.RuleName != "Hot disk" {
+		t.Errorf("event = %+v", event)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	if n := atomic.LoadInt32(calls); n != 1 {
+		t.Errorf("received %d deliveries, want 1 (system_update is not subscribed)", n)
+	}
+}
+
+func TestPayloadTemplate(t *testing.T) {
+	srv, ch, _ := receiver(t, http.StatusOK)
+	d := newTestDispatcher(t, "")
+	createTarget(t, d, dto.WebhookTarget{
+		Name:        "discord",
+		URL:         srv.URL,
+		Topics:      []string{"*"},
+		Template:    `{"content": {{ json (printf "%s: %s" .Event .Data.Message) }}}`,
+		ContentType: "application/json",
+		Enabled:     true,
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go d.work(ctx)
+
+	d.Publish(constants.TopicAlertEvent, &dto.AlertEvent{Message: "disk5 is hot"})
+
+	select {
+	case got := <-ch:
+		if string(got.body) != `{"content": "alert_event: disk5 is hot"}` {
+			t.Errorf("body = %s", got.body)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for delivery")
+	}
+}
+
+func TestRetryThenSuccess(t *testing.T) {
+	srv, _, calls := receiver(t, http.StatusServiceUnavailable, http.StatusTooManyRequests, http.StatusOK)
+	d := newTestDispatcher(t, "")
+	createTarget(t, d, dto.WebhookTarget{Name: "flaky", URL: srv.URL, Topics: []string{"*"}, Enabled: true})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go d.work(ctx)
+
+	d.Publish(constants.TopicUPSStatusUpdate, &dto.UPSStatus{Status: "ONBATT"})
+	waitFor(t, func() bool { return atomic.LoadInt32(calls) == 3 })
+
+	time.Sleep(20 * time.Millisecond)
+	if dl := d.DeadLetters(); len(dl) != 0 {
+		t.Errorf("dead letters = %+v, want none", dl)
+	}
+}
+
+func TestDeadLetterQueue(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "webhooks.json")
+	srv, _, calls := receiver(t, http.StatusInternalServerError)
+	d := newTestDispatcher(t, path)
+	target := createTarget(t, d, dto.WebhookTarget{Name: "down", URL: srv.URL, Topics: []string{"*"}, Enabled: true})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go d.work(ctx)
+
+	d.Publish(constants.TopicArrayStatusUpdate, &dto.ArrayStatus{State: "STOPPED"})
+	waitFor(t, func() bool { return len(d.DeadLetters()) == 1 })
+
+	if n := atomic.LoadInt32(calls); n != maxAttempts {
+		t.Errorf("attempts = %d, want %d", n, maxAttempts)
+	}
+	failed := d.DeadLetters()[0]
+	if failed.TargetID != target.ID || failed.Topic != constants.TopicArrayStatusUpdate || failed.Attempts != maxAttempts {
+		t.Errorf("dead letter = %+v", failed)
+	}
+
+	// The queue is saved later, in one write, and survives a restart along with the targets
+	if len(newTestDispatcher(t, path).DeadLetters()) != 0 {
+		t.Error("dead-letter queue written on the delivery path")
+	}
+	d.saveDeadLetters()
+	reloaded := newTestDispatcher(t, path)
+	if len(reloaded.DeadLetters()) != 1 || len(reloaded.Targets()) != 1 {
+		t.Fatalf("reloaded dead letters = %d, targets = %d", len(reloaded.DeadLetters()), len(reloaded.Targets()))
+	}
+
+	// Retrying moves the delivery back onto the queue
+	if err := d.RetryDeadLetter(failed.ID); err != nil {
+		t.Fatalf("RetryDeadLetter error: %v", err)
+	}
+	if err := d.RetryDeadLetter(failed.ID); !errors.Is(err, ErrDeadLetterNotFound) {
+		t.Errorf("second RetryDeadLetter error = %v, want ErrDeadLetterNotFound", err)
+	}
+	waitFor(t, func() bool { return len(d.DeadLetters()) == 1 })
+
+	d.ClearDeadLetters()
+	if len(d.DeadLetters()) != 0 {
+		t.Error("ClearDeadLetters left entries behind")
+	}
+}
+
+func TestFullQueueDropsDeliveries(t *testing.T) {
+	d := newTestDispatcher(t, "")
+	target := createTarget(t, d, dto.WebhookTarget{Name: "down", URL: "http://127.0.0.1:1", Topics: []string{"*"}, Enabled: true})
+
+	// No workers run, so the queue fills up and the rest is dropped without blocking
+	for i := 0; i < queueSize+5; i++ {
+		d.Publish(constants.TopicSystemUpdate, &dto.SystemInfo{})
+	}
+	if got, err := d.Target(target.ID); err != nil || got.Dropped != 5 {
+		t.Errorf("dropped = %d, %v; want 5", got.Dropped, err)
+	}
+	if dl := d.DeadLetters(); len(dl) != 0 {
+		t.Errorf("dropped deliveries were dead-lettered: %d", len(dl))
+	}
+}
+
+func TestDeadLetterBodyTruncated(t *testing.T) {
+	d := newTestDispatcher(t, "")
+	target := createTarget(t, d, dto.WebhookTarget{Name: "down", URL: "http://127.0.0.1:1", Topics: []string{"*"}, Enabled: true})
+
+	d.deadLetter(&delivery{id: "big", target: target, topic: constants.TopicDiskListUpdate, body: make([]byte, maxDeadLetterBody+1), attempts: maxAttempts}, errors.New("connection refused"))
+	failed := d.DeadLetters()[0]
+	if !failed.Truncated || len(failed.Body) != maxDeadLetterBody {
+		t.Errorf("dead letter body = %d bytes, truncated %v", len(failed.Body), failed.Truncated)
+	}
+	if err := d.RetryDeadLetter("big"); !errors.Is(err, ErrDeadLetterTruncated) {
+		t.Errorf("RetryDeadLetter error = %v, want ErrDeadLetterTruncated", err)
+	}
+}
+
+func TestClientErrorsAreNotRetried(t *testing.T) {
+	srv, _, calls := receiver(t, http.StatusUnauthorized)
+	d := newTestDispatcher(t, "")
+	createTarget(t, d, dto.WebhookTarget{Name: "auth", URL: srv.URL, Topics: []string{"*"}, Enabled: true})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go d.work(ctx)
+
+	d.Publish(constants.TopicAlertEvent, &dto.AlertEvent{})
+	waitFor(t, func() bool { return len(d.DeadLetters()) == 1 })
+	if n := atomic.LoadInt32(calls); n != 1 {
+		t.Errorf("attempts = %d, want 1", n)
+	}
+}
+
+func TestTargetSecretHandling(t *testing.T) {
+	srv, ch, _ := receiver(t, http.StatusOK)
+	d := newTestDispatcher(t, "")
+	created := createTarget(t, d, dto.WebhookTarget{Name: "signed", URL: srv.URL, Secret: "key", Topics: []string{"*"}, Enabled: true})
+	if created.Secret != "" || !created.HasSecret {
+		t.Fatalf("created target leaks or lost its secret: %+v", created)
+	}
+
+	// Updating without a secret keeps the existing one
+	update := created
+	update.Name = "renamed"
+	if _, err := d.UpdateTarget(created.ID, update); err != nil {
+		t.Fatalf("UpdateTarget error: %v", err)
+	}
+	if err := d.SendTest(context.Background(), created.ID); err != nil {
+		t.Fatalf("SendTest error: %v", err)
+	}
+	got := <-ch
+	if got.header.Get(HeaderSignature) != "sha256="+Sign("key", got.body) || got.header.Get(HeaderEvent) != TestTopic {
+		t.Errorf("test delivery headers = %v", got.header)
+	}
+
+	if err := d.SendTest(context.Background(), "missing"); !errors.Is(err, ErrTargetNotFound) {
+		t.Errorf("SendTest(missing) error = %v", err)
+	}
+}
+
+func TestExponentialBackoff(t *testing.T) {
+	want := []time.Duration{2 * time.Second, 4 * time.Second, 8 * time.Second, 16 * time.Second}
+	for i, w := range want {
+		if got := exponentialBackoff(i + 1); got != w {
+			t.Errorf("exponentialBackoff(%d) = %v, want %v", i+1, got, w)
+		}
+	}
+	if got := exponentialBackoff(40); got != maxBackoff {
+		t.Errorf("exponentialBackoff(40) = %v, want %v", got, maxBackoff)
+	}
+}
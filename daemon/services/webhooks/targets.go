@@ -0,0 +1,220 @@
+// Package webhooks delivers hub events and alert firings to registered HTTP endpoints with
+// HMAC signatures, retries with exponential backoff and a persisted dead-letter queue.
+package webhooks
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/constants"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/dto"
+	"github.com/ruaan-deysel/unraid-management-agent/daemon/lib"
+)
+
+// ErrTargetNotFound is returned when a webhook target ID does not exist.
+var ErrTargetNotFound = errors.New("webhook target not found")
+
+// allTopics is the topic filter entry that matches every event.
+const allTopics = "*"
+
+const defaultContentType = "application/json"
+
+// templateFuncs are available in payload templates.
+var templateFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+}
+
+// NewTarget returns a target with the defaults applied to omitted fields of a create request.
+func NewTarget() dto.WebhookTarget {
+	return dto.WebhookTarget{Enabled: true}
+}
+
+// ValidateTarget normalizes and checks a target and returns its compiled payload template,
+// which is nil when the default JSON envelope is used.
+func ValidateTarget(target *dto.WebhookTarget) (*template.Template, error) {
+	target.Name = strings.TrimSpace(target.Name)
+	target.URL = strings.TrimSpace(target.URL)
+	target.ContentType = strings.TrimSpace(target.ContentType)
+
+	if target.Name == "" {
+		return nil, fmt.Errorf("webhook name cannot be empty")
+	}
+	if len(target.Name) > 64 {
+		return nil, fmt.Errorf("webhook name too long: maximum 64 characters, got %d", len(target.Name))
+	}
+	u, err := url.Parse(target.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid webhook URL: %s", target.URL)
+	}
+	if len(target.Topics) == 0 {
+		return nil, fmt.Errorf("at least one topic is required (use %q for all topics)", allTopics)
+	}
+	for _, topic := range target.Topics {
+		if topic != allTopics && !constants.IsTopic(topic) {
+			return nil, fmt.Errorf("unknown topic: %s", topic)
+		}
+	}
+	if target.ContentType == "" {
+		target.ContentType = defaultContentType
+	}
+	target.HasSecret = target.Secret != ""
+	target.Dropped = 0
+
+	if strings.TrimSpace(target.Template) == "" {
+		target.Template = ""
+		return nil, nil
+	}
+	tmpl, err := template.New(target.Name).Funcs(templateFuncs).Parse(target.Template)
+	if err != nil {
+		return nil, fmt.Errorf("invalid payload template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// matches reports whether the target subscribes to topic.
+func matches(target dto.WebhookTarget, topic string) bool {
+	for _, t := range target.Topics {
+		if t == allTopics || t == topic {
+			return true
+		}
+	}
+	return false
+}
+
+// redact removes the secret from a target returned by the API.
+func redact(target dto.WebhookTarget) dto.WebhookTarget {
+	target.Secret = ""
+	return target
+}
+
+// view returns a target as reported by the API, with its dropped delivery count. Callers hold d.mu.
+func (d *Dispatcher) view(target dto.WebhookTarget) dto.WebhookTarget {
+	target = redact(target)
+	target.Dropped = d.dropped[target.ID]
+	return target
+}
+
+func randomID() (string, error) {
+	buf := make([]byte, 4)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// Targets returns the configured targets without their secrets.
+func (d *Dispatcher) Targets() []dto.WebhookTarget {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	list := make([]dto.WebhookTarget, len(d.targets))
+	for i, target := range d.targets {
+		list[i] = d.view(target)
+	}
+	return list
+}
+
+// Target returns one target without its secret.
+func (d *Dispatcher) Target(id string) (dto.WebhookTarget, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if i := d.indexOf(id); i >= 0 {
+		return d.view(d.targets[i]), nil
+	}
+	return dto.WebhookTarget{}, ErrTargetNotFound
+}
+
+// CreateTarget validates, stores and persists a new target.
+func (d *Dispatcher) CreateTarget(target dto.WebhookTarget) (dto.WebhookTarget, error) {
+	tmpl, err := ValidateTarget(&target)
+	if err != nil {
+		return dto.WebhookTarget{}, err
+	}
+	if target.ID, err = randomID(); err != nil {
+		return dto.WebhookTarget{}, fmt.Errorf("failed to generate webhook ID: %w", err)
+	}
+	target.CreatedAt = time.Now().UTC()
+	target.UpdatedAt = target.CreatedAt
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.targets = append(d.targets, target)
+	if err := lib.WriteJSONFile(d.path, d.targets); err != nil {
+		d.targets = d.targets[:len(d.targets)-1]
+		return dto.WebhookTarget{}, err
+	}
+	d.templates[target.ID] = tmpl
+	return redact(target), nil
+}
+
+// UpdateTarget replaces the target with the given ID. An empty secret keeps the current one.
+func (d *Dispatcher) UpdateTarget(id string, target dto.WebhookTarget) (dto.WebhookTarget, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	i := d.indexOf(id)
+	if i < 0 {
+		return dto.WebhookTarget{}, ErrTargetNotFound
+	}
+	previous := d.targets[i]
+	if target.Secret == "" {
+		target.Secret = previous.Secret
+	}
+
+	tmpl, err := ValidateTarget(&target)
+	if err != nil {
+		return dto.WebhookTarget{}, err
+	}
+	target.ID = previous.ID
+	target.CreatedAt = previous.CreatedAt
+	target.UpdatedAt = time.Now().UTC()
+
+	d.targets[i] = target
+	if err := lib.WriteJSONFile(d.path, d.targets); err != nil {
+		d.targets[i] = previous
+		return dto.WebhookTarget{}, err
+	}
+	d.templates[target.ID] = tmpl
+	return d.view(target), nil
+}
+
+// DeleteTarget removes the target with the given ID.
+func (d *Dispatcher) DeleteTarget(id string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	i := d.indexOf(id)
+	if i < 0 {
+		return ErrTargetNotFound
+	}
+
+	previous := d.targets
+	d.targets = append(append([]dto.WebhookTarget{}, d.targets[:i]...), d.targets[i+1:]...)
+	if err := lib.WriteJSONFile(d.path, d.targets); err != nil {
+		d.targets = previous
+		return err
+	}
+	delete(d.templates, id)
+	delete(d.dropped, id)
+	return nil
+}
+
+func (d *Dispatcher) indexOf(id string) int {
+	for i, target := range d.targets {
+		if target.ID == id {
+			return i
+		}
+	}
+	return -1
+}
@@ -3,6 +3,7 @@
 package main
 
 import (
+	"fmt"
 	"io"
 	"log"
 	"os"
@@ -27,7 +28,29 @@ var cli struct {
 	Debug    bool   `default:"false" help:"enable debug mode with stdout logging"`
 	LogLevel string `default:"warning" help:"log level: debug, info, warning, error"`
 
-	Boot cmd.Boot `cmd:"" default:"1" help:"start the management agent"`
+	APIKeysFile string `default:"/boot/config/plugins/unraid-management-agent/apikeys.json" help:"file storing hashed API keys"`
+
+	TLS         bool   `default:"false" help:"serve HTTPS instead of HTTP"`
+	TLSCert     string `help:"TLS certificate file (a self-signed certificate is generated if omitted)"`
+	TLSKey      string `help:"TLS private key file"`
+	TLSClientCA string `name:"tls-client-ca" help:"CA bundle used to require and verify client certificates (mutual TLS)"`
+
+	MQTTBroker          string `name:"mqtt-broker" help:"MQTT broker URL, e.g. tcp://192.168.1.10:1883 (MQTT is disabled when empty)"`
+	MQTTUsername        string `name:"mqtt-username" help:"MQTT username"`
+	MQTTPasswordFile    string `name:"mqtt-password-file" help:"file containing the MQTT password (defaults to the MQTT_PASSWORD environment variable)"`
+	MQTTClientID        string `name:"mqtt-client-id" help:"MQTT client ID (defaults to unraid-management-agent-<hostname>)"`
+	MQTTTopicPrefix     string `name:"mqtt-topic-prefix" default:"unraid" help:"prefix for published MQTT state and command topics"`
+	MQTTDiscoveryPrefix string `name:"mqtt-discovery-prefix" default:"homeassistant" help:"Home Assistant MQTT discovery prefix (empty disables discovery)"`
+
+	AlertRulesFile string `name:"alert-rules-file" default:"/boot/config/plugins/unraid-management-agent/alerts.json" help:"file storing alert rules"`
+	WebhooksFile   string `name:"webhooks-file" default:"/boot/config/plugins/unraid-management-agent/webhooks.json" help:"file storing webhook targets (failed deliveries are kept in <name>-deadletter.json)"`
+	SelfTestFile   string `name:"self-test-file" default:"/boot/config/plugins/unraid-management-agent/selftest.json" help:"file storing the SMART self-test schedule"`
+
+	HistoryFile      string `name:"history-file" default:"/var/local/unraid-management-agent/history.gob.gz" help:"file the metric history is persisted to; a path under /boot survives reboots but is only saved daily and on shutdown (empty keeps history in memory only)"`
+	HistoryRetention string `name:"history-retention" default:"5s:1h,1m:24h,15m:720h" help:"metric history tiers as resolution:retention pairs, finest first"`
+
+	Boot   cmd.Boot   `cmd:"" default:"1" help:"start the management agent"`
+	APIKey cmd.APIKey `cmd:"" name:"apikey" help:"manage API keys"`
 }
 
 func main() {
@@ -70,16 +93,65 @@ func main() {
 
 	log.Printf("Starting Unraid Management Agent v%s (log level: %s)", Version, cli.LogLevel)
 
+	mqttPassword, err := readMQTTPassword(cli.MQTTPasswordFile)
+	ctx.FatalIfErrorf(err)
+
 	// Create application context
 	appCtx := &domain.Context{
 		Config: domain.Config{
-			Version: Version,
-			Port:    cli.Port,
+			Version:     Version,
+			Port:        cli.Port,
+			APIKeysFile: cli.APIKeysFile,
+
+			TLSEnabled:      cli.TLS,
+			TLSCertFile:     cli.TLSCert,
+			TLSKeyFile:      cli.TLSKey,
+			TLSClientCAFile: cli.TLSClientCA,
+
+			MQTTBroker:          cli.MQTTBroker,
+			MQTTUsername:        cli.MQTTUsername,
+			MQTTPassword:        mqttPassword,
+			MQTTClientID:        cli.MQTTClientID,
+			MQTTTopicPrefix:     cli.MQTTTopicPrefix,
+			MQTTDiscoveryPrefix: cli.MQTTDiscoveryPrefix,
+
+			AlertRulesFile: cli.AlertRulesFile,
+			WebhooksFile:   cli.WebhooksFile,
+			SelfTestFile:   cli.SelfTestFile,
+
+			HistoryFile:      cli.HistoryFile,
+			HistoryRetention: cli.HistoryRetention,
 		},
 		Hub: pubsub.New(1024), // Buffer size for event bus
 	}
 
 	// Run the boot command
-	err := ctx.Run(appCtx)
+	err = ctx.Run(appCtx)
 	ctx.FatalIfErrorf(err)
 }
+
+// readMQTTPassword returns the MQTT password from the given file, or from the MQTT_PASSWORD
+// environment variable when no file is set. The password is never taken from the command line,
+// where it would show up in ps and /proc/<pid>/cmdline. The variable is cleared so the docker,
+// virsh and other commands the agent runs do not inherit it.
+func readMQTTPassword(path string) (string, error) {
+	if path == "" {
+		password := os.Getenv("MQTT_PASSWORD")
+		_ = os.Unsetenv("MQTT_PASSWORD")
+		return password, nil
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read MQTT password file: %w", err)
+	}
+	if info.Mode().Perm()&0o077 != 0 {
+		log.Printf("Warning: MQTT password file %s is readable by other users, restrict it with chmod 600", path)
+	}
+	// #nosec G304 - path comes from the command line of the agent
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read MQTT password file: %w", err)
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}